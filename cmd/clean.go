@@ -1,18 +1,22 @@
 package cmd
 
 import (
-	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/raucheacho/rosia-cli/internal/cleaner"
+	"github.com/raucheacho/rosia-cli/internal/config"
+	"github.com/raucheacho/rosia-cli/internal/profiles"
 	"github.com/raucheacho/rosia-cli/internal/scanner"
+	"github.com/raucheacho/rosia-cli/internal/sizecalc"
 	"github.com/raucheacho/rosia-cli/internal/telemetry"
-	"github.com/raucheacho/rosia-cli/internal/trash"
 	"github.com/raucheacho/rosia-cli/pkg/logger"
 	"github.com/raucheacho/rosia-cli/pkg/progress"
 	"github.com/raucheacho/rosia-cli/pkg/types"
@@ -25,6 +29,23 @@ var (
 	cleanRescan        bool
 	cleanDepth         int
 	cleanIncludeHidden bool
+	cleanAtomic        bool
+	cleanResume        bool
+	cleanQueue         bool
+	cleanFlushQueue    bool
+	cleanReportFile    string
+	cleanOutput        string
+	cleanIgnoreRunning bool
+	cleanSudo          bool
+	cleanMaxTotal      string
+	cleanRetain        string
+	cleanCategories    []string
+	cleanExclude       []string
+	cleanExcludePlugin []string
+
+	// cleanOut receives human-readable output; it is stderr when the
+	// report is printed as JSON so stdout stays machine-readable
+	cleanOut io.Writer = os.Stdout
 )
 
 // cleanCmd represents the clean command
@@ -35,7 +56,8 @@ var cleanCmd = &cobra.Command{
 Files are moved to trash by default for safety and can be restored later.
 
 The clean command scans directories for cleanable targets and removes them
-after confirmation. Deleted files are moved to ~/.rosia/trash and can be
+after confirmation. Without paths, the scan_paths of the configuration are
+cleaned. Deleted files are moved to the trash and can be
 restored using the 'restore' command.
 
 Flags:
@@ -44,6 +66,18 @@ Flags:
       --rescan              Rescan directories before cleaning
   -d, --depth int           Maximum depth to scan (0 = unlimited)
   -H, --include-hidden      Include hidden files and directories
+      --atomic              Restore all trashed targets if any target fails
+      --resume              Continue an interrupted clean without rescanning
+      --queue               Add targets to the deferred clean queue instead of cleaning
+      --flush-queue         Clean all targets in the deferred clean queue
+      --report-file string  Write the clean report as JSON to a file
+  -o, --output string       Report format: text or json (default "text")
+      --retain string       Keep trashed targets this long instead of trash_retention_days (e.g. 30d)
+      --category strings    Only clean targets of these categories
+      --exclude-category strings
+                            Never clean targets of these categories
+      --exclude-plugin strings
+                            Do not scan with these plugins
 
 Examples:
   # Clean current directory (with confirmation)
@@ -61,18 +95,48 @@ Examples:
   # Clean with depth limit
   rosia clean ~/projects --rescan --depth 3
 
+  # Clean everything or nothing
+  rosia clean ~/projects --atomic
+
+  # Continue a clean that was interrupted
+  rosia clean --resume
+
+  # Review now, clean later (e.g. from a nightly cron job)
+  rosia clean ~/projects --queue
+  rosia clean --flush-queue --yes
+
+  # Clean caches but keep dependencies
+  rosia clean ~/projects --exclude-category dependencies
+
+  # Keep a risky clean restorable for a month
+  rosia clean ~/projects/legacy --retain 30d
+
+  # Machine-readable report for CI and scripts
+  rosia clean . --yes --output json > report.json
+
 Safety Features:
   • Confirmation prompt before deletion (use --yes to skip)
   • Files moved to trash by default (restore with 'rosia restore')
   • Trash retention period configurable (default: 3 days)
   • Permission checks before deletion
 
+Hooks:
+  Shell commands listed under "hooks" in the configuration run around cleaning
+  (pre_clean, post_clean, pre_batch, post_batch). Per-target hooks receive
+  ROSIA_TARGET_PATH, ROSIA_TARGET_PROFILE and ROSIA_TARGET_SIZE; a failing
+  pre_clean hook skips its target.
+
 Tips:
   • Always review scan results before cleaning
   • Use --rescan to ensure fresh results
   • Avoid --no-trash unless you're certain
-  • Check trash with: ls ~/.rosia/trash`,
-	Args: cobra.MinimumNArgs(1),
+  • Check trash with: rosia trash stats`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cleanResume || cleanFlushQueue {
+			return cobra.NoArgs(cmd, args)
+		}
+		return nil
+	},
 	RunE: runClean,
 }
 
@@ -85,10 +149,81 @@ func init() {
 	cleanCmd.Flags().BoolVar(&cleanRescan, "rescan", false, "rescan directories before cleaning")
 	cleanCmd.Flags().IntVarP(&cleanDepth, "depth", "d", 0, "maximum depth to scan (0 = unlimited)")
 	cleanCmd.Flags().BoolVarP(&cleanIncludeHidden, "include-hidden", "H", false, "include hidden files and directories")
+	cleanCmd.Flags().BoolVar(&cleanAtomic, "atomic", false, "restore all trashed targets if any target fails")
+	cleanCmd.Flags().BoolVar(&cleanResume, "resume", false, "continue an interrupted clean without rescanning")
+	cleanCmd.Flags().BoolVar(&cleanQueue, "queue", false, "add targets to the deferred clean queue instead of cleaning")
+	cleanCmd.Flags().BoolVar(&cleanFlushQueue, "flush-queue", false, "clean all targets in the deferred clean queue")
+	cleanCmd.Flags().StringVar(&cleanReportFile, "report-file", "", "write the clean report as JSON to a file")
+	cleanCmd.Flags().StringVarP(&cleanOutput, "output", "o", "text", "report format: text or json")
+	cleanCmd.Flags().BoolVar(&cleanIgnoreRunning, "ignore-running", false, "clean targets even while a process, such as a build, is using them")
+	cleanCmd.Flags().StringVar(&cleanMaxTotal, "max-total", "", "stop after cleaning this much per run, e.g. 50GB (asks before exceeding it)")
+	cleanCmd.Flags().StringVar(&cleanRetain, "retain", "", "keep trashed targets this long instead of trash_retention_days, e.g. 30d")
+	cleanCmd.Flags().StringSliceVar(&cleanCategories, "category", nil, "only clean targets of these categories (dependencies, build, cache, coverage)")
+	cleanCmd.Flags().StringSliceVar(&cleanExclude, "exclude-category", nil, "never clean targets of these categories")
+	cleanCmd.Flags().StringSliceVar(&cleanExcludePlugin, "exclude-plugin", nil, "do not scan with these plugins, so their targets are not cleaned")
+	cleanCmd.Flags().BoolVar(&cleanSudo, "sudo", false, "retry targets that fail with permission errors using sudo (deletes them permanently)")
+	cleanCmd.MarkFlagsMutuallyExclusive("resume", "queue", "flush-queue")
 }
 
 func runClean(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
+	// Interrupting finishes the targets in progress and skips the rest
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// The clean section of the configuration sets the flags not given
+	defaults := GetGlobalConfig().Clean
+	if !cmd.Flags().Changed("no-trash") && defaults.UseTrash != nil {
+		cleanNoTrash = !*defaults.UseTrash
+	}
+	if !cmd.Flags().Changed("depth") && defaults.Depth > 0 {
+		cleanDepth = defaults.Depth
+	}
+	if !cmd.Flags().Changed("include-hidden") && defaults.IncludeHidden {
+		cleanIncludeHidden = true
+	}
+
+	if cleanAtomic && cleanNoTrash {
+		return fmt.Errorf("--atomic cannot be combined with --no-trash")
+	}
+	if cleanAtomic && cleanSudo {
+		return fmt.Errorf("--atomic cannot be combined with --sudo")
+	}
+
+	if err := validateCategories("category", cleanCategories); err != nil {
+		return err
+	}
+	if err := validateCategories("exclude-category", cleanExclude); err != nil {
+		return err
+	}
+	warnUnknownPlugins(cleanExcludePlugin)
+
+	var maxTotal int64
+	if cleanMaxTotal != "" {
+		var err error
+		if maxTotal, err = sizecalc.ParseSize(cleanMaxTotal); err != nil {
+			return fmt.Errorf("invalid --max-total: %w", err)
+		}
+	}
+
+	var retain time.Duration
+	if cleanRetain != "" {
+		if cleanNoTrash {
+			return fmt.Errorf("--retain cannot be combined with --no-trash")
+		}
+		var err error
+		if retain, err = profiles.ParseAge(cleanRetain); err != nil {
+			return fmt.Errorf("invalid --retain: %w", err)
+		}
+	}
+
+	switch cleanOutput {
+	case "text":
+		cleanOut = os.Stdout
+	case "json":
+		cleanOut = os.Stderr
+	default:
+		return fmt.Errorf("invalid output format %q: must be text or json", cleanOutput)
+	}
 
 	// Use global configuration and profile loader
 	cfg := GetGlobalConfig()
@@ -101,7 +236,7 @@ func runClean(cmd *cobra.Command, args []string) error {
 
 	// Initialize trash system
 	logger.Debug("Initializing trash system")
-	trashSystem, err := trash.NewDefaultSystem()
+	trashSystem, err := openTrash()
 	if err != nil {
 		logger.Error("Failed to initialize trash system: %v", err)
 		return fmt.Errorf("failed to initialize trash system: %w", err)
@@ -109,6 +244,9 @@ func runClean(cmd *cobra.Command, args []string) error {
 
 	// Create scanner
 	scan := scanner.NewScanner(profileLoader)
+	if registry := GetGlobalPluginRegistry(); registry != nil {
+		scan.SetPluginRegistry(registry)
+	}
 
 	// Initialize telemetry if enabled
 	var telemetryStore telemetry.TelemetryStore
@@ -123,27 +261,208 @@ func runClean(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	checkpointPath, err := cleaner.GetDefaultCheckpointPath()
+	if err != nil {
+		return fmt.Errorf("failed to initialize checkpoint: %w", err)
+	}
+
+	var targets []types.Target
+	var checkpoint *cleaner.Checkpoint
+	switch {
+	case cleanResume:
+		// Resume the interrupted run without rescanning or re-confirming
+		checkpoint, err = cleaner.LoadCheckpoint(checkpointPath)
+		if err != nil {
+			if _, ok := err.(types.ErrPathNotFound); ok {
+				fmt.Fprintln(cleanOut, "No interrupted clean operation to resume.")
+				return nil
+			}
+			return fmt.Errorf("failed to load checkpoint: %w", err)
+		}
+		cleanNoTrash = !checkpoint.UseTrash
+		targets = checkpoint.Pending()
+		fmt.Fprintf(cleanOut, "Resuming clean started at %s: %d of %d target(s) remaining\n",
+			checkpoint.CreatedAt.Format("2006-01-02 15:04:05"), len(targets), len(checkpoint.Targets))
+
+	case cleanFlushQueue:
+		targets, err = takeQueuedTargets()
+		if err != nil || len(targets) == 0 {
+			return err
+		}
+
+	default:
+		targets, err = scanCleanTargets(ctx, scan, cfg, args, !cleanQueue)
+		if err != nil || len(targets) == 0 {
+			return err
+		}
+		if cleanQueue {
+			return queueTargets(targets)
+		}
+	}
+
+	if maxTotal > 0 {
+		targets = limitToMaxTotal(targets, maxTotal)
+		if len(targets) == 0 {
+			fmt.Fprintln(cleanOut, "No targets fit within --max-total.")
+			return nil
+		}
+	}
+
+	if !cleanResume {
+		checkpoint, err = cleaner.NewCheckpoint(checkpointPath, targets, !cleanNoTrash)
+		if err != nil {
+			// Cleaning still works, it just cannot be resumed
+			logger.Warn("Failed to write clean checkpoint: %v", err)
+		}
+	}
+
+	if checkpoint != nil {
+		// Unless interrupted, the run completed and there is nothing left to resume
+		defer func() {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := checkpoint.Remove(); err != nil {
+				logger.Warn("%v", err)
+			}
+		}()
+	}
+
+	// Create cleaner
+	clean := cleaner.New(trashSystem)
+	if registry := GetGlobalPluginRegistry(); registry != nil {
+		clean.SetPluginRegistry(registry)
+	}
+
+	// Set telemetry store if enabled
+	if telemetryStore != nil {
+		clean.SetTelemetryStore(telemetryStore)
+		logger.Debug("Telemetry enabled for cleaner")
+	}
+
+	// Prepare clean options
+	cleanOpts := cleaner.CleanOptions{
+		SkipConfirmation: cleanYes,
+		UseTrash:         !cleanNoTrash,
+		Concurrency:      cfg.Concurrency,
+		Hooks: cleaner.NewShellHooks(
+			cfg.Hooks.PreClean,
+			cfg.Hooks.PostClean,
+			cfg.Hooks.PreBatch,
+			cfg.Hooks.PostBatch,
+		),
+		Atomic:            cleanAtomic,
+		IgnoreRunning:     cleanIgnoreRunning,
+		KeepPatterns:      cfg.KeepPatterns,
+		PermanentPatterns: cfg.PermanentPatterns,
+		Checkpoint:        checkpoint,
+		TrashRetention:    retain,
+		PluginTimeout:     time.Duration(cfg.PluginTimeoutSeconds) * time.Second,
+		ProtectedPaths:    cfg.ProtectedPaths,
+		Retry: cleaner.RetryPolicy{
+			MaxRetries: cfg.Retry.MaxRetries,
+			Backoff:    time.Duration(cfg.Retry.BackoffMs) * time.Millisecond,
+			MaxBackoff: time.Duration(cfg.Retry.MaxBackoffMs) * time.Millisecond,
+		},
+	}
+
+	// Retry root-owned targets (e.g. created by Docker) with elevated permissions
+	if cleanSudo {
+		deleter, err := newSudoDeleter(trashSystem.GetTrashDir())
+		if err != nil {
+			return err
+		}
+		cleanOpts.Elevate = func(failed int) cleaner.Deleter {
+			fmt.Fprintf(cleanOut, "\nRetrying %d target(s) with sudo. They will be deleted permanently.\n", failed)
+			return deleter
+		}
+	}
+
+	// Perform cleaning with progress
+	fmt.Fprintln(cleanOut, "\nCleaning targets...")
+	logger.Info("Starting clean operation for %d targets", len(targets))
+
+	// Atomic cleaning runs sequentially so a failure can be rolled back
+	if cleanAtomic {
+		report, err := clean.Clean(ctx, targets, cleanOpts)
+		if outErr := outputCleanReport(report); outErr != nil {
+			return outErr
+		}
+		if err != nil {
+			logger.Error("Atomic clean failed: %v", err)
+			return fmt.Errorf("clean failed: %w", err)
+		}
+		logger.Info("Clean completed successfully")
+		return nil
+	}
+
+	// Use async cleaning with progress bar
+	startTime := time.Now()
+	progressCh, err := clean.CleanAsync(ctx, targets, cleanOpts)
+	if err != nil {
+		logger.Error("Failed to start clean operation: %v", err)
+		return fmt.Errorf("failed to start clean operation: %w", err)
+	}
+
+	// Collect results with progress indication
+	report := collectCleanProgressWithBar(progressCh, startTime, len(targets))
+
+	// Display report
+	if err := outputCleanReport(report); err != nil {
+		return err
+	}
+
+	if len(report.Skipped) > 0 {
+		return fmt.Errorf("clean cancelled: %d target(s) skipped", len(report.Skipped))
+	}
+
+	if len(report.Errors) > 0 {
+		logger.Warn("Clean completed with %d errors", len(report.Errors))
+		// Return error if all targets failed
+		if report.FilesDeleted == 0 {
+			return fmt.Errorf("clean failed: all targets failed to clean")
+		}
+		// Partial success - don't return error
+	} else {
+		logger.Info("Clean completed successfully")
+	}
+
+	return nil
+}
+
+// scanCleanTargets scans args, displays the targets found and asks for
+// confirmation. It returns no targets when nothing should be cleaned.
+func scanCleanTargets(ctx context.Context, scan *scanner.Scanner, cfg *config.Config, args []string, confirm bool) ([]types.Target, error) {
 	// Prepare scan options
 	opts := scanner.ScanOptions{
-		MaxDepth:      cleanDepth,
-		IncludeHidden: cleanIncludeHidden,
-		IgnorePaths:   cfg.IgnorePaths,
-		Concurrency:   cfg.Concurrency,
+		MaxDepth:          cleanDepth,
+		IncludeHidden:     cleanIncludeHidden,
+		IgnorePaths:       cfg.IgnorePaths,
+		Concurrency:       cfg.Concurrency,
+		Categories:        cleanCategories,
+		ExcludeCategories: cleanExclude,
+		ExcludePlugins:    cleanExcludePlugin,
+		PluginTimeout:     time.Duration(cfg.PluginTimeoutSeconds) * time.Second,
+		ProtectedPaths:    protectedPaths(cfg),
 	}
 
 	// Resolve and validate paths
+	args, err := pathsOrScanPaths(args, cfg)
+	if err != nil {
+		return nil, err
+	}
 	scanPaths := make([]string, 0, len(args))
 	for _, path := range args {
 		absPath, err := filepath.Abs(path)
 		if err != nil {
 			logger.Error("Failed to resolve path %s: %v", path, err)
-			return fmt.Errorf("failed to resolve path %s: %w", path, err)
+			return nil, fmt.Errorf("failed to resolve path %s: %w", path, err)
 		}
 
 		// Check if path exists
 		if _, err := os.Stat(absPath); err != nil {
 			logger.Error("Path does not exist: %s", path)
-			return fmt.Errorf("path does not exist: %s", path)
+			return nil, fmt.Errorf("path does not exist: %s", path)
 		}
 
 		scanPaths = append(scanPaths, absPath)
@@ -155,12 +474,12 @@ func runClean(cmd *cobra.Command, args []string) error {
 	targets, err := scan.Scan(ctx, scanPaths, opts)
 	if err != nil {
 		logger.Error("Scan failed: %v", err)
-		return fmt.Errorf("scan failed: %w", err)
+		return nil, fmt.Errorf("scan failed: %w", err)
 	}
 
 	if len(targets) == 0 {
-		fmt.Println("No cleanable targets found.")
-		return nil
+		fmt.Fprintln(cleanOut, "No cleanable targets found.")
+		return nil, nil
 	}
 
 	// Calculate total size
@@ -170,9 +489,9 @@ func runClean(cmd *cobra.Command, args []string) error {
 	}
 
 	// Display targets
-	fmt.Printf("\nFound %d cleanable target(s):\n\n", len(targets))
-	fmt.Printf("%-50s %-15s %-15s\n", "PATH", "TYPE", "SIZE")
-	fmt.Println(strings.Repeat("-", 80))
+	fmt.Fprintf(cleanOut, "\nFound %d cleanable target(s):\n\n", len(targets))
+	fmt.Fprintf(cleanOut, "%-50s %-15s %-15s\n", "PATH", "TYPE", "SIZE")
+	fmt.Fprintln(cleanOut, strings.Repeat("-", 80))
 
 	for _, target := range targets {
 		path := target.Path
@@ -180,97 +499,114 @@ func runClean(cmd *cobra.Command, args []string) error {
 			path = "..." + path[len(path)-45:]
 		}
 
-		fmt.Printf("%-50s %-15s %-15s\n",
+		profile := targetOrigin(target)
+		if target.Permanent {
+			profile += " (perm.)"
+		}
+
+		fmt.Fprintf(cleanOut, "%-50s %-15s %-15s\n",
 			path,
-			target.ProfileName,
+			profile,
 			formatSize(target.Size),
 		)
 	}
 
-	fmt.Println(strings.Repeat("-", 80))
-	fmt.Printf("Total: %s across %d target(s)\n\n", formatSize(totalSize), len(targets))
+	fmt.Fprintln(cleanOut, strings.Repeat("-", 80))
+	fmt.Fprintf(cleanOut, "Total: %s across %d target(s)\n\n", formatSize(totalSize), len(targets))
 
 	// Confirmation prompt (unless --yes flag is set)
-	if !cleanYes {
+	if confirm && !cleanYes {
 		if !confirmClean(totalSize, len(targets)) {
-			fmt.Println("Clean operation cancelled.")
-			return nil
+			fmt.Fprintln(cleanOut, "Clean operation cancelled.")
+			return nil, nil
 		}
 	}
 
-	// Create cleaner
-	clean := cleaner.New(trashSystem)
+	return targets, nil
+}
 
-	// Set telemetry store if enabled
-	if telemetryStore != nil {
-		clean.SetTelemetryStore(telemetryStore)
-		logger.Debug("Telemetry enabled for cleaner")
+// queueTargets adds targets to the deferred clean queue
+func queueTargets(targets []types.Target) error {
+	queuePath, err := cleaner.GetDefaultQueuePath()
+	if err != nil {
+		return fmt.Errorf("failed to initialize clean queue: %w", err)
 	}
 
-	// Prepare clean options
-	cleanOpts := cleaner.CleanOptions{
-		SkipConfirmation: cleanYes,
-		UseTrash:         !cleanNoTrash,
-		Concurrency:      cfg.Concurrency,
+	queue, err := cleaner.LoadQueue(queuePath)
+	if err != nil {
+		return fmt.Errorf("failed to load clean queue: %w", err)
 	}
 
-	// Perform cleaning with progress
-	fmt.Println("\nCleaning targets...")
-	logger.Info("Starting clean operation for %d targets", len(targets))
+	added := queue.Add(targets)
+	if err := queue.Save(); err != nil {
+		return fmt.Errorf("failed to save clean queue: %w", err)
+	}
 
-	// Use async cleaning with progress bar
-	startTime := time.Now()
-	progressCh, err := clean.CleanAsync(ctx, targets, cleanOpts)
+	fmt.Fprintf(cleanOut, "Queued %d new target(s), %d target(s) pending.\n", added, len(queue.Entries))
+	fmt.Fprintln(cleanOut, "Run 'rosia clean --flush-queue' to clean them.")
+	return nil
+}
+
+// takeQueuedTargets empties the deferred clean queue and returns its targets
+// once the user confirmed cleaning them
+func takeQueuedTargets() ([]types.Target, error) {
+	queuePath, err := cleaner.GetDefaultQueuePath()
 	if err != nil {
-		logger.Error("Failed to start clean operation: %v", err)
-		return fmt.Errorf("failed to start clean operation: %w", err)
+		return nil, fmt.Errorf("failed to initialize clean queue: %w", err)
 	}
 
-	// Collect results with progress indication
-	report := collectCleanProgressWithBar(progressCh, startTime, len(targets))
+	queue, err := cleaner.LoadQueue(queuePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load clean queue: %w", err)
+	}
 
-	// Display report
-	displayCleanReport(report)
+	targets := queue.Targets()
+	if len(targets) == 0 {
+		fmt.Fprintln(cleanOut, "The clean queue is empty.")
+		return nil, nil
+	}
 
-	if len(report.Errors) > 0 {
-		logger.Warn("Clean completed with %d errors", len(report.Errors))
-		// Return error if all targets failed
-		if report.FilesDeleted == 0 {
-			return fmt.Errorf("clean failed: all targets failed to clean")
-		}
-		// Partial success - don't return error
-	} else {
-		logger.Info("Clean completed successfully")
+	var totalSize int64
+	for _, target := range targets {
+		totalSize += target.Size
 	}
 
-	return nil
+	fmt.Fprintf(cleanOut, "Flushing %d queued target(s) (%s)\n", len(targets), formatSize(totalSize))
+	if !cleanYes && !confirmClean(totalSize, len(targets)) {
+		fmt.Fprintln(cleanOut, "Clean operation cancelled.")
+		return nil, nil
+	}
+
+	// Targets that fail are reported and not queued again
+	queue.Clear()
+	if err := queue.Save(); err != nil {
+		return nil, fmt.Errorf("failed to save clean queue: %w", err)
+	}
+
+	return targets, nil
 }
 
 func collectCleanProgressWithBar(progressCh <-chan cleaner.CleanProgress, startTime time.Time, total int) *types.CleanReport {
-	report := &types.CleanReport{
-		TotalSize:    0,
-		FilesDeleted: 0,
-		Errors:       []types.CleanError{},
-		TrashedItems: []string{},
-	}
+	report := types.NewCleanReport()
 
 	// Create progress bar
-	bar := progress.NewSimpleBar(total, "Cleaning", os.Stdout)
+	bar := progress.NewSimpleBar(total, "Cleaning", cleanOut)
 
 	for prog := range progressCh {
-		if prog.Error != nil {
-			report.Errors = append(report.Errors, types.CleanError{
-				Target: prog.Target,
-				Error:  prog.Error,
-			})
-		} else {
-			report.TotalSize += prog.Target.Size
-			report.FilesDeleted++
+		prog.AddToReport(report)
+		if prog.Report != nil {
+			continue
 		}
 
-		// Update progress
-		bar.SetLabel(fmt.Sprintf("Cleaning (%d/%d)", prog.Current, prog.Total))
-		bar.Increment()
+		// Update progress; byte updates redraw the label without finishing a target
+		eta := progress.EstimateRemaining(time.Since(startTime), prog.BytesDone, prog.BytesTotal)
+		bar.SetLabel(fmt.Sprintf("Cleaning %s / %s, ETA %s",
+			formatSize(prog.BytesDone), formatSize(prog.BytesTotal), eta.Round(time.Second)))
+		finished := 1
+		if prog.Deleting {
+			finished = 0
+		}
+		bar.IncrementBy(finished)
 	}
 
 	bar.Finish()
@@ -280,55 +616,165 @@ func collectCleanProgressWithBar(progressCh <-chan cleaner.CleanProgress, startT
 }
 
 func confirmClean(totalSize int64, targetCount int) bool {
-	fmt.Printf("This will clean %s across %d target(s).\n", formatSize(totalSize), targetCount)
+	fmt.Fprintf(cleanOut, "This will clean %s across %d target(s).\n", formatSize(totalSize), targetCount)
 	if cleanNoTrash {
-		fmt.Println("WARNING: Files will be permanently deleted (--no-trash is set).")
+		fmt.Fprintln(cleanOut, "WARNING: Files will be permanently deleted (--no-trash is set).")
 	} else {
-		fmt.Println("Files will be moved to trash and can be restored later.")
+		fmt.Fprintln(cleanOut, "Files will be moved to trash and can be restored later.")
 	}
-	fmt.Print("\nDo you want to continue? [y/N]: ")
+	return promptYesNo(cleanOut, "\nDo you want to continue?")
+}
 
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
-		return false
+// limitToMaxTotal returns the leading targets whose combined size fits in
+// maxTotal. The targets past the limit are only kept if the user confirms.
+func limitToMaxTotal(targets []types.Target, maxTotal int64) []types.Target {
+	var total int64
+	for i, target := range targets {
+		if total+target.Size <= maxTotal {
+			total += target.Size
+			continue
+		}
+
+		var remainingSize int64
+		for _, remaining := range targets[i:] {
+			remainingSize += remaining.Size
+		}
+		fmt.Fprintf(cleanOut, "\n--max-total of %s reached: %d target(s) (%s) will not be cleaned.\n",
+			formatSize(maxTotal), len(targets)-i, formatSize(remainingSize))
+
+		if !cleanYes && promptYesNo(cleanOut, "Clean them as well?") {
+			return targets
+		}
+		return targets[:i]
 	}
+	return targets
+}
+
+// outputCleanReport writes the report to --report-file, if set, and displays
+// it in the format selected by --output
+func outputCleanReport(report *types.CleanReport) error {
+	if cleanReportFile != "" || cleanOutput == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal clean report: %w", err)
+		}
+
+		if cleanReportFile != "" {
+			if err := os.WriteFile(cleanReportFile, data, 0644); err != nil {
+				return fmt.Errorf("failed to write report file %s: %w", cleanReportFile, err)
+			}
+			logger.Debug("Clean report written to %s", cleanReportFile)
+		}
 
-	response = strings.TrimSpace(strings.ToLower(response))
-	return response == "y" || response == "yes"
+		if cleanOutput == "json" {
+			fmt.Println(string(data))
+			return nil
+		}
+	}
+
+	displayCleanReport(report)
+	return nil
+}
+
+// formatProfileSummary formats a per-profile breakdown line like
+// "12 targets, 8.2 GB, 1 error"
+func formatProfileSummary(summary *types.ProfileSummary) string {
+	line := fmt.Sprintf("%d %s, %s", summary.Targets, plural(summary.Targets, "target"), formatSize(summary.Size))
+	if summary.Errors > 0 {
+		line += fmt.Sprintf(", %d %s", summary.Errors, plural(summary.Errors, "error"))
+	}
+	return line
+}
+
+// plural returns word with an "s" appended unless n is 1
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
 }
 
 func displayCleanReport(report *types.CleanReport) {
-	fmt.Println("\n" + strings.Repeat("=", 80))
-	fmt.Println("CLEAN REPORT")
-	fmt.Println(strings.Repeat("=", 80))
+	fmt.Fprintln(cleanOut, "\n"+strings.Repeat("=", 80))
+	fmt.Fprintln(cleanOut, "CLEAN REPORT")
+	fmt.Fprintln(cleanOut, strings.Repeat("=", 80))
 
-	fmt.Printf("Files Deleted:  %d\n", report.FilesDeleted)
-	fmt.Printf("Space Reclaimed: %s\n", formatSize(report.TotalSize))
-	fmt.Printf("Duration:       %s\n", report.Duration)
+	fmt.Fprintf(cleanOut, "Files Deleted:  %d\n", report.FilesDeleted)
+	fmt.Fprintf(cleanOut, "Estimated Size:  %s\n", formatSize(report.TotalSize))
+	fmt.Fprintf(cleanOut, "Space Reclaimed: %s\n", formatSize(report.ReclaimedSize))
+	if len(report.TrashedItems) > 0 && !cleanNoTrash {
+		fmt.Fprintln(cleanOut, "                 (trashed items use disk space until the trash is emptied)")
+	}
+	fmt.Fprintf(cleanOut, "Duration:       %s\n", report.Duration)
 
 	if len(report.TrashedItems) > 0 {
-		fmt.Printf("Trashed Items:  %d\n", len(report.TrashedItems))
+		fmt.Fprintf(cleanOut, "Trashed Items:  %d\n", len(report.TrashedItems))
 		if verbose {
-			fmt.Println("\nTrashed IDs:")
+			fmt.Fprintln(cleanOut, "\nTrashed IDs:")
 			for _, id := range report.TrashedItems {
-				fmt.Printf("  - %s\n", id)
+				fmt.Fprintf(cleanOut, "  - %s\n", id)
 			}
 		}
 	}
 
+	if len(report.Profiles) > 0 {
+		fmt.Fprintln(cleanOut, "\nBy Profile:")
+		for _, name := range report.ProfileNames() {
+			fmt.Fprintf(cleanOut, "  %-20s %s\n", name+":", formatProfileSummary(report.Profiles[name]))
+		}
+	}
+
 	if len(report.Errors) > 0 {
-		fmt.Printf("\nErrors:         %d\n", len(report.Errors))
-		fmt.Println("\nFailed targets:")
-		for _, cleanErr := range report.Errors {
-			fmt.Printf("  - %s: %v\n", cleanErr.Target.Path, cleanErr.Error)
+		fmt.Fprintf(cleanOut, "\nErrors:         %d\n", len(report.Errors))
+		for _, group := range report.GroupErrors() {
+			fmt.Fprintf(cleanOut, "\n%s (%d):\n", group.Code.Label(), len(group.Errors))
+			for _, cleanErr := range group.Errors {
+				fmt.Fprintf(cleanOut, "  - %s: %v\n", cleanErr.Target.Path, cleanErr.Error)
+			}
+			if hint := group.Code.Hint(); hint != "" {
+				fmt.Fprintf(cleanOut, "  Hint: %s\n", hint)
+			}
 		}
 	}
 
-	fmt.Println(strings.Repeat("=", 80))
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(cleanOut, "\nSkipped:        %d (cancelled)\n", len(report.Skipped))
+		if verbose {
+			for _, target := range report.Skipped {
+				fmt.Fprintf(cleanOut, "  - %s\n", target.Path)
+			}
+		}
+	}
+
+	fmt.Fprintln(cleanOut, strings.Repeat("=", 80))
 
 	if len(report.TrashedItems) > 0 && !cleanNoTrash {
-		fmt.Println("\nTo restore a trashed item, use: rosia restore <trash-id>")
-		fmt.Println("To list all trashed items, use: rosia restore --list")
+		fmt.Fprintln(cleanOut, "\nTo restore a trashed item, use: rosia restore <trash-id>")
+		fmt.Fprintln(cleanOut, "To list all trashed items, use: rosia restore --list")
+	}
+
+	if len(report.Skipped) > 0 {
+		fmt.Fprintln(cleanOut, "\nTo clean the skipped targets, use: rosia clean --resume")
+	}
+
+	displayRebuildHints(report)
+}
+
+// displayRebuildHints prints how to regenerate what each profile cleaned
+func displayRebuildHints(report *types.CleanReport) {
+	var lines []string
+	for _, name := range report.ProfileNames() {
+		summary := report.Profiles[name]
+		if summary.Targets > 0 && summary.RebuildHint != "" {
+			lines = append(lines, fmt.Sprintf("  - %s: %s", name, summary.RebuildHint))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Fprintln(cleanOut, "\nTo rebuild what was cleaned:")
+	for _, line := range lines {
+		fmt.Fprintln(cleanOut, line)
 	}
 }
@@ -1,11 +1,23 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/raucheacho/rosia-cli/internal/config"
+	"github.com/raucheacho/rosia-cli/internal/sizecalc"
+	"github.com/raucheacho/rosia-cli/internal/ui"
+	"github.com/raucheacho/rosia-cli/pkg/logger"
 	"github.com/spf13/cobra"
 )
 
@@ -15,16 +27,27 @@ var configCmd = &cobra.Command{
 	Short: "Manage rosia configuration",
 	Long: `Manage rosia configuration settings.
 
-Configuration is stored in ~/.rosiarc.json and controls various aspects
-of rosia's behavior including trash retention, concurrency, and telemetry.
+Configuration is stored in config.json of the config directory and controls
+various aspects of rosia's behavior including trash retention, concurrency,
+and telemetry.
 
 Available Subcommands:
-  show  - Display current configuration
-  set   - Set a configuration value
-  reset - Reset configuration to defaults
+  show   - Display current configuration
+  get    - Print a configuration value
+  set    - Set a configuration value
+  add    - Add values to a list
+  remove - Remove values from a list
+  edit   - Edit the configuration file in $EDITOR
+  export - Export the configuration and user profiles
+  import - Import an exported configuration
+  reset  - Reset configuration to defaults
 
 Configuration File:
-  Location: ~/.rosiarc.json
+  Location: ~/.config/rosia/config.json (Linux),
+            ~/Library/Application Support/rosia/config.json (macOS),
+            %APPDATA%\rosia\config.json (Windows)
+  Installs from before platform directories keep ~/.rosiarc.json until
+  'rosia migrate-paths' moves it.
 
 Examples:
   # Show current configuration
@@ -33,6 +56,13 @@ Examples:
   # Set trash retention to 7 days
   rosia config set trash_retention_days 7
 
+  # Edit the configuration file
+  rosia config edit
+
+  # Move the configuration to another machine
+  rosia config export rosia-export.json
+  rosia config import rosia-export.json
+
   # Reset to defaults
   rosia config reset`,
 }
@@ -41,15 +71,26 @@ Examples:
 var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Display current configuration",
-	Long: `Display the current rosia configuration from ~/.rosiarc.json
+	Long: `Display the current rosia configuration from the configuration file
 
 Shows all configuration values in JSON format, including:
   • trash_retention_days: Days to keep items in trash
   • profiles: Enabled technology profiles
   • ignore_paths: Paths excluded from scanning
+  • scan_paths: Paths scanned when none are given
+  • protected_paths: Paths never scanned or cleaned
+  • detect_commands: Profiles allowed to run their detect_command
   • plugins: Enabled plugin names
   • concurrency: Worker pool size (0 = auto-detect)
   • telemetry_enabled: Anonymous statistics collection
+  • trash_compression: Store trashed directories as tar.zst archives
+  • trash_dir: Trash location (unset = default location)
+  • trash_dedup: Store identical trashed files once
+  • trash_checksums: Check trashed items for changes before restoring them
+  • trash_max_size: Disk usage of the trash above which old items are removed
+  • plugin_settings: Settings passed to plugins, by plugin name
+  • plugin_timeout_seconds: Seconds each plugin may take to scan or clean (0 = 120)
+  • scan, clean, ui: Defaults of the flags of these commands
 
 Examples:
   # Display configuration
@@ -57,19 +98,76 @@ Examples:
 	RunE: runConfigShow,
 }
 
+var configGetOutput string
+
+// configGetCmd prints a configuration value
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a configuration value",
+	Long: `Print a single configuration value, as used by the commands: with the
+system configuration merged in and defaults filled in.
+
+Nested keys are joined with dots, like retry.max_retries, scan.depth or
+plugin_settings.docker.include_volumes. Unset fields print their zero value;
+an unset key of plugin_settings or profile_states is an error.
+
+Output Formats:
+  raw   Strings as they are, numbers and booleans as JSON, lists one item
+        per line, objects as JSON; null prints nothing (default)
+  json  The value as JSON
+
+Examples:
+  # Print the trash retention
+  rosia config get trash_retention_days
+
+  # Loop over the ignored paths
+  rosia config get ignore_paths | while read -r path; do echo "$path"; done
+
+  # Print the retry settings as JSON
+  rosia config get retry --output json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runConfigGet,
+}
+
 // configSetCmd sets a configuration value
 var configSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Set a configuration value",
-	Long: `Set a configuration value in ~/.rosiarc.json
+	Long: `Set a configuration value in the configuration file
 
 Available Configuration Keys:
   trash_retention_days  Number of days to retain trashed items (integer > 0)
   concurrency           Number of concurrent operations (integer >= 0, 0 = auto)
   telemetry_enabled     Enable anonymous telemetry (true/false)
-  profiles              Comma-separated list of enabled profiles
+  trash_compression     Compress trashed directories (true/false)
+  trash_dir             Trash location; existing items are moved there ("" = default)
+  trash_dedup           Store identical trashed files once (true/false)
+  trash_checksums       Record a checksum of trashed items and check it on
+                        restore (true/false)
+  trash_max_size        Disk usage of the trash above which the oldest items are
+                        removed after cleaning, like 20GB ("" = no limit)
+  profiles              Comma-separated list of the profiles to use (empty for all)
   ignore_paths          Comma-separated list of paths to ignore
+  scan_paths            Comma-separated list of the paths scanned by scan, clean
+                        and ui when none are given (empty to require them)
+  protected_paths       Comma-separated list of paths never scanned or cleaned,
+                        with everything they contain, in addition to the
+                        built-in ones
   plugins               Comma-separated list of enabled plugins
+  detect_commands       Comma-separated list of the profiles allowed to run
+                        their detect_command
+  plugin_timeout_seconds
+                        Seconds each plugin may take to scan or clean (integer >= 0, 0 = 120)
+  plugin_settings.<plugin>.<setting>
+                        Setting passed to a plugin, as JSON or a string ("" to remove)
+  scan.depth, scan.include_hidden
+                        Defaults of the flags of 'rosia scan' ("" to remove)
+  clean.use_trash, clean.depth, clean.include_hidden
+                        Defaults of the flags of 'rosia clean'; use_trash false
+                        deletes directly like --no-trash ("" to remove)
+  ui.theme, ui.depth, ui.include_hidden
+                        Defaults of the flags of 'rosia ui'; themes: default,
+                        light, mono ("" to remove)
 
 Examples:
   # Set trash retention to 7 days
@@ -87,6 +185,22 @@ Examples:
   # Add ignore paths
   rosia config set ignore_paths "/tmp,/var"
 
+  # Scan the usual project directories when no path is given
+  rosia config set scan_paths "~/projects,~/work"
+
+  # Keep the trash on a bigger disk
+  rosia config set trash_dir /mnt/data/rosia-trash
+
+  # Keep the trash under 20 GB
+  rosia config set trash_max_size 20GB
+
+  # Have the docker plugin clean volumes too
+  rosia config set plugin_settings.docker.include_volumes true
+
+  # Always scan 4 levels deep, hidden directories included
+  rosia config set scan.depth 4
+  rosia config set scan.include_hidden true
+
 Tips:
   • Use 0 for concurrency to auto-detect based on CPU cores
   • Telemetry is disabled by default and stored locally
@@ -95,15 +209,147 @@ Tips:
 	RunE: runConfigSet,
 }
 
+// configAddCmd adds values to a list of the configuration
+var configAddCmd = &cobra.Command{
+	Use:   "add <key> <value>...",
+	Short: "Add values to a configuration list",
+	Long: `Add values to a list of the configuration, keeping the values it has,
+unlike 'rosia config set' which replaces the whole list. Values already in
+the list are skipped, so running the command again changes nothing.
+
+Lists:
+  ignore_paths  Paths to ignore (~ and relative paths are made absolute)
+  scan_paths    Paths scanned when none are given (~ and relative paths are
+                made absolute)
+  protected_paths
+                Paths never scanned or cleaned (~ and relative paths are made
+                absolute)
+  profiles      Profiles to use; adding to the empty list, which uses all
+                profiles, restricts scans to the added profiles
+  plugins       Enabled plugins
+  detect_commands
+                Profiles allowed to run their detect_command
+
+Examples:
+  # Ignore a directory without losing the ignored ones
+  rosia config add ignore_paths ~/work/vendor
+
+  # Scan two more directories when no path is given
+  rosia config add scan_paths ~/projects ~/oss`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runConfigAdd,
+}
+
+// configRemoveCmd removes values from a list of the configuration
+var configRemoveCmd = &cobra.Command{
+	Use:   "remove <key> <value>...",
+	Short: "Remove values from a configuration list",
+	Long: `Remove values from a list of the configuration: ignore_paths, scan_paths,
+protected_paths, profiles, plugins or detect_commands. Paths are made
+absolute like 'rosia config add' does. The built-in protected paths cannot
+be removed. Values not in the list are skipped with a warning. Removing the
+last profile leaves the empty list, which uses all profiles.
+
+Examples:
+  # Stop ignoring a directory
+  rosia config remove ignore_paths ~/work/vendor
+
+  # Disable a plugin
+  rosia config remove plugins docker`,
+	Aliases: []string{"rm"},
+	Args:    cobra.MinimumNArgs(2),
+	RunE:    runConfigRemove,
+}
+
+// configEditCmd opens the configuration file in an editor
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the configuration file in $EDITOR",
+	Long: `Open the configuration file in $VISUAL or $EDITOR (vi, or notepad on
+Windows, when neither is set) and save it once it is valid.
+
+The file is edited as a copy. When the editor exits, the copy is checked:
+it must be valid JSON, without unknown keys, and pass the same validation
+as 'rosia config set'. An invalid copy is never saved; its problems are
+shown with their line, along with the changes made, and you are offered
+to edit it again. Declining keeps the copy so your changes are not lost.
+
+Editors running in the background, like VS Code, must be told to wait
+until the file is closed, e.g. EDITOR="code --wait".
+
+Examples:
+  # Edit the configuration
+  rosia config edit
+
+  # Edit it with nano
+  EDITOR=nano rosia config edit`,
+	Args: cobra.NoArgs,
+	RunE: runConfigEdit,
+}
+
+// configExportCmd writes the configuration and user profiles to a file
+var configExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export the configuration and user profiles",
+	Long: `Export the user configuration and the user profiles to a file, or to the
+standard output when no file is given, to import them on another machine
+with 'rosia config import' or keep them in your dotfiles.
+
+Paths under your home directory, in ignore_paths, scan_paths,
+protected_paths and trash_dir, are written with ~ so they follow the home
+directory of the machine importing them.
+Settings of the system configuration are not exported.
+
+Examples:
+  # Export to a file
+  rosia config export rosia-export.json
+
+  # Export into your dotfiles
+  rosia config export > ~/dotfiles/rosia.json
+
+  # Export the configuration alone
+  rosia config export --no-profiles`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigExport,
+}
+
+// configImportCmd replaces the configuration with an exported one
+var configImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import an exported configuration",
+	Long: `Replace the user configuration with one exported by
+'rosia config export', read from file, or from the standard input for "-".
+Its profiles are saved to the user profiles directory, replacing the profiles
+of the same file name.
+
+The export is checked like 'rosia config edit' checks the configuration,
+and nothing is changed when it is invalid. The changes to the configuration
+and the profiles written are shown for confirmation first.
+
+Examples:
+  # Import an export
+  rosia config import rosia-export.json
+
+  # Import without confirmation, e.g. from a dotfiles install script
+  rosia config import ~/dotfiles/rosia.json --yes`,
+	Args: cobra.ExactArgs(1),
+	RunE: runConfigImport,
+}
+
+var (
+	configExportNoProfiles bool
+	configImportYes        bool
+)
+
 // configResetCmd resets configuration to defaults
 var configResetCmd = &cobra.Command{
 	Use:   "reset",
 	Short: "Reset configuration to defaults",
 	Long: `Reset the rosia configuration to default values
 
-This command overwrites ~/.rosiarc.json with default settings:
+This command overwrites the configuration file with default settings:
   • trash_retention_days: 3
-  • profiles: ["node", "python", "rust", "flutter", "go"]
+  • profiles: [] (all profiles)
   • ignore_paths: []
   • plugins: []
   • concurrency: 0 (auto-detect)
@@ -121,8 +367,18 @@ Warning:
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configAddCmd)
+	configCmd.AddCommand(configRemoveCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configExportCmd)
+	configCmd.AddCommand(configImportCmd)
 	configCmd.AddCommand(configResetCmd)
+
+	configGetCmd.Flags().StringVarP(&configGetOutput, "output", "o", "raw", "output format: raw or json")
+	configExportCmd.Flags().BoolVar(&configExportNoProfiles, "no-profiles", false, "export the configuration without the user profiles")
+	configImportCmd.Flags().BoolVarP(&configImportYes, "yes", "y", false, "skip the confirmation prompt")
 }
 
 func runConfigShow(cmd *cobra.Command, args []string) error {
@@ -130,13 +386,21 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 	cfg := GetGlobalConfig()
 
 	// Get config path from global config manager
-	configPath := "~/.rosiarc.json"
+	configPath := "config.json"
 	if globalConfigManager != nil {
 		configPath = globalConfigManager.GetConfigPath()
 	}
 
 	// Display configuration
-	fmt.Printf("Configuration file: %s\n\n", configPath)
+	fmt.Printf("Configuration file: %s\n", configPath)
+	if globalConfigManager != nil {
+		for _, layer := range globalConfigManager.Layers() {
+			if layer != configPath {
+				fmt.Printf("System configuration: %s\n", layer)
+			}
+		}
+	}
+	fmt.Println()
 
 	// Pretty print JSON
 	data, err := json.MarshalIndent(cfg, "", "  ")
@@ -149,6 +413,72 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	if configGetOutput != "raw" && configGetOutput != "json" {
+		return fmt.Errorf("invalid output format %q, expected raw or json", configGetOutput)
+	}
+
+	value, err := config.Get(GetGlobalConfig(), args[0])
+	if err != nil {
+		return err
+	}
+
+	if configGetOutput == "json" {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format %s: %w", args[0], err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	return printRawValue(value)
+}
+
+// printRawValue prints value, decoded from JSON, for shell scripts: strings
+// as they are, lists of scalars one item per line, null as nothing and
+// anything else as JSON
+func printRawValue(value any) error {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case string:
+		fmt.Println(value)
+		return nil
+	case []any:
+		if lines, ok := scalarLines(value); ok {
+			for _, line := range lines {
+				fmt.Println(line)
+			}
+			return nil
+		}
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format value: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// scalarLines returns the items of list as lines, strings as they are and
+// other scalars as JSON, or false when an item is a list or an object
+func scalarLines(list []any) ([]string, bool) {
+	lines := make([]string, 0, len(list))
+	for _, item := range list {
+		switch item := item.(type) {
+		case map[string]any, []any:
+			return nil, false
+		case string:
+			lines = append(lines, item)
+		default:
+			data, _ := json.Marshal(item)
+			lines = append(lines, string(data))
+		}
+	}
+	return lines, true
+}
+
 func runConfigSet(cmd *cobra.Command, args []string) error {
 	key := args[0]
 	value := args[1]
@@ -162,6 +492,7 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
+	oldTrashDir := cfg.TrashDir
 
 	// Set the value based on key
 	switch key {
@@ -185,6 +516,16 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 		}
 		cfg.Concurrency = concurrency
 
+	case "plugin_timeout_seconds":
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for plugin_timeout_seconds: must be an integer")
+		}
+		if seconds < 0 {
+			return fmt.Errorf("plugin_timeout_seconds must be non-negative")
+		}
+		cfg.PluginTimeoutSeconds = seconds
+
 	case "telemetry_enabled":
 		enabled, err := strconv.ParseBool(value)
 		if err != nil {
@@ -192,11 +533,51 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 		}
 		cfg.TelemetryEnabled = enabled
 
+	case "trash_compression":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for trash_compression: must be true or false")
+		}
+		cfg.TrashCompression = enabled
+
+	case "trash_dedup":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for trash_dedup: must be true or false")
+		}
+		cfg.TrashDedup = enabled
+
+	case "trash_checksums":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for trash_checksums: must be true or false")
+		}
+		cfg.TrashChecksums = enabled
+
+	case "trash_max_size":
+		if value != "" {
+			if _, err := sizecalc.ParseSize(value); err != nil {
+				return fmt.Errorf("invalid value for trash_max_size: %w", err)
+			}
+		}
+		cfg.TrashMaxSize = value
+
+	case "trash_dir":
+		dir := value
+		if dir != "" {
+			if dir, err = expandPath(dir); err != nil {
+				return fmt.Errorf("invalid value for trash_dir: %w", err)
+			}
+		}
+		cfg.TrashDir = dir
+
 	case "profiles":
-		// Parse comma-separated list
-		profiles := strings.Split(value, ",")
-		for i := range profiles {
-			profiles[i] = strings.TrimSpace(profiles[i])
+		// Parse comma-separated list; an empty list uses all profiles
+		profiles := []string{}
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				profiles = append(profiles, name)
+			}
 		}
 		cfg.Profiles = profiles
 
@@ -208,6 +589,35 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 		}
 		cfg.IgnorePaths = paths
 
+	case "scan_paths":
+		// Parse comma-separated list; an empty list requires paths to be given
+		paths := []string{}
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path == "" {
+				continue
+			}
+			path, err := expandPath(path)
+			if err != nil {
+				return fmt.Errorf("invalid value for scan_paths: %w", err)
+			}
+			paths = append(paths, path)
+		}
+		cfg.ScanPaths = paths
+
+	case "protected_paths":
+		paths := []string{}
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path == "" {
+				continue
+			}
+			path, err := expandPath(path)
+			if err != nil {
+				return fmt.Errorf("invalid value for protected_paths: %w", err)
+			}
+			paths = append(paths, path)
+		}
+		cfg.ProtectedPaths = paths
+
 	case "plugins":
 		// Parse comma-separated list
 		plugins := strings.Split(value, ",")
@@ -216,8 +626,28 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 		}
 		cfg.Plugins = plugins
 
+	case "detect_commands":
+		profiles := []string{}
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				profiles = append(profiles, name)
+			}
+		}
+		cfg.DetectCommands = profiles
+
+	case "scan.depth", "scan.include_hidden", "clean.use_trash", "clean.depth", "clean.include_hidden",
+		"ui.theme", "ui.depth", "ui.include_hidden":
+		if err := setCommandDefault(cfg, key, value); err != nil {
+			return err
+		}
+
 	default:
-		return fmt.Errorf("unknown configuration key: %s", key)
+		if !strings.HasPrefix(key, "plugin_settings.") {
+			return fmt.Errorf("unknown configuration key: %s", key)
+		}
+		if err := setPluginSetting(cfg, strings.TrimPrefix(key, "plugin_settings."), value); err != nil {
+			return err
+		}
 	}
 
 	// Validate configuration
@@ -225,8 +655,13 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("configuration validation failed: %w", err)
 	}
 
-	// Save configuration
-	if err := globalConfigManager.Save(cfg); err != nil {
+	if err := migrateTrash(oldTrashDir, cfg.TrashDir); err != nil {
+		return err
+	}
+
+	// Save the key along with the settings of the user configuration, the
+	// others staying with the system configuration
+	if err := globalConfigManager.SaveKeys(cfg, key); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 
@@ -236,6 +671,412 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runConfigAdd(cmd *cobra.Command, args []string) error {
+	return updateConfigList(args[0], args[1:], func(list []string, value string) ([]string, bool) {
+		if slices.Contains(list, value) {
+			fmt.Printf("%s is already in %s\n", value, args[0])
+			return list, false
+		}
+		fmt.Printf("✓ Added %s to %s\n", value, args[0])
+		return append(list, value), true
+	})
+}
+
+func runConfigRemove(cmd *cobra.Command, args []string) error {
+	return updateConfigList(args[0], args[1:], func(list []string, value string) ([]string, bool) {
+		i := slices.Index(list, value)
+		if i < 0 {
+			logger.Warn("%s is not in %s", value, args[0])
+			return list, false
+		}
+		fmt.Printf("✓ Removed %s from %s\n", value, args[0])
+		return slices.Delete(list, i, i+1), true
+	})
+}
+
+// updateConfigList applies update to the list key of the user configuration
+// for each of values, and saves the configuration when one of them changed it
+func updateConfigList(key string, values []string, update func(list []string, value string) ([]string, bool)) error {
+	if globalConfigManager == nil {
+		return fmt.Errorf("config manager not initialized")
+	}
+
+	cfg, err := globalConfigManager.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	lists := map[string]*[]string{
+		"ignore_paths":    &cfg.IgnorePaths,
+		"scan_paths":      &cfg.ScanPaths,
+		"protected_paths": &cfg.ProtectedPaths,
+		"profiles":        &cfg.Profiles,
+		"plugins":         &cfg.Plugins,
+		"detect_commands": &cfg.DetectCommands,
+	}
+	list, ok := lists[key]
+	if !ok {
+		return fmt.Errorf("%s is not a list, expected one of ignore_paths, scan_paths, protected_paths, profiles, plugins or detect_commands", key)
+	}
+
+	changed := false
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		if key == "ignore_paths" || key == "scan_paths" || key == "protected_paths" {
+			if value, err = expandPath(value); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+		}
+		var updated bool
+		*list, updated = update(*list, value)
+		changed = changed || updated
+	}
+	if !changed {
+		return nil
+	}
+	if key == "profiles" && len(cfg.Profiles) == 0 {
+		fmt.Println("profiles is empty: all profiles are used")
+	}
+
+	if err := globalConfigManager.Validate(cfg); err != nil {
+		return fmt.Errorf("configuration validation failed: %w", err)
+	}
+	if err := globalConfigManager.SaveKeys(cfg, key); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	fmt.Printf("Configuration saved to: %s\n", globalConfigManager.GetConfigPath())
+	return nil
+}
+
+// setCommandDefault sets the default key, "<command>.<setting>", of the
+// flags of a command to value. An empty value removes the default.
+func setCommandDefault(cfg *config.Config, key, value string) error {
+	depths := map[string]*int{"scan.depth": &cfg.Scan.Depth, "clean.depth": &cfg.Clean.Depth, "ui.depth": &cfg.UI.Depth}
+	hidden := map[string]*bool{"scan.include_hidden": &cfg.Scan.IncludeHidden, "clean.include_hidden": &cfg.Clean.IncludeHidden, "ui.include_hidden": &cfg.UI.IncludeHidden}
+
+	if depth, ok := depths[key]; ok {
+		if value == "" {
+			*depth = 0
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid value for %s: must be an integer >= 0", key)
+		}
+		*depth = n
+		return nil
+	}
+
+	if include, ok := hidden[key]; ok {
+		if value == "" {
+			*include = false
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: must be true or false", key)
+		}
+		*include = b
+		return nil
+	}
+
+	switch key {
+	case "clean.use_trash":
+		if value == "" {
+			cfg.Clean.UseTrash = nil
+			return nil
+		}
+		useTrash, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for clean.use_trash: must be true or false")
+		}
+		cfg.Clean.UseTrash = &useTrash
+
+	case "ui.theme":
+		if value != "" && !slices.Contains(ui.Themes(), value) {
+			return fmt.Errorf("invalid value for ui.theme: must be one of %s", strings.Join(ui.Themes(), ", "))
+		}
+		cfg.UI.Theme = value
+	}
+	return nil
+}
+
+// setPluginSetting sets the setting key, "<plugin>.<setting>", of a plugin to
+// value, parsed as JSON when it is valid JSON and taken as a string
+// otherwise. An empty value removes the setting.
+func setPluginSetting(cfg *config.Config, key, value string) error {
+	pluginName, setting, ok := strings.Cut(key, ".")
+	if !ok || pluginName == "" || setting == "" {
+		return fmt.Errorf("invalid plugin setting key: plugin_settings.%s (expected plugin_settings.<plugin>.<setting>)", key)
+	}
+
+	if value == "" {
+		delete(cfg.PluginSettings[pluginName], setting)
+		if len(cfg.PluginSettings[pluginName]) == 0 {
+			delete(cfg.PluginSettings, pluginName)
+		}
+		return nil
+	}
+
+	var parsed any
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		parsed = value
+	}
+	if cfg.PluginSettings == nil {
+		cfg.PluginSettings = make(map[string]map[string]any)
+	}
+	if cfg.PluginSettings[pluginName] == nil {
+		cfg.PluginSettings[pluginName] = make(map[string]any)
+	}
+	cfg.PluginSettings[pluginName][setting] = parsed
+	return nil
+}
+
+func runConfigEdit(cmd *cobra.Command, args []string) error {
+	if globalConfigManager == nil {
+		return fmt.Errorf("config manager not initialized")
+	}
+	configPath := globalConfigManager.GetConfigPath()
+
+	// A missing file is created with the defaults
+	original, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		original, err = json.MarshalIndent(globalConfigManager.GetDefault(), "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+
+	// The copy is edited, so the file is never left invalid
+	tmp, err := os.CreateTemp("", "rosiarc-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create a copy of the configuration: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(original)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to create a copy of the configuration: %w", err)
+	}
+
+	var edited []byte
+	var cfg *config.Config
+	for {
+		if err := runEditor(tmpPath); err != nil {
+			return fmt.Errorf("%w (your changes are kept in %s)", err, tmpPath)
+		}
+		if edited, err = os.ReadFile(tmpPath); err != nil {
+			return fmt.Errorf("failed to read the edited configuration: %w", err)
+		}
+		if bytes.Equal(edited, original) {
+			os.Remove(tmpPath)
+			fmt.Println("No changes made to the configuration")
+			return nil
+		}
+
+		if cfg, err = globalConfigManager.Check(edited); err == nil {
+			break
+		}
+		printConfigProblem(edited, original, err)
+		if !promptYesNo(os.Stdout, "Edit again?") {
+			return fmt.Errorf("configuration not saved, your changes are kept in %s", tmpPath)
+		}
+	}
+
+	// Items in the trash follow a changed trash directory
+	if current, err := globalConfigManager.Load(); err == nil {
+		if err := migrateTrash(current.TrashDir, cfg.TrashDir); err != nil {
+			return err
+		}
+	}
+
+	// The file is saved as edited, with its formatting
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(configPath, edited, 0644); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	os.Remove(tmpPath)
+
+	fmt.Printf("✓ Configuration saved to: %s\n", configPath)
+	return nil
+}
+
+// runEditor opens path in the editor of $VISUAL or $EDITOR and waits for it
+// to exit
+func runEditor(path string) error {
+	editor := strings.TrimSpace(os.Getenv("VISUAL"))
+	if editor == "" {
+		editor = strings.TrimSpace(os.Getenv("EDITOR"))
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	// The editor may have arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", fields[0], err)
+	}
+	return nil
+}
+
+// printConfigProblem shows the problem err of the edited configuration,
+// located in it when it has a position, and the changes made to original
+func printConfigProblem(edited, original []byte, err error) {
+	fmt.Printf("\n✗ The configuration is invalid: %v\n", err)
+
+	var checkErr *config.CheckError
+	if errors.As(err, &checkErr) && checkErr.Line > 0 {
+		fmt.Println()
+		fmt.Print(config.Excerpt(edited, checkErr.Line, checkErr.Column))
+	}
+
+	if diff := config.Diff(original, edited); diff != "" {
+		fmt.Println("\nYour changes:")
+		fmt.Print(diff)
+	}
+	fmt.Println()
+}
+
+func runConfigExport(cmd *cobra.Command, args []string) error {
+	if globalConfigManager == nil {
+		return fmt.Errorf("config manager not initialized")
+	}
+
+	var dirs []string
+	if !configExportNoProfiles {
+		dirs = userProfileDirectories()
+	}
+	data, err := globalConfigManager.Export(dirs)
+	if err != nil {
+		return fmt.Errorf("failed to export configuration: %w", err)
+	}
+
+	if len(args) == 0 {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[0], data, 0644); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	fmt.Printf("✓ Configuration exported to: %s\n", args[0])
+	return nil
+}
+
+func runConfigImport(cmd *cobra.Command, args []string) error {
+	if globalConfigManager == nil {
+		return fmt.Errorf("config manager not initialized")
+	}
+
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+
+	export, err := globalConfigManager.ParseExport(data)
+	if err != nil {
+		var checkErr *config.CheckError
+		if errors.As(err, &checkErr) && checkErr.Line > 0 {
+			fmt.Print(config.Excerpt(data, checkErr.Line, checkErr.Column))
+		}
+		return fmt.Errorf("invalid export %s: %w", args[0], err)
+	}
+
+	profilesDir, err := userProfilesDir()
+	if err != nil {
+		return err
+	}
+
+	// Show what changes
+	current, err := globalConfigManager.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	currentData, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format configuration: %w", err)
+	}
+	importedData, err := json.MarshalIndent(export.Config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format configuration: %w", err)
+	}
+	if diff := config.Diff(currentData, importedData); diff != "" {
+		fmt.Printf("Changes to %s:\n%s\n", globalConfigManager.GetConfigPath(), diff)
+	} else {
+		fmt.Printf("No changes to %s\n\n", globalConfigManager.GetConfigPath())
+	}
+	if names := export.ProfileNames(); len(names) > 0 {
+		fmt.Printf("Profiles written to %s:\n", profilesDir)
+		commands := export.DetectCommands()
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(profilesDir, name)); err == nil {
+				fmt.Printf("  %s (replaces the existing file)\n", name)
+			} else {
+				fmt.Printf("  %s\n", name)
+			}
+			// Commands run in the scanned directories once detect_commands
+			// lists their profile, so show them before they are imported
+			if command, ok := commands[name]; ok {
+				fmt.Printf("    detect_command: %s\n", strings.Join(command, " "))
+			}
+		}
+		fmt.Println()
+	}
+
+	if !configImportYes && !promptYesNo(os.Stdout, "Import this configuration?") {
+		fmt.Println("Import cancelled")
+		return nil
+	}
+
+	if len(export.Profiles) > 0 {
+		if err := os.MkdirAll(profilesDir, 0755); err != nil {
+			return fmt.Errorf("failed to create profile directory: %w", err)
+		}
+		for _, name := range export.ProfileNames() {
+			if err := os.WriteFile(filepath.Join(profilesDir, name), export.Profiles[name], 0644); err != nil {
+				return fmt.Errorf("failed to save profile %s: %w", name, err)
+			}
+		}
+	}
+
+	// Items in the trash follow a changed trash directory
+	if err := migrateTrash(current.TrashDir, export.Config.TrashDir); err != nil {
+		return err
+	}
+	if err := globalConfigManager.Save(export.Config); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	fmt.Printf("✓ Configuration imported to: %s\n", globalConfigManager.GetConfigPath())
+	return nil
+}
+
+// userProfileDirectories returns the directories of the user profiles, those
+// of profileDirectories but the built-in one
+func userProfileDirectories() []string {
+	return profileDirectories()[1:]
+}
+
 func runConfigReset(cmd *cobra.Command, args []string) error {
 	// Use global configuration manager
 	if globalConfigManager == nil {
@@ -245,8 +1086,15 @@ func runConfigReset(cmd *cobra.Command, args []string) error {
 	// Get default configuration
 	cfg := globalConfigManager.GetDefault()
 
-	// Save default configuration
-	if err := globalConfigManager.Save(cfg); err != nil {
+	// Items in a custom trash directory move back to the default one
+	if current, err := globalConfigManager.Load(); err == nil {
+		if err := migrateTrash(current.TrashDir, cfg.TrashDir); err != nil {
+			return err
+		}
+	}
+
+	// Empty the user configuration, leaving the system one and the defaults
+	if err := globalConfigManager.Reset(); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 
@@ -264,3 +1112,42 @@ func runConfigReset(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// migrateTrash moves the trashed items from oldDir to newDir when the trash
+// directory setting changes. Empty directories stand for the default location.
+func migrateTrash(oldDir, newDir string) error {
+	if oldDir == newDir {
+		return nil
+	}
+
+	oldTrash, err := newTrashSystem(oldDir)
+	if err != nil {
+		return fmt.Errorf("failed to open current trash: %w", err)
+	}
+	newTrash, err := newTrashSystem(newDir)
+	if err != nil {
+		return fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	moved, err := oldTrash.MigrateTo(newTrash)
+	if err != nil {
+		logger.Error("Trash migration stopped after %d items: %v", moved, err)
+		return fmt.Errorf("failed to move trash items to %s (moved %d, re-run to finish): %w", newTrash.GetTrashDir(), moved, err)
+	}
+	if moved > 0 {
+		fmt.Printf("✓ Moved %d trash item(s) to %s\n", moved, newTrash.GetTrashDir())
+	}
+	return nil
+}
+
+// expandPath resolves a leading ~ and returns path as an absolute path
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		path = filepath.Join(homeDir, path[1:])
+	}
+	return filepath.Abs(path)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/raucheacho/rosia-cli/internal/cleaner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	elevatedRemoveHome      string
+	elevatedRemoveProtected []string
+)
+
+// elevatedRemoveCmd is the helper 'clean --sudo' runs with elevated
+// permissions. It only removes the path it is given, after checking it is
+// not protected; the clean command makes every other check beforehand.
+var elevatedRemoveCmd = &cobra.Command{
+	Use:    "elevated-remove [flags] -- <path>",
+	Short:  "Remove a target refused to the user (used by clean --sudo)",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := cleaner.RemoveElevated(args[0], elevatedRemoveHome, elevatedRemoveProtected); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", args[0], err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(elevatedRemoveCmd)
+
+	elevatedRemoveCmd.Flags().StringVar(&elevatedRemoveHome, "home", "", "home directory of the user, never removed")
+	elevatedRemoveCmd.Flags().StringArrayVar(&elevatedRemoveProtected, "protect", nil, "path never removed, along with what it contains")
+}
+
+// newSudoDeleter returns the deleter retrying targets with sudo through
+// elevatedRemoveCmd, refusing the protected paths of the configuration and
+// the trash directory
+func newSudoDeleter(trashDir string) (*cleaner.SudoDeleter, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate rosia for sudo: %w", err)
+	}
+	return &cleaner.SudoDeleter{
+		Helper:    []string{executable, elevatedRemoveCmd.Name()},
+		Protected: append(append([]string{}, GetGlobalConfig().ProtectedPaths...), trashDir),
+	}, nil
+}
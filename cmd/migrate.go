@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/raucheacho/rosia-cli/internal/config"
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+	"github.com/raucheacho/rosia-cli/internal/trash"
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+var migratePathsDryRun bool
+
+// migratePathsCmd moves the files of earlier versions to the platform
+// directories
+var migratePathsCmd = &cobra.Command{
+	Use:   "migrate-paths",
+	Short: "Move rosia's files from ~/.rosia to the platform directories",
+	Long: `Move the files earlier versions kept in ~/.rosia and ~/.rosiarc.json to
+the directories of the platform, where new installs keep them:
+
+  configuration      ~/.rosiarc.json      → config.json in the config directory
+  profiles           ~/.rosia/profiles    → profiles in the config directory
+  plugins            ~/.rosia/plugins     → plugins in the data directory
+  trash              ~/.rosia/trash       → trash in the data directory
+  statistics         ~/.rosia/stats.json  → stats.json in the data directory
+  clean checkpoint and queue              → the data directory
+
+The config directory is $XDG_CONFIG_HOME/rosia (~/.config/rosia) on Linux,
+~/Library/Application Support/rosia on macOS and %APPDATA%\rosia on Windows.
+The data directory is $XDG_DATA_HOME/rosia (~/.local/share/rosia) on Linux,
+~/Library/Application Support/rosia on macOS and %LOCALAPPDATA%\rosia on
+Windows.
+
+Until they are migrated, rosia keeps using the files in ~/.rosia. Trash
+items are moved into the trash in use, and a trash_dir pointing at
+~/.rosia/trash is reset to the default location. Files that exist in both
+places are left in ~/.rosia with a warning. An interrupted migration can be
+run again to finish it.
+
+Examples:
+  # Show what would move
+  rosia migrate-paths --dry-run
+
+  # Move the files
+  rosia migrate-paths`,
+	Args: cobra.NoArgs,
+	RunE: runMigratePaths,
+}
+
+func init() {
+	rootCmd.AddCommand(migratePathsCmd)
+
+	migratePathsCmd.Flags().BoolVar(&migratePathsDryRun, "dry-run", false, "show what would move without moving anything")
+}
+
+func runMigratePaths(cmd *cobra.Command, args []string) error {
+	locations, err := fsutils.Locations()
+	if err != nil {
+		return err
+	}
+
+	var pending []fsutils.Location
+	for _, location := range locations {
+		if location.NeedsMigration() {
+			pending = append(pending, location)
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Println("Nothing to migrate: rosia already uses the platform directories")
+		return nil
+	}
+
+	// The configuration may move and reference the legacy trash
+	manager := globalConfigManager
+	if manager == nil {
+		return fmt.Errorf("config manager not initialized")
+	}
+	cfg, err := manager.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	problems := 0
+	for _, location := range pending {
+		if migratePathsDryRun {
+			fmt.Printf("Would move %s: %s → %s\n", location.Name, location.Legacy, location.Platform)
+			continue
+		}
+
+		switch location.Name {
+		case "trash":
+			err = migrateLegacyTrash(location, cfg)
+		case "configuration":
+			err = fsutils.MovePath(location.Legacy, location.Platform)
+			if err == nil && manager.GetConfigPath() == location.Legacy {
+				manager = config.NewManagerWithPath(location.Platform)
+			}
+		default:
+			err = mergeLegacyPath(location.Legacy, location.Platform)
+		}
+		if err != nil {
+			logger.Warn("Failed to move %s: %v", location.Name, err)
+			problems++
+			continue
+		}
+		fmt.Printf("✓ Moved %s to %s\n", location.Name, location.Platform)
+	}
+	if migratePathsDryRun {
+		return nil
+	}
+
+	// A trash_dir naming the legacy trash now names the default one
+	if trashLocation, err := fsutils.TrashLocation(); err == nil && cfg.TrashDir != "" &&
+		filepath.Clean(cfg.TrashDir) == filepath.Clean(trashLocation.Legacy) && !trashLocation.NeedsMigration() {
+		current, err := manager.Load()
+		if err == nil {
+			current.TrashDir = ""
+			err = manager.SaveKeys(current, "trash_dir")
+		}
+		if err != nil {
+			logger.Warn("Failed to reset trash_dir of the configuration: %v", err)
+			problems++
+		} else {
+			fmt.Println("✓ Reset trash_dir to the default location")
+		}
+	}
+
+	// Only remove ~/.rosia once nothing is left in it
+	if legacyDir, err := fsutils.GetLegacyDir(); err == nil {
+		os.Remove(legacyDir)
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d location(s) could not be migrated, run 'rosia migrate-paths' again once fixed", problems)
+	}
+	return nil
+}
+
+// migrateLegacyTrash moves the items of the legacy trash into the trash in
+// use once it is migrated: trash_dir, or the platform trash when trash_dir
+// is unset or names the legacy trash
+func migrateLegacyTrash(location fsutils.Location, cfg *config.Config) error {
+	legacy, err := trash.NewSystem(location.Legacy)
+	if err != nil {
+		return err
+	}
+	dest := location.Platform
+	if cfg.TrashDir != "" && filepath.Clean(cfg.TrashDir) != filepath.Clean(location.Legacy) {
+		dest = cfg.TrashDir
+	}
+	target, err := trash.NewSystem(dest)
+	if err != nil {
+		return err
+	}
+
+	moved, err := legacy.MigrateTo(target)
+	if err != nil {
+		return fmt.Errorf("moved %d item(s): %w", moved, err)
+	}
+	if moved > 0 {
+		fmt.Printf("✓ Moved %d trash item(s) to %s\n", moved, dest)
+	}
+	return os.RemoveAll(location.Legacy)
+}
+
+// mergeLegacyPath moves the file or directory legacy to platform. The
+// entries of a directory are moved one by one into an existing one; those
+// already there are left in legacy.
+func mergeLegacyPath(legacy, platform string) error {
+	info, err := os.Stat(legacy)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(platform); os.IsNotExist(err) {
+		return fsutils.MovePath(legacy, platform)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s already exists, remove one of %s and %s", platform, legacy, platform)
+	}
+
+	entries, err := os.ReadDir(legacy)
+	if err != nil {
+		return err
+	}
+	var conflicts []string
+	for _, entry := range entries {
+		from := filepath.Join(legacy, entry.Name())
+		to := filepath.Join(platform, entry.Name())
+		if _, err := os.Lstat(to); err == nil {
+			conflicts = append(conflicts, entry.Name())
+			continue
+		}
+		if err := fsutils.MovePath(from, to); err != nil {
+			return err
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%d entries of %s already exist in %s: %v", len(conflicts), legacy, platform, conflicts)
+	}
+	return os.Remove(legacy)
+}
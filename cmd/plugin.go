@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"text/tabwriter"
 
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
 	"github.com/raucheacho/rosia-cli/internal/plugins"
+	"github.com/raucheacho/rosia-cli/internal/plugins/caches"
+	"github.com/raucheacho/rosia-cli/internal/plugins/docker"
 	"github.com/raucheacho/rosia-cli/pkg/logger"
 	"github.com/spf13/cobra"
 )
@@ -23,16 +30,24 @@ Go or any language that supports JSON-RPC communication.
 Available Subcommands:
   list        List all loaded plugins
   info        Show detailed information about a plugin
+  install     Download and install a plugin
+  update      Update installed plugins
+  remove      Remove a plugin
 
 Plugin Directory:
-  Plugins are loaded from: ~/.rosia/plugins/
+  Plugins are loaded from the plugins/ directory of the data directory
+  (~/.local/share/rosia/plugins/ on Linux), or ~/.rosia/plugins/ until
+  'rosia migrate-paths' moves it.
 
 Examples:
   # List all loaded plugins
   rosia plugin list
 
   # Show details about a specific plugin
-  rosia plugin info rosia-docker`,
+  rosia plugin info rosia-docker
+
+  # Install a plugin of the plugin index
+  rosia plugin install rosia-docker`,
 }
 
 var pluginListCmd = &cobra.Command{
@@ -40,7 +55,7 @@ var pluginListCmd = &cobra.Command{
 	Short: "List all loaded plugins",
 	Long: `Display a list of all currently loaded plugins with their versions.
 
-This command scans the plugin directory (~/.rosia/plugins/) and displays
+This command scans the plugin directory and displays
 information about each successfully loaded plugin.
 
 Examples:
@@ -72,10 +87,92 @@ Examples:
 	RunE: runPluginInfo,
 }
 
+var pluginInstallCmd = &cobra.Command{
+	Use:   "install <url|name>",
+	Short: "Download and install a plugin",
+	Long: `Download a plugin into the plugin directory and enable it.
+
+The plugin is either a URL, of a .so file, an executable or a .tar.gz, .tgz
+or .zip archive holding one, or the name of a plugin of the plugin index.
+Downloads are verified before they are installed:
+  • Plugins of the index against the checksum of the index
+  • URLs against --sha256
+
+With --insecure, a download without a checksum is installed unverified. The
+checksum published next to a URL as <url>.sha256 is then checked, which
+catches a corrupted download but not a replaced one.
+
+Once verified, the download is shown and the install confirmed, as the
+plugin runs with your permissions from then on. The plugin must load before
+it is installed, replacing any plugin of the same name installed before. It
+is then added to the plugins of the configuration.
+
+Examples:
+  # Install a plugin of the index
+  rosia plugin install rosia-docker
+
+  # Install a plugin from a URL with its checksum
+  rosia plugin install https://example.com/my-plugin.tar.gz --sha256 9f86d0...
+
+  # Install without asking
+  rosia plugin install rosia-docker --yes`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPluginInstall,
+}
+
+var pluginUpdateCmd = &cobra.Command{
+	Use:   "update [plugin-name...]",
+	Short: "Update installed plugins",
+	Long: `Install the latest version of plugins installed with rosia plugin install.
+
+Plugins of the index update when the index has another version, plugins of
+a URL when the checksum of the URL changed. A URL is verified against
+--sha256, given with the name of a single plugin, or updated unverified with
+--insecure. Each update is confirmed like an install. Without names, every
+installed plugin is updated.
+
+Examples:
+  # Update all installed plugins
+  rosia plugin update
+
+  # Update one plugin
+  rosia plugin update rosia-docker`,
+	RunE: runPluginUpdate,
+}
+
+var pluginRemoveCmd = &cobra.Command{
+	Use:   "remove <plugin-name>",
+	Short: "Remove a plugin",
+	Long: `Delete a plugin from the plugin directory and the plugins of the
+configuration.
+
+Examples:
+  rosia plugin remove rosia-docker`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPluginRemove,
+}
+
+var (
+	pluginSHA256   string
+	pluginInsecure bool
+	pluginIndex    string
+	pluginYes      bool
+)
+
 func init() {
 	rootCmd.AddCommand(pluginCmd)
 	pluginCmd.AddCommand(pluginListCmd)
 	pluginCmd.AddCommand(pluginInfoCmd)
+	pluginCmd.AddCommand(pluginInstallCmd)
+	pluginCmd.AddCommand(pluginUpdateCmd)
+	pluginCmd.AddCommand(pluginRemoveCmd)
+
+	for _, cmd := range []*cobra.Command{pluginInstallCmd, pluginUpdateCmd} {
+		cmd.Flags().StringVar(&pluginSHA256, "sha256", "", "expected SHA-256 checksum of a download from a URL")
+		cmd.Flags().BoolVar(&pluginInsecure, "insecure", false, "install downloads that have no checksum to verify")
+		cmd.Flags().StringVar(&pluginIndex, "index", plugins.DefaultIndexURL, "URL of the plugin index")
+		cmd.Flags().BoolVarP(&pluginYes, "yes", "y", false, "skip the confirmation prompt")
+	}
 }
 
 // runPluginList lists all loaded plugins
@@ -89,8 +186,9 @@ func runPluginList(cmd *cobra.Command, args []string) error {
 	// Create plugin registry and load plugins
 	registry := plugins.NewRegistry()
 	if err := registry.LoadAll(pluginDir); err != nil {
-		return fmt.Errorf("failed to load plugins: %w", err)
+		warnPluginErrors(err)
 	}
+	registerBuiltinPlugins(registry)
 
 	// Get all plugins
 	allPlugins := registry.List()
@@ -135,8 +233,9 @@ func runPluginInfo(cmd *cobra.Command, args []string) error {
 	// Create plugin registry and load plugins
 	registry := plugins.NewRegistry()
 	if err := registry.LoadAll(pluginDir); err != nil {
-		return fmt.Errorf("failed to load plugins: %w", err)
+		warnPluginErrors(err)
 	}
+	registerBuiltinPlugins(registry)
 
 	// Get the specific plugin
 	plugin, err := registry.Get(pluginName)
@@ -148,19 +247,193 @@ func runPluginInfo(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Plugin: %s\n", plugin.Name())
 	fmt.Printf("Version: %s\n", plugin.Version())
 	fmt.Printf("Description: %s\n", plugin.Description())
+	if process, ok := plugin.(*plugins.ProcessPlugin); ok {
+		fmt.Printf("Executable: %s\n", process.Path())
+	}
+	if isBuiltinPlugin(plugin) {
+		fmt.Println("Built-in: yes")
+	}
+
+	return nil
+}
+
+// runPluginInstall installs a plugin and enables it in the configuration
+func runPluginInstall(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	installer, err := newPluginInstaller()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Installing %s...\n", args[0])
+	installation, err := installer.Install(ctx, args[0], plugins.InstallOptions{
+		SHA256:   pluginSHA256,
+		Insecure: pluginInsecure,
+		Confirm:  confirmPluginInstall,
+	})
+	if errors.Is(err, plugins.ErrInstallCancelled) {
+		fmt.Println("Install cancelled")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := setPluginEnabled(installation.Name, true); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Installed plugin %s %s\n", installation.Name, installation.Version)
+	if installation.Unverified {
+		logger.Warn("Plugin %s was installed unverified", installation.Name)
+	}
+	fmt.Printf("Plugin file: %s\n", filepath.Join(installer.Dir, installation.File))
+	return nil
+}
+
+// runPluginUpdate updates the named or all installed plugins
+func runPluginUpdate(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	installer, err := newPluginInstaller()
+	if err != nil {
+		return err
+	}
+
+	names := args
+	if len(names) == 0 {
+		installed, err := installer.Installed()
+		if err != nil {
+			return err
+		}
+		for name := range installed {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	}
+	if len(names) == 0 {
+		fmt.Println("No plugins were installed with rosia plugin install")
+		return nil
+	}
+	if pluginSHA256 != "" && len(names) != 1 {
+		return fmt.Errorf("--sha256 needs the name of the plugin it verifies")
+	}
+
+	failed := 0
+	for _, name := range names {
+		installation, updated, err := installer.Update(ctx, name, plugins.InstallOptions{
+			SHA256:   pluginSHA256,
+			Insecure: pluginInsecure,
+			Confirm:  confirmPluginInstall,
+		})
+		switch {
+		case errors.Is(err, plugins.ErrInstallCancelled):
+			fmt.Printf("Update of plugin %s cancelled\n", name)
+		case err != nil:
+			logger.Error("Failed to update plugin %s: %v", name, err)
+			failed++
+		case updated:
+			fmt.Printf("✓ Updated plugin %s to %s\n", name, installation.Version)
+		default:
+			fmt.Printf("Plugin %s is up to date (%s)\n", name, installation.Version)
+		}
+	}
 
+	if failed > 0 {
+		return fmt.Errorf("failed to update %d plugin(s)", failed)
+	}
+	return nil
+}
+
+// runPluginRemove removes a plugin and disables it in the configuration
+func runPluginRemove(cmd *cobra.Command, args []string) error {
+	installer, err := newPluginInstaller()
+	if err != nil {
+		return err
+	}
+
+	installation, err := installer.Remove(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := setPluginEnabled(installation.Name, false); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Removed plugin %s\n", installation.Name)
+	return nil
+}
+
+// confirmPluginInstall shows the verified download of the plugin of source
+// and asks whether to install it, unless --yes was given
+func confirmPluginInstall(source string, download plugins.Download) bool {
+	if pluginYes {
+		return true
+	}
+
+	fmt.Printf("Plugin: %s\n", source)
+	fmt.Printf("Download: %s\n", download.URL)
+	switch {
+	case !download.Unverified:
+		fmt.Printf("SHA-256: %s (verified)\n", download.SHA256)
+	case download.SHA256 != "":
+		fmt.Printf("SHA-256: %s (unverified: published by the same server)\n", download.SHA256)
+	default:
+		fmt.Println("SHA-256: none (unverified)")
+	}
+	return promptYesNo(os.Stdout, "The plugin will run with your permissions. Install and enable it?")
+}
+
+// newPluginInstaller returns an installer into the plugin directory
+func newPluginInstaller() (*plugins.Installer, error) {
+	pluginDir, err := getPluginDirectory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plugin directory: %w", err)
+	}
+
+	installer := plugins.NewInstaller(pluginDir)
+	installer.IndexURL = pluginIndex
+	return installer, nil
+}
+
+// setPluginEnabled adds name to or removes it from the plugins of the
+// configuration
+func setPluginEnabled(name string, enabled bool) error {
+	if globalConfigManager == nil {
+		return fmt.Errorf("config manager not initialized")
+	}
+
+	cfg, err := globalConfigManager.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if enabled == slices.Contains(cfg.Plugins, name) {
+		return nil
+	}
+	if enabled {
+		cfg.Plugins = append(cfg.Plugins, name)
+	} else {
+		cfg.Plugins = slices.DeleteFunc(cfg.Plugins, func(plugin string) bool { return plugin == name })
+	}
+
+	if err := globalConfigManager.SaveKeys(cfg, "plugins"); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
 	return nil
 }
 
 // getPluginDirectory returns the plugin directory path
 func getPluginDirectory() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	location, err := fsutils.PluginsLocation()
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", err
 	}
-
-	pluginDir := filepath.Join(homeDir, ".rosia", "plugins")
-	return pluginDir, nil
+	return location.Path(), nil
 }
 
 // truncateString truncates a string to the specified length
@@ -170,3 +443,45 @@ func truncateString(s string, maxLen int) string {
 	}
 	return s[:maxLen-3] + "..."
 }
+
+// builtinPlugins returns the plugins shipped with rosia, enabled like
+// installed plugins by listing them in the plugins of the configuration
+func builtinPlugins() []plugins.Plugin {
+	return []plugins.Plugin{caches.New(), docker.New()}
+}
+
+// registerBuiltinPlugins registers the built-in plugins in registry, but
+// those replaced by a loaded plugin of the same name
+func registerBuiltinPlugins(registry plugins.PluginRegistry) {
+	for _, plugin := range builtinPlugins() {
+		if _, err := registry.Get(plugin.Name()); err == nil {
+			logger.Debug("Plugin %s replaces the built-in plugin", plugin.Name())
+			continue
+		}
+		if err := registry.Register(plugin); err != nil {
+			logger.Warn("Failed to register built-in plugin %s: %v", plugin.Name(), err)
+		}
+	}
+}
+
+// warnPluginErrors logs the errors of loading plugins, one warning per
+// plugin
+func warnPluginErrors(err error) {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		logger.Warn("%v", err)
+		return
+	}
+	for _, err := range joined.Unwrap() {
+		logger.Warn("%v", err)
+	}
+}
+
+// isBuiltinPlugin reports whether plugin is shipped with rosia
+func isBuiltinPlugin(plugin plugins.Plugin) bool {
+	switch plugin.(type) {
+	case *caches.Plugin, *docker.Plugin:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,721 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/config"
+	"github.com/raucheacho/rosia-cli/internal/profiles"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileCreateDetect      []string
+	profileCreatePatterns    []string
+	profileCreateDescription string
+	profileCreateExtends     string
+	profileCreateForce       bool
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage technology profiles",
+	Long: `Manage the technology profiles used to detect cleanable targets.
+
+Profiles are loaded from the built-in profiles directory, the profiles/
+directory of the config directory and, until 'rosia migrate-paths' moves it,
+~/.rosia/profiles/. Enabling or disabling a
+profile is saved in the configuration, so the profile files stay untouched.
+
+Available Subcommands:
+  list        List all loaded profiles
+  show        Show the rules of a profile
+  enable      Enable a profile
+  disable     Disable a profile
+  create      Create a user profile
+  validate    Check profile files for problems
+  test        Show what a profile selects in a directory
+
+Examples:
+  # List all profiles
+  rosia profile list
+
+  # Show what the Rust profile cleans
+  rosia profile show rust
+
+  # Stop cleaning Flutter projects
+  rosia profile disable flutter
+
+  # Check a profile before installing it
+  rosia profile validate ./zig.json`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all loaded profiles",
+	Long: `Display all loaded profiles with their version and whether they are
+enabled.
+
+Examples:
+  # List all profiles
+  rosia profile list`,
+	Args: cobra.NoArgs,
+	RunE: runProfileList,
+}
+
+var profileShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show the rules of a profile",
+	Long: `Display a profile with its patterns, detect files and other rules, after
+inheritance from the profile it extends. Names are matched ignoring case.
+
+Examples:
+  # Show the Node.js profile
+  rosia profile show node.js`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProfileShow,
+}
+
+var profileEnableCmd = &cobra.Command{
+	Use:   "enable <name>",
+	Short: "Enable a profile",
+	Long: `Enable a profile so scans report its targets again. The setting is saved
+in the configuration and overrides the "enabled" field of the profile file.
+
+Examples:
+  # Enable the Go profile
+  rosia profile enable go`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setProfileEnabled(args[0], true)
+	},
+}
+
+var profileDisableCmd = &cobra.Command{
+	Use:   "disable <name>",
+	Short: "Disable a profile",
+	Long: `Disable a profile so scans no longer report its targets. The setting is
+saved in the configuration and overrides the "enabled" field of the profile
+file.
+
+Examples:
+  # Disable the Go profile
+  rosia profile disable go`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setProfileEnabled(args[0], false)
+	},
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a user profile",
+	Long: `Create a profile in the user profiles directory, asking for the files that
+identify the technology and the patterns to clean. Values given as flags are
+not asked for. The profile is validated before it is saved.
+
+A profile with the name of an existing one, e.g. a built-in profile,
+overrides it.
+
+Flags:
+      --detect strings       Files that indicate the technology
+      --patterns strings     Directory/file names to clean
+      --description string   Human-readable description
+      --extends string       Profile to inherit patterns and detect files from
+      --force                Overwrite an existing profile file
+
+Examples:
+  # Answer the questions interactively
+  rosia profile create Zig
+
+  # Create a profile without prompts
+  rosia profile create Zig --detect build.zig --patterns zig-cache,zig-out`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProfileCreate,
+}
+
+var profileValidateCmd = &cobra.Command{
+	Use:   "validate [path]",
+	Short: "Check profile files for problems",
+	Long: `Check a profile file, or every JSON file of a directory, and report all
+their problems with the line they are on: invalid JSON, missing fields,
+invalid patterns and names used by several profiles of a directory.
+
+At startup, a profile with a problem is skipped with a warning. Without a
+path, all profile directories are checked. The command fails if any problem
+is found.
+
+Examples:
+  # Check all profile directories
+  rosia profile validate
+
+  # Check a single profile
+  rosia profile validate ~/.rosia/profiles/zig.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runProfileValidate,
+}
+
+var profileTestCmd = &cobra.Command{
+	Use:   "test <name> [dir]",
+	Short: "Show what a profile selects in a directory",
+	Long: `Check a profile against a project directory without scanning it: whether
+the directory is detected as a project of the profile, which detect file
+triggered it, and which of its directories would be selected for cleaning.
+The directory defaults to the current one.
+
+Only the directory itself is checked, not its subdirectories, and size and
+age thresholds are not applied.
+
+Examples:
+  # Check the Node.js profile against the current directory
+  rosia profile test node.js
+
+  # Try a custom profile on a project
+  rosia profile test zig ~/projects/my-app`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runProfileTest,
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileShowCmd)
+	profileCmd.AddCommand(profileEnableCmd)
+	profileCmd.AddCommand(profileDisableCmd)
+	profileCmd.AddCommand(profileCreateCmd)
+	profileCmd.AddCommand(profileValidateCmd)
+	profileCmd.AddCommand(profileTestCmd)
+
+	profileCreateCmd.Flags().StringSliceVar(&profileCreateDetect, "detect", nil, "files that indicate the technology")
+	profileCreateCmd.Flags().StringSliceVar(&profileCreatePatterns, "patterns", nil, "directory/file names to clean")
+	profileCreateCmd.Flags().StringVar(&profileCreateDescription, "description", "", "human-readable description")
+	profileCreateCmd.Flags().StringVar(&profileCreateExtends, "extends", "", "profile to inherit patterns and detect files from")
+	profileCreateCmd.Flags().BoolVar(&profileCreateForce, "force", false, "overwrite an existing profile file")
+}
+
+// runProfileList lists all loaded profiles
+func runProfileList(cmd *cobra.Command, args []string) error {
+	profileLoader, err := requireProfileLoader()
+	if err != nil {
+		return err
+	}
+
+	allProfiles := profileLoader.GetProfiles()
+	if len(allProfiles) == 0 {
+		fmt.Println("No profiles loaded.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tENABLED\tDESCRIPTION")
+	fmt.Fprintln(w, "----\t-------\t-------\t-----------")
+
+	for _, profile := range allProfiles {
+		enabled := "no"
+		if profile.Enabled {
+			enabled = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			profile.Name,
+			profile.Version,
+			enabled,
+			truncateString(profile.Description, 50),
+		)
+	}
+
+	w.Flush()
+
+	fmt.Printf("\nTotal profiles: %d\n", len(allProfiles))
+
+	return nil
+}
+
+// runProfileShow displays the rules of a profile
+func runProfileShow(cmd *cobra.Command, args []string) error {
+	profileLoader, err := requireProfileLoader()
+	if err != nil {
+		return err
+	}
+
+	profile, err := findProfile(profileLoader, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Profile: %s\n", profile.Name)
+	fmt.Printf("Version: %s\n", profile.Version)
+	fmt.Printf("Enabled: %t\n", profile.Enabled)
+	if profile.Description != "" {
+		fmt.Printf("Description: %s\n", profile.Description)
+	}
+	if profile.Extends != "" {
+		fmt.Printf("Extends: %s\n", profile.Extends)
+	}
+	if profile.Priority != 0 {
+		fmt.Printf("Priority: %d\n", profile.Priority)
+	}
+
+	printProfileList("Patterns", categorized(profile.Patterns, profile))
+	printProfileList("Regex patterns", categorized(profile.RegexPatterns, profile))
+	printProfileList("Symlinks", categorized(profile.Symlinks, profile))
+	printProfileList("Detect", withContent(profile.Detect, profile))
+	printProfileList("Detect heuristics", heuristics(profile))
+	if len(profile.DetectCommand) > 0 {
+		fmt.Printf("Detect command: %s\n", strings.Join(profile.DetectCommand, " "))
+	}
+	printProfileList("Keep", profile.Keep)
+	printProfileList("Permanent", profile.Permanent)
+
+	if profile.MinSize != "" {
+		fmt.Printf("Minimum size: %s\n", profile.MinSize)
+	}
+	if profile.MinAge != "" {
+		fmt.Printf("Minimum age: %s\n", profile.MinAge)
+	}
+	if profile.RebuildHint != "" {
+		fmt.Printf("Rebuild hint: %s\n", profile.RebuildHint)
+	}
+
+	return nil
+}
+
+// categorized returns patterns with their category, required detect files
+// and stale_after age, if any, in parentheses
+func categorized(patterns []string, profile *types.Profile) []string {
+	lines := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lines[i] = pattern
+		var details []string
+		if category := profile.Categories[pattern]; category != "" {
+			details = append(details, category)
+		}
+		if required := profile.Requires[pattern]; len(required) > 0 {
+			details = append(details, "with "+strings.Join(required, " or "))
+		}
+		if staleAfter := profile.StaleAfter[pattern]; staleAfter != "" {
+			details = append(details, "stale after "+staleAfter)
+		}
+		if len(details) > 0 {
+			lines[i] += " (" + strings.Join(details, ", ") + ")"
+		}
+	}
+	return lines
+}
+
+// withContent returns detect patterns with the content they must match, if
+// any, in parentheses
+func withContent(patterns []string, profile *types.Profile) []string {
+	lines := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lines[i] = pattern
+		if expr := profile.DetectContent[pattern]; expr != "" {
+			lines[i] += " (containing " + expr + ")"
+		}
+	}
+	return lines
+}
+
+// heuristics returns the descriptions of the detect heuristics of profile
+func heuristics(profile *types.Profile) []string {
+	lines := make([]string, len(profile.DetectHeuristics))
+	for i, heuristic := range profile.DetectHeuristics {
+		lines[i] = heuristic.String()
+	}
+	return lines
+}
+
+// printProfileList prints a titled list of profile entries, if any
+func printProfileList(title string, entries []string) {
+	if len(entries) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, entry := range entries {
+		fmt.Printf("  • %s\n", entry)
+	}
+}
+
+// setProfileEnabled enables or disables the profile name and saves the
+// choice in the configuration
+func setProfileEnabled(name string, enabled bool) error {
+	profileLoader, err := requireProfileLoader()
+	if err != nil {
+		return err
+	}
+	if globalConfigManager == nil {
+		return fmt.Errorf("config manager not initialized")
+	}
+
+	profile, err := findProfile(profileLoader, name)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := globalConfigManager.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if cfg.ProfileStates == nil {
+		cfg.ProfileStates = make(map[string]bool)
+	}
+	cfg.ProfileStates[profile.Name] = enabled
+
+	if err := globalConfigManager.SaveKeys(cfg, "profile_states"); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	if err := profileLoader.SetEnabled(profile.Name, enabled); err != nil {
+		return err
+	}
+
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	fmt.Printf("✓ Profile %s %s\n", profile.Name, state)
+	fmt.Printf("Configuration saved to: %s\n", globalConfigManager.GetConfigPath())
+
+	return nil
+}
+
+// runProfileCreate asks for the rules of a new profile and saves it in the
+// user profile directory
+func runProfileCreate(cmd *cobra.Command, args []string) error {
+	profileLoader, err := requireProfileLoader()
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(args[0])
+	fileName := profileFileName(name)
+	if fileName == "" {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+
+	profilesDir, err := userProfilesDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(profilesDir, fileName)
+	_, statErr := os.Stat(path)
+	if statErr == nil && !profileCreateForce {
+		return fmt.Errorf("profile file %s already exists (use --force to overwrite)", path)
+	}
+
+	profile := types.Profile{
+		Name:        name,
+		Version:     "1.0.0",
+		Description: profileCreateDescription,
+		Extends:     profileCreateExtends,
+		Detect:      profileCreateDetect,
+		Patterns:    profileCreatePatterns,
+		Enabled:     true,
+	}
+
+	// Ask for whatever was not given as a flag
+	reader := bufio.NewReader(os.Stdin)
+	if len(profile.Detect) == 0 {
+		profile.Detect = splitList(promptLine(reader, os.Stdout, "Files that identify the project (comma-separated, e.g. build.zig)"))
+	}
+	if len(profile.Patterns) == 0 {
+		profile.Patterns = splitList(promptLine(reader, os.Stdout, "Directories or files to clean (comma-separated, e.g. zig-cache,zig-out)"))
+	}
+	if !cmd.Flags().Changed("description") {
+		profile.Description = promptLine(reader, os.Stdout, "Description (optional)")
+	}
+
+	if profile.Extends != "" {
+		parent, err := findProfile(profileLoader, profile.Extends)
+		if err != nil {
+			return fmt.Errorf("invalid --extends: %w", err)
+		}
+		profile.Extends = parent.Name
+	}
+	if err := profileLoader.ValidateProfile(&profile); err != nil {
+		return fmt.Errorf("invalid profile: %w", err)
+	}
+
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format profile: %w", err)
+	}
+	if err := os.MkdirAll(profilesDir, 0755); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	fmt.Printf("✓ Profile %s saved to %s\n", profile.Name, path)
+	if existing, err := profileLoader.GetProfile(name); err == nil && statErr != nil {
+		fmt.Printf("It overrides the existing %s profile.\n", existing.Name)
+	}
+
+	return nil
+}
+
+// runProfileValidate checks the profile files at the given path, or in all
+// profile directories, and reports their problems
+func runProfileValidate(cmd *cobra.Command, args []string) error {
+	paths := args
+	if len(paths) == 0 {
+		for _, dir := range profileDirectories() {
+			if _, err := os.Stat(dir); err == nil {
+				paths = append(paths, dir)
+			}
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no profile directory found")
+		}
+	}
+
+	// A separate loader, so the loaded profiles are left alone
+	validator := profiles.NewLoader()
+	files := 0
+	var problems []profiles.ValidationProblem
+	for _, path := range paths {
+		report, err := validator.ValidatePath(path)
+		if err != nil {
+			return fmt.Errorf("failed to validate %s: %w", path, err)
+		}
+		files += report.Files
+		problems = append(problems, report.Problems...)
+	}
+
+	if len(problems) == 0 {
+		fmt.Printf("✓ All %d profile file(s) are valid.\n", files)
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("✗ %s\n", problem)
+	}
+	fmt.Println()
+	fmt.Printf("Checked %d profile file(s).\n", files)
+
+	return fmt.Errorf("found %d problem(s)", len(problems))
+}
+
+// runProfileTest shows how a profile matches a directory
+func runProfileTest(cmd *cobra.Command, args []string) error {
+	profileLoader, err := requireProfileLoader()
+	if err != nil {
+		return err
+	}
+
+	profile, err := findProfile(profileLoader, args[0])
+	if err != nil {
+		return err
+	}
+
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	fmt.Printf("Profile: %s\n", profile.Name)
+	fmt.Printf("Directory: %s\n\n", dir)
+
+	pattern, detected := profileLoader.DetectMatch(dir, profile)
+	unconfirmed := *profile
+	unconfirmed.DetectCommand = nil
+	candidates := strings.Join(slices.Concat(profile.Detect, heuristics(profile)), ", ")
+	if pattern != "" {
+		fmt.Printf("✓ Detected by %s (%s)\n", pattern, detected)
+	} else if candidate, _ := profileLoader.DetectMatch(dir, &unconfirmed); candidate != "" {
+		fmt.Printf("✗ Not detected: %s found, but the detect command (%s) failed\n", candidate, strings.Join(profile.DetectCommand, " "))
+	} else if len(profile.DetectContent) > 0 {
+		fmt.Printf("✗ Not detected: none of %s found with the content required\n", candidates)
+	} else {
+		fmt.Printf("✗ Not detected: none of %s found\n", candidates)
+	}
+
+	// Entries matched by name, then paths matched by path patterns
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && profileLoader.MatchesTarget(dir, entry.Name(), profile) {
+			names = append(names, entry.Name())
+		}
+	}
+	for _, path := range profileLoader.NestedTargets(dir, profile) {
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			names = append(names, filepath.ToSlash(rel))
+		}
+	}
+
+	selected := make([]string, len(names))
+	for i, name := range names {
+		line := name
+		if category := profileLoader.Category(name, profile); category != "" {
+			line += " (" + category + ")"
+		}
+		if profileLoader.IsPermanent(name, profile) {
+			line += ", deleted permanently"
+		}
+		if strings.HasPrefix(name, ".") || strings.Contains(name, "/.") {
+			line += ", hidden: only scanned with --include-hidden"
+		}
+		if keep := slices.Concat(profile.Keep, profileLoader.ExcludedPaths(name, profile)); len(keep) > 0 {
+			line += ", keeps " + strings.Join(keep, ", ")
+		}
+		if staleAfter := profileLoader.StaleAfter(name, profile); staleAfter > 0 {
+			if info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil && time.Since(info.ModTime()) < staleAfter {
+				line += ", not stale yet: skipped by scans"
+			}
+		}
+		// Scans use the first detecting profile that selects the directory
+		if other := scanProfile(profileLoader, dir, name); other != nil && other.Name != profile.Name {
+			line += ", selected by " + other.Name + " in scans"
+		}
+		selected[i] = line
+	}
+
+	fmt.Println()
+	switch {
+	case len(selected) == 0:
+		fmt.Println("No directory matches the patterns of the profile.")
+	case pattern != "":
+		printProfileList("Would select", selected)
+	default:
+		printProfileList("Would select if detected", selected)
+	}
+
+	// Global locations are selected once per scan finding a project
+	var globals []string
+	for _, global := range profileLoader.GlobalTargets(profile) {
+		globals = append(globals, global.Path)
+	}
+	if len(globals) > 0 {
+		fmt.Println()
+		printProfileList("Global locations, selected when a scan detects a project", globals)
+	}
+
+	// Symlinks are cleaned through the directories they point to
+	var links []string
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 || !profileLoader.MatchesSymlink(entry.Name(), profile) {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(filepath.Join(dir, entry.Name())); err == nil {
+			links = append(links, entry.Name()+" -> "+resolved)
+		} else {
+			links = append(links, entry.Name()+" (broken link, skipped)")
+		}
+	}
+	if len(links) > 0 {
+		fmt.Println()
+		printProfileList("Would select the targets of symlinks", links)
+	}
+
+	if !profile.Enabled {
+		fmt.Printf("\nNote: %s is disabled, so scans skip it (rosia profile enable %s).\n", profile.Name, profile.Name)
+	}
+	if _, err := os.Stat(filepath.Join(dir, config.ProjectFileName)); err == nil {
+		fmt.Printf("\nNote: the %s of this directory may change what scans select.\n", config.ProjectFileName)
+	}
+	if profile.MinSize != "" || profile.MinAge != "" {
+		fmt.Println("\nNote: scans only report targets that reach the min_size or min_age of the profile.")
+	}
+
+	return nil
+}
+
+// scanProfile returns the profile a scan would select name, an entry of dir
+// or a path inside it, with, or nil
+func scanProfile(profileLoader *profiles.Loader, dir, name string) *types.Profile {
+	detected, err := profileLoader.MatchProfiles(dir)
+	if err != nil {
+		return nil
+	}
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	for _, profile := range detected {
+		if strings.Contains(name, "/") {
+			if slices.Contains(profileLoader.NestedTargets(dir, profile), path) {
+				return profile
+			}
+		} else if profileLoader.MatchesTarget(dir, name, profile) {
+			return profile
+		}
+	}
+	return nil
+}
+
+// profileFileName returns the file name of the profile name, e.g.
+// "next-js.json" for "Next.js"
+func profileFileName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return ""
+	}
+	return slug + ".json"
+}
+
+// promptLine writes question to w and returns the next line read from r
+func promptLine(r *bufio.Reader, w io.Writer, question string) string {
+	fmt.Fprintf(w, "%s: ", question)
+	line, _ := r.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// requireProfileLoader returns the global profile loader, or an error when
+// profiles could not be initialized
+func requireProfileLoader() (*profiles.Loader, error) {
+	profileLoader := GetGlobalProfileLoader()
+	if profileLoader == nil {
+		return nil, fmt.Errorf("profile loader not initialized")
+	}
+	return profileLoader, nil
+}
+
+// findProfile returns the profile name, listing the available profiles when
+// there is none
+func findProfile(profileLoader *profiles.Loader, name string) (*types.Profile, error) {
+	profile, err := profileLoader.FindProfile(name)
+	if err != nil {
+		all := profileLoader.GetProfiles()
+		names := make([]string, len(all))
+		for i, p := range all {
+			names[i] = p.Name
+		}
+		return nil, fmt.Errorf("%w (available: %s)", err, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/internal/profiles"
+)
+
+// createProfile runs 'rosia profile create name' with the given flags, the
+// built-in profiles loaded and the home directory at dir, and returns the
+// path of the user profile it writes
+func createProfile(t *testing.T, dir, name string, detect, patterns []string, extends string, force bool) (string, error) {
+	t.Helper()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, ".config"))
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	profileLoaderOnce = sync.Once{}
+	profileLoaderOnce.Do(func() { globalProfileLoader = loader })
+
+	// Questions of missing flags get empty answers
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+	}
+	os.Stdin, stdin = stdin, os.Stdin
+
+	profileCreateDetect = detect
+	profileCreatePatterns = patterns
+	profileCreateDescription = "Created by a test"
+	profileCreateExtends = extends
+	profileCreateForce = force
+	description := profileCreateCmd.Flags().Lookup("description")
+	description.Changed = true
+	t.Cleanup(func() {
+		os.Stdin.Close()
+		os.Stdin = stdin
+		profileCreateDetect, profileCreatePatterns = nil, nil
+		profileCreateDescription, profileCreateExtends = "", ""
+		profileCreateForce = false
+		description.Changed = false
+	})
+
+	path := filepath.Join(dir, ".config", "rosia", "profiles", profileFileName(name))
+	return path, runProfileCreate(profileCreateCmd, []string{name})
+}
+
+func TestProfileCreate(t *testing.T) {
+	dir := t.TempDir()
+	path, err := createProfile(t, dir, "Zig Build", []string{"build.zig"}, []string{"zig-cache", "zig-out"}, "", false)
+	if err != nil {
+		t.Fatalf("profile create failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the profile at %s: %v", path, err)
+	}
+	expected := `{
+  "name": "Zig Build",
+  "version": "1.0.0",
+  "patterns": [
+    "zig-cache",
+    "zig-out"
+  ],
+  "detect": [
+    "build.zig"
+  ],
+  "description": "Created by a test",
+  "enabled": true
+}
+`
+	if string(data) != expected {
+		t.Errorf("Expected profile:\n%s\ngot:\n%s", expected, data)
+	}
+
+	// The saved profile loads back
+	if _, err := profiles.NewLoader().LoadDirs(filepath.Dir(path)); err != nil {
+		t.Errorf("Failed to load the created profile: %v", err)
+	}
+}
+
+func TestProfileCreate_Extends(t *testing.T) {
+	dir := t.TempDir()
+	path, err := createProfile(t, dir, "Go Tools", nil, []string{"bin"}, "go", false)
+	if err != nil {
+		t.Fatalf("profile create failed: %v", err)
+	}
+
+	// The parent is saved under its name, and its detect files are inherited
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the profile at %s: %v", path, err)
+	}
+	if !strings.Contains(string(data), `"extends": "Go"`) {
+		t.Errorf("Expected the profile to extend Go, got:\n%s", data)
+	}
+}
+
+func TestProfileCreate_Exists(t *testing.T) {
+	dir := t.TempDir()
+	path, err := createProfile(t, dir, "Zig", []string{"build.zig"}, []string{"zig-cache"}, "", false)
+	if err != nil {
+		t.Fatalf("profile create failed: %v", err)
+	}
+
+	// An existing profile file is kept without --force
+	_, err = createProfile(t, dir, "Zig", []string{"build.zig"}, []string{"zig-out"}, "", false)
+	if err == nil || !strings.Contains(err.Error(), "already exists (use --force to overwrite)") {
+		t.Fatalf("Expected an existing profile error, got: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read profile: %v", err)
+	}
+	if !strings.Contains(string(data), "zig-cache") {
+		t.Errorf("Expected the profile to be kept, got:\n%s", data)
+	}
+
+	// And overwritten with it
+	if _, err := createProfile(t, dir, "Zig", []string{"build.zig"}, []string{"zig-out"}, "", true); err != nil {
+		t.Fatalf("profile create --force failed: %v", err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read profile: %v", err)
+	}
+	if strings.Contains(string(data), "zig-cache") || !strings.Contains(string(data), "zig-out") {
+		t.Errorf("Expected the profile to be overwritten, got:\n%s", data)
+	}
+}
+
+func TestProfileCreate_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  string
+		detect   []string
+		patterns []string
+		extends  string
+		want     string
+	}{
+		{name: "no file name", profile: "...", detect: []string{"build.zig"}, patterns: []string{"zig-out"}, want: `invalid profile name "..."`},
+		{name: "no detect files", profile: "Zig", patterns: []string{"zig-out"}, want: "at least one detect pattern"},
+		{name: "no patterns", profile: "Zig", detect: []string{"build.zig"}, want: "at least one pattern"},
+		{name: "invalid pattern", profile: "Zig", detect: []string{"build.zig"}, patterns: []string{"zig-[out"}, want: "invalid glob pattern"},
+		{name: "unknown parent", profile: "Zig", patterns: []string{"zig-out"}, extends: "zag", want: "invalid --extends"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := createProfile(t, t.TempDir(), tt.profile, tt.detect, tt.patterns, tt.extends, false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Expected an error with %q, got: %v", tt.want, err)
+			}
+			// Nothing is saved
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("Expected no profile file, got: %v", err)
+			}
+		})
+	}
+}
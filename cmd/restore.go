@@ -2,16 +2,31 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/raucheacho/rosia-cli/internal/telemetry"
 	"github.com/raucheacho/rosia-cli/internal/trash"
 	"github.com/raucheacho/rosia-cli/pkg/logger"
 	"github.com/spf13/cobra"
 )
 
 var (
-	restoreList bool
-	restoreAll  bool
+	restoreList  bool
+	restoreAll   bool
+	restoreTo    string
+	restoreForce bool
+	restoreMerge bool
+	restoreYes   bool
+	restoreOnly  []string
+	restoreDry   bool
+
+	restoreProfile string
+	restorePath    string
+	restoreSince   string
+	restoreBefore  string
 )
 
 // restoreCmd represents the restore command
@@ -20,24 +35,54 @@ var restoreCmd = &cobra.Command{
 	Short: "Restore a trashed item to its original location",
 	Long: `Restore a previously trashed item back to its original location.
 
-When you clean files with rosia, they are moved to ~/.rosia/trash instead
+When you clean files with rosia, they are moved to the trash instead
 of being permanently deleted. This command allows you to restore those files
 if you change your mind or accidentally deleted something important.
 
 Flags:
   -l, --list                List all trashed items with their IDs
       --all                 Restore all trashed items
+      --to <path>           Restore to another location instead
+      --force               Replace an existing path (it is moved to the trash)
+      --merge               Merge into an existing directory (trashed files win)
+      --only <glob>         Restore only matching paths inside the item
+      --dry-run             Show where the item would go without restoring it
+      --profile <name>      Only list or restore items cleaned by this profile
+      --path <glob>         Only list or restore items whose original path matches
+      --since <time>        Only list or restore items deleted at or after this time
+      --before <time>       Only list or restore items deleted before this time
+  -y, --yes                 Skip confirmation prompts
 
 Examples:
   # List all trashed items
   rosia restore --list
 
+  # List the node_modules trashed by the Node profile in the last week
+  rosia restore --list --profile Node --path node_modules --since 7d
+
   # Restore a specific item by ID
   rosia restore 20250428_143022_node_modules
 
   # Restore all trashed items
   rosia restore --all
 
+  # Restore into another directory when the original one is gone
+  rosia restore 20250428_143022_node_modules --to ~/recovered
+
+  # Replace a node_modules that was reinstalled since the clean
+  rosia restore 20250428_143022_node_modules --force
+
+  # Check for conflicts and free space before restoring
+  rosia restore 20250428_143022_node_modules --dry-run
+
+  # Recover a single file and keep the rest in the trash
+  rosia restore 20250428_143022_node_modules --only node_modules/.package-lock.json
+
+Filters:
+  --path matches the whole original path, or only its last element when the
+  glob has no "/". --since and --before take a date (2025-04-28), an RFC 3339
+  timestamp or an age relative to now (36h, 7d, 2w).
+
 Trash ID Format:
   Trash IDs follow the format: YYYYMMDD_HHMMSS_<basename>
   Example: 20250428_143022_node_modules
@@ -45,8 +90,8 @@ Trash ID Format:
 Tips:
   • Use --list to see available items before restoring
   • Trash items are automatically cleaned after retention period (default: 3 days)
-  • Original paths must be available for restoration
-  • If path conflicts exist, restoration will fail with an error`,
+  • Original paths must be available for restoration, otherwise use --to
+  • If path conflicts exist, restoration fails unless --force or --merge is set`,
 	RunE: runRestore,
 }
 
@@ -56,25 +101,58 @@ func init() {
 	// Restore-specific flags
 	restoreCmd.Flags().BoolVarP(&restoreList, "list", "l", false, "list all trashed items")
 	restoreCmd.Flags().BoolVar(&restoreAll, "all", false, "restore all trashed items")
+	restoreCmd.Flags().StringVar(&restoreTo, "to", "", "restore to this path instead of the original location")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "replace an existing path, moving it to the trash")
+	restoreCmd.Flags().BoolVar(&restoreMerge, "merge", false, "merge into an existing directory, moving files with the same name to the trash")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "skip confirmation prompts")
+	restoreCmd.Flags().StringArrayVar(&restoreOnly, "only", nil, "restore only paths inside the item matching this glob (repeatable)")
+	restoreCmd.Flags().BoolVar(&restoreDry, "dry-run", false, "show where the item would go, conflicts and space needed without restoring")
+	restoreCmd.Flags().StringVar(&restoreProfile, "profile", "", "with --list or --all, only items cleaned by this profile")
+	restoreCmd.Flags().StringVar(&restorePath, "path", "", "with --list or --all, only items whose original path matches this glob")
+	restoreCmd.Flags().StringVar(&restoreSince, "since", "", "with --list or --all, only items deleted at or after this date or age (e.g. 2025-04-28, 7d)")
+	restoreCmd.Flags().StringVar(&restoreBefore, "before", "", "with --list or --all, only items deleted before this date or age")
+	restoreCmd.MarkFlagsMutuallyExclusive("force", "merge")
 }
 
 func runRestore(cmd *cobra.Command, args []string) error {
 	// Initialize trash system
 	logger.Debug("Initializing trash system")
-	trashSystem, err := trash.NewDefaultSystem()
+	trashSystem, err := openTrash()
 	if err != nil {
 		logger.Error("Failed to initialize trash system: %v", err)
 		return fmt.Errorf("failed to initialize trash system: %w", err)
 	}
 
+	filter, err := restoreFilter()
+	if err != nil {
+		return err
+	}
+	filtered := filter != (trash.ListFilter{})
+
 	// Handle --list flag
 	if restoreList {
-		return listTrashedItems(trashSystem)
+		return listTrashedItems(trashSystem, filter)
+	}
+
+	if filtered && !restoreAll {
+		return fmt.Errorf("--profile, --path, --since and --before can only be used with --list or --all")
+	}
+
+	if restoreAll && (restoreTo != "" || len(restoreOnly) > 0 || restoreDry) {
+		return fmt.Errorf("--to, --only and --dry-run can only be used when restoring a single item")
+	}
+
+	conflict := trash.ConflictFail
+	switch {
+	case restoreForce:
+		conflict = trash.ConflictReplace
+	case restoreMerge:
+		conflict = trash.ConflictMerge
 	}
 
 	// Handle --all flag
 	if restoreAll {
-		return restoreAllItems(trashSystem)
+		return restoreAllItems(trashSystem, filter, conflict)
 	}
 
 	// Require trash ID argument if not using --list or --all
@@ -93,30 +171,109 @@ func runRestore(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get trash metadata: %w", err)
 	}
 
-	logger.Info("Restoring: %s (size: %s)", metadata.OriginalPath, formatSize(metadata.Size))
+	dest := metadata.OriginalPath
+	if restoreTo != "" {
+		dest = resolveRestoreDestination(restoreTo, metadata.OriginalPath)
+	}
+
+	opts := trash.RestoreOptions{Conflict: conflict, Only: restoreOnly}
+	if restoreTo != "" {
+		opts.To = dest
+	}
+
+	if restoreDry {
+		return previewRestore(trashSystem, trashID, opts)
+	}
+
+	logger.Info("Restoring: %s (size: %s)", dest, formatSize(metadata.Size))
+
+	if _, err := os.Lstat(dest); err == nil && conflict != trash.ConflictFail && !restoreYes {
+		if len(restoreOnly) > 0 {
+			fmt.Printf("Existing paths in %s matching %s will be moved to the trash and replaced.\n", dest, strings.Join(restoreOnly, ", "))
+			if !promptYesNo(os.Stdout, "Do you want to continue?") {
+				fmt.Println("Restore cancelled.")
+				return nil
+			}
+		} else if !confirmConflict(dest, conflict) {
+			fmt.Println("Restore cancelled.")
+			return nil
+		}
+	}
 
 	// Restore the item
-	if err := trashSystem.Restore(trashID); err != nil {
+	if err := trashSystem.RestoreWith(trashID, opts); err != nil {
 		logger.Error("Failed to restore item %s: %v", trashID, err)
+		recordOperation(telemetry.EventRestore, 0, 0, 1, err)
 		return fmt.Errorf("failed to restore item: %w", err)
 	}
 
-	fmt.Printf("✓ Successfully restored: %s\n", metadata.OriginalPath)
-	logger.Info("Successfully restored: %s", metadata.OriginalPath)
+	if len(restoreOnly) > 0 {
+		// Only part of the item is restored, of unknown size
+		recordOperation(telemetry.EventRestore, 1, 0, 0, nil)
+		fmt.Printf("✓ Restored paths matching %s into %s (the item stays in the trash)\n", strings.Join(restoreOnly, ", "), dest)
+		logger.Info("Partially restored %s into %s", trashID, dest)
+		return nil
+	}
+
+	recordOperation(telemetry.EventRestore, 1, metadata.Size, 0, nil)
+	fmt.Printf("✓ Successfully restored: %s\n", dest)
+	logger.Info("Successfully restored: %s", dest)
 
 	return nil
 }
 
-func listTrashedItems(trashSystem *trash.System) error {
+// restoreFilter returns the trash filter selected by the filter flags
+func restoreFilter() (trash.ListFilter, error) {
+	filter := trash.ListFilter{Profile: restoreProfile, PathPattern: restorePath}
+
+	if restorePath != "" {
+		if _, err := filepath.Match(restorePath, ""); err != nil {
+			return filter, fmt.Errorf("invalid --path glob %q: %w", restorePath, err)
+		}
+	}
+
+	now := time.Now()
+	if restoreSince != "" {
+		t, err := parseTime(restoreSince, now)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --since: %w", err)
+		}
+		filter.DeletedAfter = t
+	}
+	if restoreBefore != "" {
+		t, err := parseTime(restoreBefore, now)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --before: %w", err)
+		}
+		filter.DeletedBefore = t
+	}
+
+	return filter, nil
+}
+
+// resolveRestoreDestination returns the path an item is restored to with
+// --to. Like mv, an existing directory receives the item under its original name.
+func resolveRestoreDestination(to, originalPath string) string {
+	if info, err := os.Stat(to); err == nil && info.IsDir() {
+		return filepath.Join(to, filepath.Base(originalPath))
+	}
+	return to
+}
+
+func listTrashedItems(trashSystem *trash.System, filter trash.ListFilter) error {
 	logger.Debug("Listing trashed items")
-	items, err := trashSystem.List()
+	items, err := trashSystem.ListWith(filter)
 	if err != nil {
 		logger.Error("Failed to list trashed items: %v", err)
 		return fmt.Errorf("failed to list trashed items: %w", err)
 	}
 
 	if len(items) == 0 {
-		fmt.Println("No trashed items found.")
+		if filter != (trash.ListFilter{}) {
+			fmt.Println("No trashed items match the filters.")
+		} else {
+			fmt.Println("No trashed items found.")
+		}
 		return nil
 	}
 
@@ -124,8 +281,8 @@ func listTrashedItems(trashSystem *trash.System) error {
 	fmt.Printf("Found %d trashed item(s):\n\n", len(items))
 
 	// Display table header
-	fmt.Printf("%-40s %-40s %-15s %-20s\n", "TRASH ID", "ORIGINAL PATH", "SIZE", "DELETED AT")
-	fmt.Println(strings.Repeat("-", 120))
+	fmt.Printf("%-40s %-40s %-15s %-12s %-20s\n", "TRASH ID", "ORIGINAL PATH", "PROFILE", "SIZE", "DELETED AT")
+	fmt.Println(strings.Repeat("-", 132))
 
 	// Calculate total size
 	var totalSize int64
@@ -135,64 +292,151 @@ func listTrashedItems(trashSystem *trash.System) error {
 		totalSize += item.Size
 
 		id := item.ID
-		if len(id) > 38 {
-			id = id[:35] + "..."
+		suffix := ""
+		if item.Pinned {
+			suffix = " (pinned)"
+		}
+		if len(id)+len(suffix) > 38 {
+			id = id[:35-len(suffix)] + "..."
 		}
+		id += suffix
 
 		path := item.OriginalPath
 		if len(path) > 38 {
 			path = "..." + path[len(path)-35:]
 		}
 
+		profile := item.ProfileName
+		if profile == "" {
+			profile = "-"
+		} else if len(profile) > 15 {
+			profile = profile[:12] + "..."
+		}
+
 		deletedAt := item.DeletedAt.Format("2006-01-02 15:04:05")
 
-		fmt.Printf("%-40s %-40s %-15s %-20s\n",
+		fmt.Printf("%-40s %-40s %-15s %-12s %-20s\n",
 			id,
 			path,
+			profile,
 			formatSize(item.Size),
 			deletedAt,
 		)
 	}
 
-	fmt.Println(strings.Repeat("-", 120))
+	fmt.Println(strings.Repeat("-", 132))
 	fmt.Printf("Total: %s across %d item(s)\n", formatSize(totalSize), len(items))
 	fmt.Println("\nTo restore an item, use: rosia restore <trash-id>")
 
 	return nil
 }
 
-func restoreAllItems(trashSystem *trash.System) error {
+// previewRestore prints what restoring trashID with opts would do
+func previewRestore(trashSystem *trash.System, trashID string, opts trash.RestoreOptions) error {
+	preview, err := trashSystem.Preview(trashID, opts)
+	if err != nil {
+		logger.Error("Failed to preview restore of %s: %v", trashID, err)
+		return fmt.Errorf("failed to preview restore: %w", err)
+	}
+
+	fmt.Printf("Dry run: nothing will be restored.\n\n")
+	fmt.Printf("Item:         %s\n", trashID)
+	fmt.Printf("Destination:  %s\n", preview.Dest)
+
+	switch {
+	case !preview.Exists:
+		fmt.Printf("Conflict:     none\n")
+	case len(opts.Only) > 0:
+		fmt.Printf("Conflict:     destination exists; matching paths are merged into it\n")
+	case opts.Conflict == trash.ConflictReplace:
+		fmt.Printf("Conflict:     destination exists and would be moved to the trash (--force)\n")
+	case opts.Conflict == trash.ConflictMerge:
+		fmt.Printf("Conflict:     destination exists and would be merged into (--merge)\n")
+	default:
+		fmt.Printf("Conflict:     destination exists (use --force or --merge)\n")
+	}
+
+	if preview.SpaceNeeded == 0 {
+		fmt.Printf("Space needed: none (moved within the same filesystem)\n")
+	} else {
+		fmt.Printf("Space needed: %s\n", formatSize(preview.SpaceNeeded))
+	}
+	if preview.SpaceFree >= 0 {
+		fmt.Printf("Space free:   %s\n", formatSize(preview.SpaceFree))
+	} else {
+		fmt.Printf("Space free:   unknown\n")
+	}
+
+	fmt.Println()
+	if preview.Err != nil {
+		fmt.Printf("✗ The restore would fail: %v\n", preview.Err)
+	} else {
+		fmt.Println("✓ The restore would succeed")
+	}
+	return nil
+}
+
+// confirmConflict asks before replacing or merging into an existing dest
+func confirmConflict(dest string, conflict trash.ConflictMode) bool {
+	if conflict == trash.ConflictReplace {
+		fmt.Printf("%s already exists and will be replaced. It is moved to the trash first.\n", dest)
+	} else {
+		fmt.Printf("The item will be merged into %s. Files with the same name will be moved to the trash and replaced.\n", dest)
+	}
+	return promptYesNo(os.Stdout, "Do you want to continue?")
+}
+
+func restoreAllItems(trashSystem *trash.System, filter trash.ListFilter, conflict trash.ConflictMode) error {
 	logger.Debug("Restoring all trashed items")
-	items, err := trashSystem.List()
+	items, err := trashSystem.ListWith(filter)
 	if err != nil {
 		logger.Error("Failed to list trashed items: %v", err)
 		return fmt.Errorf("failed to list trashed items: %w", err)
 	}
 
 	if len(items) == 0 {
-		fmt.Println("No trashed items found.")
+		if filter != (trash.ListFilter{}) {
+			fmt.Println("No trashed items match the filters.")
+		} else {
+			fmt.Println("No trashed items found.")
+		}
 		return nil
 	}
 
+	if conflict != trash.ConflictFail && !restoreYes {
+		fmt.Println("Items whose original path exists will be replaced (--force) or merged (--merge).")
+		if !promptYesNo(os.Stdout, "Do you want to continue?") {
+			fmt.Println("Restore cancelled.")
+			return nil
+		}
+	}
+
 	fmt.Printf("Restoring %d item(s)...\n\n", len(items))
 	logger.Info("Restoring %d items", len(items))
 
 	successCount := 0
 	errorCount := 0
+	var restoredSize int64
+	var firstErr error
 
 	for _, item := range items {
 		fmt.Printf("Restoring: %s... ", item.OriginalPath)
 
-		if err := trashSystem.Restore(item.ID); err != nil {
+		if err := trashSystem.RestoreWith(item.ID, trash.RestoreOptions{Conflict: conflict}); err != nil {
 			fmt.Printf("✗ Failed: %v\n", err)
 			logger.Error("Failed to restore %s: %v", item.OriginalPath, err)
 			errorCount++
+			if firstErr == nil {
+				firstErr = err
+			}
 		} else {
 			fmt.Println("✓ Success")
 			logger.Debug("Restored %s", item.OriginalPath)
 			successCount++
+			restoredSize += item.Size
 		}
 	}
+	recordOperation(telemetry.EventRestore, successCount, restoredSize, errorCount, firstErr)
 
 	fmt.Printf("\nRestored %d item(s), %d error(s)\n", successCount, errorCount)
 	logger.Info("Restore all completed: %d success, %d errors", successCount, errorCount)
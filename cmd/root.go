@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/raucheacho/rosia-cli/internal/config"
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
 	"github.com/raucheacho/rosia-cli/internal/plugins"
 	"github.com/raucheacho/rosia-cli/internal/profiles"
 	"github.com/raucheacho/rosia-cli/pkg/logger"
@@ -28,6 +31,7 @@ var (
 	globalConfigManager  *config.Manager
 	globalProfileLoader  *profiles.Loader
 	globalPluginRegistry plugins.PluginRegistry
+	profileLoaderOnce    sync.Once
 )
 
 // rootCmd represents the base command
@@ -74,6 +78,8 @@ For more information, visit: https://github.com/raucheacho/rosia-cli`,
 
 // Execute runs the root command
 func Execute() error {
+	// Telemetry is written in the background, until the command returns
+	defer closeTelemetryStores()
 	return rootCmd.Execute()
 }
 
@@ -121,7 +127,7 @@ func isCriticalError(err error) bool {
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose logging")
-	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "config file path (default: ~/.rosiarc.json)")
+	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "config file path (default: config.json of the config directory)")
 
 	// Set up initialization hooks
 	cobra.OnInitialize(initLogger, initComponents)
@@ -156,55 +162,148 @@ func initComponents() {
 	// Load and validate configuration
 	globalConfig, err = globalConfigManager.LoadAndValidate()
 	if err != nil {
-		logger.Debug("Failed to load config, using defaults: %v", err)
+		logger.Warn("Invalid configuration, using defaults: %v", err)
 		globalConfig = globalConfigManager.GetDefault()
 	} else {
 		logger.Debug("Configuration loaded successfully")
 	}
 
-	// Initialize profile loader
-	globalProfileLoader = profiles.NewLoader()
+	// Unknown keys are ignored, but usually typos
+	for _, key := range globalConfigManager.UnknownKeys() {
+		logger.Warn("%s: %s", key.File, key)
+	}
 
-	// Determine profiles directory
-	profilesDir := findProfilesDirectory()
+	// Plugins and profiles are loaded on first use, as most commands need
+	// neither
+	globalPluginRegistry = plugins.NewLazyRegistry(loadPlugins)
+}
 
-	// Load profiles
-	loadedProfiles, err := globalProfileLoader.LoadAll(profilesDir)
+// reloadConfig loads the configuration files again, for long-running
+// commands to apply their edits. Invalid files keep the current
+// configuration rather than falling back to the defaults.
+func reloadConfig() error {
+	cfg, err := globalConfigManager.LoadAndValidate()
 	if err != nil {
-		logger.Warn("Failed to load profiles: %v", err)
-	} else {
-		logger.Debug("Loaded %d profile(s) from %s", len(loadedProfiles), profilesDir)
-		if verbose {
-			for _, p := range loadedProfiles {
-				logger.Debug("  - %s (v%s): %s", p.Name, p.Version, p.Description)
-			}
+		return err
+	}
+	globalConfig = cfg
+	return nil
+}
+
+// loadPlugins registers the plugins enabled in the configuration, installed
+// or built-in, into registry and configures them
+func loadPlugins(registry plugins.PluginRegistry) {
+	if len(globalConfig.Plugins) == 0 {
+		return
+	}
+
+	pluginsDir := findPluginsDirectory()
+	if pluginsDir != "" {
+		// The other plugins are neither opened nor started
+		if err := registry.LoadEnabled(pluginsDir, globalConfig.Plugins); err != nil {
+			warnPluginErrors(err)
+		}
+	}
+	registerBuiltinPlugins(registry)
+
+	// Only the plugins of the configuration are enabled, whatever name a
+	// plugin file gives itself
+	for _, p := range registry.List() {
+		if !slices.Contains(globalConfig.Plugins, p.Name()) {
+			registry.Unregister(p.Name())
+		}
+	}
+	for _, err := range plugins.Configure(registry, globalConfig.PluginSettings) {
+		logger.Warn("%v", err)
+	}
+	pluginList := registry.List()
+	logger.Debug("Loaded %d plugin(s)", len(pluginList))
+	if verbose {
+		for _, p := range pluginList {
+			logger.Debug("  - %s (v%s): %s", p.Name(), p.Version(), p.Description())
 		}
 	}
+}
 
-	// Initialize plugin registry
-	globalPluginRegistry = plugins.NewRegistry()
-
-	// Load plugins if configured
-	if len(globalConfig.Plugins) > 0 {
-		pluginsDir := findPluginsDirectory()
-		if pluginsDir != "" {
-			err := globalPluginRegistry.LoadAll(pluginsDir)
-			if err != nil {
-				logger.Warn("Failed to load plugins: %v", err)
-			} else {
-				pluginList := globalPluginRegistry.List()
-				logger.Debug("Loaded %d plugin(s)", len(pluginList))
-				if verbose {
-					for _, p := range pluginList {
-						logger.Debug("  - %s (v%s): %s", p.Name(), p.Version(), p.Description())
-					}
-				}
+// loadProfiles loads the built-in, user and plugin profiles into loader and
+// applies the profile settings of the configuration. The TUI also calls it to
+// reload edited profiles.
+func loadProfiles(loader *profiles.Loader) error {
+	profilesDirs := profileDirectories()
+	loadedProfiles, err := loader.LoadDirs(profilesDirs...)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Loaded %d profile(s) from %s", len(loadedProfiles), strings.Join(profilesDirs, ", "))
+	if verbose {
+		for _, p := range loadedProfiles {
+			logger.Debug("  - %s (v%s): %s", p.Name, p.Version, p.Description)
+		}
+	}
+
+	// Plugins contribute profiles, which profile files override
+	if globalPluginRegistry != nil {
+		for _, p := range globalPluginRegistry.List() {
+			provider, ok := p.(plugins.ProfileProvider)
+			if !ok {
+				continue
+			}
+			for _, err := range loader.AddProfiles("plugin "+p.Name(), provider.Profiles()...) {
+				logger.Warn("Skipping profile: %v", err)
 			}
 		}
 	}
+
+	// Only the profiles listed in the configuration are used, if any
+	if unknown := loader.EnableOnly(globalConfig.Profiles); len(unknown) > 0 {
+		logger.Warn("Unknown profile(s) in the profiles of the config: %s", strings.Join(unknown, ", "))
+	}
+
+	// Apply the profiles enabled and disabled with 'rosia profile', which
+	// override the list of the configuration
+	for name, enabled := range globalConfig.ProfileStates {
+		if err := loader.SetEnabled(name, enabled); err != nil {
+			logger.Debug("Ignoring state of profile %s: %v", name, err)
+		}
+	}
+
+	// Detect commands only run for the profiles the configuration lists
+	if unknown := loader.AllowCommands(globalConfig.DetectCommands); len(unknown) > 0 {
+		logger.Warn("Unknown profile(s) in the detect_commands of the config: %s", strings.Join(unknown, ", "))
+	}
+	for _, p := range loader.GetProfiles() {
+		if len(p.DetectCommand) > 0 && p.Enabled && !loader.CommandAllowed(&p) {
+			logger.Warn("Profile %s detects nothing: add it to detect_commands to run %q", p.Name, strings.Join(p.DetectCommand, " "))
+		}
+	}
+
+	return nil
 }
 
-// findProfilesDirectory locates the profiles directory
+// profileDirectories returns the directories profiles are loaded from, in
+// order of precedence: the built-in profiles, then the legacy
+// ~/.rosia/profiles, then the profiles directory of the config directory
+func profileDirectories() []string {
+	dirs := []string{findProfilesDirectory()}
+
+	if location, err := fsutils.ProfilesLocation(); err == nil {
+		dirs = append(dirs, location.Legacy, location.Platform)
+	}
+
+	return dirs
+}
+
+// userProfilesDir returns the directory new user profiles are saved to:
+// ~/.rosia/profiles until it is migrated, the platform one otherwise
+func userProfilesDir() (string, error) {
+	location, err := fsutils.ProfilesLocation()
+	if err != nil {
+		return "", err
+	}
+	return location.Path(), nil
+}
+
+// findProfilesDirectory locates the built-in profiles directory
 func findProfilesDirectory() string {
 	// Try current directory first
 	if _, err := os.Stat("profiles"); err == nil {
@@ -221,27 +320,16 @@ func findProfilesDirectory() string {
 		}
 	}
 
-	// Try home directory
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		profilesDir := filepath.Join(homeDir, ".rosia", "profiles")
-		if _, err := os.Stat(profilesDir); err == nil {
-			return profilesDir
-		}
-	}
-
 	// Default to current directory
 	return "profiles"
 }
 
 // findPluginsDirectory locates the plugins directory
 func findPluginsDirectory() string {
-	// Try home directory first
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		pluginsDir := filepath.Join(homeDir, ".rosia", "plugins")
-		if _, err := os.Stat(pluginsDir); err == nil {
-			return pluginsDir
+	// Try the user plugins directory first
+	if location, err := fsutils.PluginsLocation(); err == nil {
+		if _, err := os.Stat(location.Path()); err == nil {
+			return location.Path()
 		}
 	}
 
@@ -278,8 +366,15 @@ func GetGlobalConfig() *config.Config {
 	return globalConfig
 }
 
-// GetGlobalProfileLoader returns the global profile loader
+// GetGlobalProfileLoader returns the global profile loader, loading the
+// profiles on first use, after the plugins contributing profiles
 func GetGlobalProfileLoader() *profiles.Loader {
+	profileLoaderOnce.Do(func() {
+		globalProfileLoader = profiles.NewLoader()
+		if err := loadProfiles(globalProfileLoader); err != nil {
+			logger.Warn("Failed to load profiles: %v", err)
+		}
+	})
 	return globalProfileLoader
 }
 
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/raucheacho/rosia-cli/internal/scanner"
 	"github.com/raucheacho/rosia-cli/pkg/logger"
@@ -18,6 +19,9 @@ var (
 	scanDepth         int
 	scanIncludeHidden bool
 	scanDryRun        bool
+	scanCategories    []string
+	scanExclude       []string
+	scanExcludePlugin []string
 )
 
 // scanCmd represents the scan command
@@ -25,7 +29,8 @@ var scanCmd = &cobra.Command{
 	Use:   "scan [paths...]",
 	Short: "Scan directories for cleanable files and caches",
 	Long: `Scan one or more directories to identify cleanable files and caches
-based on loaded technology profiles.
+based on loaded technology profiles. Without paths, the scan_paths of the
+configuration are scanned.
 
 The scan command recursively traverses directories and identifies targets
 that match cleaning patterns for various technologies (Node.js, Python, Rust, etc.).
@@ -35,6 +40,15 @@ Flags:
   -d, --depth int           Maximum depth to scan (0 = unlimited)
   -H, --include-hidden      Include hidden files and directories
       --dry-run             Perform scan without making any changes
+      --category strings    Only report targets of these categories
+      --exclude-category strings
+                            Never report targets of these categories
+      --exclude-plugin strings
+                            Do not scan with these plugins
+
+Categories: dependencies, build, cache, coverage. Profiles assign them to
+their patterns; targets of uncategorized patterns are left out by --category
+and kept by --exclude-category.
 
 Examples:
   # Scan current directory
@@ -46,6 +60,9 @@ Examples:
   # Scan multiple directories
   rosia scan ~/projects/app1 ~/projects/app2
 
+  # Scan the scan_paths of the configuration
+  rosia scan
+
   # Limit scan depth to 3 levels
   rosia scan . --depth 3
 
@@ -55,11 +72,16 @@ Examples:
   # Dry run mode (no changes)
   rosia scan . --dry-run
 
+  # Only caches, leaving dependencies alone
+  rosia scan ~/projects --category cache
+
+  # Leave out the results of a plugin without uninstalling it
+  rosia scan ~/projects --exclude-plugin rosia-docker
+
 Tips:
   • Use --depth to limit scanning in large directory trees
   • Combine with 'clean' command: rosia scan . && rosia clean .
   • Use --verbose flag for detailed logging`,
-	Args: cobra.MinimumNArgs(1),
 	RunE: runScan,
 }
 
@@ -70,15 +92,34 @@ func init() {
 	scanCmd.Flags().IntVarP(&scanDepth, "depth", "d", 0, "maximum depth to scan (0 = unlimited)")
 	scanCmd.Flags().BoolVarP(&scanIncludeHidden, "include-hidden", "H", false, "include hidden files and directories")
 	scanCmd.Flags().BoolVar(&scanDryRun, "dry-run", false, "perform scan without making any changes")
+	scanCmd.Flags().StringSliceVar(&scanCategories, "category", nil, "only report targets of these categories (dependencies, build, cache, coverage)")
+	scanCmd.Flags().StringSliceVar(&scanExclude, "exclude-category", nil, "never report targets of these categories")
+	scanCmd.Flags().StringSliceVar(&scanExcludePlugin, "exclude-plugin", nil, "do not scan with these plugins")
 }
 
 func runScan(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
+	if err := validateCategories("category", scanCategories); err != nil {
+		return err
+	}
+	if err := validateCategories("exclude-category", scanExclude); err != nil {
+		return err
+	}
+	warnUnknownPlugins(scanExcludePlugin)
+
 	// Use global configuration and profile loader
 	cfg := GetGlobalConfig()
 	profileLoader := GetGlobalProfileLoader()
 
+	// The scan section of the configuration sets the flags not given
+	if !cmd.Flags().Changed("depth") && cfg.Scan.Depth > 0 {
+		scanDepth = cfg.Scan.Depth
+	}
+	if !cmd.Flags().Changed("include-hidden") && cfg.Scan.IncludeHidden {
+		scanIncludeHidden = true
+	}
+
 	if profileLoader == nil {
 		logger.Error("Profile loader not initialized")
 		return fmt.Errorf("profile loader not initialized")
@@ -88,6 +129,9 @@ func runScan(cmd *cobra.Command, args []string) error {
 
 	// Create scanner
 	scan := scanner.NewScanner(profileLoader)
+	if registry := GetGlobalPluginRegistry(); registry != nil {
+		scan.SetPluginRegistry(registry)
+	}
 
 	// Initialize telemetry if enabled
 	if cfg.TelemetryEnabled {
@@ -102,14 +146,23 @@ func runScan(cmd *cobra.Command, args []string) error {
 
 	// Prepare scan options
 	opts := scanner.ScanOptions{
-		MaxDepth:      scanDepth,
-		IncludeHidden: scanIncludeHidden,
-		DryRun:        scanDryRun,
-		IgnorePaths:   cfg.IgnorePaths,
-		Concurrency:   cfg.Concurrency,
+		MaxDepth:          scanDepth,
+		IncludeHidden:     scanIncludeHidden,
+		DryRun:            scanDryRun,
+		IgnorePaths:       cfg.IgnorePaths,
+		Concurrency:       cfg.Concurrency,
+		Categories:        scanCategories,
+		ExcludeCategories: scanExclude,
+		ExcludePlugins:    scanExcludePlugin,
+		PluginTimeout:     time.Duration(cfg.PluginTimeoutSeconds) * time.Second,
+		ProtectedPaths:    protectedPaths(cfg),
 	}
 
 	// Resolve and validate paths
+	args, err := pathsOrScanPaths(args, cfg)
+	if err != nil {
+		return err
+	}
 	scanPaths := make([]string, 0, len(args))
 	for _, path := range args {
 		absPath, err := filepath.Abs(path)
@@ -221,7 +274,7 @@ func displayScanResults(targets []types.Target) {
 
 		fmt.Printf("%-50s %-15s %-15s\n",
 			path,
-			target.ProfileName,
+			targetOrigin(target),
 			formatSize(target.Size),
 		)
 	}
@@ -230,3 +283,30 @@ func displayScanResults(targets []types.Target) {
 	fmt.Printf("Total: %s across %d target(s)\n", formatSize(totalSize), len(targets))
 	fmt.Println("\nTo clean these targets, run: rosia clean")
 }
+
+// warnUnknownPlugins warns about the plugins of --exclude-plugin that are not
+// loaded, which are likely misspelled
+func warnUnknownPlugins(names []string) {
+	registry := GetGlobalPluginRegistry()
+	for _, name := range names {
+		if registry != nil {
+			if _, err := registry.Get(name); err == nil {
+				continue
+			}
+		}
+		logger.Warn("Unknown plugin in --exclude-plugin: %s", name)
+	}
+}
+
+// targetOrigin describes what found target: its profile, and the plugin
+// that reported it if any
+func targetOrigin(target types.Target) string {
+	switch target.Source {
+	case "":
+		return target.ProfileName
+	case target.ProfileName:
+		return target.ProfileName + " (plugin)"
+	default:
+		return target.ProfileName + " (" + target.Source + ")"
+	}
+}
@@ -1,88 +1,522 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/raucheacho/rosia-cli/internal/telemetry"
+	"github.com/raucheacho/rosia-cli/internal/ui"
 	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
 	"github.com/spf13/cobra"
 )
 
 var statsCmd = &cobra.Command{
 	Use:   "stats",
 	Short: "Display telemetry statistics",
-	Long: `Display statistics about scan and clean operations.
+	Long: `Display statistics about scan, clean, restore and purge operations.
 
 The stats command shows aggregated data from your rosia usage, including:
   • Total number of scans performed
   • Total disk space cleaned
+  • Number of items restored from and purged from the trash
   • Average size by target type (node_modules, target/, etc.)
   • Last scan timestamp
+  • Failure rates of cleans, restores and purges
 
-Statistics are stored locally in ~/.rosia/stats.json and are never
+Statistics are stored locally in stats.json of the data directory and are never
 transmitted unless you explicitly enable cloud telemetry.
 
+By default the statistics cover all time. --since, --from and --to restrict
+them to a period, computed from the recorded events, and show how it compares
+to all time. Times are dates (2025-04-28), RFC 3339 timestamps or ages (7d).
+
+--output json prints the statistics as JSON and --output csv prints their
+events, one per row, to chart the space cleaned over time in other tools.
+'rosia stats export' writes them to a file. --output prometheus prints the
+counters and gauges of all time in the Prometheus text format, to monitor
+machines with the textfile collector of the node exporter.
+
+Flags:
+      --since <time>   Only count events at or after this time, e.g. 30d
+      --from <time>    Same as --since
+      --to <time>      Only count events before this time; a date includes
+                       the whole day
+  -o, --output <fmt>   Output format: text, json, csv or prometheus
+
 Examples:
   # Display statistics
   rosia stats
 
+  # Show how much was cleaned this week
+  rosia stats --since 7d
+
+  # Show the statistics of April
+  rosia stats --from 2025-04-01 --to 2025-04-30
+
+  # Print the events of the last 30 days as CSV
+  rosia stats --since 30d -o csv
+
+  # Print the metrics for Prometheus
+  rosia stats -o prometheus
+
 Statistics Include:
   • Total Scans: Number of scan operations performed
   • Total Cleaned: Total disk space reclaimed across all clean operations
   • Average Sizes: Average size per target type (helps identify space hogs)
+  • Restores: Number of trash items restored
+  • Purged Items: Number of trash items purged
   • Last Scan: Timestamp of most recent scan operation
+  • Failure Rates: Share of the targets or items each operation failed for
 
 Privacy:
   • All statistics are stored locally by default
   • No data is transmitted without explicit opt-in
   • Enable cloud telemetry: rosia config set telemetry_enabled true
-  • Stats file location: ~/.rosia/stats.json`,
+  • Stats file location: stats.json of the data directory (~/.local/share/rosia on Linux)`,
 	RunE: runStats,
 }
 
+// statsExportCmd writes the statistics to a file
+var statsExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Export the statistics to JSON, CSV or Prometheus metrics",
+	Long: `Write the statistics to file, or to the standard output for "-", as JSON, as
+CSV or as Prometheus metrics. JSON holds the aggregates and every event; CSV
+holds the events, one per row with their time, type, profile and size, to
+chart the space cleaned over time in a spreadsheet or other tools.
+
+Prometheus metrics are the counters and gauges of all time, among which
+rosia_total_cleaned_bytes, rosia_trash_size_bytes and
+rosia_last_scan_timestamp. Written to a .prom file of the directory of the
+textfile collector of the node exporter, for instance from cron after each
+clean, they monitor the machines of a fleet. The file is replaced
+atomically, so the collector never reads it half written.
+
+The format is that of the file extension, .csv, .prom or .json, unless
+--format is given. --since, --from and --to restrict the export to a period,
+except for Prometheus metrics.
+
+Examples:
+  # Export every event for a spreadsheet
+  rosia stats export rosia-stats.csv
+
+  # Export the statistics of the last 30 days as JSON
+  rosia stats export --since 30d stats.json
+
+  # Pipe the events as CSV
+  rosia stats export --format csv - | head
+
+  # Update the metrics of the node exporter
+  rosia stats export /var/lib/node_exporter/textfile/rosia.prom`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStatsExport,
+}
+
+// statsImportCmd merges exported statistics into the local ones
+var statsImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Merge exported statistics into the local ones",
+	Long: `Merge the statistics exported as JSON by 'rosia stats export' into the local
+statistics, or those read from the standard input for "-", to aggregate the
+usage of several machines.
+
+Events are identified by their ID: those already in the local statistics,
+for instance from an earlier import of the same file, are left out. The
+totals and averages count the events merged, so the events pruned from the
+exported file are lost. Events older than those pruned from the local
+statistics are skipped, as rosia cannot tell whether it already counted
+them.
+
+Examples:
+  # On the laptop
+  rosia stats export laptop-stats.json
+
+  # On the workstation
+  rosia stats import laptop-stats.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStatsImport,
+}
+
+// statsTopCmd lists the largest targets cleaned
+var statsTopCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show the largest targets cleaned",
+	Long: `Show the largest targets cleaned, largest first, with the date they were
+cleaned, their size, profile and path, from the events of the statistics.
+
+Cleans recorded before rosia kept their targets show a single line per
+profile and clean, without a path. --since, --from and --to restrict the
+list to a period.
+
+Examples:
+  # Show the 10 largest targets cleaned
+  rosia stats top
+
+  # Show the 3 largest targets cleaned this month
+  rosia stats top --n 3 --since 30d`,
+	Args: cobra.NoArgs,
+	RunE: runStatsTop,
+}
+
+var (
+	statsTopN         int
+	statsSince        string
+	statsFrom         string
+	statsTo           string
+	statsOutput       string
+	statsExportFormat string
+)
+
 func init() {
 	rootCmd.AddCommand(statsCmd)
+	statsCmd.AddCommand(statsExportCmd)
+	statsCmd.AddCommand(statsTopCmd)
+	statsCmd.AddCommand(statsImportCmd)
+
+	statsCmd.PersistentFlags().StringVar(&statsSince, "since", "", "only count events at or after this time (e.g. 30d, 2025-04-01)")
+	statsCmd.PersistentFlags().StringVar(&statsFrom, "from", "", "same as --since")
+	statsCmd.PersistentFlags().StringVar(&statsTo, "to", "", "only count events before this time; a date includes the whole day")
+	statsCmd.MarkFlagsMutuallyExclusive("since", "from")
+	statsCmd.Flags().StringVarP(&statsOutput, "output", "o", "text", "output format: text, json, csv or prometheus")
+	statsTopCmd.Flags().IntVarP(&statsTopN, "n", "n", 10, "number of targets to show (0 = all)")
+	statsExportCmd.Flags().StringVar(&statsExportFormat, "format", "", "file format: json, csv or prometheus (default: from the file extension)")
 }
 
 func runStats(cmd *cobra.Command, args []string) error {
+	if statsOutput != "text" && statsOutput != "json" && statsOutput != "csv" && statsOutput != "prometheus" {
+		return fmt.Errorf("invalid output format %q: must be text, json, csv or prometheus", statsOutput)
+	}
+
+	store, stats, window, err := loadStats()
+	if err != nil {
+		return err
+	}
+
+	if statsOutput != "text" {
+		data, err := encodeStats(store, stats, window != nil, statsOutput)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	displayStats(stats, window)
+	return nil
+}
+
+func runStatsExport(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	format := statsExportFormat
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			format = "csv"
+		case ".prom":
+			format = "prometheus"
+		default:
+			format = "json"
+		}
+	}
+	if format != "json" && format != "csv" && format != "prometheus" {
+		return fmt.Errorf("invalid format %q: must be json, csv or prometheus", format)
+	}
+
+	store, stats, window, err := loadStats()
+	if err != nil {
+		return err
+	}
+	data, err := encodeStats(store, stats, window != nil, format)
+	if err != nil {
+		return err
+	}
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if format == "prometheus" {
+		// Replaced atomically for the textfile collector, which ignores
+		// files not ending in .prom such as the temporary one
+		tmpPath := path + ".tmp"
+		if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		if err := os.Rename(tmpPath, path); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Printf("✓ Exported the metrics to %s\n", path)
+		return nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Printf("✓ Exported %d event(s) to %s\n", len(stats.Events), path)
+	return nil
+}
+
+func runStatsImport(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	other, err := telemetry.ParseStats(data)
+	if err != nil {
+		return fmt.Errorf("%s is not a JSON export of the statistics: %w", path, err)
+	}
+
+	statsPath, err := telemetry.GetDefaultStatsPath()
+	if err != nil {
+		return fmt.Errorf("failed to get stats path: %w", err)
+	}
+	store, err := telemetry.NewFileStore(statsPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize telemetry store: %w", err)
+	}
+	result, err := store.Import(other)
+	if err != nil {
+		return fmt.Errorf("failed to import statistics: %w", err)
+	}
+
+	fmt.Printf("✓ Imported %d event(s) from %s\n", result.Added, path)
+	if result.Duplicates > 0 {
+		fmt.Printf("  %d event(s) already recorded\n", result.Duplicates)
+	}
+	if result.Skipped > 0 {
+		fmt.Printf("  %d event(s) skipped, older than the pruned local events\n", result.Skipped)
+	}
+	return nil
+}
+
+func runStatsTop(cmd *cobra.Command, args []string) error {
+	if statsTopN < 0 {
+		return fmt.Errorf("invalid --n %d: must not be negative", statsTopN)
+	}
+	_, stats, window, err := loadStats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("🏆 Largest Cleans")
+	fmt.Println("================")
+	fmt.Println()
+	if window != nil {
+		fmt.Printf("Period: %s\n\n", formatPeriod(window.from, window.to))
+	}
+
+	top := stats.TopCleans(statsTopN)
+	if len(top) == 0 {
+		fmt.Println("No cleans recorded.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "#\tDATE\tSIZE\tPROFILE\tPATH")
+	fmt.Fprintln(w, "-\t----\t----\t-------\t----")
+	for i, record := range top {
+		path := record.Path
+		if path == "" {
+			path = "(not recorded)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+			i+1,
+			record.Timestamp.Local().Format("2006-01-02 15:04"),
+			formatSize(record.Size),
+			record.Profile,
+			path,
+		)
+	}
+	return w.Flush()
+}
+
+// loadStats opens the statistics store and returns its statistics, restricted
+// to the period of --since, --from and --to along with the window describing
+// it when one is given
+func loadStats() (*telemetry.FileStore, *telemetry.Stats, *statsWindow, error) {
+	from, to, err := statsPeriod()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	// Get the stats file path
 	statsPath, err := telemetry.GetDefaultStatsPath()
 	if err != nil {
 		logger.Error("Failed to get stats path: %v", err)
-		return fmt.Errorf("failed to get stats path: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to get stats path: %w", err)
 	}
 
 	// Create telemetry store
 	store, err := telemetry.NewFileStore(statsPath)
 	if err != nil {
 		logger.Error("Failed to initialize telemetry store: %v", err)
-		return fmt.Errorf("failed to initialize telemetry store: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to initialize telemetry store: %w", err)
 	}
 
 	// Get statistics
 	stats, err := store.GetStats()
 	if err != nil {
 		logger.Error("Failed to get statistics: %v", err)
-		return fmt.Errorf("failed to get statistics: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to get statistics: %w", err)
 	}
 
-	// Display statistics
-	displayStats(stats)
+	// Restrict them to the period when one is given
+	if from.IsZero() && to.IsZero() {
+		return store, stats, nil, nil
+	}
+	if !stats.Complete(from) {
+		logger.Warn("Events up to %s were pruned from the statistics; the period only counts later ones", stats.PrunedBefore.Format("2006-01-02 15:04"))
+	}
+	return store, stats.Between(from, to), &statsWindow{from: from, to: to, lifetime: stats}, nil
+}
 
-	return nil
+// encodeStats returns stats as JSON, CSV or Prometheus metrics. The
+// statistics of all time are exported by the store as they are stored; those
+// of a period are computed. Metrics are counters, so they only cover all time.
+func encodeStats(store telemetry.TelemetryStore, stats *telemetry.Stats, windowed bool, format string) ([]byte, error) {
+	switch format {
+	case "prometheus":
+		if windowed {
+			return nil, fmt.Errorf("prometheus metrics cover all time: --since, --from and --to do not apply")
+		}
+		var b bytes.Buffer
+		if err := telemetry.WritePrometheus(&b, statsMetrics(stats)); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case "csv":
+		var b bytes.Buffer
+		if err := stats.WriteCSV(&b); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	default:
+		var data []byte
+		var err error
+		if windowed {
+			data, err = json.MarshalIndent(stats, "", "  ")
+		} else {
+			data, err = store.Export()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to export statistics: %w", err)
+		}
+		return append(data, '\n'), nil
+	}
+}
+
+// statsMetrics returns the metrics of stats along with the gauges of the
+// trash, which are left out with a warning when it cannot be read
+func statsMetrics(stats *telemetry.Stats) []telemetry.Metric {
+	metrics := stats.Metrics()
+
+	trashSystem, err := openTrash()
+	if err == nil {
+		var trashStats *types.TrashStats
+		if trashStats, err = trashSystem.Stats(); err == nil {
+			return append(metrics,
+				telemetry.Metric{
+					Name:    "rosia_trash_size_bytes",
+					Help:    "Bytes used by the trash directory.",
+					Type:    "gauge",
+					Samples: []telemetry.Sample{{Value: float64(trashStats.DiskUsage)}},
+				},
+				telemetry.Metric{
+					Name:    "rosia_trash_items",
+					Help:    "Number of items in the trash.",
+					Type:    "gauge",
+					Samples: []telemetry.Sample{{Value: float64(trashStats.Items)}},
+				},
+			)
+		}
+	}
+	logger.Warn("Failed to read the trash, its metrics are left out: %v", err)
+	return metrics
+}
+
+// statsWindow describes the period statistics are restricted to
+type statsWindow struct {
+	from, to time.Time        // Bounds of the period, zero when open
+	lifetime *telemetry.Stats // Statistics of all time, to compare with
+}
+
+// statsPeriod returns the period selected by --since, --from and --to, zero
+// on the sides left open
+func statsPeriod() (from, to time.Time, err error) {
+	now := time.Now()
+	for flag, value := range map[string]string{"since": statsSince, "from": statsFrom} {
+		if value == "" {
+			continue
+		}
+		if from, err = parseTime(value, now); err != nil {
+			return from, to, fmt.Errorf("invalid --%s: %w", flag, err)
+		}
+	}
+	if statsTo != "" {
+		if to, err = parseTime(statsTo, now); err != nil {
+			return from, to, fmt.Errorf("invalid --to: %w", err)
+		}
+		// A date ends the period at the end of the day
+		if _, dateErr := time.ParseInLocation("2006-01-02", strings.TrimSpace(statsTo), time.Local); dateErr == nil {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, fmt.Errorf("the period is empty: --to must be after --since or --from")
+	}
+	return from, to, nil
 }
 
-func displayStats(stats *telemetry.Stats) {
+// displayStats prints stats, restricted to window when it is not nil
+func displayStats(stats *telemetry.Stats, window *statsWindow) {
 	fmt.Println("📊 Rosia Statistics")
 	fmt.Println("==================")
 	fmt.Println()
 
+	if window != nil {
+		fmt.Printf("Period:             %s\n", formatPeriod(window.from, window.to))
+	}
+
 	// Total scans
-	fmt.Printf("Total Scans:        %d\n", stats.TotalScans)
+	if window != nil {
+		fmt.Printf("Total Scans:        %d (%d all time)\n", stats.TotalScans, window.lifetime.TotalScans)
+	} else {
+		fmt.Printf("Total Scans:        %d\n", stats.TotalScans)
+	}
 
 	// Total cleaned with human-readable format
-	fmt.Printf("Total Cleaned:      %s\n", formatSize(stats.TotalCleaned))
+	if window != nil {
+		fmt.Printf("Total Cleaned:      %s (%s all time)\n", formatSize(stats.TotalCleaned), formatSize(window.lifetime.TotalCleaned))
+	} else {
+		fmt.Printf("Total Cleaned:      %s\n", formatSize(stats.TotalCleaned))
+	}
+
+	// Restores and purges of the trash
+	if window != nil {
+		fmt.Printf("Restores:           %d (%d all time)\n", stats.TotalRestores, window.lifetime.TotalRestores)
+		fmt.Printf("Purged Items:       %d (%d all time)\n", stats.TotalPurges, window.lifetime.TotalPurges)
+	} else {
+		fmt.Printf("Restores:           %d\n", stats.TotalRestores)
+		fmt.Printf("Purged Items:       %d\n", stats.TotalPurges)
+	}
 
 	// Last scan timestamp
 	if !stats.LastScan.IsZero() {
@@ -91,6 +525,8 @@ func displayStats(stats *telemetry.Stats) {
 		fmt.Printf("Last Scan:          Never\n")
 	}
 
+	displayFailureRates(stats)
+
 	// Average sizes by type
 	if len(stats.AverageSizeByType) > 0 {
 		fmt.Println()
@@ -100,7 +536,131 @@ func displayStats(stats *telemetry.Stats) {
 		}
 	}
 
+	// Charts of the space cleaned, over time and by profile, and of the
+	// free space it left. The colors are those of the TUI theme; they are
+	// dropped when the output is not a terminal.
+	_ = ui.SetTheme(GetGlobalConfig().UI.Theme)
+	if stats.TotalCleaned > 0 {
+		displayStatsCharts(stats, window)
+	}
+	displayFreeSpace(stats)
+
+	fmt.Println()
+}
+
+// displayFailureRates shows the share of the items each operation failed
+// for, when any did
+func displayFailureRates(stats *telemetry.Stats) {
+	if len(stats.FailuresByOperation) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("Failure Rates:")
+	for _, operation := range []string{"clean", "restore", "purge"} {
+		if rate, ok := stats.FailureRate(operation); ok {
+			fmt.Printf("  %-20s %.1f%% (%d failed)\n", operation+":", rate*100, stats.FailuresByOperation[operation])
+		}
+	}
+}
+
+// statsTrendSamples is the number of free space measures charted at most,
+// the latest ones
+const statsTrendSamples = 40
+
+// displayFreeSpace charts the free space of the filesystems measured by the
+// scans and cleans of stats, with its change over the measures charted
+func displayFreeSpace(stats *telemetry.Stats) {
+	trends := stats.FreeSpaceTrends()
+	if len(trends) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Free Space:")
+	for _, trend := range trends {
+		samples := trend.Samples[max(0, len(trend.Samples)-statsTrendSamples):]
+		values := make([]int64, len(samples))
+		for i, sample := range samples {
+			values[i] = sample.Free
+		}
+		first, last := samples[0], samples[len(samples)-1]
+
+		change := "no change"
+		if diff := last.Free - first.Free; diff > 0 {
+			change = "+" + formatSize(diff)
+		} else if diff < 0 {
+			change = "-" + formatSize(-diff)
+		}
+		fmt.Printf("  %s\n    %s  %s free, %s since %s\n",
+			trend.Path,
+			ui.Trend(values),
+			formatSize(last.Free),
+			change,
+			first.Timestamp.Local().Format("2006-01-02"),
+		)
+	}
+}
+
+const (
+	statsChartWeeks    = 12 // Weeks charted when no period is given
+	statsChartMaxWeeks = 52 // Weeks charted at most for a period
+	statsBarWidth      = 30 // Width of the bars of the profiles chart
+)
+
+// displayStatsCharts charts the bytes cleaned per week, over the weeks of
+// window or the last weeks, and per profile
+func displayStatsCharts(stats *telemetry.Stats, window *statsWindow) {
+	end, weeks := time.Now(), statsChartWeeks
+	if window != nil {
+		if !window.to.IsZero() {
+			end = window.to
+		}
+		if !window.from.IsZero() {
+			weeks = int(math.Ceil(end.Sub(window.from).Hours() / (7 * 24)))
+			weeks = min(max(weeks, 1), statsChartMaxWeeks)
+		}
+	}
+	perWeek := stats.CleanedPerWeek(end, weeks)
+	if peak := slices.Max(perWeek); peak > 0 {
+		fmt.Println()
+		fmt.Printf("Cleaned per Week (%d weeks to %s):\n", weeks, end.Format("2006-01-02"))
+		fmt.Printf("  %s  peak %s/week\n", ui.Sparkline(perWeek), formatSize(peak))
+	}
+
+	// Profiles are charted from their totals, which include the events
+	// pruned from the log
+	bars := make([]ui.Bar, 0, len(stats.CleanedByType))
+	for profileName, total := range stats.CleanedByType {
+		bars = append(bars, ui.Bar{Label: profileName, Value: total, Text: formatSize(total)})
+	}
+	if len(bars) == 0 {
+		return
+	}
+	sort.Slice(bars, func(i, j int) bool {
+		if bars[i].Value != bars[j].Value {
+			return bars[i].Value > bars[j].Value
+		}
+		return bars[i].Label < bars[j].Label
+	})
 	fmt.Println()
+	fmt.Println("Cleaned by Profile:")
+	for _, line := range strings.Split(strings.TrimSuffix(ui.BarChart(bars, statsBarWidth), "\n"), "\n") {
+		fmt.Println("  " + line)
+	}
+}
+
+// formatPeriod describes the period from from to to, either of which may be
+// zero when open
+func formatPeriod(from, to time.Time) string {
+	const layout = "2006-01-02 15:04"
+	switch {
+	case to.IsZero():
+		return "since " + from.Format(layout)
+	case from.IsZero():
+		return "before " + to.Format(layout)
+	default:
+		return from.Format(layout) + " to " + to.Format(layout)
+	}
 }
 
 // formatTimestamp formats a timestamp in a human-readable way
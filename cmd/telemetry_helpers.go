@@ -10,12 +10,75 @@ func getTelemetryStatsPath() (string, error) {
 	return telemetry.GetDefaultStatsPath()
 }
 
-// initTelemetryStore initializes a telemetry store at the given path
+// telemetryStores are the telemetry stores opened by initTelemetryStore, by
+// path, which closeTelemetryStores flushes on exit
+var telemetryStores = map[string]*telemetry.BufferedStore{}
+
+// initTelemetryStore initializes a telemetry store at the given path. Events
+// are written in the background, so that recording them never slows down a
+// command; the store is shared by the whole command and flushed when it
+// exits.
 func initTelemetryStore(statsPath string) (telemetry.TelemetryStore, error) {
-	store, err := telemetry.NewFileStore(statsPath)
+	if store, ok := telemetryStores[statsPath]; ok {
+		return store, nil
+	}
+	fileStore, err := telemetry.NewFileStore(statsPath)
 	if err != nil {
 		logger.Warn("Failed to initialize telemetry store: %v", err)
 		return nil, err
 	}
+	store := telemetry.NewBufferedStore(fileStore)
+	telemetryStores[statsPath] = store
 	return store, nil
 }
+
+// closeTelemetryStores writes the events left in the telemetry stores
+func closeTelemetryStores() {
+	for statsPath, store := range telemetryStores {
+		if err := store.Close(); err != nil {
+			logger.Warn("Failed to record telemetry: %v", err)
+		}
+		delete(telemetryStores, statsPath)
+	}
+}
+
+// recordEvent records an event of data in the statistics, when telemetry
+// is enabled. Failures are only logged, as the statistics are never worth
+// failing a command for.
+func recordEvent(data telemetry.EventData) {
+	if !GetGlobalConfig().TelemetryEnabled {
+		return
+	}
+	statsPath, err := getTelemetryStatsPath()
+	if err != nil {
+		logger.Warn("Failed to get stats path: %v", err)
+		return
+	}
+	store, err := initTelemetryStore(statsPath)
+	if err != nil {
+		return
+	}
+	if err := store.Record(telemetry.NewEvent(data)); err != nil {
+		logger.Warn("Failed to record %s telemetry: %v", data.EventType(), err)
+	}
+}
+
+// recordOperation records the items an operation on the trash,
+// telemetry.EventRestore or telemetry.EventPurge, succeeded for, and those it
+// failed for as an error event with the first error
+func recordOperation(operation string, items int, size int64, failed int, firstErr error) {
+	if items > 0 {
+		if operation == telemetry.EventRestore {
+			recordEvent(&telemetry.RestoreEvent{Items: items, Size: size})
+		} else {
+			recordEvent(&telemetry.PurgeEvent{Items: items, Size: size})
+		}
+	}
+	if failed > 0 {
+		event := &telemetry.ErrorEvent{Operation: operation, Failed: failed}
+		if firstErr != nil {
+			event.Error = firstErr.Error()
+		}
+		recordEvent(event)
+	}
+}
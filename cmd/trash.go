@@ -0,0 +1,566 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/profiles"
+	"github.com/raucheacho/rosia-cli/internal/telemetry"
+	"github.com/raucheacho/rosia-cli/internal/trash"
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	purgeAll       bool
+	purgeOlderThan string
+	purgeYes       bool
+	exportAll      bool
+	exportOutput   string
+	fsckDryRun     bool
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage the trash",
+	Long: `Manage items that were moved to the trash by rosia clean.
+
+Trashed items are removed automatically once they are older than the
+retention period (trash_retention_days). Use these subcommands to manage
+the trash explicitly.
+
+Available Subcommands:
+  purge       Permanently delete items from the trash
+  stats       Show how much disk space the trash uses
+  pin         Keep items in the trash past the retention period
+  unpin       Let pinned items expire again
+  export      Write items to an archive
+  import      Add items from an archive to the trash
+  fsck        Find and fix broken trash entries
+
+Examples:
+  # Permanently delete a single item
+  rosia trash purge 20250428_143022_node_modules
+
+  # Empty the trash
+  rosia trash purge --all`,
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge [id...]",
+	Short: "Permanently delete items from the trash",
+	Long: `Permanently delete items from the trash right away, without waiting
+for the retention period.
+
+Select items by ID, every item with --all, or items trashed more than a
+given age ago with --older-than. Purged items cannot be restored.
+
+Pinned items are skipped by --all and --older-than and must be purged by ID.
+
+Flags:
+      --all                 Purge every item in the trash
+      --older-than <age>    Purge items trashed more than this long ago (e.g. 12h, 7d, 2w)
+  -y, --yes                 Skip the confirmation prompt
+
+Examples:
+  # Purge a single item
+  rosia trash purge 20250428_143022_node_modules
+
+  # Purge everything trashed more than a week ago
+  rosia trash purge --older-than 7d
+
+  # Empty the trash without asking
+  rosia trash purge --all --yes`,
+	RunE: runTrashPurge,
+}
+
+var trashStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show how much disk space the trash uses",
+	Long: `Display statistics about the items in the trash.
+
+Statistics Include:
+  • Items: Number of trashed items
+  • Total Size: Size of the items when they were trashed
+  • Disk Usage: Space the trash takes up now (smaller with trash_compression or trash_dedup)
+  • Oldest Item: The item that will be removed first by retention
+  • Size by Profile: Trashed items and size per profile
+
+Examples:
+  # Display trash statistics
+  rosia trash stats`,
+	Args: cobra.NoArgs,
+	RunE: runTrashStats,
+}
+
+var trashPinCmd = &cobra.Command{
+	Use:   "pin <id...>",
+	Short: "Keep items in the trash past the retention period",
+	Long: `Pin trash items so the retention cleanup never removes them.
+
+Pinned items stay in the trash until they are restored, unpinned or purged
+by ID. They are marked as pinned in 'rosia restore --list'.
+
+Examples:
+  # Keep an item indefinitely
+  rosia trash pin 20250428_143022_node_modules`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setTrashPinned(args, true)
+	},
+}
+
+var trashUnpinCmd = &cobra.Command{
+	Use:   "unpin <id...>",
+	Short: "Let pinned items expire again",
+	Long: `Unpin trash items so the retention cleanup removes them once they are
+older than the retention period.
+
+Examples:
+  # Let an item expire again
+  rosia trash unpin 20250428_143022_node_modules`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setTrashPinned(args, false)
+	},
+}
+
+var trashExportCmd = &cobra.Command{
+	Use:   "export [id...]",
+	Short: "Write items to an archive",
+	Long: `Write trash items, with their content and metadata, to a single
+tar.zst archive.
+
+The archive can be imported with 'rosia trash import' on another machine to
+migrate restorable state, or attached to a support ticket.
+
+Flags:
+      --all                 Export every item in the trash
+  -o, --output <file>       Archive to write (required)
+
+Examples:
+  # Export a single item
+  rosia trash export 20250428_143022_node_modules -o node_modules.tar.zst
+
+  # Export the whole trash
+  rosia trash export --all -o trash.tar.zst`,
+	RunE: runTrashExport,
+}
+
+var trashImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Add items from an archive to the trash",
+	Long: `Add the items of an archive written by 'rosia trash export' to the trash,
+where they can be restored as usual.
+
+Items keep their ID unless it is already taken, in which case a numeric
+suffix is added. Items restore to their original path, so use
+'rosia restore <id> --to <path>' when paths differ between machines.
+
+Examples:
+  # Import exported items
+  rosia trash import trash.tar.zst`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTrashImport,
+}
+
+var trashFsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Find and fix broken trash entries",
+	Long: `Check the trash directory for entries that cannot be listed or restored,
+for example after a crash or a full disk, and fix them.
+
+  • Metadata left in a temporary file is put back, and metadata that
+    disagrees with the stored content is corrected
+  • Items with unreadable metadata or missing content are quarantined in
+    <trash>/.quarantine, where they no longer show up but can be inspected
+  • Content without metadata, leftovers of interrupted operations and
+    unused deduplicated files are deleted to reclaim their space
+
+Flags:
+      --dry-run             Report the problems without fixing them
+
+Examples:
+  # See what is wrong first
+  rosia trash fsck --dry-run
+
+  # Fix it
+  rosia trash fsck`,
+	Args: cobra.NoArgs,
+	RunE: runTrashFsck,
+}
+
+func init() {
+	rootCmd.AddCommand(trashCmd)
+	trashCmd.AddCommand(trashPurgeCmd)
+	trashCmd.AddCommand(trashStatsCmd)
+	trashCmd.AddCommand(trashPinCmd)
+	trashCmd.AddCommand(trashUnpinCmd)
+	trashCmd.AddCommand(trashExportCmd)
+	trashCmd.AddCommand(trashImportCmd)
+	trashCmd.AddCommand(trashFsckCmd)
+
+	trashPurgeCmd.Flags().BoolVar(&purgeAll, "all", false, "purge every item in the trash")
+	trashPurgeCmd.Flags().StringVar(&purgeOlderThan, "older-than", "", "purge items trashed more than this long ago (e.g. 7d)")
+	trashPurgeCmd.Flags().BoolVarP(&purgeYes, "yes", "y", false, "skip the confirmation prompt")
+	trashPurgeCmd.MarkFlagsMutuallyExclusive("all", "older-than")
+
+	trashExportCmd.Flags().BoolVar(&exportAll, "all", false, "export every item in the trash")
+	trashExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "archive to write")
+	trashExportCmd.MarkFlagRequired("output")
+
+	trashFsckCmd.Flags().BoolVar(&fsckDryRun, "dry-run", false, "report problems without fixing them")
+}
+
+func runTrashPurge(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && !purgeAll && purgeOlderThan == "" {
+		return fmt.Errorf("specify trash IDs, --all or --older-than")
+	}
+	if len(args) > 0 && (purgeAll || purgeOlderThan != "") {
+		return fmt.Errorf("trash IDs cannot be combined with --all or --older-than")
+	}
+
+	trashSystem, err := openTrash()
+	if err != nil {
+		logger.Error("Failed to initialize trash system: %v", err)
+		return fmt.Errorf("failed to initialize trash system: %w", err)
+	}
+
+	items, err := selectPurgeItems(trashSystem, args)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Println("No trashed items to purge.")
+		return nil
+	}
+
+	var totalSize int64
+	for _, item := range items {
+		totalSize += item.Size
+	}
+
+	if !purgeYes {
+		fmt.Printf("%d item(s) (%s) will be permanently deleted. This cannot be undone.\n", len(items), formatSize(totalSize))
+		if !promptYesNo(os.Stdout, "Do you want to continue?") {
+			fmt.Println("Purge cancelled.")
+			return nil
+		}
+	}
+
+	logger.Info("Purging %d trash items", len(items))
+
+	var freed int64
+	var firstErr error
+	purged, errorCount := 0, 0
+	for _, item := range items {
+		if err := trashSystem.Purge(item.ID); err != nil {
+			fmt.Printf("✗ Failed to purge %s: %v\n", item.ID, err)
+			logger.Error("Failed to purge %s: %v", item.ID, err)
+			errorCount++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		logger.Debug("Purged %s", item.ID)
+		freed += item.Size
+		purged++
+	}
+	recordOperation(telemetry.EventPurge, purged, freed, errorCount, firstErr)
+
+	fmt.Printf("✓ Purged %d item(s), freed %s\n", purged, formatSize(freed))
+	if errorCount > 0 {
+		return fmt.Errorf("failed to purge %d item(s)", errorCount)
+	}
+	return nil
+}
+
+// selectPurgeItems returns the trash items selected by ids, --all or
+// --older-than
+func selectPurgeItems(trashSystem *trash.System, ids []string) ([]types.TrashItem, error) {
+	if len(ids) > 0 {
+		items := make([]types.TrashItem, 0, len(ids))
+		for _, id := range ids {
+			metadata, err := trashSystem.GetMetadata(id)
+			var notFound types.ErrPathNotFound
+			if errors.As(err, &notFound) {
+				return nil, fmt.Errorf("trash item not found: %s", id)
+			}
+			if err != nil {
+				// Items with broken metadata can still be purged
+				logger.Warn("Purging %s with unreadable metadata: %v", id, err)
+				items = append(items, types.TrashItem{ID: id})
+				continue
+			}
+			items = append(items, types.TrashItem{
+				ID:           id,
+				OriginalPath: metadata.OriginalPath,
+				Size:         metadata.Size,
+				DeletedAt:    metadata.DeletedAt,
+			})
+		}
+		return items, nil
+	}
+
+	items, err := trashSystem.List()
+	if err != nil {
+		logger.Error("Failed to list trashed items: %v", err)
+		return nil, fmt.Errorf("failed to list trashed items: %w", err)
+	}
+
+	cutoff := time.Now()
+	if !purgeAll {
+		age, err := profiles.ParseAge(purgeOlderThan)
+		if err != nil {
+			return nil, err
+		}
+		cutoff = cutoff.Add(-age)
+	}
+
+	var selected []types.TrashItem
+	pinned := 0
+	for _, item := range items {
+		if item.Pinned {
+			pinned++
+			continue
+		}
+		if purgeAll || item.DeletedAt.Before(cutoff) {
+			selected = append(selected, item)
+		}
+	}
+	if pinned > 0 {
+		fmt.Printf("Skipping %d pinned item(s); purge them by ID.\n", pinned)
+	}
+	return selected, nil
+}
+
+// setTrashPinned pins or unpins the trash items ids
+func setTrashPinned(ids []string, pinned bool) error {
+	trashSystem, err := openTrash()
+	if err != nil {
+		logger.Error("Failed to initialize trash system: %v", err)
+		return fmt.Errorf("failed to initialize trash system: %w", err)
+	}
+
+	action := "Pinned"
+	if !pinned {
+		action = "Unpinned"
+	}
+
+	for _, id := range ids {
+		if err := trashSystem.SetPinned(id, pinned); err != nil {
+			logger.Error("Failed to update %s: %v", id, err)
+			return fmt.Errorf("failed to update trash item %s: %w", id, err)
+		}
+		fmt.Printf("✓ %s %s\n", action, id)
+		logger.Debug("%s %s", action, id)
+	}
+	return nil
+}
+
+func runTrashStats(cmd *cobra.Command, args []string) error {
+	trashSystem, err := openTrash()
+	if err != nil {
+		logger.Error("Failed to initialize trash system: %v", err)
+		return fmt.Errorf("failed to initialize trash system: %w", err)
+	}
+
+	stats, err := trashSystem.Stats()
+	if err != nil {
+		logger.Error("Failed to get trash statistics: %v", err)
+		return fmt.Errorf("failed to get trash statistics: %w", err)
+	}
+
+	displayTrashStats(stats, trashSystem.GetTrashDir(), trashSystem.MaxSize())
+	return nil
+}
+
+func displayTrashStats(stats *types.TrashStats, trashDir string, maxSize int64) {
+	fmt.Println("🗑  Trash Statistics")
+	fmt.Println("===================")
+	fmt.Println()
+
+	fmt.Printf("Trash Directory:    %s\n", trashDir)
+	fmt.Printf("Items:              %d\n", stats.Items)
+	fmt.Printf("Total Size:         %s\n", formatSize(stats.TotalSize))
+	fmt.Printf("Disk Usage:         %s\n", formatSize(stats.DiskUsage))
+	if maxSize > 0 {
+		fmt.Printf("Size Limit:         %s (%.0f%% used)\n", formatSize(maxSize), float64(stats.DiskUsage)/float64(maxSize)*100)
+	}
+
+	if stats.Oldest != nil {
+		fmt.Printf("Oldest Item:        %s (%s)\n", stats.Oldest.ID, formatTimestamp(stats.Oldest.DeletedAt))
+	} else {
+		fmt.Printf("Oldest Item:        None\n")
+	}
+
+	if len(stats.Profiles) > 0 {
+		// Largest profiles first
+		names := make([]string, 0, len(stats.Profiles))
+		for name := range stats.Profiles {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			a, b := stats.Profiles[names[i]], stats.Profiles[names[j]]
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+			return names[i] < names[j]
+		})
+
+		fmt.Println()
+		fmt.Println("Size by Profile:")
+		for _, name := range names {
+			profile := stats.Profiles[name]
+			fmt.Printf("  %-20s %s (%d item(s))\n", name+":", formatSize(profile.Size), profile.Items)
+		}
+	}
+
+	fmt.Println()
+}
+
+func runTrashExport(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 && !exportAll {
+		return fmt.Errorf("specify trash IDs or --all")
+	}
+	if len(args) > 0 && exportAll {
+		return fmt.Errorf("trash IDs cannot be combined with --all")
+	}
+
+	trashSystem, err := openTrash()
+	if err != nil {
+		logger.Error("Failed to initialize trash system: %v", err)
+		return fmt.Errorf("failed to initialize trash system: %w", err)
+	}
+
+	ids := args
+	if exportAll {
+		items, err := trashSystem.List()
+		if err != nil {
+			logger.Error("Failed to list trashed items: %v", err)
+			return fmt.Errorf("failed to list trashed items: %w", err)
+		}
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if len(ids) == 0 {
+			fmt.Println("No trashed items to export.")
+			return nil
+		}
+	}
+
+	f, err := os.OpenFile(exportOutput, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to write export file: %w", closeErr)
+		}
+		if err != nil {
+			os.Remove(exportOutput)
+		}
+	}()
+
+	logger.Info("Exporting %d trash items to %s", len(ids), exportOutput)
+	if err := trashSystem.Export(ids, f); err != nil {
+		logger.Error("Failed to export trash items: %v", err)
+		return fmt.Errorf("failed to export trash items: %w", err)
+	}
+
+	fmt.Printf("✓ Exported %d item(s) to %s\n", len(ids), exportOutput)
+	return nil
+}
+
+func runTrashImport(cmd *cobra.Command, args []string) error {
+	trashSystem, err := openTrash()
+	if err != nil {
+		logger.Error("Failed to initialize trash system: %v", err)
+		return fmt.Errorf("failed to initialize trash system: %w", err)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open export file: %w", err)
+	}
+	defer f.Close()
+
+	ids, err := trashSystem.Import(f)
+	for _, id := range ids {
+		fmt.Printf("✓ Imported %s\n", id)
+	}
+	if err != nil {
+		logger.Error("Failed to import trash items: %v", err)
+		return fmt.Errorf("failed to import trash items: %w", err)
+	}
+
+	fmt.Printf("\nImported %d item(s). To restore an item, use: rosia restore <trash-id>\n", len(ids))
+	return nil
+}
+
+func runTrashFsck(cmd *cobra.Command, args []string) error {
+	trashSystem, err := openTrash()
+	if err != nil {
+		logger.Error("Failed to initialize trash system: %v", err)
+		return fmt.Errorf("failed to initialize trash system: %w", err)
+	}
+
+	report, err := trashSystem.Fsck(fsckDryRun)
+	if err != nil {
+		logger.Error("Failed to check the trash: %v", err)
+		return fmt.Errorf("failed to check the trash: %w", err)
+	}
+
+	if len(report.Problems) == 0 {
+		fmt.Println("✓ No problems found in the trash.")
+		return nil
+	}
+
+	if fsckDryRun {
+		fmt.Println("Dry run: nothing will be changed.")
+		fmt.Println()
+	}
+
+	trashDir := trashSystem.GetTrashDir()
+	errorCount := 0
+	for _, problem := range report.Problems {
+		path := problem.Path
+		if rel, err := filepath.Rel(trashDir, path); err == nil {
+			path = rel
+		}
+
+		action := problem.Action.String()
+		if fsckDryRun {
+			action = "would be " + action
+		}
+		if problem.Action == trash.FsckReclaimed && problem.Size > 0 {
+			action += fmt.Sprintf(" (%s)", formatSize(problem.Size))
+		}
+
+		if problem.Err != nil {
+			fmt.Printf("✗ %s: %s: failed: %v\n", path, problem.Issue, problem.Err)
+			logger.Error("Failed to fix %s: %v", problem.Path, problem.Err)
+			errorCount++
+			continue
+		}
+		fmt.Printf("• %s: %s, %s\n", path, problem.Issue, action)
+	}
+
+	fmt.Println()
+	verb := "Reclaimed"
+	if fsckDryRun {
+		verb = "Would reclaim"
+	}
+	fmt.Printf("Found %d problem(s). %s %s.\n", len(report.Problems), verb, formatSize(report.Reclaimed))
+
+	if errorCount > 0 {
+		return fmt.Errorf("failed to fix %d problem(s)", errorCount)
+	}
+	return nil
+}
@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/raucheacho/rosia-cli/internal/cleaner"
 	"github.com/raucheacho/rosia-cli/internal/profiles"
 	"github.com/raucheacho/rosia-cli/internal/scanner"
-	"github.com/raucheacho/rosia-cli/internal/trash"
 	"github.com/raucheacho/rosia-cli/internal/ui"
 	"github.com/raucheacho/rosia-cli/pkg/logger"
 	"github.com/spf13/cobra"
@@ -31,19 +31,26 @@ Features:
   • Real-time scan progress
   • Confirmation dialog before cleaning
   • Post-clean summary
+  • Profiles and configuration edited while it runs are reloaded, and a
+    new scan is started with them
 
 Keyboard Controls:
   ↑/↓         Navigate up/down
   Space       Toggle selection
   a           Select all targets
   n           Deselect all targets
+  r           Scan again
   Enter       Confirm and clean selected
   q           Quit without cleaning
 
 Examples:
-  # Launch TUI for current directory
+  # Launch TUI for the scan_paths of the configuration, or the current
+  # directory when there are none
   rosia ui
 
+  # Use colors readable on a light terminal, scanning hidden directories
+  rosia ui --theme light --include-hidden
+
   # Launch TUI for specific directory
   rosia ui ~/projects
 
@@ -58,15 +65,47 @@ Tips:
 	RunE: runUI,
 }
 
+var (
+	uiDepth         int
+	uiIncludeHidden bool
+	uiTheme         string
+)
+
 func init() {
 	rootCmd.AddCommand(uiCmd)
+
+	uiCmd.Flags().IntVarP(&uiDepth, "depth", "d", 10, "maximum depth to scan (0 = unlimited)")
+	uiCmd.Flags().BoolVarP(&uiIncludeHidden, "include-hidden", "H", false, "include hidden files and directories")
+	uiCmd.Flags().StringVar(&uiTheme, "theme", "default", "colors of the interface: "+strings.Join(ui.Themes(), ", "))
 }
 
 func runUI(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
-	// Determine scan paths
+	// The ui section of the configuration sets the flags not given
+	defaults := GetGlobalConfig().UI
+	if !cmd.Flags().Changed("depth") && defaults.Depth > 0 {
+		uiDepth = defaults.Depth
+	}
+	if !cmd.Flags().Changed("include-hidden") && defaults.IncludeHidden {
+		uiIncludeHidden = true
+	}
+	if !cmd.Flags().Changed("theme") && defaults.Theme != "" {
+		uiTheme = defaults.Theme
+	}
+	if err := ui.SetTheme(uiTheme); err != nil {
+		return err
+	}
+
+	// Determine scan paths: the paths given, the scan_paths of the
+	// configuration, or the current directory
 	scanPaths := args
+	if len(scanPaths) == 0 && len(GetGlobalConfig().ScanPaths) > 0 {
+		var err error
+		if scanPaths, err = pathsOrScanPaths(nil, GetGlobalConfig()); err != nil {
+			return err
+		}
+	}
 	if len(scanPaths) == 0 {
 		cwd, err := os.Getwd()
 		if err != nil {
@@ -86,28 +125,66 @@ func runUI(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Load profiles
-	profileLoader := profiles.NewLoader()
-	_, err := profileLoader.LoadAll("profiles")
-	if err != nil {
-		return fmt.Errorf("failed to load profiles: %w", err)
+	// Use the global profile loader, with the profiles enabled and disabled
+	// in the configuration
+	profileLoader := GetGlobalProfileLoader()
+	if profileLoader == nil {
+		return fmt.Errorf("profile loader not initialized")
 	}
 
 	// Initialize scanner
 	scannerInstance := scanner.NewScanner(profileLoader)
+	if registry := GetGlobalPluginRegistry(); registry != nil {
+		scannerInstance.SetPluginRegistry(registry)
+	}
 
 	// Initialize trash system
-	trashSystem, err := trash.NewDefaultSystem()
+	trashSystem, err := openTrash()
 	if err != nil {
 		return fmt.Errorf("failed to initialize trash system: %w", err)
 	}
 
 	// Initialize cleaner
 	cleanerInstance := cleaner.New(trashSystem)
+	if registry := GetGlobalPluginRegistry(); registry != nil {
+		cleanerInstance.SetPluginRegistry(registry)
+	}
 
 	// Run TUI
 	logger.Debug("Starting TUI for paths: %v", scanPaths)
-	if err := ui.Run(ctx, scannerInstance, cleanerInstance, scanPaths); err != nil {
+	// Profiles edited while the TUI runs are reloaded, and a new scan is
+	// started with them
+	watch := &ui.ProfileWatch{
+		Watcher: profiles.NewWatcher(profileDirectories()...),
+		Reload:  func() error { return loadProfiles(profileLoader) },
+	}
+	options := func() ui.Options {
+		cfg := GetGlobalConfig()
+		return ui.Options{
+			MaxDepth:       uiDepth,
+			IncludeHidden:  uiIncludeHidden,
+			IgnorePaths:    cfg.IgnorePaths,
+			ProtectedPaths: protectedPaths(cfg),
+		}
+	}
+	// So is the configuration, whose profiles list changes the profiles
+	// used. Options set by flags and the ui section keep their value.
+	var configWatch *ui.ConfigWatch
+	if globalConfigManager != nil {
+		configWatch = &ui.ConfigWatch{
+			Watcher: globalConfigManager.NewWatcher(),
+			Reload: func() (ui.Options, error) {
+				if err := reloadConfig(); err != nil {
+					return ui.Options{}, err
+				}
+				if err := loadProfiles(profileLoader); err != nil {
+					return ui.Options{}, err
+				}
+				return options(), nil
+			},
+		}
+	}
+	if err := ui.Run(ctx, scannerInstance, cleanerInstance, scanPaths, options(), watch, configWatch); err != nil {
 		return fmt.Errorf("TUI error: %w", err)
 	}
 
@@ -1,6 +1,21 @@
 package cmd
 
-import "fmt"
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/config"
+	"github.com/raucheacho/rosia-cli/internal/profiles"
+	"github.com/raucheacho/rosia-cli/internal/sizecalc"
+	"github.com/raucheacho/rosia-cli/internal/trash"
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
 
 // formatSize converts bytes to human-readable format (KB, MB, GB, TB)
 func formatSize(bytes int64) string {
@@ -24,3 +39,111 @@ func formatSize(bytes int64) string {
 		return fmt.Sprintf("%d B", bytes)
 	}
 }
+
+// pathsOrScanPaths returns args, the paths given to a command, or the
+// scan_paths of cfg when there are none. Scan paths that do not exist on
+// this machine are skipped with a warning.
+func pathsOrScanPaths(args []string, cfg *config.Config) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	if len(cfg.ScanPaths) == 0 {
+		return nil, fmt.Errorf("no path given: pass the paths to scan, or set default ones with 'rosia config set scan_paths <paths>'")
+	}
+
+	paths := make([]string, 0, len(cfg.ScanPaths))
+	for _, path := range cfg.ScanPaths {
+		if _, err := os.Stat(path); err != nil {
+			logger.Warn("Skipping %s of scan_paths: %v", path, err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("none of the scan_paths of the configuration exist")
+	}
+	logger.Info("Using scan_paths of the configuration: %s", strings.Join(paths, ", "))
+	return paths, nil
+}
+
+// protectedPaths returns the protected_paths of cfg and the trash
+// directory, which scans and cleans never touch in addition to the
+// built-in protected paths
+func protectedPaths(cfg *config.Config) []string {
+	paths := slices.Clone(cfg.ProtectedPaths)
+	trashDir := cfg.TrashDir
+	if trashDir == "" {
+		trashDir, _ = trash.DefaultDir()
+	}
+	return append(paths, trashDir)
+}
+
+// promptYesNo writes question to w, reads the answer from stdin and reports
+// whether it was yes
+func promptYesNo(w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N]: ", question)
+
+	reader := bufio.NewReader(os.Stdin)
+	response, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
+
+// validateCategories checks that every value of the flag named flag is a
+// target category
+func validateCategories(flag string, categories []string) error {
+	for _, category := range categories {
+		if !slices.Contains(types.Categories, category) {
+			return fmt.Errorf("invalid --%s %q: must be one of %s", flag, category, strings.Join(types.Categories, ", "))
+		}
+	}
+	return nil
+}
+
+// parseTime parses a point in time given as a date ("2025-04-28", local
+// time), an RFC 3339 timestamp or an age relative to now ("7d")
+func parseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if age, err := profiles.ParseAge(s); err == nil {
+		return now.Add(-age), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use a date (2025-04-28), an RFC 3339 timestamp or an age (7d)", s)
+}
+
+// openTrash returns the trash system at the configured location, with the
+// configured compression and deduplication
+func openTrash() (*trash.System, error) {
+	cfg := GetGlobalConfig()
+	trashSystem, err := newTrashSystem(cfg.TrashDir)
+	if err != nil {
+		return nil, err
+	}
+	trashSystem.SetCompression(cfg.TrashCompression)
+	trashSystem.SetDeduplication(cfg.TrashDedup)
+	trashSystem.SetChecksums(cfg.TrashChecksums)
+	if cfg.TrashMaxSize != "" {
+		// Validated with the configuration
+		maxSize, _ := sizecalc.ParseSize(cfg.TrashMaxSize)
+		trashSystem.SetMaxSize(maxSize)
+	}
+	return trashSystem, nil
+}
+
+// newTrashSystem returns the trash system in dir, or at the default location
+// when dir is empty
+func newTrashSystem(dir string) (*trash.System, error) {
+	if dir == "" {
+		return trash.NewDefaultSystem()
+	}
+	return trash.NewSystem(dir)
+}
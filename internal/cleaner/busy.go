@@ -0,0 +1,60 @@
+package cleaner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// process is a running process and the paths it uses
+type process struct {
+	pid   int
+	name  string
+	paths []string // Working directory and open files
+}
+
+// processSnapshot lists the processes running when a clean started. A
+// target is skipped while one of them has its working directory or an open
+// file inside it, since deleting e.g. target/ mid-compile corrupts the build.
+type processSnapshot struct {
+	processes []process
+}
+
+// snapshotProcesses lists the processes other than rosia itself. Failures to
+// inspect processes are not errors: detection is best effort.
+func snapshotProcesses() *processSnapshot {
+	return &processSnapshot{processes: listProcesses(os.Getpid())}
+}
+
+// checkNotBusy returns types.ErrTargetInUse when a process of the snapshot
+// is using path. A nil snapshot finds nothing.
+func (s *processSnapshot) checkNotBusy(path string) error {
+	if s == nil {
+		return nil
+	}
+	root := cleanRoot(path)
+	for _, proc := range s.processes {
+		for _, p := range proc.paths {
+			if isWithin(root, p) {
+				return types.ErrTargetInUse{Path: path, Process: proc.name, PID: proc.pid}
+			}
+		}
+	}
+	return nil
+}
+
+// isWithin reports whether p is root or lies inside it
+func isWithin(root, p string) bool {
+	return p == root || strings.HasPrefix(p, root+string(os.PathSeparator))
+}
+
+// cleanRoot resolves symlinks in path so it compares equal to the paths the
+// operating system reports for processes
+func cleanRoot(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
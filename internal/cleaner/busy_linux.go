@@ -0,0 +1,51 @@
+//go:build linux
+
+package cleaner
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// listProcesses reads the working directory and open files of every process
+// of /proc but self. Open files are only readable for the processes of the
+// same user, or all of them as root.
+func listProcesses(self int) []process {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var processes []process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		procDir := filepath.Join("/proc", entry.Name())
+
+		comm, err := os.ReadFile(filepath.Join(procDir, "comm"))
+		if err != nil {
+			continue
+		}
+		proc := process{pid: pid, name: strings.TrimSpace(string(comm))}
+
+		if cwd, err := os.Readlink(filepath.Join(procDir, "cwd")); err == nil {
+			proc.paths = append(proc.paths, cwd)
+		}
+		fds, _ := os.ReadDir(filepath.Join(procDir, "fd"))
+		for _, fd := range fds {
+			// Pipes and sockets read as "pipe:[...]", never an absolute path
+			if target, err := os.Readlink(filepath.Join(procDir, "fd", fd.Name())); err == nil && filepath.IsAbs(target) {
+				proc.paths = append(proc.paths, target)
+			}
+		}
+		if len(proc.paths) > 0 {
+			processes = append(processes, proc)
+		}
+	}
+
+	return processes
+}
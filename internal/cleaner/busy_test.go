@@ -0,0 +1,95 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/internal/trash"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsWithin(t *testing.T) {
+	root := filepath.Join("/tmp", "project", "target")
+
+	assert.True(t, isWithin(root, root))
+	assert.True(t, isWithin(root, filepath.Join(root, "debug", "build")))
+	assert.False(t, isWithin(root, root+"-other"))
+	assert.False(t, isWithin(root, filepath.Dir(root)))
+}
+
+// startInDir starts a process working in dir for the duration of a test,
+// like a build running in its project
+func startInDir(t *testing.T, dir string) *exec.Cmd {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("process detection is only supported on Linux")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not available")
+	}
+
+	cmd := exec.Command(sleep, "60")
+	cmd.Dir = dir
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd
+}
+
+func TestProcessSnapshot_CheckNotBusy(t *testing.T) {
+	targetDir := filepath.Join(t.TempDir(), "target")
+	require.NoError(t, os.MkdirAll(targetDir, 0755))
+
+	// Rosia's own open files never make a target busy
+	f, err := os.Create(filepath.Join(targetDir, "build.lock"))
+	require.NoError(t, err)
+	defer f.Close()
+	before := snapshotProcesses()
+	require.NoError(t, before.checkNotBusy(targetDir))
+
+	// Any other process working inside the target does
+	cmd := startInDir(t, targetDir)
+	err = snapshotProcesses().checkNotBusy(targetDir)
+	var inUse types.ErrTargetInUse
+	require.True(t, errors.As(err, &inUse), "expected ErrTargetInUse, got %v", err)
+	assert.Equal(t, cmd.Process.Pid, inUse.PID)
+	assert.Equal(t, "sleep", inUse.Process)
+	assert.Equal(t, targetDir, inUse.Path)
+
+	// A snapshot only holds the processes running when it was taken
+	assert.NoError(t, before.checkNotBusy(targetDir))
+	var none *processSnapshot
+	assert.NoError(t, none.checkNotBusy(targetDir))
+}
+
+func TestCleaner_Clean_SkipsBusyTarget(t *testing.T) {
+	tmpDir := t.TempDir()
+	targetDir := filepath.Join(tmpDir, "target")
+	require.NoError(t, os.MkdirAll(targetDir, 0755))
+	startInDir(t, targetDir)
+
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+	c := New(trashSystem)
+	targets := []types.Target{{Path: targetDir, IsDirectory: true}}
+
+	report, err := c.Clean(context.Background(), targets, CleanOptions{})
+	require.NoError(t, err)
+	require.Len(t, report.Errors, 1)
+	assert.DirExists(t, targetDir)
+
+	report, err = c.Clean(context.Background(), targets, CleanOptions{IgnoreRunning: true})
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	assert.NoDirExists(t, targetDir)
+}
@@ -0,0 +1,141 @@
+package cleaner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// Checkpoint records the progress of a clean operation on disk so an
+// interrupted run can be resumed without rescanning or re-confirming.
+//
+// The checkpoint is rewritten after every completed target. Writes are
+// serialized, so a Checkpoint can be shared by concurrent workers.
+type Checkpoint struct {
+	CreatedAt time.Time       `json:"created_at"` // When the clean operation started
+	UseTrash  bool            `json:"use_trash"`  // Whether targets are moved to trash
+	Targets   []types.Target  `json:"targets"`    // All targets of the operation
+	Completed map[string]bool `json:"completed"`  // Paths of targets already cleaned
+
+	path string
+	mu   sync.Mutex
+}
+
+// GetDefaultCheckpointPath returns the default path for the clean checkpoint file
+func GetDefaultCheckpointPath() (string, error) {
+	// Keep the checkpoint next to the trash and stats
+	location, err := fsutils.CheckpointLocation()
+	if err != nil {
+		return "", err
+	}
+	return location.Path(), nil
+}
+
+// NewCheckpoint creates a checkpoint for targets and writes it to path
+func NewCheckpoint(path string, targets []types.Target, useTrash bool) (*Checkpoint, error) {
+	cp := &Checkpoint{
+		CreatedAt: time.Now(),
+		UseTrash:  useTrash,
+		Targets:   targets,
+		Completed: make(map[string]bool),
+		path:      path,
+	}
+
+	if err := cp.save(); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// LoadCheckpoint reads a checkpoint from path.
+// It returns types.ErrPathNotFound when there is nothing to resume.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, types.ErrPathNotFound{Path: path}
+		}
+		return nil, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if cp.Completed == nil {
+		cp.Completed = make(map[string]bool)
+	}
+	cp.path = path
+
+	return &cp, nil
+}
+
+// Pending returns the targets that have not been cleaned yet, in their original order
+func (cp *Checkpoint) Pending() []types.Target {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	pending := make([]types.Target, 0, len(cp.Targets))
+	for _, target := range cp.Targets {
+		if !cp.Completed[target.Path] {
+			pending = append(pending, target)
+		}
+	}
+	return pending
+}
+
+// MarkDone records target as cleaned and persists the checkpoint
+func (cp *Checkpoint) MarkDone(target types.Target) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.Completed[target.Path] = true
+	return cp.saveLocked()
+}
+
+// Remove deletes the checkpoint file once the operation has finished
+func (cp *Checkpoint) Remove() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove checkpoint %s: %w", cp.path, err)
+	}
+	return nil
+}
+
+// save persists the checkpoint
+func (cp *Checkpoint) save() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.saveLocked()
+}
+
+// saveLocked writes the checkpoint atomically; cp.mu must be held
+func (cp *Checkpoint) saveLocked() error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cp.path), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	// Write to a temp file first so an interruption never leaves a torn checkpoint
+	tmpPath := cp.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, cp.path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return nil
+}
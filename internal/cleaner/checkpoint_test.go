@@ -0,0 +1,64 @@
+package cleaner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/internal/trash"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "checkpoint.json")
+	targets := []types.Target{
+		{Path: "/a", Size: 1},
+		{Path: "/b", Size: 2},
+		{Path: "/c", Size: 3},
+	}
+
+	cp, err := NewCheckpoint(path, targets, true)
+	require.NoError(t, err)
+	require.NoError(t, cp.MarkDone(targets[1]))
+
+	loaded, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	assert.True(t, loaded.UseTrash)
+	assert.Len(t, loaded.Targets, 3)
+	assert.Equal(t, []types.Target{targets[0], targets[2]}, loaded.Pending())
+
+	require.NoError(t, loaded.Remove())
+	_, err = LoadCheckpoint(path)
+	assert.IsType(t, types.ErrPathNotFound{}, err)
+
+	// Removing twice is not an error
+	assert.NoError(t, loaded.Remove())
+}
+
+func TestCleaner_Clean_UpdatesCheckpoint(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	doneDir := filepath.Join(tmpDir, "done")
+	require.NoError(t, os.MkdirAll(doneDir, 0755))
+
+	targets := []types.Target{
+		{Path: doneDir, ProfileName: "test", IsDirectory: true},
+		{Path: filepath.Join(tmpDir, "missing"), ProfileName: "test", IsDirectory: true},
+	}
+
+	cp, err := NewCheckpoint(filepath.Join(tmpDir, "checkpoint.json"), targets, false)
+	require.NoError(t, err)
+
+	_, err = New(trashSystem).Clean(context.Background(), targets, CleanOptions{Checkpoint: cp})
+	require.NoError(t, err)
+
+	// Only the failed target is left to resume
+	loaded, err := LoadCheckpoint(filepath.Join(tmpDir, "checkpoint.json"))
+	require.NoError(t, err)
+	assert.Equal(t, []types.Target{targets[1]}, loaded.Pending())
+}
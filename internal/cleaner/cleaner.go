@@ -19,8 +19,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync/atomic"
 	"time"
 
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
 	"github.com/raucheacho/rosia-cli/internal/plugins"
 	"github.com/raucheacho/rosia-cli/internal/telemetry"
 	"github.com/raucheacho/rosia-cli/internal/trash"
@@ -34,30 +37,68 @@ import (
 // for potential restoration, and processes deletions concurrently with error
 // isolation to ensure one failure doesn't stop the entire operation.
 type Cleaner struct {
-	trashSystem    *trash.System            // Manages trash operations
+	trashSystem    trash.Trasher            // Manages trash operations
 	telemetryStore telemetry.TelemetryStore // Records cleaning statistics
 	pluginRegistry plugins.PluginRegistry   // Manages loaded plugins
 }
 
 // CleanOptions configures the cleaning operation.
 //
-// Options control confirmation prompts, trash system usage, and concurrency settings.
+// Options control confirmation prompts, trash system usage, concurrency settings,
+// retries of failed deletions, and the hooks run around each target and batch.
 type CleanOptions struct {
-	SkipConfirmation bool
-	UseTrash         bool
-	Concurrency      int
+	SkipConfirmation  bool
+	UseTrash          bool
+	Concurrency       int
+	Hooks             Hooks         // Callbacks run before/after targets and batches
+	Retry             RetryPolicy   // Retries for transient delete failures
+	Atomic            bool          // Restore already trashed targets if any target fails
+	KeepPatterns      []string      // Paths inside every target to preserve, in addition to Target.Keep
+	Checkpoint        *Checkpoint   // Records completed targets so the run can be resumed (optional)
+	DeleteWorkers     int           // Goroutines deleting a single directory without trash (0 = auto)
+	PermanentPatterns []string      // Target names deleted directly even with UseTrash, like Target.Permanent
+	IgnoreRunning     bool          // Clean targets even while a process, such as a build, is using them
+	Deleter           Deleter       // Removes every target, overriding UseTrash and PermanentPatterns (optional)
+	TrashRetention    time.Duration // Keep trashed targets this long instead of the configured period (0 = default)
+	PluginTimeout     time.Duration // How long each plugin may take to clean (0 = plugins.DefaultTimeout)
+	HookTimeout       time.Duration // How long each pre- and post-clean hook may run (0 = DefaultHookTimeout)
+	ProtectedPaths    []string      // Paths never cleaned, in addition to fsutils.NewProtected's and the trash directory
+
+	// Elevate is called once the targets are processed, with the number of
+	// them that failed with a permission error. The Deleter it returns, if
+	// any, retries them, see RetryElevated. (optional)
+	Elevate func(failed int) Deleter
+
+	elevated  bool              // Retrying with elevated permissions, see RetryElevated
+	processes *processSnapshot  // Processes running when the clean started, unless IgnoreRunning
+	deleted   func(bytes int64) // Called with the bytes of each file deleted without trash, see CleanAsync
 }
 
-// CleanProgress reports progress during async cleaning
+// CleanProgress reports progress during async cleaning.
+//
+// Byte counts are based on the target sizes captured at scan time. Targets
+// deleted without trash also add the bytes of their files as they are
+// removed, in progresses with Deleting set, while moves to trash advance once
+// per target.
 type CleanProgress struct {
-	Current int
-	Total   int
-	Target  types.Target
-	Error   error
+	Current    int
+	Total      int
+	Target     types.Target
+	Error      error
+	TrashID    string // Trash ID when the target was moved to trash
+	Skipped    bool   // Target was not processed because the context was cancelled
+	Deleting   bool   // Only the byte counts changed, while targets are being deleted; Current and Target are those of the last finished target
+	BytesDone  int64  // Bytes of all targets processed so far, including this one, and of those being deleted
+	BytesTotal int64  // Bytes of all targets in the operation
+
+	deleted int64 // Bytes of the target reported while it was being deleted
+
+	Report *types.CleanReport // Report of the whole clean, on the last progress only, which has no target
 }
 
-// New creates a new Cleaner with the specified trash system
-func New(trashSystem *trash.System) *Cleaner {
+// New creates a new Cleaner with the specified trash backend, usually a
+// *trash.System
+func New(trashSystem trash.Trasher) *Cleaner {
 	return &Cleaner{
 		trashSystem:    trashSystem,
 		telemetryStore: nil,
@@ -80,124 +121,372 @@ func (c *Cleaner) Clean(ctx context.Context, targets []types.Target, opts CleanO
 	startTime := time.Now()
 	logger.Debug("Starting clean operation for %d targets", len(targets))
 
-	report := &types.CleanReport{
-		TotalSize:    0,
-		FilesDeleted: 0,
-		Errors:       []types.CleanError{},
-		TrashedItems: []string{},
+	report := types.NewCleanReport()
+
+	if opts.Atomic && !opts.UseTrash {
+		return report, fmt.Errorf("atomic clean requires the trash to be enabled")
+	}
+	if opts.Atomic {
+		for _, target := range targets {
+			if target.Virtual {
+				return report, fmt.Errorf("atomic clean cannot include %s, which cannot be restored", target.Path)
+			}
+		}
+	}
+
+	// Run pre-batch hooks before touching any target
+	if err := runPreBatchHooks(ctx, opts.Hooks, targets); err != nil {
+		logger.Error("Clean operation aborted: %v", err)
+		return report, err
+	}
+
+	freeSpace := SnapshotFreeSpace(targets)
+	if !opts.IgnoreRunning {
+		opts.processes = snapshotProcesses()
 	}
 
+	// A target that has started is always finished, even after cancellation,
+	// so it is never left half deleted
+	detached := context.WithoutCancel(ctx)
+
 	// Process each target
-	for _, target := range targets {
-		// Check context cancellation
-		select {
-		case <-ctx.Done():
+	for i, target := range targets {
+		// Stop before the next target once cancelled; the rest are skipped
+		if ctx.Err() != nil {
 			logger.Debug("Clean operation cancelled by context: %v", ctx.Err())
+			if opts.Atomic {
+				c.rollback(report)
+			}
+			for _, skipped := range targets[i:] {
+				report.AddSkipped(skipped)
+			}
+			c.finish(ctx, targets, report, startTime, freeSpace, opts)
 			return report, ctx.Err()
-		default:
 		}
 
 		logger.Debug("Cleaning target: %s", target.Path)
 
-		// Check permissions before deletion
-		if err := c.canDelete(target.Path); err != nil {
-			logger.Error("Permission check failed for %s: %v", target.Path, err)
-			report.Errors = append(report.Errors, types.CleanError{
-				Target: target,
-				Error:  err,
-			})
+		result, err := c.cleanTarget(detached, target, opts)
+		if err != nil {
+			report.AddError(target, err)
+			if opts.Atomic {
+				c.rollback(report)
+				// Nothing is retried once the clean is rolled back
+				opts.Elevate = nil
+				c.finish(ctx, targets, report, startTime, freeSpace, opts)
+				return report, fmt.Errorf("atomic clean rolled back: %s: %w", target.Path, err)
+			}
 			continue
 		}
 
-		// Move to trash if enabled, otherwise delete directly
-		if opts.UseTrash {
-			// Move to trash (this also removes the file from original location)
-			id, err := c.trashSystem.Move(target)
-			if err != nil {
-				logger.Error("Failed to move %s to trash: %v", target.Path, err)
-				report.Errors = append(report.Errors, types.CleanError{
-					Target: target,
-					Error:  fmt.Errorf("failed to move to trash: %w", err),
-				})
-				continue
+		report.AddSuccess(result.Target, result.TrashID)
+	}
+
+	c.finish(ctx, targets, report, startTime, freeSpace, opts)
+	logger.Info("Clean operation completed: %d files deleted, %d errors", report.FilesDeleted, len(report.Errors))
+
+	return report, nil
+}
+
+// finish completes a clean whose targets have all been processed, skipped or
+// rolled back, for Clean and CleanAsync alike. It retries the targets that
+// failed with a permission error when opts.Elevate allows it, measures the
+// duration of the clean started at startTime and the space reclaimed since
+// freeSpace was taken, runs the post-batch hooks and the plugins, and records
+// report in telemetry. The post-batch hooks run even once ctx is cancelled,
+// to restart what the pre-batch hooks stopped.
+func (c *Cleaner) finish(ctx context.Context, targets []types.Target, report *types.CleanReport, startTime time.Time, freeSpace *FreeSpaceSnapshot, opts CleanOptions) {
+	if opts.Elevate != nil && ctx.Err() == nil {
+		failed := 0
+		for _, cleanErr := range report.Errors {
+			if CanRetryElevated(cleanErr) {
+				failed++
 			}
-			logger.Debug("Moved %s to trash with ID: %s", target.Path, id)
-			report.TrashedItems = append(report.TrashedItems, id)
-		} else {
-			// Delete directly without trash backup
-			if err := os.RemoveAll(target.Path); err != nil {
-				logger.Error("Failed to delete %s: %v", target.Path, err)
-				report.Errors = append(report.Errors, types.CleanError{
-					Target: target,
-					Error:  fmt.Errorf("failed to delete: %w", err),
-				})
-				continue
+		}
+		if failed > 0 {
+			if deleter := opts.Elevate(failed); deleter != nil {
+				c.RetryElevated(ctx, report, deleter, opts)
 			}
-			logger.Debug("Deleted %s", target.Path)
 		}
-
-		// Update report
-		report.TotalSize += target.Size
-		report.FilesDeleted++
 	}
 
 	report.Duration = time.Since(startTime)
-	logger.Info("Clean operation completed: %d files deleted, %d errors", report.FilesDeleted, len(report.Errors))
+	report.ReclaimedSize = freeSpace.Reclaimed()
 
-	// Call plugin.Clean() for plugin-specific cleanup
+	// Virtual targets free space the snapshot cannot see, like Docker's
+	for _, result := range report.Cleaned {
+		if result.Target.Virtual {
+			report.ReclaimedSize += result.Target.Size
+		}
+	}
+
+	c.trimTrash()
+
+	// What was cleaned before a cancellation is still reported
+	detached := context.WithoutCancel(ctx)
+	for _, err := range runPostBatchHooks(detached, opts.Hooks, targets) {
+		logger.Warn("%v", err)
+	}
+
+	// Call plugin.Clean() for plugin-specific cleanup, unless the targets
+	// were skipped
 	if c.pluginRegistry != nil {
-		if err := c.cleanPlugins(ctx, targets); err != nil {
-			logger.Warn("Plugin clean failed: %v", err)
-			// Don't fail the entire operation if plugins fail
+		if ctx.Err() == nil {
+			if err := c.cleanPlugins(ctx, c.unprotected(targets, opts), opts.PluginTimeout); err != nil {
+				logger.Warn("Plugin clean failed: %v", err)
+				// Don't fail the entire operation if plugins fail
+			}
 		}
+		plugins.NotifyCleanComplete(detached, c.pluginRegistry, report)
 	}
 
 	// Record clean events in telemetry
 	if c.telemetryStore != nil {
-		c.recordCleanEvents(targets, report)
+		c.recordCleanEvents(report)
 	}
+}
 
-	return report, nil
+// cleanTarget checks, hooks and deletes a single target. Its result holds the
+// trash ID when the target was moved to trash, and the target with the bytes
+// freed as its size.
+func (c *Cleaner) cleanTarget(ctx context.Context, target types.Target, opts CleanOptions) (types.CleanResult, error) {
+	// Protected paths are refused whatever the options and the deleter
+	if !target.Virtual {
+		if err := c.protectedPaths(opts).Check(target.Path); err != nil {
+			logger.Error("Refusing to clean %s: %v", target.Path, err)
+			return types.CleanResult{}, err
+		}
+	}
+
+	// Check permissions before deletion; virtual targets are up to their
+	// plugin, and elevated retries are made because this check failed
+	if !target.Virtual && !opts.elevated {
+		if err := c.canDelete(target.Path); err != nil {
+			logger.Error("Permission check failed for %s: %v", target.Path, err)
+			return types.CleanResult{}, err
+		}
+	}
+
+	// Deleting a directory a build is writing to corrupts the build
+	if !opts.IgnoreRunning && !target.Virtual {
+		if err := opts.processes.checkNotBusy(target.Path); err != nil {
+			logger.Warn("Skipping %s: %v", target.Path, err)
+			return types.CleanResult{}, err
+		}
+	}
+
+	for _, hook := range opts.Hooks.PreClean {
+		if err := runHook(ctx, hook, target, opts.HookTimeout); err != nil {
+			logger.Error("Pre-clean hook failed for %s: %v", target.Path, err)
+			return types.CleanResult{}, fmt.Errorf("pre-clean hook failed: %w", err)
+		}
+	}
+
+	// Move preserved paths out of the way before the target goes
+	kept, err := stashKept(target, append(append([]string{}, target.Keep...), opts.KeepPatterns...))
+	if err != nil {
+		logger.Error("Failed to preserve kept paths in %s: %v", target.Path, err)
+		return types.CleanResult{}, err
+	}
+
+	result, err := c.removeTarget(ctx, target, opts)
+
+	// Put preserved paths back whether or not the removal succeeded
+	if restoreErr := kept.restore(); restoreErr != nil {
+		logger.Error("Failed to restore kept paths in %s: %v", target.Path, restoreErr)
+		if err == nil {
+			return result, restoreErr
+		}
+	}
+	if err != nil {
+		return types.CleanResult{}, err
+	}
+
+	if opts.Checkpoint != nil {
+		if err := opts.Checkpoint.MarkDone(target); err != nil {
+			logger.Warn("Failed to update checkpoint for %s: %v", target.Path, err)
+		}
+	}
+
+	// Post-clean hook failures don't undo a successful clean
+	for _, hook := range opts.Hooks.PostClean {
+		if err := runHook(ctx, hook, target, opts.HookTimeout); err != nil {
+			logger.Warn("Post-clean hook failed for %s: %v", target.Path, err)
+		}
+	}
+
+	return result, nil
+}
+
+// removeTarget deletes the target with the Deleter chosen for it, retrying
+// failures according to opts.Retry. Its result is that of cleanTarget.
+func (c *Cleaner) removeTarget(ctx context.Context, target types.Target, opts CleanOptions) (types.CleanResult, error) {
+	deleter := c.deleterFor(target, opts)
+
+	result := types.CleanResult{Target: target}
+	err := opts.Retry.do(ctx, target.Path, func() error {
+		var deleteErr error
+		if measuring, ok := deleter.(measuringDeleter); ok {
+			result.Target.Size, deleteErr = measuring.DeleteMeasured(ctx, target)
+			return deleteErr
+		}
+		result.TrashID, deleteErr = deleter.Delete(ctx, target)
+		return deleteErr
+	})
+	if err != nil {
+		logger.Error("Failed to clean %s: %v", target.Path, err)
+		return types.CleanResult{}, err
+	}
+
+	return result, nil
+}
+
+// deleterFor returns the Deleter used for target: its plugin for virtual
+// targets, opts.Deleter when set, otherwise the trash or direct deletion
+// depending on opts.
+func (c *Cleaner) deleterFor(target types.Target, opts CleanOptions) Deleter {
+	if target.Virtual {
+		return &PluginDeleter{Registry: c.pluginRegistry}
+	}
+	if opts.Deleter != nil {
+		return opts.Deleter
+	}
+	if opts.useTrashFor(target) {
+		return &TrashDeleter{Trash: c.trashSystem, Retention: opts.TrashRetention}
+	}
+	return &DirectDeleter{Workers: opts.DeleteWorkers, Progress: opts.deleted}
+}
+
+// useTrashFor reports whether target should be moved to trash rather than
+// deleted directly. Atomic cleans always use the trash so they can be rolled back.
+func (opts CleanOptions) useTrashFor(target types.Target) bool {
+	if !opts.UseTrash {
+		return false
+	}
+	if opts.Atomic {
+		return true
+	}
+	if target.Permanent {
+		return false
+	}
+	name := filepath.Base(target.Path)
+	for _, pattern := range opts.PermanentPatterns {
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return false
+		}
+	}
+	return true
+}
+
+// rollback restores every item trashed so far, in reverse order, and resets
+// the report counters. Items that cannot be restored are reported as errors
+// and stay in the trash so they can still be restored manually.
+func (c *Cleaner) rollback(report *types.CleanReport) {
+	logger.Warn("Rolling back %d trashed item(s)", len(report.TrashedItems))
+
+	remaining := []string{}
+	for i := len(report.Cleaned) - 1; i >= 0; i-- {
+		result := report.Cleaned[i]
+		if result.TrashID == "" {
+			continue
+		}
+		// Kept paths were put back in place of the target after it was
+		// trashed
+		err := restoreAroundKept(result.Target.Path, func() error {
+			return c.trashSystem.Restore(result.TrashID)
+		})
+		if err != nil {
+			logger.Error("Failed to roll back trash item %s: %v", result.TrashID, err)
+			report.AddError(result.Target, fmt.Errorf("rollback failed for trash item %s: %w", result.TrashID, err))
+			remaining = append(remaining, result.TrashID)
+			continue
+		}
+		logger.Debug("Rolled back trash item %s", result.TrashID)
+	}
+
+	report.Cleaned = []types.CleanResult{}
+	report.TrashedItems = remaining
+	report.TotalSize = 0
+	report.FilesDeleted = 0
+	for _, summary := range report.Profiles {
+		summary.Targets = 0
+		summary.Size = 0
+	}
 }
 
-// recordCleanEvents records clean events in telemetry for each profile type
-func (c *Cleaner) recordCleanEvents(targets []types.Target, report *types.CleanReport) {
+// recordCleanEvents records clean events in telemetry for each profile type,
+// with the path and the bytes freed of each target cleaned. Targets skipped
+// or failed are not counted as cleaned. The free space of the filesystems
+// cleaned, measured once, is recorded with the first event. Targets that
+// failed are counted in an error event, with the first error.
+func (c *Cleaner) recordCleanEvents(report *types.CleanReport) {
 	// Group targets by profile to record aggregate events
-	profileSizes := make(map[string]int64)
-	for _, target := range targets {
-		// Only count successfully cleaned targets
-		wasError := false
-		for _, cleanErr := range report.Errors {
-			if cleanErr.Target.Path == target.Path {
-				wasError = true
-				break
-			}
+	events := make(map[string]*telemetry.CleanEvent)
+	var dirs []string
+	for _, result := range report.Cleaned {
+		target := result.Target
+		event, ok := events[target.ProfileName]
+		if !ok {
+			event = &telemetry.CleanEvent{Profile: target.ProfileName, Duration: report.Duration.Seconds()}
+			events[target.ProfileName] = event
 		}
-		if !wasError {
-			profileSizes[target.ProfileName] += target.Size
+		event.Size += target.Size
+		event.Targets = append(event.Targets, telemetry.CleanedTarget{Path: target.Path, Size: target.Size})
+		if !target.Virtual {
+			// The parent, which still exists after the target is removed
+			dirs = append(dirs, filepath.Dir(target.Path))
 		}
 	}
 
 	// Record an event for each profile type
-	for profileName, size := range profileSizes {
-		event := telemetry.TelemetryEvent{
-			Type:      "clean",
-			Timestamp: time.Now(),
-			Data: map[string]interface{}{
-				"size":     size,
-				"profile":  profileName,
-				"duration": report.Duration.Seconds(),
-			},
-		}
-
-		if err := c.telemetryStore.Record(event); err != nil {
+	for profileName, event := range events {
+		if dirs != nil {
+			event.FreeSpace = telemetry.MeasureFreeSpace(dirs)
+			dirs = nil
+		}
+
+		if err := c.telemetryStore.Record(telemetry.NewEvent(event)); err != nil {
 			logger.Warn("Failed to record clean telemetry for profile %s: %v", profileName, err)
 		}
 	}
+
+	if len(report.Errors) > 0 {
+		event := &telemetry.ErrorEvent{Operation: "clean", Failed: len(report.Errors)}
+		if firstErr := report.Errors[0].Error; firstErr != nil {
+			event.Error = firstErr.Error()
+		}
+		if err := c.telemetryStore.Record(telemetry.NewEvent(event)); err != nil {
+			logger.Warn("Failed to record clean error telemetry: %v", err)
+		}
+	}
+}
+
+// protectedPaths returns the paths never cleaned: the built-in ones, those of
+// opts and the directory of the trash
+func (c *Cleaner) protectedPaths(opts CleanOptions) *fsutils.Protected {
+	paths := slices.Clone(opts.ProtectedPaths)
+	if trashSystem, ok := c.trashSystem.(interface{ GetTrashDir() string }); ok {
+		paths = append(paths, trashSystem.GetTrashDir())
+	}
+	return fsutils.NewProtected(paths...)
+}
+
+// unprotected returns the targets that are not protected paths, the only
+// ones plugins are asked to clean
+func (c *Cleaner) unprotected(targets []types.Target, opts CleanOptions) []types.Target {
+	protected := c.protectedPaths(opts)
+	kept := make([]types.Target, 0, len(targets))
+	for _, target := range targets {
+		if target.Virtual || protected.Check(target.Path) == nil {
+			kept = append(kept, target)
+		}
+	}
+	return kept
 }
 
-// cleanPlugins calls Clean() on all registered plugins
-func (c *Cleaner) cleanPlugins(ctx context.Context, targets []types.Target) error {
+// cleanPlugins calls Clean() on all registered plugins. A plugin failing,
+// panicking or timing out after timeout does not stop the others.
+func (c *Cleaner) cleanPlugins(ctx context.Context, targets []types.Target, timeout time.Duration) error {
 	allPlugins := c.pluginRegistry.List()
 	if len(allPlugins) == 0 {
 		return nil
@@ -208,13 +497,14 @@ func (c *Cleaner) cleanPlugins(ctx context.Context, targets []types.Target) erro
 	for _, plugin := range allPlugins {
 		logger.Debug("Calling plugin.Clean() for: %s", plugin.Name())
 
-		if err := plugin.Clean(ctx, targets); err != nil {
-			logger.Warn("Plugin %s clean failed: %v", plugin.Name(), err)
+		start := time.Now()
+		if err := plugins.Clean(ctx, plugin, targets, timeout); err != nil {
+			logger.Warn("Plugin %s clean failed after %v: %v", plugin.Name(), time.Since(start).Round(time.Millisecond), err)
 			// Continue with other plugins
 			continue
 		}
 
-		logger.Debug("Plugin %s clean completed", plugin.Name())
+		logger.Debug("Plugin %s clean completed in %v", plugin.Name(), time.Since(start).Round(time.Millisecond))
 	}
 
 	return nil
@@ -259,16 +549,40 @@ func (c *Cleaner) canDelete(path string) error {
 	return nil
 }
 
-// CleanAsync performs concurrent cleaning with progress reporting
+// deletingProgressInterval is how often CleanAsync reports the bytes of the
+// targets being deleted
+const deletingProgressInterval = 200 * time.Millisecond
+
+// CleanAsync performs concurrent cleaning with progress reporting. A progress
+// is sent for each target, then a last one holding the report of the whole
+// clean, once finished as Clean finishes it: with the elevated retries, the
+// space reclaimed, the post-batch hooks, the plugins and telemetry. The
+// channel is closed after it.
 func (c *Cleaner) CleanAsync(ctx context.Context, targets []types.Target, opts CleanOptions) (<-chan CleanProgress, error) {
+	startTime := time.Now()
 	progressCh := make(chan CleanProgress, 10)
 
+	if opts.Atomic {
+		return nil, fmt.Errorf("atomic clean is not supported by CleanAsync, use Clean")
+	}
+
 	// Default concurrency if not specified
 	concurrency := opts.Concurrency
 	if concurrency <= 0 {
 		concurrency = 4 // Default to 4 workers
 	}
 
+	// Run pre-batch hooks before starting any worker
+	if err := runPreBatchHooks(ctx, opts.Hooks, targets); err != nil {
+		logger.Error("Clean operation aborted: %v", err)
+		return nil, err
+	}
+
+	freeSpace := SnapshotFreeSpace(targets)
+	if !opts.IgnoreRunning {
+		opts.processes = snapshotProcesses()
+	}
+
 	go func() {
 		defer close(progressCh)
 
@@ -280,61 +594,44 @@ func (c *Cleaner) CleanAsync(ctx context.Context, targets []types.Target, opts C
 
 		// Create worker pool
 		results := make(chan CleanProgress, len(targets))
+		var deleting atomic.Int64 // Bytes deleted of the targets being cleaned
 
 		// Start workers
 		for w := 0; w < concurrency; w++ {
 			go func() {
 				for job := range jobs {
-					// Check context cancellation
-					select {
-					case <-ctx.Done():
+					// Queued targets are skipped once cancelled
+					if ctx.Err() != nil {
 						results <- CleanProgress{
 							Current: job.index,
 							Total:   len(targets),
 							Target:  job.target,
-							Error:   ctx.Err(),
+							Skipped: true,
 						}
 						continue
-					default:
 					}
 
-					// Check permissions
-					if err := c.canDelete(job.target.Path); err != nil {
-						logger.Error("Permission check failed for %s: %v", job.target.Path, err)
-						results <- CleanProgress{
-							Current: job.index,
-							Total:   len(targets),
-							Target:  job.target,
-							Error:   err,
-						}
-						continue
+					// Count the bytes deleted until the target is done
+					var deleted atomic.Int64
+					targetOpts := opts
+					targetOpts.deleted = func(bytes int64) {
+						deleted.Add(bytes)
+						deleting.Add(bytes)
 					}
 
-					// Clean the target
-					var cleanErr error
-					if opts.UseTrash {
-						_, cleanErr = c.trashSystem.Move(job.target)
-						if cleanErr != nil {
-							logger.Error("Failed to move %s to trash: %v", job.target.Path, cleanErr)
-							cleanErr = fmt.Errorf("failed to move to trash: %w", cleanErr)
-						} else {
-							logger.Debug("Moved %s to trash", job.target.Path)
-						}
-					} else {
-						cleanErr = os.RemoveAll(job.target.Path)
-						if cleanErr != nil {
-							logger.Error("Failed to delete %s: %v", job.target.Path, cleanErr)
-							cleanErr = fmt.Errorf("failed to delete: %w", cleanErr)
-						} else {
-							logger.Debug("Deleted %s", job.target.Path)
-						}
+					// Clean the target, finishing it even if cancelled meanwhile
+					result, cleanErr := c.cleanTarget(context.WithoutCancel(ctx), job.target, targetOpts)
+					if cleanErr != nil {
+						result.Target = job.target
 					}
 
 					results <- CleanProgress{
 						Current: job.index,
 						Total:   len(targets),
-						Target:  job.target,
+						Target:  result.Target,
 						Error:   cleanErr,
+						TrashID: result.TrashID,
+						deleted: deleted.Load(),
 					}
 				}
 			}()
@@ -352,35 +649,96 @@ func (c *Cleaner) CleanAsync(ctx context.Context, targets []types.Target, opts C
 		}
 		close(jobs)
 
-		// Collect and forward results
-		for i := 0; i < len(targets); i++ {
-			progress := <-results
-			progressCh <- progress
+		var bytesTotal, bytesDone int64
+		for _, target := range targets {
+			bytesTotal += target.Size
+		}
+
+		// Collect and forward results, recording them in the report, and
+		// report the bytes of the targets being deleted meanwhile
+		ticker := time.NewTicker(deletingProgressInterval)
+		defer ticker.Stop()
+		report := types.NewCleanReport()
+		last := CleanProgress{Total: len(targets)}
+		for received := 0; received < len(targets); {
+			select {
+			case progress := <-results:
+				received++
+				deleting.Add(-progress.deleted)
+				bytesDone += targets[progress.Current-1].Size
+				progress.BytesDone = min(bytesDone+deleting.Load(), bytesTotal)
+				progress.BytesTotal = bytesTotal
+				progress.AddToReport(report)
+				progressCh <- progress
+				last = progress
+			case <-ticker.C:
+				if deleting.Load() == 0 {
+					continue
+				}
+				progressCh <- CleanProgress{
+					Current:    last.Current,
+					Total:      len(targets),
+					Target:     last.Target,
+					Deleting:   true,
+					BytesDone:  min(bytesDone+deleting.Load(), bytesTotal),
+					BytesTotal: bytesTotal,
+				}
+			}
+		}
+
+		// The clean is finished as Clean finishes it, plugins included,
+		// before the last progress and the channel closes
+		c.finish(ctx, targets, report, startTime, freeSpace, opts)
+		progressCh <- CleanProgress{
+			Current:    len(targets),
+			Total:      len(targets),
+			BytesDone:  bytesDone,
+			BytesTotal: bytesTotal,
+			Report:     report,
 		}
 	}()
 
 	return progressCh, nil
 }
 
+// trimTrash brings a trash with a size limit back within it, removing the
+// oldest items of earlier cleans
+func (c *Cleaner) trimTrash() {
+	trimmer, ok := c.trashSystem.(trash.Trimmer)
+	if !ok {
+		return
+	}
+	removed, err := trimmer.Trim()
+	if len(removed) > 0 {
+		logger.Info("Removed %d old trash item(s) to keep the trash within its size limit", len(removed))
+	}
+	if err != nil {
+		logger.Warn("Failed to trim the trash: %v", err)
+	}
+}
+
+// AddToReport records the outcome of the target in report, or replaces report
+// with the report of the whole clean on the last progress
+func (p CleanProgress) AddToReport(report *types.CleanReport) {
+	switch {
+	case p.Deleting:
+	case p.Report != nil:
+		*report = *p.Report
+	case p.Skipped:
+		report.AddSkipped(p.Target)
+	case p.Error != nil:
+		report.AddError(p.Target, p.Error)
+	default:
+		report.AddSuccess(p.Target, p.TrashID)
+	}
+}
+
 // GenerateReportFromProgress creates a CleanReport from async progress results
 func GenerateReportFromProgress(progressCh <-chan CleanProgress, startTime time.Time) *types.CleanReport {
-	report := &types.CleanReport{
-		TotalSize:    0,
-		FilesDeleted: 0,
-		Errors:       []types.CleanError{},
-		TrashedItems: []string{},
-	}
+	report := types.NewCleanReport()
 
 	for progress := range progressCh {
-		if progress.Error != nil {
-			report.Errors = append(report.Errors, types.CleanError{
-				Target: progress.Target,
-				Error:  progress.Error,
-			})
-		} else {
-			report.TotalSize += progress.Target.Size
-			report.FilesDeleted++
-		}
+		progress.AddToReport(report)
 	}
 
 	report.Duration = time.Since(startTime)
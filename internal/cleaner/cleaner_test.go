@@ -2,11 +2,14 @@ package cleaner
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/raucheacho/rosia-cli/internal/plugins"
+	"github.com/raucheacho/rosia-cli/internal/telemetry"
 	"github.com/raucheacho/rosia-cli/internal/trash"
 	"github.com/raucheacho/rosia-cli/pkg/types"
 	"github.com/stretchr/testify/assert"
@@ -171,11 +174,36 @@ func TestCleaner_CleanAsync(t *testing.T) {
 	})
 	require.NoError(t, err)
 
+	// Byte progress grows monotonically up to the total size, then the last
+	// progress holds the report
+	var forwarded []CleanProgress
+	var lastBytes int64
+	var final *types.CleanReport
+	for progress := range progressCh {
+		assert.Equal(t, int64(1500), progress.BytesTotal)
+		if progress.Report != nil {
+			final = progress.Report
+			continue
+		}
+		assert.Greater(t, progress.BytesDone, lastBytes)
+		lastBytes = progress.BytesDone
+		forwarded = append(forwarded, progress)
+	}
+	assert.Equal(t, int64(1500), lastBytes)
+	require.NotNil(t, final)
+	assert.Equal(t, 5, final.FilesDeleted)
+
 	// Generate report from progress
-	report := GenerateReportFromProgress(progressCh, startTime)
+	replay := make(chan CleanProgress, len(forwarded))
+	for _, progress := range forwarded {
+		replay <- progress
+	}
+	close(replay)
+	report := GenerateReportFromProgress(replay, startTime)
 
 	assert.Equal(t, 5, report.FilesDeleted)
 	assert.Equal(t, int64(1500), report.TotalSize) // 100+200+300+400+500
+	assert.Len(t, report.TrashedItems, 5)
 	assert.Empty(t, report.Errors)
 }
 
@@ -315,6 +343,110 @@ func TestCleaner_ContextCancellation(t *testing.T) {
 	assert.Less(t, report.FilesDeleted, len(targets))
 }
 
+func TestCleaner_Clean_CancelledTargetsAreSkipped(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	var targets []types.Target
+	for _, name := range []string{"a", "b", "c"} {
+		targetDir := filepath.Join(tmpDir, name)
+		require.NoError(t, os.MkdirAll(targetDir, 0755))
+		targets = append(targets, types.Target{Path: targetDir, Size: 10, IsDirectory: true})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := New(trashSystem).Clean(ctx, targets, CleanOptions{UseTrash: true})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, report.Skipped, len(targets))
+	assert.Empty(t, report.Errors)
+	for _, target := range targets {
+		assert.DirExists(t, target.Path)
+	}
+}
+
+func TestCleaner_CleanAsync_ReportsBytesWhileDeleting(t *testing.T) {
+	tmpDir := t.TempDir()
+	targetDir := filepath.Join(tmpDir, "node_modules")
+	require.NoError(t, os.MkdirAll(filepath.Join(targetDir, "pkg"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "pkg", "index.js"), make([]byte, 300), 0644))
+	targets := []types.Target{
+		{Path: targetDir, Size: 1000, Type: "directory", IsDirectory: true},
+	}
+
+	// The post-clean hook holds the target, deleted but not finished, until
+	// the bytes it freed have been reported
+	reported := make(chan struct{})
+	hold := func(ctx context.Context, target types.Target) error {
+		select {
+		case <-reported:
+		case <-time.After(5 * time.Second):
+		}
+		return nil
+	}
+
+	cleaner := New(nil)
+	progressCh, err := cleaner.CleanAsync(context.Background(), targets, CleanOptions{
+		UseTrash: false,
+		Hooks:    Hooks{PostClean: []HookFunc{hold}},
+	})
+	require.NoError(t, err)
+
+	var deleting []CleanProgress
+	var finished []CleanProgress
+	for progress := range progressCh {
+		switch {
+		case progress.Deleting:
+			if len(deleting) == 0 {
+				close(reported)
+			}
+			deleting = append(deleting, progress)
+		case progress.Report == nil:
+			finished = append(finished, progress)
+		}
+	}
+
+	require.NotEmpty(t, deleting, "bytes should be reported while the target is deleted")
+	assert.Equal(t, int64(300), deleting[0].BytesDone)
+	assert.Equal(t, int64(1000), deleting[0].BytesTotal)
+
+	// Once finished, the target counts with its scanned size
+	require.Len(t, finished, 1)
+	assert.Equal(t, int64(1000), finished[0].BytesDone)
+
+	// Byte updates are not outcomes of targets
+	report := types.NewCleanReport()
+	deleting[0].AddToReport(report)
+	assert.Equal(t, 0, report.FilesDeleted)
+	assert.Empty(t, report.Errors)
+}
+
+func TestCleaner_CleanAsync_CancelledTargetsAreSkipped(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	var targets []types.Target
+	for _, name := range []string{"a", "b", "c"} {
+		targetDir := filepath.Join(tmpDir, name)
+		require.NoError(t, os.MkdirAll(targetDir, 0755))
+		targets = append(targets, types.Target{Path: targetDir, Size: 10, IsDirectory: true})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	progressCh, err := New(trashSystem).CleanAsync(ctx, targets, CleanOptions{UseTrash: true})
+	require.NoError(t, err)
+
+	report := GenerateReportFromProgress(progressCh, time.Now())
+	assert.Len(t, report.Skipped, len(targets))
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 0, report.FilesDeleted)
+}
+
 func TestCleaner_ConcurrentCleaning(t *testing.T) {
 	// Create temporary directories
 	tmpDir := t.TempDir()
@@ -617,3 +749,590 @@ func BenchmarkCleaner_ConcurrentVsSequential(b *testing.B) {
 		}
 	})
 }
+
+func TestCleaner_Clean_ReportsReclaimedSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	// Write a file large enough to show up in the free space delta
+	targetDir := filepath.Join(tmpDir, "target")
+	require.NoError(t, os.MkdirAll(targetDir, 0755))
+	data := make([]byte, 4*1024*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "blob"), data, 0644))
+
+	target := types.Target{Path: targetDir, Size: 1, ProfileName: "test", IsDirectory: true}
+
+	// The disk gains the size of the blob once the target is deleted
+	stubFreeSpace(t, func(path string) (uint64, error) {
+		if _, err := os.Stat(targetDir); err == nil {
+			return 1 << 30, nil
+		}
+		return 1<<30 + uint64(len(data)), nil
+	})
+
+	report, err := New(trashSystem).Clean(context.Background(), []types.Target{target}, CleanOptions{})
+	require.NoError(t, err)
+
+	// The estimate comes from the scan, the reclaimed size from the disk
+	assert.Equal(t, int64(1), report.TotalSize)
+	assert.Equal(t, int64(len(data)), report.ReclaimedSize)
+}
+
+// stubFreeSpace replaces the free space measures for the duration of t
+func stubFreeSpace(t *testing.T, measure func(path string) (uint64, error)) {
+	t.Helper()
+	original := measureFreeSpace
+	measureFreeSpace = measure
+	t.Cleanup(func() { measureFreeSpace = original })
+}
+
+func TestFreeSpaceSnapshot_Reclaimed(t *testing.T) {
+	tmpDir := t.TempDir()
+	free := uint64(5000)
+	stubFreeSpace(t, func(path string) (uint64, error) { return free, nil })
+
+	snapshot := SnapshotFreeSpace([]types.Target{{Path: filepath.Join(tmpDir, "a")}, {Path: filepath.Join(tmpDir, "b")}})
+	assert.Equal(t, int64(0), snapshot.Reclaimed())
+
+	// A filesystem is counted once, whatever the number of its targets
+	free = 7000
+	assert.Equal(t, int64(2000), snapshot.Reclaimed())
+
+	// Space taken by unrelated writes counts as nothing reclaimed
+	free = 4000
+	assert.Equal(t, int64(0), snapshot.Reclaimed())
+}
+
+func TestSnapshotFreeSpace_SkipsUnmeasurable(t *testing.T) {
+	snapshot := SnapshotFreeSpace([]types.Target{{Path: "/nonexistent/path/target"}})
+	assert.Equal(t, int64(0), snapshot.Reclaimed())
+}
+
+func TestCleaner_Clean_AtomicRollback(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	firstDir := filepath.Join(tmpDir, "first")
+	secondDir := filepath.Join(tmpDir, "second")
+	require.NoError(t, os.MkdirAll(firstDir, 0755))
+	require.NoError(t, os.MkdirAll(secondDir, 0755))
+
+	targets := []types.Target{
+		{Path: firstDir, Size: 10, ProfileName: "test", IsDirectory: true},
+		{Path: filepath.Join(tmpDir, "missing"), Size: 20, ProfileName: "test", IsDirectory: true},
+		{Path: secondDir, Size: 30, ProfileName: "test", IsDirectory: true},
+	}
+
+	report, err := New(trashSystem).Clean(context.Background(), targets, CleanOptions{
+		UseTrash: true,
+		Atomic:   true,
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rolled back")
+	assert.Equal(t, 0, report.FilesDeleted)
+	assert.Equal(t, int64(0), report.TotalSize)
+	assert.Empty(t, report.TrashedItems)
+	assert.Len(t, report.Errors, 1)
+
+	// The first target was restored, the third was never touched
+	assert.DirExists(t, firstDir)
+	assert.DirExists(t, secondDir)
+
+	items, err := trashSystem.List()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestCleaner_Clean_AtomicRollbackKeep(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	targetDir := filepath.Join(tmpDir, "target")
+	require.NoError(t, os.MkdirAll(filepath.Join(targetDir, ".cache", "turbo"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, ".cache", "turbo", "hash"), []byte("kept"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, ".cache", "other"), []byte("other"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "build.o"), []byte("object"), 0644))
+
+	targets := []types.Target{
+		{Path: targetDir, Size: 10, ProfileName: "test", IsDirectory: true},
+		{Path: filepath.Join(tmpDir, "missing"), Size: 20, ProfileName: "test", IsDirectory: true},
+	}
+
+	report, err := New(trashSystem).Clean(context.Background(), targets, CleanOptions{
+		UseTrash:     true,
+		Atomic:       true,
+		KeepPatterns: []string{".cache/turbo"},
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rolled back")
+	assert.Len(t, report.Errors, 1)
+	assert.Empty(t, report.TrashedItems)
+
+	// The target is back whole, kept paths included
+	for name, content := range map[string]string{
+		filepath.Join(".cache", "turbo", "hash"): "kept",
+		filepath.Join(".cache", "other"):         "other",
+		"build.o":                                "object",
+	} {
+		data, err := os.ReadFile(filepath.Join(targetDir, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	items, err := trashSystem.List()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	// No keep directory is left next to the target
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".rosia-keep-")
+	}
+}
+
+// memoryTrasher is a trash.Trasher that only records what it is asked to do
+type memoryTrasher struct {
+	moved    []types.Target
+	restored []string
+}
+
+func (m *memoryTrasher) MoveWith(target types.Target, opts trash.MoveOptions) (string, error) {
+	if _, err := os.Stat(target.Path); err != nil {
+		return "", err
+	}
+	m.moved = append(m.moved, target)
+	return fmt.Sprintf("mem-%d", len(m.moved)), nil
+}
+
+func (m *memoryTrasher) Restore(id string) error {
+	m.restored = append(m.restored, id)
+	return nil
+}
+
+func TestCleaner_Clean_CustomTrasher(t *testing.T) {
+	tmpDir := t.TempDir()
+	firstDir := filepath.Join(tmpDir, "first")
+	require.NoError(t, os.MkdirAll(firstDir, 0755))
+
+	targets := []types.Target{
+		{Path: firstDir, Size: 10, ProfileName: "test", IsDirectory: true},
+		{Path: filepath.Join(tmpDir, "missing"), Size: 20, ProfileName: "test", IsDirectory: true},
+	}
+
+	trasher := &memoryTrasher{}
+	_, err := New(trasher).Clean(context.Background(), targets, CleanOptions{
+		UseTrash: true,
+		Atomic:   true,
+	})
+	require.Error(t, err)
+
+	// The trashed target went through the injected backend, and so did the rollback
+	require.Len(t, trasher.moved, 1)
+	assert.Equal(t, firstDir, trasher.moved[0].Path)
+	assert.Equal(t, []string{"mem-1"}, trasher.restored)
+}
+
+// trimmingTrasher is a memoryTrasher with a size limit
+type trimmingTrasher struct {
+	memoryTrasher
+	trims int
+}
+
+func (m *trimmingTrasher) Trim() ([]types.TrashItem, error) {
+	m.trims++
+	return nil, nil
+}
+
+func TestCleaner_Clean_TrimsTrash(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "node_modules")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	trasher := &trimmingTrasher{}
+	_, err := New(trasher).Clean(context.Background(), []types.Target{{Path: dir, IsDirectory: true}}, CleanOptions{UseTrash: true})
+	require.NoError(t, err)
+
+	// The trash is trimmed once the targets are in it
+	require.Len(t, trasher.moved, 1)
+	assert.Equal(t, 1, trasher.trims)
+}
+
+func TestCleaner_Clean_ProtectedPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	// Items in the trash look like targets, but the trash is never cleaned
+	trashed := filepath.Join(tmpDir, "trash", "item", "node_modules")
+	kept := filepath.Join(tmpDir, "kept", "node_modules")
+	cleaned := filepath.Join(tmpDir, "project", "node_modules")
+	for _, dir := range []string{trashed, kept, cleaned} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+
+	targets := []types.Target{
+		{Path: trashed, IsDirectory: true},
+		{Path: kept, IsDirectory: true},
+		{Path: cleaned, IsDirectory: true},
+	}
+	report, err := New(trashSystem).Clean(context.Background(), targets, CleanOptions{
+		ProtectedPaths: []string{filepath.Join(tmpDir, "kept")},
+		Deleter:        &DirectDeleter{},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, report.Errors, 2)
+	for _, cleanErr := range report.Errors {
+		assert.Equal(t, types.ErrorCodeProtected, types.ClassifyError(cleanErr.Error), cleanErr.Target.Path)
+	}
+	assert.DirExists(t, trashed)
+	assert.DirExists(t, kept)
+	assert.NoDirExists(t, cleaned)
+}
+
+func TestCleaner_Clean_AtomicRequiresTrash(t *testing.T) {
+	trashSystem, err := trash.NewSystem(filepath.Join(t.TempDir(), "trash"))
+	require.NoError(t, err)
+
+	_, err = New(trashSystem).Clean(context.Background(), nil, CleanOptions{Atomic: true})
+	assert.Error(t, err)
+}
+
+func TestCleaner_Clean_PermanentTargets(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	var targets []types.Target
+	for _, name := range []string{"venv", "__pycache__", "marked"} {
+		dir := filepath.Join(tmpDir, name)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		targets = append(targets, types.Target{Path: dir, ProfileName: "test", IsDirectory: true, Permanent: name == "marked"})
+	}
+
+	report, err := New(trashSystem).Clean(context.Background(), targets, CleanOptions{
+		UseTrash:          true,
+		PermanentPatterns: []string{"__py*"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.FilesDeleted)
+
+	// Only the regular target went to trash
+	require.Len(t, report.TrashedItems, 1)
+	items, err := trashSystem.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, targets[0].Path, items[0].OriginalPath)
+
+	for _, target := range targets {
+		assert.NoDirExists(t, target.Path)
+	}
+}
+
+// virtualPlugin deletes its virtual targets by recording them
+type virtualPlugin struct {
+	deleted []string
+}
+
+func (p *virtualPlugin) Name() string        { return "virtual" }
+func (p *virtualPlugin) Version() string     { return "1.0.0" }
+func (p *virtualPlugin) Description() string { return "Virtual targets" }
+
+func (p *virtualPlugin) Scan(ctx context.Context) ([]types.Target, error) {
+	return nil, nil
+}
+
+func (p *virtualPlugin) Clean(ctx context.Context, targets []types.Target) error {
+	return nil
+}
+
+func (p *virtualPlugin) DeleteTarget(ctx context.Context, target types.Target) error {
+	p.deleted = append(p.deleted, target.Path)
+	return nil
+}
+
+func TestCleaner_Clean_VirtualTargets(t *testing.T) {
+	trasher := &memoryTrasher{}
+	plugin := &virtualPlugin{}
+	registry := plugins.NewRegistry()
+	require.NoError(t, registry.Register(plugin))
+
+	cleaner := New(trasher)
+	cleaner.SetPluginRegistry(registry)
+
+	targets := []types.Target{
+		{Path: "virtual://images/1", Size: 100, ProfileName: "Virtual", Source: "virtual", Virtual: true},
+		{Path: "orphan://images/2", Size: 50, ProfileName: "Orphan", Source: "missing", Virtual: true},
+	}
+	report, err := cleaner.Clean(context.Background(), targets, CleanOptions{UseTrash: true})
+	require.NoError(t, err)
+
+	// Virtual targets are deleted by their plugin, never trashed
+	assert.Equal(t, []string{"virtual://images/1"}, plugin.deleted)
+	assert.Empty(t, trasher.moved)
+	assert.Equal(t, 1, report.FilesDeleted)
+	assert.Equal(t, int64(100), report.ReclaimedSize)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0].Error.Error(), "plugin missing is not loaded")
+
+	// They cannot be restored, so atomic cleans refuse them
+	_, err = cleaner.Clean(context.Background(), targets[:1], CleanOptions{UseTrash: true, Atomic: true})
+	assert.Error(t, err)
+}
+
+// partialPlugin frees a quarter of its virtual targets
+type partialPlugin struct {
+	virtualPlugin
+}
+
+func (p *partialPlugin) DeletePart(ctx context.Context, target types.Target) (int64, error) {
+	p.deleted = append(p.deleted, target.Path)
+	return target.Size / 4, nil
+}
+
+func TestCleaner_Clean_PartialTargets(t *testing.T) {
+	plugin := &partialPlugin{}
+	registry := plugins.NewRegistry()
+	require.NoError(t, registry.Register(plugin))
+
+	cleaner := New(&memoryTrasher{})
+	cleaner.SetPluginRegistry(registry)
+
+	targets := []types.Target{{Path: "virtual://cache", Size: 400, ProfileName: "Virtual", Source: "virtual", Virtual: true}}
+	report, err := cleaner.Clean(context.Background(), targets, CleanOptions{UseTrash: true})
+	require.NoError(t, err)
+
+	// The bytes the plugin freed are reported, not the size of the target
+	assert.Equal(t, []string{"virtual://cache"}, plugin.deleted)
+	assert.Equal(t, int64(100), report.TotalSize)
+	assert.Equal(t, int64(100), report.ReclaimedSize)
+	assert.Equal(t, int64(100), report.Profiles["Virtual"].Size)
+}
+
+func TestCleaner_Clean_RecordsTargets(t *testing.T) {
+	tmpDir := t.TempDir()
+	targetDir := filepath.Join(tmpDir, "target")
+	require.NoError(t, os.MkdirAll(targetDir, 0755))
+
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+	store, err := telemetry.NewFileStore(filepath.Join(tmpDir, "stats.json"))
+	require.NoError(t, err)
+
+	cleaner := New(trashSystem)
+	cleaner.SetTelemetryStore(store)
+	target := types.Target{Path: targetDir, Size: 100, Type: "directory", ProfileName: "test", IsDirectory: true}
+	_, err = cleaner.Clean(context.Background(), []types.Target{target}, CleanOptions{UseTrash: true})
+	require.NoError(t, err)
+
+	// Each target cleaned is recorded with its clean event
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	top := stats.TopCleans(0)
+	require.Len(t, top, 1)
+	assert.Equal(t, targetDir, top[0].Path)
+	assert.Equal(t, "test", top[0].Profile)
+	assert.Equal(t, int64(100), top[0].Size)
+
+	// So is the free space of the filesystem cleaned
+	trends := stats.FreeSpaceTrends()
+	require.Len(t, trends, 1)
+	assert.Equal(t, tmpDir, trends[0].Path)
+}
+
+func TestCleaner_RecordCleanEvents_Errors(t *testing.T) {
+	store, err := telemetry.NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+	cleaner := New(nil)
+	cleaner.SetTelemetryStore(store)
+
+	cleaned := types.Target{Path: "/virtual/a", Size: 100, ProfileName: "test", Virtual: true}
+	failed := types.Target{Path: "/virtual/b", Size: 200, ProfileName: "test", Virtual: true}
+	cleaner.recordCleanEvents(&types.CleanReport{
+		Cleaned: []types.CleanResult{{Target: cleaned}},
+		Errors:  []types.CleanError{{Target: failed, Error: fmt.Errorf("permission denied")}},
+	})
+
+	// Failed targets are counted as failures rather than cleaned
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), stats.TotalCleaned)
+	assert.Equal(t, 1, stats.TargetsCleaned)
+	assert.Equal(t, 1, stats.FailuresByOperation["clean"])
+	rate, ok := stats.FailureRate("clean")
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, rate)
+	require.Len(t, stats.Events, 2)
+	assert.Equal(t, &telemetry.ErrorEvent{Operation: "clean", Failed: 1, Error: "permission denied"}, stats.Events[1].Data)
+}
+
+func TestCleaner_RecordCleanEvents_Skipped(t *testing.T) {
+	store, err := telemetry.NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+	cleaner := New(nil)
+	cleaner.SetTelemetryStore(store)
+
+	// As CleanAsync reports a clean cancelled after its first target, which
+	// freed less than its size
+	report := types.NewCleanReport()
+	report.AddSuccess(types.Target{Path: "/virtual/a", Size: 40, ProfileName: "test", Virtual: true}, "")
+	report.AddSkipped(types.Target{Path: "/virtual/b", Size: 200, ProfileName: "test", Virtual: true})
+	cleaner.recordCleanEvents(report)
+
+	// Only the bytes freed by the target cleaned are recorded
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), stats.TotalCleaned)
+	assert.Equal(t, 1, stats.TargetsCleaned)
+	top := stats.TopCleans(0)
+	require.Len(t, top, 1)
+	assert.Equal(t, "/virtual/a", top[0].Path)
+	assert.Zero(t, stats.FailuresByOperation["clean"])
+}
+
+// completionPlugin records the reports of the cleans it is notified of
+type completionPlugin struct {
+	virtualPlugin
+	reports []*types.CleanReport
+}
+
+func (p *completionPlugin) OnCleanComplete(ctx context.Context, report *types.CleanReport) error {
+	p.reports = append(p.reports, report)
+	return nil
+}
+
+func TestCleaner_CleanAsync_NotifiesPlugins(t *testing.T) {
+	plugin := &completionPlugin{}
+	registry := plugins.NewRegistry()
+	require.NoError(t, registry.Register(plugin))
+
+	cleaner := New(&memoryTrasher{})
+	cleaner.SetPluginRegistry(registry)
+
+	tmpDir := t.TempDir()
+	targets := []types.Target{
+		{Path: filepath.Join(tmpDir, "a"), Size: 100, ProfileName: "test"},
+		{Path: filepath.Join(tmpDir, "b"), Size: 200, ProfileName: "test"},
+	}
+	for _, target := range targets {
+		require.NoError(t, os.MkdirAll(target.Path, 0755))
+	}
+
+	progressCh, err := cleaner.CleanAsync(context.Background(), targets, CleanOptions{UseTrash: true, Concurrency: 2})
+	require.NoError(t, err)
+	for range progressCh {
+	}
+
+	// Once the progress channel is closed, the plugin has the whole report
+	require.Len(t, plugin.reports, 1)
+	report := plugin.reports[0]
+	assert.Equal(t, 2, report.FilesDeleted)
+	assert.Equal(t, int64(300), report.TotalSize)
+	assert.Len(t, report.TrashedItems, 2)
+}
+
+// cleaningPlugin records the targets it is asked to clean
+type cleaningPlugin struct {
+	completionPlugin
+	cleaned []string
+}
+
+func (p *cleaningPlugin) Clean(ctx context.Context, targets []types.Target) error {
+	for _, target := range targets {
+		p.cleaned = append(p.cleaned, target.Path)
+	}
+	return nil
+}
+
+func TestCleaner_CleanAsync_FinishesAsClean(t *testing.T) {
+	plugin := &cleaningPlugin{}
+	registry := plugins.NewRegistry()
+	require.NoError(t, registry.Register(plugin))
+	store, err := telemetry.NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+
+	cleaner := New(&memoryTrasher{})
+	cleaner.SetPluginRegistry(registry)
+	cleaner.SetTelemetryStore(store)
+
+	var postBatch []types.Target
+	targets := []types.Target{{Path: "virtual://cache", Size: 400, ProfileName: "Virtual", Source: "virtual", Virtual: true}}
+	progressCh, err := cleaner.CleanAsync(context.Background(), targets, CleanOptions{
+		UseTrash: true,
+		Hooks: Hooks{PostBatch: []BatchHookFunc{func(ctx context.Context, targets []types.Target) error {
+			postBatch = targets
+			return nil
+		}}},
+	})
+	require.NoError(t, err)
+	report := GenerateReportFromProgress(progressCh, time.Now())
+
+	// As with Clean, the space virtual targets free is reclaimed, the
+	// plugins clean their targets and are notified, and telemetry is recorded
+	assert.Equal(t, int64(400), report.ReclaimedSize)
+	assert.Equal(t, targets, postBatch)
+	assert.Equal(t, []string{"virtual://cache"}, plugin.deleted)
+	assert.Equal(t, []string{"virtual://cache"}, plugin.cleaned)
+	require.Len(t, plugin.reports, 1)
+	assert.Equal(t, int64(400), plugin.reports[0].ReclaimedSize)
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(400), stats.TotalCleaned)
+}
+
+func TestCleaner_Clean_CancelledFinishes(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+	store, err := telemetry.NewFileStore(filepath.Join(tmpDir, "stats.json"))
+	require.NoError(t, err)
+	plugin := &cleaningPlugin{}
+	registry := plugins.NewRegistry()
+	require.NoError(t, registry.Register(plugin))
+
+	cleaner := New(trashSystem)
+	cleaner.SetTelemetryStore(store)
+	cleaner.SetPluginRegistry(registry)
+
+	var targets []types.Target
+	for _, name := range []string{"a", "b", "c"} {
+		targetDir := filepath.Join(tmpDir, name)
+		require.NoError(t, os.MkdirAll(targetDir, 0755))
+		targets = append(targets, types.Target{Path: targetDir, Size: 10, ProfileName: "test", IsDirectory: true})
+	}
+
+	// Cancelled while the first target is cleaned
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	report, err := cleaner.Clean(ctx, targets, CleanOptions{
+		UseTrash: true,
+		Hooks: Hooks{PreClean: []HookFunc{func(ctx context.Context, target types.Target) error {
+			cancel()
+			return nil
+		}}},
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, report.FilesDeleted)
+	assert.Len(t, report.Skipped, 2)
+
+	// The target cleaned is recorded, and plugins are notified without
+	// being asked to clean the skipped targets
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TargetsCleaned)
+	top := stats.TopCleans(0)
+	require.Len(t, top, 1)
+	assert.Equal(t, targets[0].Path, top[0].Path)
+	require.Len(t, plugin.reports, 1)
+	assert.Equal(t, 1, plugin.reports[0].FilesDeleted)
+	assert.Empty(t, plugin.cleaned)
+}
@@ -0,0 +1,180 @@
+package cleaner
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+	"github.com/raucheacho/rosia-cli/internal/plugins"
+	"github.com/raucheacho/rosia-cli/internal/trash"
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// Deleter removes a single target from disk.
+//
+// The Cleaner picks a Deleter per target and handles everything around it:
+// permission checks, hooks, kept paths, retries and reporting. Implementations
+// only have to remove the target.
+type Deleter interface {
+	// Delete removes target. It returns the trash ID when the target was
+	// moved somewhere it can be restored from, or an empty string otherwise.
+	Delete(ctx context.Context, target types.Target) (string, error)
+}
+
+// measuringDeleter is a Deleter measuring the bytes it frees, which may be
+// less than the size of the target
+type measuringDeleter interface {
+	DeleteMeasured(ctx context.Context, target types.Target) (int64, error)
+}
+
+// TrashDeleter moves targets to the trash so they can be restored later
+type TrashDeleter struct {
+	Trash     trash.Trasher
+	Retention time.Duration // Keep items this long instead of the configured period (0 = default)
+}
+
+// Delete moves target to the trash
+func (d *TrashDeleter) Delete(ctx context.Context, target types.Target) (string, error) {
+	id, err := d.Trash.MoveWith(target, trash.MoveOptions{Retention: d.Retention})
+	if err != nil {
+		return "", fmt.Errorf("failed to move to trash: %w", err)
+	}
+	logger.Debug("Moved %s to trash with ID: %s", target.Path, id)
+	return id, nil
+}
+
+// PluginDeleter deletes virtual targets with the plugin that reported them
+type PluginDeleter struct {
+	Registry plugins.PluginRegistry
+}
+
+// Delete asks the plugin of target to delete it
+func (d *PluginDeleter) Delete(ctx context.Context, target types.Target) (string, error) {
+	_, err := d.DeleteMeasured(ctx, target)
+	return "", err
+}
+
+// DeleteMeasured asks the plugin of target to delete it and returns the
+// bytes freed: those the plugin measured when it is a plugins.PartialDeleter,
+// the size of target otherwise
+func (d *PluginDeleter) DeleteMeasured(ctx context.Context, target types.Target) (int64, error) {
+	if d.Registry == nil {
+		return 0, fmt.Errorf("plugin %s is not loaded", target.Source)
+	}
+	plugin, err := d.Registry.Get(target.Source)
+	if err != nil {
+		return 0, fmt.Errorf("plugin %s is not loaded", target.Source)
+	}
+
+	freed := target.Size
+	if partial, ok := plugin.(plugins.PartialDeleter); ok {
+		freed, err = partial.DeletePart(ctx, target)
+	} else if deleter, ok := plugin.(plugins.TargetDeleter); ok {
+		err = deleter.DeleteTarget(ctx, target)
+	} else {
+		return 0, fmt.Errorf("plugin %s cannot delete its targets", target.Source)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete: %w", err)
+	}
+	logger.Debug("Deleted %s with plugin %s", target.Path, target.Source)
+	return freed, nil
+}
+
+// DirectDeleter deletes targets permanently without a trash backup
+type DirectDeleter struct {
+	Workers  int               // Goroutines deleting a single directory (0 = auto)
+	Progress func(bytes int64) // Called with the size of each removed file, from several goroutines at once
+}
+
+// Delete removes target and everything it contains
+func (d *DirectDeleter) Delete(ctx context.Context, target types.Target) (string, error) {
+	if err := fsutils.RemoveAllParallelProgress(target.Path, d.Workers, d.Progress); err != nil {
+		return "", fmt.Errorf("failed to delete: %w", err)
+	}
+	logger.Debug("Deleted %s", target.Path)
+	return "", nil
+}
+
+// ShredDeleter overwrites every regular file with random data before
+// deleting the target, so its contents cannot be recovered from disk.
+//
+// Overwriting is not effective on copy-on-write filesystems and SSDs with
+// wear leveling. Files with several hard links are overwritten for every link.
+type ShredDeleter struct {
+	Passes int // Overwrite passes per file (0 = 1)
+}
+
+// Delete overwrites the files of target, then removes it
+func (d *ShredDeleter) Delete(ctx context.Context, target types.Target) (string, error) {
+	passes := d.Passes
+	if passes <= 0 {
+		passes = 1
+	}
+
+	err := filepath.WalkDir(target.Path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Symlinks are removed, never followed
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		return shredFile(path, passes)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to shred: %w", err)
+	}
+
+	if err := os.RemoveAll(target.Path); err != nil {
+		return "", fmt.Errorf("failed to delete: %w", err)
+	}
+	logger.Debug("Shredded %s", target.Path)
+	return "", nil
+}
+
+// shredFile overwrites the contents of path with random data passes times
+func shredFile(path string, passes int) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < passes; i++ {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(f, rand.Reader, info.Size()); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DryRunDeleter logs what would be deleted without touching the filesystem
+type DryRunDeleter struct{}
+
+// Delete logs target and leaves it in place
+func (d *DryRunDeleter) Delete(ctx context.Context, target types.Target) (string, error) {
+	logger.Info("Dry run: would delete %s", target.Path)
+	return "", nil
+}
@@ -0,0 +1,119 @@
+package cleaner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/trash"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingDeleter records the targets it is asked to delete
+type recordingDeleter struct {
+	deleted []string
+}
+
+func (d *recordingDeleter) Delete(ctx context.Context, target types.Target) (string, error) {
+	d.deleted = append(d.deleted, target.Path)
+	return "", nil
+}
+
+// makeTargetDir creates a directory with a single file and returns its target
+func makeTargetDir(t *testing.T, dir string) types.Target {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "file.txt"), []byte("secret data"), 0644))
+	return types.Target{Path: dir, Size: 11, IsDirectory: true}
+}
+
+func TestTrashDeleter(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	target := makeTargetDir(t, filepath.Join(tmpDir, "target"))
+	id, err := (&TrashDeleter{Trash: trashSystem}).Delete(context.Background(), target)
+	require.NoError(t, err)
+	assert.NotEmpty(t, id)
+	assert.NoDirExists(t, target.Path)
+}
+
+func TestTrashDeleter_Retention(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	target := makeTargetDir(t, filepath.Join(tmpDir, "target"))
+	deleter := &TrashDeleter{Trash: trashSystem, Retention: 30 * 24 * time.Hour}
+	id, err := deleter.Delete(context.Background(), target)
+	require.NoError(t, err)
+
+	metadata, err := trashSystem.GetMetadata(id)
+	require.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, metadata.Retention)
+}
+
+func TestDirectDeleter(t *testing.T) {
+	target := makeTargetDir(t, filepath.Join(t.TempDir(), "target"))
+
+	id, err := (&DirectDeleter{}).Delete(context.Background(), target)
+	require.NoError(t, err)
+	assert.Empty(t, id)
+	assert.NoDirExists(t, target.Path)
+}
+
+func TestShredDeleter(t *testing.T) {
+	target := makeTargetDir(t, filepath.Join(t.TempDir(), "target"))
+
+	id, err := (&ShredDeleter{Passes: 2}).Delete(context.Background(), target)
+	require.NoError(t, err)
+	assert.Empty(t, id)
+	assert.NoDirExists(t, target.Path)
+}
+
+func TestShredFile_OverwritesContents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	original := []byte("secret data")
+	require.NoError(t, os.WriteFile(path, original, 0644))
+
+	require.NoError(t, shredFile(path, 1))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, data, len(original))
+	assert.NotEqual(t, original, data)
+}
+
+func TestDryRunDeleter(t *testing.T) {
+	target := makeTargetDir(t, filepath.Join(t.TempDir(), "target"))
+
+	id, err := (&DryRunDeleter{}).Delete(context.Background(), target)
+	require.NoError(t, err)
+	assert.Empty(t, id)
+	assert.DirExists(t, target.Path)
+}
+
+func TestCleaner_Clean_CustomDeleter(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	target := makeTargetDir(t, filepath.Join(tmpDir, "target"))
+	deleter := &recordingDeleter{}
+
+	report, err := New(trashSystem).Clean(context.Background(), []types.Target{target}, CleanOptions{
+		UseTrash: true,
+		Deleter:  deleter,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{target.Path}, deleter.deleted)
+	assert.Equal(t, 1, report.FilesDeleted)
+	assert.Empty(t, report.TrashedItems)
+	assert.DirExists(t, target.Path)
+}
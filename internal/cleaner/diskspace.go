@@ -0,0 +1,77 @@
+package cleaner
+
+import (
+	"path/filepath"
+
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// measureFreeSpace returns the free space of the filesystem of a path. Tests
+// replace it to control the measures.
+var measureFreeSpace = fsutils.FreeSpace
+
+// FreeSpaceSnapshot records the free space of every filesystem touched by a
+// set of targets, keyed by filesystem ID.
+//
+// Comparing a snapshot taken before cleaning with the current free space gives
+// the space actually reclaimed, which can differ a lot from the sum of target
+// sizes when hardlinks or sparse files are involved.
+type FreeSpaceSnapshot struct {
+	paths map[string]string // Filesystem ID -> path used to measure it
+	free  map[string]uint64 // Filesystem ID -> free bytes at snapshot time
+}
+
+// SnapshotFreeSpace measures the free space of the filesystems containing targets.
+// Filesystems that cannot be measured are left out of the snapshot.
+func SnapshotFreeSpace(targets []types.Target) *FreeSpaceSnapshot {
+	snapshot := &FreeSpaceSnapshot{
+		paths: make(map[string]string),
+		free:  make(map[string]uint64),
+	}
+
+	for _, target := range targets {
+		// Measure the parent, which still exists after the target is removed
+		dir := filepath.Dir(target.Path)
+
+		id, err := fsutils.FilesystemID(dir)
+		if err != nil {
+			logger.Debug("Skipping free space measurement for %s: %v", dir, err)
+			continue
+		}
+		if _, seen := snapshot.paths[id]; seen {
+			continue
+		}
+
+		free, err := measureFreeSpace(dir)
+		if err != nil {
+			logger.Debug("Skipping free space measurement for %s: %v", dir, err)
+			continue
+		}
+
+		snapshot.paths[id] = dir
+		snapshot.free[id] = free
+	}
+
+	return snapshot
+}
+
+// Reclaimed returns the number of bytes freed since the snapshot was taken,
+// summed over all measured filesystems. Filesystems whose free space shrank
+// (e.g. because of unrelated writes) count as zero.
+func (s *FreeSpaceSnapshot) Reclaimed() int64 {
+	var reclaimed int64
+	for id, dir := range s.paths {
+		free, err := measureFreeSpace(dir)
+		if err != nil {
+			logger.Debug("Failed to measure free space for %s: %v", dir, err)
+			continue
+		}
+
+		if before := s.free[id]; free > before {
+			reclaimed += int64(free - before)
+		}
+	}
+	return reclaimed
+}
@@ -0,0 +1,149 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// SudoDeleter deletes targets permanently through an elevation command such
+// as sudo. It is meant for artifacts owned by another user, typically files
+// created as root inside Docker containers.
+//
+// Only the removal is elevated: the command runs Helper, a fixed program
+// such as rosia's elevated-remove command, which checks the protected paths
+// again before removing the target. Everything else happens unprivileged in
+// the Cleaner, see RetryElevated. The command inherits the terminal so it
+// can prompt for a password.
+type SudoDeleter struct {
+	Command   []string // Elevation command and arguments (default: sudo)
+	Helper    []string // Program and arguments removing the path given after them
+	Protected []string // Paths the helper refuses to remove, like CleanOptions.ProtectedPaths
+}
+
+// Delete runs the helper on target through the elevation command
+func (d *SudoDeleter) Delete(ctx context.Context, target types.Target) (string, error) {
+	if runtime.GOOS == "windows" {
+		return "", fmt.Errorf("elevated deletion is not supported on windows")
+	}
+	if len(d.Helper) == 0 {
+		return "", fmt.Errorf("elevated deletion requires a remove helper")
+	}
+
+	command := d.Command
+	if len(command) == 0 {
+		command = []string{"sudo"}
+	}
+
+	// The helper may run with another home directory, so the user's is given
+	args := slices.Concat(command[1:], d.Helper)
+	if home, err := os.UserHomeDir(); err == nil {
+		args = append(args, "--home", home)
+	}
+	for _, path := range d.Protected {
+		args = append(args, "--protect", path)
+	}
+	args = append(args, "--", target.Path)
+
+	cmd := exec.CommandContext(ctx, command[0], args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("elevated delete failed: %w", err)
+	}
+	logger.Debug("Deleted %s with %s", target.Path, command[0])
+	return "", nil
+}
+
+// RemoveElevated removes path for the remove helper of SudoDeleter. It runs
+// with more permissions than the checks made before it, so it checks again
+// that path is neither protected, home being the home directory of the user,
+// nor a symlink, which would be followed as the other user.
+func RemoveElevated(path, home string, protected []string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("refusing to remove relative path %s", path)
+	}
+	path = filepath.Clean(path)
+
+	guard := fsutils.NewProtected(protected...)
+	if home != "" {
+		guard.AddRoot(home)
+	}
+	if err := guard.Check(path); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return types.ErrPathNotFound{Path: path}
+		}
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("refusing to remove %s: it is a symlink", path)
+	}
+
+	return os.RemoveAll(path)
+}
+
+// IsPermissionError reports whether err was caused by missing permissions
+func IsPermissionError(err error) bool {
+	var denied types.ErrPermissionDenied
+	return errors.As(err, &denied) || errors.Is(err, os.ErrPermission)
+}
+
+// RetryElevated retries the targets in report that failed with a permission
+// error, removing them with deleter, and records the outcome in the same
+// report. The retries go through the same checks, hooks and kept paths as
+// opts, only the permission check, which they failed, is left out. Targets
+// deleted this way are removed permanently, even when the trash was used.
+// It returns the number of targets retried.
+func (c *Cleaner) RetryElevated(ctx context.Context, report *types.CleanReport, deleter Deleter, opts CleanOptions) int {
+	failed := report.TakeErrors(func(cleanErr types.CleanError) bool {
+		return CanRetryElevated(cleanErr)
+	})
+
+	opts.Deleter = deleter
+	opts.elevated = true
+	// Retries outside Clean and CleanAsync take their own process snapshot
+	if opts.processes == nil && !opts.IgnoreRunning && len(failed) > 0 {
+		opts.processes = snapshotProcesses()
+	}
+	for _, cleanErr := range failed {
+		target := cleanErr.Target
+		if ctx.Err() != nil {
+			report.AddError(target, cleanErr.Error)
+			continue
+		}
+
+		logger.Info("Retrying %s with elevated permissions", target.Path)
+		result, err := c.cleanTarget(ctx, target, opts)
+		if err != nil {
+			logger.Error("Elevated delete of %s failed: %v", target.Path, err)
+			report.AddError(target, fmt.Errorf("%w (elevated retry: %v)", cleanErr.Error, err))
+			continue
+		}
+		report.AddSuccess(result.Target, "")
+	}
+
+	return len(failed)
+}
+
+// CanRetryElevated reports whether the target of cleanErr may be retried by
+// RetryElevated: it failed with a permission error and is not virtual, since
+// plugins delete those themselves
+func CanRetryElevated(cleanErr types.CleanError) bool {
+	return !cleanErr.Target.Virtual && IsPermissionError(cleanErr.Error)
+}
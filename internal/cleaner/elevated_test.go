@@ -0,0 +1,195 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPermissionError(t *testing.T) {
+	assert.True(t, IsPermissionError(types.ErrPermissionDenied{Path: "/a"}))
+	assert.True(t, IsPermissionError(fmt.Errorf("failed to delete: %w", os.ErrPermission)))
+	assert.True(t, IsPermissionError(&os.PathError{Op: "unlinkat", Path: "/a", Err: os.ErrPermission}))
+	assert.False(t, IsPermissionError(types.ErrPathNotFound{Path: "/a"}))
+	assert.False(t, IsPermissionError(errors.New("boom")))
+}
+
+func TestCleaner_RetryElevated(t *testing.T) {
+	report := types.NewCleanReport()
+	denied := types.Target{Path: "/project/target", Size: 10, ProfileName: "Rust"}
+	missing := types.Target{Path: "/project/dist", Size: 5, ProfileName: "Node.js"}
+	report.AddError(denied, types.ErrPermissionDenied{Path: denied.Path})
+	report.AddError(missing, types.ErrPathNotFound{Path: missing.Path})
+
+	deleter := &recordingDeleter{}
+	retried := New(nil).RetryElevated(context.Background(), report, deleter, CleanOptions{})
+
+	assert.Equal(t, 1, retried)
+	assert.Equal(t, []string{denied.Path}, deleter.deleted)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, missing.Path, report.Errors[0].Target.Path)
+	assert.Equal(t, 1, report.FilesDeleted)
+	assert.Equal(t, 0, report.Profiles["Rust"].Errors)
+	assert.Equal(t, 1, report.Profiles["Rust"].Targets)
+}
+
+func TestCleaner_RetryElevated_Failure(t *testing.T) {
+	report := types.NewCleanReport()
+	denied := types.Target{Path: "/project/target"}
+	report.AddError(denied, types.ErrPermissionDenied{Path: denied.Path})
+
+	deleter := &SudoDeleter{Command: []string{"false"}, Helper: []string{"rosia", "elevated-remove"}}
+	New(nil).RetryElevated(context.Background(), report, deleter, CleanOptions{})
+
+	require.Len(t, report.Errors, 1)
+	assert.True(t, IsPermissionError(report.Errors[0].Error))
+	assert.Contains(t, report.Errors[0].Error.Error(), "elevated retry")
+	assert.Equal(t, 0, report.FilesDeleted)
+}
+
+func TestCleaner_RetryElevated_Checks(t *testing.T) {
+	tmpDir := t.TempDir()
+	protected := makeTargetDir(t, filepath.Join(tmpDir, "vendor", "target"))
+	kept := makeTargetDir(t, filepath.Join(tmpDir, "app", "target"))
+	require.NoError(t, os.WriteFile(filepath.Join(kept.Path, "CACHEDIR.TAG"), []byte("x"), 0644))
+	virtual := types.Target{Path: "docker://images/abc", Virtual: true, Source: "docker"}
+
+	report := types.NewCleanReport()
+	for _, target := range []types.Target{protected, kept, virtual} {
+		report.AddError(target, types.ErrPermissionDenied{Path: target.Path})
+	}
+
+	var hooked []string
+	opts := CleanOptions{
+		ProtectedPaths: []string{filepath.Join(tmpDir, "vendor")},
+		KeepPatterns:   []string{"CACHEDIR.TAG"},
+		Hooks: Hooks{PreClean: []HookFunc{func(ctx context.Context, target types.Target) error {
+			hooked = append(hooked, target.Path)
+			return nil
+		}}},
+	}
+	retried := New(nil).RetryElevated(context.Background(), report, &DirectDeleter{}, opts)
+
+	// Virtual targets are left to their plugin, protected paths are refused
+	// and kept paths survive the elevated removal
+	assert.Equal(t, 2, retried)
+	assert.DirExists(t, protected.Path)
+	assert.Equal(t, []string{kept.Path}, hooked)
+	assert.FileExists(t, filepath.Join(kept.Path, "CACHEDIR.TAG"))
+	assert.NoDirExists(t, filepath.Join(kept.Path, "sub"))
+
+	require.Len(t, report.Errors, 2)
+	assert.Equal(t, virtual.Path, report.Errors[0].Target.Path)
+	assert.Equal(t, protected.Path, report.Errors[1].Target.Path)
+	assert.ErrorContains(t, report.Errors[1].Error, "protected path")
+	assert.Equal(t, 1, report.FilesDeleted)
+}
+
+func TestRemoveElevated(t *testing.T) {
+	tmpDir := t.TempDir()
+	home := filepath.Join(tmpDir, "home")
+	target := makeTargetDir(t, filepath.Join(home, "app", "target"))
+	require.NoError(t, os.Symlink(target.Path, filepath.Join(tmpDir, "link")))
+
+	assert.Error(t, RemoveElevated("app/target", home, nil), "relative paths are refused")
+	assert.ErrorAs(t, RemoveElevated(home, home, nil), &types.ErrProtectedPath{})
+	assert.ErrorAs(t, RemoveElevated(target.Path, home, []string{filepath.Join(home, "app")}), &types.ErrProtectedPath{})
+	assert.ErrorContains(t, RemoveElevated(filepath.Join(tmpDir, "link"), home, nil), "symlink")
+	assert.ErrorAs(t, RemoveElevated(filepath.Join(tmpDir, "missing"), home, nil), &types.ErrPathNotFound{})
+	assert.DirExists(t, target.Path)
+
+	require.NoError(t, RemoveElevated(target.Path, home, nil))
+	assert.NoDirExists(t, target.Path)
+}
+
+// TestElevatedHelper is the remove helper of TestSudoDeleter_Command, run by
+// the test binary itself
+func TestElevatedHelper(t *testing.T) {
+	if os.Getenv("ROSIA_ELEVATED_HELPER") != "1" {
+		t.Skip("only run as a helper")
+	}
+
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	var home string
+	var protected []string
+	for len(args) > 1 && args[0] != "--" {
+		switch args[0] {
+		case "--home":
+			home = args[1]
+		case "--protect":
+			protected = append(protected, args[1])
+		}
+		args = args[2:]
+	}
+	if err := RemoveElevated(args[len(args)-1], home, protected); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestSudoDeleter_Command(t *testing.T) {
+	if _, err := os.Stat("/usr/bin/env"); err != nil {
+		t.Skip("env is not available")
+	}
+	t.Setenv("ROSIA_ELEVATED_HELPER", "1")
+
+	tmpDir := t.TempDir()
+	dir := makeTargetDir(t, filepath.Join(tmpDir, "target")).Path
+	protected := makeTargetDir(t, filepath.Join(tmpDir, "protected")).Path
+
+	// env stands in for sudo and the test binary for the helper
+	deleter := &SudoDeleter{
+		Command:   []string{"env"},
+		Helper:    []string{os.Args[0], "-test.run=^TestElevatedHelper$", "--"},
+		Protected: []string{protected},
+	}
+	_, err := deleter.Delete(context.Background(), types.Target{Path: dir})
+	require.NoError(t, err)
+	assert.NoDirExists(t, dir)
+
+	// The helper checks the protected paths again
+	_, err = deleter.Delete(context.Background(), types.Target{Path: protected})
+	assert.Error(t, err)
+	assert.DirExists(t, protected)
+}
+
+// deniedDeleter fails every target with a permission error
+type deniedDeleter struct{}
+
+func (d deniedDeleter) Delete(ctx context.Context, target types.Target) (string, error) {
+	return "", types.ErrPermissionDenied{Path: target.Path}
+}
+
+func TestCleaner_CleanAsync_Elevate(t *testing.T) {
+	target := makeTargetDir(t, filepath.Join(t.TempDir(), "target"))
+	target.ProfileName = "Rust"
+
+	elevated := &recordingDeleter{}
+	var asked []int
+	progressCh, err := New(nil).CleanAsync(context.Background(), []types.Target{target}, CleanOptions{
+		Deleter: deniedDeleter{},
+		Elevate: func(failed int) Deleter {
+			asked = append(asked, failed)
+			return elevated
+		},
+	})
+	require.NoError(t, err)
+	report := GenerateReportFromProgress(progressCh, time.Now())
+
+	// The target failing with a permission error is retried before the
+	// report is finished
+	assert.Equal(t, []int{1}, asked)
+	assert.Equal(t, []string{target.Path}, elevated.deleted)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 1, report.FilesDeleted)
+}
@@ -0,0 +1,141 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// HookFunc is called before or after a single target is cleaned.
+// An error returned by a pre-clean hook causes the target to be skipped.
+type HookFunc func(ctx context.Context, target types.Target) error
+
+// BatchHookFunc is called once before or after a batch of targets is cleaned.
+// An error returned by a pre-batch hook aborts the whole operation.
+type BatchHookFunc func(ctx context.Context, targets []types.Target) error
+
+// Hooks groups the callbacks that run around a clean operation.
+type Hooks struct {
+	PreClean  []HookFunc
+	PostClean []HookFunc
+	PreBatch  []BatchHookFunc
+	PostBatch []BatchHookFunc
+}
+
+// DefaultHookTimeout is how long a pre- or post-clean hook may run. Targets
+// are finished even once the clean is cancelled, so a hung hook would
+// otherwise hold the clean forever.
+const DefaultHookTimeout = 5 * time.Minute
+
+// Environment variables exposed to shell hooks
+const (
+	EnvTargetPath    = "ROSIA_TARGET_PATH"
+	EnvTargetProfile = "ROSIA_TARGET_PROFILE"
+	EnvTargetSize    = "ROSIA_TARGET_SIZE"
+	EnvTargetCount   = "ROSIA_TARGET_COUNT"
+	EnvTargetPaths   = "ROSIA_TARGET_PATHS"
+)
+
+// NewShellHooks builds Hooks that run the given shell commands
+func NewShellHooks(preClean, postClean, preBatch, postBatch []string) Hooks {
+	var hooks Hooks
+	for _, command := range preClean {
+		hooks.PreClean = append(hooks.PreClean, ShellHook(command))
+	}
+	for _, command := range postClean {
+		hooks.PostClean = append(hooks.PostClean, ShellHook(command))
+	}
+	for _, command := range preBatch {
+		hooks.PreBatch = append(hooks.PreBatch, ShellBatchHook(command))
+	}
+	for _, command := range postBatch {
+		hooks.PostBatch = append(hooks.PostBatch, ShellBatchHook(command))
+	}
+	return hooks
+}
+
+// ShellHook returns a HookFunc that runs command through the system shell.
+// The target path, profile and size are exposed as ROSIA_TARGET_* variables.
+func ShellHook(command string) HookFunc {
+	return func(ctx context.Context, target types.Target) error {
+		return runShell(ctx, command, []string{
+			EnvTargetPath + "=" + target.Path,
+			EnvTargetProfile + "=" + target.ProfileName,
+			EnvTargetSize + "=" + strconv.FormatInt(target.Size, 10),
+		})
+	}
+}
+
+// ShellBatchHook returns a BatchHookFunc that runs command through the system shell.
+// The number of targets and their paths (joined with the OS list separator) are
+// exposed as ROSIA_TARGET_COUNT and ROSIA_TARGET_PATHS.
+func ShellBatchHook(command string) BatchHookFunc {
+	return func(ctx context.Context, targets []types.Target) error {
+		paths := make([]string, 0, len(targets))
+		for _, target := range targets {
+			paths = append(paths, target.Path)
+		}
+		return runShell(ctx, command, []string{
+			EnvTargetCount + "=" + strconv.Itoa(len(targets)),
+			EnvTargetPaths + "=" + strings.Join(paths, string(os.PathListSeparator)),
+		})
+	}
+}
+
+// runShell executes command with the platform shell and the extra environment
+func runShell(ctx context.Context, command string, env []string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hook command %q failed: %w", command, err)
+	}
+	return nil
+}
+
+// runHook runs a pre- or post-clean hook for target, stopping it after
+// timeout (0 = DefaultHookTimeout)
+func runHook(ctx context.Context, hook HookFunc, target types.Target, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return hook(ctx, target)
+}
+
+// runPreBatchHooks runs all pre-batch hooks, stopping at the first failure
+func runPreBatchHooks(ctx context.Context, hooks Hooks, targets []types.Target) error {
+	for _, hook := range hooks.PreBatch {
+		if err := hook(ctx, targets); err != nil {
+			return fmt.Errorf("pre-batch hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// runPostBatchHooks runs all post-batch hooks, returning the failures
+func runPostBatchHooks(ctx context.Context, hooks Hooks, targets []types.Target) []error {
+	var errs []error
+	for _, hook := range hooks.PostBatch {
+		if err := hook(ctx, targets); err != nil {
+			errs = append(errs, fmt.Errorf("post-batch hook failed: %w", err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,196 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/trash"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleaner_Hooks(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	keepDir := filepath.Join(tmpDir, "keep")
+	cleanDir := filepath.Join(tmpDir, "clean")
+	require.NoError(t, os.MkdirAll(keepDir, 0755))
+	require.NoError(t, os.MkdirAll(cleanDir, 0755))
+
+	targets := []types.Target{
+		{Path: keepDir, Size: 10, ProfileName: "test", IsDirectory: true},
+		{Path: cleanDir, Size: 20, ProfileName: "test", IsDirectory: true},
+	}
+
+	var calls []string
+	hooks := Hooks{
+		PreBatch: []BatchHookFunc{func(ctx context.Context, targets []types.Target) error {
+			calls = append(calls, "pre-batch")
+			return nil
+		}},
+		PreClean: []HookFunc{func(ctx context.Context, target types.Target) error {
+			calls = append(calls, "pre:"+filepath.Base(target.Path))
+			if target.Path == keepDir {
+				return errors.New("dev server still running")
+			}
+			return nil
+		}},
+		PostClean: []HookFunc{func(ctx context.Context, target types.Target) error {
+			calls = append(calls, "post:"+filepath.Base(target.Path))
+			return errors.New("post hooks never fail the target")
+		}},
+		PostBatch: []BatchHookFunc{func(ctx context.Context, targets []types.Target) error {
+			calls = append(calls, "post-batch")
+			return nil
+		}},
+	}
+
+	report, err := New(trashSystem).Clean(context.Background(), targets, CleanOptions{
+		UseTrash: false,
+		Hooks:    hooks,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"pre-batch", "pre:keep", "pre:clean", "post:clean", "post-batch"}, calls)
+	assert.Equal(t, 1, report.FilesDeleted)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0].Error.Error(), "pre-clean hook failed")
+
+	_, err = os.Stat(keepDir)
+	assert.NoError(t, err, "target rejected by pre-clean hook should remain")
+}
+
+func TestCleaner_PreBatchHookAborts(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	targetDir := filepath.Join(tmpDir, "target")
+	require.NoError(t, os.MkdirAll(targetDir, 0755))
+	targets := []types.Target{{Path: targetDir, ProfileName: "test", IsDirectory: true}}
+
+	hooks := Hooks{PreBatch: []BatchHookFunc{func(ctx context.Context, targets []types.Target) error {
+		return errors.New("refusing")
+	}}}
+
+	cleaner := New(trashSystem)
+
+	report, err := cleaner.Clean(context.Background(), targets, CleanOptions{Hooks: hooks})
+	assert.Error(t, err)
+	assert.Equal(t, 0, report.FilesDeleted)
+
+	_, err = cleaner.CleanAsync(context.Background(), targets, CleanOptions{Hooks: hooks})
+	assert.Error(t, err)
+
+	_, err = os.Stat(targetDir)
+	assert.NoError(t, err)
+}
+
+func TestShellHook_Environment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell hook test uses sh syntax")
+	}
+
+	tmpDir := t.TempDir()
+	outFile := filepath.Join(tmpDir, "out.txt")
+
+	hook := ShellHook(`printf "%s|%s|%s" "$ROSIA_TARGET_PATH" "$ROSIA_TARGET_PROFILE" "$ROSIA_TARGET_SIZE" > ` + outFile)
+	err := hook(context.Background(), types.Target{Path: "/tmp/app/build", ProfileName: "Node.js", Size: 42})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(outFile)
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/app/build|Node.js|42", string(data))
+
+	failing := ShellHook("exit 3")
+	assert.Error(t, failing(context.Background(), types.Target{}))
+}
+
+func TestCleaner_PostBatchHookRunsAfterCancel(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		tmpDir := t.TempDir()
+		trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+		require.NoError(t, err)
+
+		var targets []types.Target
+		for _, name := range []string{"a", "b", "c"} {
+			targetDir := filepath.Join(tmpDir, name)
+			require.NoError(t, os.MkdirAll(targetDir, 0755))
+			targets = append(targets, types.Target{Path: targetDir, Size: 10, ProfileName: "test", IsDirectory: true})
+		}
+
+		// Ctrl-C while the first target is cleaned
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var postBatchErr error
+		postBatch := false
+		hooks := Hooks{
+			PreClean: []HookFunc{func(ctx context.Context, target types.Target) error {
+				cancel()
+				return nil
+			}},
+			PostBatch: []BatchHookFunc{func(ctx context.Context, targets []types.Target) error {
+				postBatch = true
+				postBatchErr = ctx.Err()
+				return nil
+			}},
+		}
+
+		opts := CleanOptions{UseTrash: true, Concurrency: 1, Hooks: hooks}
+		var report *types.CleanReport
+		if async {
+			progressCh, err := New(trashSystem).CleanAsync(ctx, targets, opts)
+			require.NoError(t, err)
+			report = GenerateReportFromProgress(progressCh, time.Now())
+		} else {
+			report, err = New(trashSystem).Clean(ctx, targets, opts)
+			assert.ErrorIs(t, err, context.Canceled)
+		}
+
+		assert.Equal(t, 1, report.FilesDeleted, "async: %v", async)
+		assert.Len(t, report.Skipped, 2, "async: %v", async)
+		assert.True(t, postBatch, "async: %v: expected post-batch hooks to run", async)
+		assert.NoError(t, postBatchErr, "async: %v: expected post-batch hooks to get a usable context", async)
+	}
+}
+
+func TestCleaner_PostBatchHookRunsAfterRollback(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+	require.NoError(t, err)
+
+	cleanDir := filepath.Join(tmpDir, "clean")
+	require.NoError(t, os.MkdirAll(cleanDir, 0755))
+	targets := []types.Target{
+		{Path: cleanDir, ProfileName: "test", IsDirectory: true},
+		{Path: filepath.Join(tmpDir, "missing"), ProfileName: "test", IsDirectory: true},
+	}
+
+	postBatch := false
+	hooks := Hooks{PostBatch: []BatchHookFunc{func(ctx context.Context, targets []types.Target) error {
+		postBatch = true
+		return nil
+	}}}
+
+	_, err = New(trashSystem).Clean(context.Background(), targets, CleanOptions{UseTrash: true, Atomic: true, Hooks: hooks})
+	assert.ErrorContains(t, err, "atomic clean rolled back")
+	assert.True(t, postBatch, "expected post-batch hooks to run after a rollback")
+	assert.DirExists(t, cleanDir)
+}
+
+func TestRunHook_Timeout(t *testing.T) {
+	hook := func(ctx context.Context, target types.Target) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	err := runHook(context.Background(), hook, types.Target{}, 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,181 @@
+package cleaner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// keptPaths holds the paths moved out of a target before it is cleaned so
+// they can be put back afterwards.
+type keptPaths struct {
+	targetPath string
+	stashDir   string
+	relPaths   []string
+}
+
+// stashKept moves every path inside target matching one of the keep patterns
+// into a temporary directory next to the target. Patterns are globs relative
+// to the target path (e.g. ".cache/turbo", "CACHEDIR.TAG").
+//
+// It returns nil when nothing needs to be preserved.
+func stashKept(target types.Target, patterns []string) (*keptPaths, error) {
+	if !target.IsDirectory || len(patterns) == 0 {
+		return nil, nil
+	}
+
+	var matched []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(target.Path, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid keep pattern '%s': %w", pattern, err)
+		}
+		for _, match := range matches {
+			if rel, err := filepath.Rel(target.Path, match); err == nil && rel != "." {
+				matched = append(matched, rel)
+			}
+		}
+	}
+
+	// Sorting puts parents before their children, which are then skipped
+	// because they move along with the parent
+	sort.Strings(matched)
+	var relPaths []string
+	selected := make(map[string]bool)
+	for _, rel := range matched {
+		if selected[rel] || isKeptParent(selected, rel) {
+			continue
+		}
+		selected[rel] = true
+		relPaths = append(relPaths, rel)
+	}
+
+	if len(relPaths) == 0 {
+		return nil, nil
+	}
+
+	// Stash next to the target so the moves stay on the same filesystem
+	stashDir, err := os.MkdirTemp(filepath.Dir(target.Path), ".rosia-keep-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create keep directory: %w", err)
+	}
+
+	kept := &keptPaths{targetPath: target.Path, stashDir: stashDir}
+	for _, rel := range relPaths {
+		dst := filepath.Join(stashDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			kept.restore()
+			return nil, fmt.Errorf("failed to preserve %s: %w", rel, err)
+		}
+		if err := os.Rename(filepath.Join(target.Path, rel), dst); err != nil {
+			kept.restore()
+			return nil, fmt.Errorf("failed to preserve %s: %w", rel, err)
+		}
+		kept.relPaths = append(kept.relPaths, rel)
+		logger.Debug("Preserving %s inside %s", rel, target.Path)
+	}
+
+	return kept, nil
+}
+
+// isKeptParent reports whether one of the already selected paths contains rel
+func isKeptParent(selected map[string]bool, rel string) bool {
+	for dir := filepath.Dir(rel); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if selected[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// restore moves the preserved paths back into the target, recreating any
+// missing parent directories. The stash directory is removed once empty.
+func (k *keptPaths) restore() error {
+	if k == nil {
+		return nil
+	}
+
+	var failed []string
+	for _, rel := range k.relPaths {
+		dst := filepath.Join(k.targetPath, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			failed = append(failed, rel)
+			continue
+		}
+		if err := os.Rename(filepath.Join(k.stashDir, rel), dst); err != nil {
+			failed = append(failed, rel)
+			continue
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restore kept paths %v (left in %s)", failed, k.stashDir)
+	}
+
+	return os.RemoveAll(k.stashDir)
+}
+
+// restoreAroundKept runs restore, which brings a trashed target back to path,
+// when the kept paths of the target may already be back there. They are moved
+// aside while restore runs, then put back into the restored tree.
+func restoreAroundKept(path string, restore func() error) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return restore()
+	}
+
+	asideDir, err := os.MkdirTemp(filepath.Dir(path), ".rosia-keep-")
+	if err != nil {
+		return fmt.Errorf("failed to create keep directory: %w", err)
+	}
+	aside := filepath.Join(asideDir, filepath.Base(path))
+	if err := os.Rename(path, aside); err != nil {
+		os.Remove(asideDir)
+		return fmt.Errorf("failed to move kept paths aside: %w", err)
+	}
+
+	if err := restore(); err != nil {
+		if renameErr := os.Rename(aside, path); renameErr != nil {
+			return fmt.Errorf("%w (kept paths left in %s)", err, asideDir)
+		}
+		os.Remove(asideDir)
+		return err
+	}
+
+	if err := mergeKept(aside, path); err != nil {
+		return fmt.Errorf("failed to restore kept paths (left in %s): %w", asideDir, err)
+	}
+	return os.RemoveAll(asideDir)
+}
+
+// mergeKept moves the entries of src into dst, descending into the
+// directories both contain
+func mergeKept(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		from := filepath.Join(src, entry.Name())
+		to := filepath.Join(dst, entry.Name())
+		info, err := os.Lstat(to)
+		switch {
+		case os.IsNotExist(err):
+			if err := os.Rename(from, to); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case entry.IsDir() && info.IsDir():
+			if err := mergeKept(from, to); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s already exists", to)
+		}
+	}
+	return nil
+}
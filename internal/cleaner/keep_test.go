@@ -0,0 +1,64 @@
+package cleaner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/internal/trash"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleaner_Clean_KeepPatterns(t *testing.T) {
+	for _, useTrash := range []bool{true, false} {
+		t.Run(map[bool]string{true: "with trash", false: "without trash"}[useTrash], func(t *testing.T) {
+			tmpDir := t.TempDir()
+			trashSystem, err := trash.NewSystem(filepath.Join(tmpDir, "trash"))
+			require.NoError(t, err)
+
+			targetDir := filepath.Join(tmpDir, "node_modules")
+			require.NoError(t, os.MkdirAll(filepath.Join(targetDir, ".cache", "turbo", "nested"), 0755))
+			require.NoError(t, os.MkdirAll(filepath.Join(targetDir, "left-pad"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(targetDir, ".cache", "turbo", "nested", "hash"), []byte("x"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(targetDir, ".cache", "other"), []byte("x"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(targetDir, "left-pad", "index.js"), []byte("x"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(targetDir, "CACHEDIR.TAG"), []byte("x"), 0644))
+
+			target := types.Target{
+				Path:        targetDir,
+				ProfileName: "test",
+				IsDirectory: true,
+				Keep:        []string{".cache/turbo", ".cache/turbo/nested"},
+			}
+
+			report, err := New(trashSystem).Clean(context.Background(), []types.Target{target}, CleanOptions{
+				UseTrash:     useTrash,
+				KeepPatterns: []string{"CACHEDIR.*", "missing"},
+			})
+			require.NoError(t, err)
+			assert.Empty(t, report.Errors)
+
+			assert.FileExists(t, filepath.Join(targetDir, ".cache", "turbo", "nested", "hash"))
+			assert.FileExists(t, filepath.Join(targetDir, "CACHEDIR.TAG"))
+			assert.NoFileExists(t, filepath.Join(targetDir, ".cache", "other"))
+			assert.NoDirExists(t, filepath.Join(targetDir, "left-pad"))
+
+			// No stash directories are left behind
+			matches, err := filepath.Glob(filepath.Join(tmpDir, ".rosia-keep-*"))
+			require.NoError(t, err)
+			assert.Empty(t, matches)
+		})
+	}
+}
+
+func TestStashKept_NothingToKeep(t *testing.T) {
+	targetDir := t.TempDir()
+
+	kept, err := stashKept(types.Target{Path: targetDir, IsDirectory: true}, []string{"missing"})
+	require.NoError(t, err)
+	assert.Nil(t, kept)
+	assert.NoError(t, kept.restore())
+}
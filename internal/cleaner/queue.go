@@ -0,0 +1,111 @@
+package cleaner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// QueueEntry is a target waiting in the deferred clean queue
+type QueueEntry struct {
+	Target   types.Target `json:"target"`    // Target to clean
+	QueuedAt time.Time    `json:"queued_at"` // When the target was queued
+}
+
+// Queue holds targets selected for cleaning at a later time, e.g. overnight.
+//
+// The queue is persisted as JSON and is not safe for concurrent use.
+type Queue struct {
+	Entries []QueueEntry `json:"entries"`
+
+	path string
+}
+
+// GetDefaultQueuePath returns the default path for the deferred clean queue
+func GetDefaultQueuePath() (string, error) {
+	location, err := fsutils.QueueLocation()
+	if err != nil {
+		return "", err
+	}
+	return location.Path(), nil
+}
+
+// LoadQueue reads the queue stored at path. A missing file is an empty queue.
+func LoadQueue(path string) (*Queue, error) {
+	queue := &Queue{Entries: []QueueEntry{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return queue, nil
+		}
+		return nil, fmt.Errorf("failed to read clean queue %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, queue); err != nil {
+		return nil, fmt.Errorf("failed to parse clean queue %s: %w", path, err)
+	}
+
+	return queue, nil
+}
+
+// Add appends targets to the queue, replacing entries with the same path.
+// It returns the number of targets that were not queued yet.
+func (q *Queue) Add(targets []types.Target) int {
+	index := make(map[string]int, len(q.Entries))
+	for i, entry := range q.Entries {
+		index[entry.Target.Path] = i
+	}
+
+	added := 0
+	now := time.Now()
+	for _, target := range targets {
+		entry := QueueEntry{Target: target, QueuedAt: now}
+		if i, exists := index[target.Path]; exists {
+			q.Entries[i] = entry
+			continue
+		}
+		index[target.Path] = len(q.Entries)
+		q.Entries = append(q.Entries, entry)
+		added++
+	}
+
+	return added
+}
+
+// Targets returns the queued targets in the order they were queued
+func (q *Queue) Targets() []types.Target {
+	targets := make([]types.Target, 0, len(q.Entries))
+	for _, entry := range q.Entries {
+		targets = append(targets, entry.Target)
+	}
+	return targets
+}
+
+// Clear removes all entries from the queue
+func (q *Queue) Clear() {
+	q.Entries = []QueueEntry{}
+}
+
+// Save writes the queue to disk
+func (q *Queue) Save() error {
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal clean queue: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return fmt.Errorf("failed to create clean queue directory: %w", err)
+	}
+
+	if err := os.WriteFile(q.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write clean queue %s: %w", q.path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package cleaner
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_AddSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+
+	// A missing queue file is an empty queue
+	queue, err := LoadQueue(path)
+	require.NoError(t, err)
+	assert.Empty(t, queue.Entries)
+
+	added := queue.Add([]types.Target{{Path: "/a", Size: 1}, {Path: "/b", Size: 2}})
+	assert.Equal(t, 2, added)
+
+	// Re-queuing a path updates it instead of duplicating it
+	added = queue.Add([]types.Target{{Path: "/a", Size: 10}, {Path: "/c", Size: 3}})
+	assert.Equal(t, 1, added)
+	require.NoError(t, queue.Save())
+
+	loaded, err := LoadQueue(path)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Target{
+		{Path: "/a", Size: 10},
+		{Path: "/b", Size: 2},
+		{Path: "/c", Size: 3},
+	}, loaded.Targets())
+
+	loaded.Clear()
+	require.NoError(t, loaded.Save())
+
+	emptied, err := LoadQueue(path)
+	require.NoError(t, err)
+	assert.Empty(t, emptied.Targets())
+}
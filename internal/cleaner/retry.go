@@ -0,0 +1,82 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+)
+
+// Default retry settings used when a RetryPolicy leaves a field unset
+const (
+	DefaultRetryBackoff    = 100 * time.Millisecond
+	DefaultRetryMaxBackoff = 2 * time.Second
+)
+
+// RetryPolicy controls how often a failed delete is retried before the
+// target is recorded as an error.
+//
+// Transient failures are common on Windows, where antivirus scanners and
+// indexers briefly hold handles on files that are being removed. The delay
+// between attempts starts at Backoff and doubles up to MaxBackoff. Only such
+// transient failures are retried, see transient; a missing, protected or
+// forbidden path fails at once.
+type RetryPolicy struct {
+	MaxRetries int           // Extra attempts after the first failure (0 = no retry)
+	Backoff    time.Duration // Delay before the first retry
+	MaxBackoff time.Duration // Upper bound for the delay between retries
+}
+
+// do runs op until it succeeds, fails with an error that is not transient,
+// the retries are exhausted or ctx is done. It returns the error of the last
+// attempt.
+func (p RetryPolicy) do(ctx context.Context, path string, op func() error) error {
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRetryMaxBackoff
+	}
+
+	err := op()
+	for attempt := 1; transient(err) && attempt <= p.MaxRetries; attempt++ {
+		logger.Debug("Retrying %s in %s (attempt %d/%d): %v", path, backoff, attempt, p.MaxRetries, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		err = op()
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return err
+}
+
+// transient reports whether err is a failure that may go away by itself: a
+// file busy or being executed, or on Windows held open by another process
+func transient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ETXTBSY) {
+		return true
+	}
+	for _, target := range platformTransientErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package cleaner
+
+// platformTransientErrors are the transient errors of the platform on top
+// of those transient checks everywhere; there are none here
+var platformTransientErrors []error
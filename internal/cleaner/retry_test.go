@@ -0,0 +1,101 @@
+package cleaner
+
+import (
+	"context"
+	"io/fs"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Do(t *testing.T) {
+	errBusy := &fs.PathError{Op: "unlinkat", Path: "target", Err: syscall.EBUSY}
+
+	t.Run("succeeds after transient failures", func(t *testing.T) {
+		attempts := 0
+		policy := RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond}
+
+		err := policy.do(context.Background(), "target", func() error {
+			attempts++
+			if attempts < 3 {
+				return errBusy
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("returns last error when retries are exhausted", func(t *testing.T) {
+		attempts := 0
+		policy := RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+
+		err := policy.do(context.Background(), "target", func() error {
+			attempts++
+			return errBusy
+		})
+
+		assert.ErrorIs(t, err, errBusy)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("zero policy does not retry", func(t *testing.T) {
+		attempts := 0
+		err := RetryPolicy{}.do(context.Background(), "target", func() error {
+			attempts++
+			return errBusy
+		})
+
+		assert.ErrorIs(t, err, errBusy)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("stops waiting when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		attempts := 0
+		policy := RetryPolicy{MaxRetries: 5, Backoff: time.Hour}
+
+		err := policy.do(ctx, "target", func() error {
+			attempts++
+			return errBusy
+		})
+
+		assert.ErrorIs(t, err, errBusy)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestRetryPolicy_DoPermanentErrors(t *testing.T) {
+	permanent := []error{
+		&fs.PathError{Op: "lstat", Path: "target", Err: syscall.ENOENT},
+		&fs.PathError{Op: "unlinkat", Path: "target", Err: syscall.EACCES},
+		&fs.PathError{Op: "rename", Path: "target", Err: syscall.EXDEV},
+		types.ErrPathNotFound{Path: "target"},
+		types.ErrProtectedPath{Path: "target", Protected: "/"},
+	}
+
+	// Failing at once rather than after the whole backoff schedule
+	policy := RetryPolicy{MaxRetries: 5, Backoff: time.Hour}
+	for _, permanentErr := range permanent {
+		attempts := 0
+		err := policy.do(context.Background(), "target", func() error {
+			attempts++
+			return permanentErr
+		})
+		assert.ErrorIs(t, err, permanentErr)
+		assert.Equal(t, 1, attempts, "%v was retried", permanentErr)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	assert.True(t, transient(&fs.PathError{Op: "unlinkat", Path: "target", Err: syscall.EBUSY}))
+	assert.True(t, transient(&fs.PathError{Op: "open", Path: "target", Err: syscall.ETXTBSY}))
+	assert.False(t, transient(nil))
+	assert.False(t, transient(&fs.PathError{Op: "unlinkat", Path: "target", Err: syscall.EPERM}))
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package cleaner
+
+import "golang.org/x/sys/windows"
+
+// platformTransientErrors are the errors of files another process, such as
+// an antivirus scanner or an indexer, holds open
+var platformTransientErrors = []error{
+	windows.ERROR_SHARING_VIOLATION,
+	windows.ERROR_LOCK_VIOLATION,
+}
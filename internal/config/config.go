@@ -4,6 +4,10 @@
 // including trash retention settings, enabled profiles, ignored paths, and performance
 // options. It provides sensible defaults when no configuration file exists.
 //
+// A system configuration, /etc/rosia/config.json, may hold settings shared by
+// every user, like ignore rules of an organization. LoadAndValidate merges the
+// user configuration over it.
+//
 // Example usage:
 //
 //	manager := config.NewManager("")
@@ -16,21 +20,99 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+	"github.com/raucheacho/rosia-cli/internal/profiles"
+	"github.com/raucheacho/rosia-cli/internal/sizecalc"
 )
 
+// legacyDefaultProfiles is the default of Config.Profiles from when the list
+// was not enforced
+var legacyDefaultProfiles = []string{"node", "python", "rust", "flutter", "go"}
+
 // Config represents user configuration loaded from ~/.rosiarc.json.
 type Config struct {
-	TrashRetentionDays int      `json:"trash_retention_days"` // Days to keep items in trash
-	Profiles           []string `json:"profiles"`             // Enabled profile names
-	IgnorePaths        []string `json:"ignore_paths"`         // Paths to exclude from scanning
-	Plugins            []string `json:"plugins"`              // Enabled plugin names
-	Concurrency        int      `json:"concurrency"`          // Worker pool size (0 = auto)
-	TelemetryEnabled   bool     `json:"telemetry_enabled"`    // Enable anonymous statistics
+	Version            int             `json:"version"`                   // Format version of the file, see CurrentVersion
+	TrashRetentionDays int             `json:"trash_retention_days"`      // Days to keep items in trash
+	Profiles           []string        `json:"profiles"`                  // Profiles to use, by name or file name like "node" (empty = all)
+	IgnorePaths        []string        `json:"ignore_paths"`              // Paths to exclude from scanning
+	ScanPaths          []string        `json:"scan_paths,omitempty"`      // Paths scanned by scan, clean and ui when none are given
+	ProtectedPaths     []string        `json:"protected_paths,omitempty"` // Paths never scanned or cleaned, with everything they contain, in addition to the built-in ones
+	Plugins            []string        `json:"plugins"`                   // Enabled plugin names
+	Concurrency        int             `json:"concurrency"`               // Worker pool size (0 = auto)
+	TelemetryEnabled   bool            `json:"telemetry_enabled"`         // Enable anonymous statistics
+	Hooks              HooksConfig     `json:"hooks"`                     // Shell commands run around cleaning
+	Retry              RetryConfig     `json:"retry"`                     // Retries for transient delete failures
+	KeepPatterns       []string        `json:"keep_patterns"`             // Paths inside every target to preserve
+	PermanentPatterns  []string        `json:"permanent_patterns"`        // Target names always deleted without trash
+	TrashCompression   bool            `json:"trash_compression"`         // Store trashed directories as tar.zst archives
+	TrashDir           string          `json:"trash_dir,omitempty"`       // Trash location (default: see trash.DefaultDir)
+	TrashDedup         bool            `json:"trash_dedup"`               // Store identical trashed files once
+	TrashChecksums     bool            `json:"trash_checksums,omitempty"` // Record a checksum of trashed items and check it on restore
+	TrashMaxSize       string          `json:"trash_max_size,omitempty"`  // Disk usage of the trash, like "20GB", above which the oldest items are removed after cleaning (empty = no limit)
+	ProfileStates      map[string]bool `json:"profile_states,omitempty"`  // Profiles enabled or disabled with 'rosia profile', overriding their "enabled" field
+	DetectCommands     []string        `json:"detect_commands,omitempty"` // Profiles, by name or file name, allowed to run their detect_command
+
+	PluginSettings       map[string]map[string]any `json:"plugin_settings,omitempty"` // Settings passed to plugins when they load, by plugin name
+	PluginTimeoutSeconds int                       `json:"plugin_timeout_seconds"`    // Seconds each plugin may take to scan or clean (0 = 120)
+
+	Scan  ScanDefaults  `json:"scan"`  // Defaults of the flags of 'rosia scan'
+	Clean CleanDefaults `json:"clean"` // Defaults of the flags of 'rosia clean'
+	UI    UIDefaults    `json:"ui"`    // Defaults of the flags of 'rosia ui'
+
+	Replace []string `json:"replace,omitempty"` // Dotted keys of lists replacing those of the system configuration instead of extending them
+}
+
+// ScanDefaults are used by 'rosia scan' for the flags not given. Zero values
+// leave the defaults of the flags.
+type ScanDefaults struct {
+	Depth         int  `json:"depth,omitempty"`          // --depth
+	IncludeHidden bool `json:"include_hidden,omitempty"` // --include-hidden
+}
+
+// CleanDefaults are used by 'rosia clean' for the flags not given. Zero
+// values leave the defaults of the flags.
+type CleanDefaults struct {
+	UseTrash      *bool `json:"use_trash,omitempty"`      // false for --no-trash
+	Depth         int   `json:"depth,omitempty"`          // --depth
+	IncludeHidden bool  `json:"include_hidden,omitempty"` // --include-hidden
+}
+
+// UIDefaults are used by 'rosia ui' for the flags not given. Zero values
+// leave the defaults of the flags.
+type UIDefaults struct {
+	Theme         string `json:"theme,omitempty"`          // --theme
+	Depth         int    `json:"depth,omitempty"`          // --depth
+	IncludeHidden bool   `json:"include_hidden,omitempty"` // --include-hidden
+}
+
+// HooksConfig lists shell commands to run before and after cleaning.
+//
+// Per-target commands receive ROSIA_TARGET_PATH, ROSIA_TARGET_PROFILE and
+// ROSIA_TARGET_SIZE in their environment; batch commands receive
+// ROSIA_TARGET_COUNT and ROSIA_TARGET_PATHS.
+type HooksConfig struct {
+	PreClean  []string `json:"pre_clean,omitempty"`  // Run before each target is cleaned
+	PostClean []string `json:"post_clean,omitempty"` // Run after each target is cleaned
+	PreBatch  []string `json:"pre_batch,omitempty"`  // Run once before a batch is cleaned
+	PostBatch []string `json:"post_batch,omitempty"` // Run once after a batch is cleaned
+}
+
+// RetryConfig controls retries of deletions that fail transiently, e.g. because
+// an antivirus scanner briefly locks a file on Windows.
+type RetryConfig struct {
+	MaxRetries   int `json:"max_retries"`    // Extra attempts after a failed delete (0 = no retry)
+	BackoffMs    int `json:"backoff_ms"`     // Delay before the first retry in milliseconds
+	MaxBackoffMs int `json:"max_backoff_ms"` // Upper bound for the delay between retries
 }
 
 // Manager handles configuration loading and saving.
@@ -38,7 +120,11 @@ type Config struct {
 // The Manager reads configuration from ~/.rosiarc.json and provides methods
 // to load, save, and retrieve default configuration values.
 type Manager struct {
-	configPath string
+	configPath  string
+	systemPath  string       // System configuration under the user one, see SystemConfigPath
+	unknownKeys []UnknownKey // Unknown keys of the files of the last Load or LoadAndValidate
+	userKeys    []string     // Top-level keys of the user configuration at the last Load, see SaveKeys
+	layers      []string     // Files merged by the last LoadAndValidate
 }
 
 // NewManager creates a new configuration manager
@@ -51,31 +137,33 @@ func NewManager() (*Manager, error) {
 
 	return &Manager{
 		configPath: configPath,
+		systemPath: SystemConfigPath(),
 	}, nil
 }
 
 // getDefaultConfigPath returns the platform-specific default config file path
 func getDefaultConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	// Existing installs keep ~/.rosiarc.json until it is migrated
+	location, err := fsutils.ConfigFileLocation()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
-
-	// For backward compatibility, keep config file in home directory
-	// In the future, this could use fsutils.GetConfigFilePath() for platform-specific paths
-	configPath := filepath.Join(homeDir, ".rosiarc.json")
-	return configPath, nil
+	return location.Path(), nil
 }
 
 // NewManagerWithPath creates a new configuration manager with a custom path
 func NewManagerWithPath(configPath string) *Manager {
 	return &Manager{
 		configPath: configPath,
+		systemPath: SystemConfigPath(),
 	}
 }
 
-// Load reads configuration from ~/.rosiarc.json
+// Load reads configuration from ~/.rosiarc.json alone, without the system
+// configuration, for it to be changed and saved. Settings the file leaves out
+// have their defaults.
 func (m *Manager) Load() (*Config, error) {
+	m.userKeys = nil
 	data, err := os.ReadFile(m.configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -85,20 +173,97 @@ func (m *Manager) Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file %s: %w", m.configPath, err)
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	// Files of earlier versions are migrated as they are read; Save writes
+	// them back in the current format
+	_, migrated, unknown, err := decodeFile(m.configPath, data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file %s: %w", m.configPath, err)
 	}
+	m.unknownKeys = unknown
 
-	return &config, nil
+	config := m.GetDefault()
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(migrated, &object); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", m.configPath, err)
+	}
+	if err := json.Unmarshal(migrated, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", m.configPath, err)
+	}
+	config.Version = CurrentVersion
+	for key := range object {
+		m.userKeys = append(m.userKeys, key)
+	}
+
+	return config, nil
 }
 
-// Save writes configuration to ~/.rosiarc.json
+// UnknownKeys returns the keys of the files of the last Load or
+// LoadAndValidate that the configuration does not have, which are ignored
+func (m *Manager) UnknownKeys() []UnknownKey {
+	return m.unknownKeys
+}
+
+// Save writes configuration to ~/.rosiarc.json, every setting included, so
+// that it overrides the whole system configuration. SaveKeys changes some
+// settings only.
 func (m *Manager) Save(config *Config) error {
-	data, err := json.MarshalIndent(config, "", "  ")
+	object, err := toMap(config)
+	if err != nil {
+		return err
+	}
+	return m.write(object)
+}
+
+// SaveKeys writes the settings of configuration the user configuration held
+// at the last Load, along with keys, to ~/.rosiarc.json. Settings are named
+// by their top-level key; "scan.depth" saves the whole "scan" object. Other
+// settings are left to the system configuration and the defaults.
+func (m *Manager) SaveKeys(config *Config, keys ...string) error {
+	object, err := toMap(config)
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return err
+	}
+	saved := make(map[string]any)
+	for _, key := range append(slices.Clone(m.userKeys), keys...) {
+		key, _, _ = strings.Cut(key, ".")
+		if value, ok := object[key]; ok {
+			saved[key] = value
+		}
 	}
+	return m.write(saved)
+}
+
+// Reset empties ~/.rosiarc.json, leaving every setting to the system
+// configuration and the defaults
+func (m *Manager) Reset() error {
+	m.userKeys = nil
+	return m.write(map[string]any{})
+}
+
+// write writes object to ~/.rosiarc.json with the current version, its keys
+// in the order of the fields of Config
+func (m *Manager) write(object map[string]any) error {
+	object["version"] = CurrentVersion
+
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	configType := reflect.TypeOf(Config{})
+	for i := range configType.NumField() {
+		key, _, _ := strings.Cut(configType.Field(i).Tag.Get("json"), ",")
+		value, ok := object[key]
+		if !ok {
+			continue
+		}
+		data, err := json.MarshalIndent(value, "  ", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal config: %w", err)
+		}
+		if buf.Len() > 1 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, "\n  %q: %s", key, data)
+	}
+	buf.WriteString("\n}")
 
 	// Ensure parent directory exists
 	dir := filepath.Dir(m.configPath)
@@ -106,7 +271,7 @@ func (m *Manager) Save(config *Config) error {
 		return fmt.Errorf("failed to create config directory %s: %w", dir, err)
 	}
 
-	if err := os.WriteFile(m.configPath, data, 0644); err != nil {
+	if err := os.WriteFile(m.configPath, buf.Bytes(), 0644); err != nil {
 		return fmt.Errorf("failed to write config file %s: %w", m.configPath, err)
 	}
 
@@ -116,8 +281,9 @@ func (m *Manager) Save(config *Config) error {
 // GetDefault returns the default configuration
 func (m *Manager) GetDefault() *Config {
 	return &Config{
+		Version:            CurrentVersion,
 		TrashRetentionDays: 3,
-		Profiles:           []string{"node", "python", "rust", "flutter", "go"},
+		Profiles:           []string{},
 		IgnorePaths:        []string{},
 		Plugins:            []string{},
 		Concurrency:        0, // 0 means auto-detect (NumCPU * 2)
@@ -144,6 +310,54 @@ func (m *Manager) Validate(config *Config) error {
 		}
 	}
 
+	for _, path := range config.ScanPaths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("scan path must be absolute: %s", path)
+		}
+	}
+
+	for _, path := range config.ProtectedPaths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("protected path must be absolute: %s", path)
+		}
+	}
+
+	if config.TrashDir != "" && !filepath.IsAbs(config.TrashDir) {
+		return fmt.Errorf("trash_dir must be an absolute path: %s", config.TrashDir)
+	}
+
+	if config.TrashMaxSize != "" {
+		if _, err := sizecalc.ParseSize(config.TrashMaxSize); err != nil {
+			return fmt.Errorf("invalid trash_max_size: %w", err)
+		}
+	}
+
+	// Keep patterns follow the rules of the keep patterns of profiles
+	for _, pattern := range config.KeepPatterns {
+		if err := profiles.ValidateKeepPattern(pattern); err != nil {
+			return fmt.Errorf("invalid keep_patterns: %w", err)
+		}
+	}
+
+	// Validate permanent patterns are valid globs
+	for _, pattern := range config.PermanentPatterns {
+		if _, err := filepath.Match(pattern, "test"); err != nil || pattern == "" {
+			return fmt.Errorf("invalid permanent pattern: %q", pattern)
+		}
+	}
+
+	if config.Retry.MaxRetries < 0 || config.Retry.BackoffMs < 0 || config.Retry.MaxBackoffMs < 0 {
+		return fmt.Errorf("retry settings must be non-negative")
+	}
+
+	if config.PluginTimeoutSeconds < 0 {
+		return fmt.Errorf("plugin timeout must be non-negative")
+	}
+
+	if config.Scan.Depth < 0 || config.Clean.Depth < 0 || config.UI.Depth < 0 {
+		return fmt.Errorf("depth must be non-negative")
+	}
+
 	// Set concurrency to NumCPU * 2 if 0
 	if config.Concurrency == 0 {
 		config.Concurrency = runtime.NumCPU() * 2
@@ -157,9 +371,10 @@ func (m *Manager) Validate(config *Config) error {
 	return nil
 }
 
-// LoadAndValidate loads the configuration and validates it
+// LoadAndValidate loads the configuration in effect, the user configuration
+// merged over the system one, and validates it
 func (m *Manager) LoadAndValidate() (*Config, error) {
-	config, err := m.Load()
+	config, err := m.loadLayers()
 	if err != nil {
 		return nil, err
 	}
@@ -11,10 +11,21 @@ import (
 )
 
 func TestNewManager(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
 	manager, err := NewManager()
 	require.NoError(t, err)
 	assert.NotNil(t, manager)
-	assert.Contains(t, manager.configPath, ".rosiarc.json")
+	assert.Equal(t, "config.json", filepath.Base(manager.configPath))
+
+	// Existing installs keep their configuration until it is migrated
+	legacy := filepath.Join(home, ".rosiarc.json")
+	require.NoError(t, os.WriteFile(legacy, []byte(`{"trash_retention_days": 3}`), 0644))
+	manager, err = NewManager()
+	require.NoError(t, err)
+	assert.Equal(t, legacy, manager.configPath)
 }
 
 func TestGetDefault(t *testing.T) {
@@ -22,7 +33,7 @@ func TestGetDefault(t *testing.T) {
 	config := manager.GetDefault()
 
 	assert.Equal(t, 3, config.TrashRetentionDays)
-	assert.Equal(t, []string{"node", "python", "rust", "flutter", "go"}, config.Profiles)
+	assert.Equal(t, []string{}, config.Profiles)
 	assert.Equal(t, []string{}, config.IgnorePaths)
 	assert.Equal(t, []string{}, config.Plugins)
 	assert.Equal(t, 0, config.Concurrency)
@@ -42,6 +53,10 @@ func TestSaveAndLoad(t *testing.T) {
 		Plugins:            []string{"docker"},
 		Concurrency:        4,
 		TelemetryEnabled:   true,
+		PluginSettings:     map[string]map[string]any{"docker": {"include_volumes": true, "max_age": float64(7)}},
+		Scan:               ScanDefaults{Depth: 4, IncludeHidden: true},
+		Clean:              CleanDefaults{UseTrash: new(bool)},
+		UI:                 UIDefaults{Theme: "light"},
 	}
 
 	// Save config
@@ -63,6 +78,33 @@ func TestSaveAndLoad(t *testing.T) {
 	assert.Equal(t, testConfig.Plugins, loadedConfig.Plugins)
 	assert.Equal(t, testConfig.Concurrency, loadedConfig.Concurrency)
 	assert.Equal(t, testConfig.TelemetryEnabled, loadedConfig.TelemetryEnabled)
+	assert.Equal(t, testConfig.PluginSettings, loadedConfig.PluginSettings)
+	assert.Equal(t, testConfig.Scan, loadedConfig.Scan)
+	assert.Equal(t, testConfig.Clean, loadedConfig.Clean)
+	assert.Equal(t, testConfig.UI, loadedConfig.UI)
+}
+
+func TestLoad_LegacyDefaultProfiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, ".rosiarc.json")
+	manager := NewManagerWithPath(configPath)
+
+	// The old default of files without a version no longer restricts the
+	// profiles used
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"trash_retention_days": 3, "profiles": ["node", "python", "rust", "flutter", "go"]}`), 0644))
+
+	config, err := manager.Load()
+	require.NoError(t, err)
+	assert.Empty(t, config.Profiles)
+
+	// Any other list is kept
+	legacy := manager.GetDefault()
+	legacy.Profiles = []string{"node", "python", "rust"}
+	require.NoError(t, manager.Save(legacy))
+
+	config, err = manager.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"node", "python", "rust"}, config.Profiles)
 }
 
 func TestLoad_NonExistentFile(t *testing.T) {
@@ -160,6 +202,77 @@ func TestValidate_IgnorePaths(t *testing.T) {
 	}
 }
 
+func TestValidate_ScanPaths(t *testing.T) {
+	manager := &Manager{}
+
+	config := &Config{TrashRetentionDays: 3, ScanPaths: []string{"/home/me/projects", "/srv/src"}}
+	assert.NoError(t, manager.Validate(config))
+
+	config.ScanPaths = []string{"/home/me/projects", "projects"}
+	err := manager.Validate(config)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "scan path must be absolute")
+}
+
+func TestValidate_TrashDir(t *testing.T) {
+	manager := &Manager{}
+
+	tests := []struct {
+		name        string
+		trashDir    string
+		expectError bool
+	}{
+		{"default location", "", false},
+		{"absolute path", filepath.Join(t.TempDir(), "trash"), false},
+		{"relative path", "trash", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				TrashRetentionDays: 3,
+				TrashDir:           tt.trashDir,
+				Concurrency:        1,
+			}
+
+			err := manager.Validate(config)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidate_TrashMaxSize(t *testing.T) {
+	manager := &Manager{}
+
+	for _, size := range []string{"", "20GB", "500 MiB", "1024"} {
+		config := &Config{TrashRetentionDays: 3, TrashMaxSize: size}
+		assert.NoError(t, manager.Validate(config), size)
+	}
+
+	config := &Config{TrashRetentionDays: 3, TrashMaxSize: "20 parsecs"}
+	err := manager.Validate(config)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid trash_max_size")
+}
+
+func TestValidate_KeepPatterns(t *testing.T) {
+	manager := &Manager{}
+
+	for _, pattern := range []string{".cache/turbo", "CACHEDIR.TAG", "*.keep", "..cache"} {
+		config := &Config{TrashRetentionDays: 3, KeepPatterns: []string{pattern}}
+		assert.NoError(t, manager.Validate(config), pattern)
+	}
+
+	for _, pattern := range []string{"", "/etc/passwd", "..", "../sibling", "a/../b", "a/../../sibling", "./../x", "[z-a"} {
+		config := &Config{TrashRetentionDays: 3, KeepPatterns: []string{pattern}}
+		assert.Error(t, manager.Validate(config), pattern)
+	}
+}
+
 func TestValidate_Concurrency(t *testing.T) {
 	manager := &Manager{}
 
@@ -192,6 +305,16 @@ func TestValidate_Concurrency(t *testing.T) {
 	}
 }
 
+func TestValidate_CommandDefaults(t *testing.T) {
+	manager := &Manager{}
+
+	config := &Config{TrashRetentionDays: 3, Scan: ScanDefaults{Depth: 4}, UI: UIDefaults{Depth: 2}}
+	assert.NoError(t, manager.Validate(config))
+
+	config.Clean.Depth = -1
+	assert.Error(t, manager.Validate(config))
+}
+
 func TestLoadAndValidate(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, ".rosiarc.json")
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// CheckError is a problem of a configuration file, at Line and Column when
+// it has a position
+type CheckError struct {
+	Line   int // Line of the problem, from 1, or 0 when it has no position
+	Column int // Column of the problem, from 1
+	Err    error
+}
+
+// Error describes the problem, with its position if any
+func (e *CheckError) Error() string {
+	if e.Line == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// Check parses data, the content of a configuration file, and validates it.
+// Unlike Load, it rejects unknown keys, which are usually misspelled. Its
+// errors are *CheckError. Files of earlier versions are migrated first, and
+// their errors have no position when migrating rewrote them.
+func (m *Manager) Check(data []byte) (*Config, error) {
+	migrated, rewritten, err := migrateJSON(data)
+	if err != nil {
+		var checkErr *CheckError
+		if !errors.As(err, &checkErr) {
+			checkErr = &CheckError{Err: err}
+		}
+		return nil, checkErr
+	}
+
+	config, err := m.check(migrated)
+	var checkErr *CheckError
+	if rewritten && errors.As(err, &checkErr) {
+		// Positions in the migrated data are not those of the file
+		checkErr.Line, checkErr.Column = 0, 0
+	}
+	if config != nil {
+		config.Version = CurrentVersion
+	}
+	return config, err
+}
+
+// check parses and validates data, a configuration of the current version
+func (m *Manager) check(data []byte) (*Config, error) {
+	var config Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&config); err != nil {
+		return nil, decodeError(data, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		line, column := position(data, int(dec.InputOffset()))
+		return nil, &CheckError{Line: line, Column: column, Err: errors.New("unexpected content after the configuration")}
+	}
+	if unknown := unknownKeys(data, reflect.TypeOf(config)); len(unknown) > 0 {
+		return nil, unknownKeyError(unknown[0])
+	}
+
+	if err := m.Validate(&config); err != nil {
+		return nil, &CheckError{Err: err}
+	}
+	return &config, nil
+}
+
+// unknownKeyError returns the error of the unknown key k, located at it
+func unknownKeyError(k UnknownKey) *CheckError {
+	description := fmt.Sprintf("unknown key %q", k.Key)
+	if k.Suggestion != "" {
+		description += fmt.Sprintf(", did you mean %q?", k.Suggestion)
+	}
+	return &CheckError{Line: k.Line, Column: k.Column, Err: errors.New(description)}
+}
+
+// decodeError locates the error of decoding data
+func decodeError(data []byte, err error) *CheckError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	offset := -1
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = int(syntaxErr.Offset) - 1
+	case errors.As(err, &typeErr):
+		// The error is after the value, which is located by its key
+		offset = int(typeErr.Offset) - 1
+		if i := strings.LastIndex(typeErr.Field, "."); typeErr.Field != "" {
+			offset = lastKeyBefore(data, typeErr.Field[i+1:], offset)
+		}
+		err = fmt.Errorf("%s must be %s, not %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		offset = len(data)
+		err = errors.New("unexpected end of the configuration")
+	}
+
+	if offset < 0 {
+		return &CheckError{Err: err}
+	}
+	line, column := position(data, offset)
+	return &CheckError{Line: line, Column: column, Err: err}
+}
+
+// lastKeyBefore returns the offset of the last property key in data before
+// offset, or offset when there is none
+func lastKeyBefore(data []byte, key string, offset int) int {
+	found := offset
+	for start := 0; ; {
+		next := findKey(data, key, start)
+		if next < 0 || next >= offset {
+			return found
+		}
+		found, start = next, next+1
+	}
+}
+
+// position returns the line and column of offset in data, from 1
+func position(data []byte, offset int) (line, column int) {
+	offset = min(max(offset, 0), len(data))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// Excerpt returns the lines of data around line, numbered, with a caret
+// under column of line
+func Excerpt(data []byte, line, column int) string {
+	lines := strings.Split(string(data), "\n")
+	var b strings.Builder
+	for i := max(line-2, 1); i <= min(line+2, len(lines)); i++ {
+		fmt.Fprintf(&b, "%4d | %s\n", i, lines[i-1])
+		if i == line {
+			fmt.Fprintf(&b, "     | %s^\n", strings.Repeat(" ", max(column-1, 0)))
+		}
+	}
+	return b.String()
+}
+
+// Diff returns the lines removed from old, prefixed with "-", and added in
+// new, prefixed with "+", in the order of the files
+func Diff(old, new []byte) string {
+	a := strings.Split(strings.TrimSuffix(string(old), "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(string(new), "\n"), "\n")
+
+	// common[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			fmt.Fprintf(&diff, "- %s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&diff, "+ %s\n", b[j])
+			j++
+		}
+	}
+	return diff.String()
+}
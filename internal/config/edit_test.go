@@ -0,0 +1,89 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	manager := &Manager{}
+
+	tests := []struct {
+		name    string
+		data    string
+		line    int
+		column  int
+		message string
+	}{
+		{
+			name:    "syntax error",
+			data:    "{\n  \"trash_retention_days\": 7,\n  \"plugins\": [\"docker\",]\n}\n",
+			line:    3,
+			column:  24,
+			message: "invalid character ']'",
+		},
+		{
+			name:    "wrong type",
+			data:    "{\n  \"trash_retention_days\": \"7\"\n}\n",
+			line:    2,
+			column:  3,
+			message: "trash_retention_days must be int, not string",
+		},
+		{
+			name:    "unknown key",
+			data:    "{\n  \"trash_retention_days\": 7,\n  \"scan\": {\"dept\": 4}\n}\n",
+			line:    3,
+			column:  12,
+			message: `unknown key "scan.dept", did you mean "scan.depth"?`,
+		},
+		{
+			name:    "unexpected end",
+			data:    "{\n  \"trash_retention_days\": 7,\n",
+			line:    3,
+			column:  1,
+			message: "unexpected end",
+		},
+		{
+			name:    "invalid value",
+			data:    `{"trash_retention_days": 0}`,
+			message: "trash_retention_days must be greater than 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := manager.Check([]byte(tt.data))
+			var checkErr *CheckError
+			require.ErrorAs(t, err, &checkErr)
+			assert.Equal(t, tt.line, checkErr.Line)
+			assert.Equal(t, tt.column, checkErr.Column)
+			assert.Contains(t, checkErr.Error(), tt.message)
+		})
+	}
+
+	config, err := manager.Check([]byte(`{"trash_retention_days": 7, "scan": {"depth": 4}}`))
+	require.NoError(t, err)
+	assert.Equal(t, 4, config.Scan.Depth)
+}
+
+func TestExcerpt(t *testing.T) {
+	data := []byte("{\n  \"a\": 1,\n  \"b\": ,\n  \"c\": 3\n}")
+
+	expected := "   1 | {\n" +
+		"   2 |   \"a\": 1,\n" +
+		"   3 |   \"b\": ,\n" +
+		"     |        ^\n" +
+		"   4 |   \"c\": 3\n" +
+		"   5 | }\n"
+	assert.Equal(t, expected, Excerpt(data, 3, 8))
+}
+
+func TestDiff(t *testing.T) {
+	old := []byte("{\n  \"a\": 1,\n  \"b\": 2\n}\n")
+	new := []byte("{\n  \"a\": 1,\n  \"b\": 3,\n  \"c\": 4\n}\n")
+
+	assert.Equal(t, "-   \"b\": 2\n+   \"b\": 3,\n+   \"c\": 4\n", Diff(old, new))
+	assert.Empty(t, Diff(old, old))
+}
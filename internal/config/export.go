@@ -0,0 +1,198 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ExportVersion is the version of the format of exports
+const ExportVersion = 1
+
+// Export is a configuration moved between machines: the user configuration
+// and the user profiles. Paths under the home directory are written with
+// "~", for the export to apply to any home directory.
+type Export struct {
+	Version  int                        `json:"version"`            // ExportVersion
+	Config   *Config                    `json:"config"`             // User configuration
+	Profiles map[string]json.RawMessage `json:"profiles,omitempty"` // Profile files, by file name
+}
+
+// Export returns the user configuration and the profile files of dirs as an
+// export. Profiles of later directories replace those of the same file name
+// in earlier ones, as when they are loaded.
+func (m *Manager) Export(profileDirs []string) ([]byte, error) {
+	config, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	mapPaths(config, func(path string) string { return collapseHome(path, home) })
+
+	export := Export{Version: ExportVersion, Config: config, Profiles: map[string]json.RawMessage{}}
+	for _, dir := range profileDirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read profile %s: %w", file, err)
+			}
+			if !json.Valid(data) {
+				return nil, fmt.Errorf("profile %s is not valid JSON", file)
+			}
+			export.Profiles[filepath.Base(file)] = json.RawMessage(data)
+		}
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal export: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ParseExport parses data, an export, and validates its configuration like
+// Check. Paths starting with "~" are resolved to the home directory.
+func (m *Manager) ParseExport(data []byte) (*Export, error) {
+	data, err := migrateExport(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, decodeError(data, err)
+	}
+	if unknown := unknownKeys(data, reflect.TypeOf(export)); len(unknown) > 0 {
+		return nil, unknownKeyError(unknown[0])
+	}
+	if export.Version != ExportVersion {
+		return nil, &CheckError{Err: fmt.Errorf("unsupported export version %d, expected %d", export.Version, ExportVersion)}
+	}
+	if export.Config == nil {
+		return nil, &CheckError{Err: errors.New("the export has no configuration")}
+	}
+	export.Config.Version = CurrentVersion
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	mapPaths(export.Config, func(path string) string { return expandHome(path, home) })
+
+	// Validation fills in defaults, which must not be saved
+	validated := *export.Config
+	if err := m.Validate(&validated); err != nil {
+		return nil, &CheckError{Err: err}
+	}
+
+	for name, profile := range export.Profiles {
+		if name != filepath.Base(name) || filepath.Ext(name) != ".json" || strings.HasPrefix(name, ".") {
+			return nil, &CheckError{Err: fmt.Errorf("invalid profile file name %q", name)}
+		}
+		if !json.Valid(profile) {
+			return nil, &CheckError{Err: fmt.Errorf("profile %s is not valid JSON", name)}
+		}
+	}
+	return &export, nil
+}
+
+// migrateExport returns data, an export, with its configuration migrated to
+// CurrentVersion like configuration files. Malformed exports are returned
+// as they are, for ParseExport to locate their errors.
+func migrateExport(data []byte) ([]byte, error) {
+	var export map[string]json.RawMessage
+	if err := json.Unmarshal(data, &export); err != nil {
+		return data, nil
+	}
+	var config map[string]any
+	if err := json.Unmarshal(export["config"], &config); err != nil || config == nil {
+		return data, nil
+	}
+
+	changed, err := migrate(config)
+	if err != nil {
+		return nil, &CheckError{Err: err}
+	}
+	if !changed {
+		return data, nil
+	}
+	if export["config"], err = json.Marshal(config); err != nil {
+		return nil, fmt.Errorf("failed to marshal migrated configuration: %w", err)
+	}
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// ProfileNames returns the file names of the profiles of the export, sorted
+func (e *Export) ProfileNames() []string {
+	names := make([]string, 0, len(e.Profiles))
+	for name := range e.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DetectCommands returns the detect_command of the profiles of the export
+// that have one, by file name
+func (e *Export) DetectCommands() map[string][]string {
+	commands := map[string][]string{}
+	for name, data := range e.Profiles {
+		var profile struct {
+			DetectCommand []string `json:"detect_command"`
+		}
+		if err := json.Unmarshal(data, &profile); err == nil && len(profile.DetectCommand) > 0 {
+			commands[name] = profile.DetectCommand
+		}
+	}
+	return commands
+}
+
+// mapPaths replaces the paths of config with fn of them
+func mapPaths(config *Config, fn func(string) string) {
+	for i, path := range config.IgnorePaths {
+		config.IgnorePaths[i] = fn(path)
+	}
+	for i, path := range config.ScanPaths {
+		config.ScanPaths[i] = fn(path)
+	}
+	for i, path := range config.ProtectedPaths {
+		config.ProtectedPaths[i] = fn(path)
+	}
+	if config.TrashDir != "" {
+		config.TrashDir = fn(config.TrashDir)
+	}
+}
+
+// collapseHome returns path with the home directory replaced by "~"
+func collapseHome(path, home string) string {
+	if path == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return "~/" + filepath.ToSlash(rest)
+	}
+	return path
+}
+
+// expandHome returns path with a leading "~" replaced by the home directory
+func expandHome(path, home string) string {
+	if path == "~" {
+		return home
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(home, filepath.FromSlash(rest))
+	}
+	return path
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport_RoundTrip(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	manager := NewManagerWithPath(filepath.Join(home, ".rosiarc.json"))
+	config := manager.GetDefault()
+	config.IgnorePaths = []string{filepath.Join(home, "work", "vendor"), filepath.Join(string(filepath.Separator), "srv")}
+	config.TrashDir = filepath.Join(home, "trash")
+	config.KeepPatterns = []string{".env"}
+	require.NoError(t, manager.Save(config))
+
+	profiles := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(profiles, "zig.json"), []byte(`{"name": "Zig"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(profiles, "notes.txt"), []byte("not a profile"), 0644))
+
+	data, err := manager.Export([]string{profiles, filepath.Join(home, "missing")})
+	require.NoError(t, err)
+
+	// Paths under the home directory are portable
+	assert.Contains(t, string(data), `"~/work/vendor"`)
+	assert.Contains(t, string(data), `"~/trash"`)
+	assert.NotContains(t, string(data), home)
+
+	// On another machine, they follow its home directory
+	other := t.TempDir()
+	t.Setenv("HOME", other)
+	t.Setenv("USERPROFILE", other)
+	export, err := manager.ParseExport(data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(other, "work", "vendor"), filepath.Join(string(filepath.Separator), "srv")}, export.Config.IgnorePaths)
+	assert.Equal(t, filepath.Join(other, "trash"), export.Config.TrashDir)
+	assert.Equal(t, []string{".env"}, export.Config.KeepPatterns)
+	assert.Equal(t, 0, export.Config.Concurrency, "defaults filled in by validation must not be imported")
+	assert.Equal(t, []string{"zig.json"}, export.ProfileNames())
+	assert.JSONEq(t, `{"name": "Zig"}`, string(export.Profiles["zig.json"]))
+}
+
+func TestParseExport_Invalid(t *testing.T) {
+	manager := NewManagerWithPath(filepath.Join(t.TempDir(), ".rosiarc.json"))
+
+	tests := []struct {
+		name   string
+		data   string
+		errMsg string
+	}{
+		{"not json", `{"version": 1,`, "unexpected end"},
+		{"unknown key", "{\n  \"version\": 1,\n  \"config\": {\"trash_retention_days\": 3, \"ignore_path\": []}\n}", `line 3, column 41: unknown key "config.ignore_path", did you mean "config.ignore_paths"?`},
+		{"version", `{"version": 2, "config": {"trash_retention_days": 3}}`, "unsupported export version 2"},
+		{"no config", `{"version": 1}`, "no configuration"},
+		{"invalid config", `{"version": 1, "config": {"trash_retention_days": 0}}`, "trash_retention_days must be greater than 0"},
+		{"profile name", `{"version": 1, "config": {"trash_retention_days": 3}, "profiles": {"../evil.json": {}}}`, "invalid profile file name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := manager.ParseExport([]byte(tt.data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
+
+func TestExport_DetectCommands(t *testing.T) {
+	export := &Export{Profiles: map[string]json.RawMessage{
+		"zig.json":     json.RawMessage(`{"name": "Zig"}`),
+		"ignored.json": json.RawMessage(`{"name": "Ignored", "detect_command": ["git", "check-ignore", "-q", "build"]}`),
+	}}
+
+	assert.Equal(t, map[string][]string{"ignored.json": {"git", "check-ignore", "-q", "build"}}, export.DetectCommands())
+}
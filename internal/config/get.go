@@ -0,0 +1,58 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Get returns the value of the dotted key of config, e.g. "retry.max_retries"
+// or "plugin_settings.docker.include_volumes", as decoded from its JSON.
+// Fields always have a value, their zero value when unset; keys of maps,
+// like the plugins of plugin_settings, are an error when missing.
+func Get(config *Config, key string) (any, error) {
+	value := reflect.ValueOf(config).Elem()
+	prefix := ""
+	for _, part := range strings.Split(key, ".") {
+		for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return nil, fmt.Errorf("%s is not set", strings.TrimSuffix(prefix, "."))
+			}
+			value = value.Elem()
+		}
+
+		switch value.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(value.Type())
+			field, ok := fields[part]
+			if !ok {
+				k := UnknownKey{Key: prefix + part}
+				if suggestion := nearest(part, fields); suggestion != "" {
+					k.Suggestion = prefix + suggestion
+				}
+				return nil, fmt.Errorf("%s", k)
+			}
+			value = value.FieldByIndex(field.Index)
+		case reflect.Map:
+			entry := value.MapIndex(reflect.ValueOf(part))
+			if !entry.IsValid() {
+				return nil, fmt.Errorf("%s is not set", prefix+part)
+			}
+			value = entry
+		default:
+			return nil, fmt.Errorf("%s has no key %q", strings.TrimSuffix(prefix, "."), part)
+		}
+		prefix += part + "."
+	}
+
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+	var result any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", key, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet(t *testing.T) {
+	config := NewManagerWithPath("").GetDefault()
+	config.IgnorePaths = []string{"/tmp", "/var"}
+	config.Retry.MaxRetries = 5
+	config.Scan.IncludeHidden = true
+	config.PluginSettings = map[string]map[string]any{"docker": {"include_volumes": true}}
+
+	tests := []struct {
+		key      string
+		expected any
+	}{
+		{"trash_retention_days", float64(3)},
+		{"telemetry_enabled", false},
+		{"trash_dir", ""},
+		{"ignore_paths", []any{"/tmp", "/var"}},
+		{"retry.max_retries", float64(5)},
+		{"retry", map[string]any{"max_retries": float64(5), "backoff_ms": float64(config.Retry.BackoffMs), "max_backoff_ms": float64(config.Retry.MaxBackoffMs)}},
+		{"scan.include_hidden", true},
+		{"scan.depth", float64(0)},
+		{"clean.use_trash", nil},
+		{"plugin_settings.docker.include_volumes", true},
+		{"plugin_settings.docker", map[string]any{"include_volumes": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			value, err := Get(config, tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestGet_Errors(t *testing.T) {
+	config := NewManagerWithPath("").GetDefault()
+	config.PluginSettings = map[string]map[string]any{"docker": {"include_volumes": true}}
+
+	tests := []struct {
+		key    string
+		errMsg string
+	}{
+		{"concurrancy", `unknown key "concurrancy", did you mean "concurrency"?`},
+		{"retry.max_retry", `unknown key "retry.max_retry", did you mean "retry.max_retries"?`},
+		{"plugin_settings.npm", "plugin_settings.npm is not set"},
+		{"plugin_settings.docker.prune", "plugin_settings.docker.prune is not set"},
+		{"trash_retention_days.days", `trash_retention_days has no key "days"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			_, err := Get(config, tt.key)
+			require.Error(t, err)
+			assert.Equal(t, tt.errMsg, err.Error())
+		})
+	}
+}
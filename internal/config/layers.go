@@ -0,0 +1,143 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+)
+
+// SystemConfigEnv is the environment variable overriding the location of the
+// system configuration
+const SystemConfigEnv = "ROSIA_SYSTEM_CONFIG"
+
+// SystemConfigPath returns the location of the system configuration, shared
+// by every user of the machine: /etc/rosia/config.json, or
+// %ProgramData%\rosia\config.json on Windows
+func SystemConfigPath() string {
+	if path := os.Getenv(SystemConfigEnv); path != "" {
+		return path
+	}
+	if runtime.GOOS == "windows" {
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = `C:\ProgramData`
+		}
+		return filepath.Join(programData, "rosia", "config.json")
+	}
+	return "/etc/rosia/config.json"
+}
+
+// loadLayers merges the defaults, the system configuration and the user
+// configuration, in this order, skipping files that do not exist. Objects
+// are merged key by key, lists are appended to the lists of the layers
+// below unless their dotted key is in the "replace" list of the file, and
+// other values override those below.
+func (m *Manager) loadLayers() (*Config, error) {
+	m.unknownKeys = nil
+	m.layers = nil
+
+	merged, err := toMap(m.GetDefault())
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range []string{m.systemPath, m.configPath} {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+
+		// Each file is migrated and decoded on its own first, for its errors
+		// to point into it
+		layer, migrated, unknown, err := decodeFile(path, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		m.unknownKeys = append(m.unknownKeys, unknown...)
+
+		var object map[string]any
+		if err := json.Unmarshal(migrated, &object); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		replace := make(map[string]bool, len(layer.Replace))
+		for _, key := range layer.Replace {
+			replace[key] = true
+		}
+		delete(object, "replace")
+		merged = mergeObjects(merged, object, "", replace)
+		m.layers = append(m.layers, path)
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge config files: %w", err)
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to merge config files: %w", err)
+	}
+	return &config, nil
+}
+
+// Layers returns the files merged by the last LoadAndValidate, from the
+// lowest to the highest precedence
+func (m *Manager) Layers() []string {
+	return m.layers
+}
+
+// mergeObjects merges layer into base. prefix is the dotted key of base
+// followed by a dot, or "" at the top.
+func mergeObjects(base, layer map[string]any, prefix string, replace map[string]bool) map[string]any {
+	if base == nil {
+		base = make(map[string]any, len(layer))
+	}
+	for key, value := range layer {
+		switch value := value.(type) {
+		case map[string]any:
+			if below, ok := base[key].(map[string]any); ok {
+				base[key] = mergeObjects(below, value, prefix+key+".", replace)
+				continue
+			}
+		case []any:
+			if below, ok := base[key].([]any); ok && !replace[prefix+key] {
+				base[key] = appendMissing(below, value)
+				continue
+			}
+		}
+		base[key] = value
+	}
+	return base
+}
+
+// appendMissing appends the values of values that list does not hold
+func appendMissing(list, values []any) []any {
+	list = slices.Clone(list)
+	for _, value := range values {
+		if !slices.ContainsFunc(list, func(v any) bool { return reflect.DeepEqual(v, value) }) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// toMap returns config as a JSON object
+func toMap(config *Config) (map[string]any, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return object, nil
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// layeredManager returns a manager of a system and a user configuration
+// holding system and user, when not empty
+func layeredManager(t *testing.T, system, user string) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	manager := NewManagerWithPath(filepath.Join(dir, ".rosiarc.json"))
+	manager.systemPath = filepath.Join(dir, "system.json")
+	if system != "" {
+		require.NoError(t, os.WriteFile(manager.systemPath, []byte(system), 0644))
+	}
+	if user != "" {
+		require.NoError(t, os.WriteFile(manager.configPath, []byte(user), 0644))
+	}
+	return manager
+}
+
+func TestLoadAndValidate_Layers(t *testing.T) {
+	manager := layeredManager(t, `{
+  "ignore_paths": ["/srv/shared"],
+  "keep_patterns": [".env"],
+  "retry": {"max_retries": 2, "backoff_ms": 100},
+  "plugin_settings": {"docker": {"include_volumes": false, "host": "tcp://docker:2375"}}
+}`, `{
+  "trash_retention_days": 7,
+  "ignore_paths": ["/home/me/work", "/srv/shared"],
+  "keep_patterns": ["local.db"],
+  "retry": {"max_retries": 5},
+  "plugin_settings": {"docker": {"include_volumes": true}},
+  "replace": ["keep_patterns"]
+}`)
+
+	config, err := manager.LoadAndValidate()
+	require.NoError(t, err)
+
+	// Lists are extended, without duplicates, unless replaced
+	assert.Equal(t, []string{"/srv/shared", "/home/me/work"}, config.IgnorePaths)
+	assert.Equal(t, []string{"local.db"}, config.KeepPatterns)
+	// Objects are merged key by key
+	assert.Equal(t, RetryConfig{MaxRetries: 5, BackoffMs: 100}, config.Retry)
+	assert.Equal(t, map[string]any{"include_volumes": true, "host": "tcp://docker:2375"}, config.PluginSettings["docker"])
+	assert.Equal(t, 7, config.TrashRetentionDays)
+	assert.Equal(t, []string{manager.systemPath, manager.configPath}, manager.Layers())
+
+	// Load reads the user configuration alone, for it to be saved back
+	user, err := manager.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/home/me/work", "/srv/shared"}, user.IgnorePaths)
+	assert.Zero(t, user.Retry.BackoffMs)
+}
+
+func TestLoadAndValidate_SystemOnly(t *testing.T) {
+	// Settings the system configuration leaves out keep their defaults
+	manager := layeredManager(t, `{"ignore_paths": ["/srv/shared"]}`, "")
+
+	config, err := manager.LoadAndValidate()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/srv/shared"}, config.IgnorePaths)
+	assert.Equal(t, 3, config.TrashRetentionDays)
+	assert.Equal(t, []string{manager.systemPath}, manager.Layers())
+}
+
+func TestLoadAndValidate_LayerErrors(t *testing.T) {
+	// Errors point into the file they are in
+	manager := layeredManager(t, "{\n  \"concurrency\": \"4\"\n}", `{"trash_retention_days": 7}`)
+	_, err := manager.LoadAndValidate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), manager.systemPath)
+	assert.Contains(t, err.Error(), "line 2")
+
+	// Unknown keys are reported with their file
+	manager = layeredManager(t, `{"ignore_path": ["/srv"]}`, `{"trash_retention_dys": 7}`)
+	_, err = manager.LoadAndValidate()
+	require.NoError(t, err)
+	unknown := manager.UnknownKeys()
+	require.Len(t, unknown, 2)
+	assert.Equal(t, manager.systemPath, unknown[0].File)
+	assert.Equal(t, "ignore_path", unknown[0].Key)
+	assert.Equal(t, manager.configPath, unknown[1].File)
+	assert.Equal(t, "trash_retention_dys", unknown[1].Key)
+}
+
+func TestSaveKeys_KeepsLayers(t *testing.T) {
+	manager := layeredManager(t, `{"trash_retention_days": 14, "ignore_paths": ["/srv/shared"]}`, `{"keep_patterns": [".env"]}`)
+
+	// As 'rosia config set concurrency 4' does
+	config, err := manager.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 3, config.TrashRetentionDays)
+	config.Concurrency = 4
+	require.NoError(t, manager.Validate(config))
+	require.NoError(t, manager.SaveKeys(config, "concurrency"))
+
+	// Only the settings of the user are saved
+	data, err := os.ReadFile(manager.configPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version": 1, "concurrency": 4, "keep_patterns": [".env"]}`, string(data))
+
+	merged, err := manager.LoadAndValidate()
+	require.NoError(t, err)
+	assert.Equal(t, 14, merged.TrashRetentionDays)
+	assert.Equal(t, 4, merged.Concurrency)
+	assert.Equal(t, []string{"/srv/shared"}, merged.IgnorePaths)
+	assert.Equal(t, []string{".env"}, merged.KeepPatterns)
+
+	// Setting a default value still overrides the system configuration
+	config, err = manager.Load()
+	require.NoError(t, err)
+	config.TrashRetentionDays = 3
+	require.NoError(t, manager.SaveKeys(config, "trash_retention_days"))
+	merged, err = manager.LoadAndValidate()
+	require.NoError(t, err)
+	assert.Equal(t, 3, merged.TrashRetentionDays)
+	assert.Equal(t, 4, merged.Concurrency)
+
+	// Reset leaves everything to the system configuration
+	require.NoError(t, manager.Reset())
+	merged, err = manager.LoadAndValidate()
+	require.NoError(t, err)
+	assert.Equal(t, 14, merged.TrashRetentionDays)
+	assert.Empty(t, merged.KeepPatterns)
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// CurrentVersion is the version of the format of the configuration files
+// Save writes. Files without a version are version 0.
+const CurrentVersion = 1
+
+// migration upgrades a configuration file, decoded as a JSON object, to the
+// next version of the format. apply reports whether it changed object.
+type migration struct {
+	description string
+	apply       func(object map[string]any) bool
+}
+
+// migrations upgrade configuration files from the version of their index to
+// the next one. Changing the format of the configuration means appending a
+// migration and incrementing CurrentVersion, so files written by earlier
+// versions keep loading.
+var migrations = []migration{
+	// 0 → 1
+	{
+		description: "drop the old default profiles list, which would hide every other built-in profile",
+		apply: func(object map[string]any) bool {
+			profiles, ok := object["profiles"].([]any)
+			if !ok || len(profiles) != len(legacyDefaultProfiles) {
+				return false
+			}
+			for i, name := range profiles {
+				if name != legacyDefaultProfiles[i] {
+					return false
+				}
+			}
+			object["profiles"] = []any{}
+			return true
+		},
+	},
+}
+
+// migrate upgrades object, a configuration file decoded as a JSON object, to
+// CurrentVersion and reports whether any migration changed it. Files of a
+// later version, written by a newer rosia, are refused rather than misread.
+func migrate(object map[string]any) (bool, error) {
+	version := 0
+	if value, ok := object["version"]; ok {
+		number, ok := value.(float64)
+		if !ok || number != float64(int(number)) || number < 0 {
+			return false, fmt.Errorf("version must be a non-negative integer, got %v", value)
+		}
+		version = int(number)
+	}
+	if version > CurrentVersion {
+		return false, fmt.Errorf("configuration version %d is newer than the version %d this rosia supports, upgrade rosia", version, CurrentVersion)
+	}
+
+	changed := false
+	for _, m := range migrations[version:] {
+		if m.apply(object) {
+			changed = true
+		}
+	}
+	if changed {
+		object["version"] = CurrentVersion
+	}
+	return changed, nil
+}
+
+// migrateJSON returns data, a configuration as a JSON object, migrated to
+// CurrentVersion, and reports whether migrating rewrote it. data is returned
+// as is when no migration changed it, so that positions in it stay valid,
+// and when it is malformed, for decoding it to locate its errors.
+func migrateJSON(data []byte) ([]byte, bool, error) {
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil || object == nil {
+		return data, false, nil
+	}
+	changed, err := migrate(object)
+	if err != nil {
+		return nil, false, &CheckError{Err: err}
+	}
+	if !changed {
+		return data, false, nil
+	}
+
+	migrated, err := json.MarshalIndent(object, "", "  ")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal migrated configuration: %w", err)
+	}
+	return migrated, true, nil
+}
+
+// decodeFile decodes data, the content of the configuration file path,
+// migrated to CurrentVersion, and returns the migrated data and its unknown
+// keys. Errors and keys are located in data unless migrating rewrote it,
+// which moves them around.
+func decodeFile(path string, data []byte) (*Config, []byte, []UnknownKey, error) {
+	migrated, rewritten, err := migrateJSON(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(migrated, &config); err != nil {
+		if rewritten {
+			return nil, nil, nil, fmt.Errorf("after migrating to version %d: %w", CurrentVersion, err)
+		}
+		return nil, nil, nil, decodeError(data, err)
+	}
+	config.Version = CurrentVersion
+
+	unknown := unknownKeys(migrated, reflect.TypeOf(config))
+	for i := range unknown {
+		unknown[i].File = path
+		if rewritten {
+			unknown[i].Line, unknown[i].Column = 0, 0
+		}
+	}
+	return &config, migrated, unknown, nil
+}
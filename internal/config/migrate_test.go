@@ -0,0 +1,135 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	// Each version but the first is reached by a migration
+	assert.Len(t, migrations, CurrentVersion)
+	for _, m := range migrations {
+		assert.NotEmpty(t, m.description)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		changed  bool
+		profiles any
+		errMsg   string
+	}{
+		{
+			name:     "legacy profiles",
+			data:     `{"profiles": ["node", "python", "rust", "flutter", "go"]}`,
+			changed:  true,
+			profiles: []any{},
+		},
+		{
+			name:     "other profiles",
+			data:     `{"profiles": ["node"]}`,
+			profiles: []any{"node"},
+		},
+		{
+			name:     "current version",
+			data:     `{"version": 1, "profiles": ["node", "python", "rust", "flutter", "go"]}`,
+			profiles: []any{"node", "python", "rust", "flutter", "go"},
+		},
+		{
+			name:   "newer version",
+			data:   `{"version": 2}`,
+			errMsg: "configuration version 2 is newer than the version 1 this rosia supports, upgrade rosia",
+		},
+		{
+			name:   "invalid version",
+			data:   `{"version": "1"}`,
+			errMsg: "version must be a non-negative integer, got 1",
+		},
+		{
+			name:   "negative version",
+			data:   `{"version": -1}`,
+			errMsg: "version must be a non-negative integer, got -1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var object map[string]any
+			require.NoError(t, json.Unmarshal([]byte(tt.data), &object))
+
+			changed, err := migrate(object)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.errMsg, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.changed, changed)
+			assert.Equal(t, tt.profiles, object["profiles"])
+		})
+	}
+}
+
+func TestLoad_NewerVersion(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".rosiarc.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"version": 99, "trash_retention_days": 3}`), 0644))
+
+	_, err := NewManagerWithPath(configPath).Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "configuration version 99 is newer")
+
+	_, err = (&Manager{}).Check([]byte(`{"version": 99}`))
+	var checkErr *CheckError
+	require.ErrorAs(t, err, &checkErr)
+}
+
+func TestLoad_MigratedUnknownKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".rosiarc.json")
+	manager := NewManagerWithPath(configPath)
+
+	// Keys of rewritten files are reported without positions, which would
+	// be those of the migrated data
+	data := `{"trash_retention_days": 3, "profiles": ["node", "python", "rust", "flutter", "go"], "concurrancy": 2}`
+	require.NoError(t, os.WriteFile(configPath, []byte(data), 0644))
+
+	config, err := manager.Load()
+	require.NoError(t, err)
+	assert.Equal(t, CurrentVersion, config.Version)
+	assert.Equal(t, []UnknownKey{{File: configPath, Key: "concurrancy", Suggestion: "concurrency"}}, manager.UnknownKeys())
+}
+
+func TestSave_Version(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".rosiarc.json")
+	manager := NewManagerWithPath(configPath)
+
+	config := manager.GetDefault()
+	config.Version = 0
+	require.NoError(t, manager.Save(config))
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	var saved map[string]any
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.Equal(t, float64(CurrentVersion), saved["version"])
+	assert.Equal(t, 0, config.Version)
+}
+
+func TestParseExport_Migrates(t *testing.T) {
+	manager := &Manager{}
+
+	export, err := manager.ParseExport([]byte(`{"version": 1, "config": {"trash_retention_days": 3, "profiles": ["node", "python", "rust", "flutter", "go"]}}`))
+	require.NoError(t, err)
+	assert.Empty(t, export.Config.Profiles)
+	assert.Equal(t, CurrentVersion, export.Config.Version)
+
+	_, err = manager.ParseExport([]byte(`{"version": 1, "config": {"version": 2, "trash_retention_days": 3}}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "configuration version 2 is newer")
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectFileName is the name of the project configuration file, read from
+// the root of scanned projects
+const ProjectFileName = ".rosia.json"
+
+// ProjectConfig represents the .rosia.json of a project, merged over the
+// user configuration when the project is scanned.
+type ProjectConfig struct {
+	Skip        bool     `json:"skip"`         // Never clean anything in the project
+	Patterns    []string `json:"patterns"`     // Patterns added to the project's profile; "!name" removes name
+	IgnorePaths []string `json:"ignore_paths"` // Paths, relative to the project root, to exclude from scanning
+}
+
+// LoadProject reads the .rosia.json of the project in dir. It returns nil
+// without error when the project has none.
+func LoadProject(dir string) (*ProjectConfig, error) {
+	path := filepath.Join(dir, ProjectFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read project config %s: %w", path, err)
+	}
+
+	var project ProjectConfig
+	if err := json.Unmarshal(data, &project); err != nil {
+		return nil, fmt.Errorf("failed to parse project config %s: %w", path, err)
+	}
+
+	if err := project.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid project config %s: %w", path, err)
+	}
+
+	return &project, nil
+}
+
+// Validate checks that the patterns are valid globs and that they and the
+// ignore paths stay inside the project
+func (p *ProjectConfig) Validate() error {
+	for _, pattern := range p.Patterns {
+		name := strings.TrimPrefix(pattern, "!")
+		if _, err := filepath.Match(name, "test"); err != nil || name == "" {
+			return fmt.Errorf("invalid pattern: %q", pattern)
+		}
+		// Locations outside the project are for profiles only
+		if strings.HasPrefix(name, "~") || filepath.IsAbs(name) {
+			return fmt.Errorf("pattern must be relative to the project: %q", pattern)
+		}
+	}
+
+	for _, path := range p.IgnorePaths {
+		if path == "" || filepath.IsAbs(path) {
+			return fmt.Errorf("ignore path must be relative to the project: %q", path)
+		}
+		for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+			if part == ".." {
+				return fmt.Errorf("ignore path must not leave the project: %q", path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AbsIgnorePaths returns the ignore paths of the project rooted at dir as
+// absolute paths
+func (p *ProjectConfig) AbsIgnorePaths(dir string) []string {
+	paths := make([]string, len(p.IgnorePaths))
+	for i, path := range p.IgnorePaths {
+		paths[i] = filepath.Join(dir, filepath.FromSlash(path))
+	}
+	return paths
+}
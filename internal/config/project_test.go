@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProject(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	project, err := LoadProject(tmpDir)
+	require.NoError(t, err)
+	assert.Nil(t, project, "a project without .rosia.json has no config")
+
+	data := `{"patterns": ["generated", "!dist"], "ignore_paths": ["packages/legacy"]}`
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, ProjectFileName), []byte(data), 0644))
+
+	project, err = LoadProject(tmpDir)
+	require.NoError(t, err)
+	require.NotNil(t, project)
+	assert.False(t, project.Skip)
+	assert.Equal(t, []string{"generated", "!dist"}, project.Patterns)
+	assert.Equal(t, []string{filepath.Join(tmpDir, "packages", "legacy")}, project.AbsIgnorePaths(tmpDir))
+}
+
+func TestLoadProject_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"invalid JSON":    `{"skip": }`,
+		"invalid pattern": `{"patterns": ["[invalid"]}`,
+		"empty negation":  `{"patterns": ["!"]}`,
+		"global pattern":  `{"patterns": ["~/Library/Caches"]}`,
+		"absolute ignore": `{"ignore_paths": ["/tmp"]}`,
+		"ignore outside":  `{"ignore_paths": ["../other"]}`,
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, ProjectFileName), []byte(data), 0644))
+
+			_, err := LoadProject(tmpDir)
+			assert.Error(t, err)
+		})
+	}
+}
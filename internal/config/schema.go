@@ -0,0 +1,143 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// UnknownKey is a key of a configuration file that the configuration does
+// not have, usually a typo
+type UnknownKey struct {
+	File       string // Configuration file of the key
+	Key        string // Dotted path of the key, e.g. "retry.max_retry"
+	Line       int    // Line of the key, from 1, or 0 when it was not found
+	Column     int    // Column of the key, from 1
+	Suggestion string // Dotted path of the nearest known key, if one is close
+}
+
+// String describes the key and the key it may stand for
+func (k UnknownKey) String() string {
+	description := fmt.Sprintf("unknown key %q", k.Key)
+	if k.Line > 0 {
+		description += fmt.Sprintf(" on line %d", k.Line)
+	}
+	if k.Suggestion != "" {
+		description += fmt.Sprintf(", did you mean %q?", k.Suggestion)
+	}
+	return description
+}
+
+// unknownKeys returns the keys of the JSON object data that the struct type
+// schema does not have, in the order of the file. Keys of nested structs are
+// checked as well; maps take any key.
+func unknownKeys(data []byte, schema reflect.Type) []UnknownKey {
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil
+	}
+
+	var unknown []UnknownKey
+	var walk func(object map[string]any, schema reflect.Type, prefix string, offset int)
+	walk = func(object map[string]any, schema reflect.Type, prefix string, offset int) {
+		fields := jsonFields(schema)
+		for key, value := range object {
+			keyOffset := findKey(data, key, offset)
+			field, known := fields[key]
+			if !known {
+				k := UnknownKey{Key: prefix + key}
+				if keyOffset >= 0 {
+					k.Line, k.Column = position(data, keyOffset)
+				}
+				if suggestion := nearest(key, fields); suggestion != "" {
+					k.Suggestion = prefix + suggestion
+				}
+				unknown = append(unknown, k)
+				continue
+			}
+
+			nested, ok := value.(map[string]any)
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if ok && fieldType.Kind() == reflect.Struct {
+				walk(nested, fieldType, prefix+key+".", max(keyOffset, offset))
+			}
+		}
+	}
+	walk(object, schema, "", 0)
+
+	sort.Slice(unknown, func(i, j int) bool {
+		if unknown[i].Line != unknown[j].Line {
+			return unknown[i].Line < unknown[j].Line
+		}
+		return unknown[i].Key < unknown[j].Key
+	})
+	return unknown
+}
+
+// jsonFields returns the fields of the struct type t by JSON key
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// findKey returns the offset of the first property key in data from offset,
+// or -1
+func findKey(data []byte, key string, offset int) int {
+	pattern := regexp.MustCompile(regexp.QuoteMeta(`"`+key+`"`) + `\s*:`)
+	loc := pattern.FindIndex(data[offset:])
+	if loc == nil {
+		return -1
+	}
+	return offset + loc[0]
+}
+
+// nearest returns the key of fields closest to key, or "" when none is
+// close enough to be a typo of it
+func nearest(key string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", max(2, len(key)/3)+1
+	for name := range fields {
+		distance := editDistance(strings.ToLower(key), name)
+		if distance < bestDistance || (distance == bestDistance && name < best) {
+			best, bestDistance = name, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
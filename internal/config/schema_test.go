@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_UnknownKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".rosiarc.json")
+	data := `{
+  "trash_retention_days": 7,
+  "concurrancy": 4,
+  "retry": {"max_retry": 2},
+  "plugin_settings": {"docker": {"include_volumes": true}},
+  "profile_states": {"node": false},
+  "clean": {"use_trash": false},
+  "zzz": true
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(data), 0644))
+
+	manager := NewManagerWithPath(configPath)
+	config, err := manager.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 7, config.TrashRetentionDays)
+
+	// Keys of maps are free, those of structs are checked
+	expected := []UnknownKey{
+		{File: configPath, Key: "concurrancy", Line: 3, Column: 3, Suggestion: "concurrency"},
+		{File: configPath, Key: "retry.max_retry", Line: 4, Column: 13, Suggestion: "retry.max_retries"},
+		{File: configPath, Key: "zzz", Line: 8, Column: 3},
+	}
+	assert.Equal(t, expected, manager.UnknownKeys())
+	assert.Equal(t, `unknown key "concurrancy" on line 3, did you mean "concurrency"?`, expected[0].String())
+	assert.Equal(t, `unknown key "zzz" on line 8`, expected[2].String())
+}
+
+func TestLoad_TypeMismatch(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".rosiarc.json")
+	data := "{\n  \"trash_retention_days\": 7,\n  \"retry\": {\"max_retries\": \"3\"}\n}"
+	require.NoError(t, os.WriteFile(configPath, []byte(data), 0644))
+
+	_, err := NewManagerWithPath(configPath).Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3, column 13: retry.max_retries must be int, not string")
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("depth", "depth"))
+	assert.Equal(t, 1, editDistance("concurrancy", "concurrency"))
+	assert.Equal(t, 2, editDistance("depht", "depth"))
+	assert.Equal(t, 5, editDistance("", "depth"))
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Watcher detects changes to the configuration files of a Manager, so that
+// long-running sessions such as the TUI can apply settings as they are
+// edited. Like the profile watcher, it polls the files rather than
+// subscribing to file system events.
+type Watcher struct {
+	paths []string
+	state string
+}
+
+// NewWatcher returns a Watcher of the system and user configuration files of
+// m, in their current state. Files that do not exist yet are watched for
+// their creation.
+func (m *Manager) NewWatcher() *Watcher {
+	w := &Watcher{}
+	for _, path := range []string{m.systemPath, m.configPath} {
+		if path != "" {
+			w.paths = append(w.paths, path)
+		}
+	}
+	w.state = w.snapshot()
+	return w
+}
+
+// Changed reports whether a configuration file was created, removed or
+// modified since the watcher was created or Changed last returned true
+func (w *Watcher) Changed() bool {
+	state := w.snapshot()
+	if state == w.state {
+		return false
+	}
+	w.state = state
+	return true
+}
+
+// snapshot describes the size and modification time of the watched files.
+// Missing files describe as empty.
+func (w *Watcher) snapshot() string {
+	var b strings.Builder
+	for _, path := range w.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String()
+}
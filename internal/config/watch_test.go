@@ -0,0 +1,27 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	manager := layeredManager(t, "", `{"trash_retention_days": 3}`)
+	watcher := manager.NewWatcher()
+	assert.False(t, watcher.Changed(), "no change right after creating the watcher")
+
+	// Edits are detected by size or modification time, and reported once
+	require.NoError(t, os.WriteFile(manager.configPath, []byte(`{"trash_retention_days": 7}`+"\n"), 0644))
+	assert.True(t, watcher.Changed())
+	assert.False(t, watcher.Changed())
+
+	// Files created later are watched too
+	require.NoError(t, os.WriteFile(manager.systemPath, []byte(`{}`), 0644))
+	assert.True(t, watcher.Changed())
+
+	require.NoError(t, os.Remove(manager.configPath))
+	assert.True(t, watcher.Changed())
+}
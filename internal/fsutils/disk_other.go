@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !windows
+
+package fsutils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// FreeSpace is not supported on this platform
+func FreeSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("free space measurement not supported on %s", runtime.GOOS)
+}
+
+// FilesystemID is not supported on this platform
+func FilesystemID(path string) (string, error) {
+	return "", fmt.Errorf("filesystem identification not supported on %s", runtime.GOOS)
+}
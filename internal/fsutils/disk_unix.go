@@ -0,0 +1,29 @@
+//go:build linux || darwin
+
+package fsutils
+
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+)
+
+// FreeSpace returns the number of bytes available to the current user on the
+// filesystem containing path
+func FreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("failed to stat filesystem for %s: %w", path, err)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// FilesystemID returns an identifier for the filesystem containing path.
+// Paths on the same filesystem return the same identifier.
+func FilesystemID(path string) (string, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	return strconv.FormatUint(uint64(stat.Dev), 10), nil
+}
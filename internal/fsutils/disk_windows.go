@@ -0,0 +1,48 @@
+//go:build windows
+
+package fsutils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procGetDiskFreeSpaceEx = kernel32.NewProc("GetDiskFreeSpaceExW")
+)
+
+// FreeSpace returns the number of bytes available to the current user on the
+// volume containing path
+func FreeSpace(path string) (uint64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, fmt.Errorf("invalid path %s: %w", path, err)
+	}
+
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	r, _, callErr := procGetDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&freeBytesAvailable)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFreeBytes)),
+	)
+	if r == 0 {
+		return 0, fmt.Errorf("failed to get free space for %s: %w", path, callErr)
+	}
+
+	return freeBytesAvailable, nil
+}
+
+// FilesystemID returns an identifier for the volume containing path.
+// Paths on the same volume return the same identifier.
+func FilesystemID(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	return strings.ToUpper(filepath.VolumeName(absPath)), nil
+}
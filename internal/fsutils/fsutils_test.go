@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -274,3 +275,106 @@ func splitString(s, sep string) []string {
 	}
 	return result
 }
+
+func TestFreeSpace(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skip("free space measurement not supported on " + runtime.GOOS)
+	}
+
+	free, err := FreeSpace(t.TempDir())
+	require.NoError(t, err)
+	assert.Greater(t, free, uint64(0))
+
+	_, err = FreeSpace(filepath.Join(t.TempDir(), "missing", "dir"))
+	assert.Error(t, err)
+}
+
+func TestFilesystemID(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skip("filesystem identification not supported on " + runtime.GOOS)
+	}
+
+	tmpDir := t.TempDir()
+	subDir := filepath.Join(tmpDir, "sub")
+	require.NoError(t, os.MkdirAll(subDir, 0755))
+
+	id1, err := FilesystemID(tmpDir)
+	require.NoError(t, err)
+	id2, err := FilesystemID(subDir)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, id1)
+	assert.Equal(t, id1, id2, "paths on the same filesystem should share an ID")
+}
+
+func TestRemoveAllParallel(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "node_modules")
+
+	// Build a wide and deep tree
+	for i := 0; i < 20; i++ {
+		dir := filepath.Join(root, "pkg"+string(rune('a'+i)), "lib", "nested")
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		for j := 0; j < 5; j++ {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "file"+string(rune('0'+j))), []byte("x"), 0644))
+		}
+	}
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Symlink(filepath.Join(root, "pkga"), filepath.Join(root, "link")))
+	}
+
+	require.NoError(t, RemoveAllParallel(root, 2))
+
+	_, err := os.Stat(root)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRemoveAllParallel_KeepsSymlinkTargets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	tmpDir := t.TempDir()
+	outside := filepath.Join(tmpDir, "outside")
+	require.NoError(t, os.MkdirAll(outside, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "keep.txt"), []byte("x"), 0644))
+
+	root := filepath.Join(tmpDir, "target")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "nested"), 0755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "nested", "link")))
+
+	require.NoError(t, RemoveAllParallel(root, 2))
+	assert.NoDirExists(t, root)
+	assert.FileExists(t, filepath.Join(outside, "keep.txt"))
+}
+
+func TestRemoveAllParallelProgress(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "target")
+	for i := 0; i < 10; i++ {
+		dir := filepath.Join(root, "dir"+string(rune('a'+i)))
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), make([]byte, 100*(i+1)), 0644))
+	}
+
+	var removed atomic.Int64
+	require.NoError(t, RemoveAllParallelProgress(root, 4, func(bytes int64) { removed.Add(bytes) }))
+	assert.NoDirExists(t, root)
+	assert.Equal(t, int64(5500), removed.Load())
+
+	// A file target is reported as a whole
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, make([]byte, 42), 0644))
+	removed.Store(0)
+	require.NoError(t, RemoveAllParallelProgress(file, 0, func(bytes int64) { removed.Add(bytes) }))
+	assert.Equal(t, int64(42), removed.Load())
+}
+
+func TestRemoveAllParallel_FileAndMissing(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0644))
+
+	require.NoError(t, RemoveAllParallel(file, 0))
+	assert.NoFileExists(t, file)
+
+	// Like os.RemoveAll, a missing path is not an error
+	assert.NoError(t, RemoveAllParallel(filepath.Join(tmpDir, "missing"), 0))
+}
@@ -0,0 +1,210 @@
+package fsutils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Location is a file or directory rosia keeps, either at its legacy path,
+// used by earlier versions, or at its path in the platform directories
+type Location struct {
+	Name     string // What the location holds, e.g. "trash"
+	Legacy   string // Path in ~/.rosia, or ~/.rosiarc.json for the configuration
+	Platform string // Path in the platform directories
+}
+
+// Path returns the legacy path while it exists, so existing installs keep
+// their files until 'rosia migrate-paths' moves them, and the platform path
+// otherwise
+func (l Location) Path() string {
+	if _, err := os.Lstat(l.Legacy); err == nil {
+		return l.Legacy
+	}
+	return l.Platform
+}
+
+// NeedsMigration reports whether the location still has files at its legacy
+// path
+func (l Location) NeedsMigration() bool {
+	if filepath.Clean(l.Legacy) == filepath.Clean(l.Platform) {
+		return false
+	}
+	_, err := os.Lstat(l.Legacy)
+	return err == nil
+}
+
+// GetLegacyDir returns ~/.rosia, where earlier versions kept their files
+func GetLegacyDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".rosia"), nil
+}
+
+// location returns the location name, at legacy in ~/.rosia and at the
+// path returned by platform
+func location(name, legacy string, platform func() (string, error)) (Location, error) {
+	legacyDir, err := GetLegacyDir()
+	if err != nil {
+		return Location{}, err
+	}
+	platformPath, err := platform()
+	if err != nil {
+		return Location{}, err
+	}
+	return Location{Name: name, Legacy: filepath.Join(legacyDir, legacy), Platform: platformPath}, nil
+}
+
+// inDataDir returns a function returning name in the data directory
+func inDataDir(name string) func() (string, error) {
+	return func() (string, error) {
+		dataDir, err := GetDataDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dataDir, name), nil
+	}
+}
+
+// ConfigFileLocation returns the location of the configuration file
+func ConfigFileLocation() (Location, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return Location{}, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	platform, err := GetConfigFilePath()
+	if err != nil {
+		return Location{}, err
+	}
+	return Location{Name: "configuration", Legacy: filepath.Join(homeDir, ".rosiarc.json"), Platform: platform}, nil
+}
+
+// ProfilesLocation returns the location of the user profiles
+func ProfilesLocation() (Location, error) {
+	return location("profiles", "profiles", GetProfilesDir)
+}
+
+// PluginsLocation returns the location of the installed plugins
+func PluginsLocation() (Location, error) {
+	return location("plugins", "plugins", GetPluginsDir)
+}
+
+// TrashLocation returns the location of the default trash
+func TrashLocation() (Location, error) {
+	return location("trash", "trash", GetTrashDir)
+}
+
+// StatsLocation returns the location of the telemetry statistics
+func StatsLocation() (Location, error) {
+	return location("statistics", "stats.json", GetStatsFilePath)
+}
+
+// CheckpointLocation returns the location of the checkpoint of interrupted
+// cleans
+func CheckpointLocation() (Location, error) {
+	return location("clean checkpoint", "clean-checkpoint.json", inDataDir("clean-checkpoint.json"))
+}
+
+// QueueLocation returns the location of the deferred clean queue
+func QueueLocation() (Location, error) {
+	return location("clean queue", "clean-queue.json", inDataDir("clean-queue.json"))
+}
+
+// Locations returns every location rosia keeps files at
+func Locations() ([]Location, error) {
+	var locations []Location
+	for _, fn := range []func() (Location, error){
+		ConfigFileLocation, ProfilesLocation, PluginsLocation, TrashLocation,
+		StatsLocation, CheckpointLocation, QueueLocation,
+	} {
+		l, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, nil
+}
+
+// MovePath moves the file or directory src to dst, which must not exist,
+// creating the parent directories of dst. It is copied and then removed
+// when dst is on another filesystem.
+func MovePath(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyPath(src, dst); err != nil {
+		// Leave no partial copy behind; src is still intact
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyPath copies the tree at src to dst, with the permissions of its files
+// and directories
+func copyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case entry.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// copyFile copies the regular file src to dst with permissions perm
+func copyFile(src, dst string, perm fs.FileMode) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,95 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocation_Path(t *testing.T) {
+	dir := t.TempDir()
+	location := Location{
+		Name:     "trash",
+		Legacy:   filepath.Join(dir, ".rosia", "trash"),
+		Platform: filepath.Join(dir, "data", "trash"),
+	}
+
+	// New installs use the platform path
+	assert.Equal(t, location.Platform, location.Path())
+	assert.False(t, location.NeedsMigration())
+
+	// Existing installs keep the legacy path until it is migrated
+	require.NoError(t, os.MkdirAll(location.Legacy, 0755))
+	assert.Equal(t, location.Legacy, location.Path())
+	assert.True(t, location.NeedsMigration())
+
+	// A legacy path that is the platform path has nothing to migrate
+	location.Platform = location.Legacy
+	assert.False(t, location.NeedsMigration())
+}
+
+func TestLocations(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+
+	locations, err := Locations()
+	require.NoError(t, err)
+	require.Len(t, locations, 7)
+
+	for _, location := range locations {
+		assert.NotEqual(t, location.Legacy, location.Platform, location.Name)
+		assert.False(t, location.NeedsMigration(), location.Name)
+	}
+
+	config, err := ConfigFileLocation()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".rosiarc.json"), config.Legacy)
+
+	trash, err := TrashLocation()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".rosia", "trash"), trash.Legacy)
+}
+
+func TestMovePath(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "file.txt"), []byte("content"), 0644))
+
+	dst := filepath.Join(dir, "nested", "dst")
+	require.NoError(t, MovePath(src, dst))
+
+	data, err := os.ReadFile(filepath.Join(dst, "sub", "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+	_, err = os.Stat(src)
+	assert.True(t, os.IsNotExist(err))
+
+	// An existing destination is never overwritten
+	other := filepath.Join(dir, "other")
+	require.NoError(t, os.WriteFile(other, []byte("other"), 0644))
+	err = MovePath(other, dst)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+	assert.FileExists(t, other)
+}
+
+func TestCopyPath(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "run.sh"), []byte("#!/bin/sh"), 0755))
+
+	dst := filepath.Join(dir, "dst")
+	require.NoError(t, copyPath(src, dst))
+
+	info, err := os.Stat(filepath.Join(dst, "sub", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	assert.FileExists(t, filepath.Join(src, "sub", "run.sh"))
+}
@@ -61,14 +61,14 @@ func GetConfigDir() (string, error) {
 	return configDir, nil
 }
 
-// GetConfigFilePath returns the full path to the configuration file
+// GetConfigFilePath returns the platform-specific configuration file path.
+// Existing installs may still use ~/.rosiarc.json, see ConfigFileLocation.
 func GetConfigFilePath() (string, error) {
-	// For backward compatibility, keep config file in home directory
-	homeDir, err := os.UserHomeDir()
+	configDir, err := GetConfigDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(homeDir, ".rosiarc.json"), nil
+	return filepath.Join(configDir, "config.json"), nil
 }
 
 // GetDataDir returns the platform-specific data directory
@@ -134,6 +134,15 @@ func GetTrashDir() (string, error) {
 	return filepath.Join(dataDir, "trash"), nil
 }
 
+// GetProfilesDir returns the platform-specific directory for user profiles
+func GetProfilesDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "profiles"), nil
+}
+
 // GetPluginsDir returns the platform-specific plugins directory
 func GetPluginsDir() (string, error) {
 	dataDir, err := GetDataDir()
@@ -63,12 +63,12 @@ func TestGetConfigDir(t *testing.T) {
 }
 
 func TestGetConfigFilePath(t *testing.T) {
-	homeDir, err := os.UserHomeDir()
+	configDir, err := GetConfigDir()
 	require.NoError(t, err)
 
 	configPath, err := GetConfigFilePath()
 	assert.NoError(t, err)
-	assert.Equal(t, filepath.Join(homeDir, ".rosiarc.json"), configPath)
+	assert.Equal(t, filepath.Join(configDir, "config.json"), configPath)
 }
 
 func TestGetDataDir(t *testing.T) {
@@ -146,6 +146,17 @@ func TestGetTrashDir(t *testing.T) {
 	assert.Equal(t, filepath.Join(dataDir, "trash"), trashDir)
 }
 
+func TestGetProfilesDir(t *testing.T) {
+	profilesDir, err := GetProfilesDir()
+	assert.NoError(t, err)
+	assert.Contains(t, profilesDir, "profiles")
+
+	// Verify it's under the config directory
+	configDir, err := GetConfigDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(configDir, "profiles"), profilesDir)
+}
+
 func TestGetPluginsDir(t *testing.T) {
 	pluginsDir, err := GetPluginsDir()
 	assert.NoError(t, err)
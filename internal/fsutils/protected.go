@@ -0,0 +1,124 @@
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// Protected lists the paths rosia never cleans, whatever the profiles,
+// plugins and options say: the filesystem root and the home directory, which
+// contain everything else, and the system directories and the paths given
+// to NewProtected, along with everything they contain.
+type Protected struct {
+	roots []string // Protected themselves, not what they contain
+	trees []string // Protected with everything they contain
+}
+
+// NewProtected returns the built-in protected paths along with trees, such
+// as the protected_paths of the configuration and the trash directory.
+// Empty and relative paths are ignored.
+func NewProtected(trees ...string) *Protected {
+	p := &Protected{}
+	if runtime.GOOS == "windows" {
+		p.roots = append(p.roots, filepath.VolumeName(os.Getenv("SystemDrive")+`\`)+`\`)
+		for _, env := range []string{"SystemRoot", "ProgramFiles", "ProgramFiles(x86)"} {
+			p.addTree(os.Getenv(env))
+		}
+	} else {
+		p.roots = append(p.roots, "/")
+		for _, dir := range []string{
+			"/bin", "/boot", "/dev", "/etc", "/lib", "/lib32", "/lib64", "/proc", "/sbin", "/sys", "/usr",
+			"/Applications", "/Library", "/System",
+		} {
+			p.addTree(dir)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && filepath.IsAbs(home) {
+		p.roots = append(p.roots, filepath.Clean(home))
+	}
+	for _, tree := range trees {
+		p.addTree(tree)
+	}
+	return p
+}
+
+// AddRoot protects dir itself, not what it contains, like the home
+// directory, e.g. the one of another user
+func (p *Protected) AddRoot(dir string) {
+	if dir != "" && filepath.IsAbs(dir) {
+		p.roots = append(p.roots, filepath.Clean(dir))
+	}
+}
+
+// addTree protects dir and everything it contains, under its own path and,
+// when it is a symlink like /bin on merged-/usr systems, its target's
+func (p *Protected) addTree(dir string) {
+	if dir == "" || !filepath.IsAbs(dir) {
+		return
+	}
+	p.trees = append(p.trees, filepath.Clean(dir))
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil && resolved != filepath.Clean(dir) {
+		p.trees = append(p.trees, resolved)
+	}
+}
+
+// Check returns types.ErrProtectedPath when cleaning path would remove a
+// protected path: when path is one, contains one or is inside a protected
+// tree. The path a symlink resolves to is checked as well.
+func (p *Protected) Check(path string) error {
+	if !filepath.IsAbs(path) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		path = abs
+	}
+	paths := []string{filepath.Clean(path)}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil && resolved != paths[0] {
+		paths = append(paths, resolved)
+	}
+
+	for _, path := range paths {
+		for _, tree := range p.trees {
+			if within(path, tree) || within(tree, path) {
+				return types.ErrProtectedPath{Path: path, Protected: tree}
+			}
+		}
+		for _, root := range p.roots {
+			if within(root, path) {
+				return types.ErrProtectedPath{Path: path, Protected: root}
+			}
+		}
+	}
+	return nil
+}
+
+// Inside reports whether dir is inside a protected tree, so that nothing it
+// contains may be cleaned and scans need not enter it
+func (p *Protected) Inside(dir string) bool {
+	dir = filepath.Clean(dir)
+	for _, tree := range p.trees {
+		if within(dir, tree) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether path is dir or inside it
+func within(path, dir string) bool {
+	if runtime.GOOS == "windows" {
+		path, dir = strings.ToLower(path), strings.ToLower(dir)
+	}
+	if path == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
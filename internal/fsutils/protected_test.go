@@ -0,0 +1,71 @@
+package fsutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtected_Check(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	trashDir := filepath.Join(home, "trash")
+	protected := NewProtected(trashDir, "relative/ignored", "")
+
+	type test struct {
+		name      string
+		path      string
+		protected bool
+	}
+	tests := []test{
+		{"home directory", home, true},
+		{"parent of the home directory", filepath.Dir(home), true},
+		{"inside the home directory", filepath.Join(home, "projects", "node_modules"), false},
+		{"trash directory", trashDir, true},
+		{"inside the trash directory", filepath.Join(trashDir, "item", "node_modules"), true},
+		{"next to the trash directory", filepath.Join(home, "trash-old"), false},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests,
+			test{"filesystem root", "/", true},
+			test{"inside a system directory", "/usr/lib/node_modules", true},
+		)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := protected.Check(tt.path)
+			if !tt.protected {
+				assert.NoError(t, err)
+				return
+			}
+			var protectedErr types.ErrProtectedPath
+			require.True(t, errors.As(err, &protectedErr), "expected a protected path error, got %v", err)
+			assert.Equal(t, types.ErrorCodeProtected, types.ClassifyError(err))
+		})
+	}
+}
+
+func TestProtected_CheckSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on Windows")
+	}
+	dir := t.TempDir()
+	tree := filepath.Join(dir, "protected")
+	require.NoError(t, os.MkdirAll(filepath.Join(tree, "cache"), 0755))
+	link := filepath.Join(dir, "projects", "cache")
+	require.NoError(t, os.MkdirAll(filepath.Dir(link), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(tree, "cache"), link))
+
+	// A link into a protected directory is checked where it leads
+	assert.Error(t, NewProtected(tree).Check(link))
+	assert.True(t, NewProtected(tree).Inside(filepath.Join(tree, "cache")))
+	assert.False(t, NewProtected(tree).Inside(filepath.Dir(link)))
+}
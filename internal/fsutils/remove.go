@@ -0,0 +1,59 @@
+package fsutils
+
+import (
+	"os"
+	"runtime"
+)
+
+// RemoveAllParallel removes path and everything it contains, deleting
+// subdirectories concurrently with at most workers goroutines.
+//
+// It is much faster than os.RemoveAll on trees with hundreds of thousands of
+// small files such as node_modules, where the time is dominated by per-file
+// syscall latency rather than disk bandwidth. A workers value <= 0 uses
+// NumCPU * 2. On Linux and macOS, entries are deleted relative to their open
+// directory with unlinkat. Anything the parallel pass could not delete is
+// handed to os.RemoveAll, so the error semantics match os.RemoveAll.
+func RemoveAllParallel(path string, workers int) error {
+	return RemoveAllParallelProgress(path, workers, nil)
+}
+
+// RemoveAllParallelProgress is RemoveAllParallel calling progress with the
+// size of every file it removes, from several goroutines at once. Files left
+// to the final os.RemoveAll pass are not reported.
+func RemoveAllParallelProgress(path string, workers int, progress func(bytes int64)) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	// Files and symlinks (even to directories) are removed directly
+	if !info.IsDir() {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if progress != nil && info.Mode().IsRegular() {
+			progress(info.Size())
+		}
+		return nil
+	}
+
+	if workers <= 0 {
+		workers = runtime.NumCPU() * 2
+	}
+
+	r := &parallelRemover{sem: make(chan struct{}, workers), progress: progress}
+	r.removeTree(path)
+
+	// Retry the remainder sequentially to get a consistent error
+	return os.RemoveAll(path)
+}
+
+// parallelRemover deletes a directory tree with a bounded number of goroutines
+type parallelRemover struct {
+	sem      chan struct{}
+	progress func(bytes int64) // Called with the size of each removed file, if set
+}
@@ -0,0 +1,68 @@
+//go:build !linux && !darwin
+
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// removeTree deletes the directory path and everything it contains
+func (r *parallelRemover) removeTree(path string) {
+	r.removeDir(path)
+}
+
+// removeDir deletes the contents of dir, then dir itself. Subdirectories are
+// handed to another goroutine when a worker slot is free, otherwise they are
+// processed inline so the pool can never deadlock.
+func (r *parallelRemover) removeDir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	var children sync.WaitGroup
+	for _, entry := range entries {
+		child := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			r.removeFile(child, entry)
+			continue
+		}
+
+		select {
+		case r.sem <- struct{}{}:
+			children.Add(1)
+			go func() {
+				defer func() {
+					<-r.sem
+					children.Done()
+				}()
+				r.removeDir(child)
+			}()
+		default:
+			r.removeDir(child)
+		}
+	}
+
+	// A directory can only go once all of its children are gone
+	children.Wait()
+	os.Remove(dir)
+}
+
+// removeFile deletes a file of a directory being removed. Failures are left
+// for the final os.RemoveAll pass.
+func (r *parallelRemover) removeFile(path string, entry os.DirEntry) {
+	if r.progress == nil {
+		os.Remove(path)
+		return
+	}
+
+	var size int64
+	if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+		size = info.Size()
+	}
+	if os.Remove(path) == nil && size > 0 {
+		r.progress(size)
+	}
+}
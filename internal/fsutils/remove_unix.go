@@ -0,0 +1,92 @@
+//go:build linux || darwin
+
+package fsutils
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// openDirFlags opens a directory being removed, never through a symlink
+const openDirFlags = unix.O_RDONLY | unix.O_DIRECTORY | unix.O_NOFOLLOW | unix.O_CLOEXEC
+
+// removeTree deletes the directory path and everything it contains.
+//
+// Entries are removed relative to a descriptor of their directory with
+// unlinkat, so the kernel does not resolve the whole path of every file, and
+// a directory replaced by a symlink meanwhile is never followed.
+func (r *parallelRemover) removeTree(path string) {
+	fd, err := unix.Open(path, openDirFlags, 0)
+	if err != nil {
+		return
+	}
+	r.removeContents(fd, path)
+	unix.Rmdir(path)
+}
+
+// removeContents deletes the entries of the directory open as fd, then closes
+// fd. Subdirectories are handed to another goroutine when a worker slot is
+// free, otherwise they are processed inline so the pool can never deadlock.
+func (r *parallelRemover) removeContents(fd int, dir string) {
+	file := os.NewFile(uintptr(fd), dir)
+	defer file.Close()
+
+	entries, err := file.ReadDir(-1)
+	if err != nil {
+		return
+	}
+
+	var children sync.WaitGroup
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() {
+			r.unlinkFile(fd, name)
+			continue
+		}
+
+		childFd, err := unix.Openat(fd, name, openDirFlags, 0)
+		if err != nil {
+			// Left for the final os.RemoveAll pass
+			continue
+		}
+		removeChild := func() {
+			r.removeContents(childFd, filepath.Join(dir, name))
+			unix.Unlinkat(fd, name, unix.AT_REMOVEDIR)
+		}
+
+		select {
+		case r.sem <- struct{}{}:
+			children.Add(1)
+			go func() {
+				defer func() {
+					<-r.sem
+					children.Done()
+				}()
+				removeChild()
+			}()
+		default:
+			removeChild()
+		}
+	}
+
+	// fd must stay open until the subdirectories using it are gone
+	children.Wait()
+}
+
+// unlinkFile deletes the file name of the directory open as dirfd. Failures
+// are left for the final os.RemoveAll pass.
+func (r *parallelRemover) unlinkFile(dirfd int, name string) {
+	var size int64
+	if r.progress != nil {
+		var stat unix.Stat_t
+		if unix.Fstatat(dirfd, name, &stat, unix.AT_SYMLINK_NOFOLLOW) == nil && stat.Mode&unix.S_IFMT == unix.S_IFREG {
+			size = stat.Size
+		}
+	}
+	if unix.Unlinkat(dirfd, name, 0) == nil && size > 0 {
+		r.progress(size)
+	}
+}
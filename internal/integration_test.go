@@ -271,6 +271,11 @@ func TestConcurrentScanAndClean(t *testing.T) {
 	var successCount int
 	var errorCount int
 	for progress := range progressCh {
+		if progress.Report != nil {
+			// The last progress holds the report of the whole clean
+			assert.Equal(t, 4, progress.Report.FilesDeleted)
+			continue
+		}
 		if progress.Error == nil {
 			successCount++
 		} else {
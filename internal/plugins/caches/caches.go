@@ -0,0 +1,279 @@
+// Package caches implements the built-in caches plugin. It reports the
+// global caches of package managers in the home directory, like the npm
+// cache or the Go module cache, and cleans them with the command of their
+// package manager, such as "go clean -modcache", rather than deleting their
+// files. Caches of package managers without such a command are reported as
+// directories, which the cleaner moves to the trash like any other target.
+//
+// The locations of the caches follow the variables the package managers
+// read, such as npm_config_cache, GOMODCACHE or CARGO_HOME.
+package caches
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/internal/sizecalc"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// Name is the name of the plugin, to list in the plugins of the
+// configuration to enable it
+const Name = "caches"
+
+// ProfileName is the profile name of the targets of the plugin
+const ProfileName = "Package Caches"
+
+// manager is a package manager with a global cache
+type manager struct {
+	name  string
+	dir   func(home string) string // Location of the cache
+	tools []string                 // Executables of the package manager, the first found is used
+	args  []string                 // Arguments cleaning the cache, or nil to remove it
+}
+
+// managers lists the package managers whose caches are reported
+var managers = []manager{
+	{
+		name: "npm",
+		dir: func(home string) string {
+			return filepath.Join(envOr("npm_config_cache", platform(
+				filepath.Join(home, ".npm"),
+				filepath.Join(home, ".npm"),
+				filepath.Join(localAppData(home), "npm-cache"),
+			)), "_cacache")
+		},
+		tools: []string{"npm"},
+		args:  []string{"cache", "clean", "--force"},
+	},
+	{
+		name: "yarn",
+		dir: func(home string) string {
+			return envOr("YARN_CACHE_FOLDER", platform(
+				filepath.Join(envOr("XDG_CACHE_HOME", filepath.Join(home, ".cache")), "yarn"),
+				filepath.Join(home, "Library", "Caches", "Yarn"),
+				filepath.Join(localAppData(home), "Yarn", "Cache"),
+			))
+		},
+		tools: []string{"yarn"},
+		args:  []string{"cache", "clean"},
+	},
+	{
+		name: "pnpm",
+		dir: func(home string) string {
+			return envOr("PNPM_STORE_DIR", platform(
+				filepath.Join(envOr("XDG_DATA_HOME", filepath.Join(home, ".local", "share")), "pnpm", "store"),
+				filepath.Join(home, "Library", "pnpm", "store"),
+				filepath.Join(localAppData(home), "pnpm", "store"),
+			))
+		},
+		tools: []string{"pnpm"},
+		args:  []string{"store", "prune"},
+	},
+	{
+		name: "pip",
+		dir: func(home string) string {
+			return envOr("PIP_CACHE_DIR", platform(
+				filepath.Join(envOr("XDG_CACHE_HOME", filepath.Join(home, ".cache")), "pip"),
+				filepath.Join(home, "Library", "Caches", "pip"),
+				filepath.Join(localAppData(home), "pip", "Cache"),
+			))
+		},
+		tools: []string{"pip", "pip3"},
+		args:  []string{"cache", "purge"},
+	},
+	{
+		name: "go",
+		dir: func(home string) string {
+			if dir := os.Getenv("GOMODCACHE"); dir != "" {
+				return dir
+			}
+			gopath, _, _ := strings.Cut(os.Getenv("GOPATH"), string(os.PathListSeparator))
+			if gopath == "" {
+				gopath = filepath.Join(home, "go")
+			}
+			return filepath.Join(gopath, "pkg", "mod")
+		},
+		tools: []string{"go"},
+		args:  []string{"clean", "-modcache"},
+	},
+	{
+		name: "cargo",
+		dir: func(home string) string {
+			return filepath.Join(envOr("CARGO_HOME", filepath.Join(home, ".cargo")), "registry")
+		},
+	},
+	{
+		name: "gradle",
+		dir: func(home string) string {
+			return filepath.Join(envOr("GRADLE_USER_HOME", filepath.Join(home, ".gradle")), "caches")
+		},
+	},
+}
+
+// Plugin reports and cleans the caches of package managers
+type Plugin struct{}
+
+// New returns the caches plugin
+func New() *Plugin {
+	return &Plugin{}
+}
+
+// Name returns "caches"
+func (p *Plugin) Name() string {
+	return Name
+}
+
+// Version returns the version of the plugin
+func (p *Plugin) Version() string {
+	return "1.0.0"
+}
+
+// Description describes what the plugin cleans
+func (p *Plugin) Description() string {
+	return "Global caches of npm, yarn, pnpm, pip, Go, Cargo and Gradle"
+}
+
+// Scan reports the caches found in the home directory
+func (p *Plugin) Scan(ctx context.Context) ([]types.Target, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the home directory: %w", err)
+	}
+
+	calc := sizecalc.NewSizeCalc(0)
+	var targets []types.Target
+	for _, m := range managers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		dir := m.dir(home)
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		size, err := calc.Calculate(dir)
+		if err != nil || size == 0 {
+			continue
+		}
+
+		targets = append(targets, types.Target{
+			Path:         dir,
+			Size:         size,
+			Type:         types.CategoryCache,
+			ProfileName:  ProfileName,
+			LastAccessed: info.ModTime(),
+			IsDirectory:  true,
+			RebuildHint:  m.name + " downloads packages again when needed",
+			Virtual:      m.args != nil,
+		})
+	}
+	return targets, nil
+}
+
+// Clean does nothing: the cleaner deletes the virtual targets of the plugin
+// with DeletePart, and the others like any directory
+func (p *Plugin) Clean(ctx context.Context, targets []types.Target) error {
+	return nil
+}
+
+// DeleteTarget cleans the cache of target with its package manager
+func (p *Plugin) DeleteTarget(ctx context.Context, target types.Target) error {
+	_, err := p.DeletePart(ctx, target)
+	return err
+}
+
+// DeletePart cleans the cache of target with its package manager and returns
+// the bytes freed, measured before and after, as package managers like pnpm
+// only remove the entries no project uses
+func (p *Plugin) DeletePart(ctx context.Context, target types.Target) (int64, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the home directory: %w", err)
+	}
+
+	for _, m := range managers {
+		if m.dir(home) != target.Path {
+			continue
+		}
+		if m.args == nil {
+			return 0, fmt.Errorf("%s has no command cleaning its cache, which is deleted as a directory", m.name)
+		}
+
+		before, err := measure(target.Path)
+		if err != nil {
+			return 0, err
+		}
+		if err := m.clean(ctx); err != nil {
+			return 0, err
+		}
+		after, err := measure(target.Path)
+		if err != nil {
+			return 0, err
+		}
+		return max(before-after, 0), nil
+	}
+	return 0, fmt.Errorf("%s is not the cache of a package manager", target.Path)
+}
+
+// clean runs the command of m cleaning its cache
+func (m manager) clean(ctx context.Context) error {
+	for _, tool := range m.tools {
+		path, err := exec.LookPath(tool)
+		if err != nil {
+			continue
+		}
+		output, err := exec.CommandContext(ctx, path, m.args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s %s failed: %w: %s", tool, strings.Join(m.args, " "), err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not installed, so its cache cannot be cleaned", m.name)
+}
+
+// measure returns the size of the cache at path, 0 when it does not exist
+func measure(path string) (int64, error) {
+	size, err := sizecalc.NewSizeCalc(0).Calculate(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure %s: %w", path, err)
+	}
+	return size, nil
+}
+
+// envOr returns the value of the environment variable key, or fallback
+// when it is empty
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// platform returns the value for the current operating system: darwin on
+// macOS, windows on Windows and unix elsewhere
+func platform(unix, darwin, windows string) string {
+	switch runtime.GOOS {
+	case "darwin":
+		return darwin
+	case "windows":
+		return windows
+	}
+	return unix
+}
+
+// localAppData returns the local application data directory of Windows
+func localAppData(home string) string {
+	return envOr("LOCALAPPDATA", filepath.Join(home, "AppData", "Local"))
+}
@@ -0,0 +1,167 @@
+package caches
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// fakeHome makes a temporary directory the home directory, with the
+// variables locating caches unset
+func fakeHome(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake package managers are shell scripts")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, key := range []string{"npm_config_cache", "YARN_CACHE_FOLDER", "PNPM_STORE_DIR", "PIP_CACHE_DIR", "GOMODCACHE", "GOPATH", "CARGO_HOME", "GRADLE_USER_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME"} {
+		t.Setenv(key, "")
+	}
+	return home
+}
+
+// writeFile creates a file of size bytes at path
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	home := fakeHome(t)
+	writeFile(t, filepath.Join(home, ".npm", "_cacache", "index"), 100)
+	writeFile(t, filepath.Join(home, ".cargo", "registry", "cache", "serde.crate"), 200)
+	gomodcache := filepath.Join(t.TempDir(), "mod")
+	t.Setenv("GOMODCACHE", gomodcache)
+	writeFile(t, filepath.Join(gomodcache, "cache", "download", "list"), 300)
+	// Empty caches are not reported
+	if err := os.MkdirAll(filepath.Join(home, ".gradle", "caches"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	targets, err := New().Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	expected := map[string]int64{
+		filepath.Join(home, ".npm", "_cacache"):   100,
+		filepath.Join(home, ".cargo", "registry"): 200,
+		gomodcache: 300,
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("Expected %d targets, got %d: %+v", len(expected), len(targets), targets)
+	}
+	for _, target := range targets {
+		size, exists := expected[target.Path]
+		if !exists {
+			t.Errorf("Unexpected target %s", target.Path)
+			continue
+		}
+		if target.Size != size {
+			t.Errorf("Expected %s to have size %d, got %d", target.Path, size, target.Size)
+		}
+		if target.Type != types.CategoryCache || target.ProfileName != ProfileName {
+			t.Errorf("Expected a cache target of profile %s, got %+v", ProfileName, target)
+		}
+		// Caches without a command cleaning them are deleted as directories
+		if virtual := target.Path != filepath.Join(home, ".cargo", "registry"); target.Virtual != virtual {
+			t.Errorf("Expected %s to be virtual: %v, got %v", target.Path, virtual, target.Virtual)
+		}
+	}
+}
+
+func TestDeleteTarget(t *testing.T) {
+	home := fakeHome(t)
+	npmCache := filepath.Join(home, ".npm", "_cacache")
+	writeFile(t, filepath.Join(npmCache, "index"), 100)
+	cargoRegistry := filepath.Join(home, ".cargo", "registry")
+	writeFile(t, filepath.Join(cargoRegistry, "cache", "serde.crate"), 200)
+
+	// A fake npm records its arguments
+	bin := t.TempDir()
+	calls := filepath.Join(t.TempDir(), "calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "npm"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to create fake npm: %v", err)
+	}
+	t.Setenv("PATH", bin)
+
+	plugin := New()
+	ctx := context.Background()
+
+	// npm cleans its cache itself
+	if err := plugin.DeleteTarget(ctx, types.Target{Path: npmCache}); err != nil {
+		t.Fatalf("DeleteTarget of the npm cache failed: %v", err)
+	}
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatalf("npm was not run: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "cache clean --force" {
+		t.Errorf("Expected npm cache clean --force, got: %s", data)
+	}
+	if _, err := os.Stat(npmCache); err != nil {
+		t.Error("Expected the npm cache to be left to npm")
+	}
+
+	// Cargo has no command to, its registry is left to the cleaner
+	if err := plugin.DeleteTarget(ctx, types.Target{Path: cargoRegistry}); err == nil {
+		t.Error("Expected an error for the cargo registry")
+	}
+	if _, err := os.Stat(cargoRegistry); err != nil {
+		t.Error("Expected the cargo registry to be left")
+	}
+
+	// Package managers that are not installed are reported
+	err = plugin.DeleteTarget(ctx, types.Target{Path: filepath.Join(home, "go", "pkg", "mod")})
+	if err == nil || !strings.Contains(err.Error(), "go is not installed") {
+		t.Errorf("Expected go not to be installed, got: %v", err)
+	}
+
+	// Other paths are never deleted
+	other := filepath.Join(home, "projects")
+	writeFile(t, filepath.Join(other, "main.go"), 10)
+	if err := plugin.DeleteTarget(ctx, types.Target{Path: other}); err == nil {
+		t.Error("Expected an error for a path that is not a cache")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Error("Expected the other path to be left")
+	}
+}
+
+func TestDeletePart(t *testing.T) {
+	home := fakeHome(t)
+	store := filepath.Join(home, ".local", "share", "pnpm", "store")
+	writeFile(t, filepath.Join(store, "v3", "used"), 100)
+	writeFile(t, filepath.Join(store, "v3", "unused"), 300)
+
+	// A fake pnpm prunes the packages no project uses
+	bin := t.TempDir()
+	script := "#!/bin/sh\n: > " + filepath.Join(store, "v3", "unused") + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "pnpm"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to create fake pnpm: %v", err)
+	}
+	t.Setenv("PATH", bin)
+
+	freed, err := New().DeletePart(context.Background(), types.Target{Path: store, Size: 400})
+	if err != nil {
+		t.Fatalf("DeletePart failed: %v", err)
+	}
+	if freed != 300 {
+		t.Errorf("Expected 300 bytes freed, got %d", freed)
+	}
+	if _, err := os.Stat(filepath.Join(store, "v3", "used")); err != nil {
+		t.Error("Expected the packages in use to be left")
+	}
+}
@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// apiVersion is the version of the Docker Engine API used, that of Docker
+// 20.10, so older daemons are refused rather than misunderstood
+const apiVersion = "v1.41"
+
+// defaultHost returns the address of the Docker daemon when DOCKER_HOST is
+// not set
+func defaultHost() string {
+	if runtime.GOOS == "windows" {
+		return "npipe:////./pipe/docker_engine"
+	}
+	return "unix:///var/run/docker.sock"
+}
+
+// client calls the Docker Engine API
+type client struct {
+	http *http.Client
+	base string // URL of the API, without the version
+}
+
+// newClient returns a client of the Docker daemon listening at host, a
+// unix://, tcp://, http:// or https:// address
+func newClient(host string) (*client, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Docker host %s: %w", host, err)
+	}
+
+	transport := &http.Transport{}
+	c := &client{http: &http.Client{Transport: transport, Timeout: time.Minute}}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		c.base = "http://docker"
+	case "tcp", "http":
+		c.base = "http://" + u.Host
+	case "https":
+		c.base = "https://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported Docker host %s (use unix://, tcp://, http:// or https://)", host)
+	}
+	return c, nil
+}
+
+// do calls the API and decodes its JSON response into result, if not nil
+func (c *client) do(ctx context.Context, method, path string, query url.Values, result any) error {
+	endpoint := c.base + "/" + apiVersion + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker is not available: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+		return fmt.Errorf("docker: %s (status %d)", apiErr.Message, resp.StatusCode)
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("invalid response of docker to %s: %w", path, err)
+	}
+	return nil
+}
+
+// diskUsage is the response of /system/df, restricted to the fields used
+type diskUsage struct {
+	Images []struct {
+		ID         string   `json:"Id"`
+		RepoTags   []string `json:"RepoTags"`
+		Created    int64    `json:"Created"`
+		Size       int64    `json:"Size"`
+		SharedSize int64    `json:"SharedSize"`
+		Containers int64    `json:"Containers"`
+	} `json:"Images"`
+	Containers []struct {
+		ID      string   `json:"Id"`
+		Names   []string `json:"Names"`
+		Created int64    `json:"Created"`
+		State   string   `json:"State"`
+		SizeRw  int64    `json:"SizeRw"`
+	} `json:"Containers"`
+	Volumes []struct {
+		Name      string `json:"Name"`
+		CreatedAt string `json:"CreatedAt"`
+		UsageData *struct {
+			Size     int64 `json:"Size"`
+			RefCount int64 `json:"RefCount"`
+		} `json:"UsageData"`
+	} `json:"Volumes"`
+	BuildCache []struct {
+		ID         string     `json:"ID"`
+		InUse      bool       `json:"InUse"`
+		Size       int64      `json:"Size"`
+		CreatedAt  time.Time  `json:"CreatedAt"`
+		LastUsedAt *time.Time `json:"LastUsedAt"`
+	} `json:"BuildCache"`
+}
+
+// diskUsage returns what the daemon stores
+func (c *client) diskUsage(ctx context.Context) (*diskUsage, error) {
+	var usage diskUsage
+	if err := c.do(ctx, http.MethodGet, "/system/df", nil, &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
@@ -0,0 +1,266 @@
+// Package docker implements the built-in docker plugin. It reports the
+// dangling images, stopped containers, unused volumes and builder cache of
+// the Docker daemon as virtual targets, and deletes them through the Docker
+// Engine API, so they are cleaned alongside the targets of profiles.
+//
+// Settings, under "docker" in plugin_settings:
+//
+//	include_volumes  Report volumes no container uses (default false, as volumes hold data)
+//	host             Address of the daemon, like DOCKER_HOST (default: DOCKER_HOST or the local socket)
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// Name is the name of the plugin, to list in the plugins of the
+// configuration to enable it
+const Name = "docker"
+
+// ProfileName is the profile name of the targets of the plugin
+const ProfileName = "Docker"
+
+// Kinds of Docker resources, the first element of the paths of targets:
+// docker://<kind>/<id>
+const (
+	KindImage      = "image"
+	KindContainer  = "container"
+	KindVolume     = "volume"
+	KindBuildCache = "build-cache"
+)
+
+// Plugin reports and deletes unused Docker resources
+type Plugin struct {
+	mu             sync.Mutex
+	host           string
+	includeVolumes bool
+	client         *client
+}
+
+// New returns the docker plugin, connecting to DOCKER_HOST or the local
+// daemon
+func New() *Plugin {
+	return &Plugin{}
+}
+
+// Name returns "docker"
+func (p *Plugin) Name() string {
+	return Name
+}
+
+// Version returns the version of the plugin
+func (p *Plugin) Version() string {
+	return "1.0.0"
+}
+
+// Description describes what the plugin cleans
+func (p *Plugin) Description() string {
+	return "Dangling images, stopped containers, unused volumes and builder cache of Docker"
+}
+
+// Configure reads the include_volumes and host settings. Invalid settings
+// leave the plugin as it was.
+func (p *Plugin) Configure(settings map[string]any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	includeVolumes, host := p.includeVolumes, p.host
+	for key, value := range settings {
+		switch key {
+		case "include_volumes":
+			include, ok := value.(bool)
+			if !ok {
+				return fmt.Errorf("include_volumes must be true or false")
+			}
+			includeVolumes = include
+		case "host":
+			address, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("host must be a string")
+			}
+			if _, err := newClient(address); err != nil {
+				return err
+			}
+			host = address
+		default:
+			return fmt.Errorf("unknown setting %s", key)
+		}
+	}
+
+	if host != p.host {
+		p.client = nil
+	}
+	p.includeVolumes, p.host = includeVolumes, host
+	return nil
+}
+
+// connect returns the client of the daemon, created on first use
+func (p *Plugin) connect() (*client, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client == nil {
+		host := p.host
+		if host == "" {
+			host = os.Getenv("DOCKER_HOST")
+		}
+		if host == "" {
+			host = defaultHost()
+		}
+		c, err := newClient(host)
+		if err != nil {
+			return nil, false, err
+		}
+		p.client = c
+	}
+	return p.client, p.includeVolumes, nil
+}
+
+// Scan reports the unused resources of the daemon
+func (p *Plugin) Scan(ctx context.Context) ([]types.Target, error) {
+	c, includeVolumes, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+	usage, err := c.diskUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []types.Target
+	for _, image := range usage.Images {
+		if !dangling(image.RepoTags) || image.Containers > 0 {
+			continue
+		}
+		size := image.Size
+		if image.SharedSize > 0 {
+			size -= image.SharedSize
+		}
+		targets = append(targets, target(KindImage, shortID(image.ID), size, types.CategoryCache, time.Unix(image.Created, 0)))
+	}
+
+	for _, container := range usage.Containers {
+		if container.State != "exited" && container.State != "created" && container.State != "dead" {
+			continue
+		}
+		id := shortID(container.ID)
+		if len(container.Names) > 0 {
+			id = strings.TrimPrefix(container.Names[0], "/")
+		}
+		targets = append(targets, target(KindContainer, id, container.SizeRw, "", time.Unix(container.Created, 0)))
+	}
+
+	if includeVolumes {
+		for _, volume := range usage.Volumes {
+			if volume.UsageData == nil || volume.UsageData.RefCount != 0 {
+				continue
+			}
+			created, _ := time.Parse(time.RFC3339, volume.CreatedAt)
+			targets = append(targets, target(KindVolume, volume.Name, max(volume.UsageData.Size, 0), "", created))
+		}
+	}
+
+	for _, record := range usage.BuildCache {
+		if record.InUse {
+			continue
+		}
+		used := record.CreatedAt
+		if record.LastUsedAt != nil {
+			used = *record.LastUsedAt
+		}
+		targets = append(targets, target(KindBuildCache, record.ID, record.Size, types.CategoryCache, used))
+	}
+
+	return targets, nil
+}
+
+// Clean does nothing: the cleaner deletes every target of the plugin with
+// DeleteTarget
+func (p *Plugin) Clean(ctx context.Context, targets []types.Target) error {
+	return nil
+}
+
+// DeleteTarget removes the Docker resource of target
+func (p *Plugin) DeleteTarget(ctx context.Context, target types.Target) error {
+	kind, id, err := ParsePath(target.Path)
+	if err != nil {
+		return err
+	}
+	c, _, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case KindImage:
+		return c.do(ctx, http.MethodDelete, "/images/"+url.PathEscape(id), nil, nil)
+	case KindContainer:
+		return c.do(ctx, http.MethodDelete, "/containers/"+url.PathEscape(id), nil, nil)
+	case KindVolume:
+		return c.do(ctx, http.MethodDelete, "/volumes/"+url.PathEscape(id), nil, nil)
+	default:
+		filters := fmt.Sprintf(`{"id":[%q]}`, id)
+		return c.do(ctx, http.MethodPost, "/build/prune", url.Values{"filters": {filters}}, nil)
+	}
+}
+
+// ParsePath returns the kind and ID of the resource of a target path,
+// docker://<kind>/<id>
+func ParsePath(path string) (kind, id string, err error) {
+	rest, ok := strings.CutPrefix(path, "docker://")
+	if ok {
+		kind, id, ok = strings.Cut(rest, "/")
+	}
+	if !ok || id == "" {
+		return "", "", fmt.Errorf("not a Docker target: %s", path)
+	}
+	switch kind {
+	case KindImage, KindContainer, KindVolume, KindBuildCache:
+		return kind, id, nil
+	}
+	return "", "", fmt.Errorf("unknown kind of Docker target: %s", path)
+}
+
+// target returns the virtual target of a resource. Resources without a
+// category have the profile name as type, like targets of profiles.
+func target(kind, id string, size int64, category string, used time.Time) types.Target {
+	if category == "" {
+		category = ProfileName
+	}
+	return types.Target{
+		Path:         "docker://" + kind + "/" + id,
+		Size:         size,
+		Type:         category,
+		ProfileName:  ProfileName,
+		LastAccessed: used,
+		Virtual:      true,
+	}
+}
+
+// dangling reports whether an image with tags is untagged
+func dangling(tags []string) bool {
+	for _, tag := range tags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+// shortID returns the 12 character form of a Docker ID
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
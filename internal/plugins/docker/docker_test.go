@@ -0,0 +1,178 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// diskUsageResponse lists one resource of every kind in use and one unused
+const diskUsageResponse = `{
+  "Images": [
+    {"Id": "sha256:1111111111111111aaaa", "RepoTags": ["<none>:<none>"], "Created": 1700000000, "Size": 300, "SharedSize": 100, "Containers": 0},
+    {"Id": "sha256:2222222222222222bbbb", "RepoTags": ["node:20"], "Created": 1700000000, "Size": 1000, "SharedSize": 0, "Containers": 0},
+    {"Id": "sha256:3333333333333333cccc", "RepoTags": null, "Created": 1700000000, "Size": 50, "SharedSize": 0, "Containers": 1}
+  ],
+  "Containers": [
+    {"Id": "4444444444444444dddd", "Names": ["/old_app"], "Created": 1700000000, "State": "exited", "SizeRw": 20},
+    {"Id": "5555555555555555eeee", "Names": ["/web"], "Created": 1700000000, "State": "running", "SizeRw": 30}
+  ],
+  "Volumes": [
+    {"Name": "orphan", "CreatedAt": "2024-01-02T03:04:05Z", "UsageData": {"Size": 4096, "RefCount": 0}},
+    {"Name": "db", "CreatedAt": "2024-01-02T03:04:05Z", "UsageData": {"Size": 8192, "RefCount": 1}}
+  ],
+  "BuildCache": [
+    {"ID": "cacheunused", "InUse": false, "Size": 700, "CreatedAt": "2024-01-02T03:04:05Z", "LastUsedAt": "2024-02-02T03:04:05Z"},
+    {"ID": "cacheinuse", "InUse": true, "Size": 900, "CreatedAt": "2024-01-02T03:04:05Z"}
+  ]
+}`
+
+// dockerServer fakes the Docker Engine API, recording the requests it
+// receives other than /system/df
+func dockerServer(t *testing.T) (*Plugin, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/"+apiVersion+"/system/df" {
+			w.Write([]byte(diskUsageResponse))
+			return
+		}
+		if r.URL.Path == "/"+apiVersion+"/images/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"No such image: missing"}`))
+			return
+		}
+		mu.Lock()
+		requests = append(requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/"+apiVersion)+" "+r.URL.Query().Get("filters"))
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	plugin := New()
+	if err := plugin.Configure(map[string]any{"host": "tcp://" + strings.TrimPrefix(server.URL, "http://")}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	return plugin, &requests
+}
+
+func TestScan(t *testing.T) {
+	plugin, _ := dockerServer(t)
+
+	targets, err := plugin.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	expected := map[string]int64{
+		"docker://image/111111111111":      200,
+		"docker://container/old_app":       20,
+		"docker://build-cache/cacheunused": 700,
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("Expected %d targets, got %d: %+v", len(expected), len(targets), targets)
+	}
+	for _, target := range targets {
+		size, exists := expected[target.Path]
+		if !exists {
+			t.Errorf("Unexpected target %s", target.Path)
+			continue
+		}
+		if target.Size != size {
+			t.Errorf("Expected %s to have size %d, got %d", target.Path, size, target.Size)
+		}
+		if !target.Virtual || target.ProfileName != ProfileName {
+			t.Errorf("Expected a virtual target of profile %s, got %+v", ProfileName, target)
+		}
+	}
+
+	// Volumes hold data, so they are only reported when asked for
+	if err := plugin.Configure(map[string]any{"include_volumes": true}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	targets, err = plugin.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	var volumes []string
+	for _, target := range targets {
+		if strings.HasPrefix(target.Path, "docker://volume/") {
+			volumes = append(volumes, target.Path)
+		}
+	}
+	if len(volumes) != 1 || volumes[0] != "docker://volume/orphan" {
+		t.Errorf("Expected the unused volume, got %v", volumes)
+	}
+}
+
+func TestDeleteTarget(t *testing.T) {
+	plugin, requests := dockerServer(t)
+	ctx := context.Background()
+
+	for _, path := range []string{
+		"docker://image/111111111111",
+		"docker://container/old_app",
+		"docker://volume/orphan",
+		"docker://build-cache/cacheunused",
+	} {
+		if err := plugin.DeleteTarget(ctx, types.Target{Path: path}); err != nil {
+			t.Errorf("DeleteTarget of %s failed: %v", path, err)
+		}
+	}
+
+	expected := []string{
+		"DELETE /images/111111111111 ",
+		"DELETE /containers/old_app ",
+		"DELETE /volumes/orphan ",
+		`POST /build/prune {"id":["cacheunused"]}`,
+	}
+	if strings.Join(*requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected requests:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(*requests, "\n"))
+	}
+
+	// Errors of the daemon are reported with its message
+	err := plugin.DeleteTarget(ctx, types.Target{Path: "docker://image/missing"})
+	if err == nil || !strings.Contains(err.Error(), "No such image: missing") {
+		t.Errorf("Expected the error of the daemon, got: %v", err)
+	}
+	if err := plugin.DeleteTarget(ctx, types.Target{Path: "/tmp/node_modules"}); err == nil {
+		t.Error("Expected an error for a path that is not a Docker target")
+	}
+}
+
+func TestConfigure_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+		want     string
+	}{
+		{name: "include volumes", settings: map[string]any{"include_volumes": "yes"}, want: "include_volumes must be true or false"},
+		{name: "host", settings: map[string]any{"host": "ssh://server"}, want: "unsupported Docker host"},
+		{name: "unknown", settings: map[string]any{"prune_all": true}, want: "unknown setting prune_all"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New().Configure(tt.settings)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestScan_Unavailable(t *testing.T) {
+	plugin := New()
+	if err := plugin.Configure(map[string]any{"host": "unix://" + t.TempDir() + "/docker.sock"}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+
+	if _, err := plugin.Scan(context.Background()); err == nil || !strings.Contains(err.Error(), "docker is not available") {
+		t.Errorf("Expected docker to be unavailable, got: %v", err)
+	}
+}
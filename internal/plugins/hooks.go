@@ -0,0 +1,83 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// ScanStartHook is implemented by plugins notified when a scan starts, with
+// the paths it scans
+type ScanStartHook interface {
+	OnScanStart(ctx context.Context, paths []string) error
+}
+
+// TargetFoundHook is implemented by plugins observing every target a scan
+// finds, including those of plugins. The hook may change the target, for
+// instance add Keep patterns of entries to preserve or set its RebuildHint.
+// Scans call it from several goroutines at once.
+type TargetFoundHook interface {
+	OnTargetFound(ctx context.Context, target *types.Target) error
+}
+
+// CleanCompleteHook is implemented by plugins notified when a clean
+// completes, with its report
+type CleanCompleteHook interface {
+	OnCleanComplete(ctx context.Context, report *types.CleanReport) error
+}
+
+// NotifyScanStart calls the ScanStartHook of the plugins of registry.
+// Errors are logged, so a failing hook never stops the scan. Hooks are
+// isolated like Scan, with DefaultTimeout.
+func NotifyScanStart(ctx context.Context, registry PluginRegistry, paths []string) {
+	for _, plugin := range registry.List() {
+		if hook, ok := plugin.(ScanStartHook); ok {
+			if err := runHook(ctx, "scan start hook", func(ctx context.Context) error {
+				return hook.OnScanStart(ctx, paths)
+			}); err != nil {
+				logger.Warn("Plugin %s scan start hook failed: %v", plugin.Name(), err)
+			}
+		}
+	}
+}
+
+// NotifyTargetFound calls the TargetFoundHook of the plugins of registry on
+// target, in the order of their names. Errors are logged and leave the
+// target as the failing hook found it.
+func NotifyTargetFound(ctx context.Context, registry PluginRegistry, target *types.Target) {
+	for _, plugin := range registry.List() {
+		if hook, ok := plugin.(TargetFoundHook); ok {
+			found := *target
+			if err := runHook(ctx, "target hook", func(ctx context.Context) error {
+				return hook.OnTargetFound(ctx, &found)
+			}); err != nil {
+				logger.Warn("Plugin %s target hook failed for %s: %v", plugin.Name(), target.Path, err)
+				continue
+			}
+			*target = found
+		}
+	}
+}
+
+// NotifyCleanComplete calls the CleanCompleteHook of the plugins of
+// registry. Errors are logged, the clean being done.
+func NotifyCleanComplete(ctx context.Context, registry PluginRegistry, report *types.CleanReport) {
+	for _, plugin := range registry.List() {
+		if hook, ok := plugin.(CleanCompleteHook); ok {
+			if err := runHook(ctx, "clean complete hook", func(ctx context.Context) error {
+				return hook.OnCleanComplete(ctx, report)
+			}); err != nil {
+				logger.Warn("Plugin %s clean complete hook failed: %v", plugin.Name(), err)
+			}
+		}
+	}
+}
+
+// runHook runs call, the hook of a plugin, isolated from the caller
+func runHook(ctx context.Context, hook string, call func(context.Context) error) error {
+	_, err := isolate(ctx, hook, DefaultTimeout, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, call(ctx)
+	})
+	return err
+}
@@ -0,0 +1,97 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// hookPlugin records the hooks called and marks the targets it finds
+type hookPlugin struct {
+	mockPlugin
+	fail      bool
+	started   []string
+	completed *types.CleanReport
+}
+
+func (p *hookPlugin) OnScanStart(ctx context.Context, paths []string) error {
+	p.started = paths
+	return nil
+}
+
+func (p *hookPlugin) OnTargetFound(ctx context.Context, target *types.Target) error {
+	target.RebuildHint = "hinted by " + p.name
+	if p.fail {
+		return errors.New("hook failed")
+	}
+	return nil
+}
+
+func (p *hookPlugin) OnCleanComplete(ctx context.Context, report *types.CleanReport) error {
+	p.completed = report
+	return nil
+}
+
+func TestNotifyHooks(t *testing.T) {
+	registry := NewRegistry()
+	hooked := &hookPlugin{mockPlugin: mockPlugin{name: "b-hooked", version: "1.0.0"}}
+	failing := &hookPlugin{mockPlugin: mockPlugin{name: "c-failing", version: "1.0.0"}, fail: true}
+	plain := &mockPlugin{name: "a-plain", version: "1.0.0"}
+	for _, plugin := range []Plugin{hooked, failing, plain} {
+		if err := registry.Register(plugin); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+	ctx := context.Background()
+
+	NotifyScanStart(ctx, registry, []string{"/projects"})
+	if len(hooked.started) != 1 || hooked.started[0] != "/projects" {
+		t.Errorf("Expected the scan start hook to receive the paths, got %v", hooked.started)
+	}
+
+	// The change of a failing hook is dropped
+	target := types.Target{Path: "/projects/app/node_modules"}
+	NotifyTargetFound(ctx, registry, &target)
+	if target.RebuildHint != "hinted by b-hooked" {
+		t.Errorf("Expected the hint of b-hooked only, got '%s'", target.RebuildHint)
+	}
+
+	report := types.NewCleanReport()
+	NotifyCleanComplete(ctx, registry, report)
+	if hooked.completed != report {
+		t.Error("Expected the clean complete hook to receive the report")
+	}
+}
+
+func TestProcessPluginHooks(t *testing.T) {
+	dir := t.TempDir()
+	record := filepath.Join(dir, "hooks.json")
+	path := writeProcessPlugin(t, dir, "test", map[string]string{
+		"initialize": `echo '{"jsonrpc":"2.0","id":'$id',"result":{"name":"test","version":"1.0.0","protocol_version":1,"hooks":["scan_start"]}}'`,
+		"scan_start": `printf '%s\n' "$line" >> '` + record + `'; echo '{"jsonrpc":"2.0","id":'$id',"result":{}}'`,
+	})
+
+	plugin, err := LoadProcess(path)
+	if err != nil {
+		t.Fatalf("LoadProcess failed: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := plugin.OnScanStart(ctx, []string{"/projects"}); err != nil {
+		t.Fatalf("OnScanStart failed: %v", err)
+	}
+	data, err := os.ReadFile(record)
+	if err != nil || !strings.Contains(string(data), `"paths":["/projects"]`) {
+		t.Errorf("Expected scan_start to be called with the paths, got %s, %v", data, err)
+	}
+
+	// Hooks the plugin does not list are not called, so it is not started
+	if err := plugin.OnCleanComplete(ctx, types.NewCleanReport()); err != nil {
+		t.Errorf("Expected no call of clean_complete, got: %v", err)
+	}
+}
@@ -0,0 +1,582 @@
+package plugins
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultIndexURL is the index of the plugins that can be installed by name
+const DefaultIndexURL = "https://raw.githubusercontent.com/raucheacho/rosia-plugins/main/index.json"
+
+// manifestFile records the plugins installed in a plugins directory. Being
+// hidden, it is not mistaken for a plugin.
+const manifestFile = ".installed.json"
+
+// maxDownloadSize bounds the size of plugin downloads and of the files
+// extracted from archives
+const maxDownloadSize = 256 << 20
+
+// Index lists the plugins that can be installed by name
+type Index struct {
+	Plugins []IndexEntry `json:"plugins"`
+}
+
+// IndexEntry is a plugin of the index, with a download per platform
+type IndexEntry struct {
+	Name        string              `json:"name"`        // Name of the plugin
+	Version     string              `json:"version"`     // Latest version
+	Description string              `json:"description"` // Human-readable description
+	Downloads   map[string]Download `json:"downloads"`   // Downloads by platform, such as linux/amd64
+}
+
+// Download is a plugin file, or an archive holding one, and its checksum
+type Download struct {
+	URL        string `json:"url"`    // Location of the file or archive
+	SHA256     string `json:"sha256"` // Hex SHA-256 checksum of the file or archive
+	Unverified bool   `json:"-"`      // The checksum, if any, proves the download is intact, not where it comes from
+}
+
+// Installation records a plugin installed from an index or a URL
+type Installation struct {
+	Name        string    `json:"name"`                 // Name of the plugin
+	Version     string    `json:"version"`              // Version installed
+	File        string    `json:"file"`                 // File name in the plugins directory
+	Source      string    `json:"source"`               // Name in the index or URL installed from
+	SHA256      string    `json:"sha256"`               // Checksum of the download
+	Unverified  bool      `json:"unverified,omitempty"` // Installed with --insecure, see Download.Unverified
+	InstalledAt time.Time `json:"installed_at"`         // When the plugin was installed or updated
+}
+
+// InstallOptions configures the verification of downloads
+type InstallOptions struct {
+	SHA256   string // Expected checksum of a download from a URL
+	Insecure bool   // Install downloads that cannot be verified
+
+	// Confirm is called with the source and the verified download before
+	// the plugin is loaded, which runs its code. Returning false cancels the
+	// install. (optional)
+	Confirm func(source string, download Download) bool
+}
+
+// ErrInstallCancelled is returned when InstallOptions.Confirm declines an
+// install
+var ErrInstallCancelled = errors.New("install cancelled")
+
+// Installer installs, updates and removes the plugins of a plugins directory
+type Installer struct {
+	Dir      string       // Plugins directory
+	IndexURL string       // Index of the plugins installed by name
+	Client   *http.Client // Client of the downloads
+	loader   *Loader
+}
+
+// NewInstaller creates an installer of plugins into dir
+func NewInstaller(dir string) *Installer {
+	return &Installer{
+		Dir:      dir,
+		IndexURL: DefaultIndexURL,
+		Client:   &http.Client{Timeout: 5 * time.Minute},
+		loader:   NewLoader(),
+	}
+}
+
+// Install downloads the plugin of source, a URL or a name of the index,
+// verifies its checksum, confirms the install with opts.Confirm, checks that
+// the plugin loads and installs it. A plugin of the same name installed
+// before is replaced.
+func (i *Installer) Install(ctx context.Context, source string, opts InstallOptions) (*Installation, error) {
+	download, version, err := i.resolve(ctx, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	return i.install(ctx, source, version, download, opts.Confirm)
+}
+
+// Update installs the latest version of the installed plugin name. It
+// returns false when the plugin is up to date.
+func (i *Installer) Update(ctx context.Context, name string, opts InstallOptions) (*Installation, bool, error) {
+	installed, err := i.Installed()
+	if err != nil {
+		return nil, false, err
+	}
+	current, exists := installed[name]
+	if !exists {
+		return nil, false, fmt.Errorf("plugin %s was not installed with rosia plugin install", name)
+	}
+
+	download, version, err := i.resolve(ctx, current.Source, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	// The index gives versions, URLs only their checksum
+	upToDate := version == current.Version
+	if version == "" {
+		upToDate = download.SHA256 != "" && strings.EqualFold(download.SHA256, current.SHA256)
+	}
+	if upToDate {
+		return &current, false, nil
+	}
+
+	updated, err := i.install(ctx, current.Source, version, download, opts.Confirm)
+	if err != nil {
+		return nil, false, err
+	}
+	return updated, true, nil
+}
+
+// Remove deletes the installed plugin name. Plugins copied into the plugins
+// directory by hand are found by their file name, name or name.so.
+func (i *Installer) Remove(name string) (*Installation, error) {
+	installed, err := i.Installed()
+	if err != nil {
+		return nil, err
+	}
+
+	installation, exists := installed[name]
+	if !exists {
+		installation = Installation{Name: name}
+		for _, file := range []string{name, name + ".so"} {
+			if _, err := os.Stat(filepath.Join(i.Dir, file)); err == nil {
+				installation.File = file
+				break
+			}
+		}
+		if installation.File == "" {
+			return nil, fmt.Errorf("plugin %s is not installed in %s", name, i.Dir)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(i.Dir, installation.File)); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove plugin %s: %w", name, err)
+	}
+
+	delete(installed, name)
+	if err := i.saveInstalled(installed); err != nil {
+		return nil, err
+	}
+	return &installation, nil
+}
+
+// Installed returns the plugins installed by the installer, by name
+func (i *Installer) Installed() (map[string]Installation, error) {
+	installed := make(map[string]Installation)
+
+	data, err := os.ReadFile(filepath.Join(i.Dir, manifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return installed, nil
+		}
+		return nil, fmt.Errorf("failed to read installed plugins: %w", err)
+	}
+
+	var list []Installation
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse installed plugins: %w", err)
+	}
+	for _, installation := range list {
+		installed[installation.Name] = installation
+	}
+	return installed, nil
+}
+
+// saveInstalled writes the manifest of the installed plugins
+func (i *Installer) saveInstalled(installed map[string]Installation) error {
+	list := make([]Installation, 0, len(installed))
+	for _, installation := range installed {
+		list = append(list, installation)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode installed plugins: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(i.Dir, manifestFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write installed plugins: %w", err)
+	}
+	return nil
+}
+
+// resolve returns the download of source and the version the index gives
+// it, if source is a name of the index
+func (i *Installer) resolve(ctx context.Context, source string, opts InstallOptions) (Download, string, error) {
+	if isURL(source) {
+		download := Download{URL: source, SHA256: opts.SHA256}
+		if download.SHA256 == "" {
+			if !opts.Insecure {
+				return Download{}, "", fmt.Errorf("no checksum to verify %s: pass --sha256, or --insecure to install it unverified", source)
+			}
+			// A checksum published next to the file catches a corrupted
+			// download, but whoever can replace the file can replace it too
+			download.Unverified = true
+			if strings.HasPrefix(source, "https://") {
+				if sum, err := i.fetchChecksum(ctx, source+".sha256"); err == nil {
+					download.SHA256 = sum
+				}
+			}
+		}
+		return download, "", nil
+	}
+
+	index, err := i.fetchIndex(ctx)
+	if err != nil {
+		return Download{}, "", err
+	}
+	for _, entry := range index.Plugins {
+		if entry.Name != source {
+			continue
+		}
+		platform := runtime.GOOS + "/" + runtime.GOARCH
+		download, exists := entry.Downloads[platform]
+		if !exists {
+			return Download{}, "", fmt.Errorf("plugin %s has no download for %s", source, platform)
+		}
+		if download.SHA256 == "" {
+			if !opts.Insecure {
+				return Download{}, "", fmt.Errorf("the index has no checksum for plugin %s: pass --insecure to install it unverified", source)
+			}
+			download.Unverified = true
+		}
+		return download, entry.Version, nil
+	}
+	return Download{}, "", fmt.Errorf("plugin %s not found in the index %s", source, i.IndexURL)
+}
+
+// install downloads, verifies and installs the plugin of download, once
+// confirm, if any, accepts it
+func (i *Installer) install(ctx context.Context, source, version string, download Download, confirm func(string, Download) bool) (*Installation, error) {
+	if err := os.MkdirAll(i.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create plugin directory: %w", err)
+	}
+
+	archive, sum, err := i.download(ctx, download)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archive)
+
+	// The plugin file is staged under a hidden name, so that a failed
+	// install leaves no plugin behind
+	file, staged, err := i.extract(archive, download.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(staged)
+
+	// Loading the plugin runs it, so it waits for the user
+	if confirm != nil && !confirm(source, download) {
+		return nil, ErrInstallCancelled
+	}
+	plugin, err := i.loader.LoadFile(staged)
+	if err != nil {
+		return nil, fmt.Errorf("downloaded plugin does not load: %w", err)
+	}
+	if version == "" {
+		version = plugin.Version()
+	}
+
+	installed, err := i.Installed()
+	if err != nil {
+		return nil, err
+	}
+	for name, other := range installed {
+		if other.File == file && name != plugin.Name() {
+			return nil, fmt.Errorf("%s is the file of plugin %s, remove it first", file, name)
+		}
+	}
+	previous, replaced := installed[plugin.Name()]
+
+	if err := os.Rename(staged, filepath.Join(i.Dir, file)); err != nil {
+		return nil, fmt.Errorf("failed to install plugin %s: %w", plugin.Name(), err)
+	}
+	if replaced && previous.File != file {
+		os.Remove(filepath.Join(i.Dir, previous.File))
+	}
+
+	installation := Installation{
+		Name:        plugin.Name(),
+		Version:     version,
+		File:        file,
+		Source:      source,
+		SHA256:      sum,
+		Unverified:  download.Unverified,
+		InstalledAt: time.Now(),
+	}
+	installed[installation.Name] = installation
+	if err := i.saveInstalled(installed); err != nil {
+		return nil, err
+	}
+	return &installation, nil
+}
+
+// download fetches download into a hidden file of the plugins directory and
+// verifies its checksum, returning the file and the checksum
+func (i *Installer) download(ctx context.Context, download Download) (string, string, error) {
+	body, err := i.get(ctx, download.URL)
+	if err != nil {
+		return "", "", err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(i.Dir, ".download-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create download file: %w", err)
+	}
+	defer tmp.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(body, maxDownloadSize+1))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("failed to download %s: %w", download.URL, err)
+	}
+	if n > maxDownloadSize {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("download %s exceeds %d MB", download.URL, maxDownloadSize>>20)
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if download.SHA256 != "" && !strings.EqualFold(sum, download.SHA256) {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", download.URL, download.SHA256, sum)
+	}
+	return tmp.Name(), sum, nil
+}
+
+// extract stages the plugin of the downloaded file: the file itself, or the
+// only .so file or executable of a .tar.gz, .tgz or .zip archive. It returns
+// the name the plugin is installed under and the staged file.
+func (i *Installer) extract(downloaded, rawURL string) (string, string, error) {
+	name := urlFileName(rawURL)
+
+	switch {
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return i.extractTar(downloaded)
+	case strings.HasSuffix(name, ".zip"):
+		return i.extractZip(downloaded)
+	}
+
+	if err := validateFileName(name); err != nil {
+		return "", "", err
+	}
+	staged := filepath.Join(i.Dir, ".install-"+name)
+	if err := os.Rename(downloaded, staged); err != nil {
+		return "", "", fmt.Errorf("failed to stage plugin: %w", err)
+	}
+	if err := os.Chmod(staged, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to stage plugin: %w", err)
+	}
+	return name, staged, nil
+}
+
+// extractTar stages the plugin of a gzipped tar archive
+func (i *Installer) extractTar(archive string) (string, string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+
+	var name, staged string
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || !isArchivedPlugin(header.Name, header.FileInfo().Mode()) {
+			continue
+		}
+		if staged != "" {
+			os.Remove(staged)
+			return "", "", fmt.Errorf("archive holds several plugins: %s and %s", name, path.Base(header.Name))
+		}
+		name = path.Base(header.Name)
+		if staged, err = i.stage(name, tr); err != nil {
+			return "", "", err
+		}
+	}
+
+	if staged == "" {
+		return "", "", fmt.Errorf("archive holds no .so file or executable")
+	}
+	return name, staged, nil
+}
+
+// extractZip stages the plugin of a zip archive
+func (i *Installer) extractZip(archive string) (string, string, error) {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer zr.Close()
+
+	var plugin *zip.File
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() || !isArchivedPlugin(f.Name, f.Mode()) {
+			continue
+		}
+		if plugin != nil {
+			return "", "", fmt.Errorf("archive holds several plugins: %s and %s", path.Base(plugin.Name), path.Base(f.Name))
+		}
+		plugin = f
+	}
+	if plugin == nil {
+		return "", "", fmt.Errorf("archive holds no .so file or executable")
+	}
+
+	r, err := plugin.Open()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer r.Close()
+
+	name := path.Base(plugin.Name)
+	staged, err := i.stage(name, r)
+	if err != nil {
+		return "", "", err
+	}
+	return name, staged, nil
+}
+
+// stage writes the plugin file name, read from r, under a hidden name of the
+// plugins directory
+func (i *Installer) stage(name string, r io.Reader) (string, error) {
+	if err := validateFileName(name); err != nil {
+		return "", err
+	}
+
+	staged := filepath.Join(i.Dir, ".install-"+name)
+	f, err := os.OpenFile(staged, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to stage plugin: %w", err)
+	}
+	n, err := io.Copy(f, io.LimitReader(r, maxDownloadSize+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > maxDownloadSize {
+		err = fmt.Errorf("plugin exceeds %d MB", maxDownloadSize>>20)
+	}
+	if err != nil {
+		os.Remove(staged)
+		return "", fmt.Errorf("failed to stage plugin: %w", err)
+	}
+	return staged, nil
+}
+
+// isArchivedPlugin reports whether an archive entry is a plugin: a .so file,
+// a file with an execute permission or, on Windows, an .exe file
+func isArchivedPlugin(name string, mode os.FileMode) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".so":
+		return true
+	case ".exe":
+		return runtime.GOOS == "windows"
+	}
+	return runtime.GOOS != "windows" && mode.Perm()&0111 != 0
+}
+
+// validateFileName checks that a plugin file name is usable in the plugins
+// directory, and not hidden from the loader
+func validateFileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid plugin file name '%s'", name)
+	}
+	return nil
+}
+
+// fetchIndex downloads the index of the plugins installed by name
+func (i *Installer) fetchIndex(ctx context.Context) (*Index, error) {
+	body, err := i.get(ctx, i.IndexURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var index Index
+	if err := json.NewDecoder(io.LimitReader(body, maxDownloadSize)).Decode(&index); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin index %s: %w", i.IndexURL, err)
+	}
+	return &index, nil
+}
+
+// fetchChecksum downloads a checksum file, whose first field is the hex
+// SHA-256 checksum as written by sha256sum
+func (i *Installer) fetchChecksum(ctx context.Context, rawURL string) (string, error) {
+	body, err := i.get(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, 4096))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid checksum file %s", rawURL)
+	}
+	if _, err := hex.DecodeString(fields[0]); err != nil {
+		return "", fmt.Errorf("invalid checksum file %s", rawURL)
+	}
+	return fields[0], nil
+}
+
+// get requests rawURL and returns the body of a successful response
+func (i *Installer) get(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", rawURL, err)
+	}
+	resp, err := i.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: %s", rawURL, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// isURL reports whether source is a URL rather than a name of the index
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
+
+// urlFileName returns the last element of the path of rawURL
+func urlFileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return path.Base(u.Path)
+}
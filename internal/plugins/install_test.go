@@ -0,0 +1,294 @@
+package plugins
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// pluginServer serves files by path over HTTPS
+func pluginServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, exists := files[r.URL.Path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestInstaller returns an installer into a temporary directory using
+// the client of server
+func newTestInstaller(t *testing.T, server *httptest.Server) *Installer {
+	t.Helper()
+	installer := NewInstaller(filepath.Join(t.TempDir(), "plugins"))
+	installer.Client = server.Client()
+	installer.IndexURL = server.URL + "/index.json"
+	return installer
+}
+
+// testPluginScript returns the content of a process plugin named test
+func testPluginScript(t *testing.T) []byte {
+	t.Helper()
+	path := writeProcessPlugin(t, t.TempDir(), "test", map[string]string{"initialize": handshake})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read plugin: %v", err)
+	}
+	return data
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestInstall_URL(t *testing.T) {
+	script := testPluginScript(t)
+	server := pluginServer(t, map[string][]byte{
+		"/my-plugin":        script,
+		"/my-plugin.sha256": []byte(sha256Hex(script) + "  my-plugin\n"),
+		"/unverified":       script,
+	})
+	installer := newTestInstaller(t, server)
+	ctx := context.Background()
+
+	installation, err := installer.Install(ctx, server.URL+"/my-plugin", InstallOptions{SHA256: sha256Hex(script)})
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if installation.Name != "test" || installation.Version != "1.0.0" || installation.File != "my-plugin" || installation.Unverified {
+		t.Errorf("Unexpected installation: %+v", installation)
+	}
+
+	// The installed plugin loads from the plugins directory
+	loaded, err := NewLoader().LoadAll(installer.Dir)
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0].Name() != "test" {
+		t.Errorf("Expected the installed plugin to load, got %v", loaded)
+	}
+
+	// Downloads without a checksum are refused unless insecure, even with
+	// a checksum published next to them, which the same server could forge
+	for _, path := range []string{"/my-plugin", "/unverified"} {
+		if _, err := installer.Install(ctx, server.URL+path, InstallOptions{}); err == nil || !strings.Contains(err.Error(), "no checksum") {
+			t.Errorf("Expected an error without a checksum for %s, got: %v", path, err)
+		}
+	}
+	installation, err = installer.Install(ctx, server.URL+"/my-plugin", InstallOptions{Insecure: true})
+	if err != nil {
+		t.Fatalf("Insecure install failed: %v", err)
+	}
+	if !installation.Unverified || installation.SHA256 != sha256Hex(script) {
+		t.Errorf("Expected an unverified installation, got %+v", installation)
+	}
+	if _, err := installer.Install(ctx, server.URL+"/unverified", InstallOptions{SHA256: strings.Repeat("0", 64)}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got: %v", err)
+	}
+	if _, err := installer.Install(ctx, server.URL+"/unverified", InstallOptions{Insecure: true}); err != nil {
+		t.Fatalf("Insecure install failed: %v", err)
+	}
+
+	// The plugin of the same name is replaced, and no staged file remains
+	entries, err := os.ReadDir(installer.Dir)
+	if err != nil {
+		t.Fatalf("Failed to read plugins directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != manifestFile+",unverified" {
+		t.Errorf("Expected only the manifest and the replacing plugin, got %v", names)
+	}
+}
+
+func TestInstall_Archives(t *testing.T) {
+	script := testPluginScript(t)
+
+	var tarball bytes.Buffer
+	gz := gzip.NewWriter(&tarball)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct {
+		name string
+		mode int64
+		data []byte
+	}{
+		{"my-plugin/README.md", 0644, []byte("# My plugin\n")},
+		{"my-plugin/bin/my-plugin", 0755, script},
+	} {
+		tw.WriteHeader(&tar.Header{Name: file.name, Mode: file.mode, Size: int64(len(file.data)), Typeflag: tar.TypeReg})
+		tw.Write(file.data)
+	}
+	tw.Close()
+	gz.Close()
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	header := &zip.FileHeader{Name: "bin/zipped-plugin", Method: zip.Deflate}
+	header.SetMode(0755)
+	w, _ := zw.CreateHeader(header)
+	w.Write(script)
+	zw.Close()
+
+	server := pluginServer(t, map[string][]byte{
+		"/my-plugin.tar.gz": tarball.Bytes(),
+		"/my-plugin.zip":    zipped.Bytes(),
+	})
+	installer := newTestInstaller(t, server)
+
+	tests := []struct {
+		url  string
+		data []byte
+		file string
+	}{
+		{url: "/my-plugin.tar.gz", data: tarball.Bytes(), file: "my-plugin"},
+		{url: "/my-plugin.zip", data: zipped.Bytes(), file: "zipped-plugin"},
+	}
+	for _, tt := range tests {
+		installation, err := installer.Install(context.Background(), server.URL+tt.url, InstallOptions{SHA256: sha256Hex(tt.data)})
+		if err != nil {
+			t.Fatalf("Install of %s failed: %v", tt.url, err)
+		}
+		if installation.File != tt.file {
+			t.Errorf("Expected %s to install %s, got %s", tt.url, tt.file, installation.File)
+		}
+		if _, err := os.Stat(filepath.Join(installer.Dir, tt.file)); err != nil {
+			t.Errorf("Expected %s to be installed: %v", tt.file, err)
+		}
+	}
+}
+
+func TestInstall_Index(t *testing.T) {
+	script := testPluginScript(t)
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+
+	files := map[string][]byte{"/test": script}
+	server := pluginServer(t, files)
+	setIndex := func(version string) {
+		index := Index{Plugins: []IndexEntry{{
+			Name:      "test",
+			Version:   version,
+			Downloads: map[string]Download{platform: {URL: server.URL + "/test", SHA256: sha256Hex(script)}},
+		}}}
+		files["/index.json"], _ = json.Marshal(index)
+	}
+	setIndex("1.0.0")
+	installer := newTestInstaller(t, server)
+	ctx := context.Background()
+
+	installation, err := installer.Install(ctx, "test", InstallOptions{})
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if installation.Source != "test" || installation.Version != "1.0.0" {
+		t.Errorf("Unexpected installation: %+v", installation)
+	}
+
+	if _, err := installer.Install(ctx, "missing", InstallOptions{}); err == nil || !strings.Contains(err.Error(), "not found in the index") {
+		t.Errorf("Expected an error for a plugin missing from the index, got: %v", err)
+	}
+
+	// Updates follow the version of the index
+	if _, updated, err := installer.Update(ctx, "test", InstallOptions{}); err != nil || updated {
+		t.Errorf("Expected the plugin to be up to date, got updated=%v, err=%v", updated, err)
+	}
+	setIndex("1.1.0")
+	installation, updated, err := installer.Update(ctx, "test", InstallOptions{})
+	if err != nil || !updated {
+		t.Fatalf("Expected the plugin to be updated, got updated=%v, err=%v", updated, err)
+	}
+	if installation.Version != "1.1.0" {
+		t.Errorf("Expected version 1.1.0, got %s", installation.Version)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	script := testPluginScript(t)
+	server := pluginServer(t, map[string][]byte{"/my-plugin": script})
+	installer := newTestInstaller(t, server)
+
+	if _, err := installer.Install(context.Background(), server.URL+"/my-plugin", InstallOptions{SHA256: sha256Hex(script)}); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	installation, err := installer.Remove("test")
+	if err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if installation.File != "my-plugin" {
+		t.Errorf("Expected my-plugin to be removed, got %s", installation.File)
+	}
+	if _, err := os.Stat(filepath.Join(installer.Dir, "my-plugin")); !os.IsNotExist(err) {
+		t.Error("Expected the plugin file to be deleted")
+	}
+	installed, err := installer.Installed()
+	if err != nil || len(installed) != 0 {
+		t.Errorf("Expected no installed plugins, got %v, %v", installed, err)
+	}
+
+	// Plugins copied by hand are removed by file name
+	if err := os.WriteFile(filepath.Join(installer.Dir, "manual.so"), []byte("so"), 0644); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	if _, err := installer.Remove("manual"); err != nil {
+		t.Errorf("Remove of a plugin copied by hand failed: %v", err)
+	}
+	if _, err := installer.Remove("missing"); err == nil {
+		t.Error("Expected an error removing a plugin that is not installed")
+	}
+}
+
+func TestInstall_Confirm(t *testing.T) {
+	// The plugin records being started
+	started := filepath.Join(t.TempDir(), "started")
+	script := []byte("#!/bin/sh\necho started >> " + started + "\n")
+	server := pluginServer(t, map[string][]byte{"/my-plugin": script})
+	installer := newTestInstaller(t, server)
+
+	var confirmed Download
+	_, err := installer.Install(context.Background(), server.URL+"/my-plugin", InstallOptions{
+		SHA256: sha256Hex(script),
+		Confirm: func(source string, download Download) bool {
+			confirmed = download
+			return false
+		},
+	})
+	if !errors.Is(err, ErrInstallCancelled) {
+		t.Fatalf("Expected the install to be cancelled, got: %v", err)
+	}
+	if confirmed.SHA256 != sha256Hex(script) || confirmed.Unverified {
+		t.Errorf("Expected the verified download to be confirmed, got %+v", confirmed)
+	}
+
+	// A declined plugin is neither started nor installed
+	if _, err := os.Stat(started); !os.IsNotExist(err) {
+		t.Errorf("Expected the plugin not to be started, got: %v", err)
+	}
+	entries, err := os.ReadDir(installer.Dir)
+	if err != nil {
+		t.Fatalf("Failed to read plugins directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected nothing to be installed, got %v", entries)
+	}
+}
@@ -0,0 +1,67 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// DefaultTimeout is how long a plugin may take to scan or clean, or to run a
+// hook, when no timeout is configured
+const DefaultTimeout = 2 * time.Minute
+
+// Scan calls the Scan method of plugin, isolated from the caller: it fails
+// if the plugin panics or takes longer than timeout (DefaultTimeout when 0),
+// rather than crash or stall the scan.
+func Scan(ctx context.Context, plugin Plugin, timeout time.Duration) ([]types.Target, error) {
+	return isolate(ctx, "scan", timeout, plugin.Scan)
+}
+
+// Clean calls the Clean method of plugin, isolated from the caller like
+// Scan
+func Clean(ctx context.Context, plugin Plugin, targets []types.Target, timeout time.Duration) error {
+	_, err := isolate(ctx, "clean", timeout, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, plugin.Clean(ctx, targets)
+	})
+	return err
+}
+
+// isolate runs call, the method of a plugin, in a goroutine of its own and
+// recovers its panics. The context of call is cancelled after timeout; a
+// plugin ignoring it is abandoned, its goroutine left running, so the caller
+// goes on. Errors do not name the plugin, which callers log.
+func isolate[T any](ctx context.Context, method string, timeout time.Duration, call func(context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("%s panicked: %v", method, r)}
+			}
+		}()
+		value, err := call(callCtx)
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-callCtx.Done():
+		var zero T
+		if err := ctx.Err(); err != nil {
+			return zero, err
+		}
+		return zero, fmt.Errorf("%s timed out after %v", method, timeout)
+	}
+}
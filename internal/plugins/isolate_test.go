@@ -0,0 +1,69 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// misbehavingPlugin panics or hangs, ignoring cancellation, when scanning
+// and cleaning
+type misbehavingPlugin struct {
+	mockPlugin
+	panics bool
+	hang   chan struct{}
+}
+
+func (p *misbehavingPlugin) Scan(ctx context.Context) ([]types.Target, error) {
+	if p.panics {
+		panic("index out of range")
+	}
+	<-p.hang
+	return nil, nil
+}
+
+func (p *misbehavingPlugin) Clean(ctx context.Context, targets []types.Target) error {
+	_, err := p.Scan(ctx)
+	return err
+}
+
+func TestScan_Isolated(t *testing.T) {
+	ctx := context.Background()
+
+	// Panics are errors
+	panicking := &misbehavingPlugin{mockPlugin: mockPlugin{name: "panicking", version: "1.0.0"}, panics: true}
+	if _, err := Scan(ctx, panicking, time.Second); err == nil || !strings.Contains(err.Error(), "panicked: index out of range") {
+		t.Errorf("Expected the panic as an error, got: %v", err)
+	}
+	if err := Clean(ctx, panicking, nil, time.Second); err == nil || !strings.Contains(err.Error(), "clean panicked") {
+		t.Errorf("Expected the panic as an error, got: %v", err)
+	}
+
+	// Plugins ignoring cancellation are abandoned after the timeout
+	hung := &misbehavingPlugin{mockPlugin: mockPlugin{name: "hung", version: "1.0.0"}, hang: make(chan struct{})}
+	defer close(hung.hang)
+	start := time.Now()
+	if _, err := Scan(ctx, hung, 50*time.Millisecond); err == nil || !strings.Contains(err.Error(), "scan timed out after 50ms") {
+		t.Errorf("Expected a timeout, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Scan took %v with a timeout of 50ms", elapsed)
+	}
+
+	// Cancellation of the caller is reported as such
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Scan(cancelled, hung, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+
+	// Well-behaved plugins return their results
+	targets, err := Scan(ctx, &mockPlugin{name: "plain", version: "1.0.0"}, time.Second)
+	if err != nil || targets == nil {
+		t.Errorf("Expected the results of the plugin, got %v, %v", targets, err)
+	}
+}
@@ -1,16 +1,19 @@
 package plugins
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"plugin"
+	"slices"
+	"strings"
 
 	"github.com/raucheacho/rosia-cli/pkg/logger"
 	"github.com/raucheacho/rosia-cli/pkg/types"
 )
 
-// Loader handles loading Go plugins from .so files
+// Loader handles loading Go plugins from .so files and process plugins from
+// executables
 type Loader struct{}
 
 // NewLoader creates a new plugin loader
@@ -18,34 +21,83 @@ func NewLoader() *Loader {
 	return &Loader{}
 }
 
-// LoadAll loads all plugins from the specified directory
+// LoadAll loads all plugins from the specified directory: Go plugins from .so
+// files and process plugins from executables. Other files are ignored.
+// Plugins failing to load are skipped; their errors are joined in the
+// returned error, along with the plugins that loaded. The plugins are nil
+// only when the directory cannot be read.
 func (l *Loader) LoadAll(dir string) ([]Plugin, error) {
-	// Check if directory exists
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		logger.Debug("Plugin directory does not exist: %s", dir)
-		return []Plugin{}, nil
-	}
+	return l.loadDir(dir, nil)
+}
 
-	// Find all .so files in the directory
-	soFiles, err := filepath.Glob(filepath.Join(dir, "*.so"))
+// LoadEnabled loads the plugins of dir named in names, like LoadAll, without
+// opening or starting the other files. A file holds the plugin the install
+// manifest names it for, or else the plugin of its file name, without its
+// extension.
+func (l *Loader) LoadEnabled(dir string, names []string) ([]Plugin, error) {
+	installed, err := NewInstaller(dir).Installed()
 	if err != nil {
-		return nil, fmt.Errorf("failed to glob plugin directory: %w", err)
+		return nil, err
+	}
+	files := make(map[string]string, len(installed))
+	for name, installation := range installed {
+		files[installation.File] = name
 	}
 
-	if len(soFiles) == 0 {
-		logger.Debug("No plugin files found in %s", dir)
-		return []Plugin{}, nil
+	return l.loadDir(dir, func(file string) bool {
+		name, ok := files[file]
+		if !ok {
+			name = pluginFileName(file)
+		}
+		return slices.Contains(names, name)
+	})
+}
+
+// pluginFileName returns the name of the plugin of a plugin file not
+// installed by the installer: the name of the file without its extension
+func pluginFileName(file string) string {
+	name := file
+	switch ext := filepath.Ext(name); strings.ToLower(ext) {
+	case ".so", ".exe", ".bat", ".cmd", ".com":
+		name = strings.TrimSuffix(name, ext)
 	}
+	return name
+}
 
-	plugins := make([]Plugin, 0, len(soFiles))
+// loadDir loads the plugin files of dir accepted by enabled, or all of them
+// when enabled is nil
+func (l *Loader) loadDir(dir string, enabled func(file string) bool) ([]Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Debug("Plugin directory does not exist: %s", dir)
+			return []Plugin{}, nil
+		}
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	plugins := make([]Plugin, 0, len(entries))
+	var errs []error
 
 	// Load each plugin file
-	for _, soFile := range soFiles {
-		logger.Debug("Loading plugin from: %s", soFile)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
 
-		plugin, err := l.Load(soFile)
+		if !isPluginFile(path) {
+			continue
+		}
+		if enabled != nil && !enabled(entry.Name()) {
+			logger.Debug("Skipping plugin %s, which is not enabled", path)
+			continue
+		}
+
+		logger.Debug("Loading plugin from: %s", path)
+		plugin, err := l.LoadFile(path)
 		if err != nil {
-			logger.Warn("Failed to load plugin %s: %v", soFile, err)
+			errs = append(errs, err)
 			// Continue loading other plugins
 			continue
 		}
@@ -54,16 +106,51 @@ func (l *Loader) LoadAll(dir string) ([]Plugin, error) {
 		logger.Info("Successfully loaded plugin: %s (version %s)", plugin.Name(), plugin.Version())
 	}
 
-	return plugins, nil
+	if len(plugins) == 0 {
+		logger.Debug("No plugin files found in %s", dir)
+	}
+
+	return plugins, errors.Join(errs...)
 }
 
-// Load loads a single plugin from the specified .so file
+// LoadFile loads the plugin of a .so file or an executable
+func (l *Loader) LoadFile(path string) (Plugin, error) {
+	if filepath.Ext(path) == ".so" {
+		return l.Load(path)
+	}
+	return l.LoadProcess(path)
+}
+
+// isPluginFile reports whether path is a .so file or an executable
+func isPluginFile(path string) bool {
+	if filepath.Ext(path) == ".so" {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && isExecutable(info)
+}
+
+// LoadProcess loads a process plugin from the specified executable, starting
+// it once for the handshake
+func (l *Loader) LoadProcess(path string) (Plugin, error) {
+	plugin, err := LoadProcess(path)
+	if err != nil {
+		return nil, types.ErrPluginLoadFailed{
+			PluginName: filepath.Base(path),
+			Reason:     err,
+		}
+	}
+	return plugin, nil
+}
+
+// Load loads a single plugin from the specified .so file. The file must
+// export a Plugin symbol implementing the Plugin interface and may export an
+// APIVersion int variable, checked against the APIVersion of rosia.
 func (l *Loader) Load(path string) (Plugin, error) {
 	pluginName := filepath.Base(path)
 
-	// Open the plugin file
-	p, err := plugin.Open(path)
-	if err != nil {
+	// plugin.Open does not tell missing files from unreadable ones
+	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
 			return nil, types.ErrPluginLoadFailed{
 				PluginName: pluginName,
@@ -76,27 +163,15 @@ func (l *Loader) Load(path string) (Plugin, error) {
 				Reason:     types.ErrPermissionDenied{Path: path},
 			}
 		}
-		return nil, types.ErrPluginLoadFailed{
-			PluginName: pluginName,
-			Reason:     fmt.Errorf("failed to open plugin file: %w", err),
-		}
+		return nil, types.ErrPluginLoadFailed{PluginName: pluginName, Reason: err}
 	}
 
-	// Look up the Plugin symbol
-	symPlugin, err := p.Lookup("Plugin")
+	// Open the plugin file
+	pluginInstance, err := openNative(path)
 	if err != nil {
 		return nil, types.ErrPluginLoadFailed{
 			PluginName: pluginName,
-			Reason:     fmt.Errorf("plugin does not export 'Plugin' symbol: %w", err),
-		}
-	}
-
-	// Type assert to Plugin interface
-	pluginInstance, ok := symPlugin.(Plugin)
-	if !ok {
-		return nil, types.ErrPluginLoadFailed{
-			PluginName: pluginName,
-			Reason:     fmt.Errorf("exported 'Plugin' symbol does not implement Plugin interface"),
+			Reason:     err,
 		}
 	}
 
@@ -111,6 +186,40 @@ func (l *Loader) Load(path string) (Plugin, error) {
 	return pluginInstance, nil
 }
 
+// lookupPlugin finds the Plugin symbol of an opened Go plugin with lookup,
+// after checking its APIVersion symbol, if any
+func lookupPlugin(lookup func(name string) (any, error)) (Plugin, error) {
+	// Plugins built before APIVersion existed speak version 1
+	if symVersion, err := lookup("APIVersion"); err == nil {
+		version, ok := symVersion.(*int)
+		if !ok {
+			return nil, fmt.Errorf("exported 'APIVersion' symbol must be an int variable, not %T", symVersion)
+		}
+		if *version != APIVersion {
+			return nil, fmt.Errorf("plugin API version %d is not supported (rosia supports version %d)", *version, APIVersion)
+		}
+	}
+
+	// Look up the Plugin symbol
+	symPlugin, err := lookup("Plugin")
+	if err != nil {
+		return nil, fmt.Errorf("plugin does not export 'Plugin' symbol: %w", err)
+	}
+
+	// The symbol is a pointer to the exported variable, which is either the
+	// plugin itself or a Plugin interface holding it
+	switch instance := symPlugin.(type) {
+	case *Plugin:
+		if *instance != nil {
+			return *instance, nil
+		}
+		return nil, fmt.Errorf("exported 'Plugin' symbol is nil")
+	case Plugin:
+		return instance, nil
+	}
+	return nil, fmt.Errorf("exported 'Plugin' symbol does not implement Plugin interface")
+}
+
 // validate checks if a plugin is valid
 func (l *Loader) validate(p Plugin) error {
 	if p.Name() == "" {
@@ -0,0 +1,173 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// symbols returns a lookup function of a Go plugin exporting symbols
+func symbols(symbols map[string]any) func(string) (any, error) {
+	return func(name string) (any, error) {
+		if symbol, ok := symbols[name]; ok {
+			return symbol, nil
+		}
+		return nil, fmt.Errorf("symbol %s not found", name)
+	}
+}
+
+func TestLookupPlugin(t *testing.T) {
+	plugin := &mockPlugin{name: "test", version: "1.0.0"}
+	var iface Plugin = plugin
+	var nilIface Plugin
+	version := APIVersion
+	otherVersion := APIVersion + 1
+
+	tests := []struct {
+		name    string
+		symbols map[string]any
+		wantErr string
+	}{
+		{name: "plugin variable", symbols: map[string]any{"Plugin": plugin}},
+		{name: "interface variable", symbols: map[string]any{"Plugin": &iface}},
+		{name: "api version", symbols: map[string]any{"Plugin": plugin, "APIVersion": &version}},
+		{name: "missing plugin", symbols: map[string]any{}, wantErr: "does not export 'Plugin' symbol"},
+		{name: "nil interface", symbols: map[string]any{"Plugin": &nilIface}, wantErr: "symbol is nil"},
+		{name: "not a plugin", symbols: map[string]any{"Plugin": &version}, wantErr: "does not implement Plugin interface"},
+		{name: "other api version", symbols: map[string]any{"Plugin": plugin, "APIVersion": &otherVersion}, wantErr: "not supported"},
+		{name: "invalid api version", symbols: map[string]any{"Plugin": plugin, "APIVersion": "1"}, wantErr: "must be an int variable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupPlugin(symbols(tt.symbols))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing '%s', got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lookupPlugin failed: %v", err)
+			}
+			if got.Name() != "test" {
+				t.Errorf("Expected plugin 'test', got '%s'", got.Name())
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	loader := NewLoader()
+	dir := t.TempDir()
+
+	_, err := loader.Load(filepath.Join(dir, "missing.so"))
+	var loadErr types.ErrPluginLoadFailed
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("Expected ErrPluginLoadFailed, got: %v", err)
+	}
+	var notFound types.ErrPathNotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("Expected ErrPathNotFound reason, got: %v", err)
+	}
+
+	// A file that is not a shared object fails to load without crashing
+	invalid := filepath.Join(dir, "invalid.so")
+	if err := os.WriteFile(invalid, []byte("not a plugin"), 0644); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	_, err = loader.Load(invalid)
+	if !errors.As(err, &loadErr) || loadErr.PluginName != "invalid.so" {
+		t.Errorf("Expected ErrPluginLoadFailed for invalid.so, got: %v", err)
+	}
+}
+
+func TestLoad_Native(t *testing.T) {
+	if !nativeSupported {
+		t.Skip("Go plugins are not supported on this platform")
+	}
+	if testing.Short() {
+		t.Skip("building a Go plugin is slow")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "native.so")
+	build := exec.Command(goTool, "build", "-buildmode=plugin", "-o", path, "./testdata/native")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build plugin: %v\n%s", err, output)
+	}
+
+	plugin, err := NewLoader().Load(path)
+	if err != nil {
+		// Test binaries built with -race or -cover differ from the plugin
+		if strings.Contains(err.Error(), "different version") {
+			t.Skipf("plugin built with other flags than the test: %v", err)
+		}
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if plugin.Name() != "native" || plugin.Version() != "1.0.0" {
+		t.Errorf("Unexpected plugin %s %s", plugin.Name(), plugin.Version())
+	}
+}
+
+func TestPluginFileName(t *testing.T) {
+	tests := map[string]string{
+		"docker":     "docker",
+		"docker.so":  "docker",
+		"docker.exe": "docker",
+		"xcode.EXE":  "xcode",
+		"my.plugin":  "my.plugin",
+	}
+	for file, want := range tests {
+		if got := pluginFileName(file); got != want {
+			t.Errorf("pluginFileName(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
+
+func TestLoadEnabled(t *testing.T) {
+	dir := t.TempDir()
+	writeProcessPlugin(t, dir, "test", map[string]string{"initialize": handshake})
+	// Installed plugins are matched by the name of the manifest
+	writeProcessPlugin(t, dir, "custom", map[string]string{
+		"initialize": `echo '{"jsonrpc":"2.0","id":'$id',"result":{"name":"installed","version":"1.0.0","protocol_version":1}}'`,
+	})
+	manifest := `[{"name": "installed", "version": "1.0.0", "file": "custom"}]`
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	// Plugins that are not enabled are never started
+	started := filepath.Join(t.TempDir(), "started")
+	for _, file := range []string{"other", "other.sh"} {
+		script := "#!/bin/sh\necho " + file + " >> " + started + "\n"
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(script), 0755); err != nil {
+			t.Fatalf("Failed to write plugin: %v", err)
+		}
+	}
+
+	loaded, err := NewLoader().LoadEnabled(dir, []string{"test", "installed", "missing"})
+	if err != nil {
+		t.Fatalf("LoadEnabled failed: %v", err)
+	}
+
+	var names []string
+	for _, plugin := range loaded {
+		names = append(names, plugin.Name())
+	}
+	if strings.Join(names, ",") != "installed,test" {
+		t.Errorf("Expected plugins installed and test, got %v", names)
+	}
+	if data, err := os.ReadFile(started); err == nil {
+		t.Errorf("Expected the other plugins not to be started, got: %s", data)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build (linux || darwin || freebsd) && cgo
+
+package plugins
+
+import (
+	"fmt"
+	"plugin"
+	"runtime"
+	"strings"
+)
+
+// nativeSupported reports whether Go plugins can be loaded on this platform
+const nativeSupported = true
+
+// openNative opens the Go plugin at path and returns its Plugin symbol.
+// Initialization panics of the plugin are reported as errors.
+func openNative(path string) (p Plugin, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p, err = nil, fmt.Errorf("plugin panicked while loading: %v", r)
+		}
+	}()
+
+	lib, err := plugin.Open(path)
+	if err != nil {
+		// Go plugins must be built with the toolchain and dependency versions
+		// of the binary loading them
+		if strings.Contains(err.Error(), "different version") {
+			return nil, fmt.Errorf("plugin was not built with the Go toolchain and packages of rosia (%s), rebuild it against this version of rosia: %w", runtime.Version(), err)
+		}
+		return nil, fmt.Errorf("failed to open plugin file: %w", err)
+	}
+
+	return lookupPlugin(func(name string) (any, error) {
+		return lib.Lookup(name)
+	})
+}
@@ -0,0 +1,17 @@
+//go:build !((linux || darwin || freebsd) && cgo)
+
+package plugins
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// nativeSupported reports whether Go plugins can be loaded on this platform
+const nativeSupported = false
+
+// openNative fails, Go plugins being unavailable on this platform or in
+// builds without cgo
+func openNative(path string) (Plugin, error) {
+	return nil, fmt.Errorf("loading Go plugins is not supported on %s/%s, use a process plugin instead", runtime.GOOS, runtime.GOARCH)
+}
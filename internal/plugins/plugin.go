@@ -2,7 +2,7 @@
 //
 // Plugins allow third-party extensions to add custom scanning and cleaning logic
 // beyond the built-in profiles. Plugins can be written in Go (using Go's plugin system)
-// or in any language via JSON-RPC.
+// or in any language as executables speaking JSON-RPC over stdio (see ProcessPlugin).
 //
 // Example Go plugin:
 //
@@ -19,10 +19,16 @@ package plugins
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/raucheacho/rosia-cli/pkg/types"
 )
 
+// APIVersion is the version of the Plugin interface. Go plugins may export it
+// as an int variable, var APIVersion = 1, to be refused by versions of rosia
+// with another interface rather than fail when called.
+const APIVersion = 1
+
 // Plugin defines the interface that all plugins must implement.
 //
 // Plugins extend Rosia's functionality by providing custom scanning and cleaning
@@ -43,3 +49,58 @@ type Plugin interface {
 	// Clean performs cleaning operations on the given targets
 	Clean(ctx context.Context, targets []types.Target) error
 }
+
+// ProfileProvider is implemented by plugins contributing profiles, which
+// are used like the profile files. A plugin whose profiles are all it needs
+// can return no targets from Scan.
+type ProfileProvider interface {
+	// Profiles returns the profiles of the plugin
+	Profiles() []types.Profile
+}
+
+// TargetDeleter is implemented by plugins reporting virtual targets, which
+// are not files, like Docker images. The cleaner deletes each of them with
+// the plugin that reported it, instead of moving it to the trash.
+type TargetDeleter interface {
+	DeleteTarget(ctx context.Context, target types.Target) error
+}
+
+// PartialDeleter is implemented by TargetDeleters that may free less than
+// the size of a target, like a cache its package manager prunes of the
+// entries still in use. The cleaner calls DeletePart instead of DeleteTarget
+// and records the bytes it returns as freed.
+type PartialDeleter interface {
+	DeletePart(ctx context.Context, target types.Target) (int64, error)
+}
+
+// Configurable is implemented by plugins accepting settings, the
+// plugin_settings of the configuration under their name. Configure is called
+// once the plugin is loaded, before it is used; an error rejects the
+// settings and the plugin is not used.
+type Configurable interface {
+	Configure(settings map[string]any) error
+}
+
+// Configure passes the settings of the plugins of registry to them, by plugin
+// name. Plugins rejecting their settings are unregistered. Plugins without
+// settings are left as they are, and settings of plugins that are not
+// registered are ignored, as they may be disabled.
+func Configure(registry PluginRegistry, settings map[string]map[string]any) []error {
+	var errs []error
+	for _, plugin := range registry.List() {
+		pluginSettings, exists := settings[plugin.Name()]
+		if !exists {
+			continue
+		}
+		configurable, ok := plugin.(Configurable)
+		if !ok {
+			errs = append(errs, fmt.Errorf("plugin %s does not accept settings", plugin.Name()))
+			continue
+		}
+		if err := configurable.Configure(pluginSettings); err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s rejected its settings: %w", plugin.Name(), err))
+			registry.Unregister(plugin.Name())
+		}
+	}
+	return errs
+}
@@ -0,0 +1,410 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// ProtocolVersion is the version of the protocol spoken with process plugins
+const ProtocolVersion = 1
+
+// handshakeTimeout is how long a process plugin may take to start and
+// describe itself when it is loaded
+const handshakeTimeout = 10 * time.Second
+
+// maxStderrSize is how much of the standard error of a process plugin is kept
+// to explain its failures
+const maxStderrSize = 4096
+
+// Info describes a process plugin, as returned by its "initialize" method
+type Info struct {
+	Name            string          `json:"name"`               // Unique identifier of the plugin
+	Version         string          `json:"version"`            // Plugin version
+	Description     string          `json:"description"`        // Human-readable description
+	ProtocolVersion int             `json:"protocol_version"`   // Version of the protocol the plugin speaks
+	Hooks           []string        `json:"hooks,omitempty"`    // Hooks the plugin handles: scan_start, clean_complete
+	Profiles        []types.Profile `json:"profiles,omitempty"` // Profiles the plugin contributes
+}
+
+// ProcessPlugin is a plugin shipped as an executable, which can be written in
+// any language. Rosia talks to it with JSON-RPC 2.0 over its standard input
+// and output, one message per line.
+//
+// Every call starts the executable and opens a session with the
+// "initialize" method, then calls "scan" or "clean" and closes its standard
+// input, upon which the plugin must exit:
+//
+//	→ {"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocol_version":1}}
+//	← {"jsonrpc":"2.0","id":1,"result":{"name":"docker","version":"1.0.0","description":"...","protocol_version":1}}
+//	→ {"jsonrpc":"2.0","id":2,"method":"scan","params":{}}
+//	← {"jsonrpc":"2.0","id":2,"result":{"targets":[{"path":"...","size":1024,"type":"cache","profile_name":"docker"}]}}
+//
+// Plugins handling the "scan_start" and "clean_complete" hooks list them in
+// the hooks of their handshake; they are called in sessions of their own.
+//
+// Settings of the plugin in the configuration are sent in the parameters of
+// "initialize", {"protocol_version":1,"settings":{...}}. Plugins reject
+// invalid settings with an error response.
+//
+// Plugins may send "log" notifications, {"jsonrpc":"2.0","method":"log",
+// "params":{"message":"..."}}, before a response; their messages are logged
+// at debug level.
+type ProcessPlugin struct {
+	path     string
+	mu       sync.RWMutex // Guards info and settings, replaced by Configure
+	info     Info
+	settings map[string]any
+}
+
+// LoadProcess starts the plugin executable at path to read its description
+func LoadProcess(path string) (*ProcessPlugin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+
+	p := &ProcessPlugin{path: path}
+	s, err := p.start(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.info = s.info
+	if err := s.close(); err != nil {
+		logger.Debug("Plugin %s did not exit cleanly: %v", path, err)
+	}
+	return p, nil
+}
+
+// Name returns the name the plugin gave in the handshake
+func (p *ProcessPlugin) Name() string {
+	info, _ := p.state()
+	return info.Name
+}
+
+// Version returns the version the plugin gave in the handshake
+func (p *ProcessPlugin) Version() string {
+	info, _ := p.state()
+	return info.Version
+}
+
+// Description returns the description the plugin gave in the handshake
+func (p *ProcessPlugin) Description() string {
+	info, _ := p.state()
+	return info.Description
+}
+
+// Profiles returns the profiles the plugin gave in the handshake
+func (p *ProcessPlugin) Profiles() []types.Profile {
+	info, _ := p.state()
+	return info.Profiles
+}
+
+// Path returns the path of the plugin executable
+func (p *ProcessPlugin) Path() string {
+	return p.path
+}
+
+// Configure sends settings to the plugin in a new handshake, and in the
+// handshake of every later session. The plugin may describe itself
+// differently with them, for instance handle other hooks.
+func (p *ProcessPlugin) Configure(settings map[string]any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+
+	s, err := p.start(ctx, settings)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.info, p.settings = s.info, settings
+	p.mu.Unlock()
+	if err := s.close(); err != nil {
+		logger.Debug("Plugin %s did not exit cleanly: %v", s.info.Name, err)
+	}
+	return nil
+}
+
+// state returns the description and settings of the plugin
+func (p *ProcessPlugin) state() (Info, map[string]any) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.info, p.settings
+}
+
+// scanResult is the result of the "scan" method
+type scanResult struct {
+	Targets []types.Target `json:"targets"`
+}
+
+// cleanParams are the parameters of the "clean" method
+type cleanParams struct {
+	Targets []types.Target `json:"targets"`
+}
+
+// Scan calls the "scan" method of the plugin and returns the targets it found
+func (p *ProcessPlugin) Scan(ctx context.Context) ([]types.Target, error) {
+	var result scanResult
+	if err := p.run(ctx, "scan", struct{}{}, &result); err != nil {
+		return nil, err
+	}
+	return result.Targets, nil
+}
+
+// Clean calls the "clean" method of the plugin with the targets it found,
+// those it reported or of its profile name. Targets of other profiles and
+// plugins are not sent to it.
+func (p *ProcessPlugin) Clean(ctx context.Context, targets []types.Target) error {
+	name := p.Name()
+	var own []types.Target
+	for _, target := range targets {
+		if target.Source == name || (target.Source == "" && target.ProfileName == name) {
+			own = append(own, target)
+		}
+	}
+	if len(own) == 0 {
+		return nil
+	}
+	return p.run(ctx, "clean", cleanParams{Targets: own}, nil)
+}
+
+// OnScanStart calls the "scan_start" hook of the plugin, if it handles it,
+// with the paths scanned
+func (p *ProcessPlugin) OnScanStart(ctx context.Context, paths []string) error {
+	if info, _ := p.state(); !slices.Contains(info.Hooks, "scan_start") {
+		return nil
+	}
+	return p.run(ctx, "scan_start", map[string][]string{"paths": paths}, nil)
+}
+
+// OnCleanComplete calls the "clean_complete" hook of the plugin, if it
+// handles it, with the report of the clean
+func (p *ProcessPlugin) OnCleanComplete(ctx context.Context, report *types.CleanReport) error {
+	if info, _ := p.state(); !slices.Contains(info.Hooks, "clean_complete") {
+		return nil
+	}
+	return p.run(ctx, "clean_complete", map[string]*types.CleanReport{"report": report}, nil)
+}
+
+// run calls method in a new session with the plugin
+func (p *ProcessPlugin) run(ctx context.Context, method string, params, result any) error {
+	_, settings := p.state()
+	s, err := p.start(ctx, settings)
+	if err != nil {
+		return err
+	}
+	if err := s.call(method, params, result); err != nil {
+		return s.abort(err)
+	}
+	if err := s.close(); err != nil {
+		logger.Debug("Plugin %s did not exit cleanly: %v", s.info.Name, err)
+	}
+	return nil
+}
+
+// initializeParams are the parameters of the "initialize" method
+type initializeParams struct {
+	ProtocolVersion int            `json:"protocol_version"`
+	Settings        map[string]any `json:"settings,omitempty"`
+}
+
+// session is a running plugin process
+type session struct {
+	path   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *json.Decoder
+	stderr *limitedBuffer
+	nextID int
+	info   Info
+}
+
+// start runs the plugin executable and performs the handshake with settings
+func (p *ProcessPlugin) start(ctx context.Context, settings map[string]any) (*session, error) {
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.WaitDelay = time.Second
+	stderr := &limitedBuffer{limit: maxStderrSize}
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", p.path, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", p.path, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", p.path, err)
+	}
+
+	s := &session{
+		path:   p.path,
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: json.NewDecoder(bufio.NewReader(stdout)),
+		stderr: stderr,
+	}
+
+	params := initializeParams{ProtocolVersion: ProtocolVersion, Settings: settings}
+	if err := s.call("initialize", params, &s.info); err != nil {
+		return nil, s.abort(err)
+	}
+	if err := s.info.validate(); err != nil {
+		return nil, s.abort(fmt.Errorf("invalid handshake of plugin %s: %w", p.path, err))
+	}
+	if name := p.Name(); name != "" && s.info.Name != name {
+		return nil, s.abort(fmt.Errorf("plugin %s changed its name from %s to %s", p.path, name, s.info.Name))
+	}
+	return s, nil
+}
+
+// validate checks the description a plugin gave in the handshake
+func (i Info) validate() error {
+	if i.Name == "" {
+		return fmt.Errorf("plugin name cannot be empty")
+	}
+	if i.Version == "" {
+		return fmt.Errorf("plugin version cannot be empty")
+	}
+	if i.ProtocolVersion != ProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %d (rosia speaks version %d)", i.ProtocolVersion, ProtocolVersion)
+	}
+	return nil
+}
+
+// request is a JSON-RPC request sent to a plugin
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// message is a JSON-RPC response or notification read from a plugin
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// rpcError is the error of a JSON-RPC response
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// call sends a request for method and decodes the result of its response
+// into result, unless result is nil
+func (s *session) call(method string, params, result any) error {
+	s.nextID++
+	req := request{JSONRPC: "2.0", ID: s.nextID, Method: method, Params: params}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+	if _, err := s.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("plugin %s failed during %s: %w", s.path, method, err)
+	}
+
+	for {
+		var msg message
+		if err := s.stdout.Decode(&msg); err != nil {
+			return fmt.Errorf("plugin %s failed during %s: %w", s.path, method, err)
+		}
+
+		// Notifications carry no ID
+		if msg.ID == nil {
+			if msg.Method == "log" {
+				var params struct {
+					Message string `json:"message"`
+				}
+				if json.Unmarshal(msg.Params, &params) == nil {
+					logger.Debug("Plugin %s: %s", filepath.Base(s.path), params.Message)
+				}
+			}
+			continue
+		}
+
+		if *msg.ID != req.ID {
+			return fmt.Errorf("plugin %s answered request %d instead of %d", s.path, *msg.ID, req.ID)
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("plugin %s failed to %s: %w", s.path, method, msg.Error)
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				return fmt.Errorf("invalid %s result from plugin %s: %w", method, s.path, err)
+			}
+		}
+		return nil
+	}
+}
+
+// close ends the session by closing the standard input of the plugin and
+// waits for it to exit
+func (s *session) close() error {
+	s.stdin.Close()
+	return s.cmd.Wait()
+}
+
+// abort ends a failed session without waiting for the plugin to exit by
+// itself and returns err with the output of the plugin on its standard
+// error, if any
+func (s *session) abort(err error) error {
+	s.stdin.Close()
+	s.cmd.Process.Kill()
+	s.cmd.Wait()
+	if output := strings.TrimSpace(s.stderr.String()); output != "" {
+		return fmt.Errorf("%w: %s", err, output)
+	}
+	return err
+}
+
+// limitedBuffer keeps the first bytes written to it, up to limit
+type limitedBuffer struct {
+	strings.Builder
+	limit int
+}
+
+// Write implements io.Writer, discarding what exceeds the limit
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Builder.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// isExecutable reports whether the directory entry info is a program: a file
+// with an execute permission or, on Windows, with an executable extension
+func isExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".exe", ".bat", ".cmd", ".com":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0111 != 0
+}
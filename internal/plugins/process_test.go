@@ -0,0 +1,343 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// writeProcessPlugin writes a shell script plugin to dir, answering the
+// methods of the protocol with the given lines of shell. $id holds the ID of
+// the request and $line the request itself.
+func writeProcessPlugin(t *testing.T, dir, name string, methods map[string]string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins are not executable on Windows")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	var cases strings.Builder
+	for method, answer := range methods {
+		fmt.Fprintf(&cases, "  *'\"method\":\"%s\"'*) %s ;;\n", method, answer)
+	}
+	script := "#!/bin/sh\n" +
+		"while IFS= read -r line; do\n" +
+		"  id=$(printf '%s' \"$line\" | sed 's/.*\"id\":\\([0-9]*\\).*/\\1/')\n" +
+		"  case \"$line\" in\n" + cases.String() + "  esac\n" +
+		"done\n"
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	return path
+}
+
+// handshake answers initialize for a plugin named test
+const handshake = `echo '{"jsonrpc":"2.0","id":'$id',"result":{"name":"test","version":"1.0.0","description":"Test plugin","protocol_version":1}}'`
+
+func TestLoadProcess(t *testing.T) {
+	path := writeProcessPlugin(t, t.TempDir(), "test", map[string]string{"initialize": handshake})
+
+	plugin, err := LoadProcess(path)
+	if err != nil {
+		t.Fatalf("LoadProcess failed: %v", err)
+	}
+
+	if plugin.Name() != "test" {
+		t.Errorf("Expected name 'test', got '%s'", plugin.Name())
+	}
+	if plugin.Version() != "1.0.0" {
+		t.Errorf("Expected version '1.0.0', got '%s'", plugin.Version())
+	}
+	if plugin.Description() != "Test plugin" {
+		t.Errorf("Expected description 'Test plugin', got '%s'", plugin.Description())
+	}
+	if plugin.Path() != path {
+		t.Errorf("Expected path '%s', got '%s'", path, plugin.Path())
+	}
+}
+
+func TestLoadProcess_InvalidHandshake(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   string
+	}{
+		{
+			name:   "protocol version",
+			answer: `echo '{"jsonrpc":"2.0","id":'$id',"result":{"name":"test","version":"1.0.0","protocol_version":2}}'`,
+			want:   "unsupported protocol version 2",
+		},
+		{
+			name:   "missing name",
+			answer: `echo '{"jsonrpc":"2.0","id":'$id',"result":{"version":"1.0.0","protocol_version":1}}'`,
+			want:   "plugin name cannot be empty",
+		},
+		{
+			name:   "error",
+			answer: `echo '{"jsonrpc":"2.0","id":'$id',"error":{"code":1,"message":"not ready"}}'`,
+			want:   "not ready",
+		},
+		{
+			name:   "exit",
+			answer: `echo 'broken' >&2; exit 1`,
+			want:   "broken",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeProcessPlugin(t, t.TempDir(), "test", map[string]string{"initialize": tt.answer})
+
+			_, err := LoadProcess(path)
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestProcessPluginScan(t *testing.T) {
+	path := writeProcessPlugin(t, t.TempDir(), "test", map[string]string{
+		"initialize": handshake,
+		"scan": `echo '{"jsonrpc":"2.0","method":"log","params":{"message":"scanning"}}'; ` +
+			`echo '{"jsonrpc":"2.0","id":'$id',"result":{"targets":[{"path":"/tmp/cache","size":42,"type":"cache","profile_name":"test"}]}}'`,
+	})
+
+	plugin, err := LoadProcess(path)
+	if err != nil {
+		t.Fatalf("LoadProcess failed: %v", err)
+	}
+
+	targets, err := plugin.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if len(targets) != 1 {
+		t.Fatalf("Expected 1 target, got %d", len(targets))
+	}
+	if targets[0].Path != "/tmp/cache" || targets[0].Size != 42 || targets[0].ProfileName != "test" {
+		t.Errorf("Unexpected target: %+v", targets[0])
+	}
+}
+
+func TestProcessPluginScan_Error(t *testing.T) {
+	path := writeProcessPlugin(t, t.TempDir(), "test", map[string]string{
+		"initialize": handshake,
+		"scan":       `echo '{"jsonrpc":"2.0","id":'$id',"error":{"code":1,"message":"docker is not running"}}'`,
+	})
+
+	plugin, err := LoadProcess(path)
+	if err != nil {
+		t.Fatalf("LoadProcess failed: %v", err)
+	}
+
+	_, err = plugin.Scan(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "docker is not running") {
+		t.Errorf("Expected the error of the plugin, got: %v", err)
+	}
+}
+
+func TestProcessPluginScan_Cancelled(t *testing.T) {
+	path := writeProcessPlugin(t, t.TempDir(), "test", map[string]string{
+		"initialize": handshake,
+		"scan":       `sleep 30`,
+	})
+
+	plugin, err := LoadProcess(path)
+	if err != nil {
+		t.Fatalf("LoadProcess failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := plugin.Scan(ctx); err == nil {
+		t.Error("Expected error for a cancelled scan, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Scan took %v after cancellation", elapsed)
+	}
+}
+
+func TestProcessPluginClean(t *testing.T) {
+	dir := t.TempDir()
+	record := filepath.Join(dir, "clean.json")
+	path := writeProcessPlugin(t, dir, "test", map[string]string{
+		"initialize": handshake,
+		"clean": `printf '%s\n' "$line" >> '` + record + `'; ` +
+			`echo '{"jsonrpc":"2.0","id":'$id',"result":{}}'`,
+	})
+
+	plugin, err := LoadProcess(path)
+	if err != nil {
+		t.Fatalf("LoadProcess failed: %v", err)
+	}
+
+	targets := []types.Target{
+		{Path: "/tmp/cache", ProfileName: "test"},
+		{Path: "/tmp/project/node_modules", ProfileName: "Node.js"},
+		{Path: "/tmp/images", ProfileName: "Docker", Source: "test"},
+	}
+	if err := plugin.Clean(context.Background(), targets); err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("Plugin was not called to clean: %v", err)
+	}
+	if !strings.Contains(string(data), "/tmp/cache") || !strings.Contains(string(data), "/tmp/images") {
+		t.Errorf("Expected the targets of the plugin to be sent, got: %s", data)
+	}
+	if strings.Contains(string(data), "node_modules") {
+		t.Errorf("Expected targets of other profiles not to be sent, got: %s", data)
+	}
+
+	// No call is made without targets of the plugin
+	if err := os.Remove(record); err != nil {
+		t.Fatalf("Failed to remove record: %v", err)
+	}
+	if err := plugin.Clean(context.Background(), targets[1:2]); err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+	if _, err := os.Stat(record); !os.IsNotExist(err) {
+		t.Error("Expected no clean call without targets of the plugin")
+	}
+}
+
+func TestLoaderLoadAll_Executables(t *testing.T) {
+	dir := t.TempDir()
+	writeProcessPlugin(t, dir, "test", map[string]string{"initialize": handshake})
+	writeProcessPlugin(t, dir, ".hidden", map[string]string{"initialize": handshake})
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Plugins\n"), 0644); err != nil {
+		t.Fatalf("Failed to write README: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "lib"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	loaded, err := NewLoader().LoadAll(dir)
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+
+	if len(loaded) != 1 {
+		t.Fatalf("Expected 1 plugin, got %d", len(loaded))
+	}
+	if loaded[0].Name() != "test" {
+		t.Errorf("Expected plugin 'test', got '%s'", loaded[0].Name())
+	}
+}
+
+func TestRegistryLoadAll_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeProcessPlugin(t, dir, "test", map[string]string{"initialize": handshake})
+	writeProcessPlugin(t, dir, "test-copy", map[string]string{"initialize": handshake})
+	writeProcessPlugin(t, dir, "broken", map[string]string{
+		"initialize": `echo '{"jsonrpc":"2.0","id":'$id',"error":{"code":1,"message":"not ready"}}'`,
+	})
+
+	registry := NewRegistry()
+	err := registry.LoadAll(dir)
+	if err == nil {
+		t.Fatal("Expected the errors of the broken and duplicate plugins")
+	}
+
+	// Every failing plugin has an error of its own
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 {
+		t.Fatalf("Expected 2 joined errors, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "failed to load plugin 'broken'") {
+		t.Errorf("Expected the error of the broken plugin, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "failed to register plugin test") {
+		t.Errorf("Expected the error of the duplicate plugin, got: %v", err)
+	}
+
+	// The other plugins are still loaded
+	if _, err := registry.Get("test"); err != nil {
+		t.Errorf("Expected plugin test to be loaded: %v", err)
+	}
+}
+
+func TestProcessPluginProfiles(t *testing.T) {
+	path := writeProcessPlugin(t, t.TempDir(), "test", map[string]string{
+		"initialize": `echo '{"jsonrpc":"2.0","id":'$id',"result":{"name":"test","version":"1.0.0","protocol_version":1,` +
+			`"profiles":[{"name":"Docker Compose","version":"1.0.0","patterns":[".docker-cache"],"detect":["compose.yaml"],"enabled":true}]}}'`,
+	})
+
+	plugin, err := LoadProcess(path)
+	if err != nil {
+		t.Fatalf("LoadProcess failed: %v", err)
+	}
+
+	var provider ProfileProvider = plugin
+	profiles := provider.Profiles()
+	if len(profiles) != 1 || profiles[0].Name != "Docker Compose" || profiles[0].Patterns[0] != ".docker-cache" {
+		t.Errorf("Unexpected profiles: %+v", profiles)
+	}
+}
+
+func TestProcessPluginConfigure(t *testing.T) {
+	dir := t.TempDir()
+	record := filepath.Join(dir, "initialize.json")
+	path := writeProcessPlugin(t, dir, "test", map[string]string{
+		"initialize": `printf '%s\n' "$line" >> '` + record + `'; ` +
+			`case "$line" in *'"include_volumes":"yes"'*) ` +
+			`echo '{"jsonrpc":"2.0","id":'$id',"error":{"code":1,"message":"include_volumes must be a boolean"}}' ;; ` +
+			`*) ` + handshake + ` ;; esac`,
+		"scan": `echo '{"jsonrpc":"2.0","id":'$id',"result":{"targets":[]}}'`,
+	})
+
+	plugin, err := LoadProcess(path)
+	if err != nil {
+		t.Fatalf("LoadProcess failed: %v", err)
+	}
+	if err := plugin.Configure(map[string]any{"include_volumes": true}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if _, err := plugin.Scan(context.Background()); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("Failed to read record: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 handshakes, got %d: %s", len(lines), data)
+	}
+	if strings.Contains(lines[0], "settings") {
+		t.Errorf("Expected no settings before Configure, got: %s", lines[0])
+	}
+	for _, line := range lines[1:] {
+		if !strings.Contains(line, `"settings":{"include_volumes":true}`) {
+			t.Errorf("Expected the settings in the handshake, got: %s", line)
+		}
+	}
+
+	// Rejected settings are an error
+	err = plugin.Configure(map[string]any{"include_volumes": "yes"})
+	if err == nil || !strings.Contains(err.Error(), "include_volumes must be a boolean") {
+		t.Errorf("Expected the plugin to reject its settings, got: %v", err)
+	}
+}
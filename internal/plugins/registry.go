@@ -1,7 +1,10 @@
 package plugins
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/raucheacho/rosia-cli/pkg/logger"
@@ -12,9 +15,14 @@ type PluginRegistry interface {
 	// Register adds a plugin to the registry
 	Register(plugin Plugin) error
 
-	// LoadAll loads all plugins from the specified directory
+	// LoadAll loads all plugins from the specified directory, returning
+	// the errors of those that failed to load or register
 	LoadAll(dir string) error
 
+	// LoadEnabled loads the plugins of the specified directory named in
+	// names, leaving the other files alone
+	LoadEnabled(dir string, names []string) error
+
 	// Get retrieves a plugin by name
 	Get(name string) (Plugin, error)
 
@@ -25,11 +33,14 @@ type PluginRegistry interface {
 	Unregister(name string) error
 }
 
-// Registry is the default implementation of PluginRegistry
+// Registry is the default implementation of PluginRegistry. It is safe for
+// concurrent use, so plugins may be looked up while others register.
 type Registry struct {
-	plugins map[string]Plugin
-	mu      sync.RWMutex
-	loader  *Loader
+	plugins  map[string]Plugin
+	mu       sync.RWMutex
+	loader   *Loader
+	load     func(registry PluginRegistry) // Registers the plugins on first use, if lazy
+	loadOnce sync.Once
 }
 
 // NewRegistry creates a new plugin registry
@@ -40,6 +51,36 @@ func NewRegistry() *Registry {
 	}
 }
 
+// NewLazyRegistry creates a plugin registry whose plugins are registered by
+// load the first time the registry is used, so that plugins are only
+// started when needed. load registers them in the registry it is given, a
+// new one whose plugins are then moved to this one.
+func NewLazyRegistry(load func(registry PluginRegistry)) *Registry {
+	r := NewRegistry()
+	r.load = load
+	return r
+}
+
+// ensureLoaded runs the load function of a lazy registry once, every other
+// caller waiting for it to complete
+func (r *Registry) ensureLoaded() {
+	r.loadOnce.Do(func() {
+		if r.load == nil {
+			return
+		}
+		staging := NewRegistry()
+		r.load(staging)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for name, plugin := range staging.plugins {
+			if _, exists := r.plugins[name]; !exists {
+				r.plugins[name] = plugin
+			}
+		}
+	})
+}
+
 // Register adds a plugin to the registry
 func (r *Registry) Register(plugin Plugin) error {
 	if plugin == nil {
@@ -51,6 +92,7 @@ func (r *Registry) Register(plugin Plugin) error {
 		return fmt.Errorf("plugin name cannot be empty")
 	}
 
+	r.ensureLoaded()
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -64,30 +106,61 @@ func (r *Registry) Register(plugin Plugin) error {
 	return nil
 }
 
-// LoadAll loads all plugins from the specified directory
+// LoadAll loads all plugins from the specified directory. Plugins failing
+// to load or register are skipped, and their errors joined in the returned
+// error, one per plugin.
 func (r *Registry) LoadAll(dir string) error {
 	logger.Debug("Loading plugins from directory: %s", dir)
-
 	plugins, err := r.loader.LoadAll(dir)
-	if err != nil {
+	return r.register(dir, plugins, err)
+}
+
+// LoadEnabled loads the plugins of the specified directory named in names,
+// as LoadAll does, without opening or starting the other files. See
+// Loader.LoadEnabled for how files are matched to names.
+func (r *Registry) LoadEnabled(dir string, names []string) error {
+	logger.Debug("Loading plugins %s from directory: %s", strings.Join(names, ", "), dir)
+	plugins, err := r.loader.LoadEnabled(dir, names)
+	return r.register(dir, plugins, err)
+}
+
+// register registers plugins, loaded from dir with err, and returns the
+// errors of loading and registering them joined
+func (r *Registry) register(dir string, plugins []Plugin, err error) error {
+	if plugins == nil {
 		return fmt.Errorf("failed to load plugins: %w", err)
 	}
+	errs := unwrapJoined(err)
 
 	// Register all loaded plugins
+	registered := 0
 	for _, plugin := range plugins {
 		if err := r.Register(plugin); err != nil {
-			logger.Warn("Failed to register plugin %s: %v", plugin.Name(), err)
+			errs = append(errs, fmt.Errorf("failed to register plugin %s: %w", plugin.Name(), err))
 			// Continue loading other plugins
 			continue
 		}
+		registered++
 	}
 
-	logger.Info("Loaded %d plugins from %s", len(plugins), dir)
-	return nil
+	logger.Info("Loaded %d plugins from %s", registered, dir)
+	return errors.Join(errs...)
+}
+
+// unwrapJoined returns the errors joined in err, or err alone
+func unwrapJoined(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
 }
 
 // Get retrieves a plugin by name
 func (r *Registry) Get(name string) (Plugin, error) {
+	r.ensureLoaded()
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -99,8 +172,9 @@ func (r *Registry) Get(name string) (Plugin, error) {
 	return plugin, nil
 }
 
-// List returns all registered plugins
+// List returns all registered plugins, sorted by name
 func (r *Registry) List() []Plugin {
+	r.ensureLoaded()
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -108,12 +182,14 @@ func (r *Registry) List() []Plugin {
 	for _, plugin := range r.plugins {
 		plugins = append(plugins, plugin)
 	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name() < plugins[j].Name() })
 
 	return plugins
 }
 
 // Unregister removes a plugin from the registry
 func (r *Registry) Unregister(name string) error {
+	r.ensureLoaded()
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -2,6 +2,9 @@ package plugins
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/raucheacho/rosia-cli/pkg/types"
@@ -45,6 +48,46 @@ func TestNewRegistry(t *testing.T) {
 	}
 }
 
+func TestNewLazyRegistry(t *testing.T) {
+	loads := 0
+	registry := NewLazyRegistry(func(registry PluginRegistry) {
+		loads++
+		if err := registry.Register(&mockPlugin{name: "lazy", version: "1.0.0"}); err != nil {
+			t.Errorf("Register failed: %v", err)
+		}
+	})
+	if loads != 0 {
+		t.Fatal("Expected plugins to load on first use only")
+	}
+
+	// Concurrent first uses load the plugins once
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := registry.Get("lazy"); err != nil {
+				t.Errorf("Get failed: %v", err)
+			}
+			if err := registry.Register(&mockPlugin{name: fmt.Sprintf("plugin-%d", i)}); err != nil {
+				t.Errorf("Register failed: %v", err)
+			}
+			registry.List()
+		}(i)
+	}
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("Expected plugins to load once, got %d", loads)
+	}
+	if len(registry.List()) != 9 {
+		t.Errorf("Expected 9 plugins, got %d", len(registry.List()))
+	}
+	if err := registry.Register(&mockPlugin{name: "lazy"}); err == nil {
+		t.Error("Expected lazily loaded plugins to be registered")
+	}
+}
+
 func TestRegister(t *testing.T) {
 	registry := NewRegistry()
 
@@ -189,3 +232,53 @@ func TestUnregisterNonExistent(t *testing.T) {
 		t.Error("Expected error when unregistering non-existent plugin")
 	}
 }
+
+// configurablePlugin records its settings, rejecting those with "invalid"
+type configurablePlugin struct {
+	mockPlugin
+	settings map[string]any
+}
+
+func (p *configurablePlugin) Configure(settings map[string]any) error {
+	if _, invalid := settings["invalid"]; invalid {
+		return errors.New("invalid setting")
+	}
+	p.settings = settings
+	return nil
+}
+
+func TestConfigure(t *testing.T) {
+	registry := NewRegistry()
+	configured := &configurablePlugin{mockPlugin: mockPlugin{name: "configured", version: "1.0.0"}}
+	rejecting := &configurablePlugin{mockPlugin: mockPlugin{name: "rejecting", version: "1.0.0"}}
+	unset := &configurablePlugin{mockPlugin: mockPlugin{name: "unset", version: "1.0.0"}}
+	plain := &mockPlugin{name: "plain", version: "1.0.0"}
+	for _, plugin := range []Plugin{configured, rejecting, unset, plain} {
+		if err := registry.Register(plugin); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+
+	errs := Configure(registry, map[string]map[string]any{
+		"configured": {"include_volumes": true},
+		"rejecting":  {"invalid": true},
+		"plain":      {"include_volumes": true},
+		"missing":    {"include_volumes": true},
+	})
+
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	if configured.settings["include_volumes"] != true {
+		t.Errorf("Expected the settings to be passed, got %v", configured.settings)
+	}
+	if unset.settings != nil {
+		t.Errorf("Expected a plugin without settings not to be configured, got %v", unset.settings)
+	}
+	if _, err := registry.Get("rejecting"); err == nil {
+		t.Error("Expected the plugin rejecting its settings to be unregistered")
+	}
+	if _, err := registry.Get("plain"); err != nil {
+		t.Errorf("Expected the plugin not accepting settings to stay registered: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+// Command native is a Go plugin used to test the loading of .so files
+package main
+
+import (
+	"context"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+type nativePlugin struct{}
+
+func (p *nativePlugin) Name() string        { return "native" }
+func (p *nativePlugin) Version() string     { return "1.0.0" }
+func (p *nativePlugin) Description() string { return "Native test plugin" }
+
+func (p *nativePlugin) Scan(ctx context.Context) ([]types.Target, error) {
+	return []types.Target{{Path: "/tmp/native", ProfileName: "native"}}, nil
+}
+
+func (p *nativePlugin) Clean(ctx context.Context, targets []types.Target) error {
+	return nil
+}
+
+// APIVersion is the version of the plugin interface implemented
+var APIVersion = 1
+
+// Plugin is the plugin loaded by rosia
+var Plugin nativePlugin
+
+func main() {}
@@ -0,0 +1,64 @@
+package profiles
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// Category returns the category of the first pattern of profile matching
+// name, or "" when that pattern has none
+func (l *Loader) Category(name string, profile *types.Profile) string {
+	if len(profile.Categories) == 0 {
+		return ""
+	}
+	return profile.Categories[l.matchingPattern(name, profile)]
+}
+
+// matchingPattern returns the first pattern, regex pattern or symlink
+// pattern of profile matching name, or "" when none does
+func (l *Loader) matchingPattern(name string, profile *types.Profile) string {
+	for _, pattern := range profile.Patterns {
+		if matched, err := filepath.Match(pattern, name); (err == nil && matched) || name == pattern {
+			return pattern
+		}
+	}
+	for _, pattern := range profile.RegexPatterns {
+		if re, err := l.compileRegex(pattern); err == nil && re.MatchString(name) {
+			return pattern
+		}
+	}
+	for _, pattern := range profile.Symlinks {
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// hasPattern reports whether pattern is one of the patterns, regex patterns
+// or symlink patterns of profile, which per-pattern settings may refer to
+func hasPattern(profile *types.Profile, pattern string) bool {
+	return slices.Contains(profile.Patterns, pattern) || slices.Contains(profile.RegexPatterns, pattern) || slices.Contains(profile.Symlinks, pattern)
+}
+
+// validateCategories checks that every category of profile is known and
+// assigned to one of its patterns
+func validateCategories(profile *types.Profile) []fieldError {
+	var errs []fieldError
+	for _, pattern := range slices.Sorted(maps.Keys(profile.Categories)) {
+		category := profile.Categories[pattern]
+		if !slices.Contains(types.Categories, category) {
+			errs = append(errs, fieldError{field: "categories", value: pattern, err: fmt.Errorf("invalid category '%s' for pattern '%s': must be one of %v", category, pattern, types.Categories)})
+			continue
+		}
+		// Patterns may be inherited, so only their own profile can be checked
+		if profile.Extends == "" && !hasPattern(profile, pattern) {
+			errs = append(errs, fieldError{field: "categories", value: pattern, err: fmt.Errorf("category given for unknown pattern '%s'", pattern)})
+		}
+	}
+	return errs
+}
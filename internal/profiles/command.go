@@ -0,0 +1,141 @@
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// detectCommandTimeout is how long a detect command may run before it is
+// killed and the directory counted as not detected
+const detectCommandTimeout = 10 * time.Second
+
+// detectCommandEnv lists the environment variables detect commands inherit.
+// Everything else, such as credentials, is withheld.
+var detectCommandEnv = []string{"PATH", "HOME", "USERPROFILE", "SYSTEMROOT", "TMPDIR", "TEMP", "TMP", "LANG"}
+
+// AllowCommands lets the profiles of names run their detect command,
+// replacing the profiles allowed before. Profiles are named as in
+// EnableOnly. The detect commands of other profiles never run, and the
+// directories they would confirm are not detected. It returns the names that
+// match no profile.
+func (l *Loader) AllowCommands(names []string) []string {
+	l.cacheMutex.Lock()
+	defer l.cacheMutex.Unlock()
+
+	l.allowedCmds = make(map[string]bool, len(names))
+	for _, name := range names {
+		l.allowedCmds[normalizeName(name)] = true
+	}
+
+	// Directories may now match other profiles
+	l.matchCache = make(map[string][]*types.Profile)
+
+	found := make(map[string]bool, len(names))
+	for i := range l.profiles {
+		found[normalizeName(l.profiles[i].Name)] = true
+		found[normalizeName(l.profiles[i].ID)] = true
+	}
+	var unknown []string
+	for _, name := range names {
+		if !found[normalizeName(name)] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// CommandAllowed reports whether profile may run its detect command, having
+// been named to AllowCommands
+func (l *Loader) CommandAllowed(profile *types.Profile) bool {
+	l.cacheMutex.RLock()
+	defer l.cacheMutex.RUnlock()
+
+	for _, key := range []string{normalizeName(profile.Name), normalizeName(profile.ID)} {
+		if key != "" && l.allowedCmds[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// commandConfirms reports whether the detect command of profile exits with
+// status 0 when run in dir, or whether the profile has no command. Commands
+// of profiles not allowed by AllowCommands confirm nothing. Results are cached
+// by directory and command, so a command runs once per directory for the
+// loader's lifetime.
+func (l *Loader) commandConfirms(dir string, profile *types.Profile) bool {
+	command := profile.DetectCommand
+	if len(command) == 0 {
+		return true
+	}
+	if !l.CommandAllowed(profile) {
+		return false
+	}
+
+	key := dir + "\x00" + strings.Join(command, "\x00")
+	l.cacheMutex.RLock()
+	confirmed, exists := l.commandCache[key]
+	l.cacheMutex.RUnlock()
+	if exists {
+		return confirmed
+	}
+
+	confirmed = runDetectCommand(dir, command) == nil
+
+	l.cacheMutex.Lock()
+	l.commandCache[key] = confirmed
+	l.cacheMutex.Unlock()
+
+	return confirmed
+}
+
+// runDetectCommand runs command in dir without a shell, with no input, its
+// output discarded and only the variables of detectCommandEnv, killing it
+// after detectCommandTimeout. The command may still read and write what the
+// user can.
+func runDetectCommand(dir string, command []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), detectCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.WaitDelay = time.Second
+	for _, name := range detectCommandEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+	if cmd.Env == nil {
+		cmd.Env = []string{}
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("detect command %q failed in %s: %w", strings.Join(command, " "), dir, err)
+	}
+	return nil
+}
+
+// validateDetectCommand checks that the detect command of profile, if any,
+// runs a program of the PATH or one given by an absolute path. Programs
+// relative to the scanned directory could come from any repository.
+func validateDetectCommand(profile *types.Profile) []fieldError {
+	if len(profile.DetectCommand) == 0 {
+		return nil
+	}
+
+	program := profile.DetectCommand[0]
+	switch {
+	case program == "":
+		return []fieldError{{field: "detect_command", value: program, err: fmt.Errorf("detect_command must name a program")}}
+	case !filepath.IsAbs(program) && strings.Contains(filepath.ToSlash(program), "/"):
+		return []fieldError{{field: "detect_command", value: program, err: fmt.Errorf("detect_command must run a program of the PATH or an absolute path, not '%s'", program)}}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package profiles
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// maxContentSize is how much of a detect file is read to match its content.
+// Detect files are manifests, small enough to be read whole.
+const maxContentSize = 1 << 20
+
+// contentMatches reports whether the file at path matches the regular
+// expression expr, or whether expr is empty. Directories never match an
+// expression.
+func (l *Loader) contentMatches(path, expr string) bool {
+	if expr == "" {
+		return true
+	}
+	re, err := l.compileRegex(expr)
+	if err != nil {
+		return false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxContentSize))
+	if err != nil {
+		return false
+	}
+	return re.Match(data)
+}
+
+// validateDetectContent checks that every detect_content expression of
+// profile compiles and is given for one of its detect patterns
+func (l *Loader) validateDetectContent(profile *types.Profile) []fieldError {
+	var errs []fieldError
+	for _, pattern := range slices.Sorted(maps.Keys(profile.DetectContent)) {
+		expr := profile.DetectContent[pattern]
+		if expr == "" {
+			errs = append(errs, fieldError{field: "detect_content", value: pattern, err: fmt.Errorf("detect_content of '%s' must not be empty", pattern)})
+			continue
+		}
+		if _, err := l.compileRegex(expr); err != nil {
+			errs = append(errs, fieldError{field: "detect_content", value: pattern, err: fmt.Errorf("invalid detect_content of '%s': %w", pattern, err)})
+			continue
+		}
+		// Detect patterns may be inherited, so only their own profile can be checked
+		if profile.Extends == "" && !isDetectPattern(profile, pattern) {
+			errs = append(errs, fieldError{field: "detect_content", value: pattern, err: fmt.Errorf("detect_content given for unknown detect pattern '%s'", pattern)})
+		}
+	}
+	return errs
+}
+
+// isDetectPattern reports whether pattern is a detect pattern of profile or
+// one of the detect files its patterns require
+func isDetectPattern(profile *types.Profile, pattern string) bool {
+	if slices.Contains(profile.Detect, pattern) {
+		return true
+	}
+	for _, files := range profile.Requires {
+		if slices.Contains(files, pattern) {
+			return true
+		}
+	}
+	return false
+}
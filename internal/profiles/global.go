@@ -0,0 +1,87 @@
+package profiles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// homePrefix starts global patterns relative to the user's home directory
+const homePrefix = "~/"
+
+// GlobalTarget is a location outside projects selected by a global pattern
+type GlobalTarget struct {
+	Path string // Absolute path of the location
+	Name string // Path as matched by the pattern, e.g. "~/Library/Developer/Xcode/DerivedData"
+}
+
+// isGlobalPattern reports whether pattern names a fixed location, such as
+// "~/Library/Developer/Xcode/DerivedData", rather than a path inside projects
+func isGlobalPattern(pattern string) bool {
+	return strings.HasPrefix(filepath.ToSlash(pattern), homePrefix) || filepath.IsAbs(pattern)
+}
+
+// GlobalTargets returns the existing directories matched by the global
+// patterns of profile, those starting with "~/" or an absolute path. They are
+// shared by all the projects of the profile, e.g. the DerivedData directory
+// of Xcode.
+func (l *Loader) GlobalTargets(profile *types.Profile) []GlobalTarget {
+	var targets []GlobalTarget
+	for _, pattern := range profile.Patterns {
+		if !isGlobalPattern(pattern) {
+			continue
+		}
+
+		// Names keep the "~/" of the pattern, so that per-pattern settings
+		// such as categories apply
+		var homeDir string
+		glob := pattern
+		if rest, ok := strings.CutPrefix(filepath.ToSlash(pattern), homePrefix); ok {
+			var err error
+			if homeDir, err = os.UserHomeDir(); err != nil {
+				continue
+			}
+			glob = filepath.Join(homeDir, filepath.FromSlash(rest))
+		}
+
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || !info.IsDir() || slices.ContainsFunc(targets, func(t GlobalTarget) bool { return t.Path == match }) {
+				continue
+			}
+			name := match
+			if homeDir != "" {
+				rel, err := filepath.Rel(homeDir, match)
+				if err != nil {
+					continue
+				}
+				name = homePrefix + filepath.ToSlash(rel)
+			}
+			targets = append(targets, GlobalTarget{Path: match, Name: name})
+		}
+	}
+	return targets
+}
+
+// validateGlobalPattern checks that a global pattern names a location below
+// the home directory or the root, without "." or ".." parts
+func validateGlobalPattern(pattern string) error {
+	rest, ok := strings.CutPrefix(filepath.ToSlash(pattern), homePrefix)
+	if !ok {
+		rest = strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(pattern, filepath.VolumeName(pattern))), "/")
+	}
+	for _, part := range strings.Split(rest, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid global pattern '%s'", pattern)
+		}
+	}
+	return nil
+}
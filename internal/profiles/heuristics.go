@@ -0,0 +1,110 @@
+package profiles
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// heuristicMatch returns the description of the first of heuristics that
+// dirPath matches and the directory it inspected. Both are empty when none
+// matches.
+func heuristicMatch(dirPath string, heuristics []types.DetectHeuristic) (string, string) {
+	for _, heuristic := range heuristics {
+		root := filepath.Join(dirPath, filepath.FromSlash(heuristic.Path))
+		if heuristicMatches(root, heuristic) {
+			return heuristic.String(), root
+		}
+	}
+	return "", ""
+}
+
+// heuristicMatches reports whether root is a directory with every entry
+// heuristic requires and enough files. Files are only counted once the
+// entries are found, so most directories cost a few stats.
+func heuristicMatches(root string, heuristic types.DetectHeuristic) bool {
+	info, err := os.Stat(root)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+
+	for _, pattern := range heuristic.Contains {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		if err != nil || len(matches) == 0 {
+			return false
+		}
+	}
+
+	return heuristic.MinFiles == 0 || countFiles(root, heuristic.MinFiles) >= heuristic.MinFiles
+}
+
+// countFiles returns the number of files in the tree of root, counting no
+// further than limit
+func countFiles(root string, limit int) int {
+	count := 0
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			count++
+			if count >= limit {
+				return filepath.SkipAll
+			}
+		}
+		return nil
+	})
+	return count
+}
+
+// validateHeuristics checks that every detect heuristic of profile inspects
+// a directory inside the project and requires at least one entry, so that
+// files are never counted in every scanned directory
+func validateHeuristics(profile *types.Profile) []fieldError {
+	var errs []fieldError
+	fail := func(heuristic types.DetectHeuristic, err error) {
+		errs = append(errs, fieldError{field: "detect_heuristics", value: heuristic.Path, err: err})
+	}
+
+	for _, heuristic := range profile.DetectHeuristics {
+		if heuristic.Path != "" {
+			if err := validateHeuristicPath(heuristic.Path); err != nil {
+				fail(heuristic, err)
+			} else if hasGlobChars(heuristic.Path) {
+				fail(heuristic, fmt.Errorf("detect heuristic path must not be a glob: '%s'", heuristic.Path))
+			}
+		}
+		if len(heuristic.Contains) == 0 {
+			fail(heuristic, fmt.Errorf("detect heuristic '%s' must list the entries it contains", heuristic))
+		}
+		for _, pattern := range heuristic.Contains {
+			if err := validateHeuristicPath(pattern); err != nil {
+				fail(heuristic, err)
+			} else if _, err := filepath.Match(pattern, "test"); err != nil {
+				fail(heuristic, fmt.Errorf("invalid detect heuristic entry '%s': %w", pattern, err))
+			}
+		}
+		if heuristic.MinFiles < 0 {
+			fail(heuristic, fmt.Errorf("min_files of detect heuristic '%s' must not be negative", heuristic))
+		}
+	}
+	return errs
+}
+
+// validateHeuristicPath checks that path, the directory of a heuristic or
+// one of its entries, stays inside the directory it is relative to
+func validateHeuristicPath(path string) error {
+	if filepath.IsAbs(path) {
+		return fmt.Errorf("detect heuristic path must be relative: '%s'", path)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == "" || part == "." || part == ".." || part == "**" {
+			return fmt.Errorf("invalid detect heuristic path '%s'", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package profiles
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// resolveInheritance returns profiles with every profile that extends another
+// merged with its parent. Profiles whose parent is missing or that extend
+// each other in a cycle are dropped with a warning. The order is preserved.
+func resolveInheritance(profiles []types.Profile) []types.Profile {
+	byName := make(map[string]*types.Profile, len(profiles))
+	for i := range profiles {
+		byName[profiles[i].Name] = &profiles[i]
+	}
+
+	resolved := make(map[string]*types.Profile, len(profiles))
+	result := make([]types.Profile, 0, len(profiles))
+	for i := range profiles {
+		profile, err := resolveProfile(&profiles[i], byName, resolved, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to load profile %s: %v\n", profiles[i].Name, err)
+			continue
+		}
+		result = append(result, *profile)
+	}
+
+	return result
+}
+
+// resolveProfile merges profile with its ancestors. chain holds the names of
+// the profiles being resolved that extend profile, to detect cycles.
+func resolveProfile(profile *types.Profile, byName, resolved map[string]*types.Profile, chain []string) (*types.Profile, error) {
+	if done, ok := resolved[profile.Name]; ok {
+		return done, nil
+	}
+	if profile.Extends == "" {
+		resolved[profile.Name] = profile
+		return profile, nil
+	}
+
+	chain = append(chain, profile.Name)
+	for _, name := range chain {
+		if name == profile.Extends {
+			return nil, fmt.Errorf("inheritance cycle: %s -> %s", strings.Join(chain, " -> "), profile.Extends)
+		}
+	}
+
+	parent, ok := byName[profile.Extends]
+	if !ok {
+		return nil, fmt.Errorf("extended profile not found: %s", profile.Extends)
+	}
+	parent, err := resolveProfile(parent, byName, resolved, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := inherit(*parent, *profile)
+	resolved[profile.Name] = &merged
+	return &merged, nil
+}
+
+// inherit returns child with the patterns, regex patterns, symlink patterns,
+// detect entries and heuristics, keep and permanent patterns of parent added
+// before its own. The description, rebuild hint, detect command, priority and
+// thresholds are inherited when child has none, and the per-pattern settings
+// of parent, such as categories, when child does not override them.
+func inherit(parent, child types.Profile) types.Profile {
+	child.Patterns = mergePatterns(parent.Patterns, child.Patterns)
+	child.RegexPatterns = mergePatterns(parent.RegexPatterns, child.RegexPatterns)
+	child.Detect = mergePatterns(parent.Detect, child.Detect)
+	child.Keep = mergePatterns(parent.Keep, child.Keep)
+	child.Permanent = mergePatterns(parent.Permanent, child.Permanent)
+	child.Symlinks = mergePatterns(parent.Symlinks, child.Symlinks)
+	child.DetectHeuristics = slices.Concat(parent.DetectHeuristics, child.DetectHeuristics)
+
+	if child.Description == "" {
+		child.Description = parent.Description
+	}
+	if child.RebuildHint == "" {
+		child.RebuildHint = parent.RebuildHint
+	}
+	if child.Priority == 0 {
+		child.Priority = parent.Priority
+	}
+	if len(child.DetectCommand) == 0 {
+		child.DetectCommand = parent.DetectCommand
+	}
+	child.Categories = mergeMaps(parent.Categories, child.Categories)
+	child.Requires = mergeMaps(parent.Requires, child.Requires)
+	child.StaleAfter = mergeMaps(parent.StaleAfter, child.StaleAfter)
+	child.DetectContent = mergeMaps(parent.DetectContent, child.DetectContent)
+	if child.MinSize == "" {
+		child.MinSize = parent.MinSize
+	}
+	if child.MinAge == "" {
+		child.MinAge = parent.MinAge
+	}
+
+	return child
+}
+
+// mergePatterns returns the patterns of base followed by those of extra that
+// are not in base
+func mergePatterns(base, extra []string) []string {
+	if len(base) == 0 {
+		return extra
+	}
+
+	merged := make([]string, 0, len(base)+len(extra))
+	seen := make(map[string]bool, len(base)+len(extra))
+	for _, pattern := range append(append([]string{}, base...), extra...) {
+		if seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		merged = append(merged, pattern)
+	}
+	return merged
+}
+
+// mergeMaps returns the per-pattern settings of base, such as categories,
+// overridden by those of extra
+func mergeMaps[V any](base, extra map[string]V) map[string]V {
+	if len(base) == 0 {
+		return extra
+	}
+
+	merged := make(map[string]V, len(base)+len(extra))
+	for pattern, value := range base {
+		merged[pattern] = value
+	}
+	for pattern, value := range extra {
+		merged[pattern] = value
+	}
+	return merged
+}
@@ -0,0 +1,70 @@
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadAll_Extends(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"node.json":   `{"name": "Node.js", "version": "1.0.0", "patterns": ["node_modules", ".next"], "detect": ["package.json"], "rebuild_hint": "run npm install", "enabled": true}`,
+		"next.json":   `{"name": "Next.js", "version": "1.0.0", "extends": "Node.js", "patterns": [".next", ".turbo"], "enabled": true}`,
+		"orphan.json": `{"name": "Orphan", "version": "1.0.0", "extends": "Missing", "enabled": true}`,
+		"a.json":      `{"name": "A", "version": "1.0.0", "extends": "B", "enabled": true}`,
+		"b.json":      `{"name": "B", "version": "1.0.0", "extends": "A", "enabled": true}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+	}
+
+	loader := NewLoader()
+	profiles, err := loader.LoadAll(dir)
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+
+	// Profiles with a missing parent or in a cycle are dropped
+	if len(profiles) != 2 {
+		t.Fatalf("Expected 2 profiles, got %d", len(profiles))
+	}
+
+	next, err := loader.GetProfile("Next.js")
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if want := []string{"node_modules", ".next", ".turbo"}; !reflect.DeepEqual(next.Patterns, want) {
+		t.Errorf("Expected patterns %v, got %v", want, next.Patterns)
+	}
+	if want := []string{"package.json"}; !reflect.DeepEqual(next.Detect, want) {
+		t.Errorf("Expected detect %v, got %v", want, next.Detect)
+	}
+	if next.RebuildHint != "run npm install" {
+		t.Errorf("Expected inherited rebuild hint, got %q", next.RebuildHint)
+	}
+
+	// The parent is unchanged
+	node, err := loader.GetProfile("Node.js")
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if len(node.Patterns) != 2 {
+		t.Errorf("Expected parent patterns to be unchanged, got %v", node.Patterns)
+	}
+}
+
+func TestLoadProfile_ExtendsSelf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "self.json")
+	data := `{"name": "Self", "version": "1.0.0", "extends": "Self", "enabled": true}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	if _, err := NewLoader().LoadProfile(path); err == nil {
+		t.Error("Expected error for profile extending itself, got nil")
+	}
+}
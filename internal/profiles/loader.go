@@ -18,8 +18,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/raucheacho/rosia-cli/pkg/types"
 )
@@ -30,8 +33,12 @@ import (
 // and provides efficient profile matching with caching support.
 type Loader struct {
 	profiles     []types.Profile
+	unresolved   []types.Profile // Profiles as loaded, before inheritance
 	profileCache map[string]*types.Profile
-	matchCache   map[string]*types.Profile
+	matchCache   map[string][]*types.Profile
+	regexCache   map[string]*regexp.Regexp
+	commandCache map[string]bool
+	allowedCmds  map[string]bool // Normalized names of the profiles allowed to run their detect command
 	cacheMutex   sync.RWMutex
 }
 
@@ -40,12 +47,61 @@ func NewLoader() *Loader {
 	return &Loader{
 		profiles:     make([]types.Profile, 0),
 		profileCache: make(map[string]*types.Profile),
-		matchCache:   make(map[string]*types.Profile),
+		matchCache:   make(map[string][]*types.Profile),
+		regexCache:   make(map[string]*regexp.Regexp),
+		commandCache: make(map[string]bool),
 	}
 }
 
 // LoadAll reads all JSON profiles from the specified directory
 func (l *Loader) LoadAll(dir string) ([]types.Profile, error) {
+	profiles, err := l.loadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.setProfiles(profiles), nil
+}
+
+// LoadDirs reads the JSON profiles of several directories, in order. A
+// profile replaces any profile of the same name loaded from an earlier
+// directory, so user directories listed after the built-in one can override
+// built-in profiles. Missing directories are skipped; an error is returned
+// only if none of them exist.
+func (l *Loader) LoadDirs(dirs ...string) ([]types.Profile, error) {
+	profiles := make([]types.Profile, 0)
+	index := make(map[string]int)
+	found := false
+
+	for _, dir := range dirs {
+		loaded, err := l.loadDir(dir)
+		if err != nil {
+			if _, notFound := err.(types.ErrPathNotFound); notFound {
+				continue
+			}
+			return nil, err
+		}
+		found = true
+
+		for _, profile := range loaded {
+			if i, exists := index[profile.Name]; exists {
+				profiles[i] = profile
+				continue
+			}
+			index[profile.Name] = len(profiles)
+			profiles = append(profiles, profile)
+		}
+	}
+
+	if !found && len(dirs) > 0 {
+		return nil, types.ErrPathNotFound{Path: dirs[0]}
+	}
+
+	return l.setProfiles(profiles), nil
+}
+
+// loadDir reads all JSON profiles from dir, skipping invalid ones
+func (l *Loader) loadDir(dir string) ([]types.Profile, error) {
 	// Check if directory exists
 	if _, err := os.Stat(dir); err != nil {
 		if os.IsNotExist(err) {
@@ -80,23 +136,76 @@ func (l *Loader) LoadAll(dir string) ([]types.Profile, error) {
 		profile, err := l.LoadProfile(profilePath)
 		if err != nil {
 			// Log error but continue loading other profiles
-			fmt.Fprintf(os.Stderr, "Warning: failed to load profile %s: %v\n", entry.Name(), err)
+			fmt.Fprintf(os.Stderr, "Warning: failed to load profile %s: %v\n", profilePath, err)
 			continue
 		}
 
 		profiles = append(profiles, *profile)
 	}
 
+	return profiles, nil
+}
+
+// setProfiles resolves the inheritance of profiles, replaces the loaded
+// profiles with the result and rebuilds the caches. It returns the resolved
+// profiles.
+func (l *Loader) setProfiles(profiles []types.Profile) []types.Profile {
+	unresolved := profiles
+	profiles = resolveInheritance(profiles)
+
+	l.cacheMutex.Lock()
+	defer l.cacheMutex.Unlock()
+
+	l.unresolved = unresolved
 	l.profiles = profiles
 
 	// Build profile cache
-	l.cacheMutex.Lock()
+	l.profileCache = make(map[string]*types.Profile, len(l.profiles))
 	for i := range l.profiles {
 		l.profileCache[l.profiles[i].Name] = &l.profiles[i]
 	}
-	l.cacheMutex.Unlock()
 
-	return profiles, nil
+	// Matches made with the previous profiles are stale, and so may be the
+	// results of their detect commands
+	l.matchCache = make(map[string][]*types.Profile)
+	l.commandCache = make(map[string]bool)
+
+	return profiles
+}
+
+// AddProfiles adds profiles that come from source rather than from files,
+// such as the profiles of a plugin, to the loaded profiles. They may extend
+// loaded profiles. Invalid profiles, and profiles named like a loaded one,
+// which profile files thus override, are skipped and returned as errors.
+func (l *Loader) AddProfiles(source string, profiles ...types.Profile) []error {
+	l.cacheMutex.RLock()
+	all := slices.Clone(l.unresolved)
+	l.cacheMutex.RUnlock()
+
+	names := make(map[string]bool, len(all))
+	for _, profile := range all {
+		names[profile.Name] = true
+	}
+
+	var errs []error
+	for _, profile := range profiles {
+		if fieldErrs := l.validate(&profile); len(fieldErrs) > 0 {
+			errs = append(errs, fmt.Errorf("profile %s of %s is invalid: %w", profile.Name, source, fieldErrs[0]))
+			continue
+		}
+		if names[profile.Name] {
+			errs = append(errs, fmt.Errorf("profile %s of %s is already loaded", profile.Name, source))
+			continue
+		}
+		if profile.ID == "" {
+			profile.ID = normalizeName(profile.Name)
+		}
+		names[profile.Name] = true
+		all = append(all, profile)
+	}
+
+	l.setProfiles(all)
+	return errs
 }
 
 // LoadProfile loads a single profile from a JSON file
@@ -114,56 +223,230 @@ func (l *Loader) LoadProfile(path string) (*types.Profile, error) {
 
 	var profile types.Profile
 	if err := json.Unmarshal(data, &profile); err != nil {
-		return nil, fmt.Errorf("failed to parse profile JSON from %s: %w", path, err)
+		line, column := syntaxPosition(data, err)
+		return nil, fmt.Errorf("failed to parse profile JSON from %s: %w", position(path, line, column), err)
 	}
 
 	// Validate profile
-	if err := l.validateProfile(&profile); err != nil {
-		return nil, fmt.Errorf("profile validation failed for %s: %w", path, err)
+	if errs := l.validate(&profile); len(errs) > 0 {
+		line, column := locate(data, errs[0].field, errs[0].value)
+		return nil, fmt.Errorf("profile validation failed for %s: %w", position(path, line, column), errs[0])
 	}
 
+	profile.ID = strings.TrimSuffix(filepath.Base(path), ".json")
 	return &profile, nil
 }
 
-// validateProfile checks if a profile has all required fields and valid patterns
-func (l *Loader) validateProfile(profile *types.Profile) error {
+// ValidateProfile checks if a profile has all required fields and valid patterns
+func (l *Loader) ValidateProfile(profile *types.Profile) error {
+	if errs := l.validate(profile); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// validate checks profile like ValidateProfile and returns every problem
+// found, in the order of the checks
+func (l *Loader) validate(profile *types.Profile) []fieldError {
+	var errs []fieldError
+	fail := func(field, value string, err error) {
+		errs = append(errs, fieldError{field: field, value: value, err: err})
+	}
+
 	if profile.Name == "" {
-		return fmt.Errorf("profile name is required")
+		fail("name", "", fmt.Errorf("profile name is required"))
 	}
 
 	if profile.Version == "" {
-		return fmt.Errorf("profile version is required")
+		fail("version", "", fmt.Errorf("profile version is required"))
 	}
 
-	if len(profile.Patterns) == 0 {
-		return fmt.Errorf("profile must have at least one pattern")
+	// Patterns and detect entries may all come from the parent profile
+	if len(profile.Patterns) == 0 && len(profile.RegexPatterns) == 0 && len(profile.Symlinks) == 0 && profile.Extends == "" {
+		fail("patterns", "", fmt.Errorf("profile must have at least one pattern"))
 	}
 
-	if len(profile.Detect) == 0 {
-		return fmt.Errorf("profile must have at least one detect pattern")
+	if len(profile.Detect) == 0 && len(profile.DetectHeuristics) == 0 && profile.Extends == "" {
+		fail("detect", "", fmt.Errorf("profile must have at least one detect pattern or heuristic"))
+	}
+
+	if profile.Extends != "" && profile.Extends == profile.Name {
+		fail("extends", profile.Extends, fmt.Errorf("profile cannot extend itself"))
 	}
 
 	// Validate pattern syntax (basic glob validation)
+	positive := 0
 	for _, pattern := range profile.Patterns {
 		if pattern == "" {
-			return fmt.Errorf("empty pattern found")
+			fail("patterns", pattern, fmt.Errorf("empty pattern found"))
+			continue
 		}
+		if negated, ok := strings.CutPrefix(pattern, negationPrefix); ok {
+			if err := validateNegativePattern(negated); err != nil {
+				fail("patterns", pattern, err)
+			}
+			continue
+		}
+		positive++
 		// Check for valid glob pattern
 		if _, err := filepath.Match(pattern, "test"); err != nil {
-			return fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+			fail("patterns", pattern, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err))
+			continue
+		}
+		if err := validatePathPattern(pattern); err != nil {
+			fail("patterns", pattern, err)
+		}
+	}
+	if len(profile.Patterns) > 0 && positive == 0 && len(profile.RegexPatterns) == 0 && len(profile.Symlinks) == 0 && profile.Extends == "" {
+		fail("patterns", "", fmt.Errorf("profile must have at least one pattern that is not negative"))
+	}
+
+	// Compile regex patterns now so matching never meets an invalid one
+	for _, pattern := range profile.RegexPatterns {
+		if pattern == "" {
+			fail("regex_patterns", pattern, fmt.Errorf("empty regex pattern found"))
+			continue
+		}
+		if _, err := l.compileRegex(pattern); err != nil {
+			fail("regex_patterns", pattern, fmt.Errorf("invalid regex pattern '%s': %w", pattern, err))
 		}
 	}
 
 	// Validate detect patterns
 	for _, detect := range profile.Detect {
-		if detect == "" {
-			return fmt.Errorf("empty detect pattern found")
+		if err := validateDetectPattern(detect); err != nil {
+			fail("detect", detect, err)
+		}
+	}
+
+	// Validate permanent patterns
+	for _, pattern := range profile.Permanent {
+		if _, err := filepath.Match(pattern, "test"); err != nil || pattern == "" {
+			fail("permanent", pattern, fmt.Errorf("invalid permanent pattern '%s'", pattern))
+		}
+	}
+
+	errs = append(errs, validateSymlinks(profile)...)
+	errs = append(errs, validateThresholds(profile)...)
+	errs = append(errs, validateStaleAfter(profile)...)
+	errs = append(errs, l.validateDetectContent(profile)...)
+	errs = append(errs, validateHeuristics(profile)...)
+	errs = append(errs, validateDetectCommand(profile)...)
+	errs = append(errs, validateCategories(profile)...)
+	errs = append(errs, validateRequires(profile)...)
+
+	// Validate keep patterns, which must stay inside the matched target
+	for _, keep := range profile.Keep {
+		if err := ValidateKeepPattern(keep); err != nil {
+			fail("keep", keep, err)
 		}
 	}
 
+	return errs
+}
+
+// ValidateKeepPattern checks that a keep pattern is a valid glob relative to
+// a target and does not escape it
+func ValidateKeepPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty keep pattern found")
+	}
+	if filepath.IsAbs(pattern) {
+		return fmt.Errorf("keep pattern must be relative: '%s'", pattern)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if part == ".." {
+			return fmt.Errorf("keep pattern must not leave the target: '%s'", pattern)
+		}
+	}
+	if _, err := filepath.Match(pattern, "test"); err != nil {
+		return fmt.Errorf("invalid keep pattern '%s': %w", pattern, err)
+	}
 	return nil
 }
 
+// validateNegativePattern checks a pattern without its "!" prefix: a target
+// name, optionally followed by a path inside the target that must not leave it
+func validateNegativePattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty negative pattern found")
+	}
+	first, rest, isPath := strings.Cut(filepath.ToSlash(pattern), "/")
+	if _, err := filepath.Match(first, "test"); err != nil || first == "" {
+		return fmt.Errorf("invalid negative pattern '!%s'", pattern)
+	}
+	if !isPath {
+		return nil
+	}
+	for _, part := range strings.Split(rest, "/") {
+		if part == "" || part == ".." {
+			return fmt.Errorf("invalid negative pattern '!%s'", pattern)
+		}
+	}
+	if _, err := filepath.Match(rest, "test"); err != nil {
+		return fmt.Errorf("invalid negative pattern '!%s': %w", pattern, err)
+	}
+	return nil
+}
+
+// validatePathPattern checks that a pattern naming a path inside the project,
+// such as "var/cache", stays inside it, and checks global patterns
+func validatePathPattern(pattern string) error {
+	if !strings.Contains(filepath.ToSlash(pattern), "/") {
+		return nil
+	}
+	if isGlobalPattern(pattern) {
+		return validateGlobalPattern(pattern)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid path pattern '%s'", pattern)
+		}
+	}
+	return nil
+}
+
+// validateDetectPattern checks a detect pattern: a file name or glob inside
+// the project, optionally prefixed with "**/" to match in subdirectories too
+func validateDetectPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty detect pattern found")
+	}
+	name, recursive := strings.CutPrefix(filepath.ToSlash(pattern), recursivePrefix)
+	if !recursive {
+		name = pattern
+	}
+	if strings.Contains(name, "**") || (recursive && (name == "" || strings.Contains(name, "/"))) {
+		return fmt.Errorf("invalid detect pattern '%s': \"**\" is only allowed as a \"**/name\" prefix", pattern)
+	}
+	if _, err := filepath.Match(name, "test"); err != nil {
+		return fmt.Errorf("invalid detect pattern '%s': %w", pattern, err)
+	}
+	return nil
+}
+
+// compileRegex returns the compiled form of a regex pattern, compiling it
+// once per loader
+func (l *Loader) compileRegex(pattern string) (*regexp.Regexp, error) {
+	l.cacheMutex.RLock()
+	re, exists := l.regexCache[pattern]
+	l.cacheMutex.RUnlock()
+	if exists {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	l.cacheMutex.Lock()
+	l.regexCache[pattern] = re
+	l.cacheMutex.Unlock()
+
+	return re, nil
+}
+
 // GetProfiles returns all loaded profiles
 func (l *Loader) GetProfiles() []types.Profile {
 	return l.profiles
@@ -181,3 +464,95 @@ func (l *Loader) GetProfile(name string) (*types.Profile, error) {
 
 	return profile, nil
 }
+
+// FindProfile returns a profile by name, ignoring case when no profile has
+// exactly that name
+func (l *Loader) FindProfile(name string) (*types.Profile, error) {
+	if profile, err := l.GetProfile(name); err == nil {
+		return profile, nil
+	}
+
+	l.cacheMutex.RLock()
+	defer l.cacheMutex.RUnlock()
+
+	for i := range l.profiles {
+		if strings.EqualFold(l.profiles[i].Name, name) {
+			return &l.profiles[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("profile not found: %s", name)
+}
+
+// EnableOnly disables every profile that names does not list, keeping the
+// state of the others. Profiles are listed by name or by ID, ignoring case
+// and punctuation, so "node", "nodejs" and "Node.js" all name the Node.js
+// profile. An empty list keeps every profile. It returns the names that match
+// no profile.
+func (l *Loader) EnableOnly(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+
+	l.cacheMutex.Lock()
+	defer l.cacheMutex.Unlock()
+
+	listed := make(map[string]bool, len(names))
+	for _, name := range names {
+		listed[normalizeName(name)] = true
+	}
+	found := make(map[string]bool, len(names))
+	for i := range l.profiles {
+		profile := &l.profiles[i]
+		matched := false
+		for _, key := range []string{normalizeName(profile.Name), normalizeName(profile.ID)} {
+			if key != "" && listed[key] {
+				found[key] = true
+				matched = true
+			}
+		}
+		if !matched {
+			profile.Enabled = false
+		}
+	}
+
+	// Directories may now match fewer profiles
+	l.matchCache = make(map[string][]*types.Profile)
+
+	var unknown []string
+	for _, name := range names {
+		if !found[normalizeName(name)] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// normalizeName returns name in lower case without punctuation, e.g.
+// "nodejs" for "Node.js"
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// SetEnabled enables or disables the profile with the given name, overriding
+// the "enabled" field it was loaded with
+func (l *Loader) SetEnabled(name string, enabled bool) error {
+	l.cacheMutex.Lock()
+	defer l.cacheMutex.Unlock()
+
+	profile, exists := l.profileCache[name]
+	if !exists {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+	profile.Enabled = enabled
+
+	// Directories may now match another profile
+	l.matchCache = make(map[string][]*types.Profile)
+
+	return nil
+}
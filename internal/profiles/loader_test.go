@@ -1,9 +1,16 @@
 package profiles
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
 )
 
 func TestLoadAll(t *testing.T) {
@@ -23,11 +30,23 @@ func TestLoadAll(t *testing.T) {
 
 	// Check that we have the expected profiles
 	expectedProfiles := map[string]bool{
-		"Node.js": false,
-		"Python":  false,
-		"Rust":    false,
-		"Flutter": false,
-		"Go":      false,
+		"Node.js":   false,
+		"Python":    false,
+		"Rust":      false,
+		"Flutter":   false,
+		"Go":        false,
+		"Java":      false,
+		".NET":      false,
+		"PHP":       false,
+		"Ruby":      false,
+		"Elixir":    false,
+		"Swift":     false,
+		"Android":   false,
+		"Unity":     false,
+		"Terraform": false,
+		"Haskell":   false,
+		"Bazel":     false,
+		"C/C++":     false,
 	}
 
 	for _, profile := range profiles {
@@ -43,6 +62,52 @@ func TestLoadAll(t *testing.T) {
 	}
 }
 
+func TestLoadDirs(t *testing.T) {
+	builtinDir := filepath.Join("..", "..", "profiles")
+	userDir := t.TempDir()
+
+	// A user profile named like a built-in one replaces it
+	override := `{"name": "Go", "version": "2.0.0", "patterns": ["build"], "detect": ["go.mod"], "enabled": true}`
+	custom := `{"name": "Custom", "version": "1.0.0", "patterns": ["my-cache"], "detect": ["my-project.config"], "enabled": true}`
+	if err := os.WriteFile(filepath.Join(userDir, "go.json"), []byte(override), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(userDir, "custom.json"), []byte(custom), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	loader := NewLoader()
+	profiles, err := loader.LoadDirs(builtinDir, filepath.Join(userDir, "missing"), userDir)
+	if err != nil {
+		t.Fatalf("LoadDirs failed: %v", err)
+	}
+
+	names := map[string]int{}
+	for _, profile := range profiles {
+		names[profile.Name]++
+	}
+	if names["Go"] != 1 {
+		t.Errorf("Expected one Go profile, got %d", names["Go"])
+	}
+	if names["Custom"] != 1 || names["Node.js"] != 1 {
+		t.Errorf("Expected user and built-in profiles, got %v", names)
+	}
+
+	profile, err := loader.GetProfile("Go")
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if profile.Version != "2.0.0" {
+		t.Errorf("Expected user Go profile, got version %s", profile.Version)
+	}
+
+	// Without any existing directory there is nothing to load
+	_, err = NewLoader().LoadDirs(filepath.Join(userDir, "missing"))
+	if _, ok := err.(types.ErrPathNotFound); !ok {
+		t.Errorf("Expected ErrPathNotFound, got %v", err)
+	}
+}
+
 func TestLoadProfile(t *testing.T) {
 	loader := NewLoader()
 
@@ -126,6 +191,198 @@ func TestMatchesPattern(t *testing.T) {
 	}
 }
 
+func TestMatchesPattern_Negative(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{
+		Name:     "Test",
+		Patterns: []string{"build*", "node_modules", "!build-keep", "!node_modules/.cache/janitor"},
+	}
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"build", true},
+		{"build-out", true},
+		{"build-keep", false},
+		{"node_modules", true},
+	}
+
+	for _, tt := range tests {
+		result := loader.MatchesPattern(tt.name, profile)
+		if result != tt.expected {
+			t.Errorf("MatchesPattern(%s) = %v, expected %v", tt.name, result, tt.expected)
+		}
+	}
+
+	excluded := loader.ExcludedPaths("node_modules", profile)
+	if len(excluded) != 1 || excluded[0] != filepath.Join(".cache", "janitor") {
+		t.Errorf("Expected .cache/janitor to be excluded, got %v", excluded)
+	}
+	if excluded := loader.ExcludedPaths("build", profile); len(excluded) != 0 {
+		t.Errorf("Expected no excluded paths for build, got %v", excluded)
+	}
+}
+
+func TestLoadProfile_InvalidNegativePattern(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	tests := map[string]string{
+		"empty.json":    `{"name": "Test", "version": "1.0.0", "patterns": ["dist", "!"], "detect": ["x"], "enabled": true}`,
+		"escape.json":   `{"name": "Test", "version": "1.0.0", "patterns": ["dist", "!dist/../src"], "detect": ["x"], "enabled": true}`,
+		"negative.json": `{"name": "Test", "version": "1.0.0", "patterns": ["!dist"], "detect": ["x"], "enabled": true}`,
+		"badglob.json":  `{"name": "Test", "version": "1.0.0", "patterns": ["dist", "!dist/[a"], "detect": ["x"], "enabled": true}`,
+	}
+
+	for name, data := range tests {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for %s, got nil", name)
+		}
+	}
+}
+
+func TestMatchesPattern_Regex(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	path := filepath.Join(tmpDir, "regex.json")
+	data := `{"name": "Regex", "version": "1.0.0", "regex_patterns": ["^build-[0-9a-f]{8}$"], "patterns": ["!build-deadbeef"], "detect": ["x"], "enabled": true}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	profile, err := loader.LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"build-0123abcd", true},
+		{"build-0123abcde", false},
+		{"build-xyz", false},
+		{"build-deadbeef", false}, // excluded by the negative pattern
+	}
+
+	for _, tt := range tests {
+		result := loader.MatchesPattern(tt.name, profile)
+		if result != tt.expected {
+			t.Errorf("MatchesPattern(%s) = %v, expected %v", tt.name, result, tt.expected)
+		}
+	}
+
+	// Invalid expressions fail at load time
+	data = `{"name": "Regex", "version": "1.0.0", "regex_patterns": ["^build-[0-9"], "detect": ["x"], "enabled": true}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if _, err := loader.LoadProfile(path); err == nil {
+		t.Error("Expected error for invalid regex pattern, got nil")
+	}
+}
+
+func TestMeetsThresholds(t *testing.T) {
+	loader := NewLoader()
+	now := time.Now()
+	old := now.Add(-15 * 24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		profile  types.Profile
+		target   types.Target
+		expected bool
+	}{
+		{"no thresholds", types.Profile{}, types.Target{Size: 1, LastAccessed: now}, true},
+		{"large enough", types.Profile{MinSize: "500MB"}, types.Target{Size: 600 << 20, LastAccessed: now}, true},
+		{"too small", types.Profile{MinSize: "500MB"}, types.Target{Size: 100 << 20, LastAccessed: old}, false},
+		{"old enough", types.Profile{MinAge: "14d"}, types.Target{Size: 1, LastAccessed: old}, true},
+		{"too recent", types.Profile{MinAge: "14d"}, types.Target{Size: 600 << 20, LastAccessed: now}, false},
+		{"either small but old", types.Profile{MinSize: "500MB", MinAge: "14d"}, types.Target{Size: 1, LastAccessed: old}, true},
+		{"either large but recent", types.Profile{MinSize: "500MB", MinAge: "14d"}, types.Target{Size: 600 << 20, LastAccessed: now}, true},
+		{"neither", types.Profile{MinSize: "500MB", MinAge: "14d"}, types.Target{Size: 1, LastAccessed: now}, false},
+	}
+
+	for _, tt := range tests {
+		if result := loader.MeetsThresholds(tt.target, &tt.profile, now); result != tt.expected {
+			t.Errorf("%s: MeetsThresholds = %v, expected %v", tt.name, result, tt.expected)
+		}
+	}
+}
+
+func TestLoadProfile_InvalidThresholds(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	tests := map[string]string{
+		"size.json": `{"name": "Test", "version": "1.0.0", "patterns": ["dist"], "detect": ["x"], "min_size": "500XB", "enabled": true}`,
+		"age.json":  `{"name": "Test", "version": "1.0.0", "patterns": ["dist"], "detect": ["x"], "min_age": "two weeks", "enabled": true}`,
+	}
+
+	for name, data := range tests {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for %s, got nil", name)
+		}
+	}
+}
+
+func TestCategory(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{
+		Name:          "Test",
+		Patterns:      []string{"node_modules", "*.egg-info", "dist"},
+		RegexPatterns: []string{"^build-[0-9]+$"},
+		Categories: map[string]string{
+			"node_modules":   types.CategoryDependencies,
+			"*.egg-info":     types.CategoryBuild,
+			"^build-[0-9]+$": types.CategoryBuild,
+		},
+	}
+
+	tests := map[string]string{
+		"node_modules": types.CategoryDependencies,
+		"pkg.egg-info": types.CategoryBuild,
+		"build-42":     types.CategoryBuild,
+		"dist":         "",
+		"src":          "",
+	}
+
+	for name, expected := range tests {
+		if category := loader.Category(name, profile); category != expected {
+			t.Errorf("Category(%s) = %q, expected %q", name, category, expected)
+		}
+	}
+}
+
+func TestLoadProfile_InvalidCategories(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	tests := map[string]string{
+		"unknown.json": `{"name": "Test", "version": "1.0.0", "patterns": ["dist"], "detect": ["x"], "categories": {"dist": "junk"}, "enabled": true}`,
+		"pattern.json": `{"name": "Test", "version": "1.0.0", "patterns": ["dist"], "detect": ["x"], "categories": {"build": "build"}, "enabled": true}`,
+	}
+
+	for name, data := range tests {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for %s, got nil", name)
+		}
+	}
+}
+
 func TestLoadProfile_InvalidJSON(t *testing.T) {
 	loader := NewLoader()
 
@@ -208,6 +465,65 @@ func TestLoadProfile_InvalidPattern(t *testing.T) {
 	}
 }
 
+func TestLoadProfile_KeepPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		keep    string
+		wantErr bool
+	}{
+		{name: "relative path", keep: `[".cache/turbo", "CACHEDIR.TAG"]`},
+		{name: "glob", keep: `["*.lock"]`},
+		{name: "absolute path", keep: `["/etc/passwd"]`, wantErr: true},
+		{name: "parent directory", keep: `["../sibling"]`, wantErr: true},
+		{name: "empty pattern", keep: `[""]`, wantErr: true},
+		{name: "invalid glob", keep: `["[invalid"]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `{
+				"name": "Test",
+				"version": "1.0",
+				"patterns": ["target"],
+				"detect": ["test.txt"],
+				"keep": ` + tt.keep + `,
+				"enabled": true
+			}`
+
+			profilePath := filepath.Join(t.TempDir(), "keep.json")
+			if err := os.WriteFile(profilePath, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			_, err := NewLoader().LoadProfile(profilePath)
+			if tt.wantErr && err == nil {
+				t.Error("Expected error for keep pattern, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{
+		Patterns:  []string{"venv", "__pycache__"},
+		Permanent: []string{"__pycache__", "*.pyc"},
+	}
+
+	if !loader.IsPermanent("__pycache__", profile) {
+		t.Error("Expected __pycache__ to be permanent")
+	}
+	if !loader.IsPermanent("module.pyc", profile) {
+		t.Error("Expected module.pyc to match a permanent glob")
+	}
+	if loader.IsPermanent("venv", profile) {
+		t.Error("Expected venv not to be permanent")
+	}
+}
+
 func TestMatchProfile_Caching(t *testing.T) {
 	loader := NewLoader()
 
@@ -337,6 +653,47 @@ func TestGetProfile(t *testing.T) {
 	}
 }
 
+func TestSetEnabled(t *testing.T) {
+	loader := NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+
+	profile, err := loader.FindProfile("node.js")
+	if err != nil {
+		t.Fatalf("FindProfile failed: %v", err)
+	}
+	if profile.Name != "Node.js" {
+		t.Errorf("Expected profile name 'Node.js', got '%s'", profile.Name)
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to create package.json: %v", err)
+	}
+	if matched, _ := loader.MatchProfile(tmpDir); matched == nil {
+		t.Fatal("Expected Node.js project to match")
+	}
+
+	// Disabling drops cached matches
+	if err := loader.SetEnabled("Node.js", false); err != nil {
+		t.Fatalf("SetEnabled failed: %v", err)
+	}
+	if matched, _ := loader.MatchProfile(tmpDir); matched != nil {
+		t.Errorf("Expected no match for disabled profile, got %s", matched.Name)
+	}
+	if profile.Enabled {
+		t.Error("Expected profile to be disabled")
+	}
+
+	if err := loader.SetEnabled("NonExistent", true); err == nil {
+		t.Error("Expected error for non-existent profile, got nil")
+	}
+	if _, err := loader.FindProfile("NonExistent"); err == nil {
+		t.Error("Expected error for non-existent profile, got nil")
+	}
+}
+
 func TestMatchProfile_NoMatch(t *testing.T) {
 	loader := NewLoader()
 
@@ -414,3 +771,716 @@ func TestMatchProfile_GlobPattern(t *testing.T) {
 		t.Errorf("Expected profile 'GlobTest', got '%s'", profile.Name)
 	}
 }
+
+func TestDetectMatch(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{Name: "Test", Detect: []string{"missing.txt", "*.csproj"}}
+
+	tmpDir := t.TempDir()
+	if pattern, path := loader.DetectMatch(tmpDir, profile); pattern != "" || path != "" {
+		t.Errorf("Expected no match, got %s (%s)", pattern, path)
+	}
+
+	project := filepath.Join(tmpDir, "App.csproj")
+	if err := os.WriteFile(project, []byte(""), 0644); err != nil {
+		t.Fatalf("Failed to create project file: %v", err)
+	}
+
+	// Disabled profiles are still checked
+	pattern, path := loader.DetectMatch(tmpDir, profile)
+	if pattern != "*.csproj" || path != project {
+		t.Errorf("Expected *.csproj matching %s, got %s (%s)", project, pattern, path)
+	}
+}
+
+func TestMatchesTarget_Requires(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{
+		Name:     "Java",
+		Patterns: []string{"target", "build", "out"},
+		Requires: map[string][]string{
+			"target": {"pom.xml"},
+			"build":  {"build.gradle", "*.gradle.kts"},
+		},
+	}
+
+	maven := t.TempDir()
+	if err := os.WriteFile(filepath.Join(maven, "pom.xml"), []byte(""), 0644); err != nil {
+		t.Fatalf("Failed to create pom.xml: %v", err)
+	}
+	gradle := t.TempDir()
+	if err := os.WriteFile(filepath.Join(gradle, "settings.gradle.kts"), []byte(""), 0644); err != nil {
+		t.Fatalf("Failed to create settings.gradle.kts: %v", err)
+	}
+
+	tests := []struct {
+		dir      string
+		name     string
+		expected bool
+	}{
+		{maven, "target", true},
+		{maven, "build", false},
+		{maven, "out", true},
+		{gradle, "target", false},
+		{gradle, "build", true},
+		{gradle, "out", true},
+		{gradle, "src", false},
+	}
+
+	for _, tt := range tests {
+		if got := loader.MatchesTarget(tt.dir, tt.name, profile); got != tt.expected {
+			t.Errorf("MatchesTarget(%s, %s) = %v, want %v", tt.dir, tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestMatchProfiles(t *testing.T) {
+	loader := NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+
+	// A Rust crate built with Gradle
+	tmpDir := t.TempDir()
+	for _, name := range []string{"Cargo.toml", "build.gradle.kts"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(""), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	matches, err := loader.MatchProfiles(tmpDir)
+	if err != nil {
+		t.Fatalf("MatchProfiles failed: %v", err)
+	}
+	names := make([]string, len(matches))
+	for i, profile := range matches {
+		names[i] = profile.Name
+	}
+	if len(names) != 2 || names[0] != "Java" || names[1] != "Rust" {
+		t.Errorf("Expected Java and Rust, got %v", names)
+	}
+
+	if profile, _ := loader.MatchProfile(tmpDir); profile == nil || profile.Name != "Java" {
+		t.Errorf("Expected MatchProfile to return the first match, got %v", profile)
+	}
+}
+
+func TestMatchProfiles_Priority(t *testing.T) {
+	userDir := t.TempDir()
+	// Rust cleans target before Java, loaded first, in mixed projects
+	data := `{"name": "Rust", "version": "1.0.0", "patterns": ["target"], "detect": ["Cargo.toml"], "priority": 10, "enabled": true}`
+	if err := os.WriteFile(filepath.Join(userDir, "rust.json"), []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	loader := NewLoader()
+	if _, err := loader.LoadDirs(filepath.Join("..", "..", "profiles"), userDir); err != nil {
+		t.Fatalf("LoadDirs failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	for _, name := range []string{"Cargo.toml", "build.gradle.kts"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(""), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	matches, err := loader.MatchProfiles(tmpDir)
+	if err != nil {
+		t.Fatalf("MatchProfiles failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Name != "Rust" || matches[1].Name != "Java" {
+		t.Errorf("Expected Rust before Java, got %v", matches)
+	}
+}
+
+func TestLoadProfile_InvalidRequires(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	tests := map[string]string{
+		"empty.json":   `{"name": "Test", "version": "1.0.0", "patterns": ["target"], "detect": ["x"], "requires": {"target": []}}`,
+		"unknown.json": `{"name": "Test", "version": "1.0.0", "patterns": ["target"], "detect": ["x"], "requires": {"build": ["pom.xml"]}}`,
+	}
+
+	for name, data := range tests {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for %s, got nil", name)
+		}
+	}
+}
+
+func TestDetectMatch_Recursive(t *testing.T) {
+	loader := NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	profile := &types.Profile{Name: "Test", Detect: []string{"**/*.csproj"}}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"App.csproj", true},
+		{"src/App/App.csproj", true},
+		{"src/App/Nested/App.csproj", false},
+		{".hidden/App.csproj", false},
+		{"node_modules/pkg/App.csproj", false},
+	}
+
+	for _, tt := range tests {
+		root := t.TempDir()
+		file := filepath.Join(root, filepath.FromSlash(tt.path))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(""), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", file, err)
+		}
+
+		pattern, path := loader.DetectMatch(root, profile)
+		if tt.expected && (pattern != "**/*.csproj" || path != file) {
+			t.Errorf("Expected %s to be detected, got %q (%s)", tt.path, pattern, path)
+		}
+		if !tt.expected && pattern != "" {
+			t.Errorf("Expected %s not to be detected, got %s", tt.path, path)
+		}
+	}
+}
+
+func TestDetectMatch_RecursiveDuringReload(t *testing.T) {
+	dir := filepath.Join("..", "..", "profiles")
+	loader := NewLoader()
+	if _, err := loader.LoadAll(dir); err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	profile := &types.Profile{Name: "Test", Detect: []string{"**/*.csproj"}}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "src", "node_modules"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	// Profiles reloaded while a scan searches subdirectories, run with -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			loader.LoadAll(dir)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		loader.DetectMatch(root, profile)
+	}
+	<-done
+}
+
+func TestLoadProfile_InvalidDetect(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	for i, detect := range []string{"**/", "**/src/*.csproj", "src/**/*.csproj", "[invalid"} {
+		data := fmt.Sprintf(`{"name": "Test", "version": "1.0.0", "patterns": ["bin"], "detect": [%q]}`, detect)
+		path := filepath.Join(tmpDir, fmt.Sprintf("detect%d.json", i))
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for detect pattern %q, got nil", detect)
+		}
+	}
+}
+
+func TestNestedTargets(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{Name: "Test", Patterns: []string{"vendor", "var/cache", "tmp/*/cache", "!var/log"}}
+
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"vendor", "var/cache", "var/log", "tmp/a/cache", "tmp/b"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+
+	targets := loader.NestedTargets(tmpDir, profile)
+	expected := []string{filepath.Join(tmpDir, "var", "cache"), filepath.Join(tmpDir, "tmp", "a", "cache")}
+	if len(targets) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, targets)
+	}
+	for i := range expected {
+		if targets[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], targets[i])
+		}
+	}
+
+	// Path patterns never match a name alone
+	if loader.MatchesPattern("cache", profile) {
+		t.Error("Expected cache not to match var/cache")
+	}
+	if got := loader.Category("var/cache", &types.Profile{Patterns: profile.Patterns, Categories: map[string]string{"var/cache": types.CategoryCache}}); got != types.CategoryCache {
+		t.Errorf("Expected category %q for var/cache, got %q", types.CategoryCache, got)
+	}
+}
+
+func TestLoadProfile_InvalidPathPattern(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	for i, pattern := range []string{"/var/../cache", "var/../cache", "var//cache", "var/", "~/", "~/./cache"} {
+		data := fmt.Sprintf(`{"name": "Test", "version": "1.0.0", "patterns": [%q], "detect": ["x"]}`, pattern)
+		path := filepath.Join(tmpDir, fmt.Sprintf("path%d.json", i))
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for pattern %q, got nil", pattern)
+		}
+	}
+}
+
+func TestStaleAfter(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{
+		Name:       "Test",
+		Patterns:   []string{"_build/*", "deps"},
+		StaleAfter: map[string]string{"_build/*": "30d"},
+	}
+
+	if got := loader.StaleAfter("_build/dev", profile); got != 30*24*time.Hour {
+		t.Errorf("Expected 720h for _build/dev, got %v", got)
+	}
+	if got := loader.StaleAfter("deps", profile); got != 0 {
+		t.Errorf("Expected no stale_after for deps, got %v", got)
+	}
+}
+
+func TestLoadProfile_InvalidStaleAfter(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	for i, staleAfter := range []string{`{"_build/*": "soon"}`, `{"cover": "30d"}`} {
+		data := fmt.Sprintf(`{"name": "Test", "version": "1.0.0", "patterns": ["_build/*"], "detect": ["x"], "stale_after": %s}`, staleAfter)
+		path := filepath.Join(tmpDir, fmt.Sprintf("stale%d.json", i))
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for stale_after %s, got nil", staleAfter)
+		}
+	}
+}
+
+func TestGlobalTargets(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	for _, dir := range []string{"Library/Developer/Xcode/DerivedData/App-abc", "Library/Developer/Xcode/DerivedData/Lib-def", "cache"} {
+		if err := os.MkdirAll(filepath.Join(homeDir, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	other := t.TempDir()
+
+	loader := NewLoader()
+	profile := &types.Profile{Name: "Test", Patterns: []string{"build", "~/Library/Developer/Xcode/DerivedData/*", other, "~/missing"}}
+
+	targets := loader.GlobalTargets(profile)
+	expected := []GlobalTarget{
+		{Path: filepath.Join(homeDir, "Library", "Developer", "Xcode", "DerivedData", "App-abc"), Name: "~/Library/Developer/Xcode/DerivedData/App-abc"},
+		{Path: filepath.Join(homeDir, "Library", "Developer", "Xcode", "DerivedData", "Lib-def"), Name: "~/Library/Developer/Xcode/DerivedData/Lib-def"},
+		{Path: other, Name: other},
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, targets)
+	}
+	for i := range expected {
+		if targets[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], targets[i])
+		}
+	}
+
+	// Global patterns are neither names nor paths inside projects
+	if loader.MatchesPattern("DerivedData", profile) {
+		t.Error("Expected DerivedData not to match a global pattern")
+	}
+	if nested := loader.NestedTargets(homeDir, profile); len(nested) != 0 {
+		t.Errorf("Expected no nested targets, got %v", nested)
+	}
+	if got := loader.Category(targets[0].Name, &types.Profile{Patterns: profile.Patterns, Categories: map[string]string{"~/Library/Developer/Xcode/DerivedData/*": types.CategoryBuild}}); got != types.CategoryBuild {
+		t.Errorf("Expected category %q for %s, got %q", types.CategoryBuild, targets[0].Name, got)
+	}
+}
+
+func TestDetectContent(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{
+		Name:          "Test",
+		Patterns:      []string{"captures"},
+		Detect:        []string{"settings.gradle", "*.properties"},
+		DetectContent: map[string]string{"settings.gradle": `com\.android`, "*.properties": `(?m)^android\.`},
+		Enabled:       true,
+	}
+
+	tmpDir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// Files without the content do not count
+	write("settings.gradle", "include ':lib'\n")
+	write("local.properties", "sdk.dir=/opt/sdk\n")
+	if pattern, _ := loader.DetectMatch(tmpDir, profile); pattern != "" {
+		t.Errorf("Expected no detection, got %s", pattern)
+	}
+
+	// Any file matched by a glob may have the content
+	write("gradle.properties", "org.gradle.jvmargs=-Xmx2g\nandroid.useAndroidX=true\n")
+	pattern, path := loader.DetectMatch(tmpDir, profile)
+	if pattern != "*.properties" || path != filepath.Join(tmpDir, "gradle.properties") {
+		t.Errorf("Expected *.properties to match gradle.properties, got %s (%s)", pattern, path)
+	}
+}
+
+func TestLoadProfile_InvalidDetectContent(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	for i, content := range []string{`{"build.gradle": "(unclosed"}`, `{"pom.xml": "x"}`, `{"build.gradle": ""}`} {
+		data := fmt.Sprintf(`{"name": "Test", "version": "1.0.0", "patterns": ["build"], "detect": ["build.gradle"], "detect_content": %s}`, content)
+		path := filepath.Join(tmpDir, fmt.Sprintf("content%d.json", i))
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for detect_content %s, got nil", content)
+		}
+	}
+}
+
+func TestMatchesSymlink(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{Name: "Test", Symlinks: []string{"bazel-*"}, Categories: map[string]string{"bazel-*": types.CategoryBuild}}
+
+	if !loader.MatchesSymlink("bazel-out", profile) {
+		t.Error("Expected bazel-out to match bazel-*")
+	}
+	if loader.MatchesSymlink("out", profile) {
+		t.Error("Expected out not to match bazel-*")
+	}
+	// Symlink patterns do not select directories
+	if loader.MatchesPattern("bazel-out", profile) {
+		t.Error("Expected bazel-out not to match the patterns")
+	}
+	if got := loader.Category("bazel-bin", profile); got != types.CategoryBuild {
+		t.Errorf("Expected category %q for bazel-bin, got %q", types.CategoryBuild, got)
+	}
+}
+
+func TestLoadProfile_InvalidSymlinks(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	for i, pattern := range []string{"[invalid", "out/bazel-*", ""} {
+		data := fmt.Sprintf(`{"name": "Test", "version": "1.0.0", "patterns": [], "symlinks": [%q], "detect": ["x"]}`, pattern)
+		path := filepath.Join(tmpDir, fmt.Sprintf("symlinks%d.json", i))
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for symlink pattern %q, got nil", pattern)
+		}
+	}
+}
+
+func TestDetectHeuristics(t *testing.T) {
+	loader := NewLoader()
+	profile := &types.Profile{
+		Name:             "Test",
+		Patterns:         []string{"node_modules"},
+		DetectHeuristics: []types.DetectHeuristic{{Path: "node_modules", Contains: []string{".package-lock.json"}, MinFiles: 3}},
+		Enabled:          true,
+	}
+
+	tmpDir := t.TempDir()
+	modules := filepath.Join(tmpDir, "node_modules")
+	if err := os.MkdirAll(filepath.Join(modules, "left-pad"), 0755); err != nil {
+		t.Fatalf("Failed to create node_modules: %v", err)
+	}
+	write := func(name string) {
+		if err := os.WriteFile(filepath.Join(modules, filepath.FromSlash(name)), []byte("{}"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// Too few files
+	write(".package-lock.json")
+	write("left-pad/package.json")
+	if pattern, _ := loader.DetectMatch(tmpDir, profile); pattern != "" {
+		t.Errorf("Expected no detection, got %s", pattern)
+	}
+
+	// Files in subdirectories count
+	write("left-pad/index.js")
+	pattern, path := loader.DetectMatch(tmpDir, profile)
+	if pattern != "node_modules with .package-lock.json and at least 3 files" || path != modules {
+		t.Errorf("Expected the heuristic to match node_modules, got %q (%s)", pattern, path)
+	}
+
+	// The entries are required whatever the number of files
+	if err := os.Remove(filepath.Join(modules, ".package-lock.json")); err != nil {
+		t.Fatalf("Failed to remove .package-lock.json: %v", err)
+	}
+	write("a.js")
+	if pattern, _ := loader.DetectMatch(tmpDir, profile); pattern != "" {
+		t.Errorf("Expected no detection without .package-lock.json, got %s", pattern)
+	}
+}
+
+func TestDetectCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	loader := NewLoader()
+	profile := &types.Profile{
+		Name:          "Test",
+		Patterns:      []string{"build"},
+		Detect:        []string{"Makefile"},
+		DetectCommand: []string{"sh", "-c", `test -f marker && test -z "$ROSIA_TEST_SECRET"`},
+		Enabled:       true,
+	}
+	t.Setenv("ROSIA_TEST_SECRET", "hunter2")
+
+	tmpDir := t.TempDir()
+	write := func(name string) {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(""), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// Without an opt-in, the command does not run and confirms nothing
+	write("marker")
+	write("Makefile")
+	if pattern, _ := loader.DetectMatch(tmpDir, profile); pattern != "" {
+		t.Errorf("Expected no detection before the command is allowed, got %s", pattern)
+	}
+	if unknown := loader.AllowCommands([]string{"test", "missing"}); len(unknown) != 2 {
+		t.Errorf("Expected both names unknown to a loader without profiles, got %v", unknown)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "Makefile")); err != nil {
+		t.Fatalf("Failed to remove Makefile: %v", err)
+	}
+
+	// The command only confirms directories the detect patterns match
+	if pattern, _ := loader.DetectMatch(tmpDir, profile); pattern != "" {
+		t.Errorf("Expected no detection without Makefile, got %s", pattern)
+	}
+
+	// It runs in the directory, without the environment of rosia
+	write("Makefile")
+	if pattern, _ := loader.DetectMatch(tmpDir, profile); pattern != "Makefile" {
+		t.Errorf("Expected detection by Makefile, got %q", pattern)
+	}
+
+	// Results are cached per directory
+	if err := os.Remove(filepath.Join(tmpDir, "marker")); err != nil {
+		t.Fatalf("Failed to remove marker: %v", err)
+	}
+	if pattern, _ := loader.DetectMatch(tmpDir, profile); pattern != "Makefile" {
+		t.Errorf("Expected the cached result, got %q", pattern)
+	}
+	other := NewLoader()
+	other.AllowCommands([]string{"Test"})
+	if pattern, _ := other.DetectMatch(tmpDir, profile); pattern != "" {
+		t.Errorf("Expected no detection once the command fails, got %s", pattern)
+	}
+}
+
+func TestLoadProfile_InvalidDetection(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	invalid := []string{
+		`"detect_heuristics": [{"path": "node_modules", "min_files": 1000}]`,
+		`"detect_heuristics": [{"path": "../node_modules", "contains": ["x"]}]`,
+		`"detect_heuristics": [{"path": "lib/*", "contains": ["x"]}]`,
+		`"detect_heuristics": [{"contains": ["[invalid"]}]`,
+		`"detect_heuristics": [{"contains": ["x"], "min_files": -1}]`,
+		`"detect": ["x"], "detect_command": [""]`,
+		`"detect": ["x"], "detect_command": ["./detect.sh"]`,
+	}
+	for i, fields := range invalid {
+		data := fmt.Sprintf(`{"name": "Test", "version": "1.0.0", "patterns": ["build"], %s}`, fields)
+		path := filepath.Join(tmpDir, fmt.Sprintf("detection%d.json", i))
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write profile: %v", err)
+		}
+		if _, err := loader.LoadProfile(path); err == nil {
+			t.Errorf("Expected error for %s, got nil", fields)
+		}
+	}
+
+	// A heuristic replaces the detect patterns
+	data := `{"name": "Test", "version": "1.0.0", "patterns": ["build"], "detect_heuristics": [{"contains": ["x"]}]}`
+	path := filepath.Join(tmpDir, "heuristic.json")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if _, err := loader.LoadProfile(path); err != nil {
+		t.Errorf("Expected a profile detected by heuristics only to load, got %v", err)
+	}
+}
+
+func TestEnableOnly(t *testing.T) {
+	loader := NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+
+	// Profiles are named by file or by name, ignoring case and punctuation
+	unknown := loader.EnableOnly([]string{"nodejs", "Rust", "dotnet", "cpp", "cobol"})
+	if len(unknown) != 1 || unknown[0] != "cobol" {
+		t.Errorf("Expected cobol to be unknown, got %v", unknown)
+	}
+
+	enabled := make(map[string]bool)
+	for _, profile := range loader.GetProfiles() {
+		if profile.Enabled {
+			enabled[profile.Name] = true
+		}
+	}
+	for _, name := range []string{"Node.js", "Rust", ".NET", "C/C++"} {
+		if !enabled[name] {
+			t.Errorf("Expected %s to stay enabled", name)
+		}
+	}
+	if len(enabled) != 4 {
+		t.Errorf("Expected only 4 enabled profiles, got %v", enabled)
+	}
+
+	// Detection skips the other profiles
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module test\n"), 0644); err != nil {
+		t.Fatalf("Failed to create go.mod: %v", err)
+	}
+	if profile, err := loader.MatchProfile(tmpDir); err != nil || profile != nil {
+		t.Errorf("Expected Go projects not to be detected, got %v (%v)", profile, err)
+	}
+
+	// An empty list keeps every profile
+	if unknown := loader.EnableOnly(nil); unknown != nil {
+		t.Errorf("Expected no unknown profile, got %v", unknown)
+	}
+	if profile, _ := loader.GetProfile("Node.js"); !profile.Enabled {
+		t.Error("Expected Node.js to stay enabled")
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(t.TempDir(), "profiles")
+	watcher := NewWatcher(dir, missing)
+
+	if watcher.Changed() {
+		t.Error("Expected no change right after creating the watcher")
+	}
+
+	// Only profile files count
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("todo"), 0644); err != nil {
+		t.Fatalf("Failed to write notes: %v", err)
+	}
+	if watcher.Changed() {
+		t.Error("Expected other files to be ignored")
+	}
+
+	path := filepath.Join(dir, "custom.json")
+	if err := os.WriteFile(path, []byte(`{"name": "Custom"}`), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if !watcher.Changed() {
+		t.Error("Expected an added profile to be a change")
+	}
+	if watcher.Changed() {
+		t.Error("Expected a change to be reported once")
+	}
+
+	// Edits are detected by size or modification time
+	if err := os.WriteFile(path, []byte(`{"name": "Custom", "enabled": true}`), 0644); err != nil {
+		t.Fatalf("Failed to edit profile: %v", err)
+	}
+	if !watcher.Changed() {
+		t.Error("Expected an edited profile to be a change")
+	}
+
+	// Directories created later are watched too
+	if err := os.MkdirAll(missing, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", missing, err)
+	}
+	if err := os.WriteFile(filepath.Join(missing, "other.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if !watcher.Changed() {
+		t.Error("Expected a profile in a new directory to be a change")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Failed to remove profile: %v", err)
+	}
+	if !watcher.Changed() {
+		t.Error("Expected a removed profile to be a change")
+	}
+}
+
+func TestAddProfiles(t *testing.T) {
+	loader := NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+
+	errs := loader.AddProfiles("plugin docker",
+		types.Profile{Name: "Docker Compose", Version: "1.0.0", Patterns: []string{".docker-cache"}, Detect: []string{"compose.yaml"}, Enabled: true},
+		types.Profile{Name: "Bun", Version: "1.0.0", Extends: "Node.js", Detect: []string{"bun.lockb"}, Enabled: true},
+		types.Profile{Name: "Node.js", Version: "2.0.0", Patterns: []string{"node_modules"}, Detect: []string{"package.json"}, Enabled: true},
+		types.Profile{Name: "Broken", Version: "1.0.0", Enabled: true},
+	)
+
+	// Loaded profiles are not replaced and invalid ones are skipped
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "Node.js of plugin docker is already loaded") {
+		t.Errorf("Unexpected error: %v", errs[0])
+	}
+	if node, err := loader.GetProfile("Node.js"); err != nil || node.Version == "2.0.0" {
+		t.Errorf("Expected the Node.js profile file to be kept, got %v, %v", node, err)
+	}
+
+	compose, err := loader.GetProfile("Docker Compose")
+	if err != nil {
+		t.Fatalf("Expected the Docker Compose profile to be added: %v", err)
+	}
+	if compose.ID != "dockercompose" {
+		t.Errorf("Expected ID dockercompose, got %s", compose.ID)
+	}
+
+	// Added profiles may extend loaded ones
+	bun, err := loader.GetProfile("Bun")
+	if err != nil {
+		t.Fatalf("Expected the Bun profile to be added: %v", err)
+	}
+	if !slices.Contains(bun.Patterns, "node_modules") {
+		t.Errorf("Expected Bun to inherit the patterns of Node.js, got %v", bun.Patterns)
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "compose.yaml"), []byte(""), 0644); err != nil {
+		t.Fatalf("Failed to create compose.yaml: %v", err)
+	}
+	profile, err := loader.MatchProfile(tmpDir)
+	if err != nil || profile == nil || profile.Name != "Docker Compose" {
+		t.Errorf("Expected Docker Compose to detect the directory, got %v, %v", profile, err)
+	}
+}
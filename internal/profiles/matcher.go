@@ -1,15 +1,42 @@
 package profiles
 
 import (
+	"cmp"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/raucheacho/rosia-cli/pkg/types"
 )
 
+// negationPrefix marks a profile pattern that excludes matches instead of
+// adding them
+const negationPrefix = "!"
+
+// recursivePrefix marks a detect pattern matched in subdirectories too
+const recursivePrefix = "**/"
+
+// maxDetectDepth is how many levels of subdirectories a "**/" detect pattern
+// searches. Detection runs for every scanned directory, so it must stay
+// shallow.
+const maxDetectDepth = 2
+
 // MatchProfile detects the technology type by checking detect patterns
 // Returns the first matching profile or nil if no match found
 func (l *Loader) MatchProfile(dirPath string) (*types.Profile, error) {
+	matches, err := l.MatchProfiles(dirPath)
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+	return matches[0], nil
+}
+
+// MatchProfiles returns every enabled profile detecting dirPath, by
+// decreasing priority, then in load order. A project may use several
+// technologies, e.g. a Rust crate built with Gradle, each with its own
+// targets.
+func (l *Loader) MatchProfiles(dirPath string) ([]*types.Profile, error) {
 	// Check cache first
 	l.cacheMutex.RLock()
 	if cached, exists := l.matchCache[dirPath]; exists {
@@ -29,6 +56,7 @@ func (l *Loader) MatchProfile(dirPath string) (*types.Profile, error) {
 	}
 
 	// Try to match against each profile
+	var matches []*types.Profile
 	for i := range l.profiles {
 		profile := &l.profiles[i]
 
@@ -37,60 +65,230 @@ func (l *Loader) MatchProfile(dirPath string) (*types.Profile, error) {
 			continue
 		}
 
-		// Check if any detect pattern matches
-		if l.matchesDetectPatterns(dirPath, profile.Detect) {
-			// Cache the result
-			l.cacheMutex.Lock()
-			l.matchCache[dirPath] = profile
-			l.cacheMutex.Unlock()
-
-			return profile, nil
+		// Check if any detect pattern or heuristic matches
+		if pattern, _ := l.DetectMatch(dirPath, profile); pattern != "" {
+			matches = append(matches, profile)
 		}
 	}
 
-	// No match found, cache nil result
+	// Profiles with a higher priority select shared entries first
+	slices.SortStableFunc(matches, func(a, b *types.Profile) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+
+	// Cache the result, even when nothing matched
 	l.cacheMutex.Lock()
-	l.matchCache[dirPath] = nil
+	l.matchCache[dirPath] = matches
 	l.cacheMutex.Unlock()
 
-	return nil, nil
+	return matches, nil
 }
 
-// matchesDetectPatterns checks if any detect pattern exists in the directory
-func (l *Loader) matchesDetectPatterns(dirPath string, detectPatterns []string) bool {
+// DetectMatch returns the first detect pattern of profile found in dirPath,
+// or the description of the first detect heuristic it matches, whether or not
+// the profile is enabled, along with the path it matched. Both are empty when
+// the directory is not detected as a project of profile, including when the
+// detect command of the profile fails in it or is not allowed to run.
+func (l *Loader) DetectMatch(dirPath string, profile *types.Profile) (pattern, path string) {
+	pattern, path = l.detectMatch(dirPath, profile.Detect, profile.DetectContent)
+	if pattern == "" {
+		pattern, path = heuristicMatch(dirPath, profile.DetectHeuristics)
+	}
+	if pattern == "" || !l.commandConfirms(dirPath, profile) {
+		return "", ""
+	}
+	return pattern, path
+}
+
+// detectMatch returns the first of detectPatterns found in dirPath and the
+// path it matched. A pattern with an expression in content only matches files
+// whose content matches it.
+func (l *Loader) detectMatch(dirPath string, detectPatterns []string, content map[string]string) (string, string) {
 	for _, pattern := range detectPatterns {
+		expr := content[pattern]
+
+		// Patterns such as "**/*.csproj" also match in subdirectories
+		if name, ok := strings.CutPrefix(filepath.ToSlash(pattern), recursivePrefix); ok {
+			if path := l.findNested(dirPath, name, maxDetectDepth); path != "" && l.contentMatches(path, expr) {
+				return pattern, path
+			}
+			continue
+		}
+
 		// Check if file/directory exists in the directory
 		targetPath := filepath.Join(dirPath, pattern)
-		if _, err := os.Stat(targetPath); err == nil {
-			return true
+		if _, err := os.Stat(targetPath); err == nil && l.contentMatches(targetPath, expr) {
+			return pattern, targetPath
 		}
 
 		// Also try glob matching for patterns with wildcards
 		if hasGlobChars(pattern) {
 			matches, err := filepath.Glob(filepath.Join(dirPath, pattern))
-			if err == nil && len(matches) > 0 {
-				return true
+			if err != nil {
+				continue
+			}
+			for _, match := range matches {
+				if l.contentMatches(match, expr) {
+					return pattern, match
+				}
 			}
 		}
 	}
 
+	return "", ""
+}
+
+// findNested returns the first entry named like the glob pattern in dir or,
+// up to depth levels down, in its subdirectories. Hidden directories and
+// directories that are targets of a loaded profile, such as node_modules,
+// are not searched.
+func (l *Loader) findNested(dir, pattern string, depth int) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	for _, entry := range entries {
+		if matched, err := filepath.Match(pattern, entry.Name()); err == nil && matched {
+			return filepath.Join(dir, entry.Name())
+		}
+	}
+	if depth == 0 {
+		return ""
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || l.isTargetName(entry.Name()) {
+			continue
+		}
+		if path := l.findNested(filepath.Join(dir, entry.Name()), pattern, depth-1); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// isTargetName reports whether name matches the patterns of a loaded profile
+func (l *Loader) isTargetName(name string) bool {
+	// The lock is released before matching, which takes it to compile regexes;
+	// reloads replace the profiles rather than change their patterns
+	l.cacheMutex.RLock()
+	profiles := l.profiles
+	l.cacheMutex.RUnlock()
+
+	for i := range profiles {
+		if l.MatchesPattern(name, &profiles[i]) {
+			return true
+		}
+	}
 	return false
 }
 
-// MatchesPattern checks if a file or directory name matches any of the profile's patterns
+// MatchesPattern checks if a file or directory name matches any of the
+// profile's glob or regex patterns and is not excluded by a negative pattern
+// (e.g. "!dist-keep")
 func (l *Loader) MatchesPattern(name string, profile *types.Profile) bool {
+	matches := false
 	for _, pattern := range profile.Patterns {
+		if strings.HasPrefix(pattern, negationPrefix) {
+			continue
+		}
+
 		matched, err := filepath.Match(pattern, name)
 		if err == nil && matched {
-			return true
+			matches = true
+			break
 		}
 
 		// Also check if the name contains the pattern (for paths like "node_modules")
 		if name == pattern {
-			return true
+			matches = true
+			break
 		}
 	}
+	if !matches {
+		for _, pattern := range profile.RegexPatterns {
+			if re, err := l.compileRegex(pattern); err == nil && re.MatchString(name) {
+				matches = true
+				break
+			}
+		}
+	}
+	if !matches {
+		return false
+	}
 
+	// Negative patterns naming the target itself exclude it entirely
+	for _, pattern := range profile.Patterns {
+		negated, ok := strings.CutPrefix(pattern, negationPrefix)
+		if !ok || strings.Contains(filepath.ToSlash(negated), "/") {
+			continue
+		}
+		if matched, err := filepath.Match(negated, name); err == nil && matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NestedTargets returns the directories inside the project directory dir
+// matched by the path patterns of profile, such as "var/cache", whose
+// requirements the project meets. Other patterns only match the entries of
+// dir, by name, and are checked with MatchesTarget.
+func (l *Loader) NestedTargets(dir string, profile *types.Profile) []string {
+	var targets []string
+	for _, pattern := range profile.Patterns {
+		if strings.HasPrefix(pattern, negationPrefix) || !strings.Contains(pattern, "/") || isGlobalPattern(pattern) {
+			continue
+		}
+		if !l.requirementsMet(dir, profile.Requires[pattern], profile) {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() && !slices.Contains(targets, match) {
+				targets = append(targets, match)
+			}
+		}
+	}
+	return targets
+}
+
+// ExcludedPaths returns the paths, relative to a target named name, that the
+// profile's negative patterns exclude from it. A pattern such as
+// "!node_modules/.cache/janitor" excludes ".cache/janitor" from every
+// "node_modules" target; the excluded paths are preserved when the target is
+// cleaned.
+func (l *Loader) ExcludedPaths(name string, profile *types.Profile) []string {
+	var excluded []string
+	for _, pattern := range profile.Patterns {
+		negated, ok := strings.CutPrefix(pattern, negationPrefix)
+		if !ok {
+			continue
+		}
+		first, rest, ok := strings.Cut(filepath.ToSlash(negated), "/")
+		if !ok || rest == "" {
+			continue
+		}
+		if matched, err := filepath.Match(first, name); err == nil && matched {
+			excluded = append(excluded, filepath.FromSlash(rest))
+		}
+	}
+	return excluded
+}
+
+// IsPermanent checks if a target name matches one of the profile's permanent
+// patterns, meaning it should be deleted directly instead of moved to trash
+func (l *Loader) IsPermanent(name string, profile *types.Profile) bool {
+	for _, pattern := range profile.Permanent {
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
 	return false
 }
 
@@ -115,5 +313,5 @@ func containsAny(s string, chars string) bool {
 func (l *Loader) ClearCache() {
 	l.cacheMutex.Lock()
 	defer l.cacheMutex.Unlock()
-	l.matchCache = make(map[string]*types.Profile)
+	l.matchCache = make(map[string][]*types.Profile)
 }
@@ -0,0 +1,78 @@
+package profiles
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// MatchesTarget checks if the entry name of the project directory dir is a
+// target of profile: it must match one of the profile's patterns, as with
+// MatchesPattern, and the project must contain one of the files that pattern
+// requires, if any. A profile detecting both Maven and Gradle projects can so
+// clean "target" in the former only.
+func (l *Loader) MatchesTarget(dir, name string, profile *types.Profile) bool {
+	if !l.MatchesPattern(name, profile) {
+		return false
+	}
+	if len(profile.Requires) == 0 {
+		return true
+	}
+
+	for _, pattern := range profile.Patterns {
+		if strings.HasPrefix(pattern, negationPrefix) {
+			continue
+		}
+		if matched, err := filepath.Match(pattern, name); (err == nil && matched) || name == pattern {
+			if l.requirementsMet(dir, profile.Requires[pattern], profile) {
+				return true
+			}
+		}
+	}
+	for _, pattern := range profile.RegexPatterns {
+		if re, err := l.compileRegex(pattern); err == nil && re.MatchString(name) {
+			if l.requirementsMet(dir, profile.Requires[pattern], profile) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requirementsMet reports whether dir contains one of the detect files
+// required, with the content profile expects of them, or whether nothing is
+// required
+func (l *Loader) requirementsMet(dir string, required []string, profile *types.Profile) bool {
+	if len(required) == 0 {
+		return true
+	}
+	pattern, _ := l.detectMatch(dir, required, profile.DetectContent)
+	return pattern != ""
+}
+
+// validateRequires checks that every requirement of profile names detect
+// files for one of its positive patterns
+func validateRequires(profile *types.Profile) []fieldError {
+	var errs []fieldError
+	for _, pattern := range slices.Sorted(maps.Keys(profile.Requires)) {
+		files := profile.Requires[pattern]
+		if len(files) == 0 {
+			errs = append(errs, fieldError{field: "requires", value: pattern, err: fmt.Errorf("requires of pattern '%s' must list detect files", pattern)})
+			continue
+		}
+		for _, file := range files {
+			if err := validateDetectPattern(file); err != nil {
+				errs = append(errs, fieldError{field: "requires", value: pattern, err: err})
+			}
+		}
+		// Patterns may be inherited, so only their own profile can be checked
+		if profile.Extends == "" && !hasPattern(profile, pattern) {
+			errs = append(errs, fieldError{field: "requires", value: pattern, err: fmt.Errorf("requires given for unknown pattern '%s'", pattern)})
+		}
+	}
+	return errs
+}
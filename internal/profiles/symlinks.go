@@ -0,0 +1,37 @@
+package profiles
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// MatchesSymlink checks if the name of a symlink in a project matches one of
+// the profile's symlink patterns, whose target directories are cleaned. Other
+// symlinks are never followed.
+func (l *Loader) MatchesSymlink(name string, profile *types.Profile) bool {
+	for _, pattern := range profile.Symlinks {
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSymlinks checks that the symlink patterns of profile are globs
+// matching names in the project
+func validateSymlinks(profile *types.Profile) []fieldError {
+	var errs []fieldError
+	for _, pattern := range profile.Symlinks {
+		if _, err := filepath.Match(pattern, "test"); err != nil || pattern == "" {
+			errs = append(errs, fieldError{field: "symlinks", value: pattern, err: fmt.Errorf("invalid symlink pattern '%s'", pattern)})
+			continue
+		}
+		if strings.Contains(filepath.ToSlash(pattern), "/") {
+			errs = append(errs, fieldError{field: "symlinks", value: pattern, err: fmt.Errorf("symlink pattern must be a name in the project: '%s'", pattern)})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,107 @@
+package profiles
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/sizecalc"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// Thresholds returns the min_size, in bytes, and the min_age of profile.
+// Zero means the threshold is not set.
+func (l *Loader) Thresholds(profile *types.Profile) (int64, time.Duration) {
+	var minSize int64
+	var minAge time.Duration
+	if profile.MinSize != "" {
+		minSize, _ = sizecalc.ParseSize(profile.MinSize)
+	}
+	if profile.MinAge != "" {
+		minAge, _ = ParseAge(profile.MinAge)
+	}
+	return minSize, minAge
+}
+
+// MeetsThresholds reports whether a sized target of profile is large or old
+// enough to be reported. When the profile sets both min_size and min_age,
+// reaching either one is enough.
+func (l *Loader) MeetsThresholds(target types.Target, profile *types.Profile, now time.Time) bool {
+	minSize, minAge := l.Thresholds(profile)
+	if minSize == 0 && minAge == 0 {
+		return true
+	}
+
+	if minSize > 0 && target.Size >= minSize {
+		return true
+	}
+	return minAge > 0 && now.Sub(target.LastAccessed) >= minAge
+}
+
+// StaleAfter returns the stale_after age of the first pattern of profile
+// matching name, or zero when that pattern has none. Targets of the pattern
+// last modified more recently are not reported.
+func (l *Loader) StaleAfter(name string, profile *types.Profile) time.Duration {
+	if len(profile.StaleAfter) == 0 {
+		return 0
+	}
+	age, _ := ParseAge(profile.StaleAfter[l.matchingPattern(name, profile)])
+	return age
+}
+
+// validateThresholds checks the min_size and min_age of profile
+func validateThresholds(profile *types.Profile) []fieldError {
+	var errs []fieldError
+	if profile.MinSize != "" {
+		if _, err := sizecalc.ParseSize(profile.MinSize); err != nil {
+			errs = append(errs, fieldError{field: "min_size", value: profile.MinSize, err: fmt.Errorf("invalid min_size: %w", err)})
+		}
+	}
+	if profile.MinAge != "" {
+		if _, err := ParseAge(profile.MinAge); err != nil {
+			errs = append(errs, fieldError{field: "min_age", value: profile.MinAge, err: fmt.Errorf("invalid min_age: %w", err)})
+		}
+	}
+	return errs
+}
+
+// validateStaleAfter checks that every stale_after age of profile is valid
+// and given for one of its patterns
+func validateStaleAfter(profile *types.Profile) []fieldError {
+	var errs []fieldError
+	for _, pattern := range slices.Sorted(maps.Keys(profile.StaleAfter)) {
+		if _, err := ParseAge(profile.StaleAfter[pattern]); err != nil {
+			errs = append(errs, fieldError{field: "stale_after", value: pattern, err: fmt.Errorf("invalid stale_after for pattern '%s': %w", pattern, err)})
+			continue
+		}
+		// Patterns may be inherited, so only their own profile can be checked
+		if profile.Extends == "" && !hasPattern(profile, pattern) {
+			errs = append(errs, fieldError{field: "stale_after", value: pattern, err: fmt.Errorf("stale_after given for unknown pattern '%s'", pattern)})
+		}
+	}
+	return errs
+}
+
+// ParseAge parses an age such as "7d", "2w" or "36h". Days and weeks are
+// accepted on top of the units understood by time.ParseDuration.
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(s, suffix); ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q: use a number followed by h, d or w, e.g. 7d", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,217 @@
+package profiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// fieldError is a validation error about a field of a profile, or about one
+// of its values
+type fieldError struct {
+	field string // JSON key of the field
+	value string // Offending value, or "" when it is the field as a whole
+	err   error
+}
+
+func (e fieldError) Error() string {
+	return e.err.Error()
+}
+
+func (e fieldError) Unwrap() error {
+	return e.err
+}
+
+// ValidationProblem is an issue found in a profile file by ValidatePath
+type ValidationProblem struct {
+	Path    string // Profile file
+	Line    int    // Line of the problem, or 0 when it is about the whole file
+	Column  int    // Column of the problem, or 0 when only the line is known
+	Message string
+}
+
+// String returns the problem as "path:line:column: message", leaving out the
+// parts of the position that are not known
+func (p ValidationProblem) String() string {
+	return fmt.Sprintf("%s: %s", position(p.Path, p.Line, p.Column), p.Message)
+}
+
+// ValidationReport lists the problems found by ValidatePath
+type ValidationReport struct {
+	Files    int // Profile files checked
+	Problems []ValidationProblem
+}
+
+// ValidatePath checks the profile file at path, or every JSON file of the
+// profile directory at path, and reports all their problems with the line
+// they are on. Unlike LoadAll, which skips a file on its first problem, every
+// problem of every file is reported, as well as names used by several
+// profiles of the directory. Inheritance is not resolved, since the parent
+// of a profile may be in another directory.
+func (l *Loader) ValidatePath(path string) (*ValidationReport, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, types.ErrPathNotFound{Path: path}
+		}
+		if os.IsPermission(err) {
+			return nil, types.ErrPermissionDenied{Path: path}
+		}
+		return nil, fmt.Errorf("failed to access %s: %w", path, err)
+	}
+
+	report := &ValidationReport{}
+	if !info.IsDir() {
+		_, _, problems := l.validateFile(path)
+		report.Files = 1
+		report.Problems = problems
+		return report, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if os.IsPermission(err) {
+			return nil, types.ErrPermissionDenied{Path: path}
+		}
+		return nil, fmt.Errorf("failed to read profiles directory %s: %w", path, err)
+	}
+
+	// File defining each name first
+	names := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		profilePath := filepath.Join(path, entry.Name())
+		profile, data, problems := l.validateFile(profilePath)
+		report.Files++
+		report.Problems = append(report.Problems, problems...)
+
+		if profile == nil || profile.Name == "" {
+			continue
+		}
+		if first, exists := names[profile.Name]; exists {
+			line, column := locate(data, "name", profile.Name)
+			report.Problems = append(report.Problems, ValidationProblem{
+				Path:    profilePath,
+				Line:    line,
+				Column:  column,
+				Message: fmt.Sprintf("duplicate profile name '%s', already used by %s", profile.Name, filepath.Base(first)),
+			})
+			continue
+		}
+		names[profile.Name] = profilePath
+	}
+
+	return report, nil
+}
+
+// validateFile parses and validates the profile file at path. It returns the
+// profile, or nil if it could not be parsed, the content of the file and the
+// problems found, sorted by line.
+func (l *Loader) validateFile(path string) (*types.Profile, []byte, []ValidationProblem) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, []ValidationProblem{{Path: path, Message: fmt.Sprintf("failed to read profile file: %v", err)}}
+	}
+
+	var profile types.Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		line, column := syntaxPosition(data, err)
+		return nil, data, []ValidationProblem{{Path: path, Line: line, Column: column, Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	var problems []ValidationProblem
+	for _, fieldErr := range l.validate(&profile) {
+		line, column := locate(data, fieldErr.field, fieldErr.value)
+		problems = append(problems, ValidationProblem{Path: path, Line: line, Column: column, Message: fieldErr.Error()})
+	}
+	// Problems about the whole file first
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+
+	return &profile, data, problems
+}
+
+// syntaxPosition returns the line and column at which decoding data failed
+// with err, or zeros when err does not tell
+func syntaxPosition(data []byte, err error) (int, int) {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return 0, 0
+	}
+
+	// The offset is just past the byte the error is about
+	if offset > 0 {
+		offset--
+	}
+	return lineColumn(data, int(offset))
+}
+
+// locate returns the line and column of value in the field of a JSON
+// profile, or of the field itself when value is empty. It returns zeros when
+// the field is not in data, e.g. when it is missing.
+func locate(data []byte, field, value string) (int, int) {
+	key := regexp.MustCompile(regexp.QuoteMeta(string(quote(field))) + `\s*:`)
+	loc := key.FindIndex(data)
+	if loc == nil {
+		return 0, 0
+	}
+
+	offset := loc[0]
+	if value != "" {
+		if i := bytes.Index(data[loc[1]:], quote(value)); i >= 0 {
+			offset = loc[1] + i
+		}
+	}
+	return lineColumn(data, offset)
+}
+
+// quote returns s as a JSON string, with HTML characters left as they are
+func quote(s string) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// lineColumn returns the 1-based line and column of the byte at offset in
+// data
+func lineColumn(data []byte, offset int) (int, int) {
+	offset = min(offset, len(data))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// position formats path with the line and column, if known, as
+// "path:line:column"
+func position(path string, line, column int) string {
+	switch {
+	case line == 0:
+		return path
+	case column == 0:
+		return fmt.Sprintf("%s:%d", path, line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", path, line, column)
+	}
+}
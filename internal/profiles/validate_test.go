@@ -0,0 +1,116 @@
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidatePath_File(t *testing.T) {
+	loader := NewLoader()
+	path := filepath.Join(t.TempDir(), "bad.json")
+	data := `{
+  "name": "Bad",
+  "patterns": [
+    "dist",
+    "[invalid"
+  ],
+  "detect": ["x"],
+  "keep": ["../outside"],
+  "enabled": true
+}
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	report, err := loader.ValidatePath(path)
+	if err != nil {
+		t.Fatalf("ValidatePath failed: %v", err)
+	}
+	if report.Files != 1 {
+		t.Errorf("Expected 1 file checked, got %d", report.Files)
+	}
+
+	// Every problem is reported, the missing version without a line
+	want := []struct {
+		line    int
+		message string
+	}{
+		{0, "profile version is required"},
+		{5, "invalid glob pattern '[invalid'"},
+		{8, "keep pattern must not leave the target"},
+	}
+	if len(report.Problems) != len(want) {
+		t.Fatalf("Expected %d problems, got %v", len(want), report.Problems)
+	}
+	for i, w := range want {
+		problem := report.Problems[i]
+		if problem.Line != w.line || !strings.Contains(problem.Message, w.message) {
+			t.Errorf("Expected problem %q on line %d, got %q on line %d", w.message, w.line, problem.Message, problem.Line)
+		}
+	}
+	if got := report.Problems[1].String(); got != path+":5:5: invalid glob pattern '[invalid': syntax error in pattern" {
+		t.Errorf("Unexpected problem string %q", got)
+	}
+}
+
+func TestValidatePath_Directory(t *testing.T) {
+	loader := NewLoader()
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"a.json":      `{"name": "Same", "version": "1.0.0", "patterns": ["dist"], "detect": ["x"]}`,
+		"b.json":      "{\n  \"name\": \"Same\",\n  \"version\": \"1.0.0\",\n  \"patterns\": [\"out\"],\n  \"detect\": [\"y\"]\n}",
+		"broken.json": "{\n  \"name\": \"Broken\",\n  \"version\": 1\n}",
+		"notes.txt":   "not a profile",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	report, err := loader.ValidatePath(tmpDir)
+	if err != nil {
+		t.Fatalf("ValidatePath failed: %v", err)
+	}
+	if report.Files != 3 {
+		t.Errorf("Expected 3 files checked, got %d", report.Files)
+	}
+	if len(report.Problems) != 2 {
+		t.Fatalf("Expected 2 problems, got %v", report.Problems)
+	}
+
+	duplicate := report.Problems[0]
+	if filepath.Base(duplicate.Path) != "b.json" || duplicate.Line != 2 || !strings.Contains(duplicate.Message, "duplicate profile name 'Same'") {
+		t.Errorf("Expected duplicate name on line 2 of b.json, got %v", duplicate)
+	}
+
+	invalid := report.Problems[1]
+	if filepath.Base(invalid.Path) != "broken.json" || invalid.Line != 3 || !strings.Contains(invalid.Message, "invalid JSON") {
+		t.Errorf("Expected invalid JSON on line 3 of broken.json, got %v", invalid)
+	}
+}
+
+func TestValidatePath_NotFound(t *testing.T) {
+	loader := NewLoader()
+	if _, err := loader.ValidatePath(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected error for missing path, got nil")
+	}
+}
+
+func TestLoadProfile_ErrorLine(t *testing.T) {
+	loader := NewLoader()
+	path := filepath.Join(t.TempDir(), "bad.json")
+	data := "{\n  \"name\": \"Bad\",\n  \"version\": \"1.0.0\",\n  \"patterns\": [\"[invalid\"],\n  \"detect\": [\"x\"]\n}"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	_, err := loader.LoadProfile(path)
+	if err == nil || !strings.Contains(err.Error(), path+":4:") {
+		t.Errorf("Expected error with line 4 of %s, got %v", path, err)
+	}
+}
@@ -0,0 +1,59 @@
+package profiles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Watcher detects changes to the profile files of directories, so that
+// long-running sessions such as the TUI can reload profiles as they are
+// edited. It polls the directories, which hold a few small files, rather
+// than subscribing to file system events.
+type Watcher struct {
+	dirs  []string
+	state string
+}
+
+// NewWatcher returns a Watcher of the JSON profiles in dirs, in their
+// current state
+func NewWatcher(dirs ...string) *Watcher {
+	w := &Watcher{dirs: dirs}
+	w.state = w.snapshot()
+	return w
+}
+
+// Changed reports whether a profile file was added, removed or modified since
+// the watcher was created or Changed last returned true
+func (w *Watcher) Changed() bool {
+	state := w.snapshot()
+	if state == w.state {
+		return false
+	}
+	w.state = state
+	return true
+}
+
+// snapshot describes the name, size and modification time of every JSON
+// file of the watched directories. Missing directories describe as empty.
+func (w *Watcher) snapshot() string {
+	var b strings.Builder
+	for _, dir := range w.dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&b, "%s\x00%d\x00%d\n", filepath.Join(dir, entry.Name()), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
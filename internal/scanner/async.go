@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -9,7 +10,10 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+	"github.com/raucheacho/rosia-cli/internal/plugins"
 	"github.com/raucheacho/rosia-cli/pkg/types"
 )
 
@@ -23,6 +27,10 @@ func (s *Scanner) ScanAsync(ctx context.Context, paths []string, opts ScanOption
 		defer close(targetChan)
 		defer close(errorChan)
 
+		if s.pluginRegistry != nil {
+			plugins.NotifyScanStart(ctx, s.pluginRegistry, paths)
+		}
+
 		// Determine concurrency level
 		concurrency := opts.Concurrency
 		if concurrency <= 0 {
@@ -48,6 +56,33 @@ func (s *Scanner) ScanAsync(ctx context.Context, paths []string, opts ScanOption
 		// Close jobs channel and wait for workers to finish
 		close(pool.jobs)
 		pool.wg.Wait()
+
+		// Plugins scan once, whatever the paths
+		if s.pluginRegistry != nil {
+			pluginTargets, _ := s.scanPlugins(ctx, opts)
+			pluginTargets = dropProtected(pluginTargets, pool.protected)
+			for _, target := range filterCategories(pluginTargets, opts) {
+				if _, sent := pool.sent.LoadOrStore(target.Path, true); sent {
+					continue
+				}
+				plugins.NotifyTargetFound(ctx, s.pluginRegistry, &target)
+				select {
+				case targetChan <- target:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+
+		// Record scan event in telemetry, like Scan
+		if s.telemetryStore != nil && ctx.Err() == nil {
+			sent := 0
+			pool.sent.Range(func(any, any) bool {
+				sent++
+				return true
+			})
+			s.recordScanEvent(paths, sent)
+		}
 	}()
 
 	return targetChan, errorChan
@@ -55,20 +90,23 @@ func (s *Scanner) ScanAsync(ctx context.Context, paths []string, opts ScanOption
 
 // workerPool manages concurrent scanning operations
 type workerPool struct {
-	workers int
-	jobs    chan string
-	scanner *Scanner
-	opts    ScanOptions
-	wg      sync.WaitGroup
+	workers   int
+	jobs      chan string
+	scanner   *Scanner
+	opts      ScanOptions
+	wg        sync.WaitGroup
+	sent      sync.Map           // Paths of the targets sent, since workers may all find the same global targets
+	protected *fsutils.Protected // Paths never sent as or in targets
 }
 
 // newWorkerPool creates a new worker pool
 func newWorkerPool(workers int, scanner *Scanner, opts ScanOptions) *workerPool {
 	return &workerPool{
-		workers: workers,
-		jobs:    make(chan string, workers*2),
-		scanner: scanner,
-		opts:    opts,
+		workers:   workers,
+		jobs:      make(chan string, workers*2),
+		scanner:   scanner,
+		opts:      opts,
+		protected: fsutils.NewProtected(opts.ProtectedPaths...),
 	}
 }
 
@@ -102,8 +140,20 @@ func (p *workerPool) worker(ctx context.Context, targetChan chan<- types.Target,
 			}
 		}
 
+		// Drop protected targets, those of excluded categories and those
+		// below the thresholds of their profile
+		targets = dropProtected(targets, p.protected)
+		targets = filterCategories(targets, p.opts)
+		targets = p.scanner.applyThresholds(p.scanner.sizeForThresholds(ctx, targets), time.Now())
+
 		// Send targets to channel
 		for _, target := range targets {
+			if _, sent := p.sent.LoadOrStore(target.Path, true); sent {
+				continue
+			}
+			if p.scanner.pluginRegistry != nil {
+				plugins.NotifyTargetFound(ctx, p.scanner.pluginRegistry, &target)
+			}
 			select {
 			case targetChan <- target:
 			case <-ctx.Done():
@@ -118,20 +168,25 @@ func (s *Scanner) scanPathAsync(ctx context.Context, rootPath string, opts ScanO
 	targets := make([]types.Target, 0)
 	rootDepth := strings.Count(rootPath, string(os.PathSeparator))
 
+	// Projects may opt out of cleaning or change their rules in .rosia.json
+	projects := newProjects(s.profileLoader, opts.IgnorePaths)
+	if projects.enter(rootPath) {
+		return targets, nil
+	}
+	protected := fsutils.NewProtected(opts.ProtectedPaths...)
+	targets = append(targets, s.nestedTargets(rootPath, projects, opts, rootDepth)...)
+
 	// First, try to match the root directory itself
-	profile, err := s.profileLoader.MatchProfile(rootPath)
-	if err == nil && profile != nil {
-		baseName := filepath.Base(rootPath)
-		if s.profileLoader.MatchesPattern(baseName, profile) {
-			target, err := s.createTarget(rootPath, profile)
-			if err == nil {
-				targets = append(targets, target)
-			}
+	if profile, _ := s.targetProfile(rootPath, filepath.Base(rootPath), projects); profile != nil {
+		target, err := s.createTarget(rootPath, filepath.Base(rootPath), profile)
+		if err == nil {
+			targets = append(targets, target)
 		}
 	}
 
 	// Walk the directory tree
-	err = filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+	var linked []types.Target
+	err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
 		// Check context cancellation
 		select {
 		case <-ctx.Done():
@@ -170,49 +225,60 @@ func (s *Scanner) scanPathAsync(ctx context.Context, rootPath string, opts ScanO
 		}
 
 		// Check if path should be ignored
-		if s.shouldIgnore(path, opts.IgnorePaths) {
+		if s.shouldIgnore(path, projects.ignorePaths) {
 			if d.IsDir() {
 				return fs.SkipDir
 			}
 			return nil
 		}
 
-		// Only process directories for profile matching
-		if !d.IsDir() {
+		// Nothing inside a protected directory may be cleaned
+		if d.IsDir() && protected.Inside(path) {
+			return fs.SkipDir
+		}
+
+		// Symlinks are never followed, but profiles may clean their target
+		if d.Type()&fs.ModeSymlink != 0 {
+			if target, ok := s.linkedTarget(path, d.Name(), projects); ok {
+				linked = append(linked, target)
+			}
 			return nil
 		}
 
-		// Get the parent directory for profile matching
-		parentDir := filepath.Dir(path)
-		profile, err := s.profileLoader.MatchProfile(parentDir)
-		if err != nil {
+		// Only process directories for profile matching
+		if !d.IsDir() {
 			return nil
 		}
+		if projects.selected[path] || projects.enter(path) {
+			return fs.SkipDir
+		}
+		targets = append(targets, s.nestedTargets(path, projects, opts, rootDepth)...)
 
-		// If no profile matched the parent, try matching the current directory
-		if profile == nil {
-			profile, err = s.profileLoader.MatchProfile(path)
-			if err != nil {
-				return nil
-			}
+		// Match against the profiles of the parent directory, or if none
+		// detects it, of the current directory
+		profile, detected := s.targetProfile(filepath.Dir(path), d.Name(), projects)
+		if !detected {
+			profile, _ = s.targetProfile(path, d.Name(), projects)
 		}
 
-		// If we have a profile, check if this directory matches any patterns
 		if profile != nil {
-			baseName := d.Name()
-			if s.profileLoader.MatchesPattern(baseName, profile) {
-				target, err := s.createTarget(path, profile)
-				if err == nil {
-					targets = append(targets, target)
-					// Skip descending into matched directories
-					return fs.SkipDir
-				}
+			target, err := s.createTarget(path, d.Name(), profile)
+			if err == nil {
+				targets = append(targets, target)
+				// Skip descending into matched directories
+				return fs.SkipDir
+			}
+			if errors.Is(err, errNotStale) {
+				// Still in use, but not to be searched for targets either
+				return fs.SkipDir
 			}
 		}
 
 		return nil
 	})
 
+	targets = append(targets, outermost(linked)...)
+
 	if err != nil && err != context.Canceled {
 		return targets, fmt.Errorf("error walking directory: %w", err)
 	}
@@ -0,0 +1,27 @@
+package scanner
+
+import (
+	"slices"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// filterCategories keeps the targets whose category is selected by the
+// Categories and ExcludeCategories of opts
+func filterCategories(targets []types.Target, opts ScanOptions) []types.Target {
+	if len(opts.Categories) == 0 && len(opts.ExcludeCategories) == 0 {
+		return targets
+	}
+
+	kept := targets[:0]
+	for _, target := range targets {
+		if len(opts.Categories) > 0 && !slices.Contains(opts.Categories, target.Type) {
+			continue
+		}
+		if slices.Contains(opts.ExcludeCategories, target.Type) {
+			continue
+		}
+		kept = append(kept, target)
+	}
+	return kept
+}
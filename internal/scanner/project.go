@@ -0,0 +1,87 @@
+package scanner
+
+import (
+	"slices"
+
+	"github.com/raucheacho/rosia-cli/internal/config"
+	"github.com/raucheacho/rosia-cli/internal/profiles"
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// projects applies the .rosia.json of the projects met while walking a
+// scanned path. It is not safe for concurrent use; every walk has its own.
+type projects struct {
+	loader      *profiles.Loader
+	patterns    map[string][]string          // Patterns of the .rosia.json of each project root
+	merged      map[[2]string]*types.Profile // Profiles, by project root and name, with the patterns of the project added
+	ignorePaths []string                     // Ignore paths of the scan and of the projects met so far
+	selected    map[string]bool              // Targets selected by path and global patterns, not to be walked
+	globals     map[string]bool              // Profiles whose global targets were added
+}
+
+// newProjects returns the projects of a walk ignoring ignorePaths
+func newProjects(loader *profiles.Loader, ignorePaths []string) *projects {
+	return &projects{
+		loader:      loader,
+		patterns:    make(map[string][]string),
+		merged:      make(map[[2]string]*types.Profile),
+		ignorePaths: slices.Clone(ignorePaths),
+		selected:    make(map[string]bool),
+		globals:     make(map[string]bool),
+	}
+}
+
+// enter reads the .rosia.json of dir if it is a project root and reports
+// whether the walk must skip dir, because the project opts out of cleaning
+func (p *projects) enter(dir string) bool {
+	profile, err := p.loader.MatchProfile(dir)
+	if err != nil || profile == nil {
+		return false
+	}
+
+	project, err := config.LoadProject(dir)
+	if err != nil {
+		logger.Warn("Ignoring project config: %v", err)
+		return false
+	}
+	if project == nil {
+		return false
+	}
+
+	if project.Skip {
+		logger.Debug("Skipping %s: opted out of cleaning in %s", dir, config.ProjectFileName)
+		return true
+	}
+
+	p.ignorePaths = append(p.ignorePaths, project.AbsIgnorePaths(dir)...)
+
+	if len(project.Patterns) > 0 {
+		p.patterns[dir] = project.Patterns
+	}
+
+	return false
+}
+
+// profile returns profile, one of those detecting the project root dir, with
+// the patterns of the project's .rosia.json added
+func (p *projects) profile(dir string, profile *types.Profile) *types.Profile {
+	patterns, ok := p.patterns[dir]
+	if !ok {
+		return profile
+	}
+
+	key := [2]string{dir, profile.Name}
+	if merged, ok := p.merged[key]; ok {
+		return merged
+	}
+
+	merged := *profile
+	merged.Patterns = slices.Concat(profile.Patterns, patterns)
+	if err := p.loader.ValidateProfile(&merged); err != nil {
+		logger.Warn("Ignoring patterns of %s in %s for profile %s: %v", config.ProjectFileName, dir, profile.Name, err)
+		merged = *profile
+	}
+	p.merged[key] = &merged
+	return &merged
+}
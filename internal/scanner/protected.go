@@ -0,0 +1,24 @@
+package scanner
+
+import (
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// dropProtected removes the targets that protected refuses to clean, from
+// profiles and plugins alike. Virtual targets are not paths; their plugin
+// cleans them.
+func dropProtected(targets []types.Target, protected *fsutils.Protected) []types.Target {
+	kept := targets[:0]
+	for _, target := range targets {
+		if !target.Virtual {
+			if err := protected.Check(target.Path); err != nil {
+				logger.Warn("Skipping %s: %v", target.Path, err)
+				continue
+			}
+		}
+		kept = append(kept, target)
+	}
+	return kept
+}
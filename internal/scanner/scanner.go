@@ -17,13 +17,16 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
 	"github.com/raucheacho/rosia-cli/internal/plugins"
 	"github.com/raucheacho/rosia-cli/internal/profiles"
 	"github.com/raucheacho/rosia-cli/internal/sizecalc"
@@ -49,11 +52,16 @@ type Scanner struct {
 // Options control depth limits, hidden file inclusion, dry-run mode,
 // concurrency settings, and path exclusions.
 type ScanOptions struct {
-	MaxDepth      int
-	IncludeHidden bool
-	IgnorePaths   []string
-	DryRun        bool
-	Concurrency   int
+	MaxDepth          int
+	IncludeHidden     bool
+	IgnorePaths       []string
+	DryRun            bool
+	Concurrency       int
+	Categories        []string      // Only report targets of these categories (all when empty)
+	ExcludeCategories []string      // Never report targets of these categories
+	PluginTimeout     time.Duration // How long each plugin may take to scan (0 = plugins.DefaultTimeout)
+	ExcludePlugins    []string      // Plugins not to scan with, by name
+	ProtectedPaths    []string      // Paths never reported as or in targets, in addition to fsutils.NewProtected's
 }
 
 // NewScanner creates a new scanner with the given profile loader
@@ -90,6 +98,10 @@ func (s *Scanner) SetPluginRegistry(registry plugins.PluginRegistry) {
 func (s *Scanner) Scan(ctx context.Context, paths []string, opts ScanOptions) ([]types.Target, error) {
 	targets := make([]types.Target, 0)
 
+	if s.pluginRegistry != nil {
+		plugins.NotifyScanStart(ctx, s.pluginRegistry, paths)
+	}
+
 	for _, path := range paths {
 		// Check context cancellation
 		select {
@@ -111,9 +123,12 @@ func (s *Scanner) Scan(ctx context.Context, paths []string, opts ScanOptions) ([
 		targets = append(targets, pathTargets...)
 	}
 
+	// Global targets are met again in every path with a project using them
+	targets = uniqueTargets(targets)
+
 	// Call plugin.Scan() for each registered plugin
 	if s.pluginRegistry != nil {
-		pluginTargets, err := s.scanPlugins(ctx)
+		pluginTargets, err := s.scanPlugins(ctx, opts)
 		if err != nil {
 			logger.Warn("Plugin scan failed: %v", err)
 			// Continue with core targets even if plugins fail
@@ -123,6 +138,12 @@ func (s *Scanner) Scan(ctx context.Context, paths []string, opts ScanOptions) ([
 		}
 	}
 
+	// Protected paths are never targets, whoever reports them
+	targets = dropProtected(targets, fsutils.NewProtected(opts.ProtectedPaths...))
+
+	// Excluded categories need not be sized
+	targets = filterCategories(targets, opts)
+
 	// Calculate sizes for all targets
 	if len(targets) > 0 {
 		logger.Debug("Calculating sizes for %d targets", len(targets))
@@ -132,9 +153,19 @@ func (s *Scanner) Scan(ctx context.Context, paths []string, opts ScanOptions) ([
 			return targets, fmt.Errorf("failed to calculate sizes: %w", err)
 		}
 
+		// Profile thresholds depend on the sizes
+		targets = s.applyThresholds(targets, time.Now())
+
+		// Plugins observe and may augment the final targets
+		if s.pluginRegistry != nil {
+			for i := range targets {
+				plugins.NotifyTargetFound(ctx, s.pluginRegistry, &targets[i])
+			}
+		}
+
 		// Record scan event in telemetry
 		if s.telemetryStore != nil {
-			s.recordScanEvent(len(targets))
+			s.recordScanEvent(paths, len(targets))
 		}
 
 		return targets, nil
@@ -144,30 +175,29 @@ func (s *Scanner) Scan(ctx context.Context, paths []string, opts ScanOptions) ([
 
 	// Record scan event even if no targets found
 	if s.telemetryStore != nil {
-		s.recordScanEvent(0)
+		s.recordScanEvent(paths, 0)
 	}
 
 	return targets, nil
 }
 
-// recordScanEvent records a scan event in telemetry
-func (s *Scanner) recordScanEvent(targetsFound int) {
-	event := telemetry.TelemetryEvent{
-		Type:      "scan",
-		Timestamp: time.Now(),
-		Data: map[string]interface{}{
-			"timestamp":     time.Now(),
-			"targets_found": targetsFound,
-		},
-	}
+// recordScanEvent records a scan event in telemetry, with the free space of
+// the filesystems of paths
+func (s *Scanner) recordScanEvent(paths []string, targetsFound int) {
+	event := telemetry.NewEvent(&telemetry.ScanEvent{
+		TargetsFound: targetsFound,
+		FreeSpace:    telemetry.MeasureFreeSpace(paths),
+	})
 
 	if err := s.telemetryStore.Record(event); err != nil {
 		logger.Warn("Failed to record scan telemetry: %v", err)
 	}
 }
 
-// scanPlugins calls Scan() on all registered plugins and merges results
-func (s *Scanner) scanPlugins(ctx context.Context) ([]types.Target, error) {
+// scanPlugins calls Scan() on all registered plugins but the excluded ones
+// and merges results, setting the Source of the targets to the plugin. A
+// plugin failing, panicking or timing out is skipped.
+func (s *Scanner) scanPlugins(ctx context.Context, opts ScanOptions) ([]types.Target, error) {
 	allPlugins := s.pluginRegistry.List()
 	if len(allPlugins) == 0 {
 		return []types.Target{}, nil
@@ -177,16 +207,24 @@ func (s *Scanner) scanPlugins(ctx context.Context) ([]types.Target, error) {
 	allTargets := make([]types.Target, 0)
 
 	for _, plugin := range allPlugins {
+		if slices.Contains(opts.ExcludePlugins, plugin.Name()) {
+			logger.Debug("Skipping excluded plugin: %s", plugin.Name())
+			continue
+		}
 		logger.Debug("Calling plugin.Scan() for: %s", plugin.Name())
 
-		targets, err := plugin.Scan(ctx)
+		start := time.Now()
+		targets, err := plugins.Scan(ctx, plugin, opts.PluginTimeout)
 		if err != nil {
-			logger.Warn("Plugin %s scan failed: %v", plugin.Name(), err)
+			logger.Warn("Plugin %s scan failed after %v: %v", plugin.Name(), time.Since(start).Round(time.Millisecond), err)
 			// Continue with other plugins
 			continue
 		}
 
-		logger.Debug("Plugin %s found %d targets", plugin.Name(), len(targets))
+		logger.Debug("Plugin %s found %d targets in %v", plugin.Name(), len(targets), time.Since(start).Round(time.Millisecond))
+		for i := range targets {
+			targets[i].Source = plugin.Name()
+		}
 		allTargets = append(allTargets, targets...)
 	}
 
@@ -198,21 +236,25 @@ func (s *Scanner) scanPath(ctx context.Context, rootPath string, opts ScanOption
 	targets := make([]types.Target, 0)
 	rootDepth := strings.Count(rootPath, string(os.PathSeparator))
 
+	// Projects may opt out of cleaning or change their rules in .rosia.json
+	projects := newProjects(s.profileLoader, opts.IgnorePaths)
+	if projects.enter(rootPath) {
+		return targets, nil
+	}
+	protected := fsutils.NewProtected(opts.ProtectedPaths...)
+	targets = append(targets, s.nestedTargets(rootPath, projects, opts, rootDepth)...)
+
 	// First, try to match the root directory itself
-	profile, err := s.profileLoader.MatchProfile(rootPath)
-	if err == nil && profile != nil {
-		// Check if root path matches any patterns
-		baseName := filepath.Base(rootPath)
-		if s.profileLoader.MatchesPattern(baseName, profile) {
-			target, err := s.createTarget(rootPath, profile)
-			if err == nil {
-				targets = append(targets, target)
-			}
+	if profile, _ := s.targetProfile(rootPath, filepath.Base(rootPath), projects); profile != nil {
+		target, err := s.createTarget(rootPath, filepath.Base(rootPath), profile)
+		if err == nil {
+			targets = append(targets, target)
 		}
 	}
 
 	// Walk the directory tree
-	err = filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+	var linked []types.Target
+	err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
 		// Check context cancellation
 		select {
 		case <-ctx.Done():
@@ -251,50 +293,60 @@ func (s *Scanner) scanPath(ctx context.Context, rootPath string, opts ScanOption
 		}
 
 		// Check if path should be ignored
-		if s.shouldIgnore(path, opts.IgnorePaths) {
+		if s.shouldIgnore(path, projects.ignorePaths) {
 			if d.IsDir() {
 				return fs.SkipDir
 			}
 			return nil
 		}
 
-		// Only process directories for profile matching
-		if !d.IsDir() {
+		// Nothing inside a protected directory may be cleaned
+		if d.IsDir() && protected.Inside(path) {
+			return fs.SkipDir
+		}
+
+		// Symlinks are never followed, but profiles may clean their target
+		if d.Type()&fs.ModeSymlink != 0 {
+			if target, ok := s.linkedTarget(path, d.Name(), projects); ok {
+				linked = append(linked, target)
+			}
 			return nil
 		}
 
-		// Get the parent directory for profile matching
-		parentDir := filepath.Dir(path)
-		profile, err := s.profileLoader.MatchProfile(parentDir)
-		if err != nil {
-			// Continue on error
+		// Only process directories for profile matching
+		if !d.IsDir() {
 			return nil
 		}
+		if projects.selected[path] || projects.enter(path) {
+			return fs.SkipDir
+		}
+		targets = append(targets, s.nestedTargets(path, projects, opts, rootDepth)...)
 
-		// If no profile matched the parent, try matching the current directory
-		if profile == nil {
-			profile, err = s.profileLoader.MatchProfile(path)
-			if err != nil {
-				return nil
-			}
+		// Match against the profiles of the parent directory, or if none
+		// detects it, of the current directory
+		profile, detected := s.targetProfile(filepath.Dir(path), d.Name(), projects)
+		if !detected {
+			profile, _ = s.targetProfile(path, d.Name(), projects)
 		}
 
-		// If we have a profile, check if this directory matches any patterns
 		if profile != nil {
-			baseName := d.Name()
-			if s.profileLoader.MatchesPattern(baseName, profile) {
-				target, err := s.createTarget(path, profile)
-				if err == nil {
-					targets = append(targets, target)
-					// Skip descending into matched directories
-					return fs.SkipDir
-				}
+			target, err := s.createTarget(path, d.Name(), profile)
+			if err == nil {
+				targets = append(targets, target)
+				// Skip descending into matched directories
+				return fs.SkipDir
+			}
+			if errors.Is(err, errNotStale) {
+				// Still in use, but not to be searched for targets either
+				return fs.SkipDir
 			}
 		}
 
 		return nil
 	})
 
+	targets = append(targets, outermost(linked)...)
+
 	if err != nil && err != context.Canceled {
 		return targets, fmt.Errorf("error walking directory: %w", err)
 	}
@@ -302,8 +354,93 @@ func (s *Scanner) scanPath(ctx context.Context, rootPath string, opts ScanOption
 	return targets, nil
 }
 
-// createTarget creates a Target from a path and profile
-func (s *Scanner) createTarget(path string, profile *types.Profile) (types.Target, error) {
+// targetProfile returns the first profile detecting the project directory
+// dir whose patterns select its entry name, with the project's .rosia.json
+// applied, and reports whether any profile detects dir
+func (s *Scanner) targetProfile(dir, name string, projects *projects) (*types.Profile, bool) {
+	detected, err := s.profileLoader.MatchProfiles(dir)
+	if err != nil || len(detected) == 0 {
+		return nil, false
+	}
+
+	for _, profile := range detected {
+		profile = projects.profile(dir, profile)
+		if s.profileLoader.MatchesTarget(dir, name, profile) {
+			return profile, true
+		}
+	}
+	return nil, true
+}
+
+// nestedTargets returns the targets matched in the project directory dir by
+// path patterns, such as "var/cache", of the profiles detecting it. They are
+// marked as selected so the walk does not enter them.
+func (s *Scanner) nestedTargets(dir string, projects *projects, opts ScanOptions, rootDepth int) []types.Target {
+	detected, err := s.profileLoader.MatchProfiles(dir)
+	if err != nil {
+		return nil
+	}
+
+	var targets []types.Target
+	for _, profile := range detected {
+		profile = projects.profile(dir, profile)
+		for _, path := range s.profileLoader.NestedTargets(dir, profile) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil || projects.selected[path] || s.shouldIgnore(path, projects.ignorePaths) {
+				continue
+			}
+			if opts.MaxDepth > 0 && strings.Count(path, string(os.PathSeparator))-rootDepth > opts.MaxDepth {
+				continue
+			}
+			if !opts.IncludeHidden && slices.ContainsFunc(strings.Split(rel, string(os.PathSeparator)), isHidden) {
+				continue
+			}
+
+			target, err := s.createTarget(path, filepath.ToSlash(rel), profile)
+			if err != nil && !errors.Is(err, errNotStale) {
+				continue
+			}
+			projects.selected[path] = true
+			if err == nil {
+				targets = append(targets, target)
+			}
+		}
+		targets = append(targets, s.globalTargets(profile, projects)...)
+	}
+	return targets
+}
+
+// globalTargets returns the targets of the global patterns of profile, such
+// as "~/Library/Developer/Xcode/DerivedData", the first time a project of the
+// profile is met. They are outside the scanned paths, so the depth limit and
+// hidden rules do not apply to them.
+func (s *Scanner) globalTargets(profile *types.Profile, projects *projects) []types.Target {
+	if projects.globals[profile.Name] {
+		return nil
+	}
+	projects.globals[profile.Name] = true
+
+	var targets []types.Target
+	for _, global := range s.profileLoader.GlobalTargets(profile) {
+		if projects.selected[global.Path] || s.shouldIgnore(global.Path, projects.ignorePaths) {
+			continue
+		}
+		target, err := s.createTarget(global.Path, global.Name, profile)
+		if err != nil && !errors.Is(err, errNotStale) {
+			continue
+		}
+		projects.selected[global.Path] = true
+		if err == nil {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// createTarget creates a Target from a path and profile. name is what the
+// profile's patterns matched: the base name of path, or its path inside the
+// project for path patterns.
+func (s *Scanner) createTarget(path, name string, profile *types.Profile) (types.Target, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -315,18 +452,53 @@ func (s *Scanner) createTarget(path string, profile *types.Profile) (types.Targe
 		return types.Target{}, fmt.Errorf("failed to stat path %s: %w", path, err)
 	}
 
+	// Paths excluded by negative patterns are kept when the target is cleaned
+	keep := profile.Keep
+	if excluded := s.profileLoader.ExcludedPaths(name, profile); len(excluded) > 0 {
+		keep = append(append([]string{}, profile.Keep...), excluded...)
+	}
+
+	// Targets of uncategorized patterns are classified by their profile
+	category := s.profileLoader.Category(name, profile)
+	if category == "" {
+		category = profile.Name
+	}
+
+	lastAccessed := getLastAccessTime(info)
+	if staleAfter := s.profileLoader.StaleAfter(name, profile); staleAfter > 0 && time.Since(newestModTime(path, info)) < staleAfter {
+		logger.Debug("Skipping %s: modified within the stale_after of profile %s", path, profile.Name)
+		return types.Target{}, errNotStale
+	}
+
 	target := types.Target{
 		Path:         path,
-		Type:         profile.Name,
+		Type:         category,
 		ProfileName:  profile.Name,
 		IsDirectory:  info.IsDir(),
-		LastAccessed: getLastAccessTime(info),
+		LastAccessed: lastAccessed,
 		Size:         0, // Will be calculated later by SizeCalc
+		Keep:         keep,
+		Permanent:    s.profileLoader.IsPermanent(name, profile),
+		RebuildHint:  profile.RebuildHint,
 	}
 
 	return target, nil
 }
 
+// uniqueTargets returns targets without those of a path already listed
+func uniqueTargets(targets []types.Target) []types.Target {
+	seen := make(map[string]bool, len(targets))
+	unique := targets[:0]
+	for _, target := range targets {
+		if seen[target.Path] {
+			continue
+		}
+		seen[target.Path] = true
+		unique = append(unique, target)
+	}
+	return unique
+}
+
 // shouldIgnore checks if a path should be ignored based on ignore patterns
 func (s *Scanner) shouldIgnore(path string, ignorePaths []string) bool {
 	for _, ignorePath := range ignorePaths {
@@ -356,3 +528,23 @@ func getLastAccessTime(info os.FileInfo) time.Time {
 	// Use ModTime as a fallback since access time is platform-specific
 	return info.ModTime()
 }
+
+// newestModTime returns the modification time of path, info, or that of the
+// newest of its immediate children when it is a directory. Builds rewrite the
+// files of a directory without changing the directory's own time.
+func newestModTime(path string, info os.FileInfo) time.Time {
+	newest := info.ModTime()
+	if !info.IsDir() {
+		return newest
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return newest
+	}
+	for _, entry := range entries {
+		if childInfo, err := entry.Info(); err == nil && childInfo.ModTime().After(newest) {
+			newest = childInfo.ModTime()
+		}
+	}
+	return newest
+}
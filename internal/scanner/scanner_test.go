@@ -4,9 +4,14 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/raucheacho/rosia-cli/internal/plugins"
 	"github.com/raucheacho/rosia-cli/internal/profiles"
+	"github.com/raucheacho/rosia-cli/pkg/types"
 )
 
 func TestScan(t *testing.T) {
@@ -722,3 +727,900 @@ func BenchmarkScanner_ConcurrentVsSequential(b *testing.B) {
 		}
 	})
 }
+
+func TestScanNegativePatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	profilesDir := filepath.Join(tmpDir, "profiles")
+	projectDir := filepath.Join(tmpDir, "project")
+
+	profile := `{"name": "Test", "version": "1.0.0", "patterns": ["node_modules", "dist*", "!dist-keep", "!node_modules/.cache/janitor"], "detect": ["package.json"], "keep": ["CACHEDIR.TAG"], "enabled": true}`
+	if err := os.MkdirAll(profilesDir, 0755); err != nil {
+		t.Fatalf("Failed to create profiles dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(profilesDir, "test.json"), []byte(profile), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	for _, dir := range []string{"node_modules", "dist", "dist-keep"} {
+		if err := os.MkdirAll(filepath.Join(projectDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to create package.json: %v", err)
+	}
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(profilesDir); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+
+	targets, err := NewScanner(loader).Scan(context.Background(), []string{projectDir}, ScanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	found := make(map[string]types.Target)
+	for _, target := range targets {
+		found[filepath.Base(target.Path)] = target
+	}
+	if _, ok := found["dist-keep"]; ok {
+		t.Error("Expected dist-keep to be excluded")
+	}
+	if _, ok := found["dist"]; !ok {
+		t.Error("Expected dist to be found")
+	}
+
+	nodeModules, ok := found["node_modules"]
+	if !ok {
+		t.Fatal("Expected node_modules to be found")
+	}
+	want := []string{"CACHEDIR.TAG", filepath.Join(".cache", "janitor")}
+	if len(nodeModules.Keep) != 2 || nodeModules.Keep[0] != want[0] || nodeModules.Keep[1] != want[1] {
+		t.Errorf("Expected keep %v, got %v", want, nodeModules.Keep)
+	}
+	if dist := found["dist"]; len(dist.Keep) != 1 {
+		t.Errorf("Expected only the profile keep pattern for dist, got %v", dist.Keep)
+	}
+}
+
+func TestScanThresholds(t *testing.T) {
+	tmpDir := t.TempDir()
+	profilesDir := filepath.Join(tmpDir, "profiles")
+
+	profile := `{"name": "Test", "version": "1.0.0", "patterns": ["target"], "detect": ["Cargo.toml"], "min_size": "1KB", "enabled": true}`
+	if err := os.MkdirAll(profilesDir, 0755); err != nil {
+		t.Fatalf("Failed to create profiles dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(profilesDir, "test.json"), []byte(profile), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	// One project with a large target, one with a small one
+	sizes := map[string]int{"large": 4096, "small": 10}
+	for name, size := range sizes {
+		project := filepath.Join(tmpDir, "projects", name)
+		if err := os.MkdirAll(filepath.Join(project, "target"), 0755); err != nil {
+			t.Fatalf("Failed to create target: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(project, "Cargo.toml"), []byte(""), 0644); err != nil {
+			t.Fatalf("Failed to create Cargo.toml: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(project, "target", "data"), make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create data: %v", err)
+		}
+	}
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(profilesDir); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	scanner := NewScanner(loader)
+	opts := ScanOptions{MaxDepth: 10}
+
+	targets, err := scanner.Scan(context.Background(), []string{filepath.Join(tmpDir, "projects")}, opts)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(targets) != 1 || filepath.Base(filepath.Dir(targets[0].Path)) != "large" {
+		t.Errorf("Expected only the large target, got %v", targets)
+	}
+
+	// The async scan applies the same thresholds
+	targetChan, errorChan := scanner.ScanAsync(context.Background(), []string{filepath.Join(tmpDir, "projects")}, opts)
+	var asyncTargets []types.Target
+	for target := range targetChan {
+		asyncTargets = append(asyncTargets, target)
+	}
+	for err := range errorChan {
+		t.Fatalf("ScanAsync failed: %v", err)
+	}
+	if len(asyncTargets) != 1 || asyncTargets[0].Size < 1024 {
+		t.Errorf("Expected only the large target, got %v", asyncTargets)
+	}
+}
+
+func TestScanCategories(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"node_modules", ".cache", "dist"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to create package.json: %v", err)
+	}
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	scanner := NewScanner(loader)
+
+	scan := func(opts ScanOptions) map[string]string {
+		opts.MaxDepth = 10
+		opts.IncludeHidden = true
+		targets, err := scanner.Scan(context.Background(), []string{tmpDir}, opts)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		found := make(map[string]string)
+		for _, target := range targets {
+			found[filepath.Base(target.Path)] = target.Type
+		}
+		return found
+	}
+
+	all := scan(ScanOptions{})
+	expected := map[string]string{"node_modules": types.CategoryDependencies, ".cache": types.CategoryCache, "dist": types.CategoryBuild}
+	for name, category := range expected {
+		if all[name] != category {
+			t.Errorf("Expected %s to be in category %q, got %q", name, category, all[name])
+		}
+	}
+
+	caches := scan(ScanOptions{Categories: []string{types.CategoryCache}})
+	if len(caches) != 1 || caches[".cache"] == "" {
+		t.Errorf("Expected only .cache, got %v", caches)
+	}
+
+	noDeps := scan(ScanOptions{ExcludeCategories: []string{types.CategoryDependencies}})
+	if _, ok := noDeps["node_modules"]; ok || len(noDeps) != 2 {
+		t.Errorf("Expected everything but node_modules, got %v", noDeps)
+	}
+}
+
+func TestScanProjectConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	projects := map[string]string{
+		"custom":  `{"patterns": ["generated", "!dist"], "ignore_paths": ["packages"]}`,
+		"skipped": `{"skip": true}`,
+		"plain":   "",
+	}
+	for project, config := range projects {
+		for _, dir := range []string{"node_modules", "dist", "generated", "packages/app/node_modules"} {
+			if err := os.MkdirAll(filepath.Join(tmpDir, project, dir), 0755); err != nil {
+				t.Fatalf("Failed to create %s: %v", dir, err)
+			}
+		}
+		for _, dir := range []string{"", "packages/app"} {
+			if err := os.WriteFile(filepath.Join(tmpDir, project, dir, "package.json"), []byte("{}"), 0644); err != nil {
+				t.Fatalf("Failed to create package.json: %v", err)
+			}
+		}
+		if config != "" {
+			if err := os.WriteFile(filepath.Join(tmpDir, project, ".rosia.json"), []byte(config), 0644); err != nil {
+				t.Fatalf("Failed to create .rosia.json: %v", err)
+			}
+		}
+	}
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	scanner := NewScanner(loader)
+
+	targets, err := scanner.Scan(context.Background(), []string{tmpDir}, ScanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	found := make(map[string]bool)
+	for _, target := range targets {
+		rel, _ := filepath.Rel(tmpDir, target.Path)
+		found[filepath.ToSlash(rel)] = true
+	}
+	expected := map[string]bool{
+		"custom/node_modules":             true,
+		"custom/generated":                true,
+		"plain/node_modules":              true,
+		"plain/dist":                      true,
+		"plain/packages/app/node_modules": true,
+	}
+	if len(found) != len(expected) {
+		t.Errorf("Expected targets %v, got %v", expected, found)
+	}
+	for path := range expected {
+		if !found[path] {
+			t.Errorf("Expected target %s, got %v", path, found)
+		}
+	}
+}
+
+// scanFixture writes files under a temporary directory, names ending in a
+// slash as directories, scans it with the built-in profiles and returns the
+// targets by their slash-separated path in it
+func scanFixture(t *testing.T, files map[string]string) map[string]types.Target {
+	t.Helper()
+	tmpDir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatalf("Failed to create %s: %v", name, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	targets, err := NewScanner(loader).Scan(context.Background(), []string{tmpDir}, ScanOptions{MaxDepth: 10, IncludeHidden: true})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	found := make(map[string]types.Target)
+	for _, target := range targets {
+		rel, _ := filepath.Rel(tmpDir, target.Path)
+		found[filepath.ToSlash(rel)] = target
+	}
+	return found
+}
+
+func TestScanBuiltinProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected map[string]string // Profile selecting each target
+	}{
+		{
+			// Maven cleans target, Gradle build and .gradle, IntelliJ out
+			// only with its production layout, and the target of a Rust
+			// crate built with Gradle stays Rust's
+			name: "java",
+			files: map[string]string{
+				"maven/pom.xml":                "",
+				"maven/target/":                "",
+				"maven/src/":                   "",
+				"maven/out/production/app/":    "",
+				"gradle/out/report.html":       "",
+				"gradle/build.gradle":          "",
+				"gradle/build/":                "",
+				"gradle/.gradle/":              "",
+				"gradle/src/":                  "",
+				"rust-gradle/Cargo.toml":       "",
+				"rust-gradle/build.gradle.kts": "",
+				"rust-gradle/target/":          "",
+				"rust-gradle/build/":           "",
+				"rust-gradle/.gradle/":         "",
+			},
+			expected: map[string]string{
+				"maven/target":        "Java",
+				"maven/out":           "Java",
+				"gradle/build":        "Java",
+				"gradle/.gradle":      "Java",
+				"rust-gradle/build":   "Java",
+				"rust-gradle/.gradle": "Java",
+				"rust-gradle/target":  "Rust",
+			},
+		},
+		{
+			// A solution without a .sln at its root, its project two levels
+			// down: bin, obj and TestResults need a project file next to
+			// them, packages a .sln
+			name: "dotnet",
+			files: map[string]string{
+				"TestResults/":         "",
+				"packages/":            "",
+				"bin/":                 "",
+				"src/App/App.csproj":   "",
+				"src/App/bin/":         "",
+				"src/App/obj/":         "",
+				"src/App/TestResults/": "",
+			},
+			expected: map[string]string{
+				"src/App/bin":         ".NET",
+				"src/App/obj":         ".NET",
+				"src/App/TestResults": ".NET",
+			},
+		},
+		{
+			// A Gradle project without Android plugins is left to the Java
+			// profile
+			name: "android",
+			files: map[string]string{
+				"android/settings.gradle.kts":   "pluginManagement {\n  repositories {\n    google {\n      content { includeGroupByRegex(\"com\\\\.android.*\") }\n    }\n  }\n}\ninclude(\":app\")\n",
+				"android/app/build.gradle.kts":  "plugins { id(\"com.android.application\") }\n",
+				"android/app/build/outputs/apk": "",
+				"android/captures/heap.hprof":   "",
+				"gradle/settings.gradle":        "include 'lib'\n",
+				"gradle/build/libs/lib.jar":     "",
+			},
+			expected: map[string]string{
+				"android/app/build": "Android",
+				"android/captures":  "Android",
+				"gradle/build":      "Java",
+			},
+		},
+		{
+			// A Library directory outside Unity projects is left alone
+			name: "unity",
+			files: map[string]string{
+				"game/Assets/":   "",
+				"game/Library/":  "",
+				"game/Temp/":     "",
+				"game/Obj/":      "",
+				"game/Logs/":     "",
+				"game/Packages/": "",
+				"game/ProjectSettings/ProjectVersion.txt": "m_EditorVersion: 2022.3.10f1\n",
+				"docs/Library/": "",
+			},
+			expected: map[string]string{
+				"game/Library": "Unity",
+				"game/Temp":    "Unity",
+				"game/Obj":     "Unity",
+				"game/Logs":    "Unity",
+			},
+		},
+		{
+			name: "haskell",
+			files: map[string]string{
+				"stack.yaml":                         "packages:\n- core\n",
+				"core/core.cabal":                    "name: core\n",
+				".stack-work/install/x":              "",
+				"core/.stack-work/dist/x":            "",
+				"core/dist-newstyle/cache/plan.json": "",
+			},
+			expected: map[string]string{
+				".stack-work":        "Haskell",
+				"core/.stack-work":   "Haskell",
+				"core/dist-newstyle": "Haskell",
+			},
+		},
+		{
+			name: "cpp",
+			files: map[string]string{
+				// CMake project built out of source, in CLion and in place
+				"engine/CMakeLists.txt":                  "project(engine CXX)\n",
+				"engine/build/CMakeCache.txt":            "",
+				"engine/cmake-build-debug/engine":        "",
+				"engine/CMakeFiles/engine.dir/main.o":    "",
+				"meson/meson.build":                      "project('tool', 'c')\n",
+				"meson/build/meson-private/coredata.dat": "",
+				"make/Makefile":                          "CC = gcc\n\nbuild/%.o: %.c\n\t$(CC) -c -o $@ $<\n",
+				"make/build/tool.o":                      "",
+				"autotools/configure.ac":                 "",
+				"autotools/Makefile":                     "CC = gcc\n",
+				"autotools/build/Makefile":               "",
+				// A build directory holding nothing generated is left alone,
+				// even in a project compiling C
+				"manual/Makefile":        "CC = gcc\n",
+				"manual/build/notes.txt": "",
+				// A build directory CMake did not configure is left alone
+				"docs/CMakeLists.txt":   "project(docs NONE)\n",
+				"docs/build/index.html": "",
+				// So is the build of other stacks using make as a task runner
+				"web/Makefile":       "build:\n\tnpm run build\n",
+				"web/build/index.js": "",
+			},
+			expected: map[string]string{
+				"engine/build":             "C/C++",
+				"engine/cmake-build-debug": "C/C++",
+				"engine/CMakeFiles":        "C/C++",
+				"meson/build":              "C/C++",
+				"make/build":               "C/C++",
+				"autotools/build":          "C/C++",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := scanFixture(t, tt.files)
+			if len(found) != len(tt.expected) {
+				t.Errorf("Expected targets %v, got %v", tt.expected, found)
+			}
+			for path, profile := range tt.expected {
+				if found[path].ProfileName != profile {
+					t.Errorf("Expected %s selected by %s, got %q", path, profile, found[path].ProfileName)
+				}
+			}
+		})
+	}
+}
+
+func TestScanTerraformModule(t *testing.T) {
+	found := scanFixture(t, map[string]string{
+		"main.tf":               "terraform {}\n",
+		".terraform/providers/": "",
+	})
+	target, ok := found[".terraform"]
+	if len(found) != 1 || !ok {
+		t.Fatalf("Expected .terraform, got %v", found)
+	}
+	// The selected workspace survives cleaning
+	if !slices.Contains(target.Keep, "environment") {
+		t.Errorf("Expected environment to be kept, got %v", target.Keep)
+	}
+}
+
+func TestScanPathPatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"vendor", "var/cache/dev", "var/log", ".phpunit.cache"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "composer.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to create composer.json: %v", err)
+	}
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	scanner := NewScanner(loader)
+
+	targets, err := scanner.Scan(context.Background(), []string{tmpDir}, ScanOptions{MaxDepth: 10, IncludeHidden: true})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	found := make(map[string]string)
+	for _, target := range targets {
+		rel, _ := filepath.Rel(tmpDir, target.Path)
+		found[filepath.ToSlash(rel)] = target.Type
+	}
+	expected := map[string]string{
+		"vendor":         types.CategoryDependencies,
+		"var/cache":      types.CategoryCache,
+		".phpunit.cache": types.CategoryCache,
+	}
+	if len(found) != len(expected) {
+		t.Errorf("Expected targets %v, got %v", expected, found)
+	}
+	for path, category := range expected {
+		if found[path] != category {
+			t.Errorf("Expected %s in category %q, got %q", path, category, found[path])
+		}
+	}
+}
+
+func TestScanElixirStaleEnvs(t *testing.T) {
+	tmpDir := t.TempDir()
+	project := filepath.Join(tmpDir, "app")
+	for _, dir := range []string{"_build/dev", "_build/prod", "_build/test", "deps/jason"} {
+		if err := os.MkdirAll(filepath.Join(project, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(project, "mix.exs"), []byte(""), 0644); err != nil {
+		t.Fatalf("Failed to create mix.exs: %v", err)
+	}
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	if err := os.Chtimes(filepath.Join(project, "_build", "prod"), old, old); err != nil {
+		t.Fatalf("Failed to age _build/prod: %v", err)
+	}
+	// Rebuilding rewrites the files of an environment, not the directory
+	if err := os.WriteFile(filepath.Join(project, "_build", "test", "app.beam"), []byte(""), 0644); err != nil {
+		t.Fatalf("Failed to create app.beam: %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(project, "_build", "test"), old, old); err != nil {
+		t.Fatalf("Failed to age _build/test: %v", err)
+	}
+
+	// A user profile overriding the built-in one to only report stale environments
+	userDir := filepath.Join(tmpDir, "user-profiles")
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		t.Fatalf("Failed to create user profiles dir: %v", err)
+	}
+	override := `{"name": "Elixir", "version": "1.0.0", "patterns": ["_build/*", "deps"], "detect": ["mix.exs"], "stale_after": {"_build/*": "30d"}, "enabled": true}`
+	if err := os.WriteFile(filepath.Join(userDir, "elixir.json"), []byte(override), 0644); err != nil {
+		t.Fatalf("Failed to write user profile: %v", err)
+	}
+
+	scan := func(dirs ...string) map[string]bool {
+		loader := profiles.NewLoader()
+		if _, err := loader.LoadDirs(dirs...); err != nil {
+			t.Fatalf("Failed to load profiles: %v", err)
+		}
+		targets, err := NewScanner(loader).Scan(context.Background(), []string{project}, ScanOptions{MaxDepth: 10})
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		found := make(map[string]bool)
+		for _, target := range targets {
+			rel, _ := filepath.Rel(project, target.Path)
+			found[filepath.ToSlash(rel)] = true
+		}
+		return found
+	}
+
+	// Every environment is a target of its own
+	builtin := filepath.Join("..", "..", "profiles")
+	found := scan(builtin)
+	for _, path := range []string{"_build/dev", "_build/prod", "_build/test", "deps"} {
+		if !found[path] {
+			t.Errorf("Expected %s to be a target, got %v", path, found)
+		}
+	}
+
+	// With stale_after, recently built environments are left alone
+	found = scan(builtin, userDir)
+	if found["_build/dev"] {
+		t.Error("Expected recent _build/dev not to be a target")
+	}
+	if found["_build/test"] {
+		t.Error("Expected _build/test, with a recent file, not to be a target")
+	}
+	if !found["_build/prod"] || !found["deps"] {
+		t.Errorf("Expected stale _build/prod and deps to be targets, got %v", found)
+	}
+}
+
+func TestScanGlobalTargets(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	derivedData := filepath.Join(homeDir, "Library", "Developer", "Xcode", "DerivedData")
+	if err := os.MkdirAll(derivedData, 0755); err != nil {
+		t.Fatalf("Failed to create DerivedData: %v", err)
+	}
+
+	// Two scanned paths with a Swift package each, and one without
+	var paths []string
+	for _, name := range []string{"a", "b", "c"} {
+		dir := filepath.Join(t.TempDir(), name)
+		if err := os.MkdirAll(filepath.Join(dir, "Pods"), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		if name != "c" {
+			if err := os.WriteFile(filepath.Join(dir, "Package.swift"), []byte(""), 0644); err != nil {
+				t.Fatalf("Failed to create Package.swift: %v", err)
+			}
+		}
+		paths = append(paths, dir)
+	}
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	scanner := NewScanner(loader)
+
+	targets, err := scanner.Scan(context.Background(), paths, ScanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	// Pods needs a Podfile, so the global DerivedData is the only target
+	if len(targets) != 1 || targets[0].Path != derivedData || targets[0].Type != types.CategoryBuild {
+		t.Fatalf("Expected %s once, got %v", derivedData, targets)
+	}
+
+	// Without a Swift project, the global location is not reported
+	targets, err = scanner.Scan(context.Background(), paths[2:], ScanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(targets) != 0 {
+		t.Errorf("Expected no targets, got %v", targets)
+	}
+}
+
+func TestScanBazelSymlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	workspace := filepath.Join(tmpDir, "repo")
+	execroot := filepath.Join(tmpDir, "output_base", "execroot", "repo")
+	bin := filepath.Join(execroot, "bazel-out", "k8-fastbuild", "bin")
+	for _, dir := range []string{workspace, bin} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "MODULE.bazel"), []byte(""), 0644); err != nil {
+		t.Fatalf("Failed to create MODULE.bazel: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "app"), make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("Failed to create output: %v", err)
+	}
+
+	links := map[string]string{
+		"bazel-repo": execroot,
+		"bazel-out":  filepath.Join(execroot, "bazel-out"),
+		"bazel-bin":  bin,
+		"bazel-self": workspace, // Never followed to the workspace itself
+		"bazel-up":   tmpDir,    // Nor to a directory containing it
+		"bazel-gone": filepath.Join(tmpDir, "missing"),
+		"out":        bin, // Not a Bazel link
+	}
+	for name, dest := range links {
+		if err := os.Symlink(dest, filepath.Join(workspace, name)); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	scanner := NewScanner(loader)
+
+	targets, err := scanner.Scan(context.Background(), []string{workspace}, ScanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	// The links all point inside the execroot, which is cleaned once
+	resolved, _ := filepath.EvalSymlinks(execroot)
+	if len(targets) != 1 {
+		t.Fatalf("Expected 1 target, got %v", targets)
+	}
+	target := targets[0]
+	if target.Path != resolved || target.Link != filepath.Join(workspace, "bazel-repo") {
+		t.Errorf("Expected %s through bazel-repo, got %s through %s", resolved, target.Path, target.Link)
+	}
+	if target.Size < 4096 {
+		t.Errorf("Expected the output tree to be sized, got %d bytes", target.Size)
+	}
+	if target.ProfileName != "Bazel" || target.Type != types.CategoryBuild {
+		t.Errorf("Expected a Bazel build target, got %s %s", target.ProfileName, target.Type)
+	}
+}
+
+// fakePlugin reports fixed targets
+type fakePlugin struct {
+	targets []types.Target
+}
+
+func (p *fakePlugin) Name() string        { return "fake" }
+func (p *fakePlugin) Version() string     { return "1.0.0" }
+func (p *fakePlugin) Description() string { return "Fake plugin" }
+
+func (p *fakePlugin) Scan(ctx context.Context) ([]types.Target, error) {
+	return p.targets, nil
+}
+
+func (p *fakePlugin) Clean(ctx context.Context, targets []types.Target) error {
+	return nil
+}
+
+func TestScanAsyncPlugins(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	registry := plugins.NewRegistry()
+	plugin := &fakePlugin{targets: []types.Target{
+		{Path: filepath.Join(tmpDir, "images"), Size: 42, Type: "cache", ProfileName: "fake"},
+	}}
+	if err := registry.Register(plugin); err != nil {
+		t.Fatalf("Failed to register plugin: %v", err)
+	}
+
+	scanner := NewScanner(profiles.NewLoader())
+	scanner.SetPluginRegistry(registry)
+
+	// Plugins scan once, however many paths are scanned
+	targetChan, errorChan := scanner.ScanAsync(context.Background(), []string{tmpDir, tmpDir}, ScanOptions{MaxDepth: 10})
+	var targets []types.Target
+	for target := range targetChan {
+		targets = append(targets, target)
+	}
+	for err := range errorChan {
+		t.Fatalf("ScanAsync failed: %v", err)
+	}
+
+	if len(targets) != 1 || targets[0].ProfileName != "fake" || targets[0].Size != 42 {
+		t.Errorf("Expected the target of the plugin, got %v", targets)
+	}
+}
+
+// keepPlugin preserves the .cache entries of the targets of its profile
+type keepPlugin struct {
+	fakePlugin
+	profile string
+}
+
+func (p *keepPlugin) OnTargetFound(ctx context.Context, target *types.Target) error {
+	if target.ProfileName == p.profile {
+		target.Keep = append(target.Keep, ".cache")
+	}
+	return nil
+}
+
+func TestScanTargetFoundHook(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "node_modules"), 0755); err != nil {
+		t.Fatalf("Failed to create node_modules: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to create package.json: %v", err)
+	}
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	registry := plugins.NewRegistry()
+	if err := registry.Register(&keepPlugin{profile: "Node.js"}); err != nil {
+		t.Fatalf("Failed to register plugin: %v", err)
+	}
+	scanner := NewScanner(loader)
+	scanner.SetPluginRegistry(registry)
+
+	targets, err := scanner.Scan(context.Background(), []string{tmpDir}, ScanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(targets) != 1 || !slices.Equal(targets[0].Keep, []string{".cache"}) {
+		t.Errorf("Expected the hook to add a keep pattern, got %v", targets)
+	}
+
+	targetChan, errorChan := scanner.ScanAsync(context.Background(), []string{tmpDir}, ScanOptions{MaxDepth: 10})
+	var asyncTargets []types.Target
+	for target := range targetChan {
+		asyncTargets = append(asyncTargets, target)
+	}
+	for err := range errorChan {
+		t.Fatalf("ScanAsync failed: %v", err)
+	}
+	if len(asyncTargets) != 1 || !slices.Equal(asyncTargets[0].Keep, []string{".cache"}) {
+		t.Errorf("Expected the hook to add a keep pattern, got %v", asyncTargets)
+	}
+}
+
+// hungPlugin never returns from Scan, ignoring cancellation
+type hungPlugin struct {
+	fakePlugin
+	release chan struct{}
+}
+
+func (p *hungPlugin) Name() string { return "hung" }
+
+func (p *hungPlugin) Scan(ctx context.Context) ([]types.Target, error) {
+	<-p.release
+	return nil, nil
+}
+
+func TestScanPluginTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "images"), 0755); err != nil {
+		t.Fatalf("Failed to create images: %v", err)
+	}
+
+	hung := &hungPlugin{release: make(chan struct{})}
+	defer close(hung.release)
+	registry := plugins.NewRegistry()
+	for _, plugin := range []plugins.Plugin{hung, &fakePlugin{targets: []types.Target{
+		{Path: filepath.Join(tmpDir, "images"), Size: 42, Type: "cache", ProfileName: "fake"},
+	}}} {
+		if err := registry.Register(plugin); err != nil {
+			t.Fatalf("Failed to register plugin: %v", err)
+		}
+	}
+	scanner := NewScanner(profiles.NewLoader())
+	scanner.SetPluginRegistry(registry)
+
+	// The hung plugin is skipped, the other plugins still contribute
+	start := time.Now()
+	targets, err := scanner.Scan(context.Background(), []string{tmpDir}, ScanOptions{MaxDepth: 10, PluginTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Scan took %v with a plugin timeout of 50ms", elapsed)
+	}
+	if len(targets) != 1 || targets[0].ProfileName != "fake" {
+		t.Errorf("Expected the target of the other plugin, got %v", targets)
+	}
+}
+
+func TestScanProtectedPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"project", "protected/project"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir, "node_modules"), 0755); err != nil {
+			t.Fatalf("Failed to create node_modules: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, dir, "package.json"), []byte("{}"), 0644); err != nil {
+			t.Fatalf("Failed to create package.json: %v", err)
+		}
+	}
+	protected := filepath.Join(tmpDir, "protected")
+
+	loader := profiles.NewLoader()
+	if _, err := loader.LoadAll(filepath.Join("..", "..", "profiles")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+
+	// Plugins cannot report protected paths either
+	registry := plugins.NewRegistry()
+	plugin := &fakePlugin{targets: []types.Target{
+		{Path: filepath.Join(protected, "cache"), Type: "cache", ProfileName: "Fake"},
+	}}
+	if err := registry.Register(plugin); err != nil {
+		t.Fatalf("Failed to register plugin: %v", err)
+	}
+	scanner := NewScanner(loader)
+	scanner.SetPluginRegistry(registry)
+	opts := ScanOptions{MaxDepth: 10, ProtectedPaths: []string{protected}}
+
+	expected := filepath.Join(tmpDir, "project", "node_modules")
+	targets, err := scanner.Scan(context.Background(), []string{tmpDir}, opts)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(targets) != 1 || targets[0].Path != expected {
+		t.Errorf("Expected only %s, got %v", expected, targets)
+	}
+
+	targetChan, errorChan := scanner.ScanAsync(context.Background(), []string{tmpDir}, opts)
+	var found []string
+	for target := range targetChan {
+		found = append(found, target.Path)
+	}
+	for err := range errorChan {
+		t.Fatalf("ScanAsync failed: %v", err)
+	}
+	if len(found) != 1 || found[0] != expected {
+		t.Errorf("Expected only %s from ScanAsync, got %v", expected, found)
+	}
+}
+
+func TestScanPluginSource(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "images"), 0755); err != nil {
+		t.Fatalf("Failed to create images: %v", err)
+	}
+
+	registry := plugins.NewRegistry()
+	plugin := &fakePlugin{targets: []types.Target{
+		{Path: filepath.Join(tmpDir, "images"), Size: 42, Type: "cache", ProfileName: "Docker", Source: "other"},
+	}}
+	if err := registry.Register(plugin); err != nil {
+		t.Fatalf("Failed to register plugin: %v", err)
+	}
+	scanner := NewScanner(profiles.NewLoader())
+	scanner.SetPluginRegistry(registry)
+
+	// Targets are attributed to the plugin reporting them
+	targets, err := scanner.Scan(context.Background(), []string{tmpDir}, ScanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(targets) != 1 || targets[0].Source != "fake" {
+		t.Errorf("Expected the target to come from plugin fake, got %v", targets)
+	}
+
+	// Excluded plugins are not scanned with
+	targets, err = scanner.Scan(context.Background(), []string{tmpDir}, ScanOptions{MaxDepth: 10, ExcludePlugins: []string{"fake"}})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(targets) != 0 {
+		t.Errorf("Expected no targets of the excluded plugin, got %v", targets)
+	}
+
+	targetChan, errorChan := scanner.ScanAsync(context.Background(), []string{tmpDir}, ScanOptions{MaxDepth: 10, ExcludePlugins: []string{"fake"}})
+	for target := range targetChan {
+		t.Errorf("Expected no targets of the excluded plugin, got %v", target)
+	}
+	for err := range errorChan {
+		t.Fatalf("ScanAsync failed: %v", err)
+	}
+}
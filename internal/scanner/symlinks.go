@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// linkedTarget returns the target for the symlink at path, named name, when
+// a profile detecting its project cleans the directory it points to, such as
+// Bazel's bazel-out. The target is the resolved directory, so that it is
+// sized and cleaned instead of the link.
+func (s *Scanner) linkedTarget(path, name string, projects *projects) (types.Target, bool) {
+	dir := filepath.Dir(path)
+	detected, err := s.profileLoader.MatchProfiles(dir)
+	if err != nil {
+		return types.Target{}, false
+	}
+
+	for _, profile := range detected {
+		profile = projects.profile(dir, profile)
+		if !s.profileLoader.MatchesSymlink(name, profile) {
+			continue
+		}
+
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			logger.Debug("Skipping %s: cannot resolve symlink: %v", path, err)
+			return types.Target{}, false
+		}
+		if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+			return types.Target{}, false
+		}
+		// A link to the project or to one of its parents would clean it all
+		if resolved == dir || strings.HasPrefix(dir, resolved+string(os.PathSeparator)) {
+			logger.Warn("Skipping %s: it points to %s, which contains the project", path, resolved)
+			return types.Target{}, false
+		}
+		if s.shouldIgnore(resolved, projects.ignorePaths) {
+			return types.Target{}, false
+		}
+
+		target, err := s.createTarget(resolved, name, profile)
+		if err != nil {
+			return types.Target{}, false
+		}
+		target.Link = path
+		return target, true
+	}
+	return types.Target{}, false
+}
+
+// outermost returns the targets that are not inside the directory of
+// another, since links such as bazel-bin point inside the one of bazel-out
+func outermost(targets []types.Target) []types.Target {
+	sort.SliceStable(targets, func(i, j int) bool {
+		return len(targets[i].Path) < len(targets[j].Path)
+	})
+
+	var kept []types.Target
+	for _, target := range targets {
+		inside := false
+		for _, outer := range kept {
+			if target.Path == outer.Path || strings.HasPrefix(target.Path, outer.Path+string(os.PathSeparator)) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			kept = append(kept, target)
+		}
+	}
+	return kept
+}
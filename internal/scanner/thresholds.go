@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/raucheacho/rosia-cli/pkg/logger"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// errNotStale is returned by createTarget for targets modified more recently
+// than the stale_after of their pattern
+var errNotStale = errors.New("target is not stale yet")
+
+// applyThresholds drops the sized targets that do not reach the min_size or
+// min_age of their profile. Targets without a loaded profile, such as those
+// of plugins, are kept.
+func (s *Scanner) applyThresholds(targets []types.Target, now time.Time) []types.Target {
+	kept := targets[:0]
+	for _, target := range targets {
+		profile, err := s.profileLoader.GetProfile(target.ProfileName)
+		if err == nil && !s.profileLoader.MeetsThresholds(target, profile, now) {
+			logger.Debug("Skipping %s: below the thresholds of profile %s", target.Path, profile.Name)
+			continue
+		}
+		kept = append(kept, target)
+	}
+	return kept
+}
+
+// sizeForThresholds sizes the targets whose profile has a min_size, so that
+// applyThresholds can be used before the targets are sized otherwise
+func (s *Scanner) sizeForThresholds(ctx context.Context, targets []types.Target) []types.Target {
+	var indexes []int
+	var toSize []types.Target
+	for i, target := range targets {
+		profile, err := s.profileLoader.GetProfile(target.ProfileName)
+		if err != nil {
+			continue
+		}
+		if minSize, _ := s.profileLoader.Thresholds(profile); minSize > 0 {
+			indexes = append(indexes, i)
+			toSize = append(toSize, target)
+		}
+	}
+	if len(toSize) == 0 {
+		return targets
+	}
+
+	sized, err := s.sizeCalc.CalculateTargets(ctx, toSize)
+	if err != nil {
+		logger.Warn("Failed to calculate sizes: %v", err)
+	}
+	for j, i := range indexes {
+		targets[i] = sized[j]
+	}
+	return targets
+}
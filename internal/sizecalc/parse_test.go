@@ -0,0 +1,37 @@
+package sizecalc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"512", 512},
+		{"10B", 10},
+		{"4K", 4096},
+		{"2MB", 2 << 20},
+		{"50GB", 50 << 30},
+		{"1.5 GiB", 3 << 29},
+		{"1tb", 1 << 40},
+		{" 100 mb ", 100 << 20},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.input)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, got, tt.input)
+	}
+}
+
+func TestParseSize_Invalid(t *testing.T) {
+	for _, input := range []string{"", "GB", "10XB", "1.2.3MB", "-5GB"} {
+		_, err := ParseSize(input)
+		assert.Error(t, err, input)
+	}
+}
@@ -141,8 +141,11 @@ func (sc *SizeCalc) CalculateTargets(ctx context.Context, targets []types.Target
 		}()
 	}
 
-	// Submit jobs
+	// Submit jobs; virtual targets are sized by their plugin
 	for i := range targets {
+		if targets[i].Virtual {
+			continue
+		}
 		select {
 		case <-ctx.Done():
 			close(jobs)
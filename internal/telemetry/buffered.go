@@ -0,0 +1,121 @@
+package telemetry
+
+import "sync"
+
+// BufferedStore is a TelemetryStore that keeps the events recorded in memory
+// and writes them to a FileStore in the background, so that recording never
+// waits for the statistics file to be rewritten. Events recorded while a
+// write is in progress are written together by the next one. Close writes
+// the events left and must be called before the process exits.
+type BufferedStore struct {
+	store *FileStore
+
+	mu      sync.Mutex // Guards pending, closed and err
+	pending []TelemetryEvent
+	closed  bool
+	err     error // First error of a background write
+
+	flushMu sync.Mutex    // Serializes writes, so events keep their order
+	wake    chan struct{} // Signals pending events to the writer
+	done    chan struct{} // Closed once the writer has returned
+}
+
+// NewBufferedStore returns a BufferedStore writing to store, and starts its
+// background writer
+func NewBufferedStore(store *FileStore) *BufferedStore {
+	b := &BufferedStore{
+		store: store,
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Record buffers event for the background writer. Once the store is closed,
+// events are written directly.
+func (b *BufferedStore) Record(event TelemetryEvent) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return b.store.Record(event)
+	}
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, event)
+
+	// Under the lock, so that Close cannot close wake meanwhile
+	select {
+	case b.wake <- struct{}{}:
+	default:
+		// The writer is already due to run
+	}
+	return nil
+}
+
+// GetStats returns the statistics of the store, with the events buffered
+func (b *BufferedStore) GetStats() (*Stats, error) {
+	if err := b.Flush(); err != nil {
+		return nil, err
+	}
+	return b.store.GetStats()
+}
+
+// Export returns the statistics of the store as JSON, with the events
+// buffered
+func (b *BufferedStore) Export() ([]byte, error) {
+	if err := b.Flush(); err != nil {
+		return nil, err
+	}
+	return b.store.Export()
+}
+
+// Flush writes the events buffered, waiting for the write
+func (b *BufferedStore) Flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	events := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+	return b.store.RecordAll(events)
+}
+
+// Close stops the background writer and writes the events left. It returns
+// the first error of the writes, background ones included. Closing twice is
+// harmless.
+func (b *BufferedStore) Close() error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.wake)
+	}
+	b.mu.Unlock()
+	<-b.done
+
+	err := b.Flush()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	return err
+}
+
+// run writes the events buffered whenever some are recorded, until Close
+func (b *BufferedStore) run() {
+	defer close(b.done)
+	for range b.wake {
+		if err := b.Flush(); err != nil {
+			b.mu.Lock()
+			if b.err == nil {
+				b.err = err
+			}
+			b.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package telemetry
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferedStore(t *testing.T) {
+	fileStore, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+	store := NewBufferedStore(fileStore)
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.Record(NewEvent(&CleanEvent{Profile: "node", Size: int64(i + 1)})))
+		}()
+	}
+	wg.Wait()
+
+	// Reads include the events not written yet
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Len(t, stats.Events, 50)
+	assert.Equal(t, int64(50*51/2), stats.TotalCleaned)
+
+	// Close writes the events left, later ones are written directly
+	require.NoError(t, store.Record(NewEvent(&ScanEvent{})))
+	require.NoError(t, store.Close())
+	require.NoError(t, store.Close())
+	require.NoError(t, store.Record(NewEvent(&ScanEvent{})))
+
+	stats, err = fileStore.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalScans)
+	assert.Len(t, stats.Events, 52)
+}
+
+func TestBufferedStore_Error(t *testing.T) {
+	statsPath := filepath.Join(t.TempDir(), "stats.json")
+	fileStore, err := NewFileStore(statsPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(statsPath, []byte(`{"version": 99}`), 0644))
+
+	// Recording never fails, the writes report their errors on Close
+	store := NewBufferedStore(fileStore)
+	require.NoError(t, store.Record(NewEvent(&ScanEvent{})))
+	assert.ErrorContains(t, store.Close(), "upgrade rosia")
+}
@@ -0,0 +1,188 @@
+package telemetry
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version of the format of the statistics files Save
+// writes. Files without a version, whose events were free-form maps, are
+// version 0; their data decodes as the typed events of version 1. Version 2
+// adds the bytes cleaned per profile, derived from the events when loading
+// earlier files. Changing the format of the file, like adding a field or an
+// event type, means incrementing it, so that older versions of rosia refuse
+// the files rather than drop what they do not know when rewriting them.
+const SchemaVersion = 2
+
+// Event types
+const (
+	EventScan    = "scan"
+	EventClean   = "clean"
+	EventRestore = "restore"
+	EventPurge   = "purge"
+	EventError   = "error"
+)
+
+// EventData is the data of a telemetry event: a *ScanEvent, *CleanEvent,
+// *RestoreEvent, *PurgeEvent or *ErrorEvent
+type EventData interface {
+	EventType() string
+}
+
+// ScanEvent is the data of a scan
+type ScanEvent struct {
+	TargetsFound int                `json:"targets_found"`        // Number of targets found
+	FreeSpace    []FreeSpaceMeasure `json:"free_space,omitempty"` // Free space of the filesystems scanned
+}
+
+// CleanEvent is the data of the targets of a profile cleaned together
+type CleanEvent struct {
+	Profile   string             `json:"profile"`              // Profile of the targets
+	Size      int64              `json:"size"`                 // Bytes cleaned
+	Duration  float64            `json:"duration"`             // Duration of the whole clean, in seconds
+	Targets   []CleanedTarget    `json:"targets,omitempty"`    // Targets cleaned, nil for events recorded before they were
+	FreeSpace []FreeSpaceMeasure `json:"free_space,omitempty"` // Free space of the filesystems cleaned, once cleaned
+}
+
+// CleanedTarget is a target cleaned by a clean event
+type CleanedTarget struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// RestoreEvent is the data of trash items restored together
+type RestoreEvent struct {
+	Items int   `json:"items"` // Number of items restored
+	Size  int64 `json:"size"`  // Their size in bytes, 0 when unknown
+}
+
+// PurgeEvent is the data of trash items purged together
+type PurgeEvent struct {
+	Items int   `json:"items"` // Number of items purged
+	Size  int64 `json:"size"`  // Their size in bytes
+}
+
+// ErrorEvent is the data of the failures of an operation
+type ErrorEvent struct {
+	Operation string `json:"operation"`       // "clean", "restore" or "purge"
+	Failed    int    `json:"failed"`          // Number of targets or items it failed for
+	Error     string `json:"error,omitempty"` // First error
+}
+
+// FreeSpaceMeasure is the free space of a filesystem at the time of an event
+type FreeSpaceMeasure struct {
+	Path       string `json:"path"`       // Path it was measured at
+	Filesystem string `json:"filesystem"` // Identifier of the filesystem
+	Free       int64  `json:"free"`       // Free bytes
+}
+
+// EventType returns EventScan
+func (*ScanEvent) EventType() string { return EventScan }
+
+// EventType returns EventClean
+func (*CleanEvent) EventType() string { return EventClean }
+
+// EventType returns EventRestore
+func (*RestoreEvent) EventType() string { return EventRestore }
+
+// EventType returns EventPurge
+func (*PurgeEvent) EventType() string { return EventPurge }
+
+// EventType returns EventError
+func (*ErrorEvent) EventType() string { return EventError }
+
+// NewEvent returns an event of data happening now
+func NewEvent(data EventData) TelemetryEvent {
+	return TelemetryEvent{Type: data.EventType(), Timestamp: time.Now(), Data: data}
+}
+
+// newEventData returns the data of an event of type eventType, nil for an
+// unknown type
+func newEventData(eventType string) EventData {
+	switch eventType {
+	case EventScan:
+		return &ScanEvent{}
+	case EventClean:
+		return &CleanEvent{}
+	case EventRestore:
+		return &RestoreEvent{}
+	case EventPurge:
+		return &PurgeEvent{}
+	case EventError:
+		return &ErrorEvent{}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes the data of an event by its type, and gives the
+// events written before they had IDs one derived from their content.
+// Events of unknown types keep no data.
+func (e *TelemetryEvent) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID        string          `json:"id"`
+		Type      string          `json:"type"`
+		Timestamp time.Time       `json:"timestamp"`
+		Data      json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*e = TelemetryEvent{ID: raw.ID, Type: raw.Type, Timestamp: raw.Timestamp}
+	if eventData := newEventData(raw.Type); eventData != nil {
+		if len(raw.Data) > 0 && string(raw.Data) != "null" {
+			if err := json.Unmarshal(raw.Data, eventData); err != nil {
+				return fmt.Errorf("invalid data of %s event: %w", raw.Type, err)
+			}
+		}
+		e.Data = eventData
+	}
+	if e.ID == "" {
+		e.ID = legacyEventID(raw.Type, raw.Timestamp, raw.Data)
+	}
+	return nil
+}
+
+// newEventID returns a random event ID
+func newEventID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// legacyEventID returns the ID of an event recorded before events had one,
+// derived from its content so that every copy of it gets the same ID. The
+// content is hashed as version 0 wrote it, its data as a map.
+func legacyEventID(eventType string, timestamp time.Time, data json.RawMessage) string {
+	var fields map[string]interface{}
+	_ = json.Unmarshal(data, &fields)
+	content, _ := json.Marshal(struct {
+		Type      string                 `json:"type"`
+		Timestamp time.Time              `json:"timestamp"`
+		Data      map[string]interface{} `json:"data"`
+	}{eventType, timestamp, fields})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+// eventID returns the ID of event, derived from its content when it has none
+func eventID(event TelemetryEvent) string {
+	if event.ID != "" {
+		return event.ID
+	}
+	data, _ := json.Marshal(event.Data)
+	return legacyEventID(event.Type, event.Timestamp, data)
+}
+
+// checkVersion returns an error for statistics of a later version, written
+// by a newer rosia, which are refused rather than misread
+func checkVersion(version int) error {
+	if version > SchemaVersion {
+		return fmt.Errorf("statistics version %d is newer than the version %d this rosia supports, upgrade rosia", version, SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore_RecordTyped(t *testing.T) {
+	statsPath := filepath.Join(t.TempDir(), "stats.json")
+	store, err := NewFileStore(statsPath)
+	require.NoError(t, err)
+
+	clean := &CleanEvent{
+		Profile:   "node",
+		Size:      4000,
+		Duration:  1.5,
+		Targets:   []CleanedTarget{{Path: "/p/node_modules", Size: 4000}},
+		FreeSpace: []FreeSpaceMeasure{{Path: "/p", Filesystem: "1", Free: 9000}},
+	}
+	require.NoError(t, store.Record(NewEvent(clean)))
+	require.NoError(t, store.Record(NewEvent(&ErrorEvent{Operation: "clean", Failed: 2, Error: "busy"})))
+
+	// Events read back as they were recorded, in a file of the current version
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	require.Len(t, stats.Events, 2)
+	assert.Equal(t, EventClean, stats.Events[0].Type)
+	assert.Equal(t, clean, stats.Events[0].Data)
+	assert.NotEmpty(t, stats.Events[0].ID)
+	assert.Equal(t, &ErrorEvent{Operation: "clean", Failed: 2, Error: "busy"}, stats.Events[1].Data)
+
+	data, err := os.ReadFile(statsPath)
+	require.NoError(t, err)
+	var file struct {
+		Version int `json:"version"`
+	}
+	require.NoError(t, json.Unmarshal(data, &file))
+	assert.Equal(t, SchemaVersion, file.Version)
+}
+
+func TestFileStore_LegacyEvents(t *testing.T) {
+	statsPath := filepath.Join(t.TempDir(), "stats.json")
+	// Version 0 files hold the data of their events as free-form maps
+	require.NoError(t, os.WriteFile(statsPath, []byte(`{
+  "total_scans": 1,
+  "total_cleaned": 1000,
+  "average_size_by_type": {"node": 1000},
+  "cleans_by_type": {"node": 1},
+  "events": [
+    {"type": "scan", "timestamp": "2025-04-28T14:30:00Z", "data": {"timestamp": "2025-04-28T14:30:00Z", "targets_found": 2}},
+    {"type": "clean", "timestamp": "2025-04-28T14:31:00Z", "data": {"size": 1000, "profile": "node", "duration": 0.5,
+      "targets": [{"path": "/p/node_modules", "size": 1000}], "free_space": [{"path": "/p", "filesystem": "1", "free": 5000}]}},
+    {"type": "upgrade", "timestamp": "2025-04-28T14:32:00Z", "data": {"from": "1.0"}}
+  ]
+}`), 0644))
+
+	store, err := NewFileStore(statsPath)
+	require.NoError(t, err)
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+
+	require.Len(t, stats.Events, 3)
+	assert.Equal(t, &ScanEvent{TargetsFound: 2}, stats.Events[0].Data)
+	assert.Equal(t, &CleanEvent{
+		Profile:   "node",
+		Size:      1000,
+		Duration:  0.5,
+		Targets:   []CleanedTarget{{Path: "/p/node_modules", Size: 1000}},
+		FreeSpace: []FreeSpaceMeasure{{Path: "/p", Filesystem: "1", Free: 5000}},
+	}, stats.Events[1].Data)
+	assert.Nil(t, stats.Events[2].Data, "unknown event types keep no data")
+
+	// Their IDs derive from their content, the same on every load
+	again, err := store.GetStats()
+	require.NoError(t, err)
+	for i, event := range stats.Events {
+		assert.NotEmpty(t, event.ID)
+		assert.Equal(t, event.ID, again.Events[i].ID)
+	}
+	assert.NotEqual(t, stats.Events[0].ID, stats.Events[1].ID)
+}
+
+func TestFileStore_NewerVersion(t *testing.T) {
+	statsPath := filepath.Join(t.TempDir(), "stats.json")
+	newer := []byte(`{"version": 99, "events": []}`)
+	require.NoError(t, os.WriteFile(statsPath, newer, 0644))
+
+	// Files of a newer rosia are neither read nor rewritten
+	store, err := NewFileStore(statsPath)
+	require.NoError(t, err)
+	_, err = store.GetStats()
+	assert.ErrorContains(t, err, "upgrade rosia")
+	assert.Error(t, store.Record(NewEvent(&ScanEvent{})))
+	data, err := os.ReadFile(statsPath)
+	require.NoError(t, err)
+	assert.Equal(t, newer, data)
+
+	_, err = ParseStats(newer)
+	assert.ErrorContains(t, err, "upgrade rosia")
+}
+
+func TestNewEvent(t *testing.T) {
+	event := NewEvent(&PurgeEvent{Items: 2, Size: 300})
+	assert.Equal(t, EventPurge, event.Type)
+	assert.WithinDuration(t, time.Now(), event.Timestamp, time.Second)
+	assert.Empty(t, event.ID, "IDs are assigned by Record")
+}
@@ -0,0 +1,75 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ImportResult summarizes the events merged by Import
+type ImportResult struct {
+	Added      int // Events merged into the store
+	Duplicates int // Events the store already had
+	Skipped    int // Events older than the pruned events of the store
+}
+
+// ParseStats parses statistics exported as JSON, by Export or by
+// 'rosia stats export', of any version up to SchemaVersion
+func ParseStats(data []byte) (*Stats, error) {
+	var stats Stats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("failed to parse statistics: %w", err)
+	}
+	if err := checkVersion(stats.Version); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Import merges the events of other, such as the statistics exported on
+// another machine, into the store. Events the store already has, by ID, are
+// left out, so that importing the same file twice counts it once. The
+// aggregates count the events merged: those other pruned are lost with it.
+//
+// Events at or before the pruned events of the store are skipped too, since
+// the store cannot tell whether it already counted them.
+func (fs *FileStore) Import(other *Stats) (ImportResult, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var result ImportResult
+	stats, err := fs.load()
+	if err != nil {
+		return result, fmt.Errorf("failed to load telemetry stats: %w", err)
+	}
+
+	seen := make(map[string]bool, len(stats.Events))
+	for _, event := range stats.Events {
+		seen[event.ID] = true
+	}
+	for _, event := range other.Events {
+		event.ID = eventID(event)
+		switch {
+		case seen[event.ID]:
+			result.Duplicates++
+		case !stats.PrunedBefore.IsZero() && !event.Timestamp.After(stats.PrunedBefore):
+			result.Skipped++
+		default:
+			seen[event.ID] = true
+			fs.apply(stats, event)
+			result.Added++
+		}
+	}
+	if result.Added == 0 {
+		return result, nil
+	}
+
+	// Pruning expects the events in the order they happened
+	sort.SliceStable(stats.Events, func(i, j int) bool {
+		return stats.Events[i].Timestamp.Before(stats.Events[j].Timestamp)
+	})
+	fs.prune(stats, time.Now())
+
+	return result, fs.save(stats)
+}
@@ -0,0 +1,108 @@
+package telemetry
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore_Import(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	laptop, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+	require.NoError(t, laptop.Record(TelemetryEvent{Type: "scan", Timestamp: now.Add(-2 * time.Hour), Data: &ScanEvent{}}))
+	require.NoError(t, laptop.Record(TelemetryEvent{Type: "clean", Timestamp: now.Add(-2 * time.Hour), Data: &CleanEvent{Size: 3000, Profile: "node"}}))
+	data, err := laptop.Export()
+	require.NoError(t, err)
+
+	workstation, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+	require.NoError(t, workstation.Record(TelemetryEvent{Type: "scan", Timestamp: now.Add(-time.Hour), Data: &ScanEvent{}}))
+	require.NoError(t, workstation.Record(TelemetryEvent{Type: "clean", Timestamp: now.Add(-time.Hour), Data: &CleanEvent{Size: 1000, Profile: "node"}}))
+
+	exported, err := ParseStats(data)
+	require.NoError(t, err)
+	result, err := workstation.Import(exported)
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Added: 2}, result)
+
+	stats, err := workstation.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalScans)
+	assert.Equal(t, int64(4000), stats.TotalCleaned)
+	assert.Equal(t, int64(2000), stats.AverageSizeByType["node"])
+	assert.Equal(t, 2, stats.CleansByType["node"])
+	assert.Equal(t, int64(4000), stats.CleanedByType["node"])
+	assert.WithinDuration(t, now.Add(-time.Hour), stats.LastScan, time.Second, "an older scan leaves the last scan")
+	require.Len(t, stats.Events, 4)
+	assert.True(t, stats.Events[0].Timestamp.Equal(now.Add(-2*time.Hour)), "events are kept in time order")
+
+	// Importing the same file again counts nothing
+	result, err = workstation.Import(exported)
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Duplicates: 2}, result)
+	stats, err = workstation.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4000), stats.TotalCleaned)
+	assert.Equal(t, int64(4000), stats.CleanedByType["node"])
+}
+
+func TestFileStore_ImportLegacy(t *testing.T) {
+	// Events written before they had IDs are identified by their content
+	legacy := []byte(`{
+  "events": [
+    {"type": "clean", "timestamp": "2025-04-28T14:30:00Z", "data": {"size": 1000, "profile": "node"}},
+    {"type": "clean", "timestamp": "2025-04-28T14:31:00Z", "data": {"size": 2000, "profile": "node"}}
+  ]
+}`)
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+	store.SetLimits(0, 0)
+
+	for i, want := range []ImportResult{{Added: 2}, {Duplicates: 2}} {
+		other, err := ParseStats(legacy)
+		require.NoError(t, err)
+		result, err := store.Import(other)
+		require.NoError(t, err)
+		assert.Equal(t, want, result, "import %d", i+1)
+	}
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3000), stats.TotalCleaned)
+	for _, event := range stats.Events {
+		assert.NotEmpty(t, event.ID)
+	}
+}
+
+func TestFileStore_ImportPruned(t *testing.T) {
+	now := time.Now()
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+	store.SetLimits(0, 1)
+	for i := range 2 {
+		require.NoError(t, store.Record(TelemetryEvent{Type: "scan", Timestamp: now.Add(time.Duration(i-3) * time.Hour)}))
+	}
+
+	// Events the store may have pruned are not counted again
+	other := &Stats{Events: []TelemetryEvent{
+		{ID: "a", Type: "scan", Timestamp: now.Add(-4 * time.Hour)},
+		{ID: "b", Type: "scan", Timestamp: now},
+	}}
+	result, err := store.Import(other)
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Added: 1, Skipped: 1}, result)
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.TotalScans)
+}
+
+func TestParseStats_Invalid(t *testing.T) {
+	_, err := ParseStats([]byte("timestamp,type\n"))
+	assert.Error(t, err)
+}
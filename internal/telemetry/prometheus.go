@@ -0,0 +1,152 @@
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric is a metric in the Prometheus text exposition format, as read by
+// the textfile collector of the node exporter
+type Metric struct {
+	Name    string   // Metric name, e.g. "rosia_total_scans"
+	Help    string   // Description of the metric
+	Type    string   // "counter" or "gauge"
+	Samples []Sample // Values of the metric, one per label set
+}
+
+// Sample is a value of a Metric
+type Sample struct {
+	Labels map[string]string // Labels of the value, nil for none
+	Value  float64
+}
+
+// Metrics returns the statistics of s as Prometheus metrics. They cover all
+// time: the counters include the events pruned from the log.
+func (s *Stats) Metrics() []Metric {
+	metrics := []Metric{
+		{
+			Name:    "rosia_total_scans",
+			Help:    "Number of scans performed.",
+			Type:    "counter",
+			Samples: []Sample{{Value: float64(s.TotalScans)}},
+		},
+		{
+			Name:    "rosia_total_cleaned_bytes",
+			Help:    "Bytes cleaned, of every profile.",
+			Type:    "counter",
+			Samples: []Sample{{Value: float64(s.TotalCleaned)}},
+		},
+	}
+
+	metrics = append(metrics,
+		Metric{
+			Name:    "rosia_total_restores",
+			Help:    "Number of trash items restored.",
+			Type:    "counter",
+			Samples: []Sample{{Value: float64(s.TotalRestores)}},
+		},
+		Metric{
+			Name:    "rosia_total_purges",
+			Help:    "Number of trash items purged.",
+			Type:    "counter",
+			Samples: []Sample{{Value: float64(s.TotalPurges)}},
+		},
+	)
+
+	failures := Metric{Name: "rosia_failures", Help: "Number of items an operation failed for.", Type: "counter"}
+	for operation, failed := range s.FailuresByOperation {
+		failures.Samples = append(failures.Samples, Sample{
+			Labels: map[string]string{"operation": operation},
+			Value:  float64(failed),
+		})
+	}
+	if len(failures.Samples) > 0 {
+		metrics = append(metrics, failures)
+	}
+
+	cleaned := Metric{Name: "rosia_cleaned_bytes", Help: "Bytes cleaned per profile.", Type: "counter"}
+	for profileName, size := range s.CleanedByType {
+		cleaned.Samples = append(cleaned.Samples, Sample{
+			Labels: map[string]string{"profile": profileName},
+			Value:  float64(size),
+		})
+	}
+	if len(cleaned.Samples) > 0 {
+		metrics = append(metrics, cleaned)
+	}
+
+	if !s.LastScan.IsZero() {
+		metrics = append(metrics, Metric{
+			Name:    "rosia_last_scan_timestamp",
+			Help:    "Time of the last scan, in seconds since the epoch.",
+			Type:    "gauge",
+			Samples: []Sample{{Value: float64(s.LastScan.Unix())}},
+		})
+	}
+
+	free := Metric{Name: "rosia_free_space_bytes", Help: "Free space of the filesystems last scanned or cleaned, at that time.", Type: "gauge"}
+	for _, trend := range s.FreeSpaceTrends() {
+		free.Samples = append(free.Samples, Sample{
+			Labels: map[string]string{"path": trend.Path},
+			Value:  float64(trend.Samples[len(trend.Samples)-1].Free),
+		})
+	}
+	if len(free.Samples) > 0 {
+		metrics = append(metrics, free)
+	}
+
+	return metrics
+}
+
+// WritePrometheus writes metrics to w in the Prometheus text exposition
+// format. Samples are sorted by their labels, for the output to be stable.
+func WritePrometheus(w io.Writer, metrics []Metric) error {
+	var b strings.Builder
+	for _, metric := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n", metric.Name, escapeHelp(metric.Help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", metric.Name, metric.Type)
+
+		lines := make([]string, 0, len(metric.Samples))
+		for _, sample := range metric.Samples {
+			lines = append(lines, metric.Name+formatLabels(sample.Labels)+" "+strconv.FormatFloat(sample.Value, 'f', -1, 64))
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatLabels returns labels as {name="value",...}, sorted by name, or ""
+// when there are none
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(labels[name]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeHelp escapes a HELP line, where quotes need no escaping
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
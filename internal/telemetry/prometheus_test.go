@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	stats := &Stats{
+		TotalScans:          4,
+		TotalCleaned:        5001,
+		AverageSizeByType:   map[string]int64{"Node.js": 1500, "Rust": 2000},
+		CleansByType:        map[string]int{"Node.js": 2, "Rust": 1},
+		CleanedByType:       map[string]int64{"Node.js": 3001, "Rust": 2000}, // Exact, unlike the averages
+		TotalRestores:       2,
+		FailuresByOperation: map[string]int{"clean": 1},
+		LastScan:            time.Unix(1745850600, 0),
+		Events: []TelemetryEvent{
+			{Type: "scan", Data: &ScanEvent{FreeSpace: []FreeSpaceMeasure{{Path: `/home/u/"a"`, Filesystem: "1", Free: 1000}}}},
+			{Type: "scan", Data: &ScanEvent{FreeSpace: []FreeSpaceMeasure{{Path: "/home/u/b", Filesystem: "1", Free: 2000}}}},
+		},
+	}
+
+	var b strings.Builder
+	require.NoError(t, WritePrometheus(&b, stats.Metrics()))
+	assert.Equal(t, `# HELP rosia_total_scans Number of scans performed.
+# TYPE rosia_total_scans counter
+rosia_total_scans 4
+# HELP rosia_total_cleaned_bytes Bytes cleaned, of every profile.
+# TYPE rosia_total_cleaned_bytes counter
+rosia_total_cleaned_bytes 5001
+# HELP rosia_total_restores Number of trash items restored.
+# TYPE rosia_total_restores counter
+rosia_total_restores 2
+# HELP rosia_total_purges Number of trash items purged.
+# TYPE rosia_total_purges counter
+rosia_total_purges 0
+# HELP rosia_failures Number of items an operation failed for.
+# TYPE rosia_failures counter
+rosia_failures{operation="clean"} 1
+# HELP rosia_cleaned_bytes Bytes cleaned per profile.
+# TYPE rosia_cleaned_bytes counter
+rosia_cleaned_bytes{profile="Node.js"} 3001
+rosia_cleaned_bytes{profile="Rust"} 2000
+# HELP rosia_last_scan_timestamp Time of the last scan, in seconds since the epoch.
+# TYPE rosia_last_scan_timestamp gauge
+rosia_last_scan_timestamp 1745850600
+# HELP rosia_free_space_bytes Free space of the filesystems last scanned or cleaned, at that time.
+# TYPE rosia_free_space_bytes gauge
+rosia_free_space_bytes{path="/home/u/b"} 2000
+`, b.String())
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "", formatLabels(nil))
+	assert.Equal(t, `{a="1",path="C:\\p \"x\"\n"}`, formatLabels(map[string]string{"path": "C:\\p \"x\"\n", "a": "1"}))
+}
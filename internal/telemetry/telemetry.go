@@ -1,35 +1,44 @@
 // Package telemetry provides statistics tracking and reporting functionality.
 //
-// The telemetry system records scan and clean operations locally in ~/.rosia/stats.json,
-// enabling users to track disk space savings over time. All data is stored locally
-// unless the user explicitly opts in to cloud telemetry.
+// The telemetry system records scan, clean, restore and purge operations
+// locally, enabling users to track disk space savings over time. Events and
+// the totals computed from them are kept in a versioned statistics file, see
+// SchemaVersion, in the data directory (~/.rosia/stats.json for installs not
+// migrated yet, see GetDefaultStatsPath). All data is stored locally unless
+// the user explicitly opts in to cloud telemetry.
 //
 // Example usage:
 //
-//	store := telemetry.NewStore("~/.rosia/stats.json")
-//	store.Record(telemetry.TelemetryEvent{
-//	    Type: "scan",
-//	    Data: map[string]interface{}{"targets_found": 42},
-//	})
+//	path, _ := telemetry.GetDefaultStatsPath()
+//	store, _ := telemetry.NewFileStore(path)
+//	store.Record(telemetry.NewEvent(&telemetry.ScanEvent{TargetsFound: 42}))
 //	stats, _ := store.GetStats()
 package telemetry
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
 )
 
 // TelemetryEvent represents a single telemetry event.
 //
-// Events are recorded for scan and clean operations with associated metadata.
+// Events are recorded for scan, clean, restore and purge operations with
+// their typed data, and for the failures of the last three as error events.
 type TelemetryEvent struct {
-	Type      string                 `json:"type"`      // Event type (e.g., "scan", "clean")
-	Timestamp time.Time              `json:"timestamp"` // When the event occurred
-	Data      map[string]interface{} `json:"data"`      // Event-specific data
+	ID        string    `json:"id,omitempty"` // Unique ID, assigned by Record, for merging stores
+	Type      string    `json:"type"`         // Event type, that of Data: EventScan, EventClean...
+	Timestamp time.Time `json:"timestamp"`    // When the event occurred
+	Data      EventData `json:"data"`         // Event-specific data, nil for unknown types
 }
 
 // Stats represents aggregated telemetry statistics.
@@ -37,13 +46,28 @@ type TelemetryEvent struct {
 // Stats are computed from recorded events and provide insights into
 // cleaning history and disk space savings.
 type Stats struct {
-	TotalScans        int              `json:"total_scans"`          // Total number of scans performed
-	TotalCleaned      int64            `json:"total_cleaned"`        // Total bytes cleaned
-	AverageSizeByType map[string]int64 `json:"average_size_by_type"` // Average size per target type
-	LastScan          time.Time        `json:"last_scan"`            // Timestamp of last scan
-	Events            []TelemetryEvent `json:"events"`               // All recorded events
+	Version             int              `json:"version"`               // SchemaVersion of the file
+	TotalScans          int              `json:"total_scans"`           // Total number of scans performed
+	TotalCleaned        int64            `json:"total_cleaned"`         // Total bytes cleaned
+	AverageSizeByType   map[string]int64 `json:"average_size_by_type"`  // Average size per target type
+	CleansByType        map[string]int   `json:"cleans_by_type"`        // Number of clean events per target type, for the averages
+	CleanedByType       map[string]int64 `json:"cleaned_by_type"`       // Bytes cleaned per target type
+	LastScan            time.Time        `json:"last_scan"`             // Timestamp of last scan
+	TargetsCleaned      int              `json:"targets_cleaned"`       // Number of targets cleaned, for the failure rate of cleans
+	TotalRestores       int              `json:"total_restores"`        // Number of trash items restored
+	TotalPurges         int              `json:"total_purges"`          // Number of trash items purged
+	FailuresByOperation map[string]int   `json:"failures_by_operation"` // Number of items each operation failed for
+	PrunedBefore        time.Time        `json:"pruned_before"`         // Events up to this time may have been pruned (zero = none were)
+	Events              []TelemetryEvent `json:"events"`                // Recorded events, but the pruned ones
 }
 
+// Default limits of the event log. Older events are pruned as new ones are
+// recorded; the aggregates keep counting them.
+const (
+	DefaultMaxEventAge = 365 * 24 * time.Hour
+	DefaultMaxEvents   = 10000
+)
+
 // TelemetryStore defines the interface for telemetry operations.
 //
 // Implementations handle recording events and computing statistics.
@@ -53,10 +77,14 @@ type TelemetryStore interface {
 	Export() ([]byte, error)
 }
 
-// FileStore implements TelemetryStore using a JSON file
+// FileStore implements TelemetryStore using a JSON file. The file is
+// rewritten on each Record, so its event log is kept bounded by age and
+// count; the aggregates stay exact.
 type FileStore struct {
-	filePath string
-	mu       sync.RWMutex
+	filePath    string
+	maxEventAge time.Duration // Events older than this are pruned (0 = no limit)
+	maxEvents   int           // Only the newest events are kept beyond this count (0 = no limit)
+	mu          sync.RWMutex
 }
 
 // NewFileStore creates a new FileStore instance
@@ -68,7 +96,9 @@ func NewFileStore(filePath string) (*FileStore, error) {
 	}
 
 	store := &FileStore{
-		filePath: filePath,
+		filePath:    filePath,
+		maxEventAge: DefaultMaxEventAge,
+		maxEvents:   DefaultMaxEvents,
 	}
 
 	// Initialize file if it doesn't exist
@@ -77,6 +107,8 @@ func NewFileStore(filePath string) (*FileStore, error) {
 			TotalScans:        0,
 			TotalCleaned:      0,
 			AverageSizeByType: make(map[string]int64),
+			CleansByType:      make(map[string]int),
+			CleanedByType:     make(map[string]int64),
 			Events:            []TelemetryEvent{},
 		}
 		if err := store.save(initialStats); err != nil {
@@ -87,8 +119,25 @@ func NewFileStore(filePath string) (*FileStore, error) {
 	return store, nil
 }
 
-// Record appends a new telemetry event to the store
+// SetLimits bounds the event log to the events of the last maxAge and to the
+// newest maxEvents of them, 0 for no limit. Pruning happens on the next
+// Record.
+func (fs *FileStore) SetLimits(maxAge time.Duration, maxEvents int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.maxEventAge = maxAge
+	fs.maxEvents = maxEvents
+}
+
+// Record appends a new telemetry event to the store, pruning the events
+// beyond the limits of the log
 func (fs *FileStore) Record(event TelemetryEvent) error {
+	return fs.RecordAll([]TelemetryEvent{event})
+}
+
+// RecordAll appends events to the store like Record, rewriting the file once
+// for all of them
+func (fs *FileStore) RecordAll(events []TelemetryEvent) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -97,38 +146,83 @@ func (fs *FileStore) Record(event TelemetryEvent) error {
 		return fmt.Errorf("failed to load telemetry stats: %w", err)
 	}
 
+	for _, event := range events {
+		if event.ID == "" {
+			event.ID = newEventID()
+		}
+		fs.apply(stats, event)
+	}
+	fs.prune(stats, time.Now())
+
+	return fs.save(stats)
+}
+
+// apply counts event in the aggregates of stats and appends it to the events
+func (fs *FileStore) apply(stats *Stats, event TelemetryEvent) {
+	// The type and data of an event go together, either may be left out
+	if event.Type == "" && event.Data != nil {
+		event.Type = event.Data.EventType()
+	} else if event.Data == nil {
+		event.Data = newEventData(event.Type)
+	}
+
 	// Update aggregated statistics based on event type BEFORE adding to events list
-	switch event.Type {
-	case "scan":
+	switch data := event.Data.(type) {
+	case *ScanEvent:
 		stats.TotalScans++
-		if timestamp, ok := event.Data["timestamp"].(time.Time); ok {
-			stats.LastScan = timestamp
-		} else if timestampStr, ok := event.Data["timestamp"].(string); ok {
-			if t, err := time.Parse(time.RFC3339, timestampStr); err == nil {
-				stats.LastScan = t
-			}
-		}
-	case "clean":
-		if size, ok := event.Data["size"].(float64); ok {
-			stats.TotalCleaned += int64(size)
-		} else if size, ok := event.Data["size"].(int64); ok {
-			stats.TotalCleaned += size
+		// Merged events may be older than the last scan
+		if event.Timestamp.After(stats.LastScan) {
+			stats.LastScan = event.Timestamp
 		}
-
+	case *CleanEvent:
+		stats.TotalCleaned += data.Size
 		// Update average size by type (before adding event to list)
-		if profileName, ok := event.Data["profile"].(string); ok {
-			if size, ok := event.Data["size"].(float64); ok {
-				fs.updateAverageSize(stats, profileName, int64(size))
-			} else if size, ok := event.Data["size"].(int64); ok {
-				fs.updateAverageSize(stats, profileName, size)
+		if data.Profile != "" {
+			fs.updateAverageSize(stats, data.Profile, data.Size)
+			if stats.CleanedByType == nil {
+				stats.CleanedByType = make(map[string]int64)
 			}
+			stats.CleanedByType[data.Profile] += data.Size
+		}
+		stats.TargetsCleaned += len(data.Targets)
+	case *RestoreEvent:
+		stats.TotalRestores += data.Items
+	case *PurgeEvent:
+		stats.TotalPurges += data.Items
+	case *ErrorEvent:
+		if stats.FailuresByOperation == nil {
+			stats.FailuresByOperation = make(map[string]int)
 		}
+		stats.FailuresByOperation[data.Operation] += data.Failed
 	}
 
 	// Add event to the list AFTER updating aggregates
 	stats.Events = append(stats.Events, event)
+}
 
-	return fs.save(stats)
+// prune drops the events older than the maximum age, then the oldest ones
+// beyond the maximum count. The aggregates, already updated, keep counting
+// them, and PrunedBefore records up to when the log is incomplete.
+func (fs *FileStore) prune(stats *Stats, now time.Time) {
+	// Events are in the order they were recorded
+	drop := 0
+	if fs.maxEventAge > 0 {
+		cutoff := now.Add(-fs.maxEventAge)
+		for drop < len(stats.Events) && stats.Events[drop].Timestamp.Before(cutoff) {
+			drop++
+		}
+	}
+	if fs.maxEvents > 0 && len(stats.Events)-drop > fs.maxEvents {
+		drop = len(stats.Events) - fs.maxEvents
+	}
+	if drop == 0 {
+		return
+	}
+
+	if last := stats.Events[drop-1].Timestamp; last.After(stats.PrunedBefore) {
+		stats.PrunedBefore = last
+	}
+	stats.Events = append([]TelemetryEvent(nil), stats.Events[drop:]...)
 }
 
 // updateAverageSize updates the running average for a profile type
@@ -137,9 +231,10 @@ func (fs *FileStore) updateAverageSize(stats *Stats, profileName string, size in
 		stats.AverageSizeByType = make(map[string]int64)
 	}
 
-	// Simple running average calculation
+	// Simple running average calculation, over the clean events counted
+	// rather than those left in the log, which may have been pruned
 	currentAvg := stats.AverageSizeByType[profileName]
-	count := fs.countCleanEventsByProfile(stats, profileName)
+	count := stats.CleansByType[profileName]
 
 	if count == 0 {
 		stats.AverageSizeByType[profileName] = size
@@ -147,21 +242,31 @@ func (fs *FileStore) updateAverageSize(stats *Stats, profileName string, size in
 		newAvg := ((currentAvg * int64(count)) + size) / int64(count+1)
 		stats.AverageSizeByType[profileName] = newAvg
 	}
+	stats.CleansByType[profileName] = count + 1
 }
 
 // countCleanEventsByProfile counts clean events for a specific profile
-func (fs *FileStore) countCleanEventsByProfile(stats *Stats, profileName string) int {
+func countCleanEventsByProfile(stats *Stats, profileName string) int {
 	count := 0
 	for _, event := range stats.Events {
-		if event.Type == "clean" {
-			if profile, ok := event.Data["profile"].(string); ok && profile == profileName {
-				count++
-			}
+		if data, ok := event.Data.(*CleanEvent); ok && data.Profile == profileName {
+			count++
 		}
 	}
 	return count
 }
 
+// cleanedByProfile sums the sizes of the clean events of a profile
+func cleanedByProfile(stats *Stats, profileName string) int64 {
+	var size int64
+	for _, event := range stats.Events {
+		if data, ok := event.Data.(*CleanEvent); ok && data.Profile == profileName {
+			size += data.Size
+		}
+	}
+	return size
+}
+
 // GetStats returns the current aggregated statistics
 func (fs *FileStore) GetStats() (*Stats, error) {
 	fs.mu.RLock()
@@ -194,11 +299,39 @@ func (fs *FileStore) load() (*Stats, error) {
 	if err := json.Unmarshal(data, &stats); err != nil {
 		return nil, fmt.Errorf("failed to parse telemetry file %s: %w", fs.filePath, err)
 	}
+	if err := checkVersion(stats.Version); err != nil {
+		return nil, fmt.Errorf("telemetry file %s: %w", fs.filePath, err)
+	}
+	// Earlier versions decode as the current one, events without IDs getting
+	// one from their content as they are decoded
+	stats.Version = SchemaVersion
 
 	// Initialize map if nil
 	if stats.AverageSizeByType == nil {
 		stats.AverageSizeByType = make(map[string]int64)
 	}
+	if stats.FailuresByOperation == nil {
+		stats.FailuresByOperation = make(map[string]int)
+	}
+	// Files written before the counts were stored have every event
+	if stats.CleansByType == nil {
+		stats.CleansByType = make(map[string]int)
+		for profileName := range stats.AverageSizeByType {
+			stats.CleansByType[profileName] = countCleanEventsByProfile(&stats, profileName)
+		}
+	}
+	// Files written before the totals were stored have them from their
+	// events, or, once events were pruned, from the averages
+	if stats.CleanedByType == nil {
+		stats.CleanedByType = make(map[string]int64)
+		for profileName, count := range stats.CleansByType {
+			if stats.PrunedBefore.IsZero() {
+				stats.CleanedByType[profileName] = cleanedByProfile(&stats, profileName)
+			} else {
+				stats.CleanedByType[profileName] = stats.AverageSizeByType[profileName] * int64(count)
+			}
+		}
+	}
 
 	return &stats, nil
 }
@@ -217,15 +350,239 @@ func (fs *FileStore) save(stats *Stats) error {
 	return nil
 }
 
+// Between returns the statistics of the events recorded at or after from and
+// before to, computed from the event log. A zero from or to leaves the window
+// open on that side. Unlike the lifetime aggregates, which Record updates as
+// events come, the averages are those of the events of the window. Events
+// pruned from the log are not counted, see Complete.
+func (s *Stats) Between(from, to time.Time) *Stats {
+	window := &Stats{
+		Version:             s.Version,
+		AverageSizeByType:   make(map[string]int64),
+		CleansByType:        make(map[string]int),
+		CleanedByType:       make(map[string]int64),
+		FailuresByOperation: make(map[string]int),
+		PrunedBefore:        s.PrunedBefore,
+		Events:              []TelemetryEvent{},
+	}
+	for _, event := range s.Events {
+		if (!from.IsZero() && event.Timestamp.Before(from)) || (!to.IsZero() && !event.Timestamp.Before(to)) {
+			continue
+		}
+		window.Events = append(window.Events, event)
+
+		switch data := event.Data.(type) {
+		case *ScanEvent:
+			window.TotalScans++
+			if event.Timestamp.After(window.LastScan) {
+				window.LastScan = event.Timestamp
+			}
+		case *CleanEvent:
+			window.TargetsCleaned += len(data.Targets)
+			window.TotalCleaned += data.Size
+			if data.Profile != "" {
+				window.AverageSizeByType[data.Profile] += data.Size
+				window.CleansByType[data.Profile]++
+				window.CleanedByType[data.Profile] += data.Size
+			}
+		case *RestoreEvent:
+			window.TotalRestores += data.Items
+		case *PurgeEvent:
+			window.TotalPurges += data.Items
+		case *ErrorEvent:
+			window.FailuresByOperation[data.Operation] += data.Failed
+		}
+	}
+	for profileName, count := range window.CleansByType {
+		window.AverageSizeByType[profileName] /= int64(count)
+	}
+	return window
+}
+
+// Complete reports whether the event log holds every event since from: it
+// does not when events that recent were pruned, so that Between undercounts
+// windows starting before PrunedBefore.
+func (s *Stats) Complete(from time.Time) bool {
+	return s.PrunedBefore.IsZero() || from.After(s.PrunedBefore)
+}
+
+// FailureRate returns the share of the items operation, "clean", "restore"
+// or "purge", failed for, and false when it was never performed. Cleans
+// recorded before their targets were counted are left out.
+func (s *Stats) FailureRate(operation string) (float64, bool) {
+	var succeeded int
+	switch operation {
+	case "clean":
+		succeeded = s.TargetsCleaned
+	case "restore":
+		succeeded = s.TotalRestores
+	case "purge":
+		succeeded = s.TotalPurges
+	}
+	failed := s.FailuresByOperation[operation]
+	if succeeded+failed == 0 {
+		return 0, false
+	}
+	return float64(failed) / float64(succeeded+failed), true
+}
+
+// csvHeader lists the columns written by WriteCSV
+var csvHeader = []string{"timestamp", "type", "profile", "size", "duration_seconds", "targets_found"}
+
+// WriteCSV writes the events of s to w as CSV, one row per event in the
+// order they were recorded, for charting the space cleaned over time in
+// other tools. Columns that do not apply to an event are empty.
+func (s *Stats) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, event := range s.Events {
+		record := []string{event.Timestamp.Format(time.RFC3339), event.Type, "", "", "", ""}
+		switch data := event.Data.(type) {
+		case *ScanEvent:
+			record[5] = strconv.Itoa(data.TargetsFound)
+		case *CleanEvent:
+			record[2] = data.Profile
+			record[3] = strconv.FormatInt(data.Size, 10)
+			record[4] = strconv.FormatFloat(data.Duration, 'f', -1, 64)
+		case *RestoreEvent:
+			record[3] = strconv.FormatInt(data.Size, 10)
+		case *PurgeEvent:
+			record[3] = strconv.FormatInt(data.Size, 10)
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// CleanedPerWeek returns the bytes cleaned in each of the weeks weeks
+// before end, oldest first, according to the events of s
+func (s *Stats) CleanedPerWeek(end time.Time, weeks int) []int64 {
+	const week = 7 * 24 * time.Hour
+	totals := make([]int64, weeks)
+	start := end.Add(-time.Duration(weeks) * week)
+	for _, event := range s.Events {
+		data, ok := event.Data.(*CleanEvent)
+		if !ok || event.Timestamp.Before(start) || !event.Timestamp.Before(end) {
+			continue
+		}
+		totals[int(event.Timestamp.Sub(start)/week)] += data.Size
+	}
+	return totals
+}
+
+// MeasureFreeSpace returns the free space of the filesystems containing
+// paths, once per filesystem, for the FreeSpace of an event. Paths whose
+// filesystem cannot be measured are left out.
+func MeasureFreeSpace(paths []string) []FreeSpaceMeasure {
+	seen := make(map[string]bool)
+	var measured []FreeSpaceMeasure
+	for _, path := range paths {
+		id, err := fsutils.FilesystemID(path)
+		if err != nil || seen[id] {
+			continue
+		}
+		free, err := fsutils.FreeSpace(path)
+		if err != nil {
+			continue
+		}
+		seen[id] = true
+		measured = append(measured, FreeSpaceMeasure{Path: path, Filesystem: id, Free: int64(free)})
+	}
+	return measured
+}
+
+// FreeSpaceSample is the free space of a filesystem at the time of an event
+type FreeSpaceSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Free      int64     `json:"free"`
+}
+
+// FreeSpaceTrend is the free space of a filesystem over the events of the
+// log
+type FreeSpaceTrend struct {
+	Filesystem string            `json:"filesystem"` // Identifier of the filesystem
+	Path       string            `json:"path"`       // Path it was last measured at
+	Samples    []FreeSpaceSample `json:"samples"`    // Measures, in the order they were recorded
+}
+
+// FreeSpaceTrends returns the free space of each filesystem measured by the
+// events of s, sorted by path
+func (s *Stats) FreeSpaceTrends() []FreeSpaceTrend {
+	trends := make(map[string]*FreeSpaceTrend)
+	for _, event := range s.Events {
+		var measures []FreeSpaceMeasure
+		switch data := event.Data.(type) {
+		case *ScanEvent:
+			measures = data.FreeSpace
+		case *CleanEvent:
+			measures = data.FreeSpace
+		}
+		for _, measure := range measures {
+			trend, ok := trends[measure.Filesystem]
+			if !ok {
+				trend = &FreeSpaceTrend{Filesystem: measure.Filesystem}
+				trends[measure.Filesystem] = trend
+			}
+			trend.Path = measure.Path
+			trend.Samples = append(trend.Samples, FreeSpaceSample{Timestamp: event.Timestamp, Free: measure.Free})
+		}
+	}
+
+	result := make([]FreeSpaceTrend, 0, len(trends))
+	for _, trend := range trends {
+		result = append(result, *trend)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// CleanRecord is a target cleaned, as recorded by a clean event
+type CleanRecord struct {
+	Timestamp time.Time `json:"timestamp"` // When it was cleaned
+	Path      string    `json:"path"`      // Its path, empty for events recorded before paths were
+	Profile   string    `json:"profile"`   // Profile it was detected by
+	Size      int64     `json:"size"`      // Bytes cleaned
+}
+
+// TopCleans returns the n largest targets cleaned according to the events of
+// s, largest first, or all of them when n is not positive. Clean events
+// recorded without their targets count as a single target of their size.
+func (s *Stats) TopCleans(n int) []CleanRecord {
+	var records []CleanRecord
+	for _, event := range s.Events {
+		data, ok := event.Data.(*CleanEvent)
+		if !ok {
+			continue
+		}
+		if data.Targets == nil {
+			records = append(records, CleanRecord{Timestamp: event.Timestamp, Profile: data.Profile, Size: data.Size})
+			continue
+		}
+		for _, target := range data.Targets {
+			records = append(records, CleanRecord{Timestamp: event.Timestamp, Path: target.Path, Profile: data.Profile, Size: target.Size})
+		}
+	}
+
+	// Ties keep the order they were recorded in
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Size > records[j].Size })
+	if n > 0 && len(records) > n {
+		records = records[:n]
+	}
+	return records
+}
+
 // GetDefaultStatsPath returns the default path for the stats file
 // Uses platform-specific paths (XDG on Linux, ~/Library on macOS, %LOCALAPPDATA% on Windows)
 func GetDefaultStatsPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	// Existing installs keep ~/.rosia/stats.json until it is migrated
+	location, err := fsutils.StatsLocation()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
-
-	// For backward compatibility, keep stats file in ~/.rosia
-	// In the future, this could use fsutils.GetStatsFilePath() for platform-specific paths
-	return filepath.Join(homeDir, ".rosia", "stats.json"), nil
+	return location.Path(), nil
 }
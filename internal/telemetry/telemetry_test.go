@@ -3,6 +3,7 @@ package telemetry
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -42,10 +43,7 @@ func TestFileStore_RecordScanEvent(t *testing.T) {
 	event := TelemetryEvent{
 		Type:      "scan",
 		Timestamp: now,
-		Data: map[string]interface{}{
-			"timestamp":     now,
-			"targets_found": 5,
-		},
+		Data:      &ScanEvent{TargetsFound: 5},
 	}
 
 	err = store.Record(event)
@@ -68,10 +66,7 @@ func TestFileStore_RecordCleanEvent(t *testing.T) {
 	event := TelemetryEvent{
 		Type:      "clean",
 		Timestamp: time.Now(),
-		Data: map[string]interface{}{
-			"size":    int64(1024000),
-			"profile": "node",
-		},
+		Data:      &CleanEvent{Size: 1024000, Profile: "node"},
 	}
 
 	err = store.Record(event)
@@ -94,10 +89,7 @@ func TestFileStore_MultipleCleanEvents(t *testing.T) {
 	event1 := TelemetryEvent{
 		Type:      "clean",
 		Timestamp: time.Now(),
-		Data: map[string]interface{}{
-			"size":    int64(1000),
-			"profile": "node",
-		},
+		Data:      &CleanEvent{Size: 1000, Profile: "node"},
 	}
 	err = store.Record(event1)
 	require.NoError(t, err)
@@ -106,10 +98,7 @@ func TestFileStore_MultipleCleanEvents(t *testing.T) {
 	event2 := TelemetryEvent{
 		Type:      "clean",
 		Timestamp: time.Now(),
-		Data: map[string]interface{}{
-			"size":    int64(2000),
-			"profile": "node",
-		},
+		Data:      &CleanEvent{Size: 2000, Profile: "node"},
 	}
 	err = store.Record(event2)
 	require.NoError(t, err)
@@ -119,6 +108,7 @@ func TestFileStore_MultipleCleanEvents(t *testing.T) {
 	assert.Equal(t, int64(3000), stats.TotalCleaned)
 	// Average should be (1000 + 2000) / 2 = 1500
 	assert.Equal(t, int64(1500), stats.AverageSizeByType["node"])
+	assert.Equal(t, int64(3000), stats.CleanedByType["node"])
 }
 
 func TestFileStore_Export(t *testing.T) {
@@ -131,9 +121,7 @@ func TestFileStore_Export(t *testing.T) {
 	event := TelemetryEvent{
 		Type:      "scan",
 		Timestamp: time.Now(),
-		Data: map[string]interface{}{
-			"targets_found": 3,
-		},
+		Data:      &ScanEvent{TargetsFound: 3},
 	}
 	err = store.Record(event)
 	require.NoError(t, err)
@@ -144,9 +132,315 @@ func TestFileStore_Export(t *testing.T) {
 	assert.Contains(t, string(data), "total_scans")
 }
 
+func TestFileStore_Prune(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+	store.SetLimits(0, 2)
+
+	now := time.Now()
+	for i, size := range []int64{1000, 2000, 3000, 6001} {
+		event := TelemetryEvent{
+			Type:      "clean",
+			Timestamp: now.Add(time.Duration(i-4) * time.Hour),
+			Data:      &CleanEvent{Size: size, Profile: "node"},
+		}
+		require.NoError(t, store.Record(event))
+	}
+
+	// Only the newest events are kept, but the aggregates count them all
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	require.Len(t, stats.Events, 2)
+	assert.Equal(t, int64(12001), stats.TotalCleaned)
+	assert.Equal(t, int64(3000), stats.AverageSizeByType["node"])
+	assert.Equal(t, 4, stats.CleansByType["node"])
+	assert.Equal(t, int64(12001), stats.CleanedByType["node"], "the total is exact, unlike the average")
+	assert.WithinDuration(t, now.Add(-3*time.Hour), stats.PrunedBefore, time.Second)
+
+	assert.False(t, stats.Complete(now.Add(-4*time.Hour)))
+	assert.True(t, stats.Complete(now.Add(-2*time.Hour)))
+	assert.True(t, (&Stats{}).Complete(time.Time{}))
+
+	// Events older than the maximum age are pruned
+	store.SetLimits(90*time.Minute, 0)
+	require.NoError(t, store.Record(TelemetryEvent{Type: "scan", Timestamp: now}))
+	stats, err = store.GetStats()
+	require.NoError(t, err)
+	require.Len(t, stats.Events, 2)
+	assert.Equal(t, "scan", stats.Events[1].Type)
+	assert.Equal(t, int64(12001), stats.TotalCleaned)
+	assert.Equal(t, int64(12001), stats.CleanedByType["node"])
+}
+
+func TestFileStore_LegacyCounts(t *testing.T) {
+	statsPath := filepath.Join(t.TempDir(), "stats.json")
+	// Files written before the counts were stored keep every event
+	require.NoError(t, os.WriteFile(statsPath, []byte(`{
+  "total_cleaned": 3000,
+  "average_size_by_type": {"node": 1500},
+  "events": [
+    {"type": "clean", "timestamp": "2025-04-28T14:30:00Z", "data": {"size": 1000, "profile": "node"}},
+    {"type": "clean", "timestamp": "2025-04-28T14:31:00Z", "data": {"size": 2000, "profile": "node"}}
+  ]
+}`), 0644))
+
+	store, err := NewFileStore(statsPath)
+	require.NoError(t, err)
+	require.NoError(t, store.Record(TelemetryEvent{Type: "clean", Timestamp: time.Now(), Data: &CleanEvent{Size: 4500, Profile: "node"}}))
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.CleansByType["node"])
+	assert.Equal(t, int64(2500), stats.AverageSizeByType["node"])
+	assert.Equal(t, int64(7500), stats.CleanedByType["node"])
+}
+
+func TestFileStore_LegacyTotals(t *testing.T) {
+	statsPath := filepath.Join(t.TempDir(), "stats.json")
+	// Version 1 files have the totals of their events, or of their averages
+	// once events were pruned
+	require.NoError(t, os.WriteFile(statsPath, []byte(`{
+  "version": 1,
+  "average_size_by_type": {"node": 1500, "rust": 2000},
+  "cleans_by_type": {"node": 2, "rust": 1},
+  "events": [
+    {"type": "clean", "timestamp": "2025-04-28T14:30:00Z", "data": {"size": 1000, "profile": "node"}},
+    {"type": "clean", "timestamp": "2025-04-28T14:31:00Z", "data": {"size": 2001, "profile": "node"}},
+    {"type": "clean", "timestamp": "2025-04-28T14:32:00Z", "data": {"size": 2000, "profile": "rust"}}
+  ]
+}`), 0644))
+	store, err := NewFileStore(statsPath)
+	require.NoError(t, err)
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"node": 3001, "rust": 2000}, stats.CleanedByType)
+	assert.Equal(t, SchemaVersion, stats.Version)
+
+	pruned := []byte(`{
+  "version": 1,
+  "pruned_before": "2025-04-28T14:00:00Z",
+  "average_size_by_type": {"node": 1500},
+  "cleans_by_type": {"node": 2},
+  "events": []
+}`)
+	require.NoError(t, os.WriteFile(statsPath, pruned, 0644))
+	stats, err = store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"node": 3000}, stats.CleanedByType)
+}
+
+func TestStats_Between(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	weekAgo := now.AddDate(0, 0, -7)
+	for _, event := range []TelemetryEvent{
+		{Type: "scan", Timestamp: now.AddDate(0, 0, -30)},
+		{Type: "clean", Timestamp: now.AddDate(0, 0, -30), Data: &CleanEvent{Size: 8000, Profile: "node"}},
+		{Type: "scan", Timestamp: now.Add(-time.Hour)},
+		{Type: "clean", Timestamp: now.Add(-time.Hour), Data: &CleanEvent{Size: 1000, Profile: "node"}},
+		{Type: "clean", Timestamp: now.Add(-time.Hour), Data: &CleanEvent{Size: 3000, Profile: "node"}},
+		{Type: "clean", Timestamp: now.Add(-time.Hour), Data: &CleanEvent{Size: 500, Profile: "rust"}},
+	} {
+		require.NoError(t, store.Record(event))
+	}
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+
+	week := stats.Between(weekAgo, time.Time{})
+	assert.Equal(t, 1, week.TotalScans)
+	assert.Equal(t, int64(4500), week.TotalCleaned)
+	assert.Equal(t, map[string]int64{"node": 2000, "rust": 500}, week.AverageSizeByType)
+	assert.Equal(t, map[string]int64{"node": 4000, "rust": 500}, week.CleanedByType)
+	assert.True(t, week.LastScan.Equal(now.Add(-time.Hour)))
+	assert.Len(t, week.Events, 4)
+
+	before := stats.Between(time.Time{}, weekAgo)
+	assert.Equal(t, 1, before.TotalScans)
+	assert.Equal(t, int64(8000), before.TotalCleaned)
+	assert.Equal(t, map[string]int64{"node": 8000}, before.AverageSizeByType)
+
+	all := stats.Between(time.Time{}, time.Time{})
+	assert.Equal(t, stats.TotalScans, all.TotalScans)
+	assert.Equal(t, stats.TotalCleaned, all.TotalCleaned)
+
+	empty := stats.Between(now, time.Time{})
+	assert.Zero(t, empty.TotalScans)
+	assert.True(t, empty.LastScan.IsZero())
+	assert.Empty(t, empty.AverageSizeByType)
+}
+
+func TestStats_CleanedPerWeek(t *testing.T) {
+	end := time.Date(2025, 4, 28, 0, 0, 0, 0, time.UTC)
+	clean := func(at time.Time, size int64) TelemetryEvent {
+		return TelemetryEvent{Type: "clean", Timestamp: at, Data: &CleanEvent{Size: size, Profile: "node"}}
+	}
+	stats := &Stats{Events: []TelemetryEvent{
+		clean(end.AddDate(0, 0, -30), 8000), // Before the weeks
+		clean(end.AddDate(0, 0, -21), 1000), // Start of the first week
+		clean(end.AddDate(0, 0, -1), 2000),
+		clean(end.Add(-time.Hour), 500),
+		{Type: "scan", Timestamp: end.Add(-time.Hour)},
+		clean(end, 4000), // Past the end
+	}}
+
+	assert.Equal(t, []int64{1000, 0, 2500}, stats.CleanedPerWeek(end, 3))
+	assert.Empty(t, stats.CleanedPerWeek(end, 0))
+}
+
+func TestMeasureFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.Mkdir(sub, 0755))
+
+	// Paths of the same filesystem are measured once, missing ones skipped
+	measured := MeasureFreeSpace([]string{dir, sub, filepath.Join(dir, "missing")})
+	require.Len(t, measured, 1)
+	assert.Equal(t, dir, measured[0].Path)
+	assert.NotEmpty(t, measured[0].Filesystem)
+	assert.Positive(t, measured[0].Free)
+}
+
+func TestStats_FreeSpaceTrends(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	for _, event := range []TelemetryEvent{
+		{Type: "scan", Timestamp: now, Data: &ScanEvent{FreeSpace: []FreeSpaceMeasure{
+			{Path: "/home/u/a", Filesystem: "1", Free: 5000},
+			{Path: "/mnt/b", Filesystem: "2", Free: 100},
+		}}},
+		{Type: "clean", Timestamp: now.Add(time.Minute), Data: &CleanEvent{Size: 1000}},
+		{Type: "clean", Timestamp: now.Add(2 * time.Minute), Data: &CleanEvent{Size: 1000, FreeSpace: []FreeSpaceMeasure{
+			{Path: "/home/u/c", Filesystem: "1", Free: 6000},
+		}}},
+	} {
+		require.NoError(t, store.Record(event))
+	}
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	trends := stats.FreeSpaceTrends()
+	require.Len(t, trends, 2)
+
+	// Filesystems are named by the path they were last measured at
+	assert.Equal(t, "1", trends[0].Filesystem)
+	assert.Equal(t, "/home/u/c", trends[0].Path)
+	require.Len(t, trends[0].Samples, 2)
+	assert.Equal(t, int64(5000), trends[0].Samples[0].Free)
+	assert.Equal(t, int64(6000), trends[0].Samples[1].Free)
+	assert.True(t, trends[0].Samples[1].Timestamp.Equal(now.Add(2*time.Minute)))
+	assert.Equal(t, "/mnt/b", trends[1].Path)
+}
+
+func TestStats_TopCleans(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	for _, event := range []TelemetryEvent{
+		{Type: "scan", Timestamp: now, Data: &ScanEvent{TargetsFound: 3}},
+		// Recorded before targets were
+		{Type: "clean", Timestamp: now, Data: &CleanEvent{Size: 2500, Profile: "rust"}},
+		{Type: "clean", Timestamp: now.Add(time.Minute), Data: &CleanEvent{
+			Size:    4000,
+			Profile: "node",
+			Targets: []CleanedTarget{
+				{Path: "/p/a/node_modules", Size: 1000},
+				{Path: "/p/b/node_modules", Size: 3000},
+			},
+		}},
+	} {
+		require.NoError(t, store.Record(event))
+	}
+
+	// Targets are read back from the file
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+
+	top := stats.TopCleans(2)
+	require.Len(t, top, 2)
+	assert.Equal(t, "/p/b/node_modules", top[0].Path)
+	assert.Equal(t, "node", top[0].Profile)
+	assert.Equal(t, int64(3000), top[0].Size)
+	assert.True(t, top[0].Timestamp.Equal(now.Add(time.Minute)))
+	assert.Equal(t, CleanRecord{Timestamp: top[1].Timestamp, Profile: "rust", Size: 2500}, top[1])
+
+	assert.Len(t, stats.TopCleans(0), 3)
+	assert.Empty(t, (&Stats{}).TopCleans(10))
+}
+
+func TestStats_WriteCSV(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+
+	store.SetLimits(0, 0)
+
+	at := time.Date(2025, 4, 28, 14, 30, 0, 0, time.UTC)
+	require.NoError(t, store.Record(TelemetryEvent{Type: "scan", Timestamp: at, Data: &ScanEvent{TargetsFound: 3}}))
+	require.NoError(t, store.Record(TelemetryEvent{Type: "clean", Timestamp: at.Add(time.Minute), Data: &CleanEvent{Size: 1024, Profile: "node,js", Duration: 1.5}}))
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	var b strings.Builder
+	require.NoError(t, stats.WriteCSV(&b))
+	assert.Equal(t, `timestamp,type,profile,size,duration_seconds,targets_found
+2025-04-28T14:30:00Z,scan,,,,3
+2025-04-28T14:31:00Z,clean,"node,js",1024,1.5,
+`, b.String())
+}
+
 func TestGetDefaultStatsPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
 	path, err := GetDefaultStatsPath()
 	require.NoError(t, err)
-	assert.Contains(t, path, ".rosia")
-	assert.Contains(t, path, "stats.json")
+	assert.Equal(t, "stats.json", filepath.Base(path))
+
+	// Existing installs keep their statistics until they are migrated
+	legacy := filepath.Join(home, ".rosia", "stats.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(legacy), 0755))
+	require.NoError(t, os.WriteFile(legacy, []byte("{}"), 0644))
+	path, err = GetDefaultStatsPath()
+	require.NoError(t, err)
+	assert.Equal(t, legacy, path)
+}
+
+func TestStats_RestoresPurgesAndFailures(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+
+	now := time.Now()
+	for _, event := range []TelemetryEvent{
+		{Type: "restore", Timestamp: now.Add(-2 * time.Hour), Data: &RestoreEvent{Items: 3, Size: 300}},
+		{Type: "error", Timestamp: now.Add(-2 * time.Hour), Data: &ErrorEvent{Operation: "restore", Failed: 1}},
+		{Type: "purge", Timestamp: now, Data: &PurgeEvent{Items: 4, Size: 400}},
+	} {
+		require.NoError(t, store.Record(event))
+	}
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.TotalRestores)
+	assert.Equal(t, 4, stats.TotalPurges)
+	assert.Equal(t, int64(0), stats.TotalCleaned, "restores and purges clean nothing")
+
+	rate, ok := stats.FailureRate("restore")
+	assert.True(t, ok)
+	assert.Equal(t, 0.25, rate)
+	rate, ok = stats.FailureRate("purge")
+	assert.True(t, ok)
+	assert.Zero(t, rate)
+	_, ok = stats.FailureRate("clean")
+	assert.False(t, ok, "no clean was performed")
+
+	// The statistics of a period count its events alone
+	window := stats.Between(now.Add(-time.Hour), time.Time{})
+	assert.Zero(t, window.TotalRestores)
+	assert.Equal(t, 4, window.TotalPurges)
+	assert.Empty(t, window.FailuresByOperation)
 }
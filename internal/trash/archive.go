@@ -0,0 +1,252 @@
+package trash
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveName is the file holding the content of compressed trash items
+const archiveName = "content.tar.zst"
+
+// writeArchive stores the directory tree at src as a zstd-compressed tar
+// archive at dst. Paths in the archive are relative to src.
+func writeArchive(src, dst string) (err error) {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	zw, err := zstd.NewWriter(f)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+
+	if err := writeTree(tw, src, ""); err != nil {
+		zw.Close()
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// writeTree adds the entries below src to tw, named by their path relative
+// to src under prefix. src itself is added as prefix when prefix is set.
+func writeTree(tw *tar.Writer, src, prefix string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(prefix, rel))
+		if name == "." {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if xattrs, err := listXattrs(path); err == nil && len(xattrs) > 0 {
+			header.PAXRecords = make(map[string]string, len(xattrs))
+			for xattr, value := range xattrs {
+				header.PAXRecords[paxXattrPrefix+xattr] = string(value)
+			}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+}
+
+// extractArchive unpacks the archive at src, written by writeArchive, into
+// the directory dst, which must not exist yet
+func extractArchive(src, dst string) error {
+	if err := os.Mkdir(dst, 0755); err != nil {
+		return err
+	}
+	return extractEntries(src, dst, nil)
+}
+
+// extractEntries unpacks the entries of the archive at src for which match
+// returns true (all entries when match is nil) into the existing directory
+// dst. Missing parent directories of matched entries are created.
+func extractEntries(src, dst string, match func(name string) bool) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return extractStream(f, dst, match)
+}
+
+// extractStream is extractEntries for an archive read from r
+func extractStream(r io.Reader, dst string, match func(name string) bool) error {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	type dirTime struct {
+		path   string
+		header *tar.Header
+	}
+	var dirs []dirTime
+	var links []string
+
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// Refuse entries that would escape dst
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path in trash archive: %s", header.Name)
+		}
+		if match != nil && !match(name) {
+			continue
+		}
+		// Refuse entries below a symlink from the archive, which could
+		// point anywhere
+		for _, link := range links {
+			if rel, err := filepath.Rel(link, name); err == nil && filepath.IsLocal(rel) {
+				return fmt.Errorf("invalid path in trash archive: %s is below a symlink", header.Name)
+			}
+		}
+		path := filepath.Join(dst, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirTime{path, header})
+
+		case tar.TypeReg:
+			if err := extractFile(tr, path, header.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+			if err := applyHeader(path, header); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, path); err != nil {
+				return err
+			}
+			applyHeader(path, header)
+			links = append(links, name)
+
+		default:
+			return fmt.Errorf("unsupported entry in trash archive: %s", header.Name)
+		}
+	}
+
+	// Permissions and times are applied last so read-only directories and
+	// the files written into them don't get in the way
+	for i := len(dirs) - 1; i >= 0; i-- {
+		applyHeader(dirs[i].path, dirs[i].header)
+	}
+
+	return nil
+}
+
+// archiveEntries lists the entries in the archive at src
+func archiveEntries(src string) ([]itemEntry, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var entries []itemEntry
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, itemEntry{
+			name: filepath.FromSlash(header.Name),
+			dir:  header.Typeflag == tar.TypeDir,
+		})
+	}
+}
+
+// extractFile writes the current archive entry to path
+func extractFile(r io.Reader, path string, perm fs.FileMode) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = io.Copy(f, r)
+	return err
+}
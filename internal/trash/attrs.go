@@ -0,0 +1,136 @@
+package trash
+
+import (
+	"archive/tar"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// modeBits are the bits of a file mode that chmod applies
+const modeBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// paxXattrPrefix prefixes extended attributes in tar PAX records, as
+// written by GNU tar and bsdtar
+const paxXattrPrefix = "SCHILY.xattr."
+
+// captureAttributes returns the mode, ownership, link target and extended
+// attributes of the file at path
+func captureAttributes(path string) (*types.FileAttributes, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := &types.FileAttributes{Mode: info.Mode(), UID: -1, GID: -1}
+	if uid, gid, ok := owner(info); ok {
+		attrs.UID, attrs.GID = uid, gid
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if attrs.LinkTarget, err = os.Readlink(path); err != nil {
+			return nil, err
+		}
+	}
+	// Filesystems without extended attributes simply have none
+	attrs.Xattrs, _ = listXattrs(path)
+
+	return attrs, nil
+}
+
+// applyAttributes gives the file at path the attributes in attrs. Ownership
+// can only be given away by root and some extended attributes are reserved
+// to the system, so those are kept as they are when refused.
+func applyAttributes(path string, attrs *types.FileAttributes) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if attrs.LinkTarget != "" && info.Mode()&fs.ModeSymlink != 0 {
+		if target, err := os.Readlink(path); err == nil && target != attrs.LinkTarget {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			if err := os.Symlink(attrs.LinkTarget, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	setOwner(path, info, attrs.UID, attrs.GID)
+	setXattrs(path, attrs.Xattrs)
+
+	// Changing the owner clears setuid and setgid, so the mode comes last
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return os.Chmod(path, attrs.Mode&modeBits)
+	}
+	return nil
+}
+
+// copyMetadata gives dst, a copy of src described by info, the ownership,
+// extended attributes, mode and modification time of src
+func copyMetadata(src, dst string, info fs.FileInfo) error {
+	if uid, gid, ok := owner(info); ok {
+		if dstInfo, err := os.Lstat(dst); err == nil {
+			setOwner(dst, dstInfo, uid, gid)
+		}
+	}
+	if xattrs, err := listXattrs(src); err == nil {
+		setXattrs(dst, xattrs)
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+	if err := os.Chmod(dst, info.Mode()&modeBits); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// setOwner changes the owner of the file at path, described by info, to
+// uid and gid when they are known and differ, ignoring refusals
+func setOwner(path string, info fs.FileInfo, uid, gid int) {
+	if uid < 0 || gid < 0 {
+		return
+	}
+	if curUID, curGID, ok := owner(info); ok && curUID == uid && curGID == gid {
+		return
+	}
+	os.Lchown(path, uid, gid)
+}
+
+// headerXattrs returns the extended attributes recorded in an archive header
+func headerXattrs(header *tar.Header) map[string][]byte {
+	var xattrs map[string][]byte
+	for key, value := range header.PAXRecords {
+		if name, ok := strings.CutPrefix(key, paxXattrPrefix); ok {
+			if xattrs == nil {
+				xattrs = map[string][]byte{}
+			}
+			xattrs[name] = []byte(value)
+		}
+	}
+	return xattrs
+}
+
+// applyHeader gives the file extracted at path the ownership, extended
+// attributes, mode and modification time recorded in header
+func applyHeader(path string, header *tar.Header) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	setOwner(path, info, header.Uid, header.Gid)
+	setXattrs(path, headerXattrs(header))
+
+	if header.Typeflag == tar.TypeSymlink {
+		return nil
+	}
+	if err := os.Chmod(path, header.FileInfo().Mode()&modeBits); err != nil {
+		return err
+	}
+	return os.Chtimes(path, header.AccessTime, header.ModTime)
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package trash
+
+import "io/fs"
+
+// owner is unavailable on this platform
+func owner(info fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// listXattrs is unavailable on this platform
+func listXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+// setXattrs is a no-op on this platform
+func setXattrs(path string, xattrs map[string][]byte) {}
@@ -0,0 +1,60 @@
+//go:build linux || darwin
+
+package trash
+
+import (
+	"bytes"
+	"io/fs"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// owner returns the user and group owning the file described by info
+func owner(info fs.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
+
+// listXattrs returns the extended attributes of the file at path, without
+// following symlinks
+func listXattrs(path string) (map[string][]byte, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	names := make([]byte, size)
+	size, err = unix.Llistxattr(path, names)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := map[string][]byte{}
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		valueSize, err := unix.Lgetxattr(path, string(name), nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, valueSize)
+		valueSize, err = unix.Lgetxattr(path, string(name), value)
+		if err != nil {
+			continue
+		}
+		xattrs[string(name)] = value[:valueSize]
+	}
+	return xattrs, nil
+}
+
+// setXattrs sets the extended attributes xattrs on the file at path,
+// skipping those the filesystem or the current user cannot set
+func setXattrs(path string, xattrs map[string][]byte) {
+	for name, value := range xattrs {
+		unix.Lsetxattr(path, name, value, 0)
+	}
+}
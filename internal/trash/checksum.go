@@ -0,0 +1,113 @@
+package trash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// checksumPrefix names the algorithm of the checksums written by checksum
+const checksumPrefix = "sha256:"
+
+// checksum returns a digest of the file or directory tree at path.
+//
+// Every entry contributes its relative path, its type and the hash of its
+// content (or link target), so modified, renamed, missing and added files
+// all change the digest.
+func checksum(path string) (string, error) {
+	tree := sha256.New()
+
+	err := filepath.WalkDir(path, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case entry.IsDir():
+			fmt.Fprintf(tree, "d %s\n", rel)
+
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(tree, "l %s %s\n", rel, link)
+
+		case entry.Type().IsRegular():
+			sum, err := fileChecksum(p)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(tree, "f %s %s\n", rel, sum)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return checksumPrefix + hex.EncodeToString(tree.Sum(nil)), nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 of the file at path
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// contentPath returns where the content of an item is stored
+func (s *System) contentPath(id string, metadata *types.TrashMetadata) string {
+	if metadata.Compressed {
+		return filepath.Join(s.trashDir, id, archiveName)
+	}
+	return filepath.Join(s.trashDir, id, "content")
+}
+
+// Verify checks the content of item id against the checksum recorded when it
+// was trashed. It returns types.ErrChecksumMismatch when the content changed.
+// Items trashed before checksums were recorded always pass.
+func (s *System) Verify(id string) error {
+	metadata, err := s.GetMetadata(id)
+	if err != nil {
+		return fmt.Errorf("failed to get metadata for trash item %s: %w", id, err)
+	}
+	return s.verify(id, metadata)
+}
+
+func (s *System) verify(id string, metadata *types.TrashMetadata) error {
+	if metadata.Checksum == "" {
+		return nil
+	}
+
+	actual, err := checksum(s.contentPath(id, metadata))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return types.ErrChecksumMismatch{ID: id, Expected: metadata.Checksum}
+		}
+		return fmt.Errorf("failed to verify trash item %s: %w", id, err)
+	}
+	if actual != metadata.Checksum {
+		return types.ErrChecksumMismatch{ID: id, Expected: metadata.Checksum, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package trash
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// moveTree renames src to dst, copying and then deleting src when they are
+// on different filesystems
+func moveTree(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		// Leave no partial copy behind; src is still intact
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// mergeTree moves the entries of directory src into the existing directory
+// dst. Directories present in both are merged recursively; any other entry
+// in dst with the same name is moved out of the way by replace first.
+func mergeTree(src, dst string, replace func(path string) error) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		from := filepath.Join(src, entry.Name())
+		to := filepath.Join(dst, entry.Name())
+
+		existing, err := os.Lstat(to)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			if err := moveTree(from, to); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if entry.IsDir() && existing.IsDir() {
+			if err := mergeTree(from, to, replace); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := replace(to); err != nil {
+			return err
+		}
+		if err := moveTree(from, to); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyTree copies the file or directory tree at src to dst, preserving
+// permissions, ownership where allowed, extended attributes, modification
+// times and symlinks
+func copyTree(src, dst string) error {
+	// Directories stay writable until their content is copied
+	var dirs, srcDirs []string
+	var modes []fs.FileInfo
+
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(target, info.Mode().Perm()|0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, target)
+			srcDirs = append(srcDirs, path)
+			modes = append(modes, info)
+			return nil
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+			return copyMetadata(path, target, info)
+		case info.Mode().IsRegular():
+			if err := copyFile(path, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return copyMetadata(path, target, info)
+		default:
+			// Devices, sockets and pipes are not build artifacts
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		copyMetadata(srcDirs[i], dirs[i], modes[i])
+	}
+	return nil
+}
+
+// copyFile copies the regular file src to dst with the given permissions
+func copyFile(src, dst string, perm fs.FileMode) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,280 @@
+package trash
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// storeName is the hidden directory holding the contents shared by
+	// deduplicated items, one hard link per distinct file
+	storeName = ".store"
+	// refsName lists, inside an item, the store entries its files link to
+	refsName = "refs"
+)
+
+// SetDeduplication enables hard-linking identical files of uncompressed items
+// to a shared store. It is ignored where hard link counts are not available.
+func (s *System) SetDeduplication(enabled bool) {
+	s.dedup = enabled && dedupSupported
+}
+
+// storeDir returns the directory of the content store
+func (s *System) storeDir() string {
+	return filepath.Join(s.trashDir, storeName)
+}
+
+// storePath returns the store entry for key
+func (s *System) storePath(key string) string {
+	return filepath.Join(s.storeDir(), key[:2], key)
+}
+
+// dedupe links the regular files of the item in itemDir to the store,
+// replacing files whose content is already stored, and records the entries
+// used in the item's refs file. Files that cannot be linked are kept as they
+// are; an error means the store cannot be used at all.
+func (s *System) dedupe(itemDir string) error {
+	contentPath := filepath.Join(itemDir, "content")
+	refs := map[string]string{}
+
+	err := filepath.WalkDir(contentPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			return nil
+		}
+		// Links share their attributes, which would leak to the other copies
+		if xattrs, _ := listXattrs(path); len(xattrs) > 0 {
+			return nil
+		}
+
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%s-%04o", sum, info.Mode()&modeBits)
+		if uid, gid, ok := owner(info); ok {
+			key += fmt.Sprintf("-%d-%d", uid, gid)
+		}
+		stored := s.storePath(key)
+
+		if err := os.MkdirAll(filepath.Dir(stored), 0755); err != nil {
+			return err
+		}
+		if err := os.Link(path, stored); err != nil {
+			// Files of other users, e.g. created by Docker, cannot be linked
+			if os.IsPermission(err) {
+				return nil
+			}
+			if !os.IsExist(err) {
+				return err
+			}
+			if !s.replaceWithLink(path, info, stored) {
+				return nil
+			}
+		}
+
+		if _, ok := refs[key]; !ok {
+			rel, err := filepath.Rel(contentPath, path)
+			if err != nil {
+				return err
+			}
+			refs[key] = filepath.ToSlash(rel)
+		}
+		return nil
+	})
+
+	// Record whatever was linked, even on error, so the entries are released
+	// with the item
+	if len(refs) > 0 {
+		if writeErr := writeRefs(itemDir, refs); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return err
+}
+
+// replaceWithLink replaces the file at path, described by info, with a hard
+// link to the store entry stored and reports whether path now uses the entry
+func (s *System) replaceWithLink(path string, info fs.FileInfo, stored string) bool {
+	storedInfo, err := os.Lstat(stored)
+	if err != nil {
+		return false
+	}
+	if os.SameFile(info, storedInfo) {
+		return true
+	}
+
+	// Link beside the file, then rename over it, so the file is never missing
+	tmpPath := path + ".dedup"
+	if err := os.Link(stored, tmpPath); err != nil {
+		return false
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return false
+	}
+	return true
+}
+
+// detach makes the files of the item in itemDir independent of the store and
+// of other items, so they can be restored and modified safely. Files shared
+// with other items are copied; store entries used only by this item are
+// removed.
+func (s *System) detach(itemDir string) error {
+	refs, err := readRefs(itemDir)
+	if err != nil || len(refs) == 0 {
+		return err
+	}
+	contentPath := filepath.Join(itemDir, "content")
+
+	// Decide from the link counts before any copy changes them, so that
+	// several files of this item linked to the same entry are all copied
+	var shared []string
+	err = filepath.WalkDir(contentPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		// One link from the store and one from this file is not sharing
+		if links, ok := linkCount(info); ok && links > 2 {
+			shared = append(shared, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range shared {
+		if err := unlinkFile(path); err != nil {
+			return fmt.Errorf("failed to copy shared file %s: %w", path, err)
+		}
+	}
+
+	for key, rel := range refs {
+		stored := s.storePath(key)
+		storedInfo, err := os.Lstat(stored)
+		if err != nil {
+			continue
+		}
+		links, ok := linkCount(storedInfo)
+		if !ok {
+			continue
+		}
+		if links == 1 {
+			os.Remove(stored)
+			continue
+		}
+		// The only other link is this item's own file
+		if info, err := os.Lstat(filepath.Join(contentPath, filepath.FromSlash(rel))); err == nil && links == 2 && os.SameFile(info, storedInfo) {
+			os.Remove(stored)
+		}
+	}
+
+	return os.Remove(filepath.Join(itemDir, refsName))
+}
+
+// release removes the store entries listed in refs that no item links to
+// anymore, once the item using them is gone
+func (s *System) release(refs map[string]string) {
+	for key := range refs {
+		stored := s.storePath(key)
+		info, err := os.Lstat(stored)
+		if err != nil {
+			continue
+		}
+		if links, ok := linkCount(info); ok && links == 1 {
+			os.Remove(stored)
+		}
+	}
+}
+
+// removeItem deletes the item in itemDir and releases its store entries
+func (s *System) removeItem(itemDir string) error {
+	refs, _ := readRefs(itemDir)
+	if err := os.RemoveAll(itemDir); err != nil {
+		return err
+	}
+	s.release(refs)
+	return nil
+}
+
+// unlinkFile replaces the file at path with a copy of itself, breaking any
+// hard links to it
+func unlinkFile(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".detach"
+	if err := copyFile(path, tmpPath, info.Mode().Perm()); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := copyMetadata(path, tmpPath, info); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// writeRefs writes refs, mapping store keys to the first file of the item
+// linked to them, as the refs file of itemDir
+func writeRefs(itemDir string, refs map[string]string) error {
+	var b strings.Builder
+	for key, rel := range refs {
+		fmt.Fprintf(&b, "%s\t%s\n", key, rel)
+	}
+	if err := os.WriteFile(filepath.Join(itemDir, refsName), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write store references: %w", err)
+	}
+	return nil
+}
+
+// readRefs reads the refs file of itemDir. Items that are not deduplicated
+// have none.
+func readRefs(itemDir string) (map[string]string, error) {
+	f, err := os.Open(filepath.Join(itemDir, refsName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	refs := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, rel, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || len(key) < 2 || strings.ContainsAny(key, `/\`) {
+			continue
+		}
+		refs[key] = rel
+	}
+	return refs, scanner.Err()
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package trash
+
+import "io/fs"
+
+// dedupSupported reports whether link counts are available for deduplication
+const dedupSupported = false
+
+// linkCount is unavailable on this platform
+func linkCount(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// fileID is unavailable on this platform
+func fileID(info fs.FileInfo) ([2]uint64, bool) {
+	return [2]uint64{}, false
+}
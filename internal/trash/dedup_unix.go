@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+package trash
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// dedupSupported reports whether link counts are available for deduplication
+const dedupSupported = true
+
+// linkCount returns the number of hard links to the file described by info
+func linkCount(info fs.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Nlink), true
+}
+
+// fileID returns an identifier shared by all hard links to the file
+// described by info
+func fileID(info fs.FileInfo) ([2]uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return [2]uint64{}, false
+	}
+	return [2]uint64{uint64(stat.Dev), uint64(stat.Ino)}, true
+}
@@ -0,0 +1,167 @@
+package trash
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// Export writes the items ids, with their content and metadata, to w as a
+// single zstd-compressed tar archive that Import can read on another machine.
+// Every item is stored under its ID, laid out as in the trash directory.
+func (s *System) Export(ids []string, w io.Writer) error {
+	// Items cannot be restored or purged while they are being exported
+	unlock, err := s.lock(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, id := range ids {
+		if _, err := s.GetMetadata(id); err != nil {
+			return fmt.Errorf("failed to get metadata for trash item %s: %w", id, err)
+		}
+	}
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+
+	for _, id := range ids {
+		if err := writeTree(tw, filepath.Join(s.trashDir, id), id); err != nil {
+			zw.Close()
+			return fmt.Errorf("failed to export trash item %s: %w", id, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// Import adds the items of an archive written by Export to the trash and
+// returns their IDs. Items whose ID is already taken get a numeric suffix.
+// Nothing is added unless every item in the archive is valid.
+func (s *System) Import(r io.Reader) ([]string, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Unpack next to the items so they can be renamed into place
+	staging, err := os.MkdirTemp(s.trashDir, ".import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := extractStream(r, staging, nil); err != nil {
+		return nil, fmt.Errorf("failed to read trash export: %w", err)
+	}
+
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash export: %w", err)
+	}
+
+	staged := make(map[string]*types.TrashMetadata, len(entries))
+	for _, entry := range entries {
+		metadata, err := stagedMetadata(staging, entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trash export: %w", err)
+		}
+		staged[entry.Name()] = metadata
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		id, err := s.importItem(filepath.Join(staging, name), name, staged[name])
+		if err != nil {
+			return ids, fmt.Errorf("failed to import trash item %s: %w", name, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// stagedMetadata checks that entry of the staging directory is a complete
+// trash item and returns its metadata
+func stagedMetadata(staging string, entry os.DirEntry) (*types.TrashMetadata, error) {
+	if !entry.IsDir() {
+		return nil, fmt.Errorf("unexpected file %s", entry.Name())
+	}
+	itemDir := filepath.Join(staging, entry.Name())
+
+	data, err := os.ReadFile(filepath.Join(itemDir, "metadata.json"))
+	if err != nil {
+		return nil, fmt.Errorf("item %s has no metadata", entry.Name())
+	}
+	var metadata types.TrashMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata of item %s: %w", entry.Name(), err)
+	}
+
+	content := "content"
+	if metadata.Compressed {
+		content = archiveName
+	}
+	if _, err := os.Lstat(filepath.Join(itemDir, content)); err != nil {
+		return nil, fmt.Errorf("item %s has no content", entry.Name())
+	}
+
+	return &metadata, nil
+}
+
+// importItem moves the staged item at src into the trash under its exported
+// ID, or a unique variant of it, and returns the ID used
+func (s *System) importItem(src, exportedID string, metadata *types.TrashMetadata) (string, error) {
+	id, err := s.createItemDir(exportedID)
+	if err != nil {
+		return "", err
+	}
+	itemDir := filepath.Join(s.trashDir, id)
+
+	content := "content"
+	if metadata.Compressed {
+		content = archiveName
+	}
+	if err := moveTree(filepath.Join(src, content), filepath.Join(itemDir, content)); err != nil {
+		os.RemoveAll(itemDir)
+		return "", err
+	}
+
+	// Items migrated from a deduplicated trash may still share files, which
+	// must be tracked by this store too
+	_, refsErr := os.Lstat(filepath.Join(src, refsName))
+	if !metadata.Compressed && (s.dedup || refsErr == nil) {
+		if err := s.dedupe(itemDir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to deduplicate trash item %s: %v\n", id, err)
+		}
+	}
+
+	metadata.ID = id
+	if err := writeMetadata(itemDir, metadata); err != nil {
+		os.RemoveAll(itemDir)
+		return "", err
+	}
+
+	// The content may have been damaged in transit
+	if err := s.verify(id, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	return id, nil
+}
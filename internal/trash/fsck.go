@@ -0,0 +1,232 @@
+package trash
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// quarantineName is the hidden directory Fsck moves broken items to
+const quarantineName = ".quarantine"
+
+// FsckAction is what Fsck does about a problem
+type FsckAction int
+
+const (
+	// FsckRepaired means the item was fixed in place and can be restored
+	FsckRepaired FsckAction = iota
+	// FsckQuarantined means the item was moved out of the trash listing,
+	// into the quarantine directory, for manual inspection
+	FsckQuarantined
+	// FsckReclaimed means leftover data was deleted to free its space
+	FsckReclaimed
+)
+
+// String returns the action as a past-tense verb
+func (a FsckAction) String() string {
+	switch a {
+	case FsckRepaired:
+		return "repaired"
+	case FsckQuarantined:
+		return "quarantined"
+	case FsckReclaimed:
+		return "reclaimed"
+	default:
+		return "unknown"
+	}
+}
+
+// FsckProblem is an inconsistency found by Fsck
+type FsckProblem struct {
+	Path   string     // Entry of the trash directory the problem is in
+	Issue  string     // What is wrong
+	Action FsckAction // What was done, or would be done in a dry run
+	Size   int64      // Bytes freed by a reclaim
+	Err    error      // Why the action failed, or nil
+}
+
+// FsckReport lists the problems found by Fsck
+type FsckReport struct {
+	Problems  []FsckProblem
+	Reclaimed int64 // Bytes freed, or that would be freed in a dry run
+}
+
+// Fsck checks the trash directory for entries List cannot use and fixes them.
+//
+// Metadata left as a temporary file by an interrupted write is put back,
+// and metadata disagreeing with the stored content is corrected. Items with
+// unreadable metadata or without content are quarantined in a hidden
+// directory of the trash, where they no longer show up but can still be
+// inspected. Content directories without metadata, leftovers of interrupted
+// operations and store entries no item uses anymore are deleted. With dryRun,
+// the problems are only reported.
+func (s *System) Fsck(dryRun bool) (*FsckReport, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := os.ReadDir(s.trashDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash directory: %w", err)
+	}
+
+	report := &FsckReport{}
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(s.trashDir, name)
+
+		switch {
+		case strings.HasPrefix(name, ".import-"):
+			s.fsckReclaim(report, path, "unfinished import", dryRun)
+		case strings.HasPrefix(name, ".") || !entry.IsDir():
+			// The lock file, the store and the quarantine
+		default:
+			s.fsckItem(report, name, dryRun)
+		}
+	}
+
+	s.fsckStore(report, dryRun)
+
+	return report, nil
+}
+
+// fsckItem checks the item directory id
+func (s *System) fsckItem(report *FsckReport, id string, dryRun bool) {
+	itemDir := filepath.Join(s.trashDir, id)
+	metadataPath := filepath.Join(itemDir, "metadata.json")
+	tmpPath := metadataPath + ".tmp"
+
+	data, err := os.ReadFile(metadataPath)
+	if os.IsNotExist(err) {
+		// An interrupted metadata update leaves the new version behind
+		if tmpData, tmpErr := os.ReadFile(tmpPath); tmpErr == nil && json.Valid(tmpData) {
+			s.fsckDo(report, FsckProblem{Path: itemDir, Issue: "metadata only in temporary file", Action: FsckRepaired}, dryRun, func() error {
+				return os.Rename(tmpPath, metadataPath)
+			})
+			if dryRun {
+				return
+			}
+			data, err = tmpData, nil
+		} else {
+			s.fsckReclaim(report, itemDir, "content without metadata", dryRun)
+			return
+		}
+	}
+	if err != nil {
+		s.fsckQuarantine(report, id, fmt.Sprintf("unreadable metadata: %v", err), dryRun)
+		return
+	}
+
+	var metadata types.TrashMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		s.fsckQuarantine(report, id, "invalid metadata", dryRun)
+		return
+	}
+
+	_, contentErr := os.Lstat(filepath.Join(itemDir, "content"))
+	_, archiveErr := os.Lstat(filepath.Join(itemDir, archiveName))
+	hasContent, hasArchive := contentErr == nil, archiveErr == nil
+	if !hasContent && !hasArchive {
+		s.fsckQuarantine(report, id, "content is missing", dryRun)
+		return
+	}
+
+	// Fix metadata that disagrees with the directory it is in
+	var issues []string
+	if metadata.ID != id {
+		issues = append(issues, fmt.Sprintf("ID %q does not match its directory", metadata.ID))
+		metadata.ID = id
+	}
+	if metadata.Compressed != hasArchive && hasContent != hasArchive {
+		issues = append(issues, "compression flag does not match the stored content")
+		metadata.Compressed = hasArchive
+	}
+	if len(issues) > 0 {
+		s.fsckDo(report, FsckProblem{Path: itemDir, Issue: strings.Join(issues, "; "), Action: FsckRepaired}, dryRun, func() error {
+			return writeMetadata(itemDir, &metadata)
+		})
+	}
+
+	// Leftovers of interrupted writes, archives and merges
+	leftovers := []struct{ name, issue string }{
+		{"metadata.json.tmp", "stale temporary metadata"},
+		{archiveName + ".partial", "incomplete archive"},
+		{"extracted", "unfinished merge"},
+	}
+	for _, leftover := range leftovers {
+		path := filepath.Join(itemDir, leftover.name)
+		if _, err := os.Lstat(path); err == nil {
+			s.fsckReclaim(report, path, leftover.issue, dryRun)
+		}
+	}
+}
+
+// fsckStore removes store entries no item links to anymore, e.g. after an
+// interrupted purge
+func (s *System) fsckStore(report *FsckReport, dryRun bool) {
+	filepath.WalkDir(s.storeDir(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if links, ok := linkCount(info); ok && links == 1 {
+			s.fsckReclaim(report, path, "unused store entry", dryRun)
+		}
+		return nil
+	})
+}
+
+// fsckQuarantine moves the item id to the quarantine directory
+func (s *System) fsckQuarantine(report *FsckReport, id, issue string, dryRun bool) {
+	itemDir := filepath.Join(s.trashDir, id)
+	s.fsckDo(report, FsckProblem{Path: itemDir, Issue: issue, Action: FsckQuarantined}, dryRun, func() error {
+		quarantine := filepath.Join(s.trashDir, quarantineName)
+		if err := os.MkdirAll(quarantine, 0755); err != nil {
+			return err
+		}
+		dest := filepath.Join(quarantine, id)
+		for n := 2; ; n++ {
+			if _, err := os.Lstat(dest); os.IsNotExist(err) {
+				break
+			}
+			dest = filepath.Join(quarantine, fmt.Sprintf("%s_%d", id, n))
+		}
+		return os.Rename(itemDir, dest)
+	})
+}
+
+// fsckReclaim deletes path, an item or a leftover inside the trash
+func (s *System) fsckReclaim(report *FsckReport, path, issue string, dryRun bool) {
+	size, _ := diskUsage(path, map[[2]uint64]bool{})
+	problem := FsckProblem{Path: path, Issue: issue, Action: FsckReclaimed, Size: size}
+	err := s.fsckDo(report, problem, dryRun, func() error {
+		// Items may link to store entries that become unused
+		if filepath.Dir(path) == s.trashDir {
+			return s.removeItem(path)
+		}
+		return os.RemoveAll(path)
+	})
+	if err == nil {
+		report.Reclaimed += size
+	}
+}
+
+// fsckDo records problem in report and, unless dryRun, fixes it with fix.
+// It returns the error of fix.
+func (s *System) fsckDo(report *FsckReport, problem FsckProblem, dryRun bool, fix func() error) error {
+	if !dryRun {
+		problem.Err = fix()
+	}
+	report.Problems = append(report.Problems, problem)
+	return problem.Err
+}
@@ -0,0 +1,33 @@
+package trash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockName is the file in the trash directory that trash operations lock to
+// stay consistent when several rosia processes run at once
+const lockName = ".lock"
+
+// lock takes the trash lock, waiting for other holders as needed, and
+// returns a function releasing it.
+//
+// Moves take the lock shared, so targets can still be trashed in parallel.
+// Operations that change or remove existing items take it exclusively, so
+// they never see an item that is half written or half removed.
+func (s *System) lock(exclusive bool) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(s.trashDir, lockName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trash lock: %w", err)
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock trash: %w", err)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !windows
+
+package trash
+
+import "os"
+
+// lockFile is a no-op on platforms without file locking support
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without file locking support
+func unlockFile(f *os.File) error {
+	return nil
+}
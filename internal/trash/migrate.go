@@ -0,0 +1,68 @@
+package trash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MigrateTo moves every item into the trash managed by dst, for example
+// after the trash directory setting changed, and returns the number of items
+// moved. Items keep their ID unless dst already has an item with it, in
+// which case a numeric suffix is added. Items are copied when dst is on
+// another filesystem.
+func (s *System) MigrateTo(dst *System) (int, error) {
+	if sameDir(s.trashDir, dst.trashDir) {
+		return 0, nil
+	}
+
+	unlock, err := s.lock(true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	unlockDst, err := dst.lock(true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlockDst()
+
+	items, err := s.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list trash items: %w", err)
+	}
+
+	moved := 0
+	for _, item := range items {
+		metadata, err := s.GetMetadata(item.ID)
+		if err != nil {
+			return moved, fmt.Errorf("failed to get metadata for trash item %s: %w", item.ID, err)
+		}
+
+		itemDir := filepath.Join(s.trashDir, item.ID)
+		if _, err := dst.importItem(itemDir, item.ID, metadata); err != nil {
+			return moved, fmt.Errorf("failed to migrate trash item %s: %w", item.ID, err)
+		}
+		if err := os.RemoveAll(itemDir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to clean up trash directory %s: %v\n", itemDir, err)
+		}
+		moved++
+	}
+
+	// Migrated items are tracked by the store of dst now
+	if err := os.RemoveAll(s.storeDir()); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to remove trash store %s: %v\n", s.storeDir(), err)
+	}
+
+	return moved, nil
+}
+
+// sameDir reports whether a and b name the same directory
+func sameDir(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return os.SameFile(infoA, infoB)
+}
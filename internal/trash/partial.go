@@ -0,0 +1,178 @@
+package trash
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// itemEntry is a path inside a trashed item, relative to the item root
+type itemEntry struct {
+	name string
+	dir  bool
+}
+
+// restorePartial copies the entries of item id matching opts.Only to dest,
+// the path of the whole item, and leaves the item in the trash so the rest
+// can still be restored.
+func (s *System) restorePartial(id string, metadata *types.TrashMetadata, dest string, opts RestoreOptions) error {
+	itemDir := filepath.Join(s.trashDir, id)
+	contentPath := filepath.Join(itemDir, "content")
+	archivePath := filepath.Join(itemDir, archiveName)
+	match := onlyMatcher(filepath.Base(metadata.OriginalPath), opts.Only)
+
+	// Collect the matching entries first so conflicts are found before
+	// anything is written
+	var all []itemEntry
+	var err error
+	if metadata.Compressed {
+		all, err = archiveEntries(archivePath)
+	} else {
+		all, err = contentEntries(contentPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read trash item %s: %w", id, err)
+	}
+
+	var entries []itemEntry
+	for _, entry := range all {
+		if match(entry.name) {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no paths in trash item %s match %s", id, strings.Join(opts.Only, ", "))
+	}
+
+	var conflicts []string
+	for _, entry := range entries {
+		path := filepath.Join(dest, entry.name)
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		// Restored directories are merged into existing ones
+		if entry.dir && info.IsDir() {
+			continue
+		}
+		if opts.Conflict == ConflictFail {
+			return fmt.Errorf("cannot restore trash item %s: path already exists: %s", id, path)
+		}
+		conflicts = append(conflicts, path)
+	}
+
+	// Replaced paths go to the trash so they can be recovered in turn
+	for _, path := range conflicts {
+		if _, err := s.trashReplaced(path); err != nil {
+			return fmt.Errorf("cannot restore trash item %s: failed to move existing %s to trash: %w", id, path, err)
+		}
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		if os.IsPermission(err) {
+			return types.ErrPermissionDenied{Path: dest}
+		}
+		return fmt.Errorf("failed to create directory %s for restore: %w", dest, err)
+	}
+
+	if metadata.Compressed {
+		err = extractEntries(archivePath, dest, match)
+	} else {
+		err = copyEntries(contentPath, dest, entries)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore from item %s to %s: %w", id, dest, err)
+	}
+
+	return nil
+}
+
+// contentEntries lists the entries below the content directory of an item,
+// parents before their children
+func contentEntries(contentPath string) ([]itemEntry, error) {
+	var entries []itemEntry
+	err := filepath.WalkDir(contentPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(contentPath, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		entries = append(entries, itemEntry{name: rel, dir: entry.IsDir()})
+		return nil
+	})
+	return entries, err
+}
+
+// copyEntries copies entries, listed parents first, from src to dst
+func copyEntries(src, dst string, entries []itemEntry) error {
+	for _, entry := range entries {
+		from := filepath.Join(src, entry.name)
+		to := filepath.Join(dst, entry.name)
+
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+		if entry.dir {
+			if err := os.MkdirAll(to, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		info, err := os.Lstat(from)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(from)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, to); err != nil {
+				return err
+			}
+			copyMetadata(from, to, info)
+			continue
+		}
+		if err := copyFile(from, to, info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := copyMetadata(from, to, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onlyMatcher returns a function reporting whether a path, relative to the
+// item root, is selected by patterns. Patterns are globs relative to the
+// item and may start with the item's own name, as in
+// "node_modules/.package-lock.json". Entries inside a selected directory are
+// selected too.
+func onlyMatcher(itemName string, patterns []string) func(name string) bool {
+	cleaned := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = filepath.Clean(filepath.FromSlash(pattern))
+		if rest, ok := strings.CutPrefix(pattern, itemName+string(filepath.Separator)); ok {
+			pattern = rest
+		}
+		cleaned = append(cleaned, pattern)
+	}
+
+	return func(name string) bool {
+		for path := name; path != "." && path != string(filepath.Separator); path = filepath.Dir(path) {
+			for _, pattern := range cleaned {
+				if matched, err := filepath.Match(pattern, path); err == nil && matched {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
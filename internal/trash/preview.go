@@ -0,0 +1,88 @@
+package trash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
+)
+
+// RestorePreview describes what RestoreWith would do, without doing it
+type RestorePreview struct {
+	Dest        string // Path the item would be restored to
+	Exists      bool   // Dest already exists
+	SpaceNeeded int64  // Bytes written to the destination filesystem (0 when the item is only renamed)
+	SpaceFree   int64  // Bytes available on the destination filesystem, or -1 when unknown
+	Err         error  // Why the restore would fail, or nil
+}
+
+// Preview reports where item id would be restored with opts, whether that
+// conflicts with an existing path and how much space the restore needs,
+// without changing anything.
+func (s *System) Preview(id string, opts RestoreOptions) (*RestorePreview, error) {
+	metadata, err := s.GetMetadata(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata for trash item %s: %w", id, err)
+	}
+
+	dest, err := restoreDest(metadata, opts)
+	if err != nil {
+		return nil, err
+	}
+	preview := &RestorePreview{Dest: dest, SpaceFree: -1}
+
+	existing, err := os.Lstat(dest)
+	preview.Exists = err == nil
+	if preview.Exists && len(opts.Only) == 0 {
+		switch opts.Conflict {
+		case ConflictFail:
+			preview.Err = fmt.Errorf("path already exists: %s", dest)
+		case ConflictMerge:
+			if !existing.IsDir() {
+				preview.Err = fmt.Errorf("%s is not a directory", dest)
+			}
+		}
+	}
+
+	// Uncompressed items are renamed into place when the destination is on
+	// the same filesystem as the trash; everything else is written out
+	parent := existingParent(dest)
+	preview.SpaceNeeded = metadata.Size
+	if !metadata.Compressed && len(opts.Only) == 0 && sameFilesystem(s.contentPath(id, metadata), parent) {
+		preview.SpaceNeeded = 0
+	}
+
+	if free, err := fsutils.FreeSpace(parent); err == nil {
+		preview.SpaceFree = int64(free)
+		if preview.Err == nil && preview.SpaceNeeded > preview.SpaceFree {
+			preview.Err = fmt.Errorf("not enough space on the destination filesystem")
+		}
+	}
+
+	return preview, nil
+}
+
+// existingParent returns path or its closest ancestor that exists
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// sameFilesystem reports whether a and b are known to be on the same filesystem
+func sameFilesystem(a, b string) bool {
+	idA, err := fsutils.FilesystemID(a)
+	if err != nil {
+		return false
+	}
+	idB, err := fsutils.FilesystemID(b)
+	return err == nil && idA == idB
+}
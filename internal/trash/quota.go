@@ -0,0 +1,77 @@
+package trash
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
+
+// SetMaxSize limits the disk usage of the trash to maxSize bytes, enforced
+// by Trim (0 = no limit)
+func (s *System) SetMaxSize(maxSize int64) {
+	s.maxSize = maxSize
+}
+
+// MaxSize returns the limit of the disk usage of the trash set with
+// SetMaxSize, or 0 when there is none
+func (s *System) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Trim removes the oldest items until the disk usage of the trash is within
+// its limit, and returns them. Pinned items and the items moved by s are
+// kept, so the targets just cleaned can always be restored; the trash may
+// therefore stay over its limit.
+func (s *System) Trim() ([]types.TrashItem, error) {
+	if s.maxSize <= 0 {
+		return nil, nil
+	}
+
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	items, err := s.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash items: %w", err)
+	}
+
+	// Files deduplicated across items are counted with the newest item
+	// holding them, so removing older items only subtracts what they free
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	seen := map[[2]uint64]bool{}
+	usage := make([]int64, len(items))
+	var total int64
+	for i, item := range items {
+		if usage[i], err = diskUsage(item.TrashPath, seen); err != nil {
+			return nil, fmt.Errorf("failed to measure trash item %s: %w", item.ID, err)
+		}
+		total += usage[i]
+	}
+
+	var removed []types.TrashItem
+	for i := len(items) - 1; i >= 0 && total > s.maxSize; i-- {
+		item := items[i]
+		if item.Pinned || s.movedHere(item.ID) {
+			continue
+		}
+		if err := s.removeItem(item.TrashPath); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", item.ID, err)
+		}
+		total -= usage[i]
+		removed = append(removed, item)
+	}
+	return removed, nil
+}
+
+// movedHere reports whether the item id was moved to the trash by s
+func (s *System) movedHere(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.moved[id]
+}
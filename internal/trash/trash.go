@@ -1,6 +1,6 @@
 // Package trash provides trash system functionality for safe file deletion.
 //
-// The trash system moves deleted files to a temporary location (~/.rosia/trash/)
+// The trash system moves deleted files to a temporary location (see DefaultDir)
 // before permanent removal, enabling restoration if needed. It maintains metadata
 // for each trashed item and supports automatic cleanup based on retention periods.
 //
@@ -14,11 +14,16 @@ package trash
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/raucheacho/rosia-cli/internal/fsutils"
 	"github.com/raucheacho/rosia-cli/pkg/types"
 )
 
@@ -28,6 +33,13 @@ import (
 // and automatic cleanup of old items based on retention policies.
 type System struct {
 	trashDir string
+	compress bool  // Store directories as tar.zst archives
+	dedup    bool  // Hard-link identical files to a shared store
+	checksum bool  // Record a checksum of every item, verified on restore
+	maxSize  int64 // Disk usage Trim brings the trash back to (0 = no limit)
+
+	mu    sync.Mutex
+	moved map[string]bool // Items moved by this System, which Trim keeps
 }
 
 // NewSystem creates a new trash system with the specified trash directory
@@ -42,41 +54,92 @@ func NewSystem(trashDir string) (*System, error) {
 	}, nil
 }
 
+// SetCompression enables storing trashed directories as tar.zst archives.
+//
+// Compression trades CPU time for a much smaller trash, especially for trees
+// of tiny files such as node_modules. Items are decompressed transparently
+// on restore.
+func (s *System) SetCompression(enabled bool) {
+	s.compress = enabled
+}
+
+// SetChecksums enables recording a checksum of every trashed item, checked
+// before it is restored. Hashing reads the whole item once more, so it is
+// off by default.
+func (s *System) SetChecksums(enabled bool) {
+	s.checksum = enabled
+}
+
 // NewDefaultSystem creates a new trash system with the default location
 // Uses platform-specific paths (XDG on Linux, ~/Library on macOS, %LOCALAPPDATA% on Windows)
 func NewDefaultSystem() (*System, error) {
-	trashDir, err := getDefaultTrashDir()
+	trashDir, err := DefaultDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get default trash directory: %w", err)
 	}
 	return NewSystem(trashDir)
 }
 
-// getDefaultTrashDir returns the platform-specific default trash directory
-func getDefaultTrashDir() (string, error) {
-	homeDir, err := os.UserHomeDir()
+// DefaultDir returns the default trash directory.
+//
+// Existing installs keep using ~/.rosia/trash until it is migrated; new
+// installs use the platform-specific data directory from fsutils.GetTrashDir.
+func DefaultDir() (string, error) {
+	location, err := fsutils.TrashLocation()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", err
 	}
+	return location.Path(), nil
+}
 
-	// For backward compatibility, keep using ~/.rosia/trash
-	// In the future, this could use fsutils.GetTrashDir() for platform-specific paths
-	trashDir := filepath.Join(homeDir, ".rosia", "trash")
-	return trashDir, nil
+// MoveOptions configures MoveWith
+type MoveOptions struct {
+	Retention time.Duration // Keep the item this long instead of the retention period passed to Clean (0 = default)
 }
 
 // Move relocates a target to the trash with a timestamp-based ID
 func (s *System) Move(target types.Target) (string, error) {
+	return s.MoveWith(target, MoveOptions{})
+}
+
+// MoveWith relocates a target to the trash according to opts
+func (s *System) MoveWith(target types.Target, opts MoveOptions) (string, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	id, err := s.moveWith(target, opts)
+	if id != "" {
+		s.mu.Lock()
+		if s.moved == nil {
+			s.moved = map[string]bool{}
+		}
+		s.moved[id] = true
+		s.mu.Unlock()
+	}
+	return id, err
+}
+
+// moveWith is MoveWith for callers holding the trash lock
+func (s *System) moveWith(target types.Target, opts MoveOptions) (string, error) {
 	// Generate unique ID: YYYYMMDD_HHMMSS_<basename>
 	timestamp := time.Now().Format("20060102_150405")
 	basename := filepath.Base(target.Path)
 	id := fmt.Sprintf("%s_%s", timestamp, basename)
 
-	// Create trash item directory
-	itemDir := filepath.Join(s.trashDir, id)
-	if err := os.MkdirAll(itemDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create trash item directory: %w", err)
+	// Create trash item directory. Targets with the same name trashed within
+	// the same second get a numeric suffix instead of sharing a directory.
+	id, err := s.createItemDir(id)
+	if err != nil {
+		return "", err
 	}
+	itemDir := filepath.Join(s.trashDir, id)
+
+	// Capture what a copy or an archive could lose on the way back. A
+	// missing target makes the move fail below.
+	attrs, _ := captureAttributes(target.Path)
 
 	// Create metadata
 	metadata := types.TrashMetadata{
@@ -85,45 +148,313 @@ func (s *System) Move(target types.Target) (string, error) {
 		Size:         target.Size,
 		DeletedAt:    time.Now(),
 		ProfileName:  target.ProfileName,
+		Compressed:   s.compress && target.IsDirectory,
+		Retention:    opts.Retention,
+		Attributes:   attrs,
 	}
 
 	// Write metadata.json
+	if err := writeMetadata(itemDir, &metadata); err != nil {
+		return "", err
+	}
+
+	if metadata.Compressed {
+		if err := s.archive(target, itemDir); err != nil {
+			return "", err
+		}
+	} else {
+		// Move the actual content
+		contentPath := filepath.Join(itemDir, "content")
+		if err := os.Rename(target.Path, contentPath); err != nil {
+			// Clean up metadata if move fails
+			os.RemoveAll(itemDir)
+			return "", fmt.Errorf("failed to move target to trash: %w", err)
+		}
+
+		// The content is intact whatever happens here, only less is shared
+		if s.dedup {
+			if err := s.dedupe(itemDir); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to deduplicate trash item %s: %v\n", id, err)
+			}
+		}
+	}
+
+	if !s.checksum {
+		return id, nil
+	}
+
+	// Record a checksum of the stored content so restores can detect
+	// changes. The item is already safe in the trash, so failing to hash it
+	// only loses the check.
+	sum, err := checksum(s.contentPath(id, &metadata))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to checksum trash item %s: %v\n", id, err)
+		return id, nil
+	}
+	metadata.Checksum = sum
+	if err := writeMetadata(itemDir, &metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record checksum of trash item %s: %v\n", id, err)
+	}
+
+	return id, nil
+}
+
+// writeMetadata stores metadata as metadata.json in itemDir. The file is
+// replaced atomically, so readers never see partially written metadata.
+func writeMetadata(itemDir string, metadata *types.TrashMetadata) error {
 	metadataPath := filepath.Join(itemDir, "metadata.json")
 	metadataData, err := json.MarshalIndent(metadata, "", "  ")
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	tmpPath := metadataPath + ".tmp"
+	if err := os.WriteFile(tmpPath, metadataData, 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if err := os.Rename(tmpPath, metadataPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write metadata: %w", err)
 	}
+	return nil
+}
 
-	if err := os.WriteFile(metadataPath, metadataData, 0644); err != nil {
-		return "", fmt.Errorf("failed to write metadata: %w", err)
+// createItemDir creates the directory of a new item with the given ID, or
+// with a numeric suffix if that ID is taken, and returns the ID used
+func (s *System) createItemDir(id string) (string, error) {
+	unique := id
+	for n := 2; ; n++ {
+		err := os.Mkdir(filepath.Join(s.trashDir, unique), 0755)
+		if err == nil {
+			return unique, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("failed to create trash item directory: %w", err)
+		}
+		unique = fmt.Sprintf("%s_%d", id, n)
 	}
+}
 
-	// Move the actual content
-	contentPath := filepath.Join(itemDir, "content")
-	if err := os.Rename(target.Path, contentPath); err != nil {
-		// Clean up metadata if move fails
+// archive compresses target into itemDir, then removes the original. On
+// failure, the original is left whole and itemDir is removed.
+func (s *System) archive(target types.Target, itemDir string) error {
+	// The archive only gets its final name once complete, so an interrupted
+	// move never leaves a truncated archive that looks restorable
+	archivePath := filepath.Join(itemDir, archiveName)
+	partialPath := archivePath + ".partial"
+	err := writeArchive(target.Path, partialPath)
+	if err == nil {
+		err = os.Rename(partialPath, archivePath)
+	}
+	if err != nil {
+		// The original is untouched, so only the partial item is removed
 		os.RemoveAll(itemDir)
-		return "", fmt.Errorf("failed to move target to trash: %w", err)
+		return fmt.Errorf("failed to compress target into trash: %w", err)
 	}
 
-	return id, nil
+	if err := os.RemoveAll(target.Path); err != nil {
+		// Put back what was removed before dropping the item. Should that
+		// fail, the archive is the only complete copy and is kept.
+		if putErr := putBack(archivePath, target.Path); putErr != nil {
+			return fmt.Errorf("failed to remove compressed target %s, whose removed files remain in trash item %s: %w", target.Path, filepath.Base(itemDir), errors.Join(err, putErr))
+		}
+		os.RemoveAll(itemDir)
+		return fmt.Errorf("failed to remove compressed target %s: %w", target.Path, err)
+	}
+
+	return nil
+}
+
+// putBack extracts the entries of the archive at src that are missing from
+// the directory dst, undoing a partial removal of the tree it was written from
+func putBack(src, dst string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	return extractEntries(src, dst, func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dst, name))
+		return os.IsNotExist(err)
+	})
+}
+
+// ConflictMode selects what a restore does when the destination exists
+type ConflictMode int
+
+const (
+	// ConflictFail aborts the restore and leaves everything untouched
+	ConflictFail ConflictMode = iota
+	// ConflictReplace moves the existing path to the trash, then restores
+	ConflictReplace
+	// ConflictMerge overlays the item onto an existing directory. Entries
+	// present in both are replaced by the trashed version, and moved to the
+	// trash themselves.
+	ConflictMerge
+)
+
+// RestoreOptions configures RestoreWith
+type RestoreOptions struct {
+	To       string       // Destination path (default: the original path)
+	Conflict ConflictMode // What to do when the destination exists
+	Only     []string     // Globs selecting paths inside the item to restore (default: everything)
 }
 
 // Restore moves an item back to its original location
 func (s *System) Restore(id string) error {
+	return s.RestoreWith(id, RestoreOptions{})
+}
+
+// RestoreTo moves an item to dest instead of its original location, for
+// example when the original parent directory or mount is gone. dest is the
+// path the item will have, and its parent directories are created as needed.
+// The item is copied when dest is on another filesystem.
+func (s *System) RestoreTo(id, dest string) error {
+	return s.RestoreWith(id, RestoreOptions{To: dest})
+}
+
+// RestoreWith restores an item according to opts
+func (s *System) RestoreWith(id string, opts RestoreOptions) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.restoreWith(id, opts)
+}
+
+// restoreWith is RestoreWith for callers holding the trash lock
+func (s *System) restoreWith(id string, opts RestoreOptions) error {
 	// Get metadata to find original path
 	metadata, err := s.GetMetadata(id)
 	if err != nil {
 		return fmt.Errorf("failed to get metadata for trash item %s: %w", id, err)
 	}
 
-	// Check if original path already exists (conflict)
-	if _, err := os.Stat(metadata.OriginalPath); err == nil {
-		return fmt.Errorf("cannot restore trash item %s: path already exists: %s", id, metadata.OriginalPath)
+	dest, err := restoreDest(metadata, opts)
+	if err != nil {
+		return err
+	}
+
+	// Restore anyway on a mismatch: whatever is left is still the best copy
+	if err := s.verify(id, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 	}
 
+	// A partial restore copies entries out and keeps the item in the trash
+	if len(opts.Only) > 0 {
+		return s.restorePartial(id, metadata, dest, opts)
+	}
+
+	// Check if the destination already exists (conflict)
+	existing, err := os.Lstat(dest)
+	if err != nil {
+		return s.restore(id, metadata, dest)
+	}
+
+	switch opts.Conflict {
+	case ConflictReplace:
+		// The replaced path goes to the trash so it can be recovered in turn
+		replacedID, err := s.trashReplaced(dest)
+		if err != nil {
+			return fmt.Errorf("cannot restore trash item %s: failed to move existing %s to trash: %w", id, dest, err)
+		}
+		if err := s.restore(id, metadata, dest); err != nil {
+			// Put the replaced path back so nothing is lost
+			if restoreErr := s.restoreWith(replacedID, RestoreOptions{}); restoreErr != nil {
+				return fmt.Errorf("%w (replaced path kept in trash as %s)", err, replacedID)
+			}
+			return err
+		}
+		return nil
+
+	case ConflictMerge:
+		if !existing.IsDir() {
+			return fmt.Errorf("cannot merge trash item %s: %s is not a directory", id, dest)
+		}
+		return s.merge(id, metadata, dest)
+
+	default:
+		return fmt.Errorf("cannot restore trash item %s: path already exists: %s", id, dest)
+	}
+}
+
+// trashReplaced moves path, which a restore is about to replace, to the trash
+// so it can be recovered in turn, and returns its trash ID
+func (s *System) trashReplaced(path string) (string, error) {
+	existing, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	return s.moveWith(types.Target{Path: path, IsDirectory: existing.IsDir()}, MoveOptions{})
+}
+
+// restoreDest returns the path item metadata is restored to with opts
+func restoreDest(metadata *types.TrashMetadata, opts RestoreOptions) (string, error) {
+	if opts.To == "" {
+		return metadata.OriginalPath, nil
+	}
+	dest, err := filepath.Abs(opts.To)
+	if err != nil {
+		return "", fmt.Errorf("invalid restore destination %s: %w", opts.To, err)
+	}
+	return dest, nil
+}
+
+// merge overlays the content of item id onto the existing directory dest
+// and removes the item
+func (s *System) merge(id string, metadata *types.TrashMetadata, dest string) error {
+	itemDir := filepath.Join(s.trashDir, id)
+	contentPath := filepath.Join(itemDir, "content")
+
+	if err := s.detach(itemDir); err != nil {
+		return fmt.Errorf("failed to prepare trash item %s for restore: %w", id, err)
+	}
+
+	if metadata.Compressed {
+		// Unpack inside the item so the merge can move entries out of it
+		contentPath = filepath.Join(itemDir, "extracted")
+		if err := extractArchive(filepath.Join(itemDir, archiveName), contentPath); err != nil {
+			os.RemoveAll(contentPath)
+			return fmt.Errorf("failed to extract trash item %s: %w", id, err)
+		}
+	}
+
+	info, err := os.Lstat(contentPath)
+	if err != nil {
+		return fmt.Errorf("failed to read trash item %s: %w", id, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("cannot merge trash item %s: item is not a directory", id)
+	}
+
+	replace := func(path string) error {
+		_, err := s.trashReplaced(path)
+		return err
+	}
+	if err := mergeTree(contentPath, dest, replace); err != nil {
+		// Entries merged so far stay in place; the rest remain in the trash
+		return fmt.Errorf("failed to merge item %s into %s: %w", id, dest, err)
+	}
+
+	// Remove trash item directory
+	if err := os.RemoveAll(itemDir); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to clean up trash directory %s: %v\n", itemDir, err)
+	}
+
+	return nil
+}
+
+// restore moves the content of item id to dest, which must not exist, and
+// removes the item
+func (s *System) restore(id string, metadata *types.TrashMetadata, dest string) error {
+
 	// Ensure parent directory exists
-	parentDir := filepath.Dir(metadata.OriginalPath)
+	parentDir := filepath.Dir(dest)
 	if err := os.MkdirAll(parentDir, 0755); err != nil {
 		if os.IsPermission(err) {
 			return types.ErrPermissionDenied{Path: parentDir}
@@ -131,15 +462,36 @@ func (s *System) Restore(id string) error {
 		return fmt.Errorf("failed to create parent directory %s for restore: %w", parentDir, err)
 	}
 
-	// Move content back to original location
+	// Move content back to the destination
 	itemDir := filepath.Join(s.trashDir, id)
-	contentPath := filepath.Join(itemDir, "content")
+	if metadata.Compressed {
+		if err := extractArchive(filepath.Join(itemDir, archiveName), dest); err != nil {
+			// Leave no partial tree behind so the restore can be retried
+			os.RemoveAll(dest)
+			if os.IsPermission(err) {
+				return types.ErrPermissionDenied{Path: dest}
+			}
+			return fmt.Errorf("failed to restore item %s to %s: %w", id, dest, err)
+		}
+	} else {
+		// Restored files must not stay linked to files still in the trash
+		if err := s.detach(itemDir); err != nil {
+			return fmt.Errorf("failed to prepare trash item %s for restore: %w", id, err)
+		}
 
-	if err := os.Rename(contentPath, metadata.OriginalPath); err != nil {
-		if os.IsPermission(err) {
-			return types.ErrPermissionDenied{Path: metadata.OriginalPath}
+		contentPath := filepath.Join(itemDir, "content")
+		if err := moveTree(contentPath, dest); err != nil {
+			if os.IsPermission(err) {
+				return types.ErrPermissionDenied{Path: dest}
+			}
+			return fmt.Errorf("failed to restore item %s to %s: %w", id, dest, err)
+		}
+	}
+
+	if metadata.Attributes != nil {
+		if err := applyAttributes(dest, metadata.Attributes); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to restore attributes of %s: %v\n", dest, err)
 		}
-		return fmt.Errorf("failed to restore item %s to %s: %w", id, metadata.OriginalPath, err)
 	}
 
 	// Remove trash item directory
@@ -151,8 +503,22 @@ func (s *System) Restore(id string) error {
 	return nil
 }
 
+// checkID rejects IDs that are not the name of a trashed item: IDs are single
+// directory names inside the trash, and names starting with "." are the
+// trash's own files, such as its lock and deduplication store
+func checkID(id string) error {
+	if id == "" || filepath.Base(id) != id || !filepath.IsLocal(id) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("invalid trash item ID: %q", id)
+	}
+	return nil
+}
+
 // GetMetadata reads and returns the metadata for a trashed item
 func (s *System) GetMetadata(id string) (*types.TrashMetadata, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+
 	metadataPath := filepath.Join(s.trashDir, id, "metadata.json")
 
 	data, err := os.ReadFile(metadataPath)
@@ -174,6 +540,58 @@ func (s *System) GetMetadata(id string) (*types.TrashMetadata, error) {
 	return &metadata, nil
 }
 
+// ListFilter selects trashed items in ListWith. Empty fields match everything.
+type ListFilter struct {
+	Profile       string    // Profile name, case-insensitive
+	PathPattern   string    // Glob matched against the original path, or its base name for patterns without a separator
+	DeletedAfter  time.Time // Only items deleted at or after this time
+	DeletedBefore time.Time // Only items deleted before this time
+}
+
+// Match reports whether item is selected by f
+func (f ListFilter) Match(item types.TrashItem) bool {
+	if f.Profile != "" && !strings.EqualFold(f.Profile, item.ProfileName) {
+		return false
+	}
+	if f.PathPattern != "" && !matchPath(f.PathPattern, item.OriginalPath) {
+		return false
+	}
+	if !f.DeletedAfter.IsZero() && item.DeletedAt.Before(f.DeletedAfter) {
+		return false
+	}
+	if !f.DeletedBefore.IsZero() && !item.DeletedAt.Before(f.DeletedBefore) {
+		return false
+	}
+	return true
+}
+
+// matchPath matches path against pattern, or only its base name when the
+// pattern has no separator, so "node_modules" finds every node_modules
+func matchPath(pattern, path string) bool {
+	pattern = filepath.FromSlash(pattern)
+	if !strings.ContainsRune(pattern, filepath.Separator) {
+		path = filepath.Base(path)
+	}
+	matched, err := filepath.Match(pattern, path)
+	return err == nil && matched
+}
+
+// ListWith returns the trashed items selected by filter
+func (s *System) ListWith(filter ListFilter) ([]types.TrashItem, error) {
+	items, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []types.TrashItem{}
+	for _, item := range items {
+		if filter.Match(item) {
+			selected = append(selected, item)
+		}
+	}
+	return selected, nil
+}
+
 // List returns all trashed items
 func (s *System) List() ([]types.TrashItem, error) {
 	entries, err := os.ReadDir(s.trashDir)
@@ -186,12 +604,19 @@ func (s *System) List() ([]types.TrashItem, error) {
 
 	var items []types.TrashItem
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		// Hidden directories hold imports in progress, not items
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 
 		id := entry.Name()
 		metadata, err := s.GetMetadata(id)
+		var notFound types.ErrPathNotFound
+		if errors.As(err, &notFound) {
+			// Metadata is written first, so this item is still being
+			// created or its move was interrupted
+			continue
+		}
 		if err != nil {
 			// Skip items with invalid metadata
 			fmt.Fprintf(os.Stderr, "warning: skipping item with invalid metadata: %s: %v\n", id, err)
@@ -203,6 +628,9 @@ func (s *System) List() ([]types.TrashItem, error) {
 			OriginalPath: metadata.OriginalPath,
 			Size:         metadata.Size,
 			DeletedAt:    metadata.DeletedAt,
+			ProfileName:  metadata.ProfileName,
+			Pinned:       metadata.Pinned,
+			Retention:    metadata.Retention,
 			TrashPath:    filepath.Join(s.trashDir, id),
 		})
 	}
@@ -210,20 +638,123 @@ func (s *System) List() ([]types.TrashItem, error) {
 	return items, nil
 }
 
-// Clean removes trashed items older than the specified retention period
+// Stats returns the number, size and age of the trashed items, broken down
+// by profile
+func (s *System) Stats() (*types.TrashStats, error) {
+	items, err := s.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash items: %w", err)
+	}
+
+	stats := &types.TrashStats{
+		Profiles: map[string]*types.TrashProfileStats{},
+	}
+	// Files deduplicated across items are only counted once
+	seen := map[[2]uint64]bool{}
+	for i, item := range items {
+		stats.Items++
+		stats.TotalSize += item.Size
+
+		usage, err := diskUsage(item.TrashPath, seen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure trash item %s: %w", item.ID, err)
+		}
+		stats.DiskUsage += usage
+
+		if stats.Oldest == nil || item.DeletedAt.Before(stats.Oldest.DeletedAt) {
+			stats.Oldest = &items[i]
+		}
+
+		name := item.ProfileName
+		if name == "" {
+			name = types.UnknownProfile
+		}
+		profile, ok := stats.Profiles[name]
+		if !ok {
+			profile = &types.TrashProfileStats{}
+			stats.Profiles[name] = profile
+		}
+		profile.Items++
+		profile.Size += item.Size
+	}
+
+	return stats, nil
+}
+
+// diskUsage returns the total size of the regular files below path, skipping
+// hard links to files already in seen and adding the others to it
+func diskUsage(path string, seen map[[2]uint64]bool) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if id, ok := fileID(info); ok {
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+// SetPinned pins or unpins item id. Pinned items are never removed by Clean,
+// only by Purge.
+func (s *System) SetPinned(id string, pinned bool) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	metadata, err := s.GetMetadata(id)
+	if err != nil {
+		return fmt.Errorf("failed to get metadata for trash item %s: %w", id, err)
+	}
+
+	metadata.Pinned = pinned
+	return writeMetadata(filepath.Join(s.trashDir, id), metadata)
+}
+
+// Clean removes trashed items older than the specified retention period, or
+// than their own retention when they were moved with one. Pinned items are
+// kept regardless of their age.
 func (s *System) Clean(retentionPeriod time.Duration) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	items, err := s.List()
 	if err != nil {
 		return fmt.Errorf("failed to list trash items: %w", err)
 	}
 
-	cutoffTime := time.Now().Add(-retentionPeriod)
+	now := time.Now()
 	var errors []error
 
 	for _, item := range items {
-		if item.DeletedAt.Before(cutoffTime) {
+		if item.Pinned {
+			continue
+		}
+		retention := retentionPeriod
+		if item.Retention > 0 {
+			retention = item.Retention
+		}
+		if item.DeletedAt.Before(now.Add(-retention)) {
 			itemDir := filepath.Join(s.trashDir, item.ID)
-			if err := os.RemoveAll(itemDir); err != nil {
+			if err := s.removeItem(itemDir); err != nil {
 				errors = append(errors, fmt.Errorf("failed to remove %s: %w", item.ID, err))
 			}
 		}
@@ -240,3 +771,33 @@ func (s *System) Clean(retentionPeriod time.Duration) error {
 func (s *System) GetTrashDir() string {
 	return s.trashDir
 }
+
+// Purge permanently deletes the trashed item id, regardless of its age
+func (s *System) Purge(id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	itemDir := filepath.Join(s.trashDir, id)
+	if _, err := os.Lstat(itemDir); err != nil {
+		if os.IsNotExist(err) {
+			return types.ErrPathNotFound{Path: itemDir}
+		}
+		return fmt.Errorf("failed to access trash item %s: %w", id, err)
+	}
+
+	if err := s.removeItem(itemDir); err != nil {
+		if os.IsPermission(err) {
+			return types.ErrPermissionDenied{Path: itemDir}
+		}
+		return fmt.Errorf("failed to purge trash item %s: %w", id, err)
+	}
+
+	return nil
+}
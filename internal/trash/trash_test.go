@@ -1,11 +1,23 @@
 package trash
 
 import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/raucheacho/rosia-cli/pkg/types"
 )
 
@@ -509,3 +521,1766 @@ func TestSystem_GetTrashDir(t *testing.T) {
 		t.Errorf("expected trash dir %s, got %s", trashDir, sys.GetTrashDir())
 	}
 }
+
+func TestSystem_MoveAndRestore_Compressed(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	sys.SetCompression(true)
+
+	// Build a small tree with a nested directory, an executable and a symlink
+	targetDir := filepath.Join(tmpDir, "node_modules")
+	nestedDir := filepath.Join(targetDir, "pkg", "lib")
+	if err := os.MkdirAll(nestedDir, 0755); err != nil {
+		t.Fatalf("failed to create target: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(nestedDir, "index.js"), []byte("module.exports = 1"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "run.sh"), []byte("#!/bin/sh"), 0755); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("pkg", "lib", "index.js"), filepath.Join(targetDir, "link.js")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	id, err := sys.Move(types.Target{Path: targetDir, Size: 27, IsDirectory: true})
+	if err != nil {
+		t.Fatalf("failed to move to trash: %v", err)
+	}
+
+	if _, err := os.Stat(targetDir); !os.IsNotExist(err) {
+		t.Errorf("target should be removed after compression")
+	}
+	if _, err := os.Stat(filepath.Join(sys.GetTrashDir(), id, archiveName)); err != nil {
+		t.Errorf("archive should exist in trash: %v", err)
+	}
+	metadata, err := sys.GetMetadata(id)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	if !metadata.Compressed {
+		t.Errorf("metadata should record compression")
+	}
+
+	if err := sys.Restore(id); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(nestedDir, "index.js"))
+	if err != nil || string(data) != "module.exports = 1" {
+		t.Errorf("restored file content mismatch: %q, %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(targetDir, "run.sh"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("restored file should keep its mode: %v, %v", info, err)
+	}
+	link, err := os.Readlink(filepath.Join(targetDir, "link.js"))
+	if err != nil || link != filepath.Join("pkg", "lib", "index.js") {
+		t.Errorf("restored symlink mismatch: %q, %v", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(sys.GetTrashDir(), id)); !os.IsNotExist(err) {
+		t.Errorf("trash item should be removed after restore")
+	}
+}
+
+func TestSystem_RestoreKeepsAttributes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permissions")
+	}
+
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	sys.SetCompression(true)
+
+	// Modes the umask would strip from a plain copy, on the root as well
+	targetDir := filepath.Join(tmpDir, "build")
+	if err := os.MkdirAll(filepath.Join(targetDir, "bin"), 0755); err != nil {
+		t.Fatalf("failed to create target: %v", err)
+	}
+	tool := filepath.Join(targetDir, "bin", "tool")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.Chmod(tool, 0777); err != nil {
+		t.Fatalf("failed to chmod: %v", err)
+	}
+	if err := os.Chmod(targetDir, 0770); err != nil {
+		t.Fatalf("failed to chmod: %v", err)
+	}
+	setXattrs(tool, map[string][]byte{"user.rosia.test": []byte("kept")})
+	xattrs, _ := listXattrs(tool)
+	hasXattrs := string(xattrs["user.rosia.test"]) == "kept"
+
+	id, err := sys.Move(types.Target{Path: targetDir, Size: 9, IsDirectory: true})
+	if err != nil {
+		t.Fatalf("failed to move to trash: %v", err)
+	}
+	metadata, err := sys.GetMetadata(id)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	if metadata.Attributes == nil || metadata.Attributes.Mode.Perm() != 0770 || !metadata.Attributes.Mode.IsDir() {
+		t.Errorf("expected metadata to record the attributes of the target, got %+v", metadata.Attributes)
+	}
+
+	if err := sys.Restore(id); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	if info, err := os.Stat(targetDir); err != nil || info.Mode().Perm() != 0770 {
+		t.Errorf("restored root should keep its mode: %v, %v", info, err)
+	}
+	if info, err := os.Stat(tool); err != nil || info.Mode().Perm() != 0777 {
+		t.Errorf("restored file should keep its mode: %v, %v", info, err)
+	}
+	if hasXattrs {
+		if xattrs, _ := listXattrs(tool); string(xattrs["user.rosia.test"]) != "kept" {
+			t.Errorf("restored file should keep its extended attributes, got %v", xattrs)
+		}
+	}
+}
+
+func TestSystem_Move_CompressionSkipsFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	sys.SetCompression(true)
+
+	testFile := filepath.Join(tmpDir, ".coverage")
+	if err := os.WriteFile(testFile, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	id, err := sys.Move(types.Target{Path: testFile, Size: 4})
+	if err != nil {
+		t.Fatalf("failed to move to trash: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(sys.GetTrashDir(), id, "content")); err != nil {
+		t.Errorf("single files should be moved, not compressed: %v", err)
+	}
+}
+
+func TestSystem_Move_CompressionFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are tested on unix")
+	}
+
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	sys.SetCompression(true)
+
+	// Sockets cannot be archived, so compressing the target fails
+	targetDir := filepath.Join(tmpDir, "build")
+	if err := os.Mkdir(targetDir, 0755); err != nil {
+		t.Fatalf("failed to create target: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "out.o"), []byte("object"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	listener, err := net.Listen("unix", filepath.Join(targetDir, "s"))
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	defer listener.Close()
+
+	id, err := sys.Move(types.Target{Path: targetDir, Size: 6, IsDirectory: true})
+	if err == nil {
+		t.Fatal("expected the move to fail")
+	}
+	if id != "" {
+		t.Errorf("expected no ID for a failed move, got %s", id)
+	}
+
+	items, err := sys.List()
+	if err != nil {
+		t.Fatalf("failed to list trash: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected no item left in the trash, got %d", len(items))
+	}
+	if data, err := os.ReadFile(filepath.Join(targetDir, "out.o")); err != nil || string(data) != "object" {
+		t.Errorf("target should be untouched: %q, %v", data, err)
+	}
+}
+
+func TestPutBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target")
+	if err := os.MkdirAll(filepath.Join(target, "lib"), 0755); err != nil {
+		t.Fatalf("failed to create target: %v", err)
+	}
+	for _, name := range []string{"a.txt", filepath.Join("lib", "b.txt")} {
+		if err := os.WriteFile(filepath.Join(target, name), []byte(name), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	archive := filepath.Join(tmpDir, archiveName)
+	if err := writeArchive(target, archive); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+
+	// A removal that stopped halfway is undone, and what remains is kept
+	if err := os.RemoveAll(filepath.Join(target, "lib")); err != nil {
+		t.Fatalf("failed to remove lib: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "a.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("failed to change file: %v", err)
+	}
+	if err := putBack(archive, target); err != nil {
+		t.Fatalf("putBack failed: %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(target, "lib", "b.txt")); err != nil || string(data) != filepath.Join("lib", "b.txt") {
+		t.Errorf("removed file should be put back: %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "a.txt")); err != nil || string(data) != "changed" {
+		t.Errorf("remaining file should be kept: %q, %v", data, err)
+	}
+}
+
+func TestSystem_RestoreTo(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	projectDir := filepath.Join(tmpDir, "project")
+	targetDir := filepath.Join(projectDir, "build")
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		t.Fatalf("failed to create target: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "out.bin"), []byte("binary"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	id, err := sys.Move(types.Target{Path: targetDir, Size: 6, IsDirectory: true})
+	if err != nil {
+		t.Fatalf("failed to move to trash: %v", err)
+	}
+
+	// The original project is gone, so restore somewhere else
+	if err := os.RemoveAll(projectDir); err != nil {
+		t.Fatalf("failed to remove project: %v", err)
+	}
+	dest := filepath.Join(tmpDir, "recovered", "build")
+	if err := sys.RestoreTo(id, dest); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "out.bin"))
+	if err != nil || string(data) != "binary" {
+		t.Errorf("restored content mismatch: %q, %v", data, err)
+	}
+	if _, err := os.Stat(projectDir); !os.IsNotExist(err) {
+		t.Errorf("original location should not be recreated")
+	}
+	if _, err := os.Stat(filepath.Join(sys.GetTrashDir(), id)); !os.IsNotExist(err) {
+		t.Errorf("trash item should be removed after restore")
+	}
+}
+
+func TestSystem_RestoreTo_Conflict(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	testFile := filepath.Join(tmpDir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("content"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	id, err := sys.Move(types.Target{Path: testFile, Size: 7})
+	if err != nil {
+		t.Fatalf("failed to move to trash: %v", err)
+	}
+
+	dest := filepath.Join(tmpDir, "existing.txt")
+	if err := os.WriteFile(dest, []byte("keep me"), 0644); err != nil {
+		t.Fatalf("failed to create destination: %v", err)
+	}
+
+	if err := sys.RestoreTo(id, dest); err == nil {
+		t.Errorf("expected conflict error")
+	}
+	if _, err := sys.GetMetadata(id); err != nil {
+		t.Errorf("item should stay in trash after a failed restore: %v", err)
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "nested"), 0755); err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "nested", "file.txt"), []byte("data"), 0600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	// A read-only directory must still receive its content
+	if err := os.Chmod(filepath.Join(src, "nested"), 0555); err != nil {
+		t.Fatalf("failed to chmod: %v", err)
+	}
+	defer os.Chmod(filepath.Join(src, "nested"), 0755)
+
+	dst := filepath.Join(tmpDir, "dst")
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree failed: %v", err)
+	}
+	defer os.Chmod(filepath.Join(dst, "nested"), 0755)
+
+	data, err := os.ReadFile(filepath.Join(dst, "nested", "file.txt"))
+	if err != nil || string(data) != "data" {
+		t.Errorf("copied content mismatch: %q, %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dst, "nested", "file.txt"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("copied file should keep its mode: %v, %v", info, err)
+	}
+	info, err = os.Stat(filepath.Join(dst, "nested"))
+	if err != nil || info.Mode().Perm() != 0555 {
+		t.Errorf("copied directory should keep its mode: %v, %v", info, err)
+	}
+}
+
+// trashTree creates dir with the given files and moves it to the trash
+func trashTree(t *testing.T, sys *System, dir string, files map[string]string) string {
+	t.Helper()
+	var size int64
+	for name, content := range files {
+		size += int64(len(content))
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	id, err := sys.Move(types.Target{Path: dir, Size: size, IsDirectory: true})
+	if err != nil {
+		t.Fatalf("failed to move to trash: %v", err)
+	}
+	return id
+}
+
+// readFile returns the content of path, or "" if it cannot be read
+func readFile(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
+
+func TestSystem_RestoreWith_Replace(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	dir := filepath.Join(tmpDir, "node_modules")
+	id := trashTree(t, sys, dir, map[string]string{"old.js": "old"})
+
+	// The directory was recreated since the clean
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to recreate directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.js"), []byte("new"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	if err := sys.Restore(id); err == nil {
+		t.Fatalf("expected conflict error without a conflict mode")
+	}
+
+	if err := sys.RestoreWith(id, RestoreOptions{Conflict: ConflictReplace}); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	if readFile(filepath.Join(dir, "old.js")) != "old" {
+		t.Errorf("restored content missing")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.js")); !os.IsNotExist(err) {
+		t.Errorf("replaced content should be gone from the destination")
+	}
+
+	// The replaced directory is now in the trash
+	items, err := sys.List()
+	if err != nil {
+		t.Fatalf("failed to list trash: %v", err)
+	}
+	if len(items) != 1 || items[0].OriginalPath != dir {
+		t.Fatalf("replaced directory should be trashed, got %+v", items)
+	}
+	if readFile(filepath.Join(items[0].TrashPath, "content", "new.js")) != "new" {
+		t.Errorf("replaced content should be recoverable from the trash")
+	}
+}
+
+func TestSystem_RestoreWith_Merge(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		tmpDir := t.TempDir()
+		sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+		if err != nil {
+			t.Fatalf("failed to create trash system: %v", err)
+		}
+		sys.SetCompression(compressed)
+
+		dir := filepath.Join(tmpDir, "build")
+		id := trashTree(t, sys, dir, map[string]string{
+			"a.txt":     "trashed a",
+			"sub/b.txt": "trashed b",
+		})
+
+		if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+			t.Fatalf("failed to recreate directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("current a"), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "sub", "c.txt"), []byte("current c"), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+
+		if err := sys.RestoreWith(id, RestoreOptions{Conflict: ConflictMerge}); err != nil {
+			t.Fatalf("failed to merge (compressed=%v): %v", compressed, err)
+		}
+
+		if got := readFile(filepath.Join(dir, "a.txt")); got != "trashed a" {
+			t.Errorf("trashed file should win (compressed=%v), got %q", compressed, got)
+		}
+		if got := readFile(filepath.Join(dir, "sub", "b.txt")); got != "trashed b" {
+			t.Errorf("trashed file should be merged (compressed=%v), got %q", compressed, got)
+		}
+		if got := readFile(filepath.Join(dir, "sub", "c.txt")); got != "current c" {
+			t.Errorf("existing file should be kept (compressed=%v), got %q", compressed, got)
+		}
+		if _, err := os.Stat(filepath.Join(sys.GetTrashDir(), id)); !os.IsNotExist(err) {
+			t.Errorf("trash item should be removed after merge (compressed=%v)", compressed)
+		}
+	}
+}
+
+func TestSystem_Move_UniqueIDs(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	// Two targets with the same name trashed within the same second
+	first := trashTree(t, sys, filepath.Join(tmpDir, "a", "node_modules"), map[string]string{"x": "1"})
+	second := trashTree(t, sys, filepath.Join(tmpDir, "b", "node_modules"), map[string]string{"x": "2"})
+
+	if first == second {
+		t.Fatalf("trash IDs should be unique, got %s twice", first)
+	}
+	items, err := sys.List()
+	if err != nil || len(items) != 2 {
+		t.Fatalf("expected 2 trash items, got %d (%v)", len(items), err)
+	}
+}
+
+func TestSystem_RestoreWith_Only(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		tmpDir := t.TempDir()
+		sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+		if err != nil {
+			t.Fatalf("failed to create trash system: %v", err)
+		}
+		sys.SetCompression(compressed)
+
+		dir := filepath.Join(tmpDir, "node_modules")
+		id := trashTree(t, sys, dir, map[string]string{
+			".package-lock.json": "lock",
+			"left-pad/index.js":  "pad",
+			"left-pad/README.md": "readme",
+			"right-pad/index.js": "right",
+		})
+
+		opts := RestoreOptions{Only: []string{"node_modules/.package-lock.json", "left-pad"}}
+		if err := sys.RestoreWith(id, opts); err != nil {
+			t.Fatalf("failed to restore (compressed=%v): %v", compressed, err)
+		}
+
+		if got := readFile(filepath.Join(dir, ".package-lock.json")); got != "lock" {
+			t.Errorf("matched file should be restored (compressed=%v), got %q", compressed, got)
+		}
+		if got := readFile(filepath.Join(dir, "left-pad", "README.md")); got != "readme" {
+			t.Errorf("matched directory should be restored (compressed=%v), got %q", compressed, got)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "right-pad")); !os.IsNotExist(err) {
+			t.Errorf("unmatched paths should stay in the trash (compressed=%v)", compressed)
+		}
+		if _, err := sys.GetMetadata(id); err != nil {
+			t.Errorf("item should stay in the trash after a partial restore (compressed=%v): %v", compressed, err)
+		}
+
+		// Restoring the same file again conflicts unless replacing
+		opts.Only = []string{".package-lock.json"}
+		if err := sys.RestoreWith(id, opts); err == nil {
+			t.Errorf("expected conflict error (compressed=%v)", compressed)
+		}
+		opts.Conflict = ConflictReplace
+		if err := sys.RestoreWith(id, opts); err != nil {
+			t.Errorf("replace should overwrite the file (compressed=%v): %v", compressed, err)
+		}
+	}
+}
+
+// restoreReplaced restores the trash item of the path a restore replaced and
+// returns its content
+func restoreReplaced(t *testing.T, sys *System, path string) string {
+	t.Helper()
+	items, err := sys.List()
+	if err != nil {
+		t.Fatalf("failed to list trash: %v", err)
+	}
+	for _, item := range items {
+		if item.OriginalPath == path {
+			if err := sys.RestoreWith(item.ID, RestoreOptions{Conflict: ConflictReplace}); err != nil {
+				t.Fatalf("failed to restore replaced %s: %v", path, err)
+			}
+			return readFile(path)
+		}
+	}
+	t.Fatalf("replaced %s is not in the trash", path)
+	return ""
+}
+
+func TestSystem_RestoreWith_KeepsReplaced(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		tmpDir := t.TempDir()
+		sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+		if err != nil {
+			t.Fatalf("failed to create trash system: %v", err)
+		}
+		sys.SetCompression(compressed)
+
+		dir := filepath.Join(tmpDir, "node_modules")
+		files := map[string]string{".package-lock.json": "lock", "left-pad/index.js": "pad"}
+		id := trashTree(t, sys, dir, files)
+		if err := sys.RestoreWith(id, RestoreOptions{Only: []string{"left-pad"}}); err != nil {
+			t.Fatalf("failed to restore (compressed=%v): %v", compressed, err)
+		}
+
+		// Files a partial restore replaces are moved to the trash
+		lock := filepath.Join(dir, ".package-lock.json")
+		if err := os.WriteFile(lock, []byte("edited lock"), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+		opts := RestoreOptions{Only: []string{".package-lock.json"}, Conflict: ConflictReplace}
+		if err := sys.RestoreWith(id, opts); err != nil {
+			t.Fatalf("failed to replace (compressed=%v): %v", compressed, err)
+		}
+		if got := readFile(lock); got != "lock" {
+			t.Errorf("trashed file should be restored (compressed=%v), got %q", compressed, got)
+		}
+		if got := restoreReplaced(t, sys, lock); got != "edited lock" {
+			t.Errorf("replaced file should be recovered (compressed=%v), got %q", compressed, got)
+		}
+
+		// So are the files a merge replaces
+		index := filepath.Join(dir, "left-pad", "index.js")
+		if err := os.WriteFile(index, []byte("edited pad"), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+		if err := sys.RestoreWith(id, RestoreOptions{Conflict: ConflictMerge}); err != nil {
+			t.Fatalf("failed to merge (compressed=%v): %v", compressed, err)
+		}
+		if got := readFile(index); got != "pad" {
+			t.Errorf("trashed file should win (compressed=%v), got %q", compressed, got)
+		}
+		if got := restoreReplaced(t, sys, index); got != "edited pad" {
+			t.Errorf("replaced file should be recovered (compressed=%v), got %q", compressed, got)
+		}
+	}
+}
+
+func TestSystem_RestoreWith_OnlyNoMatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	id := trashTree(t, sys, filepath.Join(tmpDir, "dist"), map[string]string{"app.js": "app"})
+	if err := sys.RestoreWith(id, RestoreOptions{Only: []string{"*.css"}}); err == nil {
+		t.Errorf("expected an error when nothing matches")
+	}
+}
+
+func TestSystem_Purge(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	kept := trashTree(t, sys, filepath.Join(tmpDir, "dist"), map[string]string{"app.js": "app"})
+	purged := trashTree(t, sys, filepath.Join(tmpDir, "build"), map[string]string{"out.o": "obj"})
+
+	if err := sys.Purge(purged); err != nil {
+		t.Fatalf("failed to purge: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(sys.GetTrashDir(), purged)); !os.IsNotExist(err) {
+		t.Errorf("purged item should be removed from the trash")
+	}
+	if _, err := sys.GetMetadata(kept); err != nil {
+		t.Errorf("other items should stay in the trash: %v", err)
+	}
+
+	if err := sys.Purge(purged); err == nil {
+		t.Errorf("expected error when purging a missing item")
+	}
+	for _, id := range []string{"", "..", "../trash", kept + "/content", lockName, storeName, ".import-123"} {
+		if err := sys.Purge(id); err == nil {
+			t.Errorf("expected error for invalid ID %q", id)
+		}
+	}
+	if _, err := sys.GetMetadata(kept); err != nil {
+		t.Errorf("invalid IDs should not remove anything: %v", err)
+	}
+}
+
+func TestSystem_InvalidIDs(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	// A hidden directory that looks like an item must still be refused
+	hidden := filepath.Join(sys.GetTrashDir(), ".import-123")
+	if err := os.MkdirAll(hidden, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(hidden, "metadata.json"), []byte(`{"original_path":"/tmp/x"}`), 0644); err != nil {
+		t.Fatalf("failed to write metadata: %v", err)
+	}
+
+	for _, id := range []string{"", "..", "../trash", ".import-123", lockName, storeName} {
+		if _, err := sys.GetMetadata(id); err == nil {
+			t.Errorf("GetMetadata(%q): expected an error", id)
+		}
+		if err := sys.Restore(id); err == nil {
+			t.Errorf("Restore(%q): expected an error", id)
+		}
+		if err := sys.RestoreTo(id, filepath.Join(tmpDir, "restored")); err == nil {
+			t.Errorf("RestoreTo(%q): expected an error", id)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "restored")); !os.IsNotExist(err) {
+		t.Errorf("invalid IDs should not restore anything")
+	}
+	if _, err := os.Stat(hidden); err != nil {
+		t.Errorf("hidden directory should stay in place: %v", err)
+	}
+}
+
+func TestSystem_Stats(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	stats, err := sys.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	if stats.Items != 0 || stats.Oldest != nil {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+
+	targets := []types.Target{
+		{Path: filepath.Join(tmpDir, "a", "node_modules"), Size: 100, ProfileName: "Node.js"},
+		{Path: filepath.Join(tmpDir, "b", "node_modules"), Size: 50, ProfileName: "Node.js"},
+		{Path: filepath.Join(tmpDir, "target"), Size: 30},
+	}
+	var ids []string
+	for _, target := range targets {
+		if err := os.MkdirAll(filepath.Dir(target.Path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(target.Path, []byte("12345"), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+		id, err := sys.Move(target)
+		if err != nil {
+			t.Fatalf("failed to move to trash: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Backdate the first item so it is the oldest
+	metadataPath := filepath.Join(sys.GetTrashDir(), ids[0], "metadata.json")
+	metadata, err := sys.GetMetadata(ids[0])
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	metadata.DeletedAt = metadata.DeletedAt.Add(-48 * time.Hour)
+	data, _ := json.Marshal(metadata)
+	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+		t.Fatalf("failed to write metadata: %v", err)
+	}
+
+	stats, err = sys.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	if stats.Items != 3 {
+		t.Errorf("expected 3 items, got %d", stats.Items)
+	}
+	if stats.TotalSize != 180 {
+		t.Errorf("expected total size 180, got %d", stats.TotalSize)
+	}
+	if stats.DiskUsage < 15 {
+		t.Errorf("expected disk usage to include the item contents, got %d", stats.DiskUsage)
+	}
+	if stats.Oldest == nil || stats.Oldest.ID != ids[0] {
+		t.Errorf("expected oldest item %s, got %+v", ids[0], stats.Oldest)
+	}
+	if node := stats.Profiles["Node.js"]; node == nil || node.Items != 2 || node.Size != 150 {
+		t.Errorf("unexpected Node.js stats: %+v", node)
+	}
+	if other := stats.Profiles[types.UnknownProfile]; other == nil || other.Items != 1 {
+		t.Errorf("items without a profile should be grouped under %s: %+v", types.UnknownProfile, other)
+	}
+}
+
+func TestSystem_ListWith(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	targets := []types.Target{
+		{Path: filepath.Join(tmpDir, "a", "node_modules"), ProfileName: "Node.js"},
+		{Path: filepath.Join(tmpDir, "b", "node_modules"), ProfileName: "Node.js"},
+		{Path: filepath.Join(tmpDir, "b", "target"), ProfileName: "Rust"},
+		{Path: filepath.Join(tmpDir, "c", "dist")},
+	}
+	var ids []string
+	for _, target := range targets {
+		if err := os.MkdirAll(filepath.Dir(target.Path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(target.Path, []byte("content"), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+		id, err := sys.Move(target)
+		if err != nil {
+			t.Fatalf("failed to move to trash: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Backdate the first item by a week
+	metadata, err := sys.GetMetadata(ids[0])
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	metadata.DeletedAt = metadata.DeletedAt.Add(-7 * 24 * time.Hour)
+	if err := writeMetadata(filepath.Join(sys.GetTrashDir(), ids[0]), metadata); err != nil {
+		t.Fatalf("failed to write metadata: %v", err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name   string
+		filter ListFilter
+		want   []string
+	}{
+		{"no filter", ListFilter{}, ids},
+		{"profile", ListFilter{Profile: "node.js"}, ids[:2]},
+		{"unknown profile", ListFilter{Profile: "Go"}, nil},
+		{"base name", ListFilter{PathPattern: "node_*"}, ids[:2]},
+		{"full path", ListFilter{PathPattern: filepath.Join(tmpDir, "b", "*")}, ids[1:3]},
+		{"after", ListFilter{DeletedAfter: now.Add(-24 * time.Hour)}, ids[1:]},
+		{"before", ListFilter{DeletedBefore: now.Add(-24 * time.Hour)}, ids[:1]},
+		{"combined", ListFilter{Profile: "Node.js", DeletedAfter: now.Add(-24 * time.Hour)}, ids[1:2]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := sys.ListWith(tt.filter)
+			if err != nil {
+				t.Fatalf("failed to list: %v", err)
+			}
+			var got []string
+			for _, item := range items {
+				got = append(got, item.ID)
+			}
+			sort.Strings(got)
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestSystem_Verify(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		tmpDir := t.TempDir()
+		sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+		if err != nil {
+			t.Fatalf("failed to create trash system: %v", err)
+		}
+		sys.SetCompression(compressed)
+		sys.SetChecksums(true)
+
+		dir := filepath.Join(tmpDir, "node_modules")
+		id := trashTree(t, sys, dir, map[string]string{
+			"a/index.js": "a",
+			"b/index.js": "b",
+		})
+
+		metadata, err := sys.GetMetadata(id)
+		if err != nil {
+			t.Fatalf("failed to get metadata: %v", err)
+		}
+		if !strings.HasPrefix(metadata.Checksum, "sha256:") {
+			t.Errorf("expected a checksum in metadata (compressed=%v), got %q", compressed, metadata.Checksum)
+		}
+		if err := sys.Verify(id); err != nil {
+			t.Errorf("unmodified item should verify (compressed=%v): %v", compressed, err)
+		}
+
+		// Damage the stored content
+		if compressed {
+			err = os.WriteFile(filepath.Join(sys.GetTrashDir(), id, archiveName), []byte("garbage"), 0644)
+		} else {
+			err = os.Remove(filepath.Join(sys.GetTrashDir(), id, "content", "b", "index.js"))
+		}
+		if err != nil {
+			t.Fatalf("failed to modify trash content: %v", err)
+		}
+
+		var mismatch types.ErrChecksumMismatch
+		if err := sys.Verify(id); !errors.As(err, &mismatch) {
+			t.Errorf("expected ErrChecksumMismatch (compressed=%v), got %v", compressed, err)
+		}
+	}
+}
+
+func TestSystem_Verify_Modified(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	sys.SetChecksums(true)
+
+	dir := filepath.Join(tmpDir, "dist")
+	id := trashTree(t, sys, dir, map[string]string{"app.js": "app"})
+	if err := os.WriteFile(filepath.Join(sys.GetTrashDir(), id, "content", "app.js"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("failed to modify trash content: %v", err)
+	}
+
+	var mismatch types.ErrChecksumMismatch
+	if err := sys.Verify(id); !errors.As(err, &mismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+
+	// The restore still goes ahead with what is left
+	if err := sys.Restore(id); err != nil {
+		t.Fatalf("restore should succeed despite the mismatch: %v", err)
+	}
+	if got := readFile(filepath.Join(dir, "app.js")); got != "changed" {
+		t.Errorf("expected restored content %q, got %q", "changed", got)
+	}
+}
+
+func TestSystem_Verify_NoChecksum(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	// Checksums are only recorded once enabled, as items trashed by older
+	// versions have none
+	id := trashTree(t, sys, filepath.Join(tmpDir, "dist"), map[string]string{"app.js": "app"})
+	metadata, err := sys.GetMetadata(id)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	if metadata.Checksum != "" {
+		t.Errorf("expected no checksum without SetChecksums, got %q", metadata.Checksum)
+	}
+	if err := os.WriteFile(filepath.Join(sys.GetTrashDir(), id, "content", "app.js"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("failed to modify trash content: %v", err)
+	}
+
+	if err := sys.Verify(id); err != nil {
+		t.Errorf("items without a checksum should verify: %v", err)
+	}
+}
+
+func TestSystem_SetPinned(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	sys.SetChecksums(true)
+
+	pinned := trashTree(t, sys, filepath.Join(tmpDir, "dist"), map[string]string{"app.js": "app"})
+	unpinned := trashTree(t, sys, filepath.Join(tmpDir, "build"), map[string]string{"out.o": "obj"})
+
+	if err := sys.SetPinned(pinned, true); err != nil {
+		t.Fatalf("failed to pin: %v", err)
+	}
+	metadata, err := sys.GetMetadata(pinned)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	if !metadata.Pinned {
+		t.Errorf("expected item to be pinned")
+	}
+	if metadata.Checksum == "" {
+		t.Errorf("pinning should keep the rest of the metadata")
+	}
+
+	// Retention cleanup skips pinned items
+	if err := sys.Clean(0); err != nil {
+		t.Fatalf("failed to clean trash: %v", err)
+	}
+	if _, err := sys.GetMetadata(pinned); err != nil {
+		t.Errorf("pinned item should survive clean: %v", err)
+	}
+	if _, err := sys.GetMetadata(unpinned); err == nil {
+		t.Errorf("unpinned item should be removed by clean")
+	}
+
+	items, err := sys.List()
+	if err != nil {
+		t.Fatalf("failed to list trash items: %v", err)
+	}
+	if len(items) != 1 || !items[0].Pinned {
+		t.Errorf("expected the pinned item in the list, got %+v", items)
+	}
+
+	if err := sys.SetPinned(pinned, false); err != nil {
+		t.Fatalf("failed to unpin: %v", err)
+	}
+	if err := sys.Clean(0); err != nil {
+		t.Fatalf("failed to clean trash: %v", err)
+	}
+	if _, err := sys.GetMetadata(pinned); err == nil {
+		t.Errorf("unpinned item should be removed by clean")
+	}
+
+	if err := sys.SetPinned("missing", true); err == nil {
+		t.Errorf("expected error when pinning a missing item")
+	}
+}
+
+// storeFiles returns the number of entries in the content store of sys
+func storeFiles(t *testing.T, sys *System) int {
+	t.Helper()
+	count := 0
+	err := filepath.WalkDir(sys.storeDir(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			count++
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("failed to walk store: %v", err)
+	}
+	return count
+}
+
+func TestSystem_Dedup(t *testing.T) {
+	if !dedupSupported {
+		t.Skip("deduplication is not supported on this platform")
+	}
+
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	sys.SetDeduplication(true)
+	sys.SetChecksums(true)
+
+	a := filepath.Join(tmpDir, "a", "node_modules")
+	b := filepath.Join(tmpDir, "b", "node_modules")
+	idA := trashTree(t, sys, a, map[string]string{"lib/index.js": "shared", "a.js": "only in a"})
+	idB := trashTree(t, sys, b, map[string]string{"lib/index.js": "shared", "b.js": "only in b", "copy.js": "shared"})
+
+	if n := storeFiles(t, sys); n != 3 {
+		t.Errorf("expected 3 store entries, got %d", n)
+	}
+	sharedA, _ := os.Lstat(filepath.Join(sys.GetTrashDir(), idA, "content", "lib", "index.js"))
+	sharedB, _ := os.Lstat(filepath.Join(sys.GetTrashDir(), idB, "content", "copy.js"))
+	if sharedA == nil || sharedB == nil || !os.SameFile(sharedA, sharedB) {
+		t.Errorf("expected identical files to share storage")
+	}
+
+	stats, err := sys.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	var counted int64
+	for _, id := range []string{idA, idB} {
+		usage, err := diskUsage(filepath.Join(sys.GetTrashDir(), id), map[[2]uint64]bool{})
+		if err != nil {
+			t.Fatalf("failed to measure item: %v", err)
+		}
+		counted += usage
+	}
+	// Both items link to the same "shared" file, which takes space once
+	if want := counted - int64(len("shared")); stats.DiskUsage != want {
+		t.Errorf("expected shared files to be counted once (disk usage %d), got %d", want, stats.DiskUsage)
+	}
+
+	if err := sys.Restore(idA); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	restored, err := os.Lstat(filepath.Join(a, "lib", "index.js"))
+	if err != nil {
+		t.Fatalf("restored file missing: %v", err)
+	}
+	if os.SameFile(restored, sharedB) {
+		t.Errorf("restored file must not share storage with the trash")
+	}
+	if links, _ := linkCount(restored); links != 1 {
+		t.Errorf("expected restored file to have 1 link, got %d", links)
+	}
+	if got := readFile(filepath.Join(a, "a.js")); got != "only in a" {
+		t.Errorf("unexpected restored content %q", got)
+	}
+
+	// Modifying the restored file leaves the trashed copies alone
+	if err := os.WriteFile(filepath.Join(a, "lib", "index.js"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("failed to modify restored file: %v", err)
+	}
+	if err := sys.Verify(idB); err != nil {
+		t.Errorf("expected remaining item to be intact: %v", err)
+	}
+	if n := storeFiles(t, sys); n != 2 {
+		t.Errorf("expected entries only used by the restored item to be released, got %d entries", n)
+	}
+
+	if err := sys.Purge(idB); err != nil {
+		t.Fatalf("failed to purge: %v", err)
+	}
+	if n := storeFiles(t, sys); n != 0 {
+		t.Errorf("expected empty store after purging every item, got %d entries", n)
+	}
+}
+
+func TestSystem_Dedup_RestoreSharedWithinItem(t *testing.T) {
+	if !dedupSupported {
+		t.Skip("deduplication is not supported on this platform")
+	}
+
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	sys.SetDeduplication(true)
+
+	dir := filepath.Join(tmpDir, "node_modules")
+	id := trashTree(t, sys, dir, map[string]string{"x/LICENSE": "MIT", "y/LICENSE": "MIT"})
+	if err := sys.Restore(id); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	for _, name := range []string{"x/LICENSE", "y/LICENSE"} {
+		info, err := os.Lstat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("restored file missing: %v", err)
+		}
+		if links, _ := linkCount(info); links != 1 {
+			t.Errorf("expected %s to have 1 link, got %d", name, links)
+		}
+	}
+	if n := storeFiles(t, sys); n != 0 {
+		t.Errorf("expected empty store, got %d entries", n)
+	}
+}
+
+func TestSystem_ExportImport(t *testing.T) {
+	tmpDir := t.TempDir()
+	src, err := NewSystem(filepath.Join(tmpDir, "src-trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	src.SetChecksums(true)
+
+	dist := filepath.Join(tmpDir, "dist")
+	rawID := trashTree(t, src, dist, map[string]string{"app.js": "app"})
+	src.SetCompression(true)
+	modules := filepath.Join(tmpDir, "node_modules")
+	compressedID := trashTree(t, src, modules, map[string]string{"pkg/index.js": "pkg"})
+
+	var buf bytes.Buffer
+	if err := src.Export([]string{rawID, compressedID}, &buf); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	exported := buf.Bytes()
+
+	dst, err := NewSystem(filepath.Join(tmpDir, "dst-trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	ids, err := dst.Import(bytes.NewReader(exported))
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	sort.Strings(ids)
+	want := []string{rawID, compressedID}
+	sort.Strings(want)
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected the exported IDs %v, got %v", want, ids)
+	}
+
+	for _, id := range ids {
+		if err := dst.Verify(id); err != nil {
+			t.Errorf("imported item %s should verify: %v", id, err)
+		}
+		if err := dst.Restore(id); err != nil {
+			t.Fatalf("failed to restore imported item %s: %v", id, err)
+		}
+	}
+	if got := readFile(filepath.Join(dist, "app.js")); got != "app" {
+		t.Errorf("expected restored content %q, got %q", "app", got)
+	}
+	if got := readFile(filepath.Join(modules, "pkg", "index.js")); got != "pkg" {
+		t.Errorf("expected restored content %q, got %q", "pkg", got)
+	}
+
+	// Importing the same items again keeps both copies
+	first, err := dst.Import(bytes.NewReader(exported))
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	second, err := dst.Import(bytes.NewReader(exported))
+	if err != nil {
+		t.Fatalf("failed to import again: %v", err)
+	}
+	for i := range first {
+		if first[i] == second[i] {
+			t.Errorf("re-imported item should get a new ID, got %s twice", first[i])
+		}
+		metadata, err := dst.GetMetadata(second[i])
+		if err != nil {
+			t.Fatalf("failed to get metadata: %v", err)
+		}
+		if metadata.ID != second[i] {
+			t.Errorf("metadata ID should match the new ID %s, got %s", second[i], metadata.ID)
+		}
+	}
+	items, err := dst.List()
+	if err != nil {
+		t.Fatalf("failed to list trash items: %v", err)
+	}
+	if len(items) != 4 {
+		t.Errorf("expected 4 items after importing twice, got %d", len(items))
+	}
+}
+
+func TestSystem_Import_Invalid(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		entries []tar.Header
+	}{
+		{
+			name:    "missing metadata",
+			entries: []tar.Header{{Name: "item/content", Typeflag: tar.TypeDir, Mode: 0755}},
+		},
+		{
+			name:    "path escaping the trash",
+			entries: []tar.Header{{Name: "../escaped", Typeflag: tar.TypeReg, Mode: 0644}},
+		},
+		{
+			name: "file below a symlink",
+			entries: []tar.Header{
+				{Name: "item", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "item/content", Typeflag: tar.TypeSymlink, Linkname: tmpDir},
+				{Name: "item/content/escaped", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("failed to create writer: %v", err)
+		}
+		tw := tar.NewWriter(zw)
+		for _, header := range tt.entries {
+			if err := tw.WriteHeader(&header); err != nil {
+				t.Fatalf("failed to write header: %v", err)
+			}
+		}
+		tw.Close()
+		zw.Close()
+
+		if _, err := sys.Import(&buf); err == nil {
+			t.Errorf("%s: expected import to fail", tt.name)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("import should not write outside the trash")
+	}
+	items, err := sys.List()
+	if err != nil {
+		t.Fatalf("failed to list trash items: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("failed imports should not add items, got %d", len(items))
+	}
+	entries, _ := os.ReadDir(sys.GetTrashDir())
+	for _, entry := range entries {
+		if entry.Name() != lockName {
+			t.Errorf("failed imports should clean up, found %s", entry.Name())
+		}
+	}
+}
+
+func TestSystem_CleanItemRetention(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	move := func(name string, retention time.Duration) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+		id, err := sys.MoveWith(types.Target{Path: path}, MoveOptions{Retention: retention})
+		if err != nil {
+			t.Fatalf("failed to move to trash: %v", err)
+		}
+		return id
+	}
+
+	routine := move("cache", 0)
+	risky := move("src", 30*24*time.Hour)
+	short := move("tmp", time.Nanosecond)
+
+	metadata, err := sys.GetMetadata(risky)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	if metadata.Retention != 30*24*time.Hour {
+		t.Errorf("expected retention to be stored in metadata, got %v", metadata.Retention)
+	}
+
+	// Clean with a default retention of 0 removes items without their own
+	// retention, and items whose retention has passed
+	time.Sleep(time.Millisecond)
+	if err := sys.Clean(0); err != nil {
+		t.Fatalf("failed to clean trash: %v", err)
+	}
+	if _, err := sys.GetMetadata(routine); err == nil {
+		t.Errorf("item with the default retention should be removed")
+	}
+	if _, err := sys.GetMetadata(short); err == nil {
+		t.Errorf("item past its own retention should be removed")
+	}
+	if _, err := sys.GetMetadata(risky); err != nil {
+		t.Errorf("item within its own retention should be kept: %v", err)
+	}
+
+	// An item's own retention also applies when it is shorter than the default
+	long := time.Hour
+	short = move("tmp2", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if err := sys.Clean(long); err != nil {
+		t.Fatalf("failed to clean trash: %v", err)
+	}
+	if _, err := sys.GetMetadata(short); err == nil {
+		t.Errorf("item past its own retention should be removed despite a longer default")
+	}
+}
+
+func TestSystem_Preview(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	dir := filepath.Join(tmpDir, "dist")
+	id := trashTree(t, sys, dir, map[string]string{"app.js": "app"})
+
+	preview, err := sys.Preview(id, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("failed to preview: %v", err)
+	}
+	if preview.Dest != dir {
+		t.Errorf("expected destination %s, got %s", dir, preview.Dest)
+	}
+	if preview.Exists || preview.Err != nil {
+		t.Errorf("expected no conflict, got exists=%v err=%v", preview.Exists, preview.Err)
+	}
+	if preview.SpaceNeeded != 0 {
+		t.Errorf("a rename within the filesystem should need no space, got %d", preview.SpaceNeeded)
+	}
+
+	// A conflict fails unless the restore replaces or merges
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	preview, err = sys.Preview(id, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("failed to preview: %v", err)
+	}
+	if !preview.Exists || preview.Err == nil {
+		t.Errorf("expected a conflict, got exists=%v err=%v", preview.Exists, preview.Err)
+	}
+	preview, err = sys.Preview(id, RestoreOptions{Conflict: ConflictMerge})
+	if err != nil {
+		t.Fatalf("failed to preview: %v", err)
+	}
+	if preview.Err != nil {
+		t.Errorf("merging into a directory should succeed: %v", preview.Err)
+	}
+
+	// Nothing is changed
+	if _, err := sys.GetMetadata(id); err != nil {
+		t.Errorf("preview should leave the item in the trash: %v", err)
+	}
+	if got := readFile(filepath.Join(dir, "app.js")); got != "" {
+		t.Errorf("preview should not restore anything, found %q", got)
+	}
+
+	// Alternate locations and compressed items
+	sys.SetCompression(true)
+	compressed := trashTree(t, sys, filepath.Join(tmpDir, "build"), map[string]string{"out.o": "obj"})
+	metadata, err := sys.GetMetadata(compressed)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	to := filepath.Join(tmpDir, "recovered", "build")
+	preview, err = sys.Preview(compressed, RestoreOptions{To: to})
+	if err != nil {
+		t.Fatalf("failed to preview: %v", err)
+	}
+	if preview.Dest != to {
+		t.Errorf("expected destination %s, got %s", to, preview.Dest)
+	}
+	if metadata.Size == 0 || preview.SpaceNeeded != metadata.Size {
+		t.Errorf("compressed items need their full size %d, got %d", metadata.Size, preview.SpaceNeeded)
+	}
+}
+
+func TestSystem_Lock(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skip("file locking not supported on " + runtime.GOOS)
+	}
+
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	id := trashTree(t, sys, filepath.Join(tmpDir, "dist"), map[string]string{"app.js": "app"})
+
+	// Another process holding the lock, e.g. a running clean
+	other, err := NewSystem(sys.GetTrashDir())
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	unlock, err := other.lock(false)
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+
+	// Moves share the lock
+	path := filepath.Join(tmpDir, "cache")
+	if err := os.WriteFile(path, []byte("cache"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	if _, err := sys.Move(types.Target{Path: path}); err != nil {
+		t.Fatalf("move should not wait for a shared lock: %v", err)
+	}
+
+	// Purging an item waits until the lock is released
+	done := make(chan error, 1)
+	go func() { done <- sys.Purge(id) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("purge should wait for the lock, returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := sys.GetMetadata(id); err != nil {
+		t.Fatalf("item should be untouched while locked: %v", err)
+	}
+
+	unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("failed to purge: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("purge did not finish after the lock was released")
+	}
+}
+
+func TestSystem_ConcurrentMoveAndClean(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	sys.SetChecksums(true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("project%d", i), "node_modules")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("x"), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := sys.Move(types.Target{Path: dir, IsDirectory: true}); err != nil {
+				t.Errorf("failed to move to trash: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := sys.Clean(time.Hour); err != nil {
+				t.Errorf("failed to clean trash: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	items, err := sys.List()
+	if err != nil {
+		t.Fatalf("failed to list trash items: %v", err)
+	}
+	if len(items) != 20 {
+		t.Errorf("expected 20 items, got %d", len(items))
+	}
+	for _, item := range items {
+		if err := sys.Verify(item.ID); err != nil {
+			t.Errorf("item %s is damaged: %v", item.ID, err)
+		}
+	}
+}
+
+func TestSystem_List_SkipsIncompleteItems(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	// An item whose move was interrupted before its metadata was written
+	if err := os.MkdirAll(filepath.Join(sys.GetTrashDir(), "20250101_000000_dist", "content"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	items, err := sys.List()
+	if err != nil {
+		t.Fatalf("failed to list trash items: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected incomplete items to be skipped, got %d", len(items))
+	}
+}
+
+func TestSystem_MigrateTo(t *testing.T) {
+	tmpDir := t.TempDir()
+	src, err := NewSystem(filepath.Join(tmpDir, "old-trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	dst, err := NewSystem(filepath.Join(tmpDir, "new-trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	dist := filepath.Join(tmpDir, "dist")
+	id := trashTree(t, src, dist, map[string]string{"app.js": "app"})
+	if err := src.SetPinned(id, true); err != nil {
+		t.Fatalf("failed to pin: %v", err)
+	}
+	src.SetCompression(true)
+	trashTree(t, src, filepath.Join(tmpDir, "build"), map[string]string{"out.o": "obj"})
+
+	// An item with the same ID already in the new location
+	if err := os.MkdirAll(filepath.Join(dst.GetTrashDir(), id, "content"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := writeMetadata(filepath.Join(dst.GetTrashDir(), id), &types.TrashMetadata{ID: id, OriginalPath: "/elsewhere"}); err != nil {
+		t.Fatalf("failed to write metadata: %v", err)
+	}
+
+	moved, err := src.MigrateTo(dst)
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if moved != 2 {
+		t.Errorf("expected 2 items migrated, got %d", moved)
+	}
+
+	items, err := src.List()
+	if err != nil {
+		t.Fatalf("failed to list trash items: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected the old trash to be empty, got %d items", len(items))
+	}
+
+	items, err = dst.List()
+	if err != nil {
+		t.Fatalf("failed to list trash items: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items in the new trash, got %d", len(items))
+	}
+	for _, item := range items {
+		if item.OriginalPath != dist {
+			continue
+		}
+		if item.ID == id {
+			t.Errorf("migrated item should not replace the existing item %s", id)
+		}
+		if !item.Pinned {
+			t.Errorf("migrated item should keep its metadata")
+		}
+		if err := dst.Restore(item.ID); err != nil {
+			t.Fatalf("failed to restore migrated item: %v", err)
+		}
+	}
+	if got := readFile(filepath.Join(dist, "app.js")); got != "app" {
+		t.Errorf("expected restored content %q, got %q", "app", got)
+	}
+
+	// Migrating to the same directory is a no-op
+	same, err := NewSystem(dst.GetTrashDir() + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	if moved, err := dst.MigrateTo(same); err != nil || moved != 0 {
+		t.Errorf("expected no-op migration, got %d, %v", moved, err)
+	}
+}
+
+func TestSystem_Fsck(t *testing.T) {
+	tmpDir := t.TempDir()
+	sys, err := NewSystem(filepath.Join(tmpDir, "trash"))
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	trashDir := sys.GetTrashDir()
+
+	good := trashTree(t, sys, filepath.Join(tmpDir, "good"), map[string]string{"a.js": "a"})
+	renamed := trashTree(t, sys, filepath.Join(tmpDir, "renamed"), map[string]string{"b.js": "b"})
+	tmpOnly := trashTree(t, sys, filepath.Join(tmpDir, "tmp-only"), map[string]string{"c.js": "c"})
+	noContent := trashTree(t, sys, filepath.Join(tmpDir, "no-content"), map[string]string{"d.js": "d"})
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	// An item copied to another directory keeps its old ID
+	metadata, err := sys.GetMetadata(renamed)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	moved := renamed + "_copy"
+	if err := os.Rename(filepath.Join(trashDir, renamed), filepath.Join(trashDir, moved)); err != nil {
+		t.Fatalf("failed to rename item: %v", err)
+	}
+	// Metadata whose rename into place was interrupted
+	if err := os.Rename(filepath.Join(trashDir, tmpOnly, "metadata.json"), filepath.Join(trashDir, tmpOnly, "metadata.json.tmp")); err != nil {
+		t.Fatalf("failed to rename metadata: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(trashDir, noContent, "content")); err != nil {
+		t.Fatalf("failed to remove content: %v", err)
+	}
+	write(filepath.Join(trashDir, "20250101_000000_orphan", "content", "e.js"), "orphaned")
+	write(filepath.Join(trashDir, "20250101_000000_corrupt", "metadata.json"), "{")
+	write(filepath.Join(trashDir, "20250101_000000_corrupt", "content", "f.js"), "f")
+	write(filepath.Join(trashDir, ".import-123", "x", "metadata.json"), "{}")
+	write(filepath.Join(trashDir, good, "extracted", "a.js"), "a")
+
+	report, err := sys.Fsck(true)
+	if err != nil {
+		t.Fatalf("failed to check trash: %v", err)
+	}
+	if len(report.Problems) != 7 {
+		t.Errorf("expected 7 problems, got %d: %+v", len(report.Problems), report.Problems)
+	}
+	if _, err := os.Stat(filepath.Join(trashDir, "20250101_000000_orphan")); err != nil {
+		t.Errorf("a dry run must not change anything: %v", err)
+	}
+
+	report, err = sys.Fsck(false)
+	if err != nil {
+		t.Fatalf("failed to fix trash: %v", err)
+	}
+	actions := map[FsckAction]int{}
+	for _, problem := range report.Problems {
+		if problem.Err != nil {
+			t.Errorf("failed to fix %s: %v", problem.Path, problem.Err)
+		}
+		actions[problem.Action]++
+	}
+	if actions[FsckRepaired] != 2 || actions[FsckQuarantined] != 2 || actions[FsckReclaimed] != 3 {
+		t.Errorf("unexpected actions: %v", actions)
+	}
+	if report.Reclaimed < int64(len("orphaned")) {
+		t.Errorf("expected reclaimed space to be reported, got %d", report.Reclaimed)
+	}
+
+	items, err := sys.List()
+	if err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	sort.Strings(ids)
+	want := []string{good, moved, tmpOnly}
+	sort.Strings(want)
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("expected items %v after repair, got %v", want, ids)
+	}
+	if metadata, err := sys.GetMetadata(moved); err != nil || metadata.ID != moved {
+		t.Errorf("expected the ID to be fixed: %+v, %v", metadata, err)
+	}
+	if err := sys.Restore(moved); err != nil {
+		t.Errorf("repaired item should restore: %v", err)
+	}
+	if got := readFile(filepath.Join(metadata.OriginalPath, "b.js")); got != "b" {
+		t.Errorf("unexpected restored content %q", got)
+	}
+
+	for _, id := range []string{noContent, "20250101_000000_corrupt"} {
+		if _, err := os.Stat(filepath.Join(trashDir, quarantineName, id)); err != nil {
+			t.Errorf("expected %s to be quarantined: %v", id, err)
+		}
+	}
+	for _, path := range []string{"20250101_000000_orphan", ".import-123", filepath.Join(good, "extracted")} {
+		if _, err := os.Stat(filepath.Join(trashDir, path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be reclaimed", path)
+		}
+	}
+
+	report, err = sys.Fsck(false)
+	if err != nil {
+		t.Fatalf("failed to check trash: %v", err)
+	}
+	if len(report.Problems) != 0 {
+		t.Errorf("expected a clean trash after repair, got %+v", report.Problems)
+	}
+}
+
+func TestSystem_Trim(t *testing.T) {
+	tmpDir := t.TempDir()
+	trashDir := filepath.Join(tmpDir, "trash")
+	earlier, err := NewSystem(trashDir)
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+
+	// Items of an earlier clean, the pinned one being the oldest
+	content := strings.Repeat("x", 10000)
+	var ids []string
+	for i, name := range []string{"pinned", "oldest", "older"} {
+		id := trashTree(t, earlier, filepath.Join(tmpDir, name), map[string]string{"file": content})
+		metadata, err := earlier.GetMetadata(id)
+		if err != nil {
+			t.Fatalf("failed to get metadata: %v", err)
+		}
+		metadata.DeletedAt = metadata.DeletedAt.Add(-time.Duration(3-i) * time.Hour)
+		if err := writeMetadata(filepath.Join(trashDir, id), metadata); err != nil {
+			t.Fatalf("failed to write metadata: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := earlier.SetPinned(ids[0], true); err != nil {
+		t.Fatalf("failed to pin: %v", err)
+	}
+
+	// Without a limit, nothing is removed
+	sys, err := NewSystem(trashDir)
+	if err != nil {
+		t.Fatalf("failed to create trash system: %v", err)
+	}
+	if removed, err := sys.Trim(); err != nil || len(removed) != 0 {
+		t.Fatalf("expected nothing to be trimmed without a limit, got %v, %v", removed, err)
+	}
+
+	// Over the limit, the oldest unpinned items go until the trash fits
+	sys.SetMaxSize(32000)
+	current := trashTree(t, sys, filepath.Join(tmpDir, "current"), map[string]string{"file": content})
+	removed, err := sys.Trim()
+	if err != nil {
+		t.Fatalf("Trim failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0].ID != ids[1] {
+		t.Fatalf("expected only %s to be removed, got %+v", ids[1], removed)
+	}
+	for _, id := range []string{ids[0], ids[2], current} {
+		if _, err := sys.GetMetadata(id); err != nil {
+			t.Errorf("expected %s to be kept: %v", id, err)
+		}
+	}
+
+	// The items of the current clean are kept even over the limit
+	sys.SetMaxSize(1)
+	removed, err = sys.Trim()
+	if err != nil {
+		t.Fatalf("Trim failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0].ID != ids[2] {
+		t.Fatalf("expected only %s to be removed, got %+v", ids[2], removed)
+	}
+	if _, err := sys.GetMetadata(current); err != nil {
+		t.Errorf("expected the item of the current clean to be kept: %v", err)
+	}
+}
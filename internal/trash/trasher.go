@@ -0,0 +1,31 @@
+package trash
+
+import "github.com/raucheacho/rosia-cli/pkg/types"
+
+// Trasher moves targets somewhere they can be restored from.
+//
+// System is the default implementation. Alternatives, such as the operating
+// system's trash, a remote backup or an in-memory fake for tests, can be
+// passed to the cleaner instead.
+type Trasher interface {
+	// MoveWith moves target away according to opts and returns the ID it
+	// can be restored with
+	MoveWith(target types.Target, opts MoveOptions) (string, error)
+
+	// Restore puts the item id back at its original location
+	Restore(id string) error
+}
+
+// Trimmer is implemented by Trashers with a size limit. The cleaner trims
+// them after each clean.
+type Trimmer interface {
+	// Trim removes the oldest items until the limit is met, keeping those of
+	// the current clean, and returns them
+	Trim() ([]types.TrashItem, error)
+}
+
+// System implements Trasher and Trimmer
+var (
+	_ Trasher = (*System)(nil)
+	_ Trimmer = (*System)(nil)
+)
@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// sparkTicks are the heights of a sparkline, lowest first
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// Bar is a row of a bar chart
+type Bar struct {
+	Label string // Shown before the bar
+	Value int64  // Length of the bar, relative to the largest value
+	Text  string // Shown after the bar, e.g. the formatted value
+}
+
+// Sparkline renders values as a line of block characters, one per value,
+// scaled to the largest value. Zero values are left blank rather than drawn
+// as the lowest tick, so that empty periods stand out.
+func Sparkline(values []int64) string {
+	var largest int64
+	for _, value := range values {
+		largest = max(largest, value)
+	}
+
+	var b strings.Builder
+	for _, value := range values {
+		if value <= 0 || largest == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		tick := int(value * int64(len(sparkTicks)-1) / largest)
+		b.WriteRune(sparkTicks[tick])
+	}
+	return infoStyle.Render(b.String())
+}
+
+// Trend renders values as a sparkline scaled between their smallest and
+// largest value rather than from zero, so that small changes of large
+// values, like the free space of a disk, show. Constant values are drawn
+// at mid-height.
+func Trend(values []int64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lowest, highest := slices.Min(values), slices.Max(values)
+
+	var b strings.Builder
+	for _, value := range values {
+		tick := len(sparkTicks) / 2
+		if highest > lowest {
+			tick = int((value - lowest) * int64(len(sparkTicks)-1) / (highest - lowest))
+		}
+		b.WriteRune(sparkTicks[tick])
+	}
+	return infoStyle.Render(b.String())
+}
+
+// BarChart renders bars, one per line, with bars of at most width cells
+// scaled to the largest value. Non-zero values get at least one cell.
+func BarChart(bars []Bar, width int) string {
+	var largest int64
+	labelWidth := 0
+	for _, bar := range bars {
+		largest = max(largest, bar.Value)
+		labelWidth = max(labelWidth, len([]rune(bar.Label)))
+	}
+
+	var b strings.Builder
+	for _, bar := range bars {
+		cells := 0
+		if bar.Value > 0 && largest > 0 {
+			cells = max(1, int(bar.Value*int64(width)/largest))
+		}
+		fmt.Fprintf(&b, "%-*s %s%s %s\n",
+			labelWidth, bar.Label,
+			infoStyle.Render(strings.Repeat("█", cells)),
+			strings.Repeat(" ", width-cells),
+			helpStyle.MarginTop(0).Render(bar.Text),
+		)
+	}
+	return b.String()
+}
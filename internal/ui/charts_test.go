@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"regexp"
+	"testing"
+)
+
+// ansiEscape matches the styling lipgloss adds to a rendered string
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// plain returns s without its styling
+func plain(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		want   string
+	}{
+		{name: "empty", values: nil, want: ""},
+		{name: "single value", values: []int64{42}, want: "█"},
+		{name: "all zero", values: []int64{0, 0}, want: "  "},
+		// Scaled from zero to the largest value, zero and negative values blank
+		{name: "scaling", values: []int64{0, 1, 4, 8, -3}, want: " ▁▄█ "},
+	}
+
+	for _, tt := range tests {
+		if got := plain(Sparkline(tt.values)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestTrend(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		want   string
+	}{
+		{name: "empty", values: nil, want: ""},
+		{name: "single value", values: []int64{42}, want: "▅"},
+		{name: "constant", values: []int64{7, 7, 7}, want: "▅▅▅"},
+		// Scaled between the smallest and largest value, not from zero
+		{name: "scaling", values: []int64{1000, 1003, 1007}, want: "▁▄█"},
+	}
+
+	for _, tt := range tests {
+		if got := plain(Trend(tt.values)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestBarChart(t *testing.T) {
+	tests := []struct {
+		name  string
+		bars  []Bar
+		width int
+		want  string
+	}{
+		{name: "empty", bars: nil, width: 10, want: ""},
+		{
+			name:  "single bar",
+			bars:  []Bar{{Label: "Go", Value: 3, Text: "3 B"}},
+			width: 4,
+			want:  "Go ████ 3 B\n",
+		},
+		{
+			// Scaled to the largest value, labels aligned, small values
+			// getting one cell and zero values none
+			name: "scaling",
+			bars: []Bar{
+				{Label: "Node.js", Value: 100, Text: "100 B"},
+				{Label: "Go", Value: 50, Text: "50 B"},
+				{Label: "Rust", Value: 1, Text: "1 B"},
+				{Label: "Java", Value: 0, Text: "0 B"},
+			},
+			width: 10,
+			want: "Node.js ██████████ 100 B\n" +
+				"Go      █████      50 B\n" +
+				"Rust    █          1 B\n" +
+				"Java               0 B\n",
+		},
+		{
+			name:  "all zero",
+			bars:  []Bar{{Label: "Go", Value: 0, Text: "0 B"}},
+			width: 3,
+			want:  "Go     0 B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := plain(BarChart(tt.bars, tt.width)); got != tt.want {
+			t.Errorf("%s: expected:\n%q\ngot:\n%q", tt.name, tt.want, got)
+		}
+	}
+}
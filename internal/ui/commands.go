@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"time"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/raucheacho/rosia-cli/internal/cleaner"
 	"github.com/raucheacho/rosia-cli/internal/scanner"
@@ -11,9 +13,11 @@ import (
 func (m *TUIModel) startScan() tea.Cmd {
 	return func() tea.Msg {
 		opts := scanner.ScanOptions{
-			MaxDepth:      10,
-			IncludeHidden: false,
-			Concurrency:   0, // Use default
+			MaxDepth:       m.options.MaxDepth,
+			IncludeHidden:  m.options.IncludeHidden,
+			IgnorePaths:    m.options.IgnorePaths,
+			Concurrency:    0, // Use default
+			ProtectedPaths: m.options.ProtectedPaths,
 		}
 
 		targetsChan, errChan := m.scanner.ScanAsync(m.ctx, m.scanPaths, opts)
@@ -43,6 +47,30 @@ func (m *TUIModel) startScan() tea.Cmd {
 	}
 }
 
+// rescan scans the paths again, dropping the targets found and their
+// selection
+func (m *TUIModel) rescan() tea.Cmd {
+	m.screen = ScreenScanning
+	m.scanning = true
+	m.scanProgress = 0
+	m.currentDir = ""
+	m.targets = nil
+	m.selected = make(map[int]bool)
+	m.cursor = 0
+	return m.startScan()
+}
+
+// watchInterval is how often the configuration and profile files are
+// checked for changes
+const watchInterval = time.Second
+
+// watchFiles schedules the next check of the configuration and profile files
+func watchFiles() tea.Cmd {
+	return tea.Tick(watchInterval, func(time.Time) tea.Msg {
+		return watchTickMsg{}
+	})
+}
+
 // startClean initiates the cleaning process
 func (m *TUIModel) startClean() tea.Cmd {
 	return func() tea.Msg {
@@ -58,17 +86,29 @@ func (m *TUIModel) startClean() tea.Cmd {
 			return cleanErrorMsg{err: nil} // No targets selected
 		}
 
-		// Clean targets
+		// Clean targets asynchronously so progress can be displayed
 		opts := cleaner.CleanOptions{
 			SkipConfirmation: true,
 			UseTrash:         true,
 			Concurrency:      0,
+			ProtectedPaths:   m.options.ProtectedPaths,
 		}
-		report, err := m.cleaner.Clean(m.ctx, selectedTargets, opts)
+		progressCh, err := m.cleaner.CleanAsync(m.ctx, selectedTargets, opts)
 		if err != nil {
 			return cleanErrorMsg{err: err}
 		}
 
-		return cleanCompleteMsg{report: report}
+		return cleanStartedMsg{progressCh: progressCh, startTime: time.Now()}
+	}
+}
+
+// waitForCleanProgress waits for the next progress update from the cleaner
+func waitForCleanProgress(progressCh <-chan cleaner.CleanProgress) tea.Cmd {
+	return func() tea.Msg {
+		progress, ok := <-progressCh
+		if !ok {
+			return cleanCompleteMsg{}
+		}
+		return cleanProgressMsg{progress: progress}
 	}
 }
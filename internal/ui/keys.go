@@ -54,6 +54,13 @@ func (m *TUIModel) handleSelectionKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.selected[m.cursor] = !m.selected[m.cursor]
 		m.viewport.SetContent(m.renderTargetList())
 
+	case "p":
+		// Toggle permanent deletion (skip trash) for the current target
+		if m.cursor < len(m.targets) {
+			m.targets[m.cursor].Permanent = !m.targets[m.cursor].Permanent
+			m.viewport.SetContent(m.renderTargetList())
+		}
+
 	case "a":
 		// Select all
 		for i := range m.targets {
@@ -61,11 +68,23 @@ func (m *TUIModel) handleSelectionKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		m.viewport.SetContent(m.renderTargetList())
 
+	case "c":
+		// Toggle every target of the current target's category
+		if m.cursor < len(m.targets) {
+			m.toggleCategory(m.targets[m.cursor].Type)
+			m.viewport.SetContent(m.renderTargetList())
+		}
+
 	case "n":
 		// Deselect all
 		m.selected = make(map[int]bool)
 		m.viewport.SetContent(m.renderTargetList())
 
+	case "r":
+		// Scan again, e.g. after editing a profile
+		m.notice = ""
+		return m, m.rescan()
+
 	case "enter":
 		// Move to confirmation screen
 		if m.hasSelection() {
@@ -121,3 +140,20 @@ func (m *TUIModel) hasSelection() bool {
 	}
 	return false
 }
+
+// toggleCategory selects every target of category, or deselects them all
+// when they are already selected
+func (m *TUIModel) toggleCategory(category string) {
+	allSelected := true
+	for i, target := range m.targets {
+		if target.Type == category && !m.selected[i] {
+			allSelected = false
+			break
+		}
+	}
+	for i, target := range m.targets {
+		if target.Type == category {
+			m.selected[i] = !allSelected
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package ui
 
-import "github.com/raucheacho/rosia-cli/pkg/types"
+import (
+	"time"
+
+	"github.com/raucheacho/rosia-cli/internal/cleaner"
+	"github.com/raucheacho/rosia-cli/pkg/types"
+)
 
 // scanProgressMsg represents scan progress updates
 type scanProgressMsg struct {
@@ -18,16 +23,23 @@ type scanErrorMsg struct {
 	err error
 }
 
+// watchTickMsg asks to check the configuration and profile files for changes
+type watchTickMsg struct{}
+
+// cleanStartedMsg represents the start of an async clean
+type cleanStartedMsg struct {
+	progressCh <-chan cleaner.CleanProgress
+	startTime  time.Time
+}
+
 // cleanProgressMsg represents clean progress updates
 type cleanProgressMsg struct {
-	current int
-	total   int
+	progress cleaner.CleanProgress
 }
 
-// cleanCompleteMsg represents clean completion
-type cleanCompleteMsg struct {
-	report *types.CleanReport
-}
+// cleanCompleteMsg represents clean completion; the report has been
+// accumulated from the progress updates
+type cleanCompleteMsg struct{}
 
 // cleanErrorMsg represents clean errors
 type cleanErrorMsg struct {
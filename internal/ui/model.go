@@ -2,6 +2,8 @@ package ui
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -36,6 +38,7 @@ type TUIModel struct {
 	cleaning     bool
 	scanProgress float64
 	currentDir   string
+	notice       string
 	err          error
 
 	// Components
@@ -43,15 +46,21 @@ type TUIModel struct {
 	progress progress.Model
 
 	// Dependencies
-	scanner *scanner.Scanner
-	cleaner *cleaner.Cleaner
-	ctx     context.Context
+	scanner      *scanner.Scanner
+	cleaner      *cleaner.Cleaner
+	ctx          context.Context
+	profileWatch *ProfileWatch // Reloads edited profiles, nil to keep them
+	configWatch  *ConfigWatch  // Reloads the edited configuration, nil to keep it
 
 	// Results
-	cleanReport *types.CleanReport
+	cleanReport     *types.CleanReport
+	cleanProgressCh <-chan cleaner.CleanProgress
+	cleanLast       cleaner.CleanProgress
+	cleanStart      time.Time
 
 	// Configuration
 	scanPaths []string
+	options   Options
 	width     int
 	height    int
 }
@@ -61,7 +70,7 @@ func NewTUIModel(ctx context.Context, scanner *scanner.Scanner, cleaner *cleaner
 	vp := viewport.New(80, 20)
 	vp.Style = lipgloss.NewStyle().
 		BorderStyle(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("62"))
+		BorderForeground(borderColor)
 
 	prog := progress.New(progress.WithDefaultGradient())
 
@@ -84,10 +93,11 @@ func NewTUIModel(ctx context.Context, scanner *scanner.Scanner, cleaner *cleaner
 
 // Init initializes the model
 func (m *TUIModel) Init() tea.Cmd {
-	return tea.Batch(
-		m.startScan(),
-		tea.EnterAltScreen,
-	)
+	cmds := []tea.Cmd{m.startScan(), tea.EnterAltScreen}
+	if m.profileWatch != nil || m.configWatch != nil {
+		cmds = append(cmds, watchFiles())
+	}
+	return tea.Batch(cmds...)
 }
 
 // Update handles messages and updates the model
@@ -118,17 +128,51 @@ func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.viewport.SetContent(m.renderTargetList())
 		return m, nil
 
+	case watchTickMsg:
+		// Files are only reloaded while targets are being selected, never
+		// during a scan or a clean
+		if m.screen != ScreenSelection {
+			return m, watchFiles()
+		}
+		if m.configWatch != nil && m.configWatch.Watcher.Changed() {
+			opts, err := m.configWatch.Reload()
+			if err != nil {
+				m.notice = fmt.Sprintf("Failed to reload the configuration, keeping the previous one: %v", err)
+				return m, watchFiles()
+			}
+			m.options = opts
+			m.notice = "Configuration changed: scanning again with the new settings"
+			return m, tea.Batch(m.rescan(), watchFiles())
+		}
+		if m.profileWatch != nil && m.profileWatch.Watcher.Changed() {
+			if err := m.profileWatch.Reload(); err != nil {
+				m.notice = fmt.Sprintf("Failed to reload profiles: %v", err)
+				return m, watchFiles()
+			}
+			m.notice = "Profiles changed: scanning again with the new profiles"
+			return m, tea.Batch(m.rescan(), watchFiles())
+		}
+		return m, watchFiles()
+
 	case scanErrorMsg:
 		m.err = msg.err
 		m.scanning = false
 		return m, tea.Quit
 
+	case cleanStartedMsg:
+		m.cleanProgressCh = msg.progressCh
+		m.cleanStart = msg.startTime
+		m.cleanReport = types.NewCleanReport()
+		return m, waitForCleanProgress(m.cleanProgressCh)
+
 	case cleanProgressMsg:
-		return m, nil
+		m.cleanLast = msg.progress
+		msg.progress.AddToReport(m.cleanReport)
+		return m, waitForCleanProgress(m.cleanProgressCh)
 
 	case cleanCompleteMsg:
 		m.cleaning = false
-		m.cleanReport = msg.report
+		m.cleanReport.Duration = time.Since(m.cleanStart)
 		m.screen = ScreenSummary
 		return m, nil
 
@@ -5,12 +5,40 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/raucheacho/rosia-cli/internal/cleaner"
+	"github.com/raucheacho/rosia-cli/internal/config"
+	"github.com/raucheacho/rosia-cli/internal/profiles"
 	"github.com/raucheacho/rosia-cli/internal/scanner"
 )
 
-// Run starts the TUI application
-func Run(ctx context.Context, scanner *scanner.Scanner, cleaner *cleaner.Cleaner, scanPaths []string) error {
+// ProfileWatch reloads the profiles of the scanner when their files change,
+// so that the TUI rescans with profiles as they are edited
+type ProfileWatch struct {
+	Watcher *profiles.Watcher // Detects changes to the profile files
+	Reload  func() error      // Loads the profiles again into the scanner's loader
+}
+
+// ConfigWatch reloads the configuration when its files change, so that the
+// TUI rescans with settings such as ignore_paths as they are edited
+type ConfigWatch struct {
+	Watcher *config.Watcher         // Detects changes to the configuration files
+	Reload  func() (Options, error) // Loads the configuration again and returns the options it sets
+}
+
+// Options configure the scans of the TUI
+type Options struct {
+	MaxDepth       int      // Maximum depth to scan (0 = unlimited)
+	IncludeHidden  bool     // Scan hidden files and directories
+	IgnorePaths    []string // Paths to exclude from scanning
+	ProtectedPaths []string // Paths never scanned or cleaned, in addition to the built-in ones
+}
+
+// Run starts the TUI application. watch and configWatch may be nil to never
+// reload the profiles or the configuration.
+func Run(ctx context.Context, scanner *scanner.Scanner, cleaner *cleaner.Cleaner, scanPaths []string, opts Options, watch *ProfileWatch, configWatch *ConfigWatch) error {
 	model := NewTUIModel(ctx, scanner, cleaner, scanPaths)
+	model.options = opts
+	model.profileWatch = watch
+	model.configWatch = configWatch
 
 	p := tea.NewProgram(model, tea.WithAltScreen())
 
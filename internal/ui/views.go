@@ -2,9 +2,13 @@ package ui
 
 import (
 	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/raucheacho/rosia-cli/pkg/progress"
 )
 
 var (
@@ -35,8 +39,89 @@ var (
 
 	infoStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("86"))
+
+	borderColor lipgloss.TerminalColor = lipgloss.Color("62")
 )
 
+// Theme holds the colors of the TUI
+type Theme struct {
+	Title    lipgloss.TerminalColor
+	Selected lipgloss.TerminalColor
+	Cursor   lipgloss.TerminalColor
+	Help     lipgloss.TerminalColor
+	Error    lipgloss.TerminalColor
+	Success  lipgloss.TerminalColor
+	Info     lipgloss.TerminalColor
+	Border   lipgloss.TerminalColor
+}
+
+// themes are the themes of the TUI, by name
+var themes = map[string]Theme{
+	"default": {
+		Title:    lipgloss.Color("205"),
+		Selected: lipgloss.Color("170"),
+		Cursor:   lipgloss.Color("212"),
+		Help:     lipgloss.Color("241"),
+		Error:    lipgloss.Color("196"),
+		Success:  lipgloss.Color("42"),
+		Info:     lipgloss.Color("86"),
+		Border:   lipgloss.Color("62"),
+	},
+	// Darker colors, readable on light terminal backgrounds
+	"light": {
+		Title:    lipgloss.Color("162"),
+		Selected: lipgloss.Color("91"),
+		Cursor:   lipgloss.Color("127"),
+		Help:     lipgloss.Color("243"),
+		Error:    lipgloss.Color("160"),
+		Success:  lipgloss.Color("28"),
+		Info:     lipgloss.Color("30"),
+		Border:   lipgloss.Color("61"),
+	},
+	// No colors, only the bold text of the styles
+	"mono": {
+		Title:    lipgloss.NoColor{},
+		Selected: lipgloss.NoColor{},
+		Cursor:   lipgloss.NoColor{},
+		Help:     lipgloss.NoColor{},
+		Error:    lipgloss.NoColor{},
+		Success:  lipgloss.NoColor{},
+		Info:     lipgloss.NoColor{},
+		Border:   lipgloss.NoColor{},
+	},
+}
+
+// Themes returns the names of the themes, sorted
+func Themes() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetTheme colors the TUI with the theme name, "" being the default theme
+func SetTheme(name string) error {
+	if name == "" {
+		name = "default"
+	}
+	theme, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %s (available: %s)", name, strings.Join(Themes(), ", "))
+	}
+
+	titleStyle = titleStyle.Foreground(theme.Title)
+	selectedStyle = selectedStyle.Foreground(theme.Selected)
+	cursorStyle = cursorStyle.Foreground(theme.Cursor)
+	helpStyle = helpStyle.Foreground(theme.Help)
+	errorStyle = errorStyle.Foreground(theme.Error)
+	successStyle = successStyle.Foreground(theme.Success)
+	infoStyle = infoStyle.Foreground(theme.Info)
+	borderColor = theme.Border
+	return nil
+}
+
 // renderScanningScreen renders the scanning progress screen
 func (m *TUIModel) renderScanningScreen() string {
 	var b strings.Builder
@@ -66,7 +151,11 @@ func (m *TUIModel) renderSelectionScreen() string {
 	b.WriteString("\n\n")
 
 	if len(m.targets) == 0 {
-		b.WriteString(infoStyle.Render("No targets found. Press q to quit."))
+		b.WriteString(infoStyle.Render("No targets found. Press r to rescan or q to quit."))
+		if m.notice != "" {
+			b.WriteString("\n\n")
+			b.WriteString(infoStyle.Render(m.notice))
+		}
 		return b.String()
 	}
 
@@ -89,7 +178,12 @@ func (m *TUIModel) renderSelectionScreen() string {
 		b.WriteString("\n")
 	}
 
-	b.WriteString(helpStyle.Render("↑/↓: navigate • space: select • a: select all • n: deselect all • enter: confirm • q: quit"))
+	if m.notice != "" {
+		b.WriteString(infoStyle.Render(m.notice))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(helpStyle.Render("↑/↓: navigate • space: select • p: toggle permanent • c: select category • a: select all • n: deselect all • r: rescan • enter: confirm • q: quit"))
 
 	return b.String()
 }
@@ -116,6 +210,18 @@ func (m *TUIModel) renderTargetList() string {
 			formatSize(target.Size),
 			target.ProfileName,
 		)
+		if target.Type != "" && target.Type != target.ProfileName {
+			line += " [" + target.Type + "]"
+		}
+		if target.Permanent {
+			line += " [permanent]"
+		}
+		if target.Link != "" {
+			line += " [via " + filepath.Base(target.Link) + "]"
+		}
+		if target.Source != "" {
+			line += " [plugin " + target.Source + "]"
+		}
 
 		if i == m.cursor {
 			line = cursorStyle.Render(line)
@@ -151,7 +257,18 @@ func (m *TUIModel) renderConfirmationScreen() string {
 	))
 
 	b.WriteString(infoStyle.Render("Files will be moved to trash and can be restored later."))
-	b.WriteString("\n\n")
+	b.WriteString("\n")
+	permanentCount := 0
+	for i, target := range m.targets {
+		if m.selected[i] && target.Permanent {
+			permanentCount++
+		}
+	}
+	if permanentCount > 0 {
+		b.WriteString(errorStyle.Render(fmt.Sprintf("%d target(s) marked permanent will be deleted without trash.", permanentCount)))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
 
 	b.WriteString("Do you want to proceed?\n\n")
 	b.WriteString(helpStyle.Render("y/enter: confirm • n/q: cancel"))
@@ -166,8 +283,22 @@ func (m *TUIModel) renderCleaningScreen() string {
 	b.WriteString(titleStyle.Render("🧹 Cleaning targets..."))
 	b.WriteString("\n\n")
 
-	b.WriteString(infoStyle.Render("Please wait while files are being moved to trash..."))
-	b.WriteString("\n\n")
+	if last := m.cleanLast; last.Total > 0 {
+		percent := float64(last.Current) / float64(last.Total)
+		if last.BytesTotal > 0 {
+			percent = float64(last.BytesDone) / float64(last.BytesTotal)
+		}
+		b.WriteString(m.progress.ViewAs(percent))
+		b.WriteString("\n")
+
+		eta := progress.EstimateRemaining(time.Since(m.cleanStart), last.BytesDone, last.BytesTotal)
+		b.WriteString(infoStyle.Render(fmt.Sprintf("%s / %s freed • %d/%d targets • ETA %s",
+			formatSize(last.BytesDone), formatSize(last.BytesTotal), last.Current, last.Total, eta.Round(time.Second))))
+		b.WriteString("\n\n")
+	} else {
+		b.WriteString(infoStyle.Render("Please wait while files are being moved to trash..."))
+		b.WriteString("\n\n")
+	}
 
 	b.WriteString(helpStyle.Render("Press q to quit (cleaning will continue)"))
 
@@ -199,16 +330,60 @@ func (m *TUIModel) renderSummaryScreen() string {
 	b.WriteString(infoStyle.Render(fmt.Sprintf("✓ Duration: %s", m.cleanReport.Duration)))
 	b.WriteString("\n\n")
 
+	// Per-profile breakdown
+	if len(m.cleanReport.Profiles) > 0 {
+		for _, name := range m.cleanReport.ProfileNames() {
+			summary := m.cleanReport.Profiles[name]
+			line := fmt.Sprintf("  %s: %d targets, %s", name, summary.Targets, formatSize(summary.Size))
+			if summary.Errors > 0 {
+				line += fmt.Sprintf(", %d failed", summary.Errors)
+			}
+			b.WriteString(infoStyle.Render(line))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
 	// Errors if any
 	if len(m.cleanReport.Errors) > 0 {
 		b.WriteString(errorStyle.Render(fmt.Sprintf("⚠ %d errors occurred:", len(m.cleanReport.Errors))))
 		b.WriteString("\n")
-		for i, cleanErr := range m.cleanReport.Errors {
-			if i >= 5 {
-				b.WriteString(fmt.Sprintf("  ... and %d more\n", len(m.cleanReport.Errors)-5))
-				break
+		for _, group := range m.cleanReport.GroupErrors() {
+			b.WriteString(fmt.Sprintf("  %s (%d)\n", group.Code.Label(), len(group.Errors)))
+			for i, cleanErr := range group.Errors {
+				if i >= 3 {
+					b.WriteString(fmt.Sprintf("    ... and %d more\n", len(group.Errors)-3))
+					break
+				}
+				b.WriteString(fmt.Sprintf("    • %s: %v\n", cleanErr.Target.Path, cleanErr.Error))
 			}
-			b.WriteString(fmt.Sprintf("  • %s: %v\n", cleanErr.Target.Path, cleanErr.Error))
+			if hint := group.Code.Hint(); hint != "" {
+				b.WriteString(infoStyle.Render("    Hint: " + hint))
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if len(m.cleanReport.Skipped) > 0 {
+		b.WriteString(infoStyle.Render(fmt.Sprintf("%d targets skipped (cancelled)", len(m.cleanReport.Skipped))))
+		b.WriteString("\n\n")
+	}
+
+	// Rebuild hints
+	hints := []string{}
+	for _, name := range m.cleanReport.ProfileNames() {
+		summary := m.cleanReport.Profiles[name]
+		if summary.Targets > 0 && summary.RebuildHint != "" {
+			hints = append(hints, fmt.Sprintf("  • %s: %s", name, summary.RebuildHint))
+		}
+	}
+	if len(hints) > 0 {
+		b.WriteString(infoStyle.Render("To rebuild what was cleaned:"))
+		b.WriteString("\n")
+		for _, hint := range hints {
+			b.WriteString(hint)
+			b.WriteString("\n")
 		}
 		b.WriteString("\n")
 	}
@@ -212,6 +212,15 @@ func (m *progressModel) View() string {
 	)
 }
 
+// EstimateRemaining extrapolates the time left from the time spent so far and
+// the amount of work done. It returns 0 until some work has been done.
+func EstimateRemaining(elapsed time.Duration, done, total int64) time.Duration {
+	if done <= 0 || total <= done {
+		return 0
+	}
+	return time.Duration(float64(elapsed) * float64(total-done) / float64(done))
+}
+
 // SimpleBar is a lightweight progress bar without BubbleTea
 type SimpleBar struct {
 	total   int
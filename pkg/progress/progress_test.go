@@ -198,3 +198,9 @@ func TestSimpleBar_ProgressIndicators(t *testing.T) {
 	assert.Contains(t, output, "4/4")
 	assert.Contains(t, output, "100%")
 }
+
+func TestEstimateRemaining(t *testing.T) {
+	assert.Equal(t, time.Duration(0), EstimateRemaining(time.Second, 0, 100))
+	assert.Equal(t, time.Duration(0), EstimateRemaining(time.Second, 100, 100))
+	assert.Equal(t, 3*time.Second, EstimateRemaining(time.Second, 25, 100))
+}
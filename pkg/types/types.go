@@ -15,7 +15,17 @@
 //	}
 package types
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
 
 // Target represents a cleanable file or directory detected during scanning.
 //
@@ -23,19 +33,56 @@ import "time"
 // a specific file or directory. Targets are created by the scanner engine
 // when matching profile patterns.
 type Target struct {
-	Path         string    // Absolute path to the target file or directory
-	Size         int64     // Total size in bytes
-	Type         string    // Type classification (e.g., "dependency", "build", "cache")
-	ProfileName  string    // Name of the profile that matched this target
-	LastAccessed time.Time // Last access timestamp
-	IsDirectory  bool      // True if target is a directory
+	Path         string    `json:"path"`                   // Absolute path to the target file or directory
+	Size         int64     `json:"size"`                   // Total size in bytes
+	Type         string    `json:"type"`                   // Category of the matched pattern (e.g., "dependencies", "build", "cache"), or the profile name when it has none
+	ProfileName  string    `json:"profile_name"`           // Name of the profile that matched this target
+	LastAccessed time.Time `json:"last_accessed"`          // Last access timestamp
+	IsDirectory  bool      `json:"is_directory"`           // True if target is a directory
+	Keep         []string  `json:"keep,omitempty"`         // Glob patterns, relative to Path, of entries to preserve when cleaning
+	Permanent    bool      `json:"permanent,omitempty"`    // Always delete directly, even when the trash is used
+	RebuildHint  string    `json:"rebuild_hint,omitempty"` // How to regenerate the target, from its profile
+	Link         string    `json:"link,omitempty"`         // Symlink in the project Path was found through, if any
+	Source       string    `json:"source,omitempty"`       // Plugin that reported the target, empty for targets of profiles
+	Virtual      bool      `json:"virtual,omitempty"`      // Deleted by the Source plugin rather than as a file, like a Docker image or a cache its package manager cleans
 }
 
+// Target categories, assigned to profile patterns to classify the targets
+// they match
+const (
+	CategoryDependencies = "dependencies" // Installed packages (e.g. node_modules, venv)
+	CategoryBuild        = "build"        // Build outputs (e.g. dist, target)
+	CategoryCache        = "cache"        // Tool caches (e.g. __pycache__, .dart_tool)
+	CategoryCoverage     = "coverage"     // Test coverage reports
+)
+
+// Categories lists the valid target categories
+var Categories = []string{CategoryDependencies, CategoryBuild, CategoryCache, CategoryCoverage}
+
 // Profile defines cleaning rules and detection patterns for a specific technology stack.
 //
 // Profiles are loaded from JSON files in the profiles/ directory and define:
 //   - Patterns: directories/files to clean (supports glob patterns)
+//   - RegexPatterns: regular expressions for names globs cannot express (optional)
 //   - Detect: files that indicate the technology is present
+//   - Keep: entries inside a matched target to preserve (optional)
+//   - Permanent: patterns whose targets are never moved to trash (optional)
+//   - Extends: the name of a profile whose patterns and detect entries are
+//     inherited (optional)
+//   - MinSize, MinAge: thresholds a target must reach to be reported (optional)
+//   - Categories: the category of each pattern, used as the Type of its
+//     targets (optional)
+//   - StaleAfter: the age under which the targets of a pattern are still
+//     in use and not reported (optional)
+//   - DetectContent: what a detect file must contain to count, for files
+//     shared by several technologies (optional)
+//   - Symlinks: symlinks in the project whose target directories are
+//     cleaned, e.g. Bazel's output links (optional)
+//   - DetectHeuristics: directory structures that indicate the technology,
+//     for ecosystems without a marker file (optional)
+//   - DetectCommand: a command confirming the detection (optional)
+//   - Priority: which profile selects an entry first when several detect
+//     its directory (optional)
 //
 // Example profile for Node.js:
 //
@@ -46,12 +93,53 @@ type Target struct {
 //	  "enabled": true
 //	}
 type Profile struct {
-	Name        string   `json:"name"`        // Display name of the technology
-	Version     string   `json:"version"`     // Profile version (semver)
-	Patterns    []string `json:"patterns"`    // Glob patterns for files/directories to clean
-	Detect      []string `json:"detect"`      // Files that indicate technology presence
-	Description string   `json:"description"` // Human-readable description
-	Enabled     bool     `json:"enabled"`     // Whether profile is enabled
+	Name             string              `json:"name"`                        // Display name of the technology
+	ID               string              `json:"-"`                           // Name of the file the profile was loaded from, without ".json" (e.g. "node")
+	Version          string              `json:"version"`                     // Profile version (semver)
+	Patterns         []string            `json:"patterns"`                    // Glob patterns for files/directories to clean
+	RegexPatterns    []string            `json:"regex_patterns,omitempty"`    // Regular expressions matched against target names, for what globs cannot express
+	Detect           []string            `json:"detect"`                      // Files that indicate technology presence
+	Description      string              `json:"description"`                 // Human-readable description
+	Enabled          bool                `json:"enabled"`                     // Whether profile is enabled
+	Priority         int                 `json:"priority,omitempty"`          // Rank among the profiles detecting a directory, highest first (default 0)
+	Keep             []string            `json:"keep,omitempty"`              // Glob patterns, relative to a target, to preserve when cleaning
+	Permanent        []string            `json:"permanent,omitempty"`         // Patterns whose targets skip the trash (e.g. "__pycache__")
+	RebuildHint      string              `json:"rebuild_hint,omitempty"`      // How to regenerate cleaned targets (e.g. "run npm install")
+	Extends          string              `json:"extends,omitempty"`           // Name of the profile this one inherits from
+	MinSize          string              `json:"min_size,omitempty"`          // Only report targets at least this large (e.g. "500MB")
+	MinAge           string              `json:"min_age,omitempty"`           // Only report targets untouched for this long (e.g. "14d"); with MinSize, either is enough
+	Categories       map[string]string   `json:"categories,omitempty"`        // Category of each pattern (e.g. {"node_modules": "dependencies"})
+	Requires         map[string][]string `json:"requires,omitempty"`          // Detect files a pattern needs in the project, any of which is enough (e.g. {"target": ["pom.xml"]})
+	StaleAfter       map[string]string   `json:"stale_after,omitempty"`       // Only report targets of a pattern untouched for this long (e.g. {"_build/*": "30d"})
+	DetectContent    map[string]string   `json:"detect_content,omitempty"`    // Regular expression a detect file must contain to count (e.g. {"gradle.properties": "android\\."})
+	Symlinks         []string            `json:"symlinks,omitempty"`          // Glob patterns of symlinks whose target directories are cleaned (e.g. "bazel-*")
+	DetectHeuristics []DetectHeuristic   `json:"detect_heuristics,omitempty"` // Directory structures that also indicate the technology
+	DetectCommand    []string            `json:"detect_command,omitempty"`    // Command, run in a detected directory, that must exit with 0 to confirm it (e.g. ["git", "rev-parse"])
+}
+
+// DetectHeuristic detects a project by the structure of a directory, for
+// ecosystems lacking a marker file, e.g. a node_modules directory holding a
+// .package-lock.json and over 1000 files:
+//
+//	{"path": "node_modules", "contains": [".package-lock.json"], "min_files": 1000}
+type DetectHeuristic struct {
+	Path     string   `json:"path,omitempty"`      // Directory inspected, relative to the project (default: the project itself)
+	Contains []string `json:"contains"`            // Glob patterns of entries the directory must all contain
+	MinFiles int      `json:"min_files,omitempty"` // Minimum number of files in the directory, subdirectories included
+}
+
+// String describes the heuristic, e.g. "node_modules with .package-lock.json
+// and at least 1000 files"
+func (h DetectHeuristic) String() string {
+	dir := h.Path
+	if dir == "" {
+		dir = "directory"
+	}
+	description := dir + " with " + strings.Join(h.Contains, ", ")
+	if h.MinFiles > 0 {
+		description += " and at least " + strconv.Itoa(h.MinFiles) + " files"
+	}
+	return description
 }
 
 // Config represents user configuration loaded from ~/.rosiarc.json.
@@ -79,13 +167,150 @@ type Config struct {
 // CleanReport summarizes the results of a cleaning operation.
 //
 // The report includes statistics about deleted files, total space reclaimed,
-// any errors encountered, and items moved to trash for potential restoration.
+// the outcome of every target, and items moved to trash for potential restoration.
+//
+// TotalSize is estimated from the sizes captured at scan time, while
+// ReclaimedSize is measured from the change in filesystem free space. The two
+// can differ when hardlinks, sparse files or the trash are involved.
 type CleanReport struct {
-	TotalSize    int64         // Total bytes deleted
-	FilesDeleted int           // Number of files/directories deleted
-	Errors       []CleanError  // Errors encountered during cleaning
-	Duration     time.Duration // Time taken to complete operation
-	TrashedItems []string      // IDs of items moved to trash
+	TotalSize     int64         `json:"total_size"`     // Estimated bytes deleted (sum of target sizes)
+	ReclaimedSize int64         `json:"reclaimed_size"` // Actual bytes freed on disk
+	FilesDeleted  int           `json:"files_deleted"`  // Number of files/directories deleted
+	Cleaned       []CleanResult `json:"cleaned"`        // Targets cleaned successfully
+	Errors        []CleanError  `json:"errors"`         // Errors encountered during cleaning
+	Duration      time.Duration `json:"duration"`       // Time taken to complete operation (nanoseconds in JSON)
+	TrashedItems  []string      `json:"trashed_items"`  // IDs of items moved to trash
+	Skipped       []Target      `json:"skipped"`        // Targets not processed because the operation was cancelled
+
+	Profiles map[string]*ProfileSummary `json:"profiles"` // Breakdown by profile name
+}
+
+// ProfileSummary aggregates the clean results of a single profile.
+type ProfileSummary struct {
+	Targets     int    `json:"targets"`                // Targets cleaned successfully
+	Size        int64  `json:"size"`                   // Estimated bytes cleaned
+	Errors      int    `json:"errors"`                 // Targets that failed to clean
+	RebuildHint string `json:"rebuild_hint,omitempty"` // How to regenerate what was cleaned
+}
+
+// UnknownProfile is the summary key for targets without a profile name
+const UnknownProfile = "Other"
+
+// NewCleanReport returns an empty report ready to record results
+func NewCleanReport() *CleanReport {
+	return &CleanReport{
+		Cleaned:      []CleanResult{},
+		Errors:       []CleanError{},
+		TrashedItems: []string{},
+		Skipped:      []Target{},
+		Profiles:     map[string]*ProfileSummary{},
+	}
+}
+
+// AddSuccess records a successfully cleaned target and its trash ID, if any
+func (r *CleanReport) AddSuccess(target Target, trashID string) {
+	r.TotalSize += target.Size
+	r.FilesDeleted++
+	r.Cleaned = append(r.Cleaned, CleanResult{Target: target, TrashID: trashID})
+	if trashID != "" {
+		r.TrashedItems = append(r.TrashedItems, trashID)
+	}
+
+	summary := r.profileSummary(target.ProfileName)
+	summary.Targets++
+	summary.Size += target.Size
+	if target.RebuildHint != "" {
+		summary.RebuildHint = target.RebuildHint
+	}
+}
+
+// AddError records a target that failed to clean
+func (r *CleanReport) AddError(target Target, err error) {
+	r.Errors = append(r.Errors, CleanError{Target: target, Code: ClassifyError(err), Error: err})
+	r.profileSummary(target.ProfileName).Errors++
+}
+
+// AddSkipped records a target that was left untouched because the operation
+// was cancelled. Skipped targets are not errors.
+func (r *CleanReport) AddSkipped(target Target) {
+	r.Skipped = append(r.Skipped, target)
+}
+
+// TakeErrors removes the errors accepted by match from the report and returns
+// them, so their targets can be retried and recorded again.
+func (r *CleanReport) TakeErrors(match func(CleanError) bool) []CleanError {
+	taken := []CleanError{}
+	remaining := r.Errors[:0]
+	for _, cleanErr := range r.Errors {
+		if !match(cleanErr) {
+			remaining = append(remaining, cleanErr)
+			continue
+		}
+		taken = append(taken, cleanErr)
+		r.profileSummary(cleanErr.Target.ProfileName).Errors--
+	}
+	r.Errors = remaining
+	return taken
+}
+
+// ErrorGroup holds the errors of a report that share an ErrorCode
+type ErrorGroup struct {
+	Code   ErrorCode
+	Errors []CleanError
+}
+
+// GroupErrors groups the errors by code, in order of first occurrence
+func (r *CleanReport) GroupErrors() []ErrorGroup {
+	groups := []ErrorGroup{}
+	index := make(map[ErrorCode]int)
+	for _, cleanErr := range r.Errors {
+		i, ok := index[cleanErr.Code]
+		if !ok {
+			i = len(groups)
+			index[cleanErr.Code] = i
+			groups = append(groups, ErrorGroup{Code: cleanErr.Code})
+		}
+		groups[i].Errors = append(groups[i].Errors, cleanErr)
+	}
+	return groups
+}
+
+// ProfileNames returns the profiles in the breakdown, largest first
+func (r *CleanReport) ProfileNames() []string {
+	names := make([]string, 0, len(r.Profiles))
+	for name := range r.Profiles {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := r.Profiles[names[i]], r.Profiles[names[j]]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// profileSummary returns the breakdown entry for a profile, creating it if needed
+func (r *CleanReport) profileSummary(name string) *ProfileSummary {
+	if name == "" {
+		name = UnknownProfile
+	}
+	if r.Profiles == nil {
+		r.Profiles = map[string]*ProfileSummary{}
+	}
+	summary, ok := r.Profiles[name]
+	if !ok {
+		summary = &ProfileSummary{}
+		r.Profiles[name] = summary
+	}
+	return summary
+}
+
+// CleanResult describes a target that was cleaned successfully.
+type CleanResult struct {
+	Target  Target `json:"target"`             // The target that was cleaned
+	TrashID string `json:"trash_id,omitempty"` // Trash ID when moved to trash
 }
 
 // CleanError represents an error that occurred while cleaning a specific target.
@@ -93,8 +318,113 @@ type CleanReport struct {
 // Errors are isolated per target, allowing the cleaning operation to continue
 // even if individual targets fail.
 type CleanError struct {
-	Target Target // The target that failed to clean
-	Error  error  // The error that occurred
+	Target Target    // The target that failed to clean
+	Code   ErrorCode // Classification of Error
+	Error  error     // The error that occurred
+}
+
+// MarshalJSON encodes the error as its message, since error values have no
+// JSON representation of their own.
+func (e CleanError) MarshalJSON() ([]byte, error) {
+	var message string
+	if e.Error != nil {
+		message = e.Error.Error()
+	}
+	return json.Marshal(struct {
+		Target Target    `json:"target"`
+		Code   ErrorCode `json:"code"`
+		Error  string    `json:"error"`
+	}{e.Target, e.Code, message})
+}
+
+// ErrorCode classifies why a target failed to clean, so errors can be grouped
+// and paired with a suggested fix.
+type ErrorCode string
+
+// Error codes assigned by ClassifyError
+const (
+	ErrorCodePermissionDenied ErrorCode = "permission_denied"
+	ErrorCodeNotFound         ErrorCode = "not_found"
+	ErrorCodeCrossDevice      ErrorCode = "cross_device"
+	ErrorCodeInUse            ErrorCode = "in_use"
+	ErrorCodeProtected        ErrorCode = "protected"
+	ErrorCodeTrashFull        ErrorCode = "trash_full"
+	ErrorCodeCancelled        ErrorCode = "cancelled"
+	ErrorCodeUnknown          ErrorCode = "unknown"
+)
+
+// ClassifyError returns the ErrorCode for err, looking through wrapped errors
+func ClassifyError(err error) ErrorCode {
+	var (
+		denied    ErrPermissionDenied
+		notFound  ErrPathNotFound
+		inUse     ErrTargetInUse
+		protected ErrProtectedPath
+		trashFull ErrTrashFull
+	)
+
+	switch {
+	case errors.As(err, &denied), errors.Is(err, fs.ErrPermission):
+		return ErrorCodePermissionDenied
+	case errors.As(err, &notFound), errors.Is(err, fs.ErrNotExist):
+		return ErrorCodeNotFound
+	case errors.Is(err, syscall.EXDEV):
+		return ErrorCodeCrossDevice
+	case errors.As(err, &inUse):
+		return ErrorCodeInUse
+	case errors.As(err, &protected):
+		return ErrorCodeProtected
+	case errors.As(err, &trashFull):
+		return ErrorCodeTrashFull
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorCodeCancelled
+	default:
+		return ErrorCodeUnknown
+	}
+}
+
+// Label returns a short human-readable name for the code
+func (c ErrorCode) Label() string {
+	switch c {
+	case ErrorCodePermissionDenied:
+		return "Permission denied"
+	case ErrorCodeNotFound:
+		return "Not found"
+	case ErrorCodeCrossDevice:
+		return "Cross-device move"
+	case ErrorCodeInUse:
+		return "In use"
+	case ErrorCodeProtected:
+		return "Protected path"
+	case ErrorCodeTrashFull:
+		return "Trash full"
+	case ErrorCodeCancelled:
+		return "Cancelled"
+	default:
+		return "Other"
+	}
+}
+
+// Hint suggests how to fix errors with this code, or returns an empty string
+func (c ErrorCode) Hint() string {
+	switch c {
+	case ErrorCodePermissionDenied:
+		return "fix the ownership (e.g. files created by Docker) or re-run with --sudo"
+	case ErrorCodeNotFound:
+		return "the target was removed since the scan; re-run with --rescan"
+	case ErrorCodeCrossDevice:
+		return "the trash is on another filesystem; use --no-trash for these targets"
+	case ErrorCodeInUse:
+		return "wait for the build to finish or re-run with --ignore-running"
+	case ErrorCodeProtected:
+		return "the path is protected and never cleaned; see protected_paths"
+	case ErrorCodeTrashFull:
+		return "empty old items from the trash or use --no-trash"
+	case ErrorCodeCancelled:
+		return "run 'rosia clean --resume' to finish the clean"
+	default:
+		return ""
+	}
 }
 
 // TrashMetadata stores information about trashed items for restoration.
@@ -102,11 +432,26 @@ type CleanError struct {
 // Metadata is persisted as JSON alongside trashed items in ~/.rosia/trash/
 // and enables restoration to the original location.
 type TrashMetadata struct {
-	ID           string    `json:"id"`            // Unique identifier (timestamp-based)
-	OriginalPath string    `json:"original_path"` // Original location before deletion
-	Size         int64     `json:"size"`          // Size in bytes
-	DeletedAt    time.Time `json:"deleted_at"`    // Deletion timestamp
-	ProfileName  string    `json:"profile_name"`  // Profile that matched this item
+	ID           string          `json:"id"`                   // Unique identifier (timestamp-based)
+	OriginalPath string          `json:"original_path"`        // Original location before deletion
+	Size         int64           `json:"size"`                 // Size in bytes
+	DeletedAt    time.Time       `json:"deleted_at"`           // Deletion timestamp
+	ProfileName  string          `json:"profile_name"`         // Profile that matched this item
+	Compressed   bool            `json:"compressed,omitempty"` // Content is stored as a tar.zst archive
+	Checksum     string          `json:"checksum,omitempty"`   // Digest of the stored content, verified on restore
+	Pinned       bool            `json:"pinned,omitempty"`     // Kept by retention cleanup until purged
+	Retention    time.Duration   `json:"retention,omitempty"`  // Overrides the configured retention period (0 = default)
+	Attributes   *FileAttributes `json:"attributes,omitempty"` // Attributes of the trashed path itself (nil for older items)
+}
+
+// FileAttributes describes the permissions, ownership and extended
+// attributes of a file, as captured when it is moved to the trash.
+type FileAttributes struct {
+	Mode       fs.FileMode       `json:"mode"`                  // Type and permission bits, including setuid, setgid and sticky
+	UID        int               `json:"uid"`                   // Owner user ID (-1 when unknown)
+	GID        int               `json:"gid"`                   // Owner group ID (-1 when unknown)
+	LinkTarget string            `json:"link_target,omitempty"` // Target of a symlink
+	Xattrs     map[string][]byte `json:"xattrs,omitempty"`      // Extended attributes by name
 }
 
 // TrashItem represents a trashed item with its metadata and current location.
@@ -114,11 +459,33 @@ type TrashMetadata struct {
 // TrashItems are returned by the trash system's List() method and include
 // both the metadata and the current trash path.
 type TrashItem struct {
-	ID           string    // Unique identifier
-	OriginalPath string    // Original location
-	Size         int64     // Size in bytes
-	DeletedAt    time.Time // Deletion timestamp
-	TrashPath    string    // Current location in trash
+	ID           string        // Unique identifier
+	OriginalPath string        // Original location
+	Size         int64         // Size in bytes
+	DeletedAt    time.Time     // Deletion timestamp
+	ProfileName  string        // Profile that matched this item
+	Pinned       bool          // Kept by retention cleanup until purged
+	Retention    time.Duration // Overrides the configured retention period (0 = default)
+	TrashPath    string        // Current location in trash
+}
+
+// TrashStats summarizes the contents of the trash.
+//
+// TrashStats are returned by the trash system's Stats() method. TotalSize is
+// the size of the items when they were trashed, while DiskUsage is the space
+// the trash takes up now, which is smaller for compressed items.
+type TrashStats struct {
+	Items     int                           `json:"items"`            // Number of trashed items
+	TotalSize int64                         `json:"total_size"`       // Original size of all items in bytes
+	DiskUsage int64                         `json:"disk_usage"`       // Bytes used by the trash directory
+	Oldest    *TrashItem                    `json:"oldest,omitempty"` // Item trashed first, nil when empty
+	Profiles  map[string]*TrashProfileStats `json:"profiles"`         // Breakdown by profile name
+}
+
+// TrashProfileStats aggregates the trashed items of a single profile.
+type TrashProfileStats struct {
+	Items int   `json:"items"` // Number of trashed items
+	Size  int64 `json:"size"`  // Original size in bytes
 }
 
 // ErrPermissionDenied indicates insufficient permissions to access or modify a path.
@@ -147,6 +514,39 @@ func (e ErrPathNotFound) Error() string {
 	return "path not found: " + e.Path
 }
 
+// ErrTargetInUse indicates a process is currently using a target.
+//
+// This error is returned when a process, such as a cargo or npm build, has
+// its working directory or an open file inside the target, since deleting it
+// would corrupt the running build.
+type ErrTargetInUse struct {
+	Path    string // The target that is in use
+	Process string // Name of the process using it
+	PID     int    // Process ID
+}
+
+// Error implements the error interface.
+func (e ErrTargetInUse) Error() string {
+	return "target in use by " + e.Process + " (pid " + strconv.Itoa(e.PID) + "): " + e.Path
+}
+
+// ErrProtectedPath indicates a path rosia refuses to clean.
+//
+// This error is returned when a target is, contains or is inside a protected
+// path, such as the home directory, a system directory or the trash.
+type ErrProtectedPath struct {
+	Path      string // The target that was refused
+	Protected string // The protected path it would remove or is inside
+}
+
+// Error implements the error interface.
+func (e ErrProtectedPath) Error() string {
+	if e.Path == e.Protected {
+		return "protected path: " + e.Path
+	}
+	return "protected path: " + e.Path + " (protected: " + e.Protected + ")"
+}
+
 // ErrTrashFull indicates the trash directory has exceeded its size limit.
 //
 // This error is returned when attempting to move items to trash would exceed
@@ -161,6 +561,22 @@ func (e ErrTrashFull) Error() string {
 	return "trash directory is full"
 }
 
+// ErrChecksumMismatch indicates the content of a trashed item has changed.
+//
+// This error is returned when the stored content of a trash item no longer
+// matches the checksum recorded when it was trashed, because files in the
+// trash were modified or partially lost.
+type ErrChecksumMismatch struct {
+	ID       string // Trash item ID
+	Expected string // Checksum recorded when the item was trashed
+	Actual   string // Checksum of the current content
+}
+
+// Error implements the error interface.
+func (e ErrChecksumMismatch) Error() string {
+	return "trash item " + e.ID + " was modified or is incomplete (checksum mismatch)"
+}
+
 // ErrPluginLoadFailed indicates a plugin failed to load.
 //
 // This error is returned when a plugin cannot be loaded due to missing files,
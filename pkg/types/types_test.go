@@ -0,0 +1,133 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanReport_AddResults(t *testing.T) {
+	report := NewCleanReport()
+	report.AddSuccess(Target{Path: "/a", Size: 10}, "trash-a")
+	report.AddSuccess(Target{Path: "/b", Size: 5}, "")
+	report.AddError(Target{Path: "/c", Size: 1}, errors.New("boom"))
+
+	assert.Equal(t, int64(15), report.TotalSize)
+	assert.Equal(t, 2, report.FilesDeleted)
+	assert.Len(t, report.Cleaned, 2)
+	assert.Equal(t, []string{"trash-a"}, report.TrashedItems)
+	assert.Len(t, report.Errors, 1)
+
+	report.AddSkipped(Target{Path: "/d", Size: 7})
+	assert.Len(t, report.Skipped, 1)
+	assert.Equal(t, int64(15), report.TotalSize)
+	assert.Len(t, report.Errors, 1)
+}
+
+func TestCleanReport_MarshalJSON(t *testing.T) {
+	report := NewCleanReport()
+	report.AddSuccess(Target{Path: "/a", Size: 10, ProfileName: "node"}, "trash-a")
+	report.AddError(Target{Path: "/c"}, errors.New("permission denied: /c"))
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	assert.Equal(t, float64(10), decoded["total_size"])
+	assert.Equal(t, []interface{}{"trash-a"}, decoded["trashed_items"])
+
+	cleaned := decoded["cleaned"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "trash-a", cleaned["trash_id"])
+	assert.Equal(t, "/a", cleaned["target"].(map[string]interface{})["path"])
+
+	cleanErr := decoded["errors"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "permission denied: /c", cleanErr["error"])
+	assert.Equal(t, "unknown", cleanErr["code"])
+	assert.Equal(t, "/c", cleanErr["target"].(map[string]interface{})["path"])
+}
+
+func TestCleanReport_ProfileBreakdown(t *testing.T) {
+	report := NewCleanReport()
+	report.AddSuccess(Target{Path: "/a/node_modules", Size: 10, ProfileName: "Node.js"}, "")
+	report.AddSuccess(Target{Path: "/b/node_modules", Size: 5, ProfileName: "Node.js"}, "")
+	report.AddSuccess(Target{Path: "/c/target", Size: 40, ProfileName: "Rust"}, "")
+	report.AddError(Target{Path: "/d/node_modules", Size: 3, ProfileName: "Node.js"}, errors.New("boom"))
+	report.AddSuccess(Target{Path: "/e/tmp", Size: 1}, "")
+
+	assert.Equal(t, ProfileSummary{Targets: 2, Size: 15, Errors: 1}, *report.Profiles["Node.js"])
+	assert.Equal(t, ProfileSummary{Targets: 1, Size: 40}, *report.Profiles["Rust"])
+	assert.Equal(t, ProfileSummary{Targets: 1, Size: 1}, *report.Profiles[UnknownProfile])
+	assert.Equal(t, []string{"Rust", "Node.js", UnknownProfile}, report.ProfileNames())
+}
+
+func TestCleanReport_RebuildHints(t *testing.T) {
+	report := NewCleanReport()
+	report.AddSuccess(Target{Path: "/a/target", ProfileName: "Rust", RebuildHint: "run cargo build"}, "")
+	report.AddError(Target{Path: "/b/node_modules", ProfileName: "Node.js", RebuildHint: "run npm install"}, errors.New("boom"))
+
+	assert.Equal(t, "run cargo build", report.Profiles["Rust"].RebuildHint)
+	// Failed targets were not removed, so there is nothing to rebuild
+	assert.Empty(t, report.Profiles["Node.js"].RebuildHint)
+}
+
+func TestCleanReport_TakeErrors(t *testing.T) {
+	report := NewCleanReport()
+	report.AddError(Target{Path: "/a", ProfileName: "Rust"}, ErrPermissionDenied{Path: "/a"})
+	report.AddError(Target{Path: "/b", ProfileName: "Rust"}, errors.New("boom"))
+
+	taken := report.TakeErrors(func(cleanErr CleanError) bool {
+		_, ok := cleanErr.Error.(ErrPermissionDenied)
+		return ok
+	})
+
+	require.Len(t, taken, 1)
+	assert.Equal(t, "/a", taken[0].Target.Path)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, "/b", report.Errors[0].Target.Path)
+	assert.Equal(t, 1, report.Profiles["Rust"].Errors)
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		code ErrorCode
+	}{
+		{ErrPermissionDenied{Path: "/a"}, ErrorCodePermissionDenied},
+		{fmt.Errorf("failed to delete: %w", fs.ErrPermission), ErrorCodePermissionDenied},
+		{ErrPathNotFound{Path: "/a"}, ErrorCodeNotFound},
+		{&os.LinkError{Op: "rename", Old: "/a", New: "/b", Err: syscall.EXDEV}, ErrorCodeCrossDevice},
+		{ErrTargetInUse{Path: "/a", Process: "cargo", PID: 1}, ErrorCodeInUse},
+		{ErrProtectedPath{Path: "/usr/lib/node_modules", Protected: "/usr"}, ErrorCodeProtected},
+		{fmt.Errorf("failed to move to trash: %w", ErrTrashFull{}), ErrorCodeTrashFull},
+		{context.Canceled, ErrorCodeCancelled},
+		{errors.New("boom"), ErrorCodeUnknown},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.code, ClassifyError(tt.err), tt.err.Error())
+	}
+}
+
+func TestCleanReport_GroupErrors(t *testing.T) {
+	report := NewCleanReport()
+	report.AddError(Target{Path: "/a"}, ErrPermissionDenied{Path: "/a"})
+	report.AddError(Target{Path: "/b"}, ErrPathNotFound{Path: "/b"})
+	report.AddError(Target{Path: "/c"}, ErrPermissionDenied{Path: "/c"})
+
+	groups := report.GroupErrors()
+	require.Len(t, groups, 2)
+	assert.Equal(t, ErrorCodePermissionDenied, groups[0].Code)
+	assert.Len(t, groups[0].Errors, 2)
+	assert.Equal(t, ErrorCodeNotFound, groups[1].Code)
+	assert.NotEmpty(t, groups[0].Code.Hint())
+}